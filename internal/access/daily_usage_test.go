@@ -0,0 +1,42 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+func TestLoadTodayUsageAmountFollowsBillingTimezone(t *testing.T) {
+	db := openAccessTestDB(t)
+	if errMigrate := db.AutoMigrate(&models.Usage{}); errMigrate != nil {
+		t.Fatalf("migrate usage: %v", errMigrate)
+	}
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	userID := uint64(1)
+	// 03:00 UTC on 2026-03-08 is still the evening of 2026-03-07 in New York.
+	for _, row := range []models.Usage{
+		{UserID: &userID, Provider: "codex", Model: "m", RequestedAt: time.Date(2026, 3, 8, 3, 0, 0, 0, time.UTC), CostMicros: 1_000_000},
+		{UserID: &userID, Provider: "codex", Model: "m", RequestedAt: time.Date(2026, 3, 8, 11, 0, 0, 0, time.UTC), CostMicros: 2_000_000},
+	} {
+		if errCreate := db.Create(&row).Error; errCreate != nil {
+			t.Fatalf("create usage: %v", errCreate)
+		}
+	}
+	now := time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	internalsettings.StoreDBConfig(time.Now(), nil)
+	if used, errLoad := loadTodayUsageAmount(ctx, db, userID, now); errLoad != nil || used != 3 {
+		t.Fatalf("expected both rows on the UTC day, got %v %v", used, errLoad)
+	}
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.BillingTimezoneKey: json.RawMessage(`"America/New_York"`),
+	})
+	if used, errLoad := loadTodayUsageAmount(ctx, db, userID, now); errLoad != nil || used != 2 {
+		t.Fatalf("expected only the New York day's row, got %v %v", used, errLoad)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -19,9 +21,23 @@ import (
 // ProviderTypeDBAPIKey identifies the database API key access provider.
 const ProviderTypeDBAPIKey = "db-api-key"
 
+// apiKeyLastUsedThrottle bounds how often last_used_at is written for a single key.
+const apiKeyLastUsedThrottle = time.Minute
+
 // ErrInsufficientBalance indicates the user has no valid quota or prepaid balance.
 var ErrInsufficientBalance = errors.New("insufficient balance")
 
+// ErrDailySpendCapExceeded indicates the user has reached their daily spend cap.
+var ErrDailySpendCapExceeded = errors.New("daily spend cap exceeded")
+
+// Access metadata keys carrying the caller's remaining bill quota.
+const (
+	// MetadataQuotaRemaining holds the remaining quota across active bills.
+	MetadataQuotaRemaining = "quota_remaining"
+	// MetadataQuotaDailyRemaining holds today's remaining quota for daily-limited bills.
+	MetadataQuotaDailyRemaining = "quota_daily_remaining"
+)
+
 // DBAPIKeyProvider authenticates requests using API keys stored in the database.
 type DBAPIKeyProvider struct {
 	db *gorm.DB
@@ -130,25 +146,39 @@ func (p *DBAPIKeyProvider) Authenticate(ctx context.Context, r *http.Request) (*
 		return nil, fmt.Errorf("db api key provider: query failed: %w", err)
 	}
 
+	var quota *billQuotaSummary
 	if apiKey.User != nil {
-		if apiKey.User.Disabled {
+		if apiKey.User.SignInBlock() != "" {
 			return nil, sdkaccess.ErrInvalidCredential
 		}
-		if apiKey.UserID != nil {
-			ok, errBalance := hasValidBillOrPrepaidBalance(ctx, p.db, *apiKey.UserID)
+		if apiKey.UserID != nil && !isAccountInfoRequest(r.Method, path) {
+			ok, summary, errBalance := hasValidBillOrPrepaidBalance(ctx, p.db, *apiKey.UserID)
 			if errBalance != nil {
 				return nil, fmt.Errorf("db api key provider: balance check failed: %w", errBalance)
 			}
 			if !ok {
-				return nil, ErrInsufficientBalance
+				policy, debt, errOverage := billing.LoadUserOverage(ctx, p.db, *apiKey.UserID)
+				if errOverage != nil {
+					return nil, fmt.Errorf("db api key provider: overage check failed: %w", errOverage)
+				}
+				if !policy.AllowsRequest(debt) {
+					return nil, ErrInsufficientBalance
+				}
+			}
+			quota = &summary
+			if apiKey.User.DailySpendCap > 0 {
+				usedToday, errUsage := loadTodayUsageAmount(ctx, p.db, *apiKey.UserID, time.Now().UTC())
+				if errUsage != nil {
+					return nil, fmt.Errorf("db api key provider: spend cap check failed: %w", errUsage)
+				}
+				if usedToday >= apiKey.User.DailySpendCap {
+					return nil, ErrDailySpendCapExceeded
+				}
 			}
 		}
 	}
 
-	now := time.Now().UTC()
-	_ = p.db.WithContext(ctx).Model(&models.APIKey{}).
-		Where("id = ?", apiKey.ID).
-		Update("last_used_at", &now).Error
+	touchAPIKeyLastUsed(ctx, p.db, &apiKey, time.Now().UTC())
 
 	meta := map[string]string{
 		"api_key_id":   strconv.FormatUint(apiKey.ID, 10),
@@ -158,6 +188,12 @@ func (p *DBAPIKeyProvider) Authenticate(ctx context.Context, r *http.Request) (*
 	if apiKey.UserID != nil {
 		meta["user_id"] = strconv.FormatUint(*apiKey.UserID, 10)
 	}
+	if quota != nil && quota.LeftQuota > 0 {
+		meta[MetadataQuotaRemaining] = strconv.FormatFloat(quota.LeftQuota, 'f', -1, 64)
+		if remaining, limited := quota.dailyRemaining(); limited {
+			meta[MetadataQuotaDailyRemaining] = strconv.FormatFloat(remaining, 'f', -1, 64)
+		}
+	}
 
 	return &sdkaccess.Result{
 		Provider:  p.name,
@@ -166,6 +202,29 @@ func (p *DBAPIKeyProvider) Authenticate(ctx context.Context, r *http.Request) (*
 	}, nil
 }
 
+// touchAPIKeyLastUsed records that apiKey was used at now. Writes are skipped while the
+// stored value is younger than apiKeyLastUsedThrottle, and the conditional update keeps
+// concurrent requests from rewriting the same row.
+func touchAPIKeyLastUsed(ctx context.Context, db *gorm.DB, apiKey *models.APIKey, now time.Time) {
+	if apiKey.LastUsedAt != nil && now.Sub(*apiKey.LastUsedAt) < apiKeyLastUsedThrottle {
+		return
+	}
+	threshold := now.Add(-apiKeyLastUsedThrottle)
+	_ = db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", apiKey.ID, threshold).
+		Update("last_used_at", &now).Error
+}
+
+// isAccountInfoRequest reports whether the request reads the /v1/me balance or usage summary,
+// which stay reachable without balance so users can check why they were cut off.
+func isAccountInfoRequest(method, path string) bool {
+	if method != http.MethodGet {
+		return false
+	}
+	path = strings.TrimRight(path, "/")
+	return path == "/v1/me/balance" || path == "/v1/me/usage"
+}
+
 // extractToken extracts an API key token from headers or query parameters.
 func extractToken(r *http.Request, header string, scheme string, allowXAPIKey bool) string {
 	header = strings.TrimSpace(header)
@@ -200,28 +259,65 @@ func extractToken(r *http.Request, header string, scheme string, allowXAPIKey bo
 }
 
 // hasValidBillOrPrepaidBalance checks if a user has active bill quota or prepaid balance.
-func hasValidBillOrPrepaidBalance(ctx context.Context, db *gorm.DB, userID uint64) (bool, error) {
-	okBill, errBill := hasValidBillQuota(ctx, db, userID)
+// The bill quota summary is returned so callers can report the remaining amounts.
+func hasValidBillOrPrepaidBalance(ctx context.Context, db *gorm.DB, userID uint64) (bool, billQuotaSummary, error) {
+	summary, errBill := loadBillQuota(ctx, db, userID)
 	if errBill != nil {
-		return false, errBill
+		return false, summary, errBill
+	}
+	if summary.usable() {
+		return true, summary, nil
+	}
+	okPrepaid, errPrepaid := hasValidPrepaidBalance(ctx, db, userID)
+	return okPrepaid, summary, errPrepaid
+}
+
+// billQuotaSummary aggregates a user's active paid bills.
+type billQuotaSummary struct {
+	LeftQuota      float64 `gorm:"column:left_quota"`      // Total remaining quota across bills.
+	DailyQuota     float64 `gorm:"column:daily_quota"`     // Sum of daily quotas for limited plans.
+	UnlimitedDaily int64   `gorm:"column:unlimited_daily"` // Count of unlimited daily plans.
+	UsedToday      float64 `gorm:"-"`                      // Today's usage cost, loaded for daily-limited bills.
+}
+
+// dailyLimited reports whether every active bill carries a daily quota.
+func (s billQuotaSummary) dailyLimited() bool {
+	return s.UnlimitedDaily == 0 && s.DailyQuota > 0
+}
+
+// dailyRemaining returns today's remaining daily quota and whether a daily limit applies.
+func (s billQuotaSummary) dailyRemaining() (float64, bool) {
+	if !s.dailyLimited() {
+		return 0, false
+	}
+	remaining := s.DailyQuota - s.UsedToday
+	if remaining < 0 {
+		remaining = 0
 	}
-	if okBill {
-		return true, nil
+	if remaining > s.LeftQuota {
+		remaining = s.LeftQuota
 	}
-	return hasValidPrepaidBalance(ctx, db, userID)
+	return remaining, true
 }
 
-// hasValidBillQuota checks if the user has paid bill quota remaining.
-func hasValidBillQuota(ctx context.Context, db *gorm.DB, userID uint64) (bool, error) {
+// usable reports whether the bills still allow spending today.
+func (s billQuotaSummary) usable() bool {
+	if s.LeftQuota <= 0 {
+		return false
+	}
+	if !s.dailyLimited() {
+		return true
+	}
+	return s.UsedToday < s.DailyQuota
+}
+
+// loadBillQuota summarizes the user's paid bill quota for the current period.
+func loadBillQuota(ctx context.Context, db *gorm.DB, userID uint64) (billQuotaSummary, error) {
+	var summary billQuotaSummary
 	if db == nil {
-		return false, errors.New("nil db")
+		return summary, errors.New("nil db")
 	}
 	now := time.Now().UTC()
-	var summary struct {
-		LeftQuota      float64 `gorm:"column:left_quota"`      // Total remaining quota across bills.
-		DailyQuota     float64 `gorm:"column:daily_quota"`     // Sum of daily quotas for limited plans.
-		UnlimitedDaily int64   `gorm:"column:unlimited_daily"` // Count of unlimited daily plans.
-	}
 	if errSummary := db.WithContext(ctx).
 		Model(&models.Bill{}).
 		Select(`
@@ -232,33 +328,29 @@ func hasValidBillQuota(ctx context.Context, db *gorm.DB, userID uint64) (bool, e
 		Where("user_id = ? AND is_enabled = ? AND status = ? AND left_quota > 0", userID, true, models.BillStatusPaid).
 		Where("period_start <= ? AND period_end >= ?", now, now).
 		Scan(&summary).Error; errSummary != nil {
-		return false, errSummary
-	}
-	if summary.LeftQuota <= 0 {
-		return false, nil
+		return summary, errSummary
 	}
-	if summary.UnlimitedDaily > 0 || summary.DailyQuota <= 0 {
-		return true, nil
+	if summary.LeftQuota <= 0 || !summary.dailyLimited() {
+		return summary, nil
 	}
 	usedToday, errUsage := loadTodayUsageAmount(ctx, db, userID, now)
 	if errUsage != nil {
-		return false, errUsage
+		return summary, errUsage
 	}
-	return usedToday < summary.DailyQuota, nil
+	summary.UsedToday = usedToday
+	return summary, nil
 }
 
-// loadTodayUsageAmount calculates today's usage cost for the user in local time.
+// loadTodayUsageAmount calculates the user's usage cost for the current BILLING_TIMEZONE day.
 func loadTodayUsageAmount(ctx context.Context, db *gorm.DB, userID uint64, now time.Time) (float64, error) {
 	if db == nil {
 		return 0, errors.New("nil db")
 	}
-	loc := time.Local
-	localNow := now.In(loc)
-	todayStart := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, loc)
+	todayStart := internalsettings.BillingDayStart(now)
 	var costMicros int64
 	if errSum := db.WithContext(ctx).
 		Model(&models.Usage{}).
-		Where("user_id = ? AND requested_at >= ?", userID, todayStart).
+		Where("user_id = ? AND requested_at >= ?", userID, todayStart.UTC()).
 		Select("COALESCE(SUM(cost_micros), 0)").
 		Scan(&costMicros).Error; errSum != nil {
 		return 0, errSum
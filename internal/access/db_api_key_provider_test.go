@@ -0,0 +1,84 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestCappedKeyOnlyReachesAccountReads(t *testing.T) {
+	db := openAccessTestDB(t)
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.Bill{}, &models.PrepaidCard{}, &models.Usage{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	user := models.User{Username: "capped", Email: "capped@example.com", Password: "x", Active: true, DailySpendCap: 1}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	now := time.Now().UTC()
+	if errCreate := db.Create(&models.PrepaidCard{Name: "card", CardSN: "SN1", Password: "pw", Amount: 10, Balance: 10, IsEnabled: true, RedeemedUserID: &user.ID, RedeemedAt: &now}).Error; errCreate != nil {
+		t.Fatalf("create card: %v", errCreate)
+	}
+	if errCreate := db.Create(&models.Usage{UserID: &user.ID, Provider: "codex", Model: "m", RequestedAt: now, CostMicros: 2_000_000}).Error; errCreate != nil {
+		t.Fatalf("create usage: %v", errCreate)
+	}
+	if errCreate := db.Create(&models.APIKey{UserID: &user.ID, Name: "key", APIKey: "sk-capped", Active: true}).Error; errCreate != nil {
+		t.Fatalf("create key: %v", errCreate)
+	}
+
+	p := &DBAPIKeyProvider{db: db, name: ProviderTypeDBAPIKey, header: "Authorization", scheme: "Bearer"}
+	authenticate := func(method, path string) error {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer sk-capped")
+		_, errAuth := p.Authenticate(context.Background(), req)
+		return errAuth
+	}
+	for _, tc := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/me/api-keys"},
+		{http.MethodDelete, "/v1/me/api-keys/1"},
+		{http.MethodPost, "/v1/me/balance"},
+		{http.MethodPost, "/v1/chat/completions"},
+	} {
+		if errAuth := authenticate(tc.method, tc.path); !errors.Is(errAuth, ErrDailySpendCapExceeded) {
+			t.Fatalf("%s %s: expected the spend cap to apply, got %v", tc.method, tc.path, errAuth)
+		}
+	}
+	for _, path := range []string{"/v1/me/balance", "/v1/me/usage"} {
+		if errAuth := authenticate(http.MethodGet, path); errAuth != nil {
+			t.Fatalf("GET %s: expected account reads to stay reachable, got %v", path, errAuth)
+		}
+	}
+}
+
+func TestUnverifiedUserKeyIsRejected(t *testing.T) {
+	db := openAccessTestDB(t)
+	if errMigrate := db.AutoMigrate(&models.User{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	user := models.User{Username: "pending", Email: "pending@example.com", Password: "x"}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	if errUpdate := db.Model(&user).Update("active", false).Error; errUpdate != nil {
+		t.Fatalf("deactivate user: %v", errUpdate)
+	}
+	if errCreate := db.Create(&models.APIKey{UserID: &user.ID, Name: "key", APIKey: "sk-pending", Active: true}).Error; errCreate != nil {
+		t.Fatalf("create key: %v", errCreate)
+	}
+
+	p := &DBAPIKeyProvider{db: db, name: ProviderTypeDBAPIKey, header: "Authorization", scheme: "Bearer"}
+	req := httptest.NewRequest(http.MethodGet, "/v1/me/balance", nil)
+	req.Header.Set("Authorization", "Bearer sk-pending")
+	if _, errAuth := p.Authenticate(context.Background(), req); !errors.Is(errAuth, sdkaccess.ErrInvalidCredential) {
+		t.Fatalf("expected an unverified user's key to be rejected, got %v", errAuth)
+	}
+}
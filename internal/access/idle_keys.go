@@ -0,0 +1,105 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// defaultIdleKeyRevokeInterval is how often idle API keys are checked.
+const defaultIdleKeyRevokeInterval = time.Hour
+
+// IdleKeyRevoker disables API keys that have not been used for API_KEY_IDLE_REVOKE_DAYS.
+type IdleKeyRevoker struct {
+	db       *gorm.DB
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewIdleKeyRevoker constructs an idle API key revoker.
+func NewIdleKeyRevoker(db *gorm.DB) *IdleKeyRevoker {
+	if db == nil {
+		return nil
+	}
+	return &IdleKeyRevoker{
+		db:       db,
+		interval: defaultIdleKeyRevokeInterval,
+		now:      time.Now,
+	}
+}
+
+// Start runs the revoke loop in the background.
+func (r *IdleKeyRevoker) Start(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go r.run(ctx)
+	log.Infof("idle api key revoker started (interval=%s)", r.interval)
+}
+
+// run executes revoke passes until ctx is canceled.
+func (r *IdleKeyRevoker) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			days := apiKeyIdleRevokeDays()
+			if days <= 0 {
+				continue
+			}
+			revoked, errRun := r.RunOnce(ctx, days)
+			if errRun != nil {
+				if !errors.Is(errRun, context.Canceled) {
+					log.WithError(errRun).Warn("idle api key revoker: pass failed")
+				}
+				continue
+			}
+			if revoked > 0 {
+				log.Infof("idle api key revoker: revoked %d key(s) idle for more than %d day(s)", revoked, days)
+			}
+		}
+	}
+}
+
+// apiKeyIdleRevokeDays reads API_KEY_IDLE_REVOKE_DAYS; 0 disables auto-revoke.
+func apiKeyIdleRevokeDays() int {
+	return internalsettings.GetInt(internalsettings.APIKeyIdleRevokeDaysKey)
+}
+
+// RunOnce revokes active keys whose last use, or creation when never used, is older than
+// idleDays. It returns the number of keys revoked.
+func (r *IdleKeyRevoker) RunOnce(ctx context.Context, idleDays int) (int64, error) {
+	if r == nil || r.db == nil {
+		return 0, errors.New("idle api key revoker: nil db")
+	}
+	if idleDays <= 0 {
+		return 0, nil
+	}
+	clock := r.now
+	if clock == nil {
+		clock = time.Now
+	}
+	now := clock().UTC()
+	cutoff := now.AddDate(0, 0, -idleDays)
+	res := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("active = ? AND revoked_at IS NULL", true).
+		Where("(last_used_at < ? OR (last_used_at IS NULL AND created_at < ?))", cutoff, cutoff).
+		Updates(map[string]any{
+			"active":     false,
+			"revoked_at": &now,
+			"updated_at": now,
+		})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,99 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func openAccessTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:access_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.APIKey{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	return db
+}
+
+func TestIdleKeyRevokerDisablesOnlyIdleKeys(t *testing.T) {
+	db := openAccessTestDB(t)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -91)
+	recent := now.AddDate(0, 0, -10)
+	keys := []models.APIKey{
+		{Name: "stale", APIKey: "sk-stale", Active: true, LastUsedAt: &old, CreatedAt: old},
+		{Name: "never", APIKey: "sk-never", Active: true, CreatedAt: old},
+		{Name: "fresh", APIKey: "sk-fresh", Active: true, LastUsedAt: &recent, CreatedAt: old},
+		{Name: "new", APIKey: "sk-new", Active: true, CreatedAt: recent},
+	}
+	if errCreate := db.Create(&keys).Error; errCreate != nil {
+		t.Fatalf("create keys: %v", errCreate)
+	}
+
+	revoker := NewIdleKeyRevoker(db)
+	revoker.now = func() time.Time { return now }
+	revoked, errRun := revoker.RunOnce(context.Background(), 90)
+	if errRun != nil {
+		t.Fatalf("run: %v", errRun)
+	}
+	if revoked != 2 {
+		t.Fatalf("expected 2 revoked keys, got %d", revoked)
+	}
+
+	var rows []models.APIKey
+	if errFind := db.Order("id ASC").Find(&rows).Error; errFind != nil {
+		t.Fatalf("load keys: %v", errFind)
+	}
+	for _, row := range rows {
+		idle := row.Name == "stale" || row.Name == "never"
+		if idle != (row.RevokedAt != nil) || idle == row.Active {
+			t.Fatalf("unexpected state for %s: active=%v revoked_at=%v", row.Name, row.Active, row.RevokedAt)
+		}
+	}
+
+	if revoked, errRun = revoker.RunOnce(context.Background(), 0); errRun != nil || revoked != 0 {
+		t.Fatalf("expected disabled pass to be a no-op, got %d %v", revoked, errRun)
+	}
+}
+
+func TestTouchAPIKeyLastUsedThrottles(t *testing.T) {
+	db := openAccessTestDB(t)
+	key := models.APIKey{Name: "k", APIKey: "sk-k", Active: true}
+	if errCreate := db.Create(&key).Error; errCreate != nil {
+		t.Fatalf("create key: %v", errCreate)
+	}
+	loadLastUsed := func() time.Time {
+		var row models.APIKey
+		if errFind := db.First(&row, key.ID).Error; errFind != nil || row.LastUsedAt == nil {
+			t.Fatalf("load key: %v %+v", errFind, row)
+		}
+		return row.LastUsedAt.UTC()
+	}
+
+	first := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	touchAPIKeyLastUsed(context.Background(), db, &key, first)
+	if got := loadLastUsed(); !got.Equal(first) {
+		t.Fatalf("expected first use to be recorded, got %v", got)
+	}
+
+	// A stale in-memory copy must not bypass the throttle stored in the row.
+	touchAPIKeyLastUsed(context.Background(), db, &key, first.Add(30*time.Second))
+	if got := loadLastUsed(); !got.Equal(first) {
+		t.Fatalf("expected throttled write to be skipped, got %v", got)
+	}
+
+	later := first.Add(2 * time.Minute)
+	touchAPIKeyLastUsed(context.Background(), db, &key, later)
+	if got := loadLastUsed(); !got.Equal(later) {
+		t.Fatalf("expected write after throttle window, got %v", got)
+	}
+}
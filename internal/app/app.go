@@ -2,11 +2,11 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
+	"io"
 	"net/http"
 	"os"
-	"path"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -15,11 +15,13 @@ import (
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/access"
 	internalauth "github.com/router-for-me/CLIProxyAPIBusiness/internal/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	relayhttp "github.com/router-for-me/CLIProxyAPIBusiness/internal/http"
 	internalhttp "github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/front"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/jobs"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelreference"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelregistry"
@@ -37,6 +39,7 @@ import (
 	sdkcliproxy "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // CreateAPIKeyParams holds inputs for API key creation.
@@ -61,6 +64,41 @@ func Migrate(ctx context.Context, cfg config.AppConfig) error {
 	return db.Migrate(conn)
 }
 
+// printMigrationPlan writes the pending migration statements to w without applying them.
+func printMigrationPlan(w io.Writer, conn *gorm.DB) error {
+	statements, errPlan := db.PlanMigrate(conn)
+	for _, stmt := range statements {
+		_, _ = fmt.Fprintf(w, "[%s] %s;\n", stmt.Kind, stmt.SQL)
+	}
+	if errPlan != nil {
+		return errPlan
+	}
+	if len(statements) == 0 {
+		_, _ = fmt.Fprintln(w, "database schema is up to date; no migration statements pending")
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "%d migration statement(s) pending\n", len(statements))
+	return nil
+}
+
+// openReadReplica opens the optional read-only replica and pairs it with primary. The
+// replica is never migrated; without one every query stays on primary.
+func openReadReplica(configPath string, primary *gorm.DB) (db.DBProvider, error) {
+	readDSN, errDSN := config.LoadReadDatabaseDSN(configPath)
+	if errDSN != nil {
+		return db.DBProvider{}, errDSN
+	}
+	if readDSN == "" {
+		return db.NewDBProvider(primary, nil), nil
+	}
+	replica, errOpen := db.Open(readDSN)
+	if errOpen != nil {
+		return db.DBProvider{}, fmt.Errorf("open read replica: %w", errOpen)
+	}
+	log.Info("read replica configured for dashboard, logs, usage and billing queries")
+	return db.NewDBProvider(primary, replica), nil
+}
+
 // RunServer boots the API relay server with database-backed components.
 func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error {
 	configPath := config.ResolveConfigPath(cfg.ConfigPath)
@@ -76,8 +114,23 @@ func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error
 	if err != nil {
 		return err
 	}
-	if errMigrate := db.Migrate(conn); errMigrate != nil {
-		return errMigrate
+	migrateMode, errMode := db.ParseMigrateMode(cfg.MigrateMode)
+	if errMode != nil {
+		return errMode
+	}
+	switch migrateMode {
+	case db.MigrateModePlan:
+		return printMigrationPlan(os.Stdout, conn)
+	case db.MigrateModeSkip:
+		log.Info("database migrations skipped (migrate mode: skip)")
+	default:
+		if errMigrate := db.Migrate(conn); errMigrate != nil {
+			return errMigrate
+		}
+	}
+	dbs, errReplica := openReadReplica(configPath, conn)
+	if errReplica != nil {
+		return errReplica
 	}
 
 	initialized, errInit := HasAdminInitialized(conn)
@@ -137,8 +190,11 @@ func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error
 		return fmt.Errorf("configure logging: %w", errLog)
 	}
 
-	distFS := webBundle.DistFS
-	fileServer := http.FileServer(http.FS(distFS))
+	// routedEngine lets prefix routing re-dispatch requests once the engine exists.
+	var routedEngine atomic.Pointer[gin.Engine]
+	drainer := newRequestDrainer()
+	planModels := billing.NewPlanModelCache(conn, watcher.PlanAccessChangedAt)
+	webServer := webui.NewServer(webBundle)
 	builder := sdkcliproxy.NewBuilder().
 		WithConfig(coreCfg).
 		WithConfigPath(configPath).
@@ -148,6 +204,9 @@ func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error
 		WithServerOptions(
 			sdkapi.WithMiddleware(
 				logging.GinLogrusRecovery(),
+				drainer.Middleware(),
+				logging.GinRequestIDMiddleware(),
+				relayhttp.CLIProxyPrefixMiddleware(conn, routedEngine.Load),
 				logging.GinLogrusLogger(),
 				corsMiddleware(),
 				func(c *gin.Context) {
@@ -163,14 +222,21 @@ func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error
 					c.Redirect(http.StatusTemporaryRedirect, "/init")
 					c.Abort()
 				},
-				webUIRootMiddleware(webBundle.IndexHTML),
+				webUIRootMiddleware(webServer),
 				relayhttp.CLIProxyAuthMiddleware(enforcementAccessMgr, coreCfg.WebsocketAuth),
-				relayhttp.CLIProxyModelsMiddleware(conn, modelStore),
+				relayhttp.CLIProxyQuotaHeadersMiddleware(),
+				relayhttp.CLIProxyModelPrefixMiddleware(conn),
+				relayhttp.CLIProxyModelOverrideMiddleware(),
+				relayhttp.CLIProxyPlanModelsMiddleware(planModels),
+				relayhttp.CLIProxyFallbackMiddleware(coreManager, planModels),
+				relayhttp.CLIProxyMappingTimeoutMiddleware(),
+				relayhttp.CLIProxyModelsMiddleware(conn, modelStore, planModels),
+				relayhttp.CLIProxyMeMiddleware(conn, jwtConfig),
 			),
 			sdkapi.WithRouterConfigurator(func(engine *gin.Engine, baseHandler *sdkhandlers.BaseAPIHandler, cfg *sdkconfig.Config) {
-				internalhttp.RegisterAdminRoutes(engine, conn, jwtConfig, configPath, cfg, baseHandler)
-				front.RegisterFrontRoutes(engine, conn, jwtConfig, modelStore)
-				engine.StaticFS("/assets", webBundle.AssetsFS)
+				routedEngine.Store(engine)
+				internalhttp.RegisterAdminRoutes(engine, dbs, jwtConfig, configPath, cfg, baseHandler)
+				front.RegisterFrontRoutes(engine, dbs, jwtConfig, modelStore)
 				engine.GET("/v0/init/status", func(c *gin.Context) {
 					c.JSON(http.StatusOK, InitStatusResponse{Initialized: initState.Load()})
 				})
@@ -211,12 +277,8 @@ func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error
 						c.JSON(http.StatusBadRequest, gin.H{"error": "Admin username is required"})
 						return
 					}
-					if strings.TrimSpace(req.AdminPassword) == "" {
-						c.JSON(http.StatusBadRequest, gin.H{"error": "Admin password is required"})
-						return
-					}
-					if len(req.AdminPassword) < 6 {
-						c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 6 characters"})
+					if errPassword := ValidateAdminPassword(req.AdminPassword); errPassword != nil {
+						c.JSON(http.StatusBadRequest, gin.H{"error": errPassword.Error()})
 						return
 					}
 
@@ -227,43 +289,7 @@ func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error
 					initState.Store(true)
 					c.JSON(http.StatusOK, gin.H{"message": "Initialization successful"})
 				})
-				engine.NoRoute(func(c *gin.Context) {
-					if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
-						c.Status(http.StatusNotFound)
-						return
-					}
-					requestPath := c.Request.URL.Path
-					if isAPIRoute(requestPath) {
-						c.Status(http.StatusNotFound)
-						return
-					}
-					cleanedPath := path.Clean("/" + requestPath)
-					filePath := strings.TrimPrefix(cleanedPath, "/")
-					if filePath != "" {
-						fileInfo, errStat := fs.Stat(distFS, filePath)
-						if errStat == nil && !fileInfo.IsDir() {
-							fileServer.ServeHTTP(c.Writer, c.Request)
-							return
-						}
-						if requestPath == "/assets" || strings.HasPrefix(requestPath, "/assets/") || strings.Contains(path.Base(filePath), ".") {
-							c.Status(http.StatusNotFound)
-							return
-						}
-					}
-					if requestPath == "/init" {
-						if initState.Load() {
-							c.Status(http.StatusNotFound)
-							return
-						}
-						c.Data(http.StatusOK, "text/html; charset=utf-8", webBundle.IndexHTML)
-						return
-					}
-					if !initState.Load() {
-						c.Redirect(http.StatusTemporaryRedirect, "/init")
-						return
-					}
-					c.Data(http.StatusOK, "text/html; charset=utf-8", webBundle.IndexHTML)
-				})
+				engine.NoRoute(webUINoRoute(webServer, &initState))
 			}),
 		)
 
@@ -271,18 +297,61 @@ func RunServer(ctx context.Context, cfg config.AppConfig, defaultPort int) error
 	if err != nil {
 		return err
 	}
-	service.RegisterUsagePlugin(internalusage.NewGormUsagePlugin(conn))
+
+	// serviceCtx outlives ctx until in-flight requests have drained; cancelling it makes
+	// the SDK stop the watcher and HTTP server and flush queued usage records.
+	serviceCtx, cancelService := context.WithCancel(context.Background())
+	defer cancelService()
+	go func() {
+		select {
+		case <-ctx.Done():
+			drainBeforeShutdown(drainer, cfg.ShutdownTimeout)
+			cancelService()
+		case <-serviceCtx.Done():
+		}
+	}()
+
+	usagePlugin := internalusage.NewGormUsagePlugin(conn)
+	usagePlugin.SetAuthLookup(coreManager.GetByID)
+	usagePlugin.Start()
+	defer usagePlugin.Close()
+	service.RegisterUsagePlugin(usagePlugin)
 	if quotaPoller := quota.NewPoller(conn, coreManager); quotaPoller != nil {
-		quotaPoller.Start(ctx)
+		quotaPoller.Start(serviceCtx)
 	}
 	if modelSyncer := modelreference.NewSyncer(conn); modelSyncer != nil {
-		modelSyncer.Start(ctx)
+		modelSyncer.Start(serviceCtx)
+	}
+	if rollupJob := internalusage.NewRollupJob(conn); rollupJob != nil {
+		rollupJob.Start(serviceCtx)
+	}
+	if retentionJob := internalusage.NewRetentionJob(conn); retentionJob != nil {
+		retentionJob.Start(serviceCtx)
+	}
+	if idleKeyRevoker := access.NewIdleKeyRevoker(conn); idleKeyRevoker != nil {
+		idleKeyRevoker.Start(serviceCtx)
+	}
+	if alertJob := internalusage.NewAlertJob(conn); alertJob != nil {
+		alertJob.Start(serviceCtx)
+	}
+	if billingReconciler := internalusage.NewBillingReconciler(conn); billingReconciler != nil {
+		billingReconciler.Start(serviceCtx)
+	}
+	if renewalJob := billing.NewRenewalJob(conn); renewalJob != nil {
+		renewalJob.Start(serviceCtx)
+	}
+	if jobRunner := jobs.NewRunner(conn); jobRunner != nil {
+		jobRunner.Start(serviceCtx)
 	}
 
 	serverAccessMgr.SetProviders(nil)
 
 	log.Infof("starting relay with config=%s", cfg.ConfigPath)
-	return service.Run(ctx)
+	if errRun := service.Run(serviceCtx); errRun != nil && !(errors.Is(errRun, context.Canceled) && ctx.Err() != nil) {
+		return errRun
+	}
+	log.Info("shutdown complete")
+	return nil
 }
 
 func loadCoreConfig(configPath string) (*sdkconfig.Config, error) {
@@ -303,20 +372,6 @@ func loadCoreConfig(configPath string) (*sdkconfig.Config, error) {
 // nowUTC returns the current UTC time.
 func nowUTC() time.Time { return time.Now().UTC() }
 
-// webUIRootMiddleware serves the index HTML at the root path.
-func webUIRootMiddleware(indexHTML []byte) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
-			return
-		}
-		if c.Request.URL.Path != "/" {
-			return
-		}
-		c.Data(http.StatusOK, "text/html; charset=utf-8", indexHTML)
-		c.Abort()
-	}
-}
-
 // isAPIRoute reports whether a path targets API endpoints.
 func isAPIRoute(requestPath string) bool {
 	if requestPath == "/healthz" || strings.HasPrefix(requestPath, "/healthz/") {
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// MinAdminPasswordLength is the minimum length accepted for admin passwords.
+const MinAdminPasswordLength = 6
+
+// ValidateAdminPassword checks an admin password against the password policy.
+func ValidateAdminPassword(password string) error {
+	if strings.TrimSpace(password) == "" {
+		return fmt.Errorf("Admin password is required")
+	}
+	if len(password) < MinAdminPasswordLength {
+		return fmt.Errorf("Password must be at least %d characters", MinAdminPasswordLength)
+	}
+	return nil
+}
+
+// CreateAdmin opens the configured database, runs migrations, and creates a super admin.
+// It is used by the create-admin CLI subcommand and never starts the init server.
+func CreateAdmin(ctx context.Context, cfg config.AppConfig, username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return fmt.Errorf("Admin username is required")
+	}
+	if errPassword := ValidateAdminPassword(password); errPassword != nil {
+		return errPassword
+	}
+
+	conn, err := openConfiguredDB(cfg)
+	if err != nil {
+		return err
+	}
+	return createAdminIfAbsent(conn.WithContext(ctx), username, password)
+}
+
+// openConfiguredDB opens the database named by the config file or DB connection env the
+// same way the server does, and runs migrations.
+func openConfiguredDB(cfg config.AppConfig) (*gorm.DB, error) {
+	configPath := config.ResolveConfigPath(cfg.ConfigPath)
+	dsn, err := config.LoadDatabaseDSN(configPath)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		return nil, fmt.Errorf("migrate database: %w", errMigrate)
+	}
+	return conn, nil
+}
+
+// createAdminIfAbsent creates the admin unless the username is already taken.
+func createAdminIfAbsent(conn *gorm.DB, username, password string) error {
+	var count int64
+	if errCount := conn.Model(&models.Admin{}).Where("LOWER(username) = LOWER(?)", username).Count(&count).Error; errCount != nil {
+		return fmt.Errorf("query admin: %w", errCount)
+	}
+	if count > 0 {
+		return fmt.Errorf("admin %q already exists", username)
+	}
+	return CreateAdminUserWithConn(conn, username, password, "")
+}
@@ -113,7 +113,7 @@ func TestDatabaseConnection(dsn string) error {
 		return fmt.Errorf("failed to get sql db: %w", err)
 	}
 	defer func() {
-		err = sqlDB.Close()
+		err = db.Close(conn)
 		if err != nil {
 			log.Errorf("sql db close error: %v", err)
 		}
@@ -162,14 +162,15 @@ func validateInitRequest(req *InitRequest) error {
 
 // configFile maps YAML fields for the generated config file.
 type configFile struct {
-	Host           string `yaml:"host"`
-	Port           int    `yaml:"port"`
-	DatabaseDSN    string `yaml:"database-dsn"`
-	Debug          bool   `yaml:"debug"`
-	CommercialMode bool   `yaml:"commercial-mode"`
-	LoggingToFile  bool   `yaml:"logging-to-file"`
-	JWT            jwtCfg `yaml:"jwt"`
-	TLS            tlsCfg `yaml:"tls"`
+	Host            string `yaml:"host"`
+	Port            int    `yaml:"port"`
+	DatabaseDSN     string `yaml:"database-dsn"`
+	DatabaseReadDSN string `yaml:"database-read-dsn,omitempty"` // Optional read-only replica for heavy queries.
+	Debug           bool   `yaml:"debug"`
+	CommercialMode  bool   `yaml:"commercial-mode"`
+	LoggingToFile   bool   `yaml:"logging-to-file"`
+	JWT             jwtCfg `yaml:"jwt"`
+	TLS             tlsCfg `yaml:"tls"`
 }
 
 // jwtCfg holds JWT settings for the generated config file.
@@ -275,9 +276,17 @@ func CreateAdminUserWithConn(conn *gorm.DB, username, password, siteName string)
 }
 
 // upsertSiteNameSetting stores the SITE_NAME setting in the database.
+// An empty siteName keeps an existing setting and only seeds the default when none exists.
 func upsertSiteNameSetting(conn *gorm.DB, siteName string) error {
 	normalized := strings.TrimSpace(siteName)
 	if normalized == "" {
+		var existing int64
+		if errCount := conn.Model(&models.Setting{}).Where("key = ?", internalsettings.SiteNameKey).Count(&existing).Error; errCount != nil {
+			return fmt.Errorf("db: query SITE_NAME setting: %w", errCount)
+		}
+		if existing > 0 {
+			return nil
+		}
 		normalized = internalsettings.DefaultSiteName
 	}
 	payload, errMarshal := json.Marshal(normalized)
@@ -352,8 +361,8 @@ func RunInitServer(ctx context.Context, cfg config.AppConfig, port int) error {
 			return
 		}
 
-		if len(req.AdminPassword) < 6 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 6 characters"})
+		if errPassword := ValidateAdminPassword(req.AdminPassword); errPassword != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errPassword.Error()})
 			return
 		}
 
@@ -418,7 +427,7 @@ func RunInitServer(ctx context.Context, cfg config.AppConfig, port int) error {
 		case <-ctx.Done():
 		case <-initDone:
 		}
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 		defer cancel()
 		if errShutdown := srv.Shutdown(shutdownCtx); errShutdown != nil {
 			log.Errorf("init server shutdown error: %v", errShutdown)
@@ -1,11 +1,17 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 )
 
 func TestCreateAdminUserWithConn_SetsSuperAdmin(t *testing.T) {
@@ -30,3 +36,115 @@ func TestCreateAdminUserWithConn_SetsSuperAdmin(t *testing.T) {
 		t.Fatalf("expected first admin to be super admin")
 	}
 }
+
+func TestCreateAdmin_UsesConfiguredDatabase(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "cpab-test.db")
+	t.Setenv(config.EnvDBConnection, dsn)
+
+	if errCreate := CreateAdmin(context.Background(), config.AppConfig{}, " ops ", "secret1"); errCreate != nil {
+		t.Fatalf("CreateAdmin: %v", errCreate)
+	}
+	if errDup := CreateAdmin(context.Background(), config.AppConfig{}, "ops", "secret1"); errDup == nil {
+		t.Fatalf("expected duplicate username to fail")
+	}
+
+	conn, err := db.Open(dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	var admin models.Admin
+	if errFind := conn.Where("username = ?", "ops").First(&admin).Error; errFind != nil {
+		t.Fatalf("find admin: %v", errFind)
+	}
+	if !admin.IsSuperAdmin || !admin.Active {
+		t.Fatalf("expected active super admin, got %+v", admin)
+	}
+}
+
+func TestCreateAdmin_RejectsWeakPassword(t *testing.T) {
+	t.Setenv(config.EnvDBConnection, "file:"+filepath.Join(t.TempDir(), "cpab-test.db"))
+
+	if errCreate := CreateAdmin(context.Background(), config.AppConfig{}, "ops", "12345"); errCreate == nil {
+		t.Fatalf("expected short password to be rejected")
+	}
+}
+
+func TestCreateAdminUserWithConn_KeepsExistingSiteName(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "cpab-test.db")
+	conn, err := db.Open(dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	if errCreate := CreateAdminUserWithConn(conn, "admin", "password", "Acme"); errCreate != nil {
+		t.Fatalf("CreateAdminUserWithConn: %v", errCreate)
+	}
+	if errCreate := CreateAdminUserWithConn(conn, "ops", "password", ""); errCreate != nil {
+		t.Fatalf("CreateAdminUserWithConn: %v", errCreate)
+	}
+
+	var setting models.Setting
+	if errFind := conn.Where("key = ?", internalsettings.SiteNameKey).First(&setting).Error; errFind != nil {
+		t.Fatalf("find setting: %v", errFind)
+	}
+	if string(setting.Value) != `"Acme"` {
+		t.Fatalf("expected site name to be kept, got %s", setting.Value)
+	}
+}
+
+func TestResetAdmin_ClearsMFAAndReactivates(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "cpab-test.db")
+	t.Setenv(config.EnvDBConnection, dsn)
+
+	if errCreate := CreateAdmin(context.Background(), config.AppConfig{}, "ops", "secret1"); errCreate != nil {
+		t.Fatalf("CreateAdmin: %v", errCreate)
+	}
+	conn, err := db.Open(dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	var admin models.Admin
+	if errFind := conn.Where("username = ?", "ops").First(&admin).Error; errFind != nil {
+		t.Fatalf("find admin: %v", errFind)
+	}
+	if errUpdate := conn.Model(&admin).Updates(map[string]any{
+		"active":             false,
+		"totp_secret":        "SECRET",
+		"passkey_id":         []byte("cred"),
+		"passkey_public_key": []byte("key"),
+	}).Error; errUpdate != nil {
+		t.Fatalf("lock admin: %v", errUpdate)
+	}
+	session := models.AdminSession{JTI: "jti-1", AdminID: admin.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if errCreate := conn.Create(&session).Error; errCreate != nil {
+		t.Fatalf("create session: %v", errCreate)
+	}
+
+	if errReset := ResetAdmin(context.Background(), config.AppConfig{}, "ops", "newsecret"); errReset != nil {
+		t.Fatalf("ResetAdmin: %v", errReset)
+	}
+
+	var reset models.Admin
+	if errFind := conn.First(&reset, admin.ID).Error; errFind != nil {
+		t.Fatalf("find admin: %v", errFind)
+	}
+	if !reset.Active || reset.TOTPSecret != "" || len(reset.PasskeyID) != 0 || len(reset.PasskeyPublicKey) != 0 {
+		t.Fatalf("expected active admin without MFA, got %+v", reset)
+	}
+	if !security.CheckPassword(reset.Password, "newsecret") {
+		t.Fatalf("expected new password to be set")
+	}
+	var revoked models.AdminSession
+	if errFind := conn.First(&revoked, session.ID).Error; errFind != nil {
+		t.Fatalf("find session: %v", errFind)
+	}
+	if revoked.RevokedAt == nil {
+		t.Fatalf("expected outstanding session to be revoked")
+	}
+
+	if errMissing := ResetAdmin(context.Background(), config.AppConfig{}, "nobody", "newsecret"); !errors.Is(errMissing, ErrAdminNotFound) {
+		t.Fatalf("expected ErrAdminNotFound, got %v", errMissing)
+	}
+}
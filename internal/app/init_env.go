@@ -0,0 +1,170 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+)
+
+// Environment variables read by the headless bootstrap.
+const (
+	EnvInitDBType            = "INIT_DB_TYPE"
+	EnvInitDBDSN             = "INIT_DB_DSN"
+	EnvInitDBHost            = "INIT_DB_HOST"
+	EnvInitDBPort            = "INIT_DB_PORT"
+	EnvInitDBUser            = "INIT_DB_USER"
+	EnvInitDBPassword        = "INIT_DB_PASSWORD"
+	EnvInitDBName            = "INIT_DB_NAME"
+	EnvInitDBPath            = "INIT_DB_PATH"
+	EnvInitDBSSLMode         = "INIT_DB_SSLMODE"
+	EnvInitSiteName          = "INIT_SITE_NAME"
+	EnvInitAdminUsername     = "INIT_ADMIN_USERNAME"
+	EnvInitAdminPassword     = "INIT_ADMIN_PASSWORD"
+	EnvInitAdminPasswordFile = "INIT_ADMIN_PASSWORD_FILE"
+)
+
+// defaultPostgresPort is used when INIT_DB_PORT is unset.
+const defaultPostgresPort = 5432
+
+// EnvBootstrapRequested reports whether the environment asks for a headless bootstrap.
+func EnvBootstrapRequested() bool {
+	return strings.TrimSpace(os.Getenv(EnvInitAdminUsername)) != ""
+}
+
+// RunEnvBootstrap performs the init flow from INIT_* environment variables instead of
+// the init web server: it validates the input, checks the database is reachable, writes
+// the config file, and creates the first admin. The admin is only created while the
+// admins table is empty, so restarts with the same environment do not bootstrap again.
+func RunEnvBootstrap(ctx context.Context, cfg config.AppConfig, port int) error {
+	req, dsn, err := initRequestFromEnv()
+	if err != nil {
+		return fmt.Errorf("init bootstrap: %w", err)
+	}
+	if errTest := TestDatabaseConnection(dsn); errTest != nil {
+		return fmt.Errorf("init bootstrap: database unreachable: %w", errTest)
+	}
+
+	conn, err := db.Open(dsn)
+	if err != nil {
+		return fmt.Errorf("init bootstrap: open database: %w", err)
+	}
+	defer func() {
+		if errClose := db.Close(conn); errClose != nil {
+			log.Errorf("sql db close error: %v", errClose)
+		}
+	}()
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		return fmt.Errorf("init bootstrap: migrate database: %w", errMigrate)
+	}
+	initialized, errInit := HasAdminInitialized(conn)
+	if errInit != nil {
+		return fmt.Errorf("init bootstrap: check admin status: %w", errInit)
+	}
+
+	configPath := config.ResolveConfigPath(cfg.ConfigPath)
+	if errWrite := WriteConfigFile(configPath, dsn, port); errWrite != nil {
+		return fmt.Errorf("init bootstrap: %w", errWrite)
+	}
+	if initialized {
+		log.Infof("init bootstrap: admins already exist, skipping admin creation (config written to %s)", configPath)
+		return nil
+	}
+	if errAdmin := CreateAdminUserWithConn(conn.WithContext(ctx), req.AdminUsername, req.AdminPassword, req.SiteName); errAdmin != nil {
+		if errRemove := os.Remove(configPath); errRemove != nil {
+			log.Errorf("remove config file error: %v", errRemove)
+		}
+		return fmt.Errorf("init bootstrap: %w", errAdmin)
+	}
+	log.Infof("init bootstrap: admin %q created, config written to %s", req.AdminUsername, configPath)
+	return nil
+}
+
+// initRequestFromEnv builds and validates the init request and DSN from the environment.
+// INIT_DB_DSN wins over the individual INIT_DB_* pieces; without either, the DB
+// connection env used by the server is accepted.
+func initRequestFromEnv() (InitRequest, string, error) {
+	req := InitRequest{
+		DatabaseType:     strings.TrimSpace(os.Getenv(EnvInitDBType)),
+		DatabaseHost:     strings.TrimSpace(os.Getenv(EnvInitDBHost)),
+		DatabaseUser:     strings.TrimSpace(os.Getenv(EnvInitDBUser)),
+		DatabasePassword: os.Getenv(EnvInitDBPassword),
+		DatabaseName:     strings.TrimSpace(os.Getenv(EnvInitDBName)),
+		DatabasePath:     strings.TrimSpace(os.Getenv(EnvInitDBPath)),
+		DatabaseSSLMode:  strings.TrimSpace(os.Getenv(EnvInitDBSSLMode)),
+		SiteName:         strings.TrimSpace(os.Getenv(EnvInitSiteName)),
+		AdminUsername:    strings.TrimSpace(os.Getenv(EnvInitAdminUsername)),
+	}
+	if req.AdminUsername == "" {
+		return req, "", fmt.Errorf("%s is required", EnvInitAdminUsername)
+	}
+	password, errPassword := initAdminPasswordFromEnv()
+	if errPassword != nil {
+		return req, "", errPassword
+	}
+	req.AdminPassword = password
+	if errValidate := ValidateAdminPassword(req.AdminPassword); errValidate != nil {
+		return req, "", errValidate
+	}
+
+	if dsn := strings.TrimSpace(os.Getenv(EnvInitDBDSN)); dsn != "" {
+		req.SiteName = initSiteName(req.SiteName)
+		return req, dsn, nil
+	}
+	if req.DatabaseType == "" {
+		if dsn := strings.TrimSpace(os.Getenv(config.EnvDBConnection)); dsn != "" {
+			req.SiteName = initSiteName(req.SiteName)
+			return req, dsn, nil
+		}
+	}
+
+	if rawPort := strings.TrimSpace(os.Getenv(EnvInitDBPort)); rawPort != "" {
+		parsed, errPort := strconv.Atoi(rawPort)
+		if errPort != nil {
+			return req, "", fmt.Errorf("invalid %s: %q", EnvInitDBPort, rawPort)
+		}
+		req.DatabasePort = parsed
+	} else {
+		req.DatabasePort = defaultPostgresPort
+	}
+	if errValidate := validateInitRequest(&req); errValidate != nil {
+		return req, "", errValidate
+	}
+	dsn, errBuild := BuildDSN(req)
+	if errBuild != nil {
+		return req, "", errBuild
+	}
+	return req, dsn, nil
+}
+
+// initAdminPasswordFromEnv reads the admin password from INIT_ADMIN_PASSWORD or the file
+// named by INIT_ADMIN_PASSWORD_FILE, trimming the trailing newline secret mounts add.
+func initAdminPasswordFromEnv() (string, error) {
+	password := os.Getenv(EnvInitAdminPassword)
+	path := strings.TrimSpace(os.Getenv(EnvInitAdminPasswordFile))
+	if path == "" {
+		return password, nil
+	}
+	if password != "" {
+		return "", fmt.Errorf("set only one of %s and %s", EnvInitAdminPassword, EnvInitAdminPasswordFile)
+	}
+	data, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return "", fmt.Errorf("read %s: %w", EnvInitAdminPasswordFile, errRead)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// initSiteName applies the default site name when none is set.
+func initSiteName(siteName string) string {
+	if siteName == "" {
+		return internalsettings.DefaultSiteName
+	}
+	return siteName
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"gorm.io/gorm"
+)
+
+// MigrateDatabase copies every table from the database at fromDSN into the one at toDSN,
+// typically from the SQLite file created by the init flow into PostgreSQL. An empty
+// fromDSN uses the configured database. It is used by the migrate-db CLI subcommand and
+// must run while the server is stopped so no rows change during the copy.
+func MigrateDatabase(ctx context.Context, cfg config.AppConfig, fromDSN, toDSN string, opts db.CopyOptions) ([]db.TableCopy, error) {
+	fromDSN = strings.TrimSpace(fromDSN)
+	toDSN = strings.TrimSpace(toDSN)
+	if toDSN == "" {
+		return nil, fmt.Errorf("target database is required")
+	}
+	if fromDSN == "" {
+		dsn, errDSN := config.LoadDatabaseDSN(config.ResolveConfigPath(cfg.ConfigPath))
+		if errDSN != nil {
+			return nil, errDSN
+		}
+		fromDSN = strings.TrimSpace(dsn)
+	}
+	if fromDSN == toDSN {
+		return nil, fmt.Errorf("source and target database are the same")
+	}
+
+	src, errSrc := db.Open(fromDSN)
+	if errSrc != nil {
+		return nil, fmt.Errorf("open source database: %w", errSrc)
+	}
+	defer closeDB(src)
+	dst, errDst := db.Open(toDSN)
+	if errDst != nil {
+		return nil, fmt.Errorf("open target database: %w", errDst)
+	}
+	defer closeDB(dst)
+
+	return db.CopyDatabase(ctx, src, dst, opts)
+}
+
+// closeDB closes the connection pools behind conn.
+func closeDB(conn *gorm.DB) {
+	_ = db.Close(conn)
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	"gorm.io/gorm"
+)
+
+// ErrAdminNotFound indicates that no admin matches the requested username.
+var ErrAdminNotFound = errors.New("admin not found")
+
+// ResetAdmin opens the configured database and restores access for a locked-out admin:
+// it sets a new password, clears TOTP and passkey MFA, re-activates the account, and
+// revokes its outstanding sessions.
+func ResetAdmin(ctx context.Context, cfg config.AppConfig, username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return fmt.Errorf("Admin username is required")
+	}
+	if errPassword := ValidateAdminPassword(password); errPassword != nil {
+		return errPassword
+	}
+
+	conn, err := openConfiguredDB(cfg)
+	if err != nil {
+		return err
+	}
+	return resetAdminWithConn(conn.WithContext(ctx), username, password)
+}
+
+// resetAdminWithConn applies the admin reset inside a single transaction.
+func resetAdminWithConn(conn *gorm.DB, username, password string) error {
+	hashedPassword, errHash := security.HashPassword(password)
+	if errHash != nil {
+		return fmt.Errorf("hash password: %w", errHash)
+	}
+
+	return conn.Transaction(func(tx *gorm.DB) error {
+		var admin models.Admin
+		if errFind := tx.Select("id").Where("username = ?", username).Take(&admin).Error; errFind != nil {
+			if errors.Is(errFind, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", ErrAdminNotFound, username)
+			}
+			return fmt.Errorf("query admin: %w", errFind)
+		}
+
+		now := time.Now().UTC()
+		if errUpdate := tx.Model(&models.Admin{}).
+			Where("id = ?", admin.ID).
+			Updates(map[string]any{
+				"password":                hashedPassword,
+				"active":                  true,
+				"totp_secret":             "",
+				"passkey_id":              nil,
+				"passkey_public_key":      nil,
+				"passkey_sign_count":      nil,
+				"passkey_backup_eligible": nil,
+				"passkey_backup_state":    nil,
+				"updated_at":              now,
+			}).Error; errUpdate != nil {
+			return fmt.Errorf("update admin: %w", errUpdate)
+		}
+
+		if errRevoke := tx.Model(&models.AdminSession{}).
+			Where("admin_id = ? AND revoked_at IS NULL", admin.ID).
+			Update("revoked_at", now).Error; errRevoke != nil {
+			return fmt.Errorf("revoke admin sessions: %w", errRevoke)
+		}
+		return nil
+	})
+}
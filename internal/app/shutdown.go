@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
+	log "github.com/sirupsen/logrus"
+)
+
+// requestDrainer counts in-flight requests so shutdown can wait for them, and turns new
+// requests away once draining starts.
+type requestDrainer struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight int
+	idle     chan struct{} // Closed once draining and no request is in flight.
+}
+
+// drainTrackedKey marks a request context already counted by requestDrainer, so prefix
+// routing re-dispatching the request through the engine does not count it twice.
+type drainTrackedKey struct{}
+
+// newRequestDrainer constructs a requestDrainer.
+func newRequestDrainer() *requestDrainer {
+	return &requestDrainer{idle: make(chan struct{})}
+}
+
+// Middleware tracks each request and answers 503 with Connection: close while draining,
+// which also fails health checks so load balancers stop routing to this instance.
+func (d *requestDrainer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tracked, _ := c.Request.Context().Value(drainTrackedKey{}).(bool); tracked {
+			c.Next()
+			return
+		}
+		d.mu.Lock()
+		if d.draining {
+			d.mu.Unlock()
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		d.inFlight++
+		d.mu.Unlock()
+		defer d.done()
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), drainTrackedKey{}, true))
+		c.Next()
+	}
+}
+
+// done marks one tracked request as finished.
+func (d *requestDrainer) done() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inFlight--
+	if d.draining && d.inFlight == 0 {
+		close(d.idle)
+	}
+}
+
+// Drain rejects new requests and waits until in-flight ones finish or ctx ends. It
+// returns the number of requests still running when it gave up.
+func (d *requestDrainer) Drain(ctx context.Context) int {
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		if d.inFlight == 0 {
+			close(d.idle)
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.idle:
+		return 0
+	case <-ctx.Done():
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.inFlight
+	}
+}
+
+// drainBeforeShutdown runs the first half of shutdown, before the service context is
+// cancelled: the watcher stops reloading so config stays fixed, then new requests are
+// rejected while in-flight ones get up to timeout to finish. Cancelling the service
+// afterwards stops the watcher and HTTP server and flushes queued usage; the usage plugin
+// flushes its retry buffer last.
+func drainBeforeShutdown(drainer *requestDrainer, timeout time.Duration) {
+	log.Infof("shutdown: draining in-flight requests (timeout=%s)", timeout)
+	watcher.BeginShutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if remaining := drainer.Drain(ctx); remaining > 0 {
+		log.Warnf("shutdown: %d request(s) still in flight after %s, stopping anyway", remaining, timeout)
+		return
+	}
+	log.Info("shutdown: in-flight requests drained")
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestDrainerWaitsForInFlightAndRejectsNew(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	drainer := newRequestDrainer()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(drainer.Middleware())
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	slow := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		r.ServeHTTP(slow, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(finished)
+	}()
+	<-started
+
+	drained := make(chan int, 1)
+	go func() { drained <- drainer.Drain(context.Background()) }()
+	// Wait for Drain to flip the drainer into draining mode.
+	deadline := time.Now().Add(time.Second)
+	for {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+		if w.Code == http.StatusServiceUnavailable {
+			if w.Header().Get("Connection") != "close" {
+				t.Fatalf("expected Connection: close on rejected requests")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected new requests to be rejected while draining")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-drained:
+		t.Fatalf("expected Drain to wait for the in-flight request")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-finished
+	if remaining := <-drained; remaining != 0 || slow.Code != http.StatusOK {
+		t.Fatalf("expected the in-flight request to complete, got %d remaining and status %d", remaining, slow.Code)
+	}
+}
+
+func TestRequestDrainerGivesUpAtTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	drainer := newRequestDrainer()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	r := gin.New()
+	r.Use(drainer.Middleware())
+	r.GET("/stuck", func(c *gin.Context) {
+		close(started)
+		<-release
+	})
+	go r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stuck", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if remaining := drainer.Drain(ctx); remaining != 1 {
+		t.Fatalf("expected 1 request still in flight, got %d", remaining)
+	}
+}
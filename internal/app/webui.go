@@ -0,0 +1,91 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/webui"
+)
+
+// webUIRootMiddleware serves the index HTML at the root path, or redirects to the web UI
+// prefix when the panel is mounted elsewhere.
+func webUIRootMiddleware(server *webui.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			return
+		}
+		if c.Request.URL.Path != "/" || !webUIEnabled() {
+			return
+		}
+		if prefix := webUIPathPrefix(); prefix != "/" {
+			c.Redirect(http.StatusFound, prefix+"/")
+		} else {
+			server.ServeIndex(c.Writer, prefix)
+		}
+		c.Abort()
+	}
+}
+
+// webUINoRoute serves the web UI for unmatched GET requests: bundle files under the
+// prefix, the init page until an admin exists, and index.html for client side routes.
+// API paths always 404 so typos there are not masked by the panel.
+func webUINoRoute(server *webui.Server, initState *atomic.Bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		requestPath := c.Request.URL.Path
+		if isAPIRoute(requestPath) || !webUIEnabled() {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		prefix := webUIPathPrefix()
+		if server.ServeFile(c.Writer, c.Request, prefix, requestPath) {
+			return
+		}
+		if requestPath == "/init" {
+			if initState.Load() {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			server.ServeIndex(c.Writer, prefix)
+			return
+		}
+		if !server.IsPageRoute(prefix, requestPath) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if !initState.Load() {
+			c.Redirect(http.StatusTemporaryRedirect, "/init")
+			return
+		}
+		server.ServeIndex(c.Writer, prefix)
+	}
+}
+
+// webUIEnabled reads WEB_UI_ENABLED; API-only deployments turn it off.
+func webUIEnabled() bool {
+	return internalsettings.GetBool(internalsettings.WebUIEnabledKey)
+}
+
+// webUIPathPrefix reads WEB_UI_PATH_PREFIX, falling back to the root for values that
+// would shadow API routes.
+func webUIPathPrefix() string {
+	raw, ok := internalsettings.DBConfigValue(internalsettings.WebUIPathPrefixKey)
+	if !ok {
+		return internalsettings.DefaultWebUIPathPrefix
+	}
+	var prefix string
+	if errUnmarshal := json.Unmarshal(raw, &prefix); errUnmarshal != nil {
+		return internalsettings.DefaultWebUIPathPrefix
+	}
+	prefix = webui.NormalizePrefix(prefix)
+	if internalsettings.CheckWebUIPathPrefix(prefix) != nil {
+		return internalsettings.DefaultWebUIPathPrefix
+	}
+	return prefix
+}
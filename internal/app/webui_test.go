@@ -0,0 +1,74 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/webui"
+)
+
+func TestWebUINoRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	index := []byte("<html></html>")
+	server := webui.NewServer(webui.Bundle{
+		DistFS:    fstest.MapFS{"index.html": {Data: index}, "assets/app-1a2b3c4d.js": {Data: []byte("js")}},
+		IndexHTML: index,
+	})
+	var initState atomic.Bool
+	r := gin.New()
+	r.Use(webUIRootMiddleware(server))
+	r.NoRoute(webUINoRoute(server, &initState))
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if w := get("/admin/users"); w.Code != http.StatusTemporaryRedirect || w.Header().Get("Location") != "/init" {
+		t.Fatalf("expected redirect to init before setup, got %d", w.Code)
+	}
+	if w := get("/init"); w.Code != http.StatusOK {
+		t.Fatalf("expected init page before setup, got %d", w.Code)
+	}
+	initState.Store(true)
+	if w := get("/admin/users"); w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected SPA fallback with no-cache, got %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if w := get("/v1/unknown"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected API paths to 404, got %d", w.Code)
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.WebUIPathPrefixKey: json.RawMessage(`"/panel"`),
+	})
+	if w := get("/"); w.Code != http.StatusFound || w.Header().Get("Location") != "/panel/" {
+		t.Fatalf("expected root to redirect to the prefix, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("/admin/users"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected paths outside the prefix to 404, got %d", w.Code)
+	}
+	if w := get("/panel/assets/app-1a2b3c4d.js"); w.Code != http.StatusOK {
+		t.Fatalf("expected prefixed asset, got %d", w.Code)
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.WebUIEnabledKey:    json.RawMessage(`false`),
+		internalsettings.WebUIPathPrefixKey: json.RawMessage(`"/v1"`),
+	})
+	for _, target := range []string{"/", "/admin/users", "/assets/app-1a2b3c4d.js"} {
+		if w := get(target); w.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404 with the web UI disabled, got %d", target, w.Code)
+		}
+	}
+	if prefix := webUIPathPrefix(); prefix != "/" {
+		t.Fatalf("expected conflicting prefix to fall back to root, got %q", prefix)
+	}
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Actor kinds, target kinds and actions recorded in audit entries.
+const (
+	// ActorAdmin marks entries performed by an administrator.
+	ActorAdmin = "admin"
+	// TargetUser marks entries that act on an end user.
+	TargetUser = "user"
+	// TargetBackup marks entries that act on a database backup.
+	TargetBackup = "backup"
+
+	// ActionImpersonateGrant records an admin obtaining an impersonation token.
+	ActionImpersonateGrant = "impersonate.grant"
+	// ActionImpersonatedRequest records a user-side request made with an impersonation token.
+	ActionImpersonatedRequest = "impersonate.request"
+	// ActionUserExport records an admin exporting a user's data.
+	ActionUserExport = "user.export"
+	// ActionUserPurge records an admin purging a user and their data.
+	ActionUserPurge = "user.purge"
+	// ActionBackupDownload records an admin downloading a database backup.
+	ActionBackupDownload = "backup.download"
+)
+
+// Entry describes one audit record.
+type Entry struct {
+	ActorType  string         // Actor kind.
+	ActorID    uint64         // Actor record ID.
+	Action     string         // Action name.
+	TargetType string         // Target kind.
+	TargetID   *uint64        // Target record ID, if any.
+	Detail     map[string]any // Optional structured detail.
+	IP         string         // Client IP address.
+}
+
+// Record persists an audit entry.
+func Record(ctx context.Context, db *gorm.DB, entry Entry) error {
+	if db == nil {
+		return fmt.Errorf("audit: nil db")
+	}
+	var detail datatypes.JSON
+	if len(entry.Detail) > 0 {
+		raw, errMarshal := json.Marshal(entry.Detail)
+		if errMarshal != nil {
+			return fmt.Errorf("audit: marshal detail: %w", errMarshal)
+		}
+		detail = datatypes.JSON(raw)
+	}
+	row := models.AuditLog{
+		ActorType:  entry.ActorType,
+		ActorID:    entry.ActorID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Detail:     detail,
+		IP:         entry.IP,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if errCreate := db.WithContext(ctx).Create(&row).Error; errCreate != nil {
+		return fmt.Errorf("audit: create: %w", errCreate)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestActiveHoursBlockAuthsOutsideTheirWindow(t *testing.T) {
+	tokyo, errLoad := time.LoadLocation("Asia/Tokyo")
+	if errLoad != nil {
+		t.Skipf("tzdata unavailable: %v", errLoad)
+	}
+	night := func(id string) *coreauth.Auth {
+		return &coreauth.Auth{ID: id, Status: coreauth.StatusActive, Attributes: map[string]string{
+			models.AuthAttrActiveHoursStart:    "22:00",
+			models.AuthAttrActiveHoursEnd:      "08:00",
+			models.AuthAttrActiveHoursTimezone: "Asia/Tokyo",
+		}}
+	}
+
+	inside := time.Date(2025, 1, 1, 3, 0, 0, 0, tokyo)
+	if available, errAvail := getAvailableAuths([]*coreauth.Auth{night("a")}, "claude", "smart", inside); errAvail != nil || len(available) != 1 {
+		t.Fatalf("expected the auth inside its window, got %v %v", available, errAvail)
+	}
+
+	outside := time.Date(2025, 1, 1, 12, 30, 0, 0, tokyo)
+	_, errAvail := getAvailableAuths([]*coreauth.Auth{night("a"), night("b")}, "claude", "smart", outside)
+	var cooldown *modelCooldownError
+	if !errors.As(errAvail, &cooldown) {
+		t.Fatalf("expected a model cooldown error, got %v", errAvail)
+	}
+	if cooldown.resetIn != 9*time.Hour+30*time.Minute {
+		t.Fatalf("expected the reset to point at the next opening, got %s", cooldown.resetIn)
+	}
+
+	open := &coreauth.Auth{ID: "day", Status: coreauth.StatusActive}
+	available, errAvail := getAvailableAuths([]*coreauth.Auth{night("a"), open}, "claude", "smart", outside)
+	if errAvail != nil || len(available) != 1 || available[0].ID != "day" {
+		t.Fatalf("expected only the auth without a window, got %v %v", available, errAvail)
+	}
+
+	prevSupports := clientSupportsModel
+	clientSupportsModel = func(string, string) bool { return true }
+	t.Cleanup(func() { clientSupportsModel = prevSupports })
+	explained := ExplainModelAvailability([]*coreauth.Auth{night("a")}, "smart", outside)
+	if len(explained) != 1 || explained[0].Reason != AvailabilityReasonOutsideActiveHours || explained[0].NextRetryAt == nil ||
+		!explained[0].NextRetryAt.Equal(time.Date(2025, 1, 1, 22, 0, 0, 0, tokyo)) {
+		t.Fatalf("unexpected availability %+v", explained)
+	}
+}
+
+func TestParseActiveHours(t *testing.T) {
+	hours, errParse := models.ParseActiveHours("09:00", "17:00", "")
+	if errParse != nil {
+		t.Fatalf("parse: %v", errParse)
+	}
+	evening := time.Date(2025, 1, 1, 18, 0, 0, 0, time.UTC)
+	if hours.Contains(evening) || !hours.NextOpen(evening).Equal(time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the window to reopen the next morning, got %s", hours.NextOpen(evening))
+	}
+	if !hours.Contains(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)) || hours.Contains(time.Date(2025, 1, 1, 17, 0, 0, 0, time.UTC)) {
+		t.Fatal("expected the start to be inclusive and the end exclusive")
+	}
+	for _, bad := range [][3]string{{"9am", "17:00", ""}, {"09:00", "09:00", ""}, {"09:00", "25:00", ""}, {"09:00", "17:00", "Mars/Base"}} {
+		if _, errBad := models.ParseActiveHours(bad[0], bad[1], bad[2]); errBad == nil {
+			t.Errorf("expected %v to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/providerkeys"
+)
+
+// Reasons reported when an auth cannot serve a model.
+const (
+	// AvailabilityReasonQuotaExceeded marks auths cooling down after a quota error.
+	AvailabilityReasonQuotaExceeded = "quota_exceeded"
+	// AvailabilityReasonDisabled marks auths, or auth models, that are disabled.
+	AvailabilityReasonDisabled = "disabled"
+	// AvailabilityReasonOutsideActiveHours marks auths whose group is outside its active hours.
+	AvailabilityReasonOutsideActiveHours = "outside_active_hours"
+	// AvailabilityReasonRetryAfter marks auths waiting out a retry delay after other errors.
+	AvailabilityReasonRetryAfter = "retry_after"
+)
+
+// AuthAvailability explains whether one auth can serve a model right now.
+type AuthAvailability struct {
+	ID            string     `json:"id"`                       // Auth ID (the auth file key).
+	Provider      string     `json:"provider"`                 // Auth provider.
+	Label         string     `json:"label"`                    // Human-readable label.
+	Status        string     `json:"status"`                   // Auth lifecycle status.
+	Available     bool       `json:"available"`                // Whether the selector may pick it.
+	Reason        string     `json:"reason,omitempty"`         // Why it is blocked.
+	NextRetryAt   *time.Time `json:"next_retry_at,omitempty"`  // When the block lifts, if known.
+	StatusMessage string     `json:"status_message,omitempty"` // Last status message for the model or auth.
+}
+
+// ExplainModelAvailability lists every auth serving model with the reason the selector
+// would skip it, using the same rules as getAvailableAuths.
+func ExplainModelAvailability(auths []*coreauth.Auth, model string, now time.Time) []AuthAvailability {
+	model = strings.TrimSpace(model)
+	out := make([]AuthAvailability, 0, len(auths))
+	for _, candidate := range auths {
+		if candidate == nil {
+			continue
+		}
+		_, tracked := candidate.ModelStates[model]
+		if !tracked && !clientSupportsModel(candidate.ID, model) {
+			continue
+		}
+		if providerkeys.ExcludesModel(candidate.Attributes, model) {
+			continue
+		}
+		entry := AuthAvailability{
+			ID:            candidate.ID,
+			Provider:      candidate.Provider,
+			Label:         candidate.Label,
+			Status:        string(candidate.Status),
+			StatusMessage: candidate.StatusMessage,
+		}
+		if state := candidate.ModelStates[model]; state != nil && state.StatusMessage != "" {
+			entry.StatusMessage = state.StatusMessage
+		}
+		blocked, reason, next := isAuthBlockedForModel(candidate, model, now)
+		entry.Available = !blocked
+		if blocked {
+			switch reason {
+			case blockReasonCooldown:
+				entry.Reason = AvailabilityReasonQuotaExceeded
+			case blockReasonOutsideHours:
+				entry.Reason = AvailabilityReasonOutsideActiveHours
+			case blockReasonDisabled:
+				entry.Reason = AvailabilityReasonDisabled
+			default:
+				entry.Reason = AvailabilityReasonRetryAfter
+			}
+			if !next.IsZero() {
+				nextUTC := next.UTC()
+				entry.NextRetryAt = &nextUTC
+			}
+		}
+		out = append(out, entry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// ClearCooldown resets the retry and quota state of auth, or only of its model state
+// when model is set, leaving disabled flags alone. It reports whether anything changed.
+func ClearCooldown(auth *coreauth.Auth, model string) bool {
+	if auth == nil {
+		return false
+	}
+	model = strings.TrimSpace(model)
+	if model != "" {
+		state, ok := auth.ModelStates[model]
+		if !ok || state == nil {
+			return false
+		}
+		cleared, changed := clearModelState(state)
+		if changed {
+			auth.ModelStates = copyModelStates(auth.ModelStates)
+			auth.ModelStates[model] = cleared
+		}
+		return changed
+	}
+
+	changed := auth.Unavailable || !auth.NextRetryAfter.IsZero() || auth.Quota != (coreauth.QuotaState{}) || auth.Status == coreauth.StatusError
+	auth.Unavailable = false
+	auth.NextRetryAfter = time.Time{}
+	auth.Quota = coreauth.QuotaState{}
+	if auth.Status == coreauth.StatusError {
+		auth.Status = coreauth.StatusActive
+		auth.StatusMessage = ""
+		auth.LastError = nil
+	}
+	states := copyModelStates(auth.ModelStates)
+	for name, state := range states {
+		if state == nil {
+			continue
+		}
+		if cleared, changedState := clearModelState(state); changedState {
+			states[name] = cleared
+			changed = true
+		}
+	}
+	auth.ModelStates = states
+	return changed
+}
+
+// clearModelState returns a copy of state with its cooldown removed.
+func clearModelState(state *coreauth.ModelState) (*coreauth.ModelState, bool) {
+	if !state.Unavailable && state.NextRetryAfter.IsZero() && state.Quota == (coreauth.QuotaState{}) && state.Status != coreauth.StatusError {
+		return state, false
+	}
+	cleared := *state
+	cleared.Unavailable = false
+	cleared.NextRetryAfter = time.Time{}
+	cleared.Quota = coreauth.QuotaState{}
+	if cleared.Status == coreauth.StatusError {
+		cleared.Status = coreauth.StatusActive
+		cleared.StatusMessage = ""
+		cleared.LastError = nil
+	}
+	return &cleared, true
+}
+
+// copyModelStates copies the state map so clones sharing it are not mutated.
+func copyModelStates(states map[string]*coreauth.ModelState) map[string]*coreauth.ModelState {
+	if states == nil {
+		return nil
+	}
+	out := make(map[string]*coreauth.ModelState, len(states))
+	for name, state := range states {
+		out[name] = state
+	}
+	return out
+}
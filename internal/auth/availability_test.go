@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestExplainModelAvailabilityReportsBlockReasons(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	prevSupports := clientSupportsModel
+	clientSupportsModel = func(authID, model string) bool { return authID != "other" }
+	t.Cleanup(func() { clientSupportsModel = prevSupports })
+
+	retry := now.Add(time.Minute)
+	auths := []*coreauth.Auth{
+		{ID: "quota", Status: coreauth.StatusActive, ModelStates: map[string]*coreauth.ModelState{
+			"smart": {Unavailable: true, NextRetryAfter: retry, Quota: coreauth.QuotaState{Exceeded: true}},
+		}},
+		{ID: "ready", Status: coreauth.StatusActive},
+		{ID: "off", Status: coreauth.StatusActive, Disabled: true},
+		{ID: "error", Status: coreauth.StatusActive, ModelStates: map[string]*coreauth.ModelState{
+			"smart": {Unavailable: true, NextRetryAfter: retry},
+		}},
+		{ID: "other", Status: coreauth.StatusActive},
+	}
+
+	got := ExplainModelAvailability(auths, "smart", now)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 auths, got %d", len(got))
+	}
+	want := map[string]string{"error": AvailabilityReasonRetryAfter, "off": AvailabilityReasonDisabled, "quota": AvailabilityReasonQuotaExceeded, "ready": ""}
+	for _, entry := range got {
+		if entry.Reason != want[entry.ID] {
+			t.Fatalf("%s: reason = %q, want %q", entry.ID, entry.Reason, want[entry.ID])
+		}
+		if entry.Available != (want[entry.ID] == "") {
+			t.Fatalf("%s: available = %v", entry.ID, entry.Available)
+		}
+	}
+	if got[2].ID != "quota" || got[2].NextRetryAt == nil || !got[2].NextRetryAt.Equal(retry) {
+		t.Fatalf("expected quota next retry %s, got %+v", retry, got[2])
+	}
+}
+
+func TestClearCooldownResetsModelWithoutTouchingShared(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	state := &coreauth.ModelState{Status: coreauth.StatusError, Unavailable: true, NextRetryAfter: now.Add(time.Hour), Quota: coreauth.QuotaState{Exceeded: true}}
+	original := &coreauth.Auth{ID: "a", Status: coreauth.StatusActive, ModelStates: map[string]*coreauth.ModelState{"smart": state}}
+	auth := original.Clone()
+
+	if ClearCooldown(auth, "missing") {
+		t.Fatalf("expected no change for an untracked model")
+	}
+	if !ClearCooldown(auth, "smart") {
+		t.Fatalf("expected model cooldown to be cleared")
+	}
+	cleared := auth.ModelStates["smart"]
+	if cleared.Unavailable || !cleared.NextRetryAfter.IsZero() || cleared.Quota.Exceeded || cleared.Status != coreauth.StatusActive {
+		t.Fatalf("model state not cleared: %+v", cleared)
+	}
+	if !state.Unavailable || original.ModelStates["smart"] != state {
+		t.Fatalf("shared model state was mutated")
+	}
+	if blocked, _, _ := isAuthBlockedForModel(auth, "smart", now); blocked {
+		t.Fatalf("expected auth to be available after clearing")
+	}
+	if ClearCooldown(auth, "") {
+		t.Fatalf("expected no change once the auth is already clear")
+	}
+
+	auth.Unavailable = true
+	auth.NextRetryAfter = now.Add(time.Hour)
+	if !ClearCooldown(auth, "") || auth.Unavailable || !auth.NextRetryAfter.IsZero() {
+		t.Fatalf("expected auth-level cooldown to be cleared: %+v", auth)
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+// loadCandidateOrder reads AUTH_CANDIDATE_ORDER, falling back to ID ordering.
+func loadCandidateOrder() string {
+	return internalsettings.GetString(internalsettings.AuthCandidateOrderKey)
+}
+
+// orderCandidates sorts ID-ordered candidates by order. The sort is stable, so ties keep
+// their ID order and round-robin indexing stays predictable.
+func (s *Selector) orderCandidates(available []*coreauth.Auth, order string) {
+	if len(available) < 2 {
+		return
+	}
+	switch order {
+	case internalsettings.AuthCandidateOrderPriority:
+		sort.SliceStable(available, func(i, j int) bool {
+			return authPriority(available[i]) > authPriority(available[j])
+		})
+	case internalsettings.AuthCandidateOrderCreatedAt:
+		sort.SliceStable(available, func(i, j int) bool {
+			return available[i].CreatedAt.Before(available[j].CreatedAt)
+		})
+	case internalsettings.AuthCandidateOrderLeastRecentlyUsed:
+		lastPicked := make(map[string]int64, len(available))
+		for _, auth := range available {
+			lastPicked[auth.ID] = s.lastPickedAt(auth.ID)
+		}
+		sort.SliceStable(available, func(i, j int) bool {
+			return lastPicked[available[i].ID] < lastPicked[available[j].ID]
+		})
+	}
+}
+
+// authPriority reads the priority attribute exported by the watcher; missing means 0.
+func authPriority(auth *coreauth.Auth) int {
+	if auth == nil || auth.Attributes == nil {
+		return 0
+	}
+	priority, errParse := strconv.Atoi(strings.TrimSpace(auth.Attributes["priority"]))
+	if errParse != nil {
+		return 0
+	}
+	return priority
+}
+
+// lastPickedAt returns when authID was last selected in unix nanoseconds, or 0 if never.
+func (s *Selector) lastPickedAt(authID string) int64 {
+	if value, ok := s.lastPicked.Load(authID); ok {
+		return value.(int64)
+	}
+	return 0
+}
+
+// markPicked records that auth was selected at now.
+func (s *Selector) markPicked(auth *coreauth.Auth, now time.Time) {
+	if auth == nil {
+		return
+	}
+	s.lastPicked.Store(auth.ID, now.UnixNano())
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+func candidateIDs(auths []*coreauth.Auth) []string {
+	ids := make([]string, 0, len(auths))
+	for _, auth := range auths {
+		ids = append(ids, auth.ID)
+	}
+	return ids
+}
+
+func sameIDs(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOrderCandidates(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newCandidates := func() []*coreauth.Auth {
+		// Already in the ID order produced by collectAvailable.
+		return []*coreauth.Auth{
+			{ID: "a", Attributes: map[string]string{"priority": "1"}, CreatedAt: base.Add(2 * time.Hour)},
+			{ID: "b", Attributes: map[string]string{"priority": "5"}, CreatedAt: base},
+			{ID: "c", CreatedAt: base.Add(time.Hour)},
+			{ID: "d", Attributes: map[string]string{"priority": "5"}, CreatedAt: base.Add(time.Hour)},
+		}
+	}
+	s := &Selector{}
+
+	available := newCandidates()
+	s.orderCandidates(available, internalsettings.AuthCandidateOrderID)
+	if got := candidateIDs(available); !sameIDs(got, "a", "b", "c", "d") {
+		t.Fatalf("id order: got %v", got)
+	}
+
+	available = newCandidates()
+	s.orderCandidates(available, internalsettings.AuthCandidateOrderPriority)
+	if got := candidateIDs(available); !sameIDs(got, "b", "d", "a", "c") {
+		t.Fatalf("priority order: got %v", got)
+	}
+
+	available = newCandidates()
+	s.orderCandidates(available, internalsettings.AuthCandidateOrderCreatedAt)
+	if got := candidateIDs(available); !sameIDs(got, "b", "c", "d", "a") {
+		t.Fatalf("created_at order: got %v", got)
+	}
+
+	s.markPicked(&coreauth.Auth{ID: "a"}, base.Add(time.Minute))
+	s.markPicked(&coreauth.Auth{ID: "c"}, base)
+	available = newCandidates()
+	s.orderCandidates(available, internalsettings.AuthCandidateOrderLeastRecentlyUsed)
+	if got := candidateIDs(available); !sameIDs(got, "b", "d", "c", "a") {
+		t.Fatalf("lru order: got %v", got)
+	}
+}
+
+func TestLoadCandidateOrder(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	cases := map[string]string{
+		`"Priority"`: internalsettings.AuthCandidateOrderPriority,
+		`"lru"`:      internalsettings.AuthCandidateOrderLeastRecentlyUsed,
+		`"random"`:   internalsettings.AuthCandidateOrderID,
+		`42`:         internalsettings.AuthCandidateOrderID,
+	}
+	for raw, want := range cases {
+		internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+			internalsettings.AuthCandidateOrderKey: json.RawMessage(raw),
+		})
+		if got := loadCandidateOrder(); got != want {
+			t.Fatalf("%s: expected %q, got %q", raw, want, got)
+		}
+	}
+}
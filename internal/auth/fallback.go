@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	sdkcliproxy "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/providerkeys"
+)
+
+// clientSupportsModel reports whether an auth serves the model; replaceable in tests.
+var clientSupportsModel = func(authID, model string) bool {
+	return sdkcliproxy.GlobalModelRegistry().ClientSupportsModel(authID, model)
+}
+
+// ResolveCooldownFallback returns the first configured fallback target that has an
+// available credential when every credential serving model is cooling down.
+// Fallback chains are never followed transitively, so at most MaxFallbackTargets targets are tried.
+// Targets whose model allowed rejects are skipped; a nil allowed accepts every target.
+func ResolveCooldownFallback(model string, now time.Time, listAuths func() []*coreauth.Auth, allowed func(model string) bool) (modelmapping.FallbackTarget, bool) {
+	model = strings.TrimSpace(model)
+	if model == "" || listAuths == nil {
+		return modelmapping.FallbackTarget{}, false
+	}
+	targets, ok := modelmapping.LookupFallbacks(model)
+	if !ok {
+		return modelmapping.FallbackTarget{}, false
+	}
+
+	auths := listAuths()
+	_, errAvailable := getAvailableAuths(authsServingModel(auths, "", model), "", model, now)
+	var cooldown *modelCooldownError
+	if !errors.As(errAvailable, &cooldown) {
+		return modelmapping.FallbackTarget{}, false
+	}
+
+	for i, target := range targets {
+		if i >= modelmapping.MaxFallbackTargets {
+			break
+		}
+		// Every credential serving the requested name is already known to be cooling down.
+		if strings.EqualFold(target.Model, model) {
+			continue
+		}
+		if allowed != nil && !allowed(target.Model) {
+			continue
+		}
+		candidates := authsServingModel(auths, target.Provider, target.Model)
+		if len(candidates) == 0 {
+			continue
+		}
+		if _, errTarget := getAvailableAuths(candidates, target.Provider, target.Model, now); errTarget == nil {
+			return target, true
+		}
+	}
+	return modelmapping.FallbackTarget{}, false
+}
+
+// authsServingModel filters auths by provider (when set) and registry model support.
+func authsServingModel(auths []*coreauth.Auth, provider, model string) []*coreauth.Auth {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	out := make([]*coreauth.Auth, 0, len(auths))
+	for _, candidate := range auths {
+		if candidate == nil || candidate.Disabled {
+			continue
+		}
+		if provider != "" && strings.ToLower(strings.TrimSpace(candidate.Provider)) != provider {
+			continue
+		}
+		if !clientSupportsModel(candidate.ID, model) || providerkeys.ExcludesModel(candidate.Attributes, model) {
+			continue
+		}
+		out = append(out, candidate)
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+)
+
+func TestResolveCooldownFallbackPicksFirstAvailableTarget(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	modelmapping.StoreModelMappings(now, []models.ModelMapping{{
+		ID:              1,
+		Provider:        "claude",
+		ModelName:       "claude-sonnet",
+		NewModelName:    "smart",
+		IsEnabled:       true,
+		FallbackEnabled: true,
+		FallbackTargets: datatypes.JSON(`[{"provider":"codex","model":"smart-codex"},{"provider":"gemini","model":"smart-gemini"}]`),
+	}})
+	t.Cleanup(func() { modelmapping.StoreModelMappings(now, nil) })
+
+	supported := map[string]string{"claude-1": "smart", "codex-1": "smart-codex", "gemini-1": "smart-gemini"}
+	prevSupports := clientSupportsModel
+	clientSupportsModel = func(authID, model string) bool { return supported[authID] == model }
+	t.Cleanup(func() { clientSupportsModel = prevSupports })
+
+	cooling := func(id, provider, model string) *coreauth.Auth {
+		return &coreauth.Auth{
+			ID:       id,
+			Provider: provider,
+			Status:   coreauth.StatusActive,
+			ModelStates: map[string]*coreauth.ModelState{
+				model: {
+					Unavailable:    true,
+					NextRetryAfter: now.Add(time.Minute),
+					Quota:          coreauth.QuotaState{Exceeded: true},
+				},
+			},
+		}
+	}
+	auths := []*coreauth.Auth{
+		cooling("claude-1", "claude", "smart"),
+		cooling("codex-1", "codex", "smart-codex"),
+		{ID: "gemini-1", Provider: "gemini", Status: coreauth.StatusActive},
+	}
+
+	target, ok := ResolveCooldownFallback("smart", now, func() []*coreauth.Auth { return auths }, nil)
+	if !ok {
+		t.Fatalf("expected fallback target")
+	}
+	if target.Provider != "gemini" || target.Model != "smart-gemini" {
+		t.Fatalf("expected gemini/smart-gemini, got %s", target.String())
+	}
+	outsidePlan := func(model string) bool { return model != "smart-gemini" }
+	if target, ok = ResolveCooldownFallback("smart", now, func() []*coreauth.Auth { return auths }, outsidePlan); ok {
+		t.Fatalf("expected no fallback to a target outside the plan, got %s", target.String())
+	}
+
+	auths[0] = &coreauth.Auth{ID: "claude-1", Provider: "claude", Status: coreauth.StatusActive}
+	if _, ok = ResolveCooldownFallback("smart", now, func() []*coreauth.Auth { return auths }, nil); ok {
+		t.Fatalf("expected no fallback while primary credentials are available")
+	}
+}
+
+func TestParseFallbackTargetsBounded(t *testing.T) {
+	raw := datatypes.JSON(`[{"provider":"a","model":"m1"},{"provider":"b","model":"m2"},{"provider":"c","model":"m3"},{"provider":"d","model":"m4"}]`)
+	if _, errParse := modelmapping.ParseFallbackTargets(raw); errParse == nil {
+		t.Fatalf("expected error for more than %d targets", modelmapping.MaxFallbackTargets)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/events"
 	log "github.com/sirupsen/logrus"
 )
 
-// StatusCodeHook logs auth results with status-based severity.
+// StatusCodeHook logs auth results with status-based severity and publishes auth state
+// changes as events.
 type StatusCodeHook struct {
 	coreauth.NoopHook
 }
@@ -38,6 +40,13 @@ func (h *StatusCodeHook) OnResult(ctx context.Context, result coreauth.Result) {
 
 	statusCode := result.Error.HTTPStatus
 	entry = entry.WithField("status_code", statusCode)
+	events.Publish(events.TypeAuthState, events.AuthStateData{
+		AuthID:     result.AuthID,
+		Provider:   result.Provider,
+		Change:     "failed",
+		Model:      result.Model,
+		StatusCode: statusCode,
+	})
 
 	switch {
 	case statusCode == 401:
@@ -60,3 +69,29 @@ func (h *StatusCodeHook) OnResult(ctx context.Context, result coreauth.Result) {
 		entry.Infof("request failed: %s", result.Error.Message)
 	}
 }
+
+// OnAuthRegistered publishes an auth added to the manager.
+func (h *StatusCodeHook) OnAuthRegistered(ctx context.Context, auth *coreauth.Auth) {
+	publishAuthState(auth, "registered")
+}
+
+// OnAuthUpdated publishes an auth whose state the manager replaced.
+func (h *StatusCodeHook) OnAuthUpdated(ctx context.Context, auth *coreauth.Auth) {
+	publishAuthState(auth, "updated")
+}
+
+// publishAuthState publishes the current state of auth.
+func publishAuthState(auth *coreauth.Auth, change string) {
+	if auth == nil {
+		return
+	}
+	events.Publish(events.TypeAuthState, events.AuthStateData{
+		AuthID:        auth.ID,
+		Provider:      auth.Provider,
+		Change:        change,
+		Status:        string(auth.Status),
+		StatusMessage: auth.StatusMessage,
+		Disabled:      auth.Disabled,
+		Unavailable:   auth.Unavailable,
+	})
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// requestPrefixKey carries the routing prefix stripped from the request path.
+type requestPrefixKey struct{}
+
+// WithRequestPrefix returns ctx tagged with the path prefix the request arrived under.
+func WithRequestPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, requestPrefixKey{}, NormalizePrefix(prefix))
+}
+
+// RequestPrefixFromContext returns the routing prefix of the request behind ctx, looking
+// at ctx itself and at the request of an attached gin context.
+func RequestPrefixFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if prefix, ok := ctx.Value(requestPrefixKey{}).(string); ok {
+		return prefix
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return ""
+	}
+	prefix, _ := ginCtx.Request.Context().Value(requestPrefixKey{}).(string)
+	return prefix
+}
+
+// NormalizePrefix trims slashes and whitespace and rejects nested prefixes, matching how
+// the watcher reads the prefix from auth metadata.
+func NormalizePrefix(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if strings.Contains(prefix, "/") {
+		return ""
+	}
+	return prefix
+}
+
+// filterAuthsByPrefix keeps the auths whose Prefix equals prefix. An empty prefix means the
+// request arrived without one and every auth stays eligible; a non-empty prefix never
+// falls back to unprefixed auths, so tenants routed by path stay isolated.
+func filterAuthsByPrefix(auths []*coreauth.Auth, prefix string) []*coreauth.Auth {
+	if prefix == "" {
+		return auths
+	}
+	out := make([]*coreauth.Auth, 0, len(auths))
+	for _, auth := range auths {
+		if auth != nil && auth.Prefix == prefix {
+			out = append(out, auth)
+		}
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestFilterAuthsByPrefix(t *testing.T) {
+	auths := []*coreauth.Auth{{ID: "a", Prefix: "teamA"}, {ID: "b"}, {ID: "c", Prefix: "teamB"}}
+	if got := candidateIDs(filterAuthsByPrefix(auths, "")); !sameIDs(got, "a", "b", "c") {
+		t.Fatalf("empty prefix should keep every auth, got %v", got)
+	}
+	if got := candidateIDs(filterAuthsByPrefix(auths, "teamA")); !sameIDs(got, "a") {
+		t.Fatalf("prefix should only keep matching auths, got %v", got)
+	}
+	if got := filterAuthsByPrefix(auths, "teamC"); len(got) != 0 {
+		t.Fatalf("unknown prefix should not fall back to unprefixed auths, got %v", candidateIDs(got))
+	}
+}
+
+func TestRequestPrefixFromGinContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Request = c.Request.WithContext(WithRequestPrefix(c.Request.Context(), "/teamA/"))
+	ctx := context.WithValue(context.Background(), "gin", c)
+	if got := RequestPrefixFromContext(ctx); got != "teamA" {
+		t.Fatalf("expected teamA, got %q", got)
+	}
+	if got := RequestPrefixFromContext(context.Background()); got != "" {
+		t.Fatalf("expected no prefix, got %q", got)
+	}
+}
@@ -12,15 +12,19 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/events"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/providerkeys"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/ratelimit"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -37,6 +41,8 @@ type Selector struct {
 	db *gorm.DB
 
 	roundRobinCursor atomic.Uint64
+	// lastPicked maps auth IDs to their last selection time for least-recently-used ordering.
+	lastPicked sync.Map
 
 	rateLimiter      *ratelimit.Manager
 	resolveRateLimit func(ctx context.Context, db *gorm.DB, userID uint64, provider, model, authKey string) (ratelimit.Decision, error)
@@ -46,7 +52,7 @@ type Selector struct {
 func NewSelector(db *gorm.DB) *Selector {
 	return &Selector{
 		db:               db,
-		rateLimiter:      ratelimit.NewManager(ratelimit.LoadSettingsConfig, time.Now, nil),
+		rateLimiter:      ratelimit.NewManager(ratelimit.CurrentSettingsConfig, time.Now, nil).WithDBLimiter(db),
 		resolveRateLimit: ratelimit.ResolveLimit,
 	}
 }
@@ -58,6 +64,18 @@ func (s *Selector) Pick(ctx context.Context, provider, model string, opts clipro
 		ctx = context.Background()
 	}
 
+	if prefix := RequestPrefixFromContext(ctx); prefix != "" {
+		auths = filterAuthsByPrefix(auths, prefix)
+		if len(auths) == 0 {
+			return nil, newModelNotFoundError(provider, model)
+		}
+	}
+
+	auths = filterExcludedAuths(auths, model)
+	if len(auths) == 0 {
+		return nil, newModelNotFoundError(provider, model)
+	}
+
 	now := time.Now()
 	available, errAvailable := getAvailableAuths(auths, provider, model, now)
 	if errAvailable != nil {
@@ -100,12 +118,19 @@ func (s *Selector) Pick(ctx context.Context, provider, model string, opts clipro
 		}
 	}
 
+	order := loadCandidateOrder()
+	s.orderCandidates(available, order)
+
 	mappingID, selector := s.loadModelMappingSelector(ctx, provider, model)
 	var selected *coreauth.Auth
 	var errPick error
 	switch selector {
 	case modelMappingSelectorFillFirst:
-		selected = s.pickFillFirst(ctx, available)
+		if order == internalsettings.AuthCandidateOrderID {
+			selected = s.pickFillFirst(ctx, available)
+		} else {
+			selected = available[0]
+		}
 	case modelMappingSelectorStick:
 		selected, errPick = s.pickStick(ctx, provider, model, mappingID, available)
 	default:
@@ -117,6 +142,9 @@ func (s *Selector) Pick(ctx context.Context, provider, model string, opts clipro
 	if errLimit := s.applyRateLimit(ctx, provider, model, selected); errLimit != nil {
 		return nil, errLimit
 	}
+	if order == internalsettings.AuthCandidateOrderLeastRecentlyUsed {
+		s.markPicked(selected, now)
+	}
 
 	if selected != nil && authGroupIDByAuthKey != nil {
 		billingUserGroupID := selectedUserGroupID
@@ -362,6 +390,14 @@ func (s *Selector) applyRateLimit(ctx context.Context, provider, model string, s
 	}
 	if !result.Allowed {
 		resetIn := result.Reset.Sub(time.Now())
+		events.Publish(events.TypeRateLimit, events.RateLimitData{
+			UserID:       userID,
+			Provider:     provider,
+			Model:        model,
+			AuthKey:      authKey,
+			Limit:        decision.Limit,
+			ResetSeconds: int(math.Ceil(max(resetIn, 0).Seconds())),
+		})
 		return newRateLimitError(resetIn)
 	}
 	return nil
@@ -695,6 +731,7 @@ type blockReason int
 const (
 	blockReasonNone blockReason = iota
 	blockReasonCooldown
+	blockReasonOutsideHours // Outside the active hours of the auth's group.
 	blockReasonDisabled
 	blockReasonOther
 )
@@ -807,7 +844,7 @@ func collectAvailable(auths []*coreauth.Auth, model string, now time.Time) (avai
 			available = append(available, candidate)
 			continue
 		}
-		if reason == blockReasonCooldown {
+		if reason == blockReasonCooldown || reason == blockReasonOutsideHours {
 			cooldownCount++
 			if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
 				earliest = next
@@ -847,6 +884,9 @@ func isAuthBlockedForModel(auth *coreauth.Auth, model string, now time.Time) (bo
 	if auth.Disabled || auth.Status == coreauth.StatusDisabled {
 		return true, blockReasonDisabled, time.Time{}
 	}
+	if hours, ok := authActiveHours(auth); ok && !hours.Contains(now) {
+		return true, blockReasonOutsideHours, hours.NextOpen(now)
+	}
 	if model != "" {
 		if len(auth.ModelStates) > 0 {
 			if state, ok := auth.ModelStates[model]; ok && state != nil {
@@ -891,3 +931,27 @@ func isAuthBlockedForModel(auth *coreauth.Auth, model string, now time.Time) (bo
 	}
 	return false, blockReasonNone, time.Time{}
 }
+
+// authActiveHours returns the active hours the watcher attached to auth from its group.
+func authActiveHours(auth *coreauth.Auth) (models.ActiveHours, bool) {
+	start := auth.Attributes[models.AuthAttrActiveHoursStart]
+	end := auth.Attributes[models.AuthAttrActiveHoursEnd]
+	if start == "" && end == "" {
+		return models.ActiveHours{}, false
+	}
+	hours, errParse := models.ParseActiveHours(start, end, auth.Attributes[models.AuthAttrActiveHoursTimezone])
+	return hours, errParse == nil
+}
+
+// filterExcludedAuths drops the auths whose excluded_models attribute matches model. The SDK
+// applies per-key exclusions for its own provider sections; Qwen and iFlow keys carry theirs
+// on the auth instead.
+func filterExcludedAuths(auths []*coreauth.Auth, model string) []*coreauth.Auth {
+	out := make([]*coreauth.Auth, 0, len(auths))
+	for _, auth := range auths {
+		if auth != nil && !providerkeys.ExcludesModel(auth.Attributes, model) {
+			out = append(out, auth)
+		}
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/providerkeys"
+)
+
+func TestSelectorSkipsAuthsExcludingModel(t *testing.T) {
+	excluding := &coreauth.Auth{
+		ID:         "qwen-a",
+		Provider:   "qwen",
+		Status:     coreauth.StatusActive,
+		Attributes: map[string]string{providerkeys.ExcludedModelsAttribute: "qwen3-*"},
+	}
+	serving := &coreauth.Auth{ID: "qwen-b", Provider: "qwen", Status: coreauth.StatusActive}
+	selector := &Selector{}
+
+	picked, errPick := selector.Pick(context.Background(), "qwen", "Qwen3-Coder-Plus", cliproxyexecutor.Options{}, []*coreauth.Auth{excluding, serving})
+	if errPick != nil || picked.ID != "qwen-b" {
+		t.Fatalf("expected the non-excluding auth, got %+v %v", picked, errPick)
+	}
+	if _, errPick = selector.Pick(context.Background(), "qwen", "qwen3-coder-plus", cliproxyexecutor.Options{}, []*coreauth.Auth{excluding}); errPick == nil {
+		t.Fatalf("expected no auth for an excluded model")
+	}
+	if picked, errPick = selector.Pick(context.Background(), "qwen", "qwen-max", cliproxyexecutor.Options{}, []*coreauth.Auth{excluding}); errPick != nil || picked.ID != "qwen-a" {
+		t.Fatalf("expected other models to stay available, got %+v %v", picked, errPick)
+	}
+}
@@ -0,0 +1,198 @@
+// Package backup writes consistent copies of the database to a directory and manages the
+// backup files kept there.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"gorm.io/gorm"
+)
+
+const (
+	// namePrefix starts every backup file name.
+	namePrefix = "cpab-"
+	// nameSuffix ends every backup file name; backups are SQLite databases.
+	nameSuffix = ".db"
+	// nameLayout formats the UTC creation time embedded in backup file names.
+	nameLayout = "20060102T150405Z"
+	// partialSuffix marks a backup that is still being written.
+	partialSuffix = ".partial"
+)
+
+// namePattern matches the names of finished backup files.
+var namePattern = regexp.MustCompile(`^cpab-\d{8}T\d{6}Z\.db$`)
+
+// ErrInvalidName is returned for names that are not backup file names.
+var ErrInvalidName = errors.New("backup: invalid backup name")
+
+// File describes one backup in the backup directory.
+type File struct {
+	Name      string    `json:"name"`       // File name inside the backup directory.
+	Size      int64     `json:"size"`       // Size in bytes.
+	CreatedAt time.Time `json:"created_at"` // When the backup was taken.
+}
+
+// Name returns the file name of a backup taken at t.
+func Name(t time.Time) string {
+	return namePrefix + t.UTC().Format(nameLayout) + nameSuffix
+}
+
+// Create writes a backup of conn taken at now to dir and returns it. SQLite databases
+// are copied with VACUUM INTO, which reads one consistent snapshot while other
+// connections keep writing. PostgreSQL databases are copied table by table into a SQLite
+// file inside a read-only repeatable-read transaction, so every table reflects the same
+// snapshot; the file can be loaded back with migrate-db. The backup is written under a
+// temporary name and renamed once complete, so List never reports a partial file.
+func Create(ctx context.Context, conn *gorm.DB, dir string, now time.Time) (File, error) {
+	if conn == nil {
+		return File{}, fmt.Errorf("backup: nil connection")
+	}
+	if errMkdir := os.MkdirAll(dir, 0o700); errMkdir != nil {
+		return File{}, fmt.Errorf("backup: create dir: %w", errMkdir)
+	}
+	name := Name(now)
+	path := filepath.Join(dir, name)
+	if _, errStat := os.Stat(path); errStat == nil {
+		return File{}, fmt.Errorf("backup: %s already exists", name)
+	}
+	tmp := path + partialSuffix
+	removePartial(tmp)
+
+	var errWrite error
+	if db.IsSQLite(conn) {
+		errWrite = vacuumInto(ctx, conn, tmp)
+	} else {
+		errWrite = dumpToSQLite(ctx, conn, tmp)
+	}
+	if errWrite != nil {
+		removePartial(tmp)
+		return File{}, errWrite
+	}
+	if errRename := os.Rename(tmp, path); errRename != nil {
+		removePartial(tmp)
+		return File{}, fmt.Errorf("backup: finish %s: %w", name, errRename)
+	}
+	info, errStat := os.Stat(path)
+	if errStat != nil {
+		return File{}, fmt.Errorf("backup: stat %s: %w", name, errStat)
+	}
+	return File{Name: name, Size: info.Size(), CreatedAt: now.UTC().Truncate(time.Second)}, nil
+}
+
+// vacuumInto copies a SQLite database to path. The statement runs on the read pool so
+// that, in WAL mode, writers are neither blocked by the copy nor queued behind it.
+func vacuumInto(ctx context.Context, conn *gorm.DB, path string) error {
+	sqlDB, errDB := db.ReadDB(conn)
+	if errDB != nil {
+		return fmt.Errorf("backup: vacuum into: %w", errDB)
+	}
+	if _, errVacuum := sqlDB.ExecContext(ctx, "VACUUM INTO ?", path); errVacuum != nil {
+		return fmt.Errorf("backup: vacuum into: %w", errVacuum)
+	}
+	return nil
+}
+
+// dumpToSQLite copies every table of src into a new SQLite database at path.
+func dumpToSQLite(ctx context.Context, src *gorm.DB, path string) error {
+	dst, errOpen := db.Open(path)
+	if errOpen != nil {
+		return fmt.Errorf("backup: open target: %w", errOpen)
+	}
+	errCopy := src.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, errCopy := db.CopyDatabase(ctx, tx, dst, db.CopyOptions{})
+		return errCopy
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	// Closing the last connection checkpoints the write-ahead log into the main file, so
+	// the finished backup is a single file.
+	if errClose := db.Close(dst); errCopy == nil && errClose != nil {
+		errCopy = errClose
+	}
+	if errCopy != nil {
+		return fmt.Errorf("backup: copy database: %w", errCopy)
+	}
+	return nil
+}
+
+// removePartial deletes an unfinished backup and its SQLite side files.
+func removePartial(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		_ = os.Remove(path + suffix)
+	}
+}
+
+// List returns the backups in dir, newest first. A missing directory holds no backups.
+func List(dir string) ([]File, error) {
+	entries, errRead := os.ReadDir(dir)
+	if errRead != nil {
+		if errors.Is(errRead, os.ErrNotExist) {
+			return []File{}, nil
+		}
+		return nil, fmt.Errorf("backup: read dir: %w", errRead)
+	}
+	files := make([]File, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !namePattern.MatchString(name) {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil {
+			continue
+		}
+		createdAt, errParse := time.Parse(nameLayout, strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), nameSuffix))
+		if errParse != nil {
+			continue
+		}
+		files = append(files, File{Name: name, Size: info.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, nil
+}
+
+// Path returns the path of the backup called name in dir. It returns ErrInvalidName for
+// anything but a backup file name, so callers may pass untrusted input, and an error
+// matching os.ErrNotExist when the backup does not exist.
+func Path(dir, name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", ErrInvalidName
+	}
+	path := filepath.Join(dir, name)
+	info, errStat := os.Stat(path)
+	if errStat != nil {
+		return "", errStat
+	}
+	if !info.Mode().IsRegular() {
+		return "", os.ErrNotExist
+	}
+	return path, nil
+}
+
+// Prune deletes all but the newest keep backups in dir and returns the deleted names.
+// A keep of 0 or less keeps every backup.
+func Prune(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	files, errList := List(dir)
+	if errList != nil {
+		return nil, errList
+	}
+	var removed []string
+	for _, file := range files[min(keep, len(files)):] {
+		if errRemove := os.Remove(filepath.Join(dir, file.Name)); errRemove != nil && !errors.Is(errRemove, os.ErrNotExist) {
+			return removed, fmt.Errorf("backup: remove %s: %w", file.Name, errRemove)
+		}
+		removed = append(removed, file.Name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// openMigrated opens a migrated SQLite database file holding one auth row.
+func openMigrated(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, errOpen := db.Open(filepath.Join(t.TempDir(), "live.db"))
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	if errCreate := conn.Create(&models.Auth{Key: "a.json", Content: []byte(`{"type":"codex"}`)}).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+	return conn
+}
+
+// assertBackupHoldsAuth opens the backup at path and checks it holds the seeded auth.
+func assertBackupHoldsAuth(t *testing.T, path string) {
+	t.Helper()
+	restored, errOpen := db.Open(path)
+	if errOpen != nil {
+		t.Fatalf("open backup: %v", errOpen)
+	}
+	defer func() { _ = db.Close(restored) }()
+	var keys []string
+	if errPluck := restored.Model(&models.Auth{}).Pluck("key", &keys); errPluck.Error != nil || len(keys) != 1 || keys[0] != "a.json" {
+		t.Fatalf("unexpected backup contents %v %v", keys, errPluck.Error)
+	}
+}
+
+func TestCreateListAndPrune(t *testing.T) {
+	conn := openMigrated(t)
+	dir := filepath.Join(t.TempDir(), "backups")
+	ctx := context.Background()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Keep a write transaction open to show the backup does not wait for it or see it.
+	tx := conn.Begin()
+	if errCreate := tx.Create(&models.Auth{Key: "uncommitted.json", Content: []byte(`{}`)}).Error; errCreate != nil {
+		t.Fatalf("create in tx: %v", errCreate)
+	}
+	file, errBackup := Create(ctx, conn, dir, start)
+	tx.Rollback()
+	if errBackup != nil {
+		t.Fatalf("create backup: %v", errBackup)
+	}
+	if file.Name != "cpab-20260301T120000Z.db" || file.Size == 0 || !file.CreatedAt.Equal(start) {
+		t.Fatalf("unexpected backup %+v", file)
+	}
+	assertBackupHoldsAuth(t, filepath.Join(dir, file.Name))
+	if _, errAgain := Create(ctx, conn, dir, start); errAgain == nil {
+		t.Fatal("expected a second backup with the same name to fail")
+	}
+
+	for i := 1; i <= 3; i++ {
+		if _, errCreate := Create(ctx, conn, dir, start.Add(time.Duration(i)*time.Hour)); errCreate != nil {
+			t.Fatalf("create backup %d: %v", i, errCreate)
+		}
+	}
+	if errWrite := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o600); errWrite != nil {
+		t.Fatalf("write stray file: %v", errWrite)
+	}
+	files, errList := List(dir)
+	if errList != nil || len(files) != 4 || files[0].Name != "cpab-20260301T150000Z.db" {
+		t.Fatalf("unexpected listing %+v %v", files, errList)
+	}
+
+	removed, errPrune := Prune(dir, 2)
+	if errPrune != nil || len(removed) != 2 || removed[1] != file.Name {
+		t.Fatalf("unexpected prune %v %v", removed, errPrune)
+	}
+	if files, _ = List(dir); len(files) != 2 {
+		t.Fatalf("expected two backups left, got %+v", files)
+	}
+	if removed, _ = Prune(dir, 0); len(removed) != 0 {
+		t.Fatal("expected keep 0 to keep every backup")
+	}
+}
+
+func TestDumpToSQLite(t *testing.T) {
+	conn := openMigrated(t)
+	path := filepath.Join(t.TempDir(), "dump.db")
+	if errDump := dumpToSQLite(context.Background(), conn, path); errDump != nil {
+		t.Fatalf("dump: %v", errDump)
+	}
+	if _, errStat := os.Stat(path + "-wal"); !errors.Is(errStat, os.ErrNotExist) {
+		t.Fatalf("expected the dump to be a single file, got %v", errStat)
+	}
+	assertBackupHoldsAuth(t, path)
+}
+
+func TestPathRejectsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"../live.db", "cpab-20260301T120000Z.db/..", "notes.txt", ""} {
+		if _, errPath := Path(dir, name); !errors.Is(errPath, ErrInvalidName) {
+			t.Fatalf("expected %q to be rejected, got %v", name, errPath)
+		}
+	}
+	if _, errPath := Path(dir, "cpab-20260301T120000Z.db"); !errors.Is(errPath, os.ErrNotExist) {
+		t.Fatalf("expected a missing backup to report not exist, got %v", errPath)
+	}
+}
@@ -72,27 +72,72 @@ func ResolveDefaultUserGroupID(ctx context.Context, db *gorm.DB) (*uint64, error
 	return &anyGroup.ID, nil
 }
 
+// Precedence tiers assigned by SelectBillingRule, highest first.
+const (
+	// RuleTierGroupModel matches the request's groups and exact provider/model.
+	RuleTierGroupModel = 3
+	// RuleTierGroupWildcard matches the request's groups with empty provider/model.
+	RuleTierGroupWildcard = 2
+	// RuleTierDefaultModel matches the default groups and exact provider/model.
+	RuleTierDefaultModel = 1
+	// RuleTierDefaultWildcard matches the default groups with empty provider/model.
+	RuleTierDefaultWildcard = 0
+)
+
+// RuleCandidate is one enabled rule that matched a precedence tier.
+type RuleCandidate struct {
+	Rule *models.BillingRule // Matching rule.
+	Tier int                 // Precedence tier; higher wins.
+}
+
 // SelectBillingRule matches billing rules using the following priority:
 // 1) authGroup + userGroup + provider + model
 // 2) authGroup + userGroup (provider/model are empty)
-// 3) default authGroup + default userGroup (provider/model exact, then empty)
+// 3) steps 1 and 2 for each of userGroupAncestors, nearest first
+// 4) default authGroup + default userGroup (provider/model exact, then empty)
+// Ties within a tier go to the most recently updated rule, then the highest ID.
 func SelectBillingRule(
 	rules []models.BillingRule,
 	authGroupID, userGroupID uint64,
+	userGroupAncestors []uint64,
 	defaultAuthGroupID, defaultUserGroupID uint64,
 	provider, model string,
 ) *models.BillingRule {
+	if len(userGroupAncestors) > 0 {
+		if best, _ := ExplainBillingRule(rules, authGroupID, userGroupID, 0, 0, provider, model); best != nil {
+			return best
+		}
+		for _, ancestorID := range userGroupAncestors {
+			if best, _ := ExplainBillingRule(rules, authGroupID, ancestorID, 0, 0, provider, model); best != nil {
+				return best
+			}
+		}
+	}
+	best, _ := ExplainBillingRule(rules, authGroupID, userGroupID, defaultAuthGroupID, defaultUserGroupID, provider, model)
+	return best
+}
+
+// ExplainBillingRule runs SelectBillingRule and also returns every rule that matched a
+// tier, in input order.
+func ExplainBillingRule(
+	rules []models.BillingRule,
+	authGroupID, userGroupID uint64,
+	defaultAuthGroupID, defaultUserGroupID uint64,
+	provider, model string,
+) (*models.BillingRule, []RuleCandidate) {
 	provider = strings.ToLower(strings.TrimSpace(provider))
 	model = strings.TrimSpace(model)
 
 	bestPriority := -1
 	bestUpdatedAt := time.Time{}
 	var best *models.BillingRule
+	var candidates []RuleCandidate
 
 	consider := func(r *models.BillingRule, priority int) {
 		if r == nil {
 			return
 		}
+		candidates = append(candidates, RuleCandidate{Rule: r, Tier: priority})
 		if priority > bestPriority {
 			bestPriority = priority
 			bestUpdatedAt = r.UpdatedAt
@@ -123,26 +168,26 @@ func SelectBillingRule(
 
 		if authGroupID != 0 && userGroupID != 0 && r.AuthGroupID == authGroupID && r.UserGroupID == userGroupID {
 			if rProvider == provider && rModel == model {
-				consider(r, 3)
+				consider(r, RuleTierGroupModel)
 				continue
 			}
 			if rProvider == "" && rModel == "" {
-				consider(r, 2)
+				consider(r, RuleTierGroupWildcard)
 				continue
 			}
 		}
 
 		if defaultAuthGroupID != 0 && defaultUserGroupID != 0 && r.AuthGroupID == defaultAuthGroupID && r.UserGroupID == defaultUserGroupID {
 			if rProvider == provider && rModel == model {
-				consider(r, 1)
+				consider(r, RuleTierDefaultModel)
 				continue
 			}
 			if rProvider == "" && rModel == "" {
-				consider(r, 0)
+				consider(r, RuleTierDefaultWildcard)
 				continue
 			}
 		}
 	}
 
-	return best
+	return best, candidates
 }
@@ -0,0 +1,33 @@
+package billing
+
+import (
+	"strconv"
+
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+// currencySymbols maps display currencies to the symbol prefixed to their amounts.
+// Other currencies are prefixed with their code.
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"CNY": "¥",
+	"JPY": "¥",
+}
+
+// DisplayCurrency returns the DISPLAY_CURRENCY code used to label amounts.
+func DisplayCurrency() string {
+	return internalsettings.GetString(internalsettings.DisplayCurrencyKey)
+}
+
+// FormatMicros formats an amount in micros with decimals places in the display currency,
+// e.g. "$1.50" or "CHF 1.50". The currency only labels the amount; nothing is converted.
+func FormatMicros(micros int64, decimals int) string {
+	currency := DisplayCurrency()
+	amount := strconv.FormatFloat(float64(micros)/1_000_000, 'f', decimals, 64)
+	if symbol, ok := currencySymbols[currency]; ok {
+		return symbol + amount
+	}
+	return currency + " " + amount
+}
@@ -0,0 +1,33 @@
+package billing
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+func TestFormatMicrosUsesDisplayCurrency(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	cases := []struct {
+		currency string
+		want     string
+	}{
+		{currency: "", want: "$1.2346"},
+		{currency: `"EUR"`, want: "€1.2346"},
+		{currency: `"CHF"`, want: "CHF 1.2346"},
+		{currency: `"usd"`, want: "$1.2346"},
+	}
+	for _, tc := range cases {
+		values := map[string]json.RawMessage{}
+		if tc.currency != "" {
+			values[internalsettings.DisplayCurrencyKey] = json.RawMessage(tc.currency)
+		}
+		internalsettings.StoreDBConfig(time.Now(), values)
+		if got := FormatMicros(1_234_567, 4); got != tc.want {
+			t.Fatalf("currency %s: expected %q, got %q", tc.currency, tc.want, got)
+		}
+	}
+}
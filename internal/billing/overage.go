@@ -0,0 +1,168 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OveragePolicy is the overage policy that applies to a user.
+type OveragePolicy struct {
+	Mode  string  // One of the models.OveragePolicy constants.
+	Grace float64 // Debt allowed under allow_grace.
+}
+
+// NormalizeOveragePolicy validates an overage policy name; empty selects block.
+func NormalizeOveragePolicy(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		return models.OveragePolicyBlock, nil
+	case models.OveragePolicyBlock, models.OveragePolicyAllowGrace, models.OveragePolicyPayAsYouGo:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("overage_policy must be %s, %s or %s",
+			models.OveragePolicyBlock, models.OveragePolicyAllowGrace, models.OveragePolicyPayAsYouGo)
+	}
+}
+
+// AllowsRequest reports whether a user without balance may still send requests while
+// owing debt.
+func (p OveragePolicy) AllowsRequest(debt float64) bool {
+	switch p.Mode {
+	case models.OveragePolicyAllowGrace:
+		return debt < p.Grace
+	case models.OveragePolicyPayAsYouGo:
+		return true
+	default:
+		return false
+	}
+}
+
+// AccruesDebt reports whether usage cost not covered by balance is recorded as debt.
+// Under block such cost only arises when concurrent requests race past the balance check,
+// and it is written off as before.
+func (p OveragePolicy) AccruesDebt() bool {
+	return p.Mode == models.OveragePolicyAllowGrace || p.Mode == models.OveragePolicyPayAsYouGo
+}
+
+// LoadUserOverage returns the overage policy of a user and the debt they owe. The policy
+// comes from the user's assigned and billed groups; when several groups set one other
+// than block, the group with the lowest ID wins. Unknown users get block and no debt.
+func LoadUserOverage(ctx context.Context, db *gorm.DB, userID uint64) (OveragePolicy, float64, error) {
+	policy := OveragePolicy{Mode: models.OveragePolicyBlock}
+	if db == nil {
+		return policy, 0, errors.New("nil db")
+	}
+	var user models.User
+	if errFind := db.WithContext(ctx).
+		Select("id", "user_group_id", "bill_user_group_id", "overage_debt").
+		Where("id = ?", userID).
+		Take(&user).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			return policy, 0, nil
+		}
+		return policy, 0, errFind
+	}
+	ids := append(user.UserGroupID.Values(), user.BillUserGroupID.Values()...)
+	if len(ids) == 0 {
+		return policy, user.OverageDebt, nil
+	}
+	var groups []models.UserGroup
+	if errGroups := db.WithContext(ctx).
+		Select("id", "overage_policy", "overage_grace").
+		Where("id IN ? AND overage_policy <> ?", ids, models.OveragePolicyBlock).
+		Order("id ASC").
+		Limit(1).
+		Find(&groups).Error; errGroups != nil {
+		return policy, 0, errGroups
+	}
+	if len(groups) > 0 {
+		policy = OveragePolicy{Mode: groups[0].OveragePolicy, Grace: groups[0].OverageGrace}
+	}
+	return policy, user.OverageDebt, nil
+}
+
+// payOverageDebt lowers the user's overage debt by up to available and returns the
+// amount paid, which the caller takes out of the funds it is crediting.
+func payOverageDebt(ctx context.Context, tx *gorm.DB, userID uint64, available float64) (float64, error) {
+	if tx == nil {
+		return 0, errors.New("nil tx")
+	}
+	if available <= 0 {
+		return 0, nil
+	}
+	var user models.User
+	if errFind := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "overage_debt").
+		Where("id = ?", userID).
+		Take(&user).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, errFind
+	}
+	paid := min(user.OverageDebt, available)
+	if paid <= 0 {
+		return 0, nil
+	}
+	if errUpdate := tx.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("overage_debt", gorm.Expr("CASE WHEN overage_debt > ? THEN overage_debt - ? ELSE 0 END", paid, paid)).Error; errUpdate != nil {
+		return 0, errUpdate
+	}
+	return paid, nil
+}
+
+// PayOverageDebtFromBill settles the bill owner's overage debt from up to available of
+// the bill's left quota, in the transaction that paid or credited the bill, and returns
+// the amount paid. Bills that are not paid and enabled pay nothing.
+func PayOverageDebtFromBill(ctx context.Context, tx *gorm.DB, bill *models.Bill, available float64) (float64, error) {
+	if bill == nil || !bill.IsEnabled || bill.Status != models.BillStatusPaid {
+		return 0, nil
+	}
+	paid, errPay := payOverageDebt(ctx, tx, bill.UserID, min(available, bill.LeftQuota))
+	if errPay != nil || paid <= 0 {
+		return 0, errPay
+	}
+	if errUpdate := tx.WithContext(ctx).
+		Model(&models.Bill{}).
+		Where("id = ?", bill.ID).
+		Updates(map[string]any{
+			"used_quota": gorm.Expr("used_quota + ?", paid),
+			"left_quota": gorm.Expr("left_quota - ?", paid),
+		}).Error; errUpdate != nil {
+		return 0, errUpdate
+	}
+	bill.UsedQuota += paid
+	bill.LeftQuota -= paid
+	return paid, nil
+}
+
+// PayOverageDebtFromCard settles the card holder's overage debt from up to available of
+// the card's balance, in the transaction that redeemed or credited the card, and returns
+// the amount paid.
+func PayOverageDebtFromCard(ctx context.Context, tx *gorm.DB, card *models.PrepaidCard, available float64) (float64, error) {
+	if card == nil || card.RedeemedUserID == nil || !card.IsEnabled {
+		return 0, nil
+	}
+	paid, errPay := payOverageDebt(ctx, tx, *card.RedeemedUserID, min(available, card.Balance))
+	if errPay != nil || paid <= 0 {
+		return 0, errPay
+	}
+	if errUpdate := tx.WithContext(ctx).
+		Model(&models.PrepaidCard{}).
+		Where("id = ?", card.ID).
+		Update("balance", gorm.Expr("balance - ?", paid)).Error; errUpdate != nil {
+		return 0, errUpdate
+	}
+	card.Balance -= paid
+	return paid, nil
+}
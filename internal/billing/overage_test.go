@@ -0,0 +1,70 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestTopUpsPayDownOverageDebt(t *testing.T) {
+	db := setupRenewalDB(t)
+	if errMigrate := db.AutoMigrate(&models.PrepaidCard{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	user := models.User{Username: "debtor", Email: "debtor@example.com", Password: "x", OverageDebt: 3}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	debt := func() float64 {
+		t.Helper()
+		var reloaded models.User
+		if errFind := db.Select("overage_debt").First(&reloaded, user.ID).Error; errFind != nil {
+			t.Fatalf("reload user: %v", errFind)
+		}
+		return reloaded.OverageDebt
+	}
+	grace := OveragePolicy{Mode: models.OveragePolicyAllowGrace, Grace: 2}
+	if grace.AllowsRequest(debt()) {
+		t.Fatal("expected the debt to exceed the grace before any top-up")
+	}
+
+	card := models.PrepaidCard{Name: "c", CardSN: "sn", Password: "pw", Amount: 2, Balance: 2, RedeemedUserID: &user.ID, RedeemedAt: &now, IsEnabled: true}
+	if errCreate := db.Create(&card).Error; errCreate != nil {
+		t.Fatalf("create card: %v", errCreate)
+	}
+	paid, errPay := PayOverageDebtFromCard(ctx, db, &card, card.Balance)
+	if errPay != nil || paid != 2 || card.Balance != 0 || debt() != 1 {
+		t.Fatalf("expected the card to pay 2 of the debt, paid %v balance %v debt %v (%v)", paid, card.Balance, debt(), errPay)
+	}
+	if !grace.AllowsRequest(debt()) {
+		t.Fatal("expected the remaining debt to be within the grace")
+	}
+
+	pending := models.Bill{PlanID: 1, UserID: user.ID, PeriodStart: now, PeriodEnd: now.AddDate(0, 1, 0), TotalQuota: 10, LeftQuota: 10, IsEnabled: true, Status: models.BillStatusPending}
+	if paid, _ := PayOverageDebtFromBill(ctx, db, &pending, pending.LeftQuota); paid != 0 {
+		t.Fatalf("expected an unpaid bill to pay nothing, paid %v", paid)
+	}
+	bill := pending
+	bill.Status = models.BillStatusPaid
+	if errCreate := db.Create(&bill).Error; errCreate != nil {
+		t.Fatalf("create bill: %v", errCreate)
+	}
+	paid, errPay = PayOverageDebtFromBill(ctx, db, &bill, bill.LeftQuota)
+	if errPay != nil || paid != 1 || debt() != 0 {
+		t.Fatalf("expected the bill to settle the debt, paid %v debt %v (%v)", paid, debt(), errPay)
+	}
+	var reloaded models.Bill
+	if errFind := db.First(&reloaded, bill.ID).Error; errFind != nil {
+		t.Fatalf("reload bill: %v", errFind)
+	}
+	if reloaded.LeftQuota != 9 || reloaded.UsedQuota != 1 {
+		t.Fatalf("unexpected bill quotas left=%v used=%v", reloaded.LeftQuota, reloaded.UsedQuota)
+	}
+	if paid, _ := PayOverageDebtFromBill(ctx, db, &bill, bill.LeftQuota); paid != 0 {
+		t.Fatalf("expected nothing to pay once the debt is settled, paid %v", paid)
+	}
+}
@@ -0,0 +1,238 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// planModelCacheTTL bounds how long a user's plan model access is reused, so bills that
+// start or run out between watcher changes are picked up.
+const planModelCacheTTL = time.Minute
+
+// PlanModels is the set of models a user's active bills allow.
+type PlanModels struct {
+	Restricted bool     // Whether the user is limited to the models below.
+	Plans      []string // Names of the restricting plans, sorted.
+
+	models map[string]struct{} // Allowed model names, lower-cased.
+}
+
+// Allows reports whether model may be requested. Plan entries match on model name; the
+// provider only labels the entry, since clients address models by name alone.
+func (p PlanModels) Allows(model string) bool {
+	if !p.Restricted {
+		return true
+	}
+	_, ok := p.models[strings.ToLower(strings.TrimSpace(model))]
+	return ok
+}
+
+// PlanNames joins the restricting plan names for error messages.
+func (p PlanModels) PlanNames() string {
+	return strings.Join(p.Plans, ", ")
+}
+
+// Key identifies the allowed model set, so callers with the same plan access can share
+// cached model lists. It is empty for unrestricted users.
+func (p PlanModels) Key() string {
+	if !p.Restricted {
+		return ""
+	}
+	names := make([]string, 0, len(p.models))
+	for name := range p.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// planSupportModel is one entry of Plan.SupportModels.
+type planSupportModel struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
+}
+
+// parsePlanSupportModels returns the model names in a support_models payload, accepting
+// both the object form and the legacy list of names.
+func parsePlanSupportModels(raw []byte) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var names []string
+	var objectModels []planSupportModel
+	if errObjects := json.Unmarshal(raw, &objectModels); errObjects == nil {
+		for _, model := range objectModels {
+			names = append(names, model.Name)
+		}
+	} else if errNames := json.Unmarshal(raw, &names); errNames != nil {
+		return nil, errObjects
+	}
+	cleaned := names[:0]
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			cleaned = append(cleaned, name)
+		}
+	}
+	return cleaned, nil
+}
+
+// LoadPlanModels resolves the models userID may request from the plans of their active
+// bills: paid, enabled, within their period and with quota left. The user is restricted
+// only when every such bill belongs to a plan with a non-empty support_models list, and
+// then to the union of those lists. Users without an active bill pay from prepaid cards
+// and are not restricted. The returned time is when the resolution stops being valid
+// because an active bill's period ends.
+func LoadPlanModels(ctx context.Context, db *gorm.DB, userID uint64, now time.Time) (PlanModels, time.Time, error) {
+	if db == nil {
+		return PlanModels{}, time.Time{}, errors.New("billing: nil db")
+	}
+	now = now.UTC()
+	var bills []models.Bill
+	if errBills := db.WithContext(ctx).
+		Model(&models.Bill{}).
+		Select("id", "plan_id", "period_end").
+		Where("user_id = ? AND is_enabled = ? AND status = ? AND left_quota > 0", userID, true, models.BillStatusPaid).
+		Where("period_start <= ? AND period_end >= ?", now, now).
+		Find(&bills).Error; errBills != nil {
+		return PlanModels{}, time.Time{}, errBills
+	}
+	if len(bills) == 0 {
+		return PlanModels{}, time.Time{}, nil
+	}
+	validUntil := bills[0].PeriodEnd
+	planIDs := make([]uint64, 0, len(bills))
+	for _, bill := range bills {
+		planIDs = append(planIDs, bill.PlanID)
+		if bill.PeriodEnd.Before(validUntil) {
+			validUntil = bill.PeriodEnd
+		}
+	}
+	var plans []models.Plan
+	if errPlans := db.WithContext(ctx).
+		Model(&models.Plan{}).
+		Select("id", "name", "support_models").
+		Where("id IN ?", planIDs).
+		Find(&plans).Error; errPlans != nil {
+		return PlanModels{}, time.Time{}, errPlans
+	}
+	byID := make(map[uint64]models.Plan, len(plans))
+	for _, plan := range plans {
+		byID[plan.ID] = plan
+	}
+
+	result := PlanModels{Restricted: true, models: make(map[string]struct{})}
+	seenPlans := make(map[uint64]struct{}, len(plans))
+	for _, bill := range bills {
+		plan, ok := byID[bill.PlanID]
+		if !ok {
+			// A bill whose plan was deleted has no model list left to enforce.
+			return PlanModels{}, validUntil, nil
+		}
+		names, errParse := parsePlanSupportModels(plan.SupportModels)
+		if errParse != nil || len(names) == 0 {
+			return PlanModels{}, validUntil, nil
+		}
+		for _, name := range names {
+			result.models[strings.ToLower(name)] = struct{}{}
+		}
+		if _, seen := seenPlans[plan.ID]; !seen {
+			seenPlans[plan.ID] = struct{}{}
+			result.Plans = append(result.Plans, plan.Name)
+		}
+	}
+	sort.Strings(result.Plans)
+	return result, validUntil, nil
+}
+
+// planModelEntry is one cached resolution.
+type planModelEntry struct {
+	value     PlanModels
+	expiresAt time.Time
+}
+
+// PlanModelCache caches LoadPlanModels per user. Entries expire after a minute, when an
+// active bill's period ends, or as soon as the version moves, which callers tie to the
+// watcher seeing bills or plans change.
+type PlanModelCache struct {
+	db      *gorm.DB
+	version func() time.Time
+	now     func() time.Time
+
+	mu             sync.Mutex
+	entries        map[uint64]planModelEntry
+	entriesVersion time.Time // Plan change time the entries were resolved against.
+	prunedAt       time.Time // When expired entries were last dropped.
+}
+
+// NewPlanModelCache constructs a cache of plan model access invalidated by version.
+func NewPlanModelCache(db *gorm.DB, version func() time.Time) *PlanModelCache {
+	if version == nil {
+		version = func() time.Time { return time.Time{} }
+	}
+	return &PlanModelCache{db: db, version: version, now: time.Now, entries: make(map[uint64]planModelEntry)}
+}
+
+// Get returns the plan model access of userID, resolving it when there is no fresh entry.
+// Errors are returned and not cached.
+func (c *PlanModelCache) Get(ctx context.Context, userID uint64) (PlanModels, error) {
+	if c == nil {
+		return PlanModels{}, nil
+	}
+	now := c.now()
+	version := c.version()
+	c.mu.Lock()
+	c.syncVersionLocked(version)
+	cached, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.value, nil
+	}
+	value, validUntil, errLoad := LoadPlanModels(ctx, c.db, userID, now)
+	if errLoad != nil {
+		return PlanModels{}, errLoad
+	}
+	expiresAt := now.Add(planModelCacheTTL)
+	if !validUntil.IsZero() && validUntil.Before(expiresAt) {
+		expiresAt = validUntil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !version.Equal(c.entriesVersion) {
+		// The version moved while loading; the value may already be stale.
+		return value, nil
+	}
+	c.pruneLocked(now)
+	c.entries[userID] = planModelEntry{value: value, expiresAt: expiresAt}
+	return value, nil
+}
+
+// syncVersionLocked drops every entry once when the version moves. c.mu must be held.
+func (c *PlanModelCache) syncVersionLocked(version time.Time) {
+	if version.Equal(c.entriesVersion) {
+		return
+	}
+	c.entries = make(map[uint64]planModelEntry)
+	c.entriesVersion = version
+}
+
+// pruneLocked drops expired entries at most once per planModelCacheTTL, so users who
+// stopped sending requests do not pile up between version changes. c.mu must be held.
+func (c *PlanModelCache) pruneLocked(now time.Time) {
+	if now.Sub(c.prunedAt) < planModelCacheTTL {
+		return
+	}
+	c.prunedAt = now
+	for id, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+)
+
+func TestLoadPlanModelsAcrossOverlappingBills(t *testing.T) {
+	db := setupRenewalDB(t)
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	plans := map[string]*models.Plan{
+		"basic":     {Name: "basic", SupportModels: datatypes.JSON(`[{"provider":"openai","name":"gpt-5-mini"}]`)},
+		"coder":     {Name: "coder", SupportModels: datatypes.JSON(`["Claude-Sonnet"]`)},
+		"unlimited": {Name: "unlimited", SupportModels: datatypes.JSON(`[]`)},
+	}
+	for _, plan := range plans {
+		if errCreate := db.Create(plan).Error; errCreate != nil {
+			t.Fatalf("create plan: %v", errCreate)
+		}
+	}
+	active := func(plan string) models.Bill {
+		return models.Bill{PlanID: plans[plan].ID, PeriodStart: now.AddDate(0, 0, -10), PeriodEnd: now.AddDate(0, 0, 20), LeftQuota: 5, IsEnabled: true, Status: models.BillStatusPaid}
+	}
+	expired := active("unlimited")
+	expired.PeriodStart, expired.PeriodEnd = now.AddDate(0, -2, 0), now.AddDate(0, -1, 0)
+	exhausted := active("unlimited")
+	exhausted.LeftQuota = 0
+	endsSoon := active("coder")
+	endsSoon.PeriodEnd = now.Add(time.Hour)
+
+	cases := []struct {
+		name       string
+		bills      []models.Bill
+		restricted bool
+		plans      string
+		allowed    []string
+		denied     []string
+		validUntil time.Time
+	}{
+		{name: "no bills"},
+		{name: "single restricted plan", bills: []models.Bill{active("basic")}, restricted: true, plans: "basic", allowed: []string{"gpt-5-mini"}, denied: []string{"claude-opus"}, validUntil: now.AddDate(0, 0, 20)},
+		{name: "two restricted plans", bills: []models.Bill{active("basic"), endsSoon}, restricted: true, plans: "basic, coder", allowed: []string{"gpt-5-mini", "claude-sonnet"}, denied: []string{"claude-opus"}, validUntil: now.Add(time.Hour)},
+		{name: "same plan twice", bills: []models.Bill{active("basic"), active("basic")}, restricted: true, plans: "basic", allowed: []string{"gpt-5-mini"}, validUntil: now.AddDate(0, 0, 20)},
+		{name: "restricted and unrestricted plans", bills: []models.Bill{active("basic"), active("unlimited")}, allowed: []string{"claude-opus"}, validUntil: now.AddDate(0, 0, 20)},
+		{name: "inactive unrestricted bills", bills: []models.Bill{active("basic"), expired, exhausted}, restricted: true, plans: "basic", denied: []string{"claude-opus"}, validUntil: now.AddDate(0, 0, 20)},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			user := models.User{Username: fmt.Sprintf("u%d", i), Email: fmt.Sprintf("u%d@example.com", i), Password: "x"}
+			if errCreate := db.Create(&user).Error; errCreate != nil {
+				t.Fatalf("create user: %v", errCreate)
+			}
+			for _, bill := range tc.bills {
+				bill.UserID = user.ID
+				if errCreate := db.Create(&bill).Error; errCreate != nil {
+					t.Fatalf("create bill: %v", errCreate)
+				}
+			}
+			got, validUntil, errLoad := LoadPlanModels(context.Background(), db, user.ID, now)
+			if errLoad != nil {
+				t.Fatalf("load plan models: %v", errLoad)
+			}
+			if got.Restricted != tc.restricted || got.PlanNames() != tc.plans || !validUntil.Equal(tc.validUntil) {
+				t.Fatalf("unexpected resolution %+v valid until %s", got, validUntil)
+			}
+			for _, model := range tc.allowed {
+				if !got.Allows(model) {
+					t.Fatalf("expected %s to be allowed", model)
+				}
+			}
+			for _, model := range tc.denied {
+				if got.Allows(model) {
+					t.Fatalf("expected %s to be denied", model)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanModelCacheFollowsVersion(t *testing.T) {
+	db := setupRenewalDB(t)
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	user := models.User{Username: "alice", Password: "x"}
+	plan := models.Plan{Name: "basic", SupportModels: datatypes.JSON(`[{"name":"gpt-5-mini"}]`)}
+	for _, row := range []any{&user, &plan} {
+		if errCreate := db.Create(row).Error; errCreate != nil {
+			t.Fatalf("create: %v", errCreate)
+		}
+	}
+	bill := models.Bill{PlanID: plan.ID, UserID: user.ID, PeriodStart: now.AddDate(0, 0, -1), PeriodEnd: now.AddDate(0, 1, 0), LeftQuota: 5, IsEnabled: true, Status: models.BillStatusPaid}
+	if errCreate := db.Create(&bill).Error; errCreate != nil {
+		t.Fatalf("create bill: %v", errCreate)
+	}
+
+	version := now
+	cache := NewPlanModelCache(db, func() time.Time { return version })
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+	if got, errGet := cache.Get(ctx, user.ID); errGet != nil || !got.Restricted {
+		t.Fatalf("expected a restricted user, got %+v %v", got, errGet)
+	}
+
+	if errUpdate := db.Model(&plan).Update("support_models", datatypes.JSON(`[]`)).Error; errUpdate != nil {
+		t.Fatalf("update plan: %v", errUpdate)
+	}
+	if got, _ := cache.Get(ctx, user.ID); !got.Restricted {
+		t.Fatal("expected the cached resolution until the version moves")
+	}
+	cache.entries[user.ID+1] = planModelEntry{expiresAt: now.Add(time.Second)}
+	version = version.Add(time.Second)
+	if got, _ := cache.Get(ctx, user.ID); got.Restricted {
+		t.Fatal("expected a new version to resolve the plan again")
+	}
+	if len(cache.entries) != 1 {
+		t.Fatalf("expected a new version to drop other entries, got %d", len(cache.entries))
+	}
+
+	cache.entries[user.ID+1] = planModelEntry{expiresAt: now.Add(time.Second)}
+	now = now.Add(2 * planModelCacheTTL)
+	if _, errGet := cache.Get(ctx, user.ID); errGet != nil {
+		t.Fatalf("get: %v", errGet)
+	}
+	if _, ok := cache.entries[user.ID+1]; ok {
+		t.Fatal("expected expired entries to be pruned")
+	}
+}
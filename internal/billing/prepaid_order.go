@@ -0,0 +1,23 @@
+package billing
+
+import (
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+// prepaidDeductionOrderClauses maps PREPAID_DEDUCTION_ORDER values to the ORDER BY clause
+// that lists prepaid cards in spending order. Every clause ends with the soonest-expiry
+// order and the card ID so ties are broken the same way in each mode.
+var prepaidDeductionOrderClauses = map[string]string{
+	internalsettings.PrepaidDeductionSoonestExpiry: "expires_at ASC NULLS LAST, redeemed_at ASC NULLS LAST, id ASC",
+	internalsettings.PrepaidDeductionFIFO:          "redeemed_at ASC NULLS LAST, expires_at ASC NULLS LAST, id ASC",
+	internalsettings.PrepaidDeductionLargestFirst:  "balance DESC, expires_at ASC NULLS LAST, redeemed_at ASC NULLS LAST, id ASC",
+}
+
+// PrepaidDeductionOrder returns the ORDER BY clause for prepaid cards selected by
+// PREPAID_DEDUCTION_ORDER, falling back to soonest expiry.
+func PrepaidDeductionOrder() string {
+	if order, ok := prepaidDeductionOrderClauses[internalsettings.GetString(internalsettings.PrepaidDeductionOrderKey)]; ok {
+		return order
+	}
+	return prepaidDeductionOrderClauses[internalsettings.PrepaidDeductionSoonestExpiry]
+}
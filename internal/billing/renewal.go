@@ -0,0 +1,281 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultRenewalInterval is how often expired auto-renew bills are checked.
+	defaultRenewalInterval = 5 * time.Minute
+	// renewalBatchSize bounds the bills renewed per query.
+	renewalBatchSize = 100
+	// expiryNotifyWindow bounds how long after period end a bill.expired event is still
+	// sent, so bills that ended before the job first ran do not flood the webhook.
+	expiryNotifyWindow = 24 * time.Hour
+)
+
+// RenewalJob creates the next period's bill for paid bills marked auto_renew once their
+// period ends. Quotas come from the bill's plan; the amount and period type are kept.
+type RenewalJob struct {
+	db       *gorm.DB
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewRenewalJob constructs a bill renewal job.
+func NewRenewalJob(db *gorm.DB) *RenewalJob {
+	if db == nil {
+		return nil
+	}
+	return &RenewalJob{
+		db:       db,
+		interval: defaultRenewalInterval,
+		now:      time.Now,
+	}
+}
+
+// Start runs the renewal loop in the background.
+func (j *RenewalJob) Start(ctx context.Context) {
+	if j == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go j.run(ctx)
+	log.Infof("bill renewal job started (interval=%s)", j.interval)
+}
+
+// run executes renewal passes until ctx is canceled.
+func (j *RenewalJob) run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			renewed, errRun := j.RunOnce(ctx)
+			if errRun != nil {
+				if !errors.Is(errRun, context.Canceled) {
+					log.WithError(errRun).Warn("bill renewal: pass failed")
+				}
+				continue
+			}
+			if len(renewed) > 0 {
+				log.Infof("bill renewal: renewed %d bill(s)", len(renewed))
+			}
+		}
+	}
+}
+
+// RunOnce renews every expired auto-renew bill that has no successor yet and returns
+// the created bills. Bills that lapsed for several periods are renewed period by period
+// until the latest one covers now. Paid bills that ended without a renewal then get a
+// bill.expired webhook event.
+func (j *RenewalJob) RunOnce(ctx context.Context) ([]models.Bill, error) {
+	if j == nil || j.db == nil {
+		return nil, errors.New("bill renewal: nil db")
+	}
+	clock := j.now
+	if clock == nil {
+		clock = time.Now
+	}
+	now := clock().UTC()
+
+	var renewed []models.Bill
+	skipped := make(map[uint64]struct{})
+	for {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return renewed, errCtx
+		}
+		var due []models.Bill
+		query := j.db.WithContext(ctx).
+			Where("auto_renew = ? AND is_enabled = ? AND status = ? AND period_end <= ?", true, true, models.BillStatusPaid, now).
+			Where("NOT EXISTS (SELECT 1 FROM bills AS renewals WHERE renewals.renewed_from_bill_id = bills.id)")
+		if len(skipped) > 0 {
+			ids := make([]uint64, 0, len(skipped))
+			for id := range skipped {
+				ids = append(ids, id)
+			}
+			query = query.Where("id NOT IN ?", ids)
+		}
+		if errFind := query.Order("period_end ASC, id ASC").Limit(renewalBatchSize).Find(&due).Error; errFind != nil {
+			return renewed, errFind
+		}
+		if len(due) == 0 {
+			break
+		}
+		for i := range due {
+			next, ok, errRenew := j.renew(ctx, &due[i], now)
+			if errRenew != nil {
+				return renewed, errRenew
+			}
+			if !ok {
+				skipped[due[i].ID] = struct{}{}
+				continue
+			}
+			renewed = append(renewed, next)
+			NotifyBillEvent(BillEventRenewed, &next)
+		}
+	}
+	if errExpired := j.notifyExpired(ctx, now); errExpired != nil {
+		return renewed, errExpired
+	}
+	return renewed, nil
+}
+
+// notifyExpired queues bill.expired for paid bills that ended within expiryNotifyWindow
+// without a successor. Each bill is claimed with a conditional update so it fires once.
+func (j *RenewalJob) notifyExpired(ctx context.Context, now time.Time) error {
+	var expired []models.Bill
+	if errFind := j.db.WithContext(ctx).
+		Where("is_enabled = ? AND status = ? AND expiry_notified_at IS NULL", true, models.BillStatusPaid).
+		Where("period_end <= ? AND period_end > ?", now, now.Add(-expiryNotifyWindow)).
+		Where("NOT EXISTS (SELECT 1 FROM bills AS renewals WHERE renewals.renewed_from_bill_id = bills.id)").
+		Order("period_end ASC, id ASC").
+		Find(&expired).Error; errFind != nil {
+		return errFind
+	}
+	for i := range expired {
+		res := j.db.WithContext(ctx).Model(&models.Bill{}).
+			Where("id = ? AND expiry_notified_at IS NULL", expired[i].ID).
+			Update("expiry_notified_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		NotifyBillEvent(BillEventExpired, &expired[i])
+	}
+	return nil
+}
+
+// renew creates the successor of prior in one transaction. It reports false when the
+// bill no longer qualifies, its plan is gone or disabled, or another pass renewed it.
+func (j *RenewalJob) renew(ctx context.Context, prior *models.Bill, now time.Time) (models.Bill, bool, error) {
+	var next models.Bill
+	renewed := false
+	errTx := j.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked models.Bill
+		if errLock := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, prior.ID).Error; errLock != nil {
+			if errors.Is(errLock, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return errLock
+		}
+		if !locked.AutoRenew || !locked.IsEnabled || locked.Status != models.BillStatusPaid || locked.PeriodEnd.After(now) {
+			return nil
+		}
+		var successors int64
+		if errCount := tx.Model(&models.Bill{}).Where("renewed_from_bill_id = ?", locked.ID).Count(&successors).Error; errCount != nil {
+			return errCount
+		}
+		if successors > 0 {
+			return nil
+		}
+
+		var plan models.Plan
+		if errPlan := tx.First(&plan, locked.PlanID).Error; errPlan != nil {
+			if errors.Is(errPlan, gorm.ErrRecordNotFound) {
+				log.Warnf("bill renewal: plan %d of bill %d not found, skipping", locked.PlanID, locked.ID)
+				return nil
+			}
+			return errPlan
+		}
+		if !plan.IsEnabled {
+			log.Infof("bill renewal: plan %d of bill %d is disabled, skipping", plan.ID, locked.ID)
+			return nil
+		}
+
+		priorID := locked.ID
+		periodStart := locked.PeriodEnd.UTC()
+		next = models.Bill{
+			PlanID:            plan.ID,
+			UserID:            locked.UserID,
+			UserGroupID:       plan.UserGroupID.Clean(),
+			PeriodType:        locked.PeriodType,
+			Amount:            locked.Amount,
+			PeriodStart:       periodStart,
+			PeriodEnd:         nextPeriodEnd(periodStart, locked.PeriodType),
+			TotalQuota:        plan.TotalQuota,
+			DailyQuota:        plan.DailyQuota,
+			UsedQuota:         0,
+			LeftQuota:         plan.TotalQuota,
+			UsedCount:         0,
+			RateLimit:         plan.RateLimit,
+			IsEnabled:         true,
+			Status:            models.BillStatusPaid,
+			AutoRenew:         true,
+			RenewedFromBillID: &priorID,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		}
+		if errCreate := tx.Create(&next).Error; errCreate != nil {
+			return errCreate
+		}
+		if _, errPay := PayOverageDebtFromBill(ctx, tx, &next, next.LeftQuota); errPay != nil {
+			return errPay
+		}
+		if errRefresh := refreshBillUserGroupIDs(ctx, tx, locked.UserID, now); errRefresh != nil {
+			return errRefresh
+		}
+		renewed = true
+		return nil
+	})
+	if errTx != nil {
+		return models.Bill{}, false, errTx
+	}
+	return next, renewed, nil
+}
+
+// nextPeriodEnd returns the end of a billing period of the given type starting at start.
+func nextPeriodEnd(start time.Time, periodType models.BillPeriodType) time.Time {
+	if periodType == models.BillPeriodTypeYearly {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// refreshBillUserGroupIDs recomputes the user groups granted by a user's active bills.
+func refreshBillUserGroupIDs(ctx context.Context, tx *gorm.DB, userID uint64, now time.Time) error {
+	var bills []models.Bill
+	if errFind := tx.WithContext(ctx).
+		Model(&models.Bill{}).
+		Select("user_group_id").
+		Where("user_id = ? AND is_enabled = ? AND status = ? AND left_quota > 0", userID, true, models.BillStatusPaid).
+		Where("period_start <= ? AND period_end >= ?", now, now).
+		Find(&bills).Error; errFind != nil {
+		return errFind
+	}
+
+	seen := make(map[uint64]struct{})
+	merged := make(models.UserGroupIDs, 0)
+	for _, bill := range bills {
+		for _, gid := range bill.UserGroupID.Clean() {
+			if gid == nil || *gid == 0 {
+				continue
+			}
+			if _, ok := seen[*gid]; ok {
+				continue
+			}
+			seen[*gid] = struct{}{}
+			idCopy := *gid
+			merged = append(merged, &idCopy)
+		}
+	}
+
+	return tx.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("bill_user_group_id", merged.Clean()).Error
+}
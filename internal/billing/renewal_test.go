@@ -0,0 +1,93 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func setupRenewalDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:renewal_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.Plan{}, &models.Bill{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	return db
+}
+
+func TestRenewalJobRenewsLapsedPeriodsOnce(t *testing.T) {
+	db := setupRenewalDB(t)
+	groupID := uint64(3)
+	user := models.User{Username: "alice", Password: "x"}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	plan := models.Plan{Name: "pro", TotalQuota: 100, DailyQuota: 10, RateLimit: 5, IsEnabled: true, UserGroupID: models.UserGroupIDs{&groupID}}
+	if errCreate := db.Create(&plan).Error; errCreate != nil {
+		t.Fatalf("create plan: %v", errCreate)
+	}
+
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bills := []models.Bill{
+		// Lapsed for two periods: renews into February, then March.
+		{PlanID: plan.ID, UserID: user.ID, PeriodType: models.BillPeriodTypeMonthly, Amount: 9, PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0), TotalQuota: 50, UsedQuota: 50, IsEnabled: true, Status: models.BillStatusPaid, AutoRenew: true},
+		// Not opted in.
+		{PlanID: plan.ID, UserID: user.ID, PeriodType: models.BillPeriodTypeMonthly, PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0), IsEnabled: true, Status: models.BillStatusPaid},
+		// Unpaid.
+		{PlanID: plan.ID, UserID: user.ID, PeriodType: models.BillPeriodTypeMonthly, PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0), IsEnabled: true, Status: models.BillStatusPending, AutoRenew: true},
+	}
+	if errCreate := db.Create(&bills).Error; errCreate != nil {
+		t.Fatalf("create bills: %v", errCreate)
+	}
+
+	job := NewRenewalJob(db)
+	job.now = func() time.Time { return now }
+	renewed, errRun := job.RunOnce(context.Background())
+	if errRun != nil {
+		t.Fatalf("RunOnce: %v", errRun)
+	}
+	if len(renewed) != 2 {
+		t.Fatalf("renewed %d bills, want 2", len(renewed))
+	}
+	latest := renewed[1]
+	if !latest.PeriodStart.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) || !latest.PeriodEnd.Equal(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("latest period = %s..%s", latest.PeriodStart, latest.PeriodEnd)
+	}
+	if latest.TotalQuota != 100 || latest.LeftQuota != 100 || latest.UsedQuota != 0 || latest.DailyQuota != 10 || latest.RateLimit != 5 || latest.Amount != 9 {
+		t.Fatalf("latest bill = %+v", latest)
+	}
+	if latest.RenewedFromBillID == nil || *latest.RenewedFromBillID != renewed[0].ID {
+		t.Fatalf("latest renewed_from = %v, want %d", latest.RenewedFromBillID, renewed[0].ID)
+	}
+
+	var refreshed models.User
+	if errFind := db.First(&refreshed, user.ID).Error; errFind != nil {
+		t.Fatalf("find user: %v", errFind)
+	}
+	if groups := refreshed.BillUserGroupID.Clean(); len(groups) != 1 || *groups[0] != groupID {
+		t.Fatalf("bill_user_group_id = %v", groups)
+	}
+
+	again, errAgain := job.RunOnce(context.Background())
+	if errAgain != nil {
+		t.Fatalf("second RunOnce: %v", errAgain)
+	}
+	if len(again) != 0 {
+		t.Fatalf("second pass renewed %d bills, want 0", len(again))
+	}
+	var total int64
+	db.Model(&models.Bill{}).Count(&total)
+	if total != 5 {
+		t.Fatalf("bill count = %d, want 5", total)
+	}
+}
@@ -0,0 +1,210 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// Resolution stages run by ResolveBillingRule.
+const (
+	// RuleStageGroups matches only the request's own auth and user groups.
+	RuleStageGroups = "groups"
+	// RuleStageInherited matches the request's auth group with an ancestor of its user group.
+	RuleStageInherited = "inherited"
+	// RuleStageDefaults also matches the default groups, filling in missing request groups.
+	RuleStageDefaults = "defaults"
+)
+
+// StageCandidate is a rule considered during one resolution stage.
+type StageCandidate struct {
+	RuleCandidate
+	Stage string // Resolution stage that considered the rule.
+}
+
+// RuleResolution describes how ResolveBillingRule picked a billing rule.
+type RuleResolution struct {
+	Rule               *models.BillingRule // Winning rule; nil when nothing matched.
+	Stage              string              // Stage that produced the winner.
+	Tier               int                 // Winner's precedence tier.
+	AuthGroupID        *uint64             // Auth group used by the deciding stage.
+	UserGroupID        *uint64             // User group used by the deciding stage.
+	InheritedFromID    *uint64             // Ancestor user group whose rule won, for the inherited stage.
+	DefaultAuthGroupID *uint64             // Default auth group, when the defaults stage ran.
+	DefaultUserGroupID *uint64             // Default user group, when the defaults stage ran.
+	Candidates         []StageCandidate    // Every rule considered, by stage.
+	Reason             string              // Why the winner was chosen, or why none was.
+}
+
+// ResolveBillingRule picks the billing rule for provider/model the way usage costing
+// does: first among the request's own groups, then up the user group's parent chain,
+// then again with the default groups filling in missing IDs and serving as a fallback.
+func ResolveBillingRule(ctx context.Context, db *gorm.DB, authGroupID, userGroupID *uint64, provider, model string) (RuleResolution, error) {
+	var res RuleResolution
+	if db == nil {
+		res.Reason = "no database"
+		return res, nil
+	}
+	provider = strings.TrimSpace(provider)
+	model = strings.TrimSpace(model)
+	providerLower := strings.ToLower(provider)
+
+	loadCandidateRules := func(primaryAuthGroupID, primaryUserGroupID, defaultAuthGroupID, defaultUserGroupID uint64) ([]models.BillingRule, error) {
+		q := db.WithContext(ctx).Model(&models.BillingRule{}).Where("is_enabled = true")
+		if defaultAuthGroupID != 0 && defaultUserGroupID != 0 && (defaultAuthGroupID != primaryAuthGroupID || defaultUserGroupID != primaryUserGroupID) {
+			q = q.Where("(auth_group_id = ? AND user_group_id = ?) OR (auth_group_id = ? AND user_group_id = ?)", primaryAuthGroupID, primaryUserGroupID, defaultAuthGroupID, defaultUserGroupID)
+		} else {
+			q = q.Where("auth_group_id = ? AND user_group_id = ?", primaryAuthGroupID, primaryUserGroupID)
+		}
+		q = q.Where("((LOWER(provider) = ? AND model = ?) OR (provider = '' AND model = ''))", providerLower, model)
+
+		var rules []models.BillingRule
+		if errFindRules := q.Order("id ASC").Find(&rules).Error; errFindRules != nil {
+			return nil, errFindRules
+		}
+		return rules, nil
+	}
+	record := func(stage string, candidates []RuleCandidate) {
+		for _, candidate := range candidates {
+			res.Candidates = append(res.Candidates, StageCandidate{RuleCandidate: candidate, Stage: stage})
+		}
+	}
+
+	if authGroupID != nil && userGroupID != nil {
+		rulesPrimary, errPrimary := loadCandidateRules(*authGroupID, *userGroupID, 0, 0)
+		if errPrimary != nil {
+			return res, errPrimary
+		}
+		rule, candidates := ExplainBillingRule(rulesPrimary, *authGroupID, *userGroupID, 0, 0, provider, model)
+		record(RuleStageGroups, candidates)
+		if rule != nil {
+			res.Rule = rule
+			res.Stage = RuleStageGroups
+			res.AuthGroupID = authGroupID
+			res.UserGroupID = userGroupID
+			res.Tier, res.Reason = explainWinner(rule, candidates)
+			return res, nil
+		}
+
+		ancestors, errAncestors := UserGroupAncestors(ctx, db, *userGroupID)
+		if errAncestors != nil {
+			return res, errAncestors
+		}
+		for _, ancestorID := range ancestors {
+			rulesInherited, errInherited := loadCandidateRules(*authGroupID, ancestorID, 0, 0)
+			if errInherited != nil {
+				return res, errInherited
+			}
+			rule, candidates := ExplainBillingRule(rulesInherited, *authGroupID, ancestorID, 0, 0, provider, model)
+			record(RuleStageInherited, candidates)
+			if rule == nil {
+				continue
+			}
+			inheritedFromID := ancestorID
+			res.Rule = rule
+			res.Stage = RuleStageInherited
+			res.AuthGroupID = authGroupID
+			res.UserGroupID = userGroupID
+			res.InheritedFromID = &inheritedFromID
+			res.Tier, res.Reason = explainWinner(rule, candidates)
+			res.Reason = fmt.Sprintf("%s; inherited from parent user group %d", res.Reason, ancestorID)
+			return res, nil
+		}
+	}
+
+	defaultAuthGroupID, errDefaultAuthGroup := ResolveDefaultAuthGroupID(ctx, db)
+	if errDefaultAuthGroup != nil {
+		return res, errDefaultAuthGroup
+	}
+	defaultUserGroupID, errDefaultUserGroup := ResolveDefaultUserGroupID(ctx, db)
+	if errDefaultUserGroup != nil {
+		return res, errDefaultUserGroup
+	}
+	res.DefaultAuthGroupID = defaultAuthGroupID
+	res.DefaultUserGroupID = defaultUserGroupID
+
+	primaryAuthGroupID := authGroupID
+	if primaryAuthGroupID == nil {
+		primaryAuthGroupID = defaultAuthGroupID
+	}
+	primaryUserGroupID := userGroupID
+	if primaryUserGroupID == nil {
+		primaryUserGroupID = defaultUserGroupID
+	}
+	res.AuthGroupID = primaryAuthGroupID
+	res.UserGroupID = primaryUserGroupID
+	if primaryAuthGroupID == nil || primaryUserGroupID == nil {
+		res.Reason = "no auth group or user group to match against"
+		return res, nil
+	}
+
+	var defaultAuthGroupIDValue uint64
+	if defaultAuthGroupID != nil {
+		defaultAuthGroupIDValue = *defaultAuthGroupID
+	}
+	var defaultUserGroupIDValue uint64
+	if defaultUserGroupID != nil {
+		defaultUserGroupIDValue = *defaultUserGroupID
+	}
+
+	rules, errRules := loadCandidateRules(*primaryAuthGroupID, *primaryUserGroupID, defaultAuthGroupIDValue, defaultUserGroupIDValue)
+	if errRules != nil {
+		return res, errRules
+	}
+	rule, candidates := ExplainBillingRule(rules, *primaryAuthGroupID, *primaryUserGroupID, defaultAuthGroupIDValue, defaultUserGroupIDValue, provider, model)
+	record(RuleStageDefaults, candidates)
+	if rule == nil {
+		res.Reason = "no enabled rule matches the groups or the default groups"
+		return res, nil
+	}
+	res.Rule = rule
+	res.Stage = RuleStageDefaults
+	res.Tier, res.Reason = explainWinner(rule, candidates)
+	return res, nil
+}
+
+// explainWinner returns the winner's tier and a sentence describing why it won.
+func explainWinner(winner *models.BillingRule, candidates []RuleCandidate) (int, string) {
+	tier := -1
+	for _, candidate := range candidates {
+		if candidate.Rule == winner {
+			tier = candidate.Tier
+			break
+		}
+	}
+	var reason string
+	switch tier {
+	case RuleTierGroupModel:
+		reason = "matches the auth and user groups with the exact provider and model"
+	case RuleTierGroupWildcard:
+		reason = "matches the auth and user groups with a wildcard provider and model"
+	case RuleTierDefaultModel:
+		reason = "matches the default groups with the exact provider and model"
+	case RuleTierDefaultWildcard:
+		reason = "matches the default groups with a wildcard provider and model"
+	default:
+		return tier, "selected rule"
+	}
+	tied := 0
+	sameUpdate := 0
+	for _, candidate := range candidates {
+		if candidate.Tier != tier || candidate.Rule == winner {
+			continue
+		}
+		tied++
+		if candidate.Rule.UpdatedAt.Equal(winner.UpdatedAt) {
+			sameUpdate++
+		}
+	}
+	switch {
+	case tied == 0:
+		return tier, reason
+	case sameUpdate > 0:
+		return tier, fmt.Sprintf("%s; %d other rule(s) tie at this tier, the highest id wins among equal updated_at", reason, tied)
+	default:
+		return tier, fmt.Sprintf("%s; %d other rule(s) tie at this tier, the most recently updated wins", reason, tied)
+	}
+}
@@ -0,0 +1,131 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestResolveBillingRuleExplainsPrecedence(t *testing.T) {
+	dsn := fmt.Sprintf("file:resolve_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.UserGroup{}, &models.BillingRule{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	defaultAuth := models.AuthGroup{Name: "default", IsDefault: true}
+	vipAuth := models.AuthGroup{Name: "vip"}
+	if errCreate := db.Create(&[]*models.AuthGroup{&defaultAuth, &vipAuth}).Error; errCreate != nil {
+		t.Fatalf("create auth groups: %v", errCreate)
+	}
+	defaultUser := models.UserGroup{Name: "default", IsDefault: true}
+	if errCreate := db.Create(&defaultUser).Error; errCreate != nil {
+		t.Fatalf("create user group: %v", errCreate)
+	}
+
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	rules := []models.BillingRule{
+		{AuthGroupID: defaultAuth.ID, UserGroupID: defaultUser.ID, BillingType: models.BillingTypePerRequest, IsEnabled: true, UpdatedAt: older},
+		{AuthGroupID: defaultAuth.ID, UserGroupID: defaultUser.ID, Provider: "claude", Model: "sonnet", BillingType: models.BillingTypePerRequest, IsEnabled: true, UpdatedAt: older},
+		{AuthGroupID: defaultAuth.ID, UserGroupID: defaultUser.ID, Provider: "Claude", Model: "sonnet", BillingType: models.BillingTypePerRequest, IsEnabled: true, UpdatedAt: newer},
+		{AuthGroupID: vipAuth.ID, UserGroupID: defaultUser.ID, Provider: "claude", Model: "opus", BillingType: models.BillingTypePerRequest, IsEnabled: true, UpdatedAt: older},
+	}
+	for i := range rules {
+		if errCreate := db.Create(&rules[i]).Error; errCreate != nil {
+			t.Fatalf("create rule: %v", errCreate)
+		}
+		if errUpdate := db.Model(&rules[i]).UpdateColumn("updated_at", rules[i].UpdatedAt).Error; errUpdate != nil {
+			t.Fatalf("set updated_at: %v", errUpdate)
+		}
+	}
+
+	// The vip group has no sonnet rule, so the defaults stage decides and the newer of
+	// the two exact default rules wins over the wildcard.
+	res, errResolve := ResolveBillingRule(context.Background(), db, &vipAuth.ID, &defaultUser.ID, "claude", "sonnet")
+	if errResolve != nil {
+		t.Fatalf("resolve: %v", errResolve)
+	}
+	if res.Rule == nil || res.Rule.ID != rules[2].ID || res.Stage != RuleStageDefaults || res.Tier != RuleTierDefaultModel {
+		t.Fatalf("unexpected winner %+v", res)
+	}
+	if len(res.Candidates) != 3 || !strings.Contains(res.Reason, "most recently updated") {
+		t.Fatalf("unexpected candidates %d or reason %q", len(res.Candidates), res.Reason)
+	}
+
+	res, errResolve = ResolveBillingRule(context.Background(), db, &vipAuth.ID, &defaultUser.ID, "claude", "opus")
+	if errResolve != nil {
+		t.Fatalf("resolve: %v", errResolve)
+	}
+	if res.Rule == nil || res.Rule.ID != rules[3].ID || res.Stage != RuleStageGroups || res.Tier != RuleTierGroupModel {
+		t.Fatalf("unexpected group winner %+v", res)
+	}
+}
+
+func TestResolveBillingRuleWalksUserGroupParents(t *testing.T) {
+	dsn := fmt.Sprintf("file:resolve_inherit_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.UserGroup{}, &models.BillingRule{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	authGroup := models.AuthGroup{Name: "default", IsDefault: true}
+	if errCreate := db.Create(&authGroup).Error; errCreate != nil {
+		t.Fatalf("create auth group: %v", errCreate)
+	}
+	defaultUser := models.UserGroup{Name: "default", IsDefault: true}
+	partner := models.UserGroup{Name: "partner"}
+	if errCreate := db.Create(&[]*models.UserGroup{&defaultUser, &partner}).Error; errCreate != nil {
+		t.Fatalf("create user groups: %v", errCreate)
+	}
+	reseller := models.UserGroup{Name: "reseller", ParentID: &partner.ID}
+	if errCreate := db.Create(&reseller).Error; errCreate != nil {
+		t.Fatalf("create child group: %v", errCreate)
+	}
+
+	rules := []models.BillingRule{
+		{AuthGroupID: authGroup.ID, UserGroupID: defaultUser.ID, Provider: "claude", Model: "sonnet", BillingType: models.BillingTypePerRequest, IsEnabled: true},
+		{AuthGroupID: authGroup.ID, UserGroupID: partner.ID, Provider: "claude", Model: "sonnet", BillingType: models.BillingTypePerRequest, IsEnabled: true},
+	}
+	if errCreate := db.Create(&rules).Error; errCreate != nil {
+		t.Fatalf("create rules: %v", errCreate)
+	}
+
+	res, errResolve := ResolveBillingRule(context.Background(), db, &authGroup.ID, &reseller.ID, "claude", "sonnet")
+	if errResolve != nil {
+		t.Fatalf("resolve: %v", errResolve)
+	}
+	if res.Rule == nil || res.Rule.ID != rules[1].ID || res.Stage != RuleStageInherited || res.InheritedFromID == nil || *res.InheritedFromID != partner.ID {
+		t.Fatalf("expected the parent group's rule, got %+v", res)
+	}
+
+	parents, errParents := LoadUserGroupParents(context.Background(), db)
+	if errParents != nil {
+		t.Fatalf("load parents: %v", errParents)
+	}
+	ancestors := AncestorUserGroupIDs(parents, reseller.ID)
+	if rule := SelectBillingRule(rules, authGroup.ID, reseller.ID, ancestors, authGroup.ID, defaultUser.ID, "claude", "sonnet"); rule == nil || rule.ID != rules[1].ID {
+		t.Fatalf("expected SelectBillingRule to inherit the parent rule, got %+v", rule)
+	}
+	if rule := SelectBillingRule(rules, authGroup.ID, reseller.ID, nil, authGroup.ID, defaultUser.ID, "claude", "sonnet"); rule == nil || rule.ID != rules[0].ID {
+		t.Fatalf("expected the default rule without ancestors, got %+v", rule)
+	}
+
+	// A cycle written directly to the database must not hang the walk.
+	if errUpdate := db.Model(&partner).Update("parent_id", reseller.ID).Error; errUpdate != nil {
+		t.Fatalf("create cycle: %v", errUpdate)
+	}
+	if ancestors, errAncestors := UserGroupAncestors(context.Background(), db, reseller.ID); errAncestors != nil || len(ancestors) != 1 || ancestors[0] != partner.ID {
+		t.Fatalf("unexpected ancestors across a cycle %v %v", ancestors, errAncestors)
+	}
+}
@@ -0,0 +1,57 @@
+package billing
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// LoadUserGroupParents returns the parent of every user group that has one.
+func LoadUserGroupParents(ctx context.Context, db *gorm.DB) (map[uint64]uint64, error) {
+	parents := make(map[uint64]uint64)
+	if db == nil {
+		return parents, nil
+	}
+	var rows []models.UserGroup
+	if errFind := db.WithContext(ctx).
+		Select("id", "parent_id").
+		Where("parent_id IS NOT NULL").
+		Find(&rows).Error; errFind != nil {
+		return nil, errFind
+	}
+	for _, row := range rows {
+		if row.ParentID != nil && *row.ParentID != 0 {
+			parents[row.ID] = *row.ParentID
+		}
+	}
+	return parents, nil
+}
+
+// AncestorUserGroupIDs returns the parent chain of userGroupID, nearest first. The walk
+// stops at the first repeated group, so a cycle written outside the admin API cannot loop.
+func AncestorUserGroupIDs(parents map[uint64]uint64, userGroupID uint64) []uint64 {
+	var ancestors []uint64
+	seen := map[uint64]struct{}{userGroupID: {}}
+	for current := userGroupID; ; {
+		parentID, ok := parents[current]
+		if !ok {
+			return ancestors
+		}
+		if _, visited := seen[parentID]; visited {
+			return ancestors
+		}
+		seen[parentID] = struct{}{}
+		ancestors = append(ancestors, parentID)
+		current = parentID
+	}
+}
+
+// UserGroupAncestors loads the parent chain of userGroupID, nearest first.
+func UserGroupAncestors(ctx context.Context, db *gorm.DB, userGroupID uint64) ([]uint64, error) {
+	parents, errParents := LoadUserGroupParents(ctx, db)
+	if errParents != nil {
+		return nil, errParents
+	}
+	return AncestorUserGroupIDs(parents, userGroupID), nil
+}
@@ -0,0 +1,195 @@
+package billing
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bill webhook event names.
+const (
+	// BillEventPaid fires when a bill becomes paid.
+	BillEventPaid = "bill.paid"
+	// BillEventStatusChanged fires for any other bill status transition.
+	BillEventStatusChanged = "bill.status_changed"
+	// BillEventRenewed fires when the renewal job creates the next period's bill.
+	BillEventRenewed = "bill.renewed"
+	// BillEventExpired fires when a paid bill reaches its period end without a renewal.
+	BillEventExpired = "bill.expired"
+)
+
+// Bill webhook request headers.
+const (
+	// BillWebhookEventHeader carries the event name.
+	BillWebhookEventHeader = "X-Bill-Event"
+	// BillWebhookTimestampHeader carries the Unix timestamp included in the signature.
+	BillWebhookTimestampHeader = "X-Bill-Timestamp"
+	// BillWebhookSignatureHeader carries "sha256=" and the hex HMAC of "<timestamp>.<body>".
+	BillWebhookSignatureHeader = "X-Bill-Signature"
+)
+
+const (
+	// billWebhookQueueSize bounds events waiting for delivery.
+	billWebhookQueueSize = 256
+	// billWebhookTimeout bounds a single delivery attempt.
+	billWebhookTimeout = 10 * time.Second
+	// billWebhookAttempts is the number of delivery attempts per event.
+	billWebhookAttempts = 4
+)
+
+// billWebhookBackoff is the delay before the first retry; it doubles on each retry.
+var billWebhookBackoff = time.Second
+
+// BillWebhookPayload is the JSON body posted to BILL_WEBHOOK_URL.
+type BillWebhookPayload struct {
+	Event       string    `json:"event"`
+	BillID      uint64    `json:"bill_id"`
+	UserID      uint64    `json:"user_id"`
+	PlanID      uint64    `json:"plan_id"`
+	Status      int       `json:"status"`
+	PeriodType  int       `json:"period_type"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	RenewedFrom *uint64   `json:"renewed_from,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// billWebhookDelivery is one queued event and its destination.
+type billWebhookDelivery struct {
+	url     string
+	secret  string
+	payload BillWebhookPayload
+}
+
+var (
+	billWebhookOnce   sync.Once
+	billWebhookQueue  chan billWebhookDelivery
+	billWebhookClient = &http.Client{Timeout: billWebhookTimeout}
+)
+
+// NotifyBillEvent queues event for bill to BILL_WEBHOOK_URL and returns immediately.
+// Nothing is sent when no URL is configured; events are dropped when the queue is full.
+func NotifyBillEvent(event string, bill *models.Bill) {
+	if bill == nil {
+		return
+	}
+	url := internalsettings.GetString(internalsettings.BillWebhookURLKey)
+	if url == "" {
+		return
+	}
+	billWebhookOnce.Do(func() {
+		billWebhookQueue = make(chan billWebhookDelivery, billWebhookQueueSize)
+		go runBillWebhookWorker(billWebhookQueue)
+	})
+	delivery := billWebhookDelivery{
+		url:    url,
+		secret: internalsettings.GetString(internalsettings.BillWebhookSecretKey),
+		payload: BillWebhookPayload{
+			Event:       event,
+			BillID:      bill.ID,
+			UserID:      bill.UserID,
+			PlanID:      bill.PlanID,
+			Status:      int(bill.Status),
+			PeriodType:  int(bill.PeriodType),
+			PeriodStart: bill.PeriodStart.UTC(),
+			PeriodEnd:   bill.PeriodEnd.UTC(),
+			RenewedFrom: bill.RenewedFromBillID,
+			OccurredAt:  time.Now().UTC(),
+		},
+	}
+	select {
+	case billWebhookQueue <- delivery:
+	default:
+		log.WithField("bill_id", bill.ID).Warnf("bill webhook: queue full, dropping %s event", event)
+	}
+}
+
+// runBillWebhookWorker delivers queued events in order.
+func runBillWebhookWorker(queue <-chan billWebhookDelivery) {
+	for delivery := range queue {
+		deliverBillWebhook(context.Background(), billWebhookClient, delivery)
+	}
+}
+
+// deliverBillWebhook posts delivery, retrying failures with exponential backoff.
+func deliverBillWebhook(ctx context.Context, client *http.Client, delivery billWebhookDelivery) {
+	body, errMarshal := json.Marshal(delivery.payload)
+	if errMarshal != nil {
+		log.WithError(errMarshal).Warn("bill webhook: marshal payload failed")
+		return
+	}
+	backoff := billWebhookBackoff
+	var errPost error
+	for attempt := 1; attempt <= billWebhookAttempts; attempt++ {
+		if errPost = postBillWebhook(ctx, client, delivery, body); errPost == nil {
+			return
+		}
+		if attempt == billWebhookAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	log.WithError(errPost).WithField("bill_id", delivery.payload.BillID).
+		Warnf("bill webhook: %s delivery failed after %d attempts", delivery.payload.Event, billWebhookAttempts)
+}
+
+// postBillWebhook sends one signed delivery attempt.
+func postBillWebhook(ctx context.Context, client *http.Client, delivery billWebhookDelivery, body []byte) error {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(body))
+	if errReq != nil {
+		return errReq
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(BillWebhookEventHeader, delivery.payload.Event)
+	req.Header.Set(BillWebhookTimestampHeader, timestamp)
+	if delivery.secret != "" {
+		req.Header.Set(BillWebhookSignatureHeader, SignBillWebhook(delivery.secret, timestamp, body))
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return errDo
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignBillWebhook returns the signature header value for body sent at timestamp.
+func SignBillWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// BillStatusEvent returns the webhook event for a transition into status.
+func BillStatusEvent(status models.BillStatus) string {
+	if status == models.BillStatusPaid {
+		return BillEventPaid
+	}
+	return BillEventStatusChanged
+}
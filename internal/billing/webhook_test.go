@@ -0,0 +1,91 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestDeliverBillWebhookSignsAndRetries(t *testing.T) {
+	previous := billWebhookBackoff
+	billWebhookBackoff = time.Millisecond
+	t.Cleanup(func() { billWebhookBackoff = previous })
+
+	var calls atomic.Int32
+	var gotPayload BillWebhookPayload
+	var gotSignature, gotTimestamp string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(BillWebhookSignatureHeader)
+		gotTimestamp = r.Header.Get(BillWebhookTimestampHeader)
+		_ = json.Unmarshal(gotBody, &gotPayload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	deliverBillWebhook(context.Background(), server.Client(), billWebhookDelivery{
+		url:    server.URL,
+		secret: "shh",
+		payload: BillWebhookPayload{
+			Event:       BillEventPaid,
+			BillID:      7,
+			UserID:      3,
+			PlanID:      2,
+			Status:      int(models.BillStatusPaid),
+			PeriodStart: start,
+			PeriodEnd:   start.AddDate(0, 1, 0),
+		},
+	})
+
+	if calls.Load() != 3 {
+		t.Fatalf("attempts = %d, want 3", calls.Load())
+	}
+	if gotPayload.BillID != 7 || gotPayload.UserID != 3 || gotPayload.PlanID != 2 || gotPayload.Event != BillEventPaid {
+		t.Fatalf("payload = %+v", gotPayload)
+	}
+	if want := SignBillWebhook("shh", gotTimestamp, gotBody); gotSignature != want {
+		t.Fatalf("signature = %q, want %q", gotSignature, want)
+	}
+}
+
+func TestRenewalJobQueuesExpiryOnce(t *testing.T) {
+	db := setupRenewalDB(t)
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	bills := []models.Bill{
+		{PlanID: 1, UserID: 1, PeriodType: models.BillPeriodTypeMonthly, PeriodStart: now.AddDate(0, -1, 0), PeriodEnd: now.Add(-time.Hour), IsEnabled: true, Status: models.BillStatusPaid},
+		// Ended long before the notify window.
+		{PlanID: 1, UserID: 1, PeriodType: models.BillPeriodTypeMonthly, PeriodStart: now.AddDate(0, -3, 0), PeriodEnd: now.AddDate(0, -2, 0), IsEnabled: true, Status: models.BillStatusPaid},
+	}
+	if errCreate := db.Create(&bills).Error; errCreate != nil {
+		t.Fatalf("create bills: %v", errCreate)
+	}
+
+	job := NewRenewalJob(db)
+	job.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		if _, errRun := job.RunOnce(context.Background()); errRun != nil {
+			t.Fatalf("RunOnce: %v", errRun)
+		}
+	}
+
+	var notified []models.Bill
+	if errFind := db.Where("expiry_notified_at IS NOT NULL").Find(&notified).Error; errFind != nil {
+		t.Fatalf("find notified: %v", errFind)
+	}
+	if len(notified) != 1 || notified[0].ID != bills[0].ID {
+		t.Fatalf("notified bills = %+v, want only bill %d", notified, bills[0].ID)
+	}
+}
@@ -12,20 +12,68 @@ import (
 )
 
 const (
-	EnvConfigPath   = "CONFIG_PATH"
-	EnvDBConnection = "DB_CONNECTION"
-	EnvJWTSecret    = "JWT_SECRET"
-	EnvJWTExpiry    = "JWT_EXPIRY"
+	EnvConfigPath       = "CONFIG_PATH"
+	EnvDBConnection     = "DB_CONNECTION"
+	EnvDBReadConnection = "DB_READ_CONNECTION"
+	EnvJWTSecret        = "JWT_SECRET"
+	EnvJWTExpiry        = "JWT_EXPIRY"
+	EnvMigrateMode      = "MIGRATE_MODE"
+	EnvShutdownTimeout  = "SHUTDOWN_TIMEOUT"
+	EnvBackupDir        = "BACKUP_DIR"
 )
 
+// DefaultShutdownTimeout is how long shutdown waits for in-flight requests by default.
+const DefaultShutdownTimeout = 30 * time.Second
+
 // AppConfig holds resolved application configuration values.
 type AppConfig struct {
-	ConfigPath string
+	ConfigPath      string
+	MigrateMode     string        // Startup migration mode: auto, plan or skip.
+	ShutdownTimeout time.Duration // How long shutdown waits for in-flight requests.
 }
 
 // LoadFromEnv loads app config from environment variables.
 func LoadFromEnv() (AppConfig, error) {
-	return AppConfig{ConfigPath: ResolveConfigPath(os.Getenv(EnvConfigPath))}, nil
+	shutdownTimeout, errTimeout := ParseShutdownTimeout(os.Getenv(EnvShutdownTimeout))
+	if errTimeout != nil {
+		return AppConfig{}, errTimeout
+	}
+	return AppConfig{
+		ConfigPath:      ResolveConfigPath(os.Getenv(EnvConfigPath)),
+		MigrateMode:     strings.TrimSpace(os.Getenv(EnvMigrateMode)),
+		ShutdownTimeout: shutdownTimeout,
+	}, nil
+}
+
+// ParseShutdownTimeout parses a drain timeout such as "45s"; empty input selects
+// DefaultShutdownTimeout and zero skips waiting for in-flight requests.
+func ParseShutdownTimeout(raw string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return DefaultShutdownTimeout, nil
+	}
+	timeout, errParse := time.ParseDuration(trimmed)
+	if errParse != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid shutdown timeout %q (want a duration such as 30s)", raw)
+	}
+	return timeout, nil
+}
+
+// DefaultBackupDir is where database backups are written when BACKUP_DIR is unset.
+const DefaultBackupDir = "./backups"
+
+// LoadBackupDir returns the absolute directory database backups are written to. It is
+// read from the environment rather than the settings table so that admins cannot point
+// backups at arbitrary paths from the panel.
+func LoadBackupDir() string {
+	dir := strings.TrimSpace(os.Getenv(EnvBackupDir))
+	if dir == "" {
+		dir = DefaultBackupDir
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
 }
 
 // ResolveConfigPath normalizes the config path and applies defaults.
@@ -82,6 +130,40 @@ func LoadDatabaseDSN(configPath string) (string, error) {
 	return "", ErrMissingDatabaseDSN
 }
 
+// LoadReadDatabaseDSN reads the optional read-only replica DSN. It returns an empty DSN
+// when no replica is configured or the config file does not exist.
+func LoadReadDatabaseDSN(configPath string) (string, error) {
+	if dsn := strings.TrimSpace(os.Getenv(EnvDBReadConnection)); dsn != "" {
+		return dsn, nil
+	}
+
+	// fileConfig maps the YAML fields needed for replica DSN resolution.
+	type fileConfig struct {
+		DatabaseReadDSN string `yaml:"database-read-dsn"`
+		Database        struct {
+			ReadDSN string `yaml:"read-dsn"`
+		} `yaml:"database"`
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("read config file: %w", err)
+	}
+
+	var cfg fileConfig
+	if errUnmarshal := yaml.Unmarshal(data, &cfg); errUnmarshal != nil {
+		return "", fmt.Errorf("parse config file: %w", errUnmarshal)
+	}
+
+	if dsn := strings.TrimSpace(cfg.DatabaseReadDSN); dsn != "" {
+		return dsn, nil
+	}
+	return strings.TrimSpace(cfg.Database.ReadDSN), nil
+}
+
 // defaultJWTExpiry is used when the config omits or invalidates JWT expiry.
 const defaultJWTExpiry = 30 * 24 * time.Hour
 
@@ -40,3 +40,45 @@ func TestLoadJWTConfig_EnvOverride(t *testing.T) {
 		t.Fatalf("expected expiry=%s, got %s", (2 * time.Hour).String(), cfg.Expiry.String())
 	}
 }
+
+func TestLoadReadDatabaseDSN(t *testing.T) {
+	dir := t.TempDir()
+
+	dsn, err := LoadReadDatabaseDSN(filepath.Join(dir, "missing.yaml"))
+	if err != nil || dsn != "" {
+		t.Fatalf("expected no replica for a missing config, got %q, %v", dsn, err)
+	}
+
+	configPath := filepath.Join(dir, "config.yaml")
+	if errWrite := os.WriteFile(configPath, []byte("database-dsn: primary\ndatabase-read-dsn: replica\n"), 0600); errWrite != nil {
+		t.Fatalf("write config: %v", errWrite)
+	}
+	dsn, err = LoadReadDatabaseDSN(configPath)
+	if err != nil || dsn != "replica" {
+		t.Fatalf("expected replica dsn from file, got %q, %v", dsn, err)
+	}
+
+	t.Setenv("DB_READ_CONNECTION", "env-replica")
+	dsn, err = LoadReadDatabaseDSN(configPath)
+	if err != nil || dsn != "env-replica" {
+		t.Fatalf("expected env replica dsn, got %q, %v", dsn, err)
+	}
+}
+
+func TestLoadFromEnvShutdownTimeout(t *testing.T) {
+	cfg, err := LoadFromEnv()
+	if err != nil || cfg.ShutdownTimeout != DefaultShutdownTimeout {
+		t.Fatalf("expected default shutdown timeout, got %s, %v", cfg.ShutdownTimeout, err)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "45s")
+	cfg, err = LoadFromEnv()
+	if err != nil || cfg.ShutdownTimeout != 45*time.Second {
+		t.Fatalf("expected 45s, got %s, %v", cfg.ShutdownTimeout, err)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "-1s")
+	if _, err = LoadFromEnv(); err == nil {
+		t.Fatalf("expected a negative timeout to be rejected")
+	}
+}
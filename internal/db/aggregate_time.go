@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AggregateTime scans a nullable timestamp produced by an aggregate such as MAX. SQLite
+// loses the column type on aggregates and returns the stored text, which plain time
+// fields cannot scan.
+type AggregateTime struct {
+	Time  time.Time
+	Valid bool // Whether the value was not NULL.
+}
+
+// Scan implements sql.Scanner.
+func (t *AggregateTime) Scan(value any) error {
+	*t = AggregateTime{}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v.UTC(), true
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	default:
+		return fmt.Errorf("db: scan %T into AggregateTime", value)
+	}
+}
+
+// Value implements driver.Valuer.
+func (t AggregateTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+// parse reads a timestamp stored as SQLite text.
+func (t *AggregateTime) parse(raw string) error {
+	raw = strings.TrimSuffix(strings.TrimSpace(raw), "Z")
+	for _, layout := range sqliteTimeLayouts {
+		if parsed, errParse := time.ParseInLocation(layout, raw, time.UTC); errParse == nil {
+			t.Time, t.Valid = parsed.UTC(), true
+			return nil
+		}
+	}
+	return fmt.Errorf("db: parse timestamp %q", raw)
+}
+
+// Ptr returns the time, or nil when the value was NULL.
+func (t AggregateTime) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	value := t.Time
+	return &value
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// defaultCopyBatchSize is the number of rows read and inserted per batch by CopyDatabase.
+const defaultCopyBatchSize = 500
+
+// ErrTargetNotEmpty is returned by CopyDatabase when the target already holds data.
+var ErrTargetNotEmpty = errors.New("db: target database is not empty")
+
+// CopyOptions tunes CopyDatabase.
+type CopyOptions struct {
+	BatchSize int  // Rows per batch; 0 uses the default.
+	Resume    bool // Keep tables a previous run already copied and redo the others.
+}
+
+// TableCopy reports the outcome for one table.
+type TableCopy struct {
+	Table   string // Table name.
+	Source  int64  // Rows in the source table.
+	Target  int64  // Rows in the target table after the copy.
+	Skipped bool   // Whether a resumed run found the table already copied.
+}
+
+// CopyError names the table a copy stopped at.
+type CopyError struct {
+	Table string
+	Err   error
+}
+
+// Error implements error.
+func (e *CopyError) Error() string {
+	return fmt.Sprintf("db: copy table %s: %v", e.Table, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CopyError) Unwrap() error { return e.Err }
+
+// CopyDatabase migrates dst and copies every table managed by Migrate from src, in
+// dependency order and with primary keys preserved. Each table is copied in its own
+// transaction and its row count verified, so a failure leaves earlier tables complete and
+// is reported as a *CopyError naming the table. dst must hold no data unless
+// opts.Resume is set, in which case tables whose counts already match are skipped and
+// the rest are cleared and copied again. PostgreSQL sequences are reset afterwards.
+func CopyDatabase(ctx context.Context, src, dst *gorm.DB, opts CopyOptions) ([]TableCopy, error) {
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("db: nil connection")
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultCopyBatchSize
+	}
+	src = src.WithContext(ctx)
+	dst = dst.WithContext(ctx)
+
+	if !opts.Resume {
+		table, errEmpty := firstNonEmptyTable(dst)
+		if errEmpty != nil {
+			return nil, errEmpty
+		}
+		if table != "" {
+			return nil, fmt.Errorf("%w: table %s has rows", ErrTargetNotEmpty, table)
+		}
+	}
+	if errMigrate := Migrate(dst); errMigrate != nil {
+		return nil, fmt.Errorf("db: migrate target: %w", errMigrate)
+	}
+
+	var report []TableCopy
+	for _, model := range migratedModels() {
+		table, errTable := tableName(dst, model)
+		if errTable != nil {
+			return report, errTable
+		}
+		result, errCopy := copyTable(src, dst, model, table, batchSize, opts.Resume)
+		if errCopy != nil {
+			return report, &CopyError{Table: table, Err: errCopy}
+		}
+		report = append(report, result)
+		if result.Skipped {
+			log.Infof("db copy: %s already copied (%d rows)", table, result.Target)
+		} else {
+			log.Infof("db copy: %s copied (%d rows)", table, result.Target)
+		}
+	}
+
+	if DialectName(dst) == DialectPostgres {
+		if errSeq := resetPostgresSequences(dst); errSeq != nil {
+			return report, errSeq
+		}
+	}
+	return report, nil
+}
+
+// firstNonEmptyTable returns the first migrated table in conn that holds rows, or "".
+func firstNonEmptyTable(conn *gorm.DB) (string, error) {
+	migrator := conn.Migrator()
+	for _, model := range migratedModels() {
+		if !migrator.HasTable(model) {
+			continue
+		}
+		var count int64
+		if errCount := conn.Model(model).Count(&count).Error; errCount != nil {
+			return "", fmt.Errorf("db: count target rows: %w", errCount)
+		}
+		if count > 0 {
+			table, errTable := tableName(conn, model)
+			if errTable != nil {
+				return "", errTable
+			}
+			return table, nil
+		}
+	}
+	return "", nil
+}
+
+// tableName resolves the table of model.
+func tableName(conn *gorm.DB, model any) (string, error) {
+	stmt := &gorm.Statement{DB: conn}
+	if errParse := stmt.Parse(model); errParse != nil {
+		return "", fmt.Errorf("db: parse model %T: %w", model, errParse)
+	}
+	return stmt.Schema.Table, nil
+}
+
+// copyTable replaces the rows of one table in dst with the rows of src. Rows are loaded
+// into the model type, so JSON columns and group ID arrays are re-encoded by their
+// Valuer for the target dialect.
+func copyTable(src, dst *gorm.DB, model any, table string, batchSize int, resume bool) (TableCopy, error) {
+	result := TableCopy{Table: table}
+	if !src.Migrator().HasTable(model) {
+		return result, nil
+	}
+	if errCount := src.Model(model).Count(&result.Source).Error; errCount != nil {
+		return result, fmt.Errorf("count source rows: %w", errCount)
+	}
+	if resume {
+		var existing int64
+		if errCount := dst.Model(model).Count(&existing).Error; errCount != nil {
+			return result, fmt.Errorf("count target rows: %w", errCount)
+		}
+		if existing == result.Source && existing > 0 {
+			result.Target = existing
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	stmt := &gorm.Statement{DB: dst}
+	if errParse := stmt.Parse(model); errParse != nil {
+		return result, fmt.Errorf("parse model: %w", errParse)
+	}
+	rowsType := reflect.SliceOf(reflect.TypeOf(model).Elem())
+	errTx := dst.Transaction(func(tx *gorm.DB) error {
+		// Drop rows seeded by Migrate or left by an interrupted run.
+		if errClear := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(model).Error; errClear != nil {
+			return fmt.Errorf("clear target rows: %w", errClear)
+		}
+		batch := reflect.New(rowsType)
+		res := src.Model(model).FindInBatches(batch.Interface(), batchSize, func(_ *gorm.DB, _ int) error {
+			return tx.Table(table).Create(rowValues(tx.Statement.Context, stmt.Schema, batch.Elem())).Error
+		})
+		if res.Error != nil {
+			return fmt.Errorf("copy rows: %w", res.Error)
+		}
+		if errCount := tx.Model(model).Count(&result.Target).Error; errCount != nil {
+			return fmt.Errorf("count target rows: %w", errCount)
+		}
+		if result.Target != result.Source {
+			return fmt.Errorf("row count mismatch: source %d, target %d", result.Source, result.Target)
+		}
+		return nil
+	})
+	return result, errTx
+}
+
+// rowValues converts a slice of model rows into column maps. Creating from structs would
+// replace false and zero values with the column defaults; maps are written as they are.
+func rowValues(ctx context.Context, sch *schema.Schema, rows reflect.Value) []map[string]any {
+	out := make([]map[string]any, 0, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		values := make(map[string]any, len(sch.Fields))
+		for _, field := range sch.Fields {
+			if field.DBName == "" || !field.Creatable {
+				continue
+			}
+			value, _ := field.ValueOf(ctx, row)
+			values[field.DBName] = value
+		}
+		out = append(out, values)
+	}
+	return out
+}
+
+// resetPostgresSequences moves each serial ID sequence past the copied rows so new inserts
+// do not collide with preserved IDs.
+func resetPostgresSequences(conn *gorm.DB) error {
+	for _, model := range migratedModels() {
+		stmt := &gorm.Statement{DB: conn}
+		if errParse := stmt.Parse(model); errParse != nil {
+			return fmt.Errorf("db: parse model %T: %w", model, errParse)
+		}
+		field := stmt.Schema.PrioritizedPrimaryField
+		if field == nil || !field.AutoIncrement || field.DataType != schema.Uint && field.DataType != schema.Int {
+			continue
+		}
+		table := stmt.Schema.Table
+		query := fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL) FROM %s",
+			table, field.DBName, field.DBName, field.DBName, table,
+		)
+		if errSeq := conn.Exec(query).Error; errSeq != nil {
+			return fmt.Errorf("db: reset sequence for %s: %w", table, errSeq)
+		}
+	}
+	return nil
+}
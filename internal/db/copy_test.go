@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+)
+
+func TestCopyDatabasePreservesRows(t *testing.T) {
+	src := openTestDB(t)
+	if errMigrate := Migrate(src); errMigrate != nil {
+		t.Fatalf("migrate source: %v", errMigrate)
+	}
+	groupID := uint64(42)
+	auth := models.Auth{
+		ID:          7,
+		Key:         "auth-7",
+		AuthGroupID: models.AuthGroupIDs{&groupID},
+		Content:     datatypes.JSON(`{"type":"codex"}`),
+		IsAvailable: false,
+	}
+	if errCreate := src.Create(&auth).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+	if errUpdate := src.Model(&models.Auth{}).Where("id = ?", auth.ID).Update("is_available", false).Error; errUpdate != nil {
+		t.Fatalf("disable auth: %v", errUpdate)
+	}
+	for i := 0; i < 5; i++ {
+		if errCreate := src.Create(&models.ModelMapping{Provider: "codex", ModelName: "m", NewModelName: "n", IsEnabled: true}).Error; errCreate != nil {
+			t.Fatalf("create mapping: %v", errCreate)
+		}
+	}
+
+	dst, errOpen := Open(filepath.Join(t.TempDir(), "target.db"))
+	if errOpen != nil {
+		t.Fatalf("open target: %v", errOpen)
+	}
+	report, errCopy := CopyDatabase(context.Background(), src, dst, CopyOptions{BatchSize: 2})
+	if errCopy != nil {
+		t.Fatalf("copy: %v", errCopy)
+	}
+	if len(report) != len(migratedModels()) {
+		t.Fatalf("expected one report entry per table, got %d", len(report))
+	}
+
+	var copied models.Auth
+	if errFind := dst.First(&copied, auth.ID).Error; errFind != nil {
+		t.Fatalf("load copied auth: %v", errFind)
+	}
+	if copied.IsAvailable || len(copied.AuthGroupID) != 1 || *copied.AuthGroupID[0] != groupID || string(copied.Content) != `{"type":"codex"}` {
+		t.Fatalf("unexpected copied auth: %+v", copied)
+	}
+	var mappings int64
+	dst.Model(&models.ModelMapping{}).Count(&mappings)
+	if mappings != 5 {
+		t.Fatalf("expected 5 mappings across batches, got %d", mappings)
+	}
+	var srcSettings, dstSettings int64
+	src.Model(&models.Setting{}).Count(&srcSettings)
+	dst.Model(&models.Setting{}).Count(&dstSettings)
+	if srcSettings == 0 || srcSettings != dstSettings {
+		t.Fatalf("expected seeded settings replaced by the source rows, got %d vs %d", srcSettings, dstSettings)
+	}
+
+	if _, errAgain := CopyDatabase(context.Background(), src, dst, CopyOptions{}); !errors.Is(errAgain, ErrTargetNotEmpty) {
+		t.Fatalf("expected a non-empty target to be refused, got %v", errAgain)
+	}
+	report, errResume := CopyDatabase(context.Background(), src, dst, CopyOptions{Resume: true})
+	if errResume != nil {
+		t.Fatalf("resume: %v", errResume)
+	}
+	for _, entry := range report {
+		if entry.Source > 0 && !entry.Skipped {
+			t.Fatalf("expected resume to skip copied table %s", entry.Table)
+		}
+	}
+}
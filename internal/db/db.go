@@ -98,12 +98,17 @@ func openPostgres(dsn string) (*gorm.DB, error) {
 	}
 
 	conn, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
-		Logger: newGormLogger(),
+		Logger:  newGormLogger(),
+		NowFunc: utcNow,
 	})
 	if err != nil {
 		_ = sqlDB.Close()
 		return nil, fmt.Errorf("db: open: %w", err)
 	}
+	if errCallbacks := registerUTCTimestamps(conn); errCallbacks != nil {
+		_ = sqlDB.Close()
+		return nil, errCallbacks
+	}
 
 	sqlDB.SetMaxOpenConns(25)
 	sqlDB.SetMaxIdleConns(25)
@@ -128,7 +133,8 @@ func openSQLite(dsn string) (*gorm.DB, error) {
 	}
 
 	conn, err := gorm.Open(sqlite.Open(normalized), &gorm.Config{
-		Logger: newGormLogger(),
+		Logger:  newGormLogger(),
+		NowFunc: utcNow,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("db: open sqlite: %w", err)
@@ -138,6 +144,10 @@ func openSQLite(dsn string) (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("db: open sqlite sql: %w", err)
 	}
+	if errCallbacks := registerUTCTimestamps(conn); errCallbacks != nil {
+		_ = sqlDB.Close()
+		return nil, errCallbacks
+	}
 
 	sqlDB.SetMaxOpenConns(10)
 	sqlDB.SetMaxIdleConns(10)
@@ -155,6 +165,16 @@ func openSQLite(dsn string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("db: ping: %w", errPing)
 	}
 
+	if sqliteSharesFile(normalized) {
+		pool, errPool := newSQLiteConnPool(sqlDB, sqlite.DriverName, normalized)
+		if errPool != nil {
+			_ = sqlDB.Close()
+			return nil, errPool
+		}
+		conn.ConnPool = pool
+		conn.Statement.ConnPool = pool
+	}
+
 	return conn, nil
 }
 
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -45,6 +46,15 @@ func NormalizeLikePattern(conn *gorm.DB, pattern string) string {
 	return pattern
 }
 
+// UTCMonthExpr returns a SQL expression rendering a timestamp column as its UTC month,
+// e.g. "2026-03". SQLite timestamps are stored as UTC text, so their prefix is the month.
+func UTCMonthExpr(conn *gorm.DB, column string) string {
+	if IsSQLite(conn) {
+		return fmt.Sprintf("substr(%s, 1, 7)", column)
+	}
+	return fmt.Sprintf("to_char(%s AT TIME ZONE 'UTC', 'YYYY-MM')", column)
+}
+
 // JSONExtractTextExpr returns a SQL expression to extract a JSON field as text.
 func JSONExtractTextExpr(conn *gorm.DB, column, key string) string {
 	if IsSQLite(conn) {
@@ -68,3 +78,47 @@ func JSONArrayContainsValue(conn *gorm.DB, value uint64) any {
 	}
 	return datatypes.JSON([]byte(fmt.Sprintf("[%d]", value)))
 }
+
+// JSONArrayContainsAny returns a predicate and its bind values matching rows whose JSON
+// array column holds at least one of values. PostgreSQL ORs one containment check per
+// value so a GIN index on the column stays usable; SQLite scans the array with json_each.
+// An empty values list matches nothing.
+func JSONArrayContainsAny(conn *gorm.DB, column string, values []uint64) (string, []any) {
+	if len(values) == 0 {
+		return "1 = 0", nil
+	}
+	if IsSQLite(conn) {
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE value IN ?)", column), []any{values}
+	}
+	clauses := make([]string, 0, len(values))
+	args := make([]any, 0, len(values))
+	for _, value := range values {
+		clauses = append(clauses, fmt.Sprintf("%s @> ?", column))
+		args = append(args, JSONArrayContainsValue(conn, value))
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// JSONArrayContainsAnyText is JSONArrayContainsAny for JSON arrays of strings.
+func JSONArrayContainsAnyText(conn *gorm.DB, column string, values []string) (string, []any) {
+	if len(values) == 0 {
+		return "1 = 0", nil
+	}
+	if IsSQLite(conn) {
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE value IN ?)", column), []any{values}
+	}
+	clauses := make([]string, 0, len(values))
+	args := make([]any, 0, len(values))
+	for _, value := range values {
+		encoded, errMarshal := json.Marshal([]string{value})
+		if errMarshal != nil {
+			continue
+		}
+		clauses = append(clauses, fmt.Sprintf("%s @> ?", column))
+		args = append(args, datatypes.JSON(encoded))
+	}
+	if len(clauses) == 0 {
+		return "1 = 0", nil
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestJSONArrayContainsAnySQLite(t *testing.T) {
+	conn := openTestDB(t)
+	t.Cleanup(func() { _ = Close(conn) })
+	if errMigrate := conn.AutoMigrate(&models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auths := []models.Auth{
+		{Key: "a", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{uint64Ptr(1)}},
+		{Key: "b", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{uint64Ptr(2), uint64Ptr(3)}},
+		{Key: "c", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{uint64Ptr(4)}},
+	}
+	if errCreate := conn.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	keys := func(values []uint64) []string {
+		expr, args := JSONArrayContainsAny(conn, "auth_group_id", values)
+		var out []string
+		if errFind := conn.Model(&models.Auth{}).Where(expr, args...).Order("key ASC").Pluck("key", &out).Error; errFind != nil {
+			t.Fatalf("query %v: %v", values, errFind)
+		}
+		return out
+	}
+	if got := strings.Join(keys([]uint64{1, 3}), ","); got != "a,b" {
+		t.Fatalf("expected a,b, got %s", got)
+	}
+	if got := keys([]uint64{9}); len(got) != 0 {
+		t.Fatalf("expected no match, got %v", got)
+	}
+	if got := keys(nil); len(got) != 0 {
+		t.Fatalf("expected empty list to match nothing, got %v", got)
+	}
+}
+
+func TestJSONArrayContainsAnyPostgres(t *testing.T) {
+	conn := &gorm.DB{Config: &gorm.Config{Dialector: postgres.New(postgres.Config{})}}
+	expr, args := JSONArrayContainsAny(conn, "auth_group_id", []uint64{1, 2})
+	if expr != "(auth_group_id @> ? OR auth_group_id @> ?)" {
+		t.Fatalf("unexpected expression %q", expr)
+	}
+	if len(args) != 2 || string(args[1].(datatypes.JSON)) != "[2]" {
+		t.Fatalf("unexpected args %v", args)
+	}
+}
+
+func uint64Ptr(value uint64) *uint64 { return &value }
+
+func TestJSONArrayContainsAnyText(t *testing.T) {
+	conn := openTestDB(t)
+	t.Cleanup(func() { _ = Close(conn) })
+	if errMigrate := conn.AutoMigrate(&models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auths := []models.Auth{
+		{Key: "a", Content: datatypes.JSON(`{}`), Tags: models.Tags{"team-a"}},
+		{Key: "b", Content: datatypes.JSON(`{}`), Tags: models.Tags{"team-b", "owner:bob"}},
+		{Key: "c", Content: datatypes.JSON(`{}`), Tags: models.Tags{}},
+	}
+	if errCreate := conn.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+	expr, args := JSONArrayContainsAnyText(conn, "tags", []string{"team-a", "owner:bob"})
+	var keys []string
+	if errFind := conn.Model(&models.Auth{}).Where(expr, args...).Order("key ASC").Pluck("key", &keys).Error; errFind != nil {
+		t.Fatalf("query: %v", errFind)
+	}
+	if got := strings.Join(keys, ","); got != "a,b" {
+		t.Fatalf("expected a,b, got %s", got)
+	}
+
+	pg := &gorm.DB{Config: &gorm.Config{Dialector: postgres.New(postgres.Config{})}}
+	pgExpr, pgArgs := JSONArrayContainsAnyText(pg, "tags", []string{"x", `q"t`})
+	if pgExpr != "(tags @> ? OR tags @> ?)" || len(pgArgs) != 2 || string(pgArgs[1].(datatypes.JSON)) != `["q\"t"]` {
+		t.Fatalf("unexpected postgres predicate %s %v", pgExpr, pgArgs)
+	}
+}
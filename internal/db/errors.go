@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	// postgresUniqueViolation is the SQLSTATE for unique_violation.
+	postgresUniqueViolation = "23505"
+	// sqliteConstraintPrimaryKey is the SQLite extended code SQLITE_CONSTRAINT_PRIMARYKEY.
+	sqliteConstraintPrimaryKey = 1555
+	// sqliteConstraintUnique is the SQLite extended code SQLITE_CONSTRAINT_UNIQUE.
+	sqliteConstraintUnique = 2067
+)
+
+// IsUniqueViolation reports whether err is a unique or primary key constraint violation
+// on PostgreSQL or SQLite.
+func IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == postgresUniqueViolation
+	}
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		code := coded.Code()
+		return code == sqliteConstraintUnique || code == sqliteConstraintPrimaryKey
+	}
+	return false
+}
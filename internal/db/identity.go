@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// identityIndex is a case-insensitive unique index on a login identity column.
+type identityIndex struct {
+	name   string // Index name.
+	table  string // Indexed table.
+	column string // Indexed column, compared through LOWER().
+}
+
+// identityIndexes lists the login identities that must be unique ignoring case.
+var identityIndexes = []identityIndex{
+	{name: "idx_users_username_ci", table: "users", column: "username"},
+	{name: "idx_users_email_ci", table: "users", column: "email"},
+	{name: "idx_admins_username_ci", table: "admins", column: "username"},
+	{name: "idx_admins_email_ci", table: "admins", column: "email"},
+}
+
+// ensureCaseInsensitiveIdentityIndexes creates unique LOWER() indexes on usernames and
+// emails. Empty values are left out. A column whose rows already collide ignoring case
+// keeps working without the index: the collisions are logged and the step retries on
+// the next boot, once an operator has renamed the accounts.
+func ensureCaseInsensitiveIdentityIndexes(conn *gorm.DB) error {
+	migrator := conn.Migrator()
+	for _, idx := range identityIndexes {
+		if migrator.HasIndex(idx.table, idx.name) {
+			continue
+		}
+		duplicates, errDuplicates := caseDuplicates(conn, idx.table, idx.column)
+		if errDuplicates != nil {
+			return fmt.Errorf("db: check %s.%s duplicates: %w", idx.table, idx.column, errDuplicates)
+		}
+		if len(duplicates) > 0 {
+			log.Warnf("db: skipped unique index %s: %s.%s has values differing only by case: %s", idx.name, idx.table, idx.column, strings.Join(duplicates, ", "))
+			continue
+		}
+		stmt := fmt.Sprintf(`
+			CREATE UNIQUE INDEX IF NOT EXISTS %s
+			ON %s (LOWER(%s))
+			WHERE %s <> ''
+		`, idx.name, idx.table, idx.column, idx.column)
+		var errCreate error
+		if DialectName(conn) == DialectSQLite {
+			errCreate = conn.Exec(stmt).Error
+		} else {
+			errCreate = execIndexPostgres(conn, stmt)
+		}
+		if errCreate != nil {
+			return fmt.Errorf("db: create index %s: %w", idx.name, errCreate)
+		}
+	}
+	return nil
+}
+
+// caseDuplicates returns the lowercased non-empty values of column shared by several rows.
+func caseDuplicates(conn *gorm.DB, table, column string) ([]string, error) {
+	var values []string
+	errFind := conn.Table(table).
+		Where(column+" <> ''").
+		Group("LOWER("+column+")").
+		Having("COUNT(*) > 1").
+		Order("LOWER("+column+")").
+		Pluck("LOWER("+column+")", &values).Error
+	return values, errFind
+}
+
+// IdentityTaken reports whether a row of model other than excludeID already uses value
+// in column, ignoring case. Usernames and emails are unique case-insensitively so logins
+// are never ambiguous. column must be a trusted column name.
+func IdentityTaken(ctx context.Context, conn *gorm.DB, model any, column, value string, excludeID uint64) (bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false, nil
+	}
+	q := conn.WithContext(ctx).Model(model).Where("LOWER("+column+") = ?", strings.ToLower(value))
+	if excludeID != 0 {
+		q = q.Where("id <> ?", excludeID)
+	}
+	var count int64
+	if errCount := q.Count(&count).Error; errCount != nil {
+		return false, errCount
+	}
+	return count > 0, nil
+}
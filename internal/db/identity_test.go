@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestIdentityIndexesSkipColumnsWithCaseDuplicates(t *testing.T) {
+	conn := openTestDB(t)
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	if errDrop := conn.Exec(`DROP INDEX idx_users_username_ci`).Error; errDrop != nil {
+		t.Fatalf("drop index: %v", errDrop)
+	}
+	for _, user := range []models.User{
+		{Username: "Alice", Email: "alice@example.com", Password: "x"},
+		{Username: "alice", Email: "other@example.com", Password: "x"},
+	} {
+		if errCreate := conn.Create(&user).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+	}
+
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("expected duplicates to be reported, not fail the migration: %v", errMigrate)
+	}
+	if conn.Migrator().HasIndex("users", "idx_users_username_ci") {
+		t.Fatal("expected the username index to wait for the duplicates to be resolved")
+	}
+	if !conn.Migrator().HasIndex("users", "idx_users_email_ci") {
+		t.Fatal("expected the email index to stay in place")
+	}
+	if errCreate := conn.Create(&models.User{Username: "bob", Email: "ALICE@example.com", Password: "x"}).Error; errCreate == nil || !IsUniqueViolation(errCreate) {
+		t.Fatalf("expected a case-insensitive email collision to be rejected, got %v", errCreate)
+	}
+
+	if errRename := conn.Model(&models.User{}).Where("username = ?", "alice").Update("username", "alice2").Error; errRename != nil {
+		t.Fatalf("rename user: %v", errRename)
+	}
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate after rename: %v", errMigrate)
+	}
+	if !conn.Migrator().HasIndex("users", "idx_users_username_ci") {
+		t.Fatal("expected the username index once duplicates are gone")
+	}
+
+	taken, errTaken := IdentityTaken(context.Background(), conn, &models.User{}, "username", " ALICE ", 0)
+	if errTaken != nil || !taken {
+		t.Fatalf("expected ALICE to be taken, got %v (%v)", taken, errTaken)
+	}
+	var alice models.User
+	if errFind := conn.Where("username = ?", "Alice").First(&alice).Error; errFind != nil {
+		t.Fatalf("find alice: %v", errFind)
+	}
+	if taken, _ := IdentityTaken(context.Background(), conn, &models.User{}, "username", "alice", alice.ID); taken {
+		t.Fatal("expected the excluded row not to count")
+	}
+}
@@ -20,35 +20,17 @@ func Migrate(conn *gorm.DB) error {
 	}
 	switch DialectName(conn) {
 	case DialectSQLite:
-		return migrateSQLite(conn)
+		return applyMigrations(conn, sqliteMigrations, hasLegacySchema)
 	case DialectPostgres, "":
-		return migratePostgres(conn)
+		return applyMigrations(conn, postgresMigrations, hasLegacySchema)
 	default:
 		return fmt.Errorf("db: unsupported dialect: %s", DialectName(conn))
 	}
 }
 
-// migratePostgres applies PostgreSQL-specific schema updates and indexes.
-func migratePostgres(conn *gorm.DB) error {
-	if errRename := conn.Exec(`
-		DO $$
-		BEGIN
-			IF to_regclass('public.recharge_cards') IS NOT NULL AND to_regclass('public.prepaid_cards') IS NULL THEN
-				ALTER TABLE recharge_cards RENAME TO prepaid_cards;
-			END IF;
-		END $$;
-	`).Error; errRename != nil {
-		return fmt.Errorf("db: rename recharge_cards: %w", errRename)
-	}
-
-	if errPreAuthGroup := preMigrateAuthGroupIDsPostgres(conn); errPreAuthGroup != nil {
-		return errPreAuthGroup
-	}
-	if errPreUserGroup := preMigrateUserGroupIDsPostgres(conn); errPreUserGroup != nil {
-		return errPreUserGroup
-	}
-
-	if errAutoMigrate := conn.AutoMigrate(
+// migratedModels lists the models whose tables are managed by AutoMigrate.
+func migratedModels() []any {
+	return []any{
 		&models.Admin{},
 		&models.Plan{},
 		&models.UserGroup{},
@@ -56,8 +38,11 @@ func migratePostgres(conn *gorm.DB) error {
 		&models.User{},
 		&models.Auth{},
 		&models.Quota{},
+		&models.QuotaHistory{},
 		&models.APIKey{},
 		&models.Usage{},
+		&models.UsageDailyRollup{},
+		&models.UsageRollupDay{},
 		&models.Bill{},
 		&models.BillingRule{},
 		&models.ModelMapping{},
@@ -68,21 +53,30 @@ func migratePostgres(conn *gorm.DB) error {
 		&models.Proxy{},
 		&models.PrepaidCard{},
 		&models.Setting{},
-	); errAutoMigrate != nil {
-		return fmt.Errorf("db: migrate: %w", errAutoMigrate)
+		&models.EmailVerificationToken{},
+		&models.InviteCode{},
+		&models.AuditLog{},
+		&models.RateLimitCounter{},
+		&models.BalanceAdjustment{},
+		&models.AdminSession{},
+		&models.UsageAlert{},
+		&models.Job{},
+		&models.PendingAction{},
 	}
-	if errUsageErrorStatus := conn.Exec(`
-		ALTER TABLE usages
-		ADD COLUMN IF NOT EXISTS error_status_code integer
-	`).Error; errUsageErrorStatus != nil {
-		return fmt.Errorf("db: add usage error status: %w", errUsageErrorStatus)
-	}
-	if errUsageErrorDetail := conn.Exec(`
-		ALTER TABLE usages
-		ADD COLUMN IF NOT EXISTS error_detail jsonb
-	`).Error; errUsageErrorDetail != nil {
-		return fmt.Errorf("db: add usage error detail: %w", errUsageErrorDetail)
+}
+
+// autoMigrateModels creates and updates tables for every model. It runs on every boot
+// because GORM derives the schema from the model structs.
+func autoMigrateModels(conn *gorm.DB) error {
+	if errAutoMigrate := conn.AutoMigrate(migratedModels()...); errAutoMigrate != nil {
+		return fmt.Errorf("db: migrate: %w", errAutoMigrate)
 	}
+	return nil
+}
+
+// ensureSeedSettings seeds default groups and settings. It runs on every boot so that
+// deleted defaults are restored and new settings get their initial value.
+func ensureSeedSettings(conn *gorm.DB) error {
 	if errSeed := ensureDefaultGroups(conn); errSeed != nil {
 		return errSeed
 	}
@@ -98,13 +92,109 @@ func migratePostgres(conn *gorm.DB) error {
 	if errSeed := ensureRateLimitSetting(conn); errSeed != nil {
 		return errSeed
 	}
+	if errSeed := ensureRegistrationSettings(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureImpersonationSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureUsageRetentionSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureOIDCSettings(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureAPIKeySettings(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureAuthPriorityDecaySettings(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureWebUISetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureUsageAlertSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureWatcherDispatchSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureProviderKeyHealthSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureModelListCacheSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureJobRetentionSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureAuthTrashRetentionSetting(conn); errSeed != nil {
+		return errSeed
+	}
+	if errSeed := ensureBackupSettings(conn); errSeed != nil {
+		return errSeed
+	}
+	return nil
+}
+
+// migratePostgresLegacyTables renames legacy tables and converts group ID columns before the models are migrated.
+func migratePostgresLegacyTables(conn *gorm.DB) error {
+	if errRename := conn.Exec(`
+		DO $$
+		BEGIN
+			IF to_regclass('public.recharge_cards') IS NOT NULL AND to_regclass('public.prepaid_cards') IS NULL THEN
+				ALTER TABLE recharge_cards RENAME TO prepaid_cards;
+			END IF;
+		END $$;
+	`).Error; errRename != nil {
+		return fmt.Errorf("db: rename recharge_cards: %w", errRename)
+	}
+
+	if errPreAuthGroup := preMigrateAuthGroupIDsPostgres(conn); errPreAuthGroup != nil {
+		return errPreAuthGroup
+	}
+	if errPreUserGroup := preMigrateUserGroupIDsPostgres(conn); errPreUserGroup != nil {
+		return errPreUserGroup
+	}
+	return nil
+}
+
+// migratePostgresUsageColumns adds usage error and stream columns.
+func migratePostgresUsageColumns(conn *gorm.DB) error {
+	if errUsageErrorStatus := conn.Exec(`
+		ALTER TABLE usages
+		ADD COLUMN IF NOT EXISTS error_status_code integer
+	`).Error; errUsageErrorStatus != nil {
+		return fmt.Errorf("db: add usage error status: %w", errUsageErrorStatus)
+	}
+	if errUsageErrorDetail := conn.Exec(`
+		ALTER TABLE usages
+		ADD COLUMN IF NOT EXISTS error_detail jsonb
+	`).Error; errUsageErrorDetail != nil {
+		return fmt.Errorf("db: add usage error detail: %w", errUsageErrorDetail)
+	}
+	if errUsageStream := conn.Exec(`
+		ALTER TABLE usages
+		ADD COLUMN IF NOT EXISTS stream boolean NOT NULL DEFAULT false
+	`).Error; errUsageStream != nil {
+		return fmt.Errorf("db: add usage stream: %w", errUsageStream)
+	}
+	return nil
+}
+
+// migratePostgresGroupIDs converts auth and user group references to JSON arrays.
+func migratePostgresGroupIDs(conn *gorm.DB) error {
 	if errAuthGroup := migrateAuthGroupIDsPostgres(conn); errAuthGroup != nil {
 		return errAuthGroup
 	}
 	if errUserGroup := migrateUserGroupIDsPostgres(conn); errUserGroup != nil {
 		return errUserGroup
 	}
+	return nil
+}
 
+// migratePostgresPayloadRules drops retired payload rule columns and enforces one rule per mapping.
+func migratePostgresPayloadRules(conn *gorm.DB) error {
 	if errDropRuleType := conn.Exec(`
 		ALTER TABLE model_payload_rules
 		DROP COLUMN IF EXISTS rule_type
@@ -122,13 +212,17 @@ func migratePostgres(conn *gorm.DB) error {
 	`).Error; errDropPayloadPriority != nil {
 		return fmt.Errorf("db: drop payload priority: %w", errDropPayloadPriority)
 	}
-	if errPayloadUnique := conn.Exec(`
+	if errPayloadUnique := execIndexPostgres(conn, `
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_model_payload_rules_mapping
 		ON model_payload_rules (model_mapping_id)
-	`).Error; errPayloadUnique != nil {
+	`); errPayloadUnique != nil {
 		return fmt.Errorf("db: create payload mapping index: %w", errPayloadUnique)
 	}
+	return nil
+}
 
+// migratePostgresPrepaidCards adds prepaid card balance, validity and group columns with backfills.
+func migratePostgresPrepaidCards(conn *gorm.DB) error {
 	if errBalanceAdd := conn.Exec(`
 		ALTER TABLE prepaid_cards
 		ADD COLUMN IF NOT EXISTS balance decimal(20,10) NOT NULL DEFAULT 0
@@ -154,14 +248,14 @@ func migratePostgres(conn *gorm.DB) error {
 	`).Error; errExpiresAdd != nil {
 		return fmt.Errorf("db: add prepaid expires_at: %w", errExpiresAdd)
 	}
-	if errExpireIdx := conn.Exec(`
+	if errExpireIdx := execIndexPostgres(conn, `
 		CREATE INDEX IF NOT EXISTS idx_prepaid_cards_redeemed_expiry ON prepaid_cards (redeemed_user_id, expires_at)
-	`).Error; errExpireIdx != nil {
+	`); errExpireIdx != nil {
 		return fmt.Errorf("db: add prepaid expiry index: %w", errExpireIdx)
 	}
-	if errGroupIdx := conn.Exec(`
+	if errGroupIdx := execIndexPostgres(conn, `
 		CREATE INDEX IF NOT EXISTS idx_prepaid_cards_redeemed_user_group ON prepaid_cards (redeemed_user_id, user_group_id)
-	`).Error; errGroupIdx != nil {
+	`); errGroupIdx != nil {
 		return fmt.Errorf("db: add prepaid user group index: %w", errGroupIdx)
 	}
 	if errBackfill := conn.Exec(`
@@ -176,13 +270,22 @@ func migratePostgres(conn *gorm.DB) error {
 	`).Error; errBackfill != nil {
 		return fmt.Errorf("db: backfill prepaid user group: %w", errBackfill)
 	}
+	return nil
+}
+
+// migratePostgresDropUserBalance drops the retired users.balance column.
+func migratePostgresDropUserBalance(conn *gorm.DB) error {
 	if errDropUserBalance := conn.Exec(`
 		ALTER TABLE users
 		DROP COLUMN IF EXISTS balance
 	`).Error; errDropUserBalance != nil {
 		return fmt.Errorf("db: drop user balance: %w", errDropUserBalance)
 	}
+	return nil
+}
 
+// migratePostgresAdminRoles adds admin permissions and the super admin flag.
+func migratePostgresAdminRoles(conn *gorm.DB) error {
 	if errAdminPermAdd := conn.Exec(`
 		ALTER TABLE admins
 		ADD COLUMN IF NOT EXISTS permissions jsonb DEFAULT '[]'::jsonb
@@ -244,7 +347,11 @@ func migratePostgres(conn *gorm.DB) error {
 	`).Error; errAdminSuperSeed != nil {
 		return fmt.Errorf("db: seed admin super flag: %w", errAdminSuperSeed)
 	}
+	return nil
+}
 
+// migratePostgresMFAColumns adds TOTP and passkey columns for admins and users plus models.model_id.
+func migratePostgresMFAColumns(conn *gorm.DB) error {
 	if errAdminTotpAdd := conn.Exec(`
 		ALTER TABLE admins
 		ADD COLUMN IF NOT EXISTS totp_secret text
@@ -324,9 +431,11 @@ func migratePostgres(conn *gorm.DB) error {
 	`).Error; errModelIDAdd != nil {
 		return fmt.Errorf("db: add models model_id: %w", errModelIDAdd)
 	}
+	return nil
+}
 
-	_ = conn.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error
-
+// migratePostgresIndexes creates lookup indexes.
+func migratePostgresIndexes(conn *gorm.DB) error {
 	// ddl defines an index or DDL statement to apply.
 	type ddl struct {
 		name string // Human-readable name for error reporting.
@@ -614,10 +723,16 @@ func migratePostgres(conn *gorm.DB) error {
 		},
 	}
 	for _, item := range ddls {
-		if errDDL := conn.Exec(item.sql).Error; errDDL != nil {
+		if errDDL := execIndexPostgres(conn, item.sql); errDDL != nil {
 			return fmt.Errorf("db: create index %s: %w", item.name, errDDL)
 		}
 	}
+	return nil
+}
+
+// migratePostgresSearchIndexes creates trigram search indexes, falling back to lowercase indexes without pg_trgm.
+func migratePostgresSearchIndexes(conn *gorm.DB) error {
+	_ = conn.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error
 
 	// trgmIndex defines trigram and fallback index statements.
 	type trgmIndex struct {
@@ -815,18 +930,60 @@ func migratePostgres(conn *gorm.DB) error {
 		},
 	}
 	for _, item := range trgmIndexes {
-		if errIdx := conn.Exec(item.trgmSQL).Error; errIdx != nil {
-			if errLower := conn.Exec(item.lowerSQL).Error; errLower != nil {
+		if errIdx := execIndexPostgres(conn, item.trgmSQL); errIdx != nil {
+			if errLower := execIndexPostgres(conn, item.lowerSQL); errLower != nil {
 				return fmt.Errorf("db: create index %s: %w", item.name, errLower)
 			}
 		}
 	}
+	return nil
+}
 
+// migratePostgresAuthLookupIndexes creates the auth file email and per-auth usage indexes.
+func migratePostgresAuthLookupIndexes(conn *gorm.DB) error {
+	// ddl defines an index or DDL statement to apply.
+	type ddl struct {
+		name string // Human-readable name for error reporting.
+		sql  string // SQL to execute.
+	}
+	ddls := []ddl{
+		{
+			name: "idx_auths_content_email",
+			sql: `
+				CREATE INDEX IF NOT EXISTS idx_auths_content_email
+				ON auths ((LOWER(content->>'email')))
+			`,
+		},
+		{
+			name: "idx_usages_auth_id_requested_at",
+			sql: `
+				CREATE INDEX IF NOT EXISTS idx_usages_auth_id_requested_at
+				ON usages (auth_id, requested_at DESC)
+			`,
+		},
+	}
+	for _, item := range ddls {
+		if errDDL := execIndexPostgres(conn, item.sql); errDDL != nil {
+			return fmt.Errorf("db: create index %s: %w", item.name, errDDL)
+		}
+	}
 	return nil
 }
 
-// migrateSQLite applies SQLite-specific schema updates and indexes.
-func migrateSQLite(conn *gorm.DB) error {
+// migratePostgresAuthStatsIndexes creates the index read by the per-auth usage statistics.
+// Including duration_ms lets latency aggregates be answered from the index.
+func migratePostgresAuthStatsIndexes(conn *gorm.DB) error {
+	if errDDL := execIndexPostgres(conn, `
+		CREATE INDEX IF NOT EXISTS idx_usages_auth_id_requested_at_duration
+		ON usages (auth_id, requested_at, duration_ms)
+	`); errDDL != nil {
+		return fmt.Errorf("db: create index idx_usages_auth_id_requested_at_duration: %w", errDDL)
+	}
+	return nil
+}
+
+// migrateSQLiteLegacyTables fixes legacy timestamp column types and renames legacy tables.
+func migrateSQLiteLegacyTables(conn *gorm.DB) error {
 	if errFix := fixSQLiteTimestampColumns(conn); errFix != nil {
 		return errFix
 	}
@@ -834,30 +991,11 @@ func migrateSQLite(conn *gorm.DB) error {
 	if errRename := renameTableIfNeeded(conn, "recharge_cards", "prepaid_cards"); errRename != nil {
 		return fmt.Errorf("db: rename recharge_cards: %w", errRename)
 	}
+	return nil
+}
 
-	if errAutoMigrate := conn.AutoMigrate(
-		&models.Admin{},
-		&models.Plan{},
-		&models.UserGroup{},
-		&models.AuthGroup{},
-		&models.User{},
-		&models.Auth{},
-		&models.Quota{},
-		&models.APIKey{},
-		&models.Usage{},
-		&models.Bill{},
-		&models.BillingRule{},
-		&models.ModelMapping{},
-		&models.ModelReference{},
-		&models.UserModelAuthBinding{},
-		&models.ModelPayloadRule{},
-		&models.ProviderAPIKey{},
-		&models.Proxy{},
-		&models.PrepaidCard{},
-		&models.Setting{},
-	); errAutoMigrate != nil {
-		return fmt.Errorf("db: migrate: %w", errAutoMigrate)
-	}
+// migrateSQLiteUsageColumns adds usage error and stream columns plus models.model_id.
+func migrateSQLiteUsageColumns(conn *gorm.DB) error {
 	migrator := conn.Migrator()
 	if migrator != nil && migrator.HasTable(&models.ModelReference{}) && !migrator.HasColumn(&models.ModelReference{}, "model_id") {
 		if errModelIDAdd := conn.Exec(`
@@ -883,28 +1021,30 @@ func migrateSQLite(conn *gorm.DB) error {
 			return fmt.Errorf("db: add usage error detail: %w", errUsageErrorDetail)
 		}
 	}
-	if errSeed := ensureDefaultGroups(conn); errSeed != nil {
-		return errSeed
-	}
-	if errSeed := ensureOnlyMappedModelsSetting(conn); errSeed != nil {
-		return errSeed
-	}
-	if errSeed := ensureQuotaPollSettings(conn); errSeed != nil {
-		return errSeed
-	}
-	if errSeed := ensureAutoAssignProxySetting(conn); errSeed != nil {
-		return errSeed
-	}
-	if errSeed := ensureRateLimitSetting(conn); errSeed != nil {
-		return errSeed
+	if migrator != nil && !migrator.HasColumn(&models.Usage{}, "stream") {
+		if errUsageStream := conn.Exec(`
+			ALTER TABLE usages
+			ADD COLUMN stream numeric NOT NULL DEFAULT false
+		`).Error; errUsageStream != nil {
+			return fmt.Errorf("db: add usage stream: %w", errUsageStream)
+		}
 	}
+	return nil
+}
+
+// migrateSQLiteGroupIDs converts auth and user group references to JSON arrays.
+func migrateSQLiteGroupIDs(conn *gorm.DB) error {
 	if errAuthGroup := migrateAuthGroupIDsSQLite(conn); errAuthGroup != nil {
 		return errAuthGroup
 	}
 	if errUserGroup := migrateUserGroupIDsSQLite(conn); errUserGroup != nil {
 		return errUserGroup
 	}
+	return nil
+}
 
+// migrateSQLitePayloadRules drops the retired payload rule index and enforces one rule per mapping.
+func migrateSQLitePayloadRules(conn *gorm.DB) error {
 	if errDropPayloadIndex := conn.Exec(`
 		DROP INDEX IF EXISTS idx_model_payload_rules_enabled
 	`).Error; errDropPayloadIndex != nil {
@@ -916,7 +1056,11 @@ func migrateSQLite(conn *gorm.DB) error {
 	`).Error; errPayloadUnique != nil {
 		return fmt.Errorf("db: create payload mapping index: %w", errPayloadUnique)
 	}
+	return nil
+}
 
+// migrateSQLitePrepaidCards backfills prepaid card balances and groups.
+func migrateSQLitePrepaidCards(conn *gorm.DB) error {
 	if errBalanceBackfill := conn.Exec(`
 		UPDATE prepaid_cards
 		SET balance = amount
@@ -941,7 +1085,11 @@ func migrateSQLite(conn *gorm.DB) error {
 	`).Error; errBackfill != nil {
 		return fmt.Errorf("db: backfill prepaid user group: %w", errBackfill)
 	}
+	return nil
+}
 
+// migrateSQLiteAdminRoles backfills admin permissions and the super admin flag.
+func migrateSQLiteAdminRoles(conn *gorm.DB) error {
 	if errAdminPermUpdate := conn.Exec(`
 		UPDATE admins
 		SET permissions = '[]'
@@ -966,7 +1114,11 @@ func migrateSQLite(conn *gorm.DB) error {
 	`).Error; errAdminSuperSeed != nil {
 		return fmt.Errorf("db: seed admin super flag: %w", errAdminSuperSeed)
 	}
+	return nil
+}
 
+// migrateSQLiteIndexes creates lookup indexes.
+func migrateSQLiteIndexes(conn *gorm.DB) error {
 	// ddl defines an index or DDL statement to apply.
 	type ddl struct {
 		name string // Human-readable name for error reporting.
@@ -1174,7 +1326,49 @@ func migrateSQLite(conn *gorm.DB) error {
 			return fmt.Errorf("db: create index %s: %w", item.name, errDDL)
 		}
 	}
+	return nil
+}
 
+// migrateSQLiteAuthLookupIndexes creates the auth file email and per-auth usage indexes.
+func migrateSQLiteAuthLookupIndexes(conn *gorm.DB) error {
+	// ddl defines an index or DDL statement to apply.
+	type ddl struct {
+		name string // Human-readable name for error reporting.
+		sql  string // SQL to execute.
+	}
+	ddls := []ddl{
+		{
+			name: "idx_auths_content_email",
+			sql: `
+				CREATE INDEX IF NOT EXISTS idx_auths_content_email
+				ON auths (LOWER(json_extract(content, '$.email')))
+			`,
+		},
+		{
+			name: "idx_usages_auth_id_requested_at",
+			sql: `
+				CREATE INDEX IF NOT EXISTS idx_usages_auth_id_requested_at
+				ON usages (auth_id, requested_at DESC)
+			`,
+		},
+	}
+	for _, item := range ddls {
+		if errDDL := conn.Exec(item.sql).Error; errDDL != nil {
+			return fmt.Errorf("db: create index %s: %w", item.name, errDDL)
+		}
+	}
+	return nil
+}
+
+// migrateSQLiteAuthStatsIndexes creates the index read by the per-auth usage statistics.
+// Including duration_ms lets latency aggregates be answered from the index.
+func migrateSQLiteAuthStatsIndexes(conn *gorm.DB) error {
+	if errDDL := conn.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_usages_auth_id_requested_at_duration
+		ON usages (auth_id, requested_at, duration_ms)
+	`).Error; errDDL != nil {
+		return fmt.Errorf("db: create index idx_usages_auth_id_requested_at_duration: %w", errDDL)
+	}
 	return nil
 }
 
@@ -1525,7 +1719,7 @@ func migrateUserGroupIDsPostgres(conn *gorm.DB) error {
 		},
 	}
 	for _, item := range ddls {
-		if errDDL := conn.Exec(item.sql).Error; errDDL != nil {
+		if errDDL := execIndexPostgres(conn, item.sql); errDDL != nil {
 			return fmt.Errorf("db: create index %s: %w", item.name, errDDL)
 		}
 	}
@@ -1671,9 +1865,68 @@ func ensureQuotaPollSettings(conn *gorm.DB) error {
 	); errEnsure != nil {
 		return errEnsure
 	}
+	if errEnsure := ensureIntSetting(
+		conn,
+		internalsettings.QuotaHistoryIntervalSecondsKey,
+		internalsettings.DefaultQuotaHistoryIntervalSeconds,
+	); errEnsure != nil {
+		return errEnsure
+	}
+	if errEnsure := ensureIntSetting(
+		conn,
+		internalsettings.QuotaHistoryRetentionDaysKey,
+		internalsettings.DefaultQuotaHistoryRetentionDays,
+	); errEnsure != nil {
+		return errEnsure
+	}
 	return nil
 }
 
+// ensureWatcherDispatchSetting ensures WATCHER_DISPATCH_MAX_PENDING exists with defaults.
+func ensureWatcherDispatchSetting(conn *gorm.DB) error {
+	return ensureIntSetting(
+		conn,
+		internalsettings.WatcherDispatchMaxPendingKey,
+		internalsettings.DefaultWatcherDispatchMaxPending,
+	)
+}
+
+// ensureProviderKeyHealthSetting ensures PROVIDER_KEY_AUTH_FAILURE_THRESHOLD exists with defaults.
+func ensureProviderKeyHealthSetting(conn *gorm.DB) error {
+	return ensureIntSetting(
+		conn,
+		internalsettings.ProviderKeyAuthFailureThresholdKey,
+		internalsettings.DefaultProviderKeyAuthFailureThreshold,
+	)
+}
+
+// ensureModelListCacheSetting ensures MODEL_LIST_CACHE_TTL_SECONDS exists with defaults.
+func ensureModelListCacheSetting(conn *gorm.DB) error {
+	return ensureIntSetting(
+		conn,
+		internalsettings.ModelListCacheTTLSecondsKey,
+		internalsettings.DefaultModelListCacheTTLSeconds,
+	)
+}
+
+// ensureJobRetentionSetting ensures JOB_RETENTION_DAYS exists with defaults.
+func ensureJobRetentionSetting(conn *gorm.DB) error {
+	return ensureIntSetting(conn, internalsettings.JobRetentionDaysKey, internalsettings.DefaultJobRetentionDays)
+}
+
+// ensureAuthTrashRetentionSetting ensures AUTH_TRASH_RETENTION_DAYS exists with defaults.
+func ensureAuthTrashRetentionSetting(conn *gorm.DB) error {
+	return ensureIntSetting(conn, internalsettings.AuthTrashRetentionDaysKey, internalsettings.DefaultAuthTrashRetentionDays)
+}
+
+// ensureBackupSettings ensures BACKUP_INTERVAL_HOURS and BACKUP_RETENTION_COUNT exist with defaults.
+func ensureBackupSettings(conn *gorm.DB) error {
+	if errInterval := ensureIntSetting(conn, internalsettings.BackupIntervalHoursKey, internalsettings.DefaultBackupIntervalHours); errInterval != nil {
+		return errInterval
+	}
+	return ensureIntSetting(conn, internalsettings.BackupRetentionCountKey, internalsettings.DefaultBackupRetentionCount)
+}
+
 // ensureAutoAssignProxySetting ensures AUTO_ASSIGN_PROXY exists with defaults.
 func ensureAutoAssignProxySetting(conn *gorm.DB) error {
 	return ensureBoolSetting(
@@ -1688,6 +1941,147 @@ func ensureRateLimitSetting(conn *gorm.DB) error {
 	return ensureIntSetting(conn, internalsettings.RateLimitKey, internalsettings.DefaultRateLimit)
 }
 
+// ensureRegistrationSettings ensures registration toggles exist with defaults.
+func ensureRegistrationSettings(conn *gorm.DB) error {
+	if errEnsure := ensureBoolSetting(
+		conn,
+		internalsettings.AllowRegistrationKey,
+		internalsettings.DefaultAllowRegistration,
+	); errEnsure != nil {
+		return errEnsure
+	}
+	return ensureBoolSetting(
+		conn,
+		internalsettings.RegistrationRequireInviteKey,
+		internalsettings.DefaultRegistrationRequireInvite,
+	)
+}
+
+// ensureImpersonationSetting ensures IMPERSONATION_TOKEN_TTL_SECONDS exists with defaults.
+func ensureImpersonationSetting(conn *gorm.DB) error {
+	return ensureIntSetting(
+		conn,
+		internalsettings.ImpersonationTokenTTLSecondsKey,
+		internalsettings.DefaultImpersonationTokenTTLSeconds,
+	)
+}
+
+// ensureUsageRetentionSetting ensures USAGE_RETENTION_DAYS exists with defaults.
+func ensureUsageRetentionSetting(conn *gorm.DB) error {
+	return ensureIntSetting(conn, internalsettings.UsageRetentionDaysKey, internalsettings.DefaultUsageRetentionDays)
+}
+
+// ensureUsageAlertSetting ensures USAGE_ALERT_EMAIL_ENABLED exists with defaults.
+func ensureUsageAlertSetting(conn *gorm.DB) error {
+	return ensureBoolSetting(conn, internalsettings.UsageAlertEmailEnabledKey, internalsettings.DefaultUsageAlertEmailEnabled)
+}
+
+// ensureAPIKeySettings ensures the API key idle-revoke and per-user limit settings exist with defaults.
+func ensureAPIKeySettings(conn *gorm.DB) error {
+	if errEnsure := ensureIntSetting(conn, internalsettings.APIKeyIdleRevokeDaysKey, internalsettings.DefaultAPIKeyIdleRevokeDays); errEnsure != nil {
+		return errEnsure
+	}
+	return ensureIntSetting(conn, internalsettings.APIKeyMaxPerUserKey, internalsettings.DefaultAPIKeyMaxPerUser)
+}
+
+// ensureAuthPriorityDecaySettings ensures the auth priority decay tuning exists with defaults.
+func ensureAuthPriorityDecaySettings(conn *gorm.DB) error {
+	defaults := []struct {
+		key   string
+		value int
+	}{
+		{internalsettings.AuthPriorityDecayThresholdKey, internalsettings.DefaultAuthPriorityDecayThreshold},
+		{internalsettings.AuthPriorityDecayWindowSecondsKey, internalsettings.DefaultAuthPriorityDecayWindowSeconds},
+		{internalsettings.AuthPriorityDecayStepKey, internalsettings.DefaultAuthPriorityDecayStep},
+		{internalsettings.AuthPriorityRestoreSecondsKey, internalsettings.DefaultAuthPriorityRestoreSeconds},
+	}
+	for _, item := range defaults {
+		if errEnsure := ensureIntSetting(conn, item.key, item.value); errEnsure != nil {
+			return errEnsure
+		}
+	}
+	return nil
+}
+
+// ensureOIDCSettings ensures the SSO toggles exist with defaults.
+func ensureOIDCSettings(conn *gorm.DB) error {
+	if errEnsure := ensureBoolSetting(conn, internalsettings.OIDCEnabledKey, internalsettings.DefaultOIDCEnabled); errEnsure != nil {
+		return errEnsure
+	}
+	if errEnsure := ensureBoolSetting(conn, internalsettings.OIDCAutoProvisionKey, internalsettings.DefaultOIDCAutoProvision); errEnsure != nil {
+		return errEnsure
+	}
+	return ensureBoolSetting(conn, internalsettings.OIDCTrustMissingEmailVerifiedKey, internalsettings.DefaultOIDCTrustMissingEmailVerified)
+}
+
+// ensureWebUISetting ensures WEB_UI_ENABLED exists with defaults.
+func ensureWebUISetting(conn *gorm.DB) error {
+	return ensureBoolSetting(conn, internalsettings.WebUIEnabledKey, internalsettings.DefaultWebUIEnabled)
+}
+
+// backfillUsageRollupDays marks days rolled up before usage_rollup_days existed. Their raw
+// usage rows were already purged by retention, so every current usage ID counts as covered.
+func backfillUsageRollupDays(conn *gorm.DB) error {
+	var days []time.Time
+	if errFind := conn.Model(&models.UsageDailyRollup{}).
+		Distinct("day").
+		Where("day NOT IN (?)", conn.Model(&models.UsageRollupDay{}).Select("day")).
+		Pluck("day", &days).Error; errFind != nil {
+		return fmt.Errorf("db: query unmarked usage rollup days: %w", errFind)
+	}
+	if len(days) == 0 {
+		return nil
+	}
+	var maxID uint64
+	if errMax := conn.Model(&models.Usage{}).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; errMax != nil {
+		return fmt.Errorf("db: query max usage id: %w", errMax)
+	}
+	marks := make([]models.UsageRollupDay, 0, len(days))
+	for _, day := range days {
+		marks = append(marks, models.UsageRollupDay{Day: day.UTC(), MaxUsageID: maxID})
+	}
+	if errCreate := conn.Create(&marks).Error; errCreate != nil {
+		return fmt.Errorf("db: backfill usage rollup days: %w", errCreate)
+	}
+	return nil
+}
+
+// backfillAuthFingerprints computes the fingerprint of auths stored before the column existed.
+func backfillAuthFingerprints(conn *gorm.DB) error {
+	var rows []models.Auth
+	errBatches := conn.Model(&models.Auth{}).
+		Select("id", "content").
+		Where("fingerprint = ''").
+		FindInBatches(&rows, 500, func(tx *gorm.DB, batch int) error {
+			for _, row := range rows {
+				fingerprint := models.AuthFingerprint(row.Content)
+				if fingerprint == "" {
+					continue
+				}
+				if errUpdate := conn.Model(&models.Auth{}).
+					Where("id = ?", row.ID).
+					UpdateColumn("fingerprint", fingerprint).Error; errUpdate != nil {
+					return errUpdate
+				}
+			}
+			return nil
+		}).Error
+	if errBatches != nil {
+		return fmt.Errorf("db: backfill auth fingerprints: %w", errBatches)
+	}
+	return nil
+}
+
+// dropLegacyAuthKeyIndex drops the table-wide unique index on auths.key. AutoMigrate
+// replaces it with idx_auths_key_live, which only covers entries outside the trash so a
+// trashed key can be reused.
+func dropLegacyAuthKeyIndex(conn *gorm.DB) error {
+	if errDrop := conn.Exec(`DROP INDEX IF EXISTS idx_auths_key`).Error; errDrop != nil {
+		return fmt.Errorf("db: drop index idx_auths_key: %w", errDrop)
+	}
+	return nil
+}
+
 // ensureIntSetting ensures an integer setting exists and defaults when empty.
 func ensureIntSetting(conn *gorm.DB, key string, value int) error {
 	payload, errMarshal := json.Marshal(value)
@@ -1867,6 +2261,10 @@ func fixSQLiteTimestampColumns(conn *gorm.DB) error {
 		&models.ProviderAPIKey{},
 		&models.PrepaidCard{},
 		&models.Setting{},
+		&models.EmailVerificationToken{},
+		&models.InviteCode{},
+		&models.AuditLog{},
+		&models.BalanceAdjustment{},
 	}
 
 	for _, model := range modelsToCheck {
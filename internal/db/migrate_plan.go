@@ -0,0 +1,344 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Migration modes selected with -migrate or MIGRATE_MODE.
+const (
+	// MigrateModeAuto applies migrations at startup (the default).
+	MigrateModeAuto = "auto"
+	// MigrateModePlan prints the statements a migration would execute and exits.
+	MigrateModePlan = "plan"
+	// MigrateModeSkip leaves the schema untouched for operators who manage it out-of-band.
+	MigrateModeSkip = "skip"
+)
+
+// ParseMigrateMode normalizes a migration mode; empty input selects MigrateModeAuto.
+func ParseMigrateMode(raw string) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(raw))
+	switch mode {
+	case "":
+		return MigrateModeAuto, nil
+	case MigrateModeAuto, MigrateModePlan, MigrateModeSkip:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("db: invalid migrate mode %q (want auto, plan or skip)", raw)
+	}
+}
+
+// Plan statement kinds.
+const (
+	// PlanKindSchema marks table and column changes.
+	PlanKindSchema = "schema"
+	// PlanKindIndex marks index creation and removal.
+	PlanKindIndex = "index"
+	// PlanKindData marks seeds and backfills; updates may end up matching no rows.
+	PlanKindData = "data"
+)
+
+// PlanStatement is one statement a migration would execute.
+type PlanStatement struct {
+	Kind string // Statement kind.
+	SQL  string // Statement with whitespace collapsed.
+}
+
+// PlanMigrate runs Migrate against a recording connection and returns the statements
+// that would modify the database. Reads still reach the database so schema inspection
+// reflects the live schema; writes are recorded instead of executed. Idempotent DDL
+// (IF NOT EXISTS / IF EXISTS) that would be a no-op is dropped from the plan.
+//
+// On a database that is missing whole tables, later steps may fail to read tables that
+// the plan would have created; the statements collected so far are returned with the error.
+func PlanMigrate(conn *gorm.DB) ([]PlanStatement, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("db: nil connection")
+	}
+	recorder := &planRecorder{inner: conn.Statement.ConnPool}
+	// A context forces Session to clone the statement, so conn keeps its own pool.
+	planConn := conn.Session(&gorm.Session{
+		NewDB:   true,
+		Context: context.Background(),
+		Logger:  conn.Logger.LogMode(logger.Silent),
+	})
+	planConn.ConnPool = recorder
+	planConn.Statement.ConnPool = recorder
+
+	errMigrate := Migrate(planConn)
+
+	inspect := conn.Session(&gorm.Session{NewDB: true, Logger: conn.Logger.LogMode(logger.Silent)})
+	out := make([]PlanStatement, 0, len(recorder.statements))
+	seen := make(map[string]struct{}, len(recorder.statements))
+	for _, stmt := range recorder.statements {
+		if _, dup := seen[stmt]; dup {
+			continue
+		}
+		seen[stmt] = struct{}{}
+		pending, errCheck := statementPending(inspect, stmt)
+		if errCheck != nil {
+			return out, errCheck
+		}
+		if pending {
+			out = append(out, PlanStatement{Kind: planKind(stmt), SQL: stmt})
+		}
+	}
+	if errMigrate != nil {
+		return out, fmt.Errorf("db: plan incomplete: %w", errMigrate)
+	}
+	return out, nil
+}
+
+// planRecorder is a gorm connection pool that forwards reads and records writes.
+type planRecorder struct {
+	inner      gorm.ConnPool
+	mu         sync.Mutex
+	statements []string
+}
+
+// record stores a write statement with its arguments inlined for display.
+func (r *planRecorder) record(query string, args []any) {
+	stmt := collapseSQL(query)
+	if len(args) > 0 {
+		stmt = logger.ExplainSQL(stmt, nil, `'`, args...)
+	}
+	r.mu.Lock()
+	r.statements = append(r.statements, stmt)
+	r.mu.Unlock()
+}
+
+// hasStatements reports whether any write has been recorded.
+func (r *planRecorder) hasStatements() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.statements) > 0
+}
+
+// PrepareContext forwards statement preparation.
+func (r *planRecorder) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.inner.PrepareContext(ctx, query)
+}
+
+// ExecContext records query when it writes and forwards it otherwise.
+func (r *planRecorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if isReadOnlySQL(query) {
+		return r.inner.ExecContext(ctx, query, args...)
+	}
+	r.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+// QueryContext records query when it writes (e.g. INSERT ... RETURNING) and returns no rows.
+// Reads of tables or columns that earlier recorded statements would have created also
+// return no rows, so planning can continue past them on a fresh database.
+func (r *planRecorder) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if isReadOnlySQL(query) {
+		rows, errQuery := r.inner.QueryContext(ctx, query, args...)
+		if errQuery != nil && r.hasStatements() && isUndefinedObjectError(errQuery) {
+			return r.inner.QueryContext(ctx, "SELECT 1 WHERE 1 = 0")
+		}
+		return rows, errQuery
+	}
+	r.record(query, args)
+	return r.inner.QueryContext(ctx, "SELECT 1 WHERE 1 = 0")
+}
+
+// QueryRowContext records query when it writes and returns an empty row.
+func (r *planRecorder) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if isReadOnlySQL(query) {
+		return r.inner.QueryRowContext(ctx, query, args...)
+	}
+	r.record(query, args)
+	return r.inner.QueryRowContext(ctx, "SELECT 1 WHERE 1 = 0")
+}
+
+// BeginTx lets migrations open transactions; statements keep flowing through the recorder.
+func (r *planRecorder) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return &planTx{r}, nil
+}
+
+// planTx is a no-op transaction over the recorder.
+type planTx struct {
+	*planRecorder
+}
+
+// Commit implements gorm.TxCommitter.
+func (*planTx) Commit() error { return nil }
+
+// Rollback implements gorm.TxCommitter.
+func (*planTx) Rollback() error { return nil }
+
+// isReadOnlySQL reports whether query only reads, judged by its leading keyword.
+func isReadOnlySQL(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return true
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "SHOW", "EXPLAIN", "WITH":
+		return true
+	case "PRAGMA":
+		return !strings.Contains(query, "=")
+	default:
+		return false
+	}
+}
+
+// isUndefinedObjectError reports whether err is a missing table or column error.
+func isUndefinedObjectError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "SQLSTATE 42P01") || strings.Contains(msg, "SQLSTATE 42703") ||
+		strings.Contains(msg, "no such table") || strings.Contains(msg, "no such column")
+}
+
+// collapseSQL joins the lines of a statement into a single line.
+func collapseSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// planKind classifies a recorded statement.
+func planKind(stmt string) string {
+	upper := strings.ToUpper(stmt)
+	switch {
+	case strings.Contains(upper, " INDEX "):
+		return PlanKindIndex
+	case strings.HasPrefix(upper, "INSERT"), strings.HasPrefix(upper, "UPDATE"), strings.HasPrefix(upper, "DELETE"):
+		return PlanKindData
+	default:
+		return PlanKindSchema
+	}
+}
+
+var (
+	// createIndexPattern matches idempotent index creation and captures the index name.
+	createIndexPattern = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?IF\s+NOT\s+EXISTS\s+"?(\w+)"?`)
+	// dropIndexPattern matches idempotent index removal and captures the index name.
+	dropIndexPattern = regexp.MustCompile(`(?i)^DROP\s+INDEX\s+(?:CONCURRENTLY\s+)?IF\s+EXISTS\s+"?(\w+)"?`)
+	// addColumnPattern matches idempotent column adds and captures table and column.
+	addColumnPattern = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+"?(\w+)"?\s+ADD\s+COLUMN\s+IF\s+NOT\s+EXISTS\s+"?(\w+)"?`)
+	// dropColumnPattern matches idempotent column removal and captures table and column.
+	dropColumnPattern = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+"?(\w+)"?\s+DROP\s+COLUMN\s+IF\s+EXISTS\s+"?(\w+)"?`)
+	// createExtensionPattern matches idempotent extension creation and captures the name.
+	createExtensionPattern = regexp.MustCompile(`(?i)^CREATE\s+EXTENSION\s+IF\s+NOT\s+EXISTS\s+"?(\w+)"?`)
+	// createIndexPrefix matches the CREATE [UNIQUE] INDEX keywords for the concurrent rewrite.
+	createIndexPrefix = regexp.MustCompile(`(?i)^(CREATE\s+(?:UNIQUE\s+)?INDEX)\s+`)
+)
+
+// statementPending reports whether stmt would change the schema. Statements that are not
+// idempotent DDL are always pending.
+func statementPending(conn *gorm.DB, stmt string) (bool, error) {
+	if m := createIndexPattern.FindStringSubmatch(stmt); m != nil {
+		exists, errExists := indexExists(conn, m[1])
+		return !exists, errExists
+	}
+	if m := dropIndexPattern.FindStringSubmatch(stmt); m != nil {
+		return indexExists(conn, m[1])
+	}
+	if m := addColumnPattern.FindStringSubmatch(stmt); m != nil {
+		return !conn.Migrator().HasColumn(m[1], m[2]), nil
+	}
+	if m := dropColumnPattern.FindStringSubmatch(stmt); m != nil {
+		return conn.Migrator().HasColumn(m[1], m[2]), nil
+	}
+	if strings.HasPrefix(strings.ToUpper(stmt), "PRAGMA") {
+		return false, nil
+	}
+	if table, where, ok := splitSimpleUpdate(stmt); ok {
+		var count int64
+		if errCount := conn.Raw(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s`, table, where)).Scan(&count).Error; errCount != nil {
+			// The table or column may only exist once earlier statements run.
+			return true, nil
+		}
+		return count > 0, nil
+	}
+	if m := createExtensionPattern.FindStringSubmatch(stmt); m != nil {
+		var count int64
+		if errCount := conn.Raw(`SELECT COUNT(*) FROM pg_extension WHERE extname = ?`, m[1]).Scan(&count).Error; errCount != nil {
+			return false, fmt.Errorf("db: inspect extension %s: %w", m[1], errCount)
+		}
+		return count == 0, nil
+	}
+	return true, nil
+}
+
+// updatePattern matches the head of an UPDATE statement and captures the table.
+var updatePattern = regexp.MustCompile(`(?i)^UPDATE\s+("?\w+"?)\s+SET\s`)
+
+// splitSimpleUpdate returns the table and top-level WHERE condition of an UPDATE without a
+// FROM clause, so the plan can check whether it would touch any rows.
+func splitSimpleUpdate(stmt string) (string, string, bool) {
+	m := updatePattern.FindStringSubmatch(stmt)
+	if m == nil {
+		return "", "", false
+	}
+	upper := strings.ToUpper(stmt)
+	depth := 0
+	inQuote := false
+	for i := len(m[0]); i < len(stmt); i++ {
+		switch c := stmt[i]; {
+		case c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(upper[i:], " FROM "):
+			return "", "", false
+		case depth == 0 && strings.HasPrefix(upper[i:], " WHERE "):
+			return m[1], stmt[i+len(" WHERE "):], true
+		}
+	}
+	return "", "", false
+}
+
+// indexExists reports whether an index with name exists in the current schema.
+func indexExists(conn *gorm.DB, name string) (bool, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM pg_indexes WHERE schemaname = current_schema() AND indexname = ?`
+	if IsSQLite(conn) {
+		query = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`
+	}
+	if errCount := conn.Raw(query, name).Scan(&count).Error; errCount != nil {
+		return false, fmt.Errorf("db: inspect index %s: %w", name, errCount)
+	}
+	return count > 0, nil
+}
+
+// execIndexPostgres creates a PostgreSQL index without blocking writes to the table.
+// A plain CREATE INDEX IF NOT EXISTS is rewritten to CREATE INDEX CONCURRENTLY. A failed
+// concurrent build leaves an invalid index behind that IF NOT EXISTS would otherwise keep
+// forever, so an invalid index of the same name is dropped first. Callers must not be
+// inside a transaction. Other statements are executed unchanged.
+func execIndexPostgres(conn *gorm.DB, stmt string) error {
+	trimmed := strings.TrimSpace(stmt)
+	m := createIndexPattern.FindStringSubmatch(trimmed)
+	if m == nil || strings.Contains(strings.ToUpper(m[0]), "CONCURRENTLY") {
+		return conn.Exec(stmt).Error
+	}
+	var invalid int64
+	if errInspect := conn.Raw(`
+		SELECT COUNT(*)
+		FROM pg_index
+		JOIN pg_class ON pg_class.oid = pg_index.indexrelid
+		JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
+		WHERE pg_class.relname = ? AND pg_namespace.nspname = current_schema() AND NOT pg_index.indisvalid
+	`, m[1]).Scan(&invalid).Error; errInspect != nil {
+		return errInspect
+	}
+	if invalid > 0 {
+		if errDrop := conn.Exec(fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %s`, m[1])).Error; errDrop != nil {
+			return errDrop
+		}
+	}
+	rewritten := createIndexPrefix.ReplaceAllString(trimmed, "$1 CONCURRENTLY ")
+	return conn.Exec(rewritten).Error
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanMigrateDoesNotModifySchema(t *testing.T) {
+	conn, errOpen := Open(filepath.Join(t.TempDir(), "plan.db"))
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+
+	plan, errPlan := PlanMigrate(conn)
+	if errPlan != nil {
+		t.Fatalf("plan fresh db: %v", errPlan)
+	}
+	if len(plan) == 0 {
+		t.Fatalf("expected a fresh database to have pending statements")
+	}
+	var objects int64
+	if errCount := conn.Raw(`SELECT COUNT(*) FROM sqlite_master`).Scan(&objects).Error; errCount != nil {
+		t.Fatalf("count schema objects: %v", errCount)
+	}
+	if objects != 0 {
+		t.Fatalf("expected plan to leave the database empty, found %d objects", objects)
+	}
+
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	plan, errPlan = PlanMigrate(conn)
+	if errPlan != nil {
+		t.Fatalf("plan migrated db: %v", errPlan)
+	}
+	for _, stmt := range plan {
+		upper := strings.ToUpper(stmt.SQL)
+		if strings.Contains(upper, "IF NOT EXISTS") || strings.HasPrefix(upper, "PRAGMA") || strings.HasPrefix(upper, "INSERT INTO `SETTINGS`") {
+			t.Fatalf("expected no-op statement to be filtered from plan: %s", stmt.SQL)
+		}
+	}
+}
+
+func TestParseMigrateMode(t *testing.T) {
+	for raw, want := range map[string]string{"": MigrateModeAuto, " Plan ": MigrateModePlan, "skip": MigrateModeSkip} {
+		got, errParse := ParseMigrateMode(raw)
+		if errParse != nil || got != want {
+			t.Fatalf("ParseMigrateMode(%q) = %q, %v; want %q", raw, got, errParse, want)
+		}
+	}
+	if _, errParse := ParseMigrateMode("later"); errParse == nil {
+		t.Fatalf("expected invalid mode to be rejected")
+	}
+}
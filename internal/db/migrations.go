@@ -0,0 +1,137 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// migrationStep is one ordered migration step. Versioned steps run once and are
+// recorded in schema_migrations; steps with version 0 run on every boot.
+type migrationStep struct {
+	version  int                  // Unique, increasing version; 0 for steps that always run.
+	name     string               // Human-readable name stored with the version.
+	baseline bool                 // Whether the step predates schema_migrations.
+	apply    func(*gorm.DB) error // Step body.
+}
+
+// postgresMigrations lists PostgreSQL steps in execution order. Append new versioned steps
+// at the end; never renumber or edit a step that has shipped.
+var postgresMigrations = []migrationStep{
+	{version: 1, name: "legacy_tables", baseline: true, apply: migratePostgresLegacyTables},
+	{name: "auto_migrate_models", apply: autoMigrateModels},
+	{version: 2, name: "usage_columns", baseline: true, apply: migratePostgresUsageColumns},
+	{name: "seed_settings", apply: ensureSeedSettings},
+	{version: 3, name: "backfill_usage_rollup_days", baseline: true, apply: backfillUsageRollupDays},
+	{version: 4, name: "group_ids", baseline: true, apply: migratePostgresGroupIDs},
+	{version: 5, name: "payload_rules", baseline: true, apply: migratePostgresPayloadRules},
+	{version: 6, name: "prepaid_cards", baseline: true, apply: migratePostgresPrepaidCards},
+	{version: 7, name: "drop_user_balance", baseline: true, apply: migratePostgresDropUserBalance},
+	{version: 8, name: "admin_roles", baseline: true, apply: migratePostgresAdminRoles},
+	{version: 9, name: "mfa_columns", baseline: true, apply: migratePostgresMFAColumns},
+	{version: 10, name: "indexes", baseline: true, apply: migratePostgresIndexes},
+	{version: 11, name: "search_indexes", baseline: true, apply: migratePostgresSearchIndexes},
+	{version: 12, name: "auth_lookup_indexes", apply: migratePostgresAuthLookupIndexes},
+	{version: 13, name: "auth_stats_indexes", apply: migratePostgresAuthStatsIndexes},
+	{version: 14, name: "auth_fingerprints", apply: backfillAuthFingerprints},
+	{version: 15, name: "auth_trash", apply: dropLegacyAuthKeyIndex},
+	{name: "case_insensitive_identities", apply: ensureCaseInsensitiveIdentityIndexes},
+}
+
+// sqliteMigrations lists SQLite steps in execution order. Append new versioned steps at
+// the end; never renumber or edit a step that has shipped.
+var sqliteMigrations = []migrationStep{
+	{version: 1, name: "legacy_tables", baseline: true, apply: migrateSQLiteLegacyTables},
+	{name: "auto_migrate_models", apply: autoMigrateModels},
+	{version: 2, name: "usage_columns", baseline: true, apply: migrateSQLiteUsageColumns},
+	{name: "seed_settings", apply: ensureSeedSettings},
+	{version: 3, name: "backfill_usage_rollup_days", baseline: true, apply: backfillUsageRollupDays},
+	{version: 4, name: "group_ids", baseline: true, apply: migrateSQLiteGroupIDs},
+	{version: 5, name: "payload_rules", baseline: true, apply: migrateSQLitePayloadRules},
+	{version: 6, name: "prepaid_cards", baseline: true, apply: migrateSQLitePrepaidCards},
+	{version: 7, name: "admin_roles", baseline: true, apply: migrateSQLiteAdminRoles},
+	{version: 8, name: "indexes", baseline: true, apply: migrateSQLiteIndexes},
+	{version: 9, name: "utc_timestamps", apply: migrateSQLiteUTCTimestamps},
+	{version: 10, name: "auth_lookup_indexes", apply: migrateSQLiteAuthLookupIndexes},
+	{version: 11, name: "auth_stats_indexes", apply: migrateSQLiteAuthStatsIndexes},
+	{version: 12, name: "auth_fingerprints", apply: backfillAuthFingerprints},
+	{version: 13, name: "auth_trash", apply: dropLegacyAuthKeyIndex},
+	{name: "case_insensitive_identities", apply: ensureCaseInsensitiveIdentityIndexes},
+}
+
+// applyMigrations runs steps in order, skipping versioned steps already recorded in
+// schema_migrations. A database that has no recorded versions but already carries the
+// schema produced by the baseline steps (per legacy) has those steps marked as applied
+// instead of re-running them. A failing step stops the run; its version stays unrecorded
+// so the next boot resumes from it.
+func applyMigrations(conn *gorm.DB, steps []migrationStep, legacy func(*gorm.DB) bool) error {
+	if errTable := conn.AutoMigrate(&models.SchemaMigration{}); errTable != nil {
+		return fmt.Errorf("db: migrate schema_migrations: %w", errTable)
+	}
+	var versions []int
+	if errFind := conn.Model(&models.SchemaMigration{}).Pluck("version", &versions).Error; errFind != nil {
+		return fmt.Errorf("db: query schema_migrations: %w", errFind)
+	}
+	applied := make(map[int]struct{}, len(versions))
+	for _, version := range versions {
+		applied[version] = struct{}{}
+	}
+
+	if len(applied) == 0 && legacy != nil && legacy(conn) {
+		for _, step := range steps {
+			if step.version == 0 || !step.baseline {
+				continue
+			}
+			if errRecord := recordMigration(conn, step); errRecord != nil {
+				return errRecord
+			}
+			applied[step.version] = struct{}{}
+		}
+		log.Info("db: existing schema detected, marked baseline migrations as applied")
+	}
+
+	for _, step := range steps {
+		if step.version != 0 {
+			if _, ok := applied[step.version]; ok {
+				continue
+			}
+		}
+		if errApply := step.apply(conn); errApply != nil {
+			return errApply
+		}
+		if step.version == 0 {
+			continue
+		}
+		if errRecord := recordMigration(conn, step); errRecord != nil {
+			return errRecord
+		}
+		log.Infof("db: applied migration %d (%s)", step.version, step.name)
+	}
+	return nil
+}
+
+// recordMigration stores step as applied.
+func recordMigration(conn *gorm.DB, step migrationStep) error {
+	row := models.SchemaMigration{Version: step.version, Name: step.name, AppliedAt: time.Now().UTC()}
+	if errCreate := conn.Create(&row).Error; errCreate != nil {
+		return fmt.Errorf("db: record migration %d (%s): %w", step.version, step.name, errCreate)
+	}
+	return nil
+}
+
+// hasLegacySchema reports whether the database already carries the columns and tables
+// added by the last migrations that ran before schema_migrations existed. Only schema
+// present in releases without schema_migrations may be checked here.
+func hasLegacySchema(conn *gorm.DB) bool {
+	migrator := conn.Migrator()
+	return migrator.HasTable(&models.UserModelAuthBinding{}) &&
+		migrator.HasColumn(&models.Admin{}, "is_super_admin") &&
+		migrator.HasColumn(&models.Admin{}, "permissions") &&
+		migrator.HasColumn(&models.User{}, "passkey_backup_state") &&
+		migrator.HasColumn(&models.Usage{}, "error_detail") &&
+		migrator.HasColumn(&models.ModelReference{}, "model_id") &&
+		migrator.HasColumn(&models.PrepaidCard{}, "expires_at")
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// openTestDB opens an empty SQLite database in a temp directory.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, errOpen := Open(filepath.Join(t.TempDir(), "migrations.db"))
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	return conn
+}
+
+// appliedVersions returns the recorded schema_migrations versions in order.
+func appliedVersions(t *testing.T, conn *gorm.DB) []int {
+	t.Helper()
+	var versions []int
+	if errFind := conn.Model(&models.SchemaMigration{}).Order("version ASC").Pluck("version", &versions).Error; errFind != nil {
+		t.Fatalf("query schema_migrations: %v", errFind)
+	}
+	return versions
+}
+
+func TestMigrateFreshInstallRecordsEveryStep(t *testing.T) {
+	conn := openTestDB(t)
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	want := 0
+	for _, step := range sqliteMigrations {
+		if step.version != 0 {
+			want++
+		}
+	}
+	if got := appliedVersions(t, conn); len(got) != want {
+		t.Fatalf("expected %d recorded versions, got %v", want, got)
+	}
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("second migrate: %v", errMigrate)
+	}
+	if got := appliedVersions(t, conn); len(got) != want {
+		t.Fatalf("expected re-run to record nothing new, got %v", got)
+	}
+}
+
+func TestMigrateMarksBaselineForExistingInstall(t *testing.T) {
+	conn := openTestDB(t)
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	// Simulate a database migrated before schema_migrations existed, which also lacks
+	// the schema added alongside it.
+	if errDrop := conn.Migrator().DropTable(&models.SchemaMigration{}, &models.UsageRollupDay{}); errDrop != nil {
+		t.Fatalf("drop tables: %v", errDrop)
+	}
+	if errDrop := conn.Migrator().DropColumn(&models.Usage{}, "stream"); errDrop != nil {
+		t.Fatalf("drop usages.stream: %v", errDrop)
+	}
+
+	runs := map[int]int{}
+	step := func(version int, baseline bool) migrationStep {
+		return migrationStep{version: version, name: "step", baseline: baseline, apply: func(*gorm.DB) error {
+			runs[version]++
+			return nil
+		}}
+	}
+	steps := []migrationStep{step(1, true), step(2, true), step(3, false)}
+	if errApply := applyMigrations(conn, steps, hasLegacySchema); errApply != nil {
+		t.Fatalf("apply: %v", errApply)
+	}
+	if runs[1] != 0 || runs[2] != 0 || runs[3] != 1 {
+		t.Fatalf("expected only the new step to run, got %v", runs)
+	}
+	if got := appliedVersions(t, conn); len(got) != 3 {
+		t.Fatalf("expected 3 recorded versions, got %v", got)
+	}
+}
+
+func TestMigrateResumesAfterFailedStep(t *testing.T) {
+	conn := openTestDB(t)
+
+	runs := map[int]int{}
+	failSecond := true
+	steps := []migrationStep{
+		{version: 1, name: "first", apply: func(*gorm.DB) error { runs[1]++; return nil }},
+		{name: "always", apply: func(*gorm.DB) error { runs[0]++; return nil }},
+		{version: 2, name: "second", apply: func(*gorm.DB) error {
+			runs[2]++
+			if failSecond {
+				return errors.New("boom")
+			}
+			return nil
+		}},
+		{version: 3, name: "third", apply: func(*gorm.DB) error { runs[3]++; return nil }},
+	}
+
+	if errApply := applyMigrations(conn, steps, hasLegacySchema); errApply == nil {
+		t.Fatalf("expected failing step to stop the run")
+	}
+	if got := appliedVersions(t, conn); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected only version 1 recorded, got %v", got)
+	}
+	if runs[3] != 0 {
+		t.Fatalf("expected steps after the failure not to run")
+	}
+
+	failSecond = false
+	if errApply := applyMigrations(conn, steps, hasLegacySchema); errApply != nil {
+		t.Fatalf("resume: %v", errApply)
+	}
+	if runs[1] != 1 || runs[2] != 2 || runs[3] != 1 || runs[0] != 2 {
+		t.Fatalf("unexpected run counts after resume: %v", runs)
+	}
+	if got := appliedVersions(t, conn); len(got) != 3 {
+		t.Fatalf("expected 3 recorded versions, got %v", got)
+	}
+}
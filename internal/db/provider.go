@@ -0,0 +1,29 @@
+package db
+
+import "gorm.io/gorm"
+
+// DBProvider hands out the primary connection and an optional read-only replica.
+// Handlers that only run heavy read queries take a DBProvider so the replica can be
+// swapped in without touching the transactional write path.
+type DBProvider struct {
+	Primary *gorm.DB // Read-write connection for transactional work.
+	Replica *gorm.DB // Optional read-only connection; nil routes reads to Primary.
+}
+
+// NewDBProvider constructs a DBProvider; replica may be nil.
+func NewDBProvider(primary, replica *gorm.DB) DBProvider {
+	return DBProvider{Primary: primary, Replica: replica}
+}
+
+// Write returns the primary connection.
+func (p DBProvider) Write() *gorm.DB {
+	return p.Primary
+}
+
+// Read returns the replica when configured, otherwise the primary connection.
+func (p DBProvider) Read() *gorm.DB {
+	if p.Replica != nil {
+		return p.Replica
+	}
+	return p.Primary
+}
@@ -0,0 +1,24 @@
+package db
+
+import "testing"
+
+func TestDBProviderReadFallsBackToPrimary(t *testing.T) {
+	primary, errPrimary := Open(":memory:")
+	if errPrimary != nil {
+		t.Fatalf("open primary: %v", errPrimary)
+	}
+	replica, errReplica := Open(":memory:")
+	if errReplica != nil {
+		t.Fatalf("open replica: %v", errReplica)
+	}
+
+	single := NewDBProvider(primary, nil)
+	if single.Read() != primary || single.Write() != primary {
+		t.Fatalf("expected reads and writes on primary without a replica")
+	}
+
+	split := NewDBProvider(primary, replica)
+	if split.Read() != replica || split.Write() != primary {
+		t.Fatalf("expected reads on replica and writes on primary")
+	}
+}
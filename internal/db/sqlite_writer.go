@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SQLite busy result codes; extended codes share the low byte.
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// Busy retry tuning for SQLite writes.
+const (
+	// busyRetryAttempts bounds how often a busy SQLite write is retried.
+	busyRetryAttempts = 5
+	// busyRetryBaseDelay is the first backoff delay, doubled per attempt before jitter.
+	busyRetryBaseDelay = 20 * time.Millisecond
+)
+
+// cteWritePattern matches data-modifying statements behind a WITH clause.
+var cteWritePattern = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|REPLACE)\b`)
+
+// sqliteConnPool routes SQLite writes and transactions through a single-connection
+// writer pool while plain reads keep using a regular pool. SQLite allows one writer at a
+// time, so serializing writes in-process turns lock contention between goroutines into
+// queueing instead of "database is locked" errors.
+type sqliteConnPool struct {
+	reader *sql.DB
+	writer *sql.DB
+}
+
+// newSQLiteConnPool opens the writer pool for dsn next to reader.
+func newSQLiteConnPool(reader *sql.DB, driverName, dsn string) (*sqliteConnPool, error) {
+	writer, errOpen := sql.Open(driverName, sqliteWriterDSN(dsn))
+	if errOpen != nil {
+		return nil, fmt.Errorf("db: open sqlite writer: %w", errOpen)
+	}
+	writer.SetMaxOpenConns(1)
+	writer.SetMaxIdleConns(1)
+	writer.SetConnMaxLifetime(0)
+	if errPragma := applySQLitePragmas(writer); errPragma != nil {
+		_ = writer.Close()
+		return nil, errPragma
+	}
+	return &sqliteConnPool{reader: reader, writer: writer}, nil
+}
+
+// sqliteWriterDSN makes writer transactions take the write lock at BEGIN, so a
+// transaction never fails half-way when upgrading from a read lock.
+func sqliteWriterDSN(dsn string) string {
+	if strings.Contains(strings.ToLower(dsn), "_txlock=") {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_txlock=immediate"
+}
+
+// sqliteSharesFile reports whether separate connections to dsn see the same database.
+// In-memory databases are private to each connection, so they cannot be split.
+func sqliteSharesFile(dsn string) bool {
+	lower := strings.ToLower(dsn)
+	if strings.Contains(lower, ":memory:") || strings.Contains(lower, "mode=memory") {
+		return false
+	}
+	return sqlitePathFromDSN(dsn) != ""
+}
+
+// pool returns the pool that should run query.
+func (p *sqliteConnPool) pool(query string) *sql.DB {
+	if isReadOnlySQL(query) {
+		if fields := strings.Fields(query); len(fields) == 0 || !strings.EqualFold(fields[0], "WITH") || !cteWritePattern.MatchString(query) {
+			return p.reader
+		}
+	}
+	return p.writer
+}
+
+// PrepareContext prepares query on the pool that runs it.
+func (p *sqliteConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.pool(query).PrepareContext(ctx, query)
+}
+
+// ExecContext runs query on the writer.
+func (p *sqliteConnPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return p.writer.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs reads on the reader and INSERT ... RETURNING style writes on the writer.
+func (p *sqliteConnPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return p.pool(query).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs query on the pool that owns it.
+func (p *sqliteConnPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return p.pool(query).QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx starts every transaction on the writer.
+func (p *sqliteConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return p.writer.BeginTx(ctx, opts)
+}
+
+// GetDBConn returns the writer pool to gorm.DB.DB() callers, so raw statements run
+// through it are serialized with every other write. Use ReadDB for raw reads.
+func (p *sqliteConnPool) GetDBConn() (*sql.DB, error) {
+	return p.writer, nil
+}
+
+// Close closes both pools.
+func (p *sqliteConnPool) Close() error {
+	return errors.Join(p.writer.Close(), p.reader.Close())
+}
+
+// ReadDB returns the pool for raw read-only statements on conn: the reader pool when
+// SQLite writes are split onto their own connection, otherwise conn.DB().
+func ReadDB(conn *gorm.DB) (*sql.DB, error) {
+	if pool, ok := conn.ConnPool.(*sqliteConnPool); ok {
+		return pool.reader, nil
+	}
+	return conn.DB()
+}
+
+// Close closes the connection pools behind conn.
+func Close(conn *gorm.DB) error {
+	if conn == nil {
+		return nil
+	}
+	if pool, ok := conn.ConnPool.(*sqliteConnPool); ok {
+		return pool.Close()
+	}
+	sqlDB, errDB := conn.DB()
+	if errDB != nil {
+		return errDB
+	}
+	return sqlDB.Close()
+}
+
+// IsBusyError reports whether err is a SQLite busy or locked error.
+func IsBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		code := coded.Code() & 0xff
+		return code == sqliteBusy || code == sqliteLocked
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "sqlite_busy")
+}
+
+// RetryOnBusy runs fn inside a savepoint of tx and, on SQLite only, rolls the savepoint
+// back and retries with jittered exponential backoff while fn fails with a busy error.
+// Other dialects run fn once.
+func RetryOnBusy(ctx context.Context, tx *gorm.DB, fn func() error) error {
+	if !IsSQLite(tx) {
+		return fn()
+	}
+	delay := busyRetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		name := fmt.Sprintf("busy_retry_%d", attempt)
+		if errSave := tx.SavePoint(name).Error; errSave != nil {
+			return errSave
+		}
+		errFn := fn()
+		if errFn == nil {
+			return tx.Exec("RELEASE SAVEPOINT " + name).Error
+		}
+		if !IsBusyError(errFn) || attempt >= busyRetryAttempts {
+			return errFn
+		}
+		if errRollback := tx.RollbackTo(name).Error; errRollback != nil {
+			return errors.Join(errFn, errRollback)
+		}
+		wait := delay/2 + rand.N(delay)
+		select {
+		case <-ctx.Done():
+			return errors.Join(errFn, ctx.Err())
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
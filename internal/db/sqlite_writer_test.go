@@ -0,0 +1,89 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestSQLiteConnPoolRoutesWritesToWriter(t *testing.T) {
+	conn := openTestDB(t)
+	pool, ok := conn.ConnPool.(*sqliteConnPool)
+	if !ok {
+		t.Fatalf("expected file-backed sqlite to use the split pool, got %T", conn.ConnPool)
+	}
+	t.Cleanup(func() { _ = Close(conn) })
+
+	cases := map[string]bool{
+		"SELECT * FROM users":                              false,
+		"PRAGMA table_info(users)":                         false,
+		"WITH t AS (SELECT 1) SELECT * FROM t":             false,
+		"INSERT INTO users (name) VALUES (?) RETURNING id": true,
+		"UPDATE users SET name = ?":                        true,
+		"WITH t AS (SELECT 1) DELETE FROM users":           true,
+		"PRAGMA foreign_keys=OFF":                          true,
+	}
+	for query, wantWriter := range cases {
+		if got := pool.pool(query) == pool.writer; got != wantWriter {
+			t.Fatalf("%q: expected writer=%v, got %v", query, wantWriter, got)
+		}
+	}
+
+	if sqlDB, errDB := conn.DB(); errDB != nil || sqlDB != pool.writer {
+		t.Fatalf("expected conn.DB() to return the writer pool, got %v", errDB)
+	}
+	if sqlDB, errDB := ReadDB(conn); errDB != nil || sqlDB != pool.reader {
+		t.Fatalf("expected ReadDB to return the reader pool, got %v", errDB)
+	}
+
+	memory, errOpen := Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open memory db: %v", errOpen)
+	}
+	if _, split := memory.ConnPool.(*sqliteConnPool); split {
+		t.Fatalf("expected in-memory sqlite to keep a single pool")
+	}
+}
+
+// codedError mimics the driver error type that exposes a SQLite result code.
+type codedError struct{ code int }
+
+func (e codedError) Error() string { return "sqlite error" }
+func (e codedError) Code() int     { return e.code }
+
+func TestIsBusyError(t *testing.T) {
+	if !IsBusyError(codedError{code: 517}) {
+		t.Fatalf("expected SQLITE_BUSY_SNAPSHOT to count as busy")
+	}
+	if IsBusyError(codedError{code: 19}) {
+		t.Fatalf("expected constraint errors not to count as busy")
+	}
+	if !IsBusyError(errors.New("database is locked (5) (SQLITE_BUSY)")) {
+		t.Fatalf("expected message fallback to detect busy errors")
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	conn, errOpen := Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	if errCreate := conn.Create(&models.Setting{Key: "DUP", Value: []byte(`1`)}).Error; errCreate != nil {
+		t.Fatalf("create setting: %v", errCreate)
+	}
+	errDup := conn.Create(&models.Setting{Key: "DUP", Value: []byte(`2`)}).Error
+	if !IsUniqueViolation(errDup) {
+		t.Fatalf("expected unique violation, got %v", errDup)
+	}
+	if IsUniqueViolation(codedError{code: 19}) || IsUniqueViolation(errors.New("duplicate key")) {
+		t.Fatalf("expected other errors not to count as unique violations")
+	}
+	if !IsUniqueViolation(&pgconn.PgError{Code: "23505"}) {
+		t.Fatalf("expected postgres unique_violation to be detected")
+	}
+}
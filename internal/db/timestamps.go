@@ -0,0 +1,232 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// sqliteTimeLayout is the layout the SQLite driver writes time values with. Stored in
+	// UTC its values sort lexically in time order, which updated_at polling relies on.
+	sqliteTimeLayout = "2006-01-02 15:04:05.999999999-07:00"
+	// utcTimestampsCallback names the create and update callbacks registered by
+	// registerUTCTimestamps.
+	utcTimestampsCallback = "app:utc_timestamps"
+	// sqliteTimeFixBatchSize is the number of rows rewritten per batch by
+	// migrateSQLiteUTCTimestamps.
+	sqliteTimeFixBatchSize = 500
+)
+
+// sqliteTimeLayouts lists the layouts the SQLite driver accepts when reading time values.
+// Values without an offset are read as UTC.
+var sqliteTimeLayouts = []string{
+	sqliteTimeLayout,
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// utcNow is the gorm NowFunc: autoCreateTime and autoUpdateTime fields are stamped in UTC
+// instead of the host time zone installed by loadGlobalTimeZone.
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
+
+// registerUTCTimestamps converts every time value written through conn to UTC before
+// creates and updates, covering model fields as well as map-based Updates that bypass
+// model hooks.
+func registerUTCTimestamps(conn *gorm.DB) error {
+	if errCreate := conn.Callback().Create().Before("gorm:create").Register(utcTimestampsCallback, normalizeTimestampsUTC); errCreate != nil {
+		return fmt.Errorf("db: register create timestamp callback: %w", errCreate)
+	}
+	if errUpdate := conn.Callback().Update().Before("gorm:update").Register(utcTimestampsCallback, normalizeTimestampsUTC); errUpdate != nil {
+		return fmt.Errorf("db: register update timestamp callback: %w", errUpdate)
+	}
+	return nil
+}
+
+// normalizeTimestampsUTC converts the time values of the statement destination to UTC.
+func normalizeTimestampsUTC(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement == nil {
+		return
+	}
+	stmt := tx.Statement
+	switch dest := stmt.Dest.(type) {
+	case map[string]any:
+		normalizeTimeMap(dest)
+		return
+	case *map[string]any:
+		if dest != nil {
+			normalizeTimeMap(*dest)
+		}
+		return
+	case []map[string]any:
+		for _, item := range dest {
+			normalizeTimeMap(item)
+		}
+		return
+	}
+	if stmt.Schema == nil {
+		return
+	}
+	// Updates with a struct read values from Dest, which may differ from Model.
+	normalizeTimeValue(stmt, stmt.ReflectValue)
+	normalizeTimeValue(stmt, reflect.ValueOf(stmt.Dest))
+}
+
+// normalizeTimeValue converts the time fields of a model, a pointer to one, or a slice of
+// them to UTC. Values of other types are left untouched.
+func normalizeTimeValue(stmt *gorm.Statement, value reflect.Value) {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			normalizeTimeValue(stmt, value.Index(i))
+		}
+	case reflect.Struct:
+		if value.Type() != stmt.Schema.ModelType || !value.CanAddr() {
+			return
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DataType != schema.Time {
+				continue
+			}
+			raw, isZero := field.ValueOf(stmt.Context, value)
+			if isZero {
+				continue
+			}
+			switch typed := raw.(type) {
+			case time.Time:
+				if typed.Location() != time.UTC {
+					_ = field.Set(stmt.Context, value, typed.UTC())
+				}
+			case *time.Time:
+				if typed != nil && typed.Location() != time.UTC {
+					*typed = typed.UTC()
+				}
+			}
+		}
+	}
+}
+
+// normalizeTimeMap converts the time values of a column map to UTC.
+func normalizeTimeMap(values map[string]any) {
+	for key, raw := range values {
+		switch typed := raw.(type) {
+		case time.Time:
+			values[key] = typed.UTC()
+		case *time.Time:
+			if typed != nil {
+				values[key] = typed.UTC()
+			}
+		}
+	}
+}
+
+// migrateSQLiteUTCTimestamps rewrites the time columns of every migrated model to UTC in
+// sqliteTimeLayout. Rows written with a local offset or another layout break text
+// ordering, so the watcher could miss updated rows. Unparseable values are kept.
+func migrateSQLiteUTCTimestamps(conn *gorm.DB) error {
+	for _, model := range migratedModels() {
+		stmt := &gorm.Statement{DB: conn}
+		if errParse := stmt.Parse(model); errParse != nil {
+			return fmt.Errorf("db: parse model for utc timestamps: %w", errParse)
+		}
+		if !conn.Migrator().HasTable(stmt.Schema.Table) {
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.DataType != schema.Time {
+				continue
+			}
+			if errFix := normalizeSQLiteTimeColumn(conn, stmt.Schema.Table, field.DBName); errFix != nil {
+				return errFix
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeSQLiteTimeColumn rewrites one time column to UTC in batches keyed by rowid.
+func normalizeSQLiteTimeColumn(conn *gorm.DB, table, column string) error {
+	// timeRow is one stored time value.
+	type timeRow struct {
+		rowID int64  // SQLite rowid.
+		raw   string // Stored value as text.
+	}
+	selectSQL := fmt.Sprintf(`SELECT rowid, CAST("%s" AS TEXT) FROM "%s" WHERE "%s" IS NOT NULL AND rowid > ? ORDER BY rowid LIMIT ?`, column, table, column)
+	updateSQL := fmt.Sprintf(`UPDATE "%s" SET "%s" = ? WHERE rowid = ?`, table, column)
+	lastRowID := int64(0)
+	for {
+		rows, errQuery := conn.Raw(selectSQL, lastRowID, sqliteTimeFixBatchSize).Rows()
+		if errQuery != nil {
+			return fmt.Errorf("db: query %s.%s timestamps: %w", table, column, errQuery)
+		}
+		batch := make([]timeRow, 0, sqliteTimeFixBatchSize)
+		for rows.Next() {
+			var (
+				rowID int64
+				raw   sql.NullString
+			)
+			if errScan := rows.Scan(&rowID, &raw); errScan != nil {
+				_ = rows.Close()
+				return fmt.Errorf("db: scan %s.%s timestamp: %w", table, column, errScan)
+			}
+			batch = append(batch, timeRow{rowID: rowID, raw: raw.String})
+		}
+		errRows := rows.Err()
+		_ = rows.Close()
+		if errRows != nil {
+			return fmt.Errorf("db: read %s.%s timestamps: %w", table, column, errRows)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		errTx := conn.Transaction(func(tx *gorm.DB) error {
+			for _, row := range batch {
+				normalized, ok := normalizeSQLiteTimeText(row.raw)
+				if !ok || normalized == row.raw {
+					continue
+				}
+				if errUpdate := tx.Exec(updateSQL, normalized, row.rowID).Error; errUpdate != nil {
+					return errUpdate
+				}
+			}
+			return nil
+		})
+		if errTx != nil {
+			return fmt.Errorf("db: normalize %s.%s timestamps: %w", table, column, errTx)
+		}
+		lastRowID = batch[len(batch)-1].rowID
+	}
+}
+
+// normalizeSQLiteTimeText parses a stored SQLite time value and formats it in UTC.
+func normalizeSQLiteTimeText(raw string) (string, bool) {
+	trimmed := raw
+	if n := len(trimmed); n > 0 && trimmed[n-1] == 'Z' {
+		trimmed = trimmed[:n-1]
+	}
+	for _, layout := range sqliteTimeLayouts {
+		parsed, errParse := time.ParseInLocation(layout, trimmed, time.UTC)
+		if errParse == nil {
+			return parsed.UTC().Format(sqliteTimeLayout), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// storedAuthTime returns the raw stored text of an auths time column.
+func storedAuthTime(t *testing.T, conn *gorm.DB, key, column string) string {
+	t.Helper()
+	var raw string
+	if errScan := conn.Raw(`SELECT CAST("`+column+`" AS TEXT) FROM auths WHERE key = ?`, key).Scan(&raw).Error; errScan != nil {
+		t.Fatalf("read %s: %v", column, errScan)
+	}
+	return raw
+}
+
+func TestWritesStoreTimestampsInUTC(t *testing.T) {
+	conn := openTestDB(t)
+	t.Cleanup(func() { _ = Close(conn) })
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	created := time.Date(2026, 1, 2, 18, 0, 0, 0, shanghai)
+	auth := models.Auth{Key: "a.json", Content: datatypes.JSON(`{}`), CreatedAt: created, UpdatedAt: created}
+	if errCreate := conn.Create(&auth).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+	if got := storedAuthTime(t, conn, "a.json", "created_at"); got != "2026-01-02 10:00:00+00:00" {
+		t.Fatalf("expected created_at in UTC, got %q", got)
+	}
+
+	updated := created.Add(time.Hour)
+	if errUpdate := conn.Model(&models.Auth{}).Where("id = ?", auth.ID).
+		Updates(map[string]any{"updated_at": updated}).Error; errUpdate != nil {
+		t.Fatalf("update auth: %v", errUpdate)
+	}
+	if got := storedAuthTime(t, conn, "a.json", "updated_at"); got != "2026-01-02 11:00:00+00:00" {
+		t.Fatalf("expected map update in UTC, got %q", got)
+	}
+
+	if errUpdate := conn.Model(&models.Auth{}).Where("id = ?", auth.ID).Update("priority", 3).Error; errUpdate != nil {
+		t.Fatalf("touch auth: %v", errUpdate)
+	}
+	var reloaded models.Auth
+	if errFind := conn.First(&reloaded, auth.ID).Error; errFind != nil {
+		t.Fatalf("reload auth: %v", errFind)
+	}
+	if reloaded.UpdatedAt.Before(updated) {
+		t.Fatalf("expected autoUpdateTime after %s, got %s", updated, reloaded.UpdatedAt)
+	}
+	if got := storedAuthTime(t, conn, "a.json", "updated_at"); len(got) < 6 || got[len(got)-6:] != "+00:00" {
+		t.Fatalf("expected autoUpdateTime in UTC, got %q", got)
+	}
+}
+
+func TestMigrateSQLiteUTCTimestampsRewritesMixedRows(t *testing.T) {
+	conn := openTestDB(t)
+	t.Cleanup(func() { _ = Close(conn) })
+	if errMigrate := Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	rows := []struct {
+		key, createdAt string
+	}{
+		{"offset.json", "2026-01-02 18:00:00.5+08:00"},
+		{"iso.json", "2026-01-02T09:30:00Z"},
+		{"utc.json", "2026-01-02 09:00:00+00:00"},
+		{"junk.json", "not a time"},
+	}
+	for _, row := range rows {
+		if errInsert := conn.Exec(
+			`INSERT INTO auths (key, content, tags, auth_group_id, created_at, updated_at) VALUES (?, '{}', '[]', '[]', ?, ?)`,
+			row.key, row.createdAt, row.createdAt,
+		).Error; errInsert != nil {
+			t.Fatalf("insert %s: %v", row.key, errInsert)
+		}
+	}
+
+	if errFix := migrateSQLiteUTCTimestamps(conn); errFix != nil {
+		t.Fatalf("fix timestamps: %v", errFix)
+	}
+	want := map[string]string{
+		"offset.json": "2026-01-02 10:00:00.5+00:00",
+		"iso.json":    "2026-01-02 09:30:00+00:00",
+		"utc.json":    "2026-01-02 09:00:00+00:00",
+		"junk.json":   "not a time",
+	}
+	for key, expected := range want {
+		if got := storedAuthTime(t, conn, key, "updated_at"); got != expected {
+			t.Fatalf("%s: expected %q, got %q", key, expected, got)
+		}
+	}
+	var latest string
+	if errLatest := conn.Model(&models.Auth{}).Where("key <> ?", "junk.json").
+		Order("updated_at DESC").Limit(1).Pluck("key", &latest).Error; errLatest != nil {
+		t.Fatalf("query latest: %v", errLatest)
+	}
+	if latest != "offset.json" {
+		t.Fatalf("expected offset.json to sort last after the fix, got %s", latest)
+	}
+}
@@ -0,0 +1,158 @@
+// Package events fans out in-process notifications, such as new usage and auth state
+// changes, to live subscribers like the admin event stream.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types.
+const (
+	// TypeUsage reports a newly recorded usage row.
+	TypeUsage = "usage"
+	// TypeAuthState reports an auth that was registered, updated or failed upstream.
+	TypeAuthState = "auth_state"
+	// TypeRateLimit reports a request rejected by the rate limiter.
+	TypeRateLimit = "rate_limit"
+)
+
+// Types lists every event type.
+var Types = []string{TypeUsage, TypeAuthState, TypeRateLimit}
+
+// Event is one notification delivered to subscribers.
+type Event struct {
+	Type string    `json:"type"` // Event type.
+	Time time.Time `json:"time"` // When the event was published.
+	Data any       `json:"data"` // Type-specific payload.
+}
+
+// Broker fans out published events to subscribers. Each subscriber has its own buffer;
+// one whose buffer is full when an event arrives is dropped, so a slow consumer never
+// blocks publishers or delays other subscribers.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	count       atomic.Int64 // Subscriber count, read without the lock by Publish.
+	now         func() time.Time
+}
+
+// Subscription receives the events published after Subscribe until it is closed.
+type Subscription struct {
+	broker *Broker
+	ch     chan Event
+	closed bool // Guarded by broker.mu.
+}
+
+// NewBroker constructs an empty Broker.
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[*Subscription]struct{}), now: time.Now}
+}
+
+// defaultBroker receives the events published through Publish.
+var defaultBroker = NewBroker()
+
+// Default returns the process-wide broker.
+func Default() *Broker {
+	return defaultBroker
+}
+
+// Publish sends an event to the subscribers of the process-wide broker.
+func Publish(eventType string, data any) {
+	defaultBroker.Publish(eventType, data)
+}
+
+// Subscribe registers a subscriber that buffers up to buffer events.
+func (b *Broker) Subscribe(buffer int) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	sub := &Subscription{broker: b, ch: make(chan Event, buffer)}
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.count.Add(1)
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish sends an event to every subscriber without blocking. It is cheap while nobody
+// is subscribed, so hot paths may call it unconditionally.
+func (b *Broker) Publish(eventType string, data any) {
+	if b == nil || b.count.Load() == 0 {
+		return
+	}
+	evt := Event{Type: eventType, Time: b.now().UTC(), Data: data}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- evt:
+		default:
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// Events returns the channel of delivered events. It is closed when the subscription is
+// closed or dropped for falling behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close unregisters the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	s.broker.removeLocked(s)
+	s.broker.mu.Unlock()
+}
+
+// removeLocked unregisters sub and closes its channel; b.mu must be held.
+func (b *Broker) removeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	b.count.Add(-1)
+	close(sub.ch)
+}
+
+// UsageData is the payload of TypeUsage events.
+type UsageData struct {
+	ID              uint64    `json:"id"`
+	RequestedAt     time.Time `json:"requested_at"`
+	Provider        string    `json:"provider"`
+	Model           string    `json:"model"`
+	UserID          *uint64   `json:"user_id"`
+	APIKeyID        *uint64   `json:"api_key_id"`
+	AuthKey         string    `json:"auth_key"`
+	Failed          bool      `json:"failed"`
+	ErrorStatusCode *int      `json:"error_status_code"`
+	TotalTokens     int64     `json:"total_tokens"`
+	CostMicros      int64     `json:"cost_micros"`
+	DurationMs      *int64    `json:"duration_ms"`
+}
+
+// AuthStateData is the payload of TypeAuthState events.
+type AuthStateData struct {
+	AuthID        string `json:"auth_id"`
+	Provider      string `json:"provider"`
+	Change        string `json:"change"` // registered, updated or failed.
+	Status        string `json:"status,omitempty"`
+	StatusMessage string `json:"status_message,omitempty"`
+	Disabled      bool   `json:"disabled"`
+	Unavailable   bool   `json:"unavailable"`
+	Model         string `json:"model,omitempty"`       // Set for failed results.
+	StatusCode    int    `json:"status_code,omitempty"` // Upstream status of a failed result.
+}
+
+// RateLimitData is the payload of TypeRateLimit events.
+type RateLimitData struct {
+	UserID       uint64 `json:"user_id"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	AuthKey      string `json:"auth_key"`
+	Limit        int    `json:"limit"`
+	ResetSeconds int    `json:"reset_seconds"`
+}
@@ -0,0 +1,37 @@
+package events
+
+import "testing"
+
+func TestBrokerFansOutAndDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker()
+	b.Publish(TypeUsage, "before")
+
+	fast := b.Subscribe(4)
+	slow := b.Subscribe(1)
+	b.Publish(TypeUsage, 1)
+	if evt := <-fast.Events(); evt.Type != TypeUsage || evt.Data != 1 || evt.Time.IsZero() {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	b.Publish(TypeRateLimit, 2)
+	if evt := <-fast.Events(); evt.Type != TypeRateLimit {
+		t.Fatalf("expected the fast subscriber to keep receiving, got %+v", evt)
+	}
+	if evt := <-slow.Events(); evt.Data != 1 {
+		t.Fatalf("expected the slow subscriber to keep its buffered event, got %+v", evt)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("expected the slow subscriber to be dropped once its buffer overflowed")
+	}
+	if got := b.count.Load(); got != 1 {
+		t.Fatalf("expected one subscriber left, got %d", got)
+	}
+
+	slow.Close()
+	fast.Close()
+	fast.Close()
+	if _, ok := <-fast.Events(); ok {
+		t.Fatal("expected a closed subscription to close its channel")
+	}
+	b.Publish(TypeUsage, 3)
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/access"
-	log "github.com/sirupsen/logrus"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
 )
 
 // AccessAuthMiddleware authenticates API keys and injects access metadata.
@@ -38,8 +38,10 @@ func AccessAuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
 		case errors.Is(err, access.ErrInsufficientBalance):
 			c.AbortWithStatusJSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient balance"})
+		case errors.Is(err, access.ErrDailySpendCapExceeded):
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Daily spend cap exceeded"})
 		default:
-			log.WithError(err).Error("access auth middleware error")
+			logging.FromContext(c.Request.Context()).WithError(err).Error("access auth middleware error")
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Authentication service error"})
 		}
 	}
@@ -1,23 +1,30 @@
 package admin
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	sdkapi "github.com/router-for-me/CLIProxyAPI/v6/sdk/api"
 	sdkhandlers "github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/events"
 	handlers "github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/handlers"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/permissions"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 	"gorm.io/gorm"
 )
 
-// RegisterAdminRoutes registers admin routes, middleware, and handlers.
-func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, configPath string, cfg *sdkconfig.Config, baseHandler *sdkhandlers.BaseAPIHandler) {
+// RegisterAdminRoutes registers admin routes, middleware, and handlers. Read-only
+// analytics handlers use the replica from dbs when one is configured.
+func RegisterAdminRoutes(r *gin.Engine, dbs dbutil.DBProvider, jwtCfg config.JWTConfig, configPath string, cfg *sdkconfig.Config, baseHandler *sdkhandlers.BaseAPIHandler) {
+	db := dbs.Write()
 	if r == nil || db == nil {
 		return
 	}
@@ -41,6 +48,8 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	adminGroup.POST("/login/totp", authHandler.LoginTOTP)
 	adminGroup.POST("/login/passkey/options", authHandler.LoginPasskeyOptions)
 	adminGroup.POST("/login/passkey/verify", authHandler.LoginPasskeyVerify)
+	adminGroup.GET("/login/oidc/start", authHandler.LoginOIDCStart)
+	adminGroup.GET("/login/oidc/callback", authHandler.LoginOIDCCallback)
 
 	selfAuthed := adminGroup.Group("")
 	selfAuthed.Use(adminAuthMiddleware(db, jwtCfg))
@@ -54,13 +63,34 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	selfAuthed.POST("/mfa/passkey/verify", mfaHandler.FinishPasskeyRegistration)
 	selfAuthed.POST("/mfa/passkey/disable", mfaHandler.DisablePasskey)
 
+	sessionHandler := handlers.NewAdminSessionHandler(db)
+	selfAuthed.GET("/sessions", sessionHandler.List)
+	selfAuthed.DELETE("/sessions/:id", sessionHandler.Revoke)
+
+	systemHandler := handlers.NewSystemHandler()
+	selfAuthed.POST("/system/reload", systemHandler.Reload)
+	selfAuthed.GET("/errors", handlers.ListErrorCodes)
+	selfAuthed.GET("/openapi.json", handlers.OpenAPISpec)
+
 	authed := adminGroup.Group("")
 	authed.Use(adminAuthMiddleware(db, jwtCfg))
 	authed.Use(adminPermissionMiddleware(db))
 
+	// EventSource cannot set headers, so the event stream also takes the JWT from the query.
+	eventStream := adminGroup.Group("")
+	eventStream.Use(queryTokenMiddleware(), adminAuthMiddleware(db, jwtCfg), adminPermissionMiddleware(db))
+	eventHandler := handlers.NewEventHandler(events.Default())
+	eventStream.GET("/events/stream", eventHandler.Stream)
+
+	pendingActionHandler := handlers.NewPendingActionHandler(db)
+	authed.GET("/pending-actions", pendingActionHandler.List)
+	authed.POST("/pending-actions/:id/approve", pendingActionHandler.Approve)
+	authed.POST("/pending-actions/:id/reject", pendingActionHandler.Reject)
+
 	apiKeyHandler := handlers.NewAPIKeyHandler(db)
 	authed.POST("/api-keys", apiKeyHandler.Create)
 	authed.GET("/api-keys", apiKeyHandler.List)
+	authed.GET("/api-keys/idle", apiKeyHandler.Idle)
 	authed.DELETE("/api-keys/:id", apiKeyHandler.Revoke)
 	authed.POST("/users/:id/api-keys", apiKeyHandler.CreateForUser)
 	authed.GET("/users/:id/api-keys", apiKeyHandler.ListByUser)
@@ -69,7 +99,9 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	authed.POST("/provider-api-keys", providerKeyHandler.Create)
 	authed.GET("/provider-api-keys", providerKeyHandler.List)
 	authed.PUT("/provider-api-keys/:id", providerKeyHandler.Update)
-	authed.DELETE("/provider-api-keys/:id", providerKeyHandler.Delete)
+	authed.DELETE("/provider-api-keys/:id", pendingActionHandler.Require(internalsettings.ApprovalActionDeleteProviderAPIKey), providerKeyHandler.Delete)
+	pendingActionHandler.Register(internalsettings.ApprovalActionDeleteProviderAPIKey, providerKeyHandler.DeleteByID)
+	authed.POST("/provider-api-keys/:id/reactivate", providerKeyHandler.Reactivate)
 
 	proxyHandler := handlers.NewProxyHandler(db)
 	authed.POST("/proxies", proxyHandler.Create)
@@ -78,22 +110,46 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	authed.PUT("/proxies/:id", proxyHandler.Update)
 	authed.DELETE("/proxies/:id", proxyHandler.Delete)
 
-	usageHandler := handlers.NewUsageHandler(db)
+	usageHandler := handlers.NewUsageHandler(dbs)
 	authed.GET("/usage", usageHandler.List)
+	authed.POST("/usage/relink-auths", usageHandler.RelinkAuths)
+	authed.POST("/usage/export", usageHandler.Export)
 
-	billingHandler := handlers.NewBillingHandler(db)
+	jobHandler := handlers.NewJobHandler(db)
+	authed.GET("/jobs", jobHandler.List)
+	authed.GET("/jobs/:id", jobHandler.Get)
+	authed.GET("/jobs/:id/download", jobHandler.Download)
+
+	backupHandler := handlers.NewBackupHandler(db, config.LoadBackupDir())
+	authed.POST("/backups", backupHandler.Create)
+	authed.GET("/backups", backupHandler.List)
+	authed.GET("/backups/:name", backupHandler.Download)
+
+	billingHandler := handlers.NewBillingHandler(dbs)
 	authed.GET("/billing/summary", billingHandler.Summary)
 
 	userHandler := handlers.NewUserHandler(db)
 	authed.POST("/users", userHandler.Create)
+	authed.POST("/users/import", userHandler.Import)
 	authed.GET("/users", userHandler.List)
 	authed.GET("/users/:id", userHandler.Get)
 	authed.PUT("/users/:id", userHandler.Update)
-	authed.DELETE("/users/:id", userHandler.Delete)
+	authed.DELETE("/users/:id", pendingActionHandler.Require(internalsettings.ApprovalActionDeleteUser), userHandler.Delete)
+	pendingActionHandler.Register(internalsettings.ApprovalActionDeleteUser, userHandler.DeleteByID)
 	authed.POST("/users/:id/disable", userHandler.Disable)
 	authed.POST("/users/:id/enable", userHandler.Enable)
 	authed.PUT("/users/:id/password", userHandler.ChangePassword)
 
+	impersonationHandler := handlers.NewImpersonationHandler(db, jwtCfg)
+	authed.POST("/users/:id/impersonate", impersonationHandler.Impersonate)
+	userDataHandler := handlers.NewUserDataHandler(db, jwtCfg)
+	authed.POST("/users/:id/export", userDataHandler.Export)
+	authed.POST("/users/:id/purge", userDataHandler.Purge)
+
+	adjustmentHandler := handlers.NewBalanceAdjustmentHandler(db)
+	authed.POST("/users/:id/adjustments", adjustmentHandler.Create)
+	authed.GET("/users/:id/adjustments", adjustmentHandler.List)
+
 	authGroupHandler := handlers.NewAuthGroupHandler(db)
 	authed.POST("/auth-groups", authGroupHandler.Create)
 	authed.GET("/auth-groups", authGroupHandler.List)
@@ -102,19 +158,32 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	authed.DELETE("/auth-groups/:id", authGroupHandler.Delete)
 	authed.POST("/auth-groups/:id/default", authGroupHandler.SetDefault)
 
+	tagHandler := handlers.NewTagHandler(db)
+	authed.GET("/tags", tagHandler.List)
+
 	authFileHandler := handlers.NewAuthFileHandler(db)
 	authed.POST("/auth-files", authFileHandler.Create)
 	authed.POST("/auth-files/import", authFileHandler.Import)
 	authed.GET("/auth-files", authFileHandler.List)
 	authed.GET("/auth-files/:id", authFileHandler.Get)
 	authed.PUT("/auth-files/:id", authFileHandler.Update)
-	authed.DELETE("/auth-files/:id", authFileHandler.Delete)
+	authed.DELETE("/auth-files/:id", pendingActionHandler.Require(internalsettings.ApprovalActionDeleteAuthFile), authFileHandler.Delete)
+	pendingActionHandler.Register(internalsettings.ApprovalActionDeleteAuthFile, authFileHandler.DeleteByID)
 	authed.POST("/auth-files/:id/available", authFileHandler.SetAvailable)
 	authed.POST("/auth-files/:id/unavailable", authFileHandler.SetUnavailable)
 	authed.GET("/auth-files/types", authFileHandler.ListTypes)
+	authed.GET("/auth-files/stats", authFileHandler.StatsOverview)
+	authed.GET("/auth-files/duplicates", authFileHandler.Duplicates)
+	authed.GET("/auth-files/trash", authFileHandler.Trash)
+	authed.POST("/auth-files/:id/restore", authFileHandler.Restore)
+	authed.GET("/auth-files/:id/stats", authFileHandler.Stats)
+	authed.PATCH("/auth-files/priorities", authFileHandler.UpdatePriorities)
+	authed.POST("/auth-files/batch-move-group", authFileHandler.BatchMoveGroup)
+	authed.POST("/auth-files/check", authFileHandler.Check)
 
 	quotaHandler := handlers.NewQuotaHandler(db)
 	authed.GET("/quotas", quotaHandler.List)
+	authed.GET("/quotas/:auth_id/history", quotaHandler.History)
 
 	userGroupHandler := handlers.NewUserGroupHandler(db)
 	authed.POST("/user-groups", userGroupHandler.Create)
@@ -127,6 +196,7 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	billingRuleHandler := handlers.NewBillingRuleHandler(db)
 	authed.POST("/billing-rules", billingRuleHandler.Create)
 	authed.GET("/billing-rules", billingRuleHandler.List)
+	authed.GET("/billing-rules/resolve", billingRuleHandler.Resolve)
 	authed.GET("/billing-rules/:id", billingRuleHandler.Get)
 	authed.PUT("/billing-rules/:id", billingRuleHandler.Update)
 	authed.DELETE("/billing-rules/:id", billingRuleHandler.Delete)
@@ -137,10 +207,17 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	authed.POST("/prepaid-cards", prepaidCardHandler.Create)
 	authed.POST("/prepaid-cards/batch", prepaidCardHandler.BatchCreate)
 	authed.GET("/prepaid-cards", prepaidCardHandler.List)
+	authed.GET("/prepaid-cards/export", prepaidCardHandler.Export)
 	authed.GET("/prepaid-cards/:id", prepaidCardHandler.Get)
 	authed.PUT("/prepaid-cards/:id", prepaidCardHandler.Update)
 	authed.DELETE("/prepaid-cards/:id", prepaidCardHandler.Delete)
 
+	inviteCodeHandler := handlers.NewInviteCodeHandler(db)
+	authed.POST("/invite-codes", inviteCodeHandler.Create)
+	authed.GET("/invite-codes", inviteCodeHandler.List)
+	authed.PUT("/invite-codes/:id", inviteCodeHandler.Update)
+	authed.DELETE("/invite-codes/:id", inviteCodeHandler.Delete)
+
 	adminHandler := handlers.NewAdminHandler(db)
 	authed.POST("/admins", adminHandler.Create)
 	authed.GET("/admins", adminHandler.List)
@@ -167,6 +244,9 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	authed.POST("/model-mappings", modelMappingHandler.Create)
 	authed.GET("/model-mappings", modelMappingHandler.List)
 	authed.GET("/model-mappings/available-models", modelMappingHandler.AvailableModels)
+	authed.GET("/model-mappings/export", modelMappingHandler.Export)
+	authed.GET("/model-mappings/validate", modelMappingHandler.Validate)
+	authed.POST("/model-mappings/import", modelMappingHandler.Import)
 	authed.GET("/model-mappings/:id", modelMappingHandler.Get)
 	authed.PUT("/model-mappings/:id", modelMappingHandler.Update)
 	authed.DELETE("/model-mappings/:id", modelMappingHandler.Delete)
@@ -178,11 +258,23 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	authed.POST("/model-mappings/:id/payload-rules", payloadRuleHandler.Create)
 	authed.PUT("/model-mappings/:id/payload-rules/:rule_id", payloadRuleHandler.Update)
 	authed.DELETE("/model-mappings/:id/payload-rules/:rule_id", payloadRuleHandler.Delete)
+	authed.GET("/model-mappings/:id/payload-rules/:rule_id/preview", payloadRuleHandler.Preview)
+
+	effectiveModelHandler := handlers.NewEffectiveModelHandler(db)
+	authed.GET("/models/effective", effectiveModelHandler.List)
+
+	var coreManager *coreauth.Manager
+	if baseHandler != nil {
+		coreManager = baseHandler.AuthManager
+	}
+	authAvailabilityHandler := handlers.NewAuthAvailabilityHandler(db, coreManager)
+	authed.GET("/models/:model/availability", authAvailabilityHandler.ModelAvailability)
+	authed.POST("/auth-files/:id/clear-cooldown", authAvailabilityHandler.ClearCooldown)
 
 	modelReferenceHandler := handlers.NewModelReferenceHandler(db)
 	authed.GET("/model-references/price", modelReferenceHandler.GetPrice)
 
-	logsHandler := handlers.NewAdminLogsHandler(db)
+	logsHandler := handlers.NewAdminLogsHandler(dbs)
 	authed.GET("/logs", logsHandler.List)
 	authed.GET("/logs/detail", logsHandler.Detail)
 	authed.GET("/logs/stats", logsHandler.Stats)
@@ -206,27 +298,55 @@ func RegisterAdminRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, co
 	authed.PUT("/settings/:key", settingHandler.Update)
 	authed.DELETE("/settings/:key", settingHandler.Delete)
 
-	dashboardHandler := handlers.NewDashboardHandler(db)
+	dashboardHandler := handlers.NewDashboardHandler(dbs)
 	authed.GET("/dashboard/kpi", dashboardHandler.KPI)
 	authed.GET("/dashboard/traffic", dashboardHandler.Traffic)
 	authed.GET("/dashboard/cost-distribution", dashboardHandler.CostDistribution)
+	authed.GET("/dashboard/top-consumers", dashboardHandler.TopConsumers)
+	authed.GET("/dashboard/top-models", dashboardHandler.TopModels)
 	authed.GET("/dashboard/model-health", dashboardHandler.ModelHealth)
 	authed.GET("/dashboard/transactions", dashboardHandler.RecentTransactions)
 
 	if baseHandler != nil && baseHandler.AuthManager != nil {
 		tokenRequester := sdkapi.NewManagementTokenRequester(cfg, baseHandler.AuthManager)
-		authed.POST("/tokens/anthropic", tokenRequester.RequestAnthropicToken)
-		authed.POST("/tokens/gemini", tokenRequester.RequestGeminiCLIToken)
-		authed.POST("/tokens/codex", tokenRequester.RequestCodexToken)
-		authed.POST("/tokens/antigravity", tokenRequester.RequestAntigravityToken)
-		authed.POST("/tokens/qwen", tokenRequester.RequestQwenToken)
-		authed.POST("/tokens/iflow", tokenRequester.RequestIFlowToken)
-		authed.POST("/tokens/iflow-cookie", tokenRequester.RequestIFlowCookieToken)
+		// Provider flows stay registered and are gated per request, so changing
+		// ENABLED_OAUTH_PROVIDERS takes effect without a restart.
+		tokenFlows := authed.Group("", oauthFlowMiddleware())
+		tokenFlows.POST("/tokens/anthropic", tokenRequester.RequestAnthropicToken)
+		tokenFlows.POST("/tokens/gemini", tokenRequester.RequestGeminiCLIToken)
+		tokenFlows.POST("/tokens/codex", tokenRequester.RequestCodexToken)
+		tokenFlows.POST("/tokens/antigravity", tokenRequester.RequestAntigravityToken)
+		tokenFlows.POST("/tokens/qwen", tokenRequester.RequestQwenToken)
+		tokenFlows.POST("/tokens/iflow", tokenRequester.RequestIFlowToken)
+		tokenFlows.POST("/tokens/iflow-cookie", tokenRequester.RequestIFlowCookieToken)
 		authed.POST("/tokens/get-auth-status", tokenRequester.GetAuthStatus)
 		authed.POST("/tokens/oauth-callback", tokenRequester.PostOAuthCallback)
 	}
 }
 
+// oauthFlowMiddleware answers 404 for token-request flows of OAuth providers that
+// ENABLED_OAUTH_PROVIDERS does not list.
+func oauthFlowMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if provider, ok := permissions.TokenFlowProvider(c.FullPath()); ok && !internalsettings.OAuthProviderEnabled(provider) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "oauth provider disabled"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// queryTokenMiddleware lets the access_token query parameter stand in for a missing
+// Authorization header.
+func queryTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := strings.TrimSpace(c.Query("access_token")); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
 // adminAuthMiddleware validates admin JWTs and loads admin context.
 func adminAuthMiddleware(db *gorm.DB, jwtCfg config.JWTConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -253,6 +373,15 @@ func adminAuthMiddleware(db *gorm.DB, jwtCfg config.JWTConfig) gin.HandlerFunc {
 			return
 		}
 
+		if errSession := handlers.ValidateAdminSession(c.Request.Context(), db, claims.ID, claims.AdminID); errSession != nil {
+			if errors.Is(errSession, handlers.ErrAdminSessionInvalid) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session revoked or expired"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "query session failed"})
+			return
+		}
+
 		var admin models.Admin
 		if errFind := db.WithContext(c.Request.Context()).First(&admin, claims.AdminID).Error; errFind != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "admin not found"})
@@ -268,6 +397,7 @@ func adminAuthMiddleware(db *gorm.DB, jwtCfg config.JWTConfig) gin.HandlerFunc {
 		c.Set("adminUsername", admin.Username)
 		c.Set("adminPermissions", adminPermissions)
 		c.Set("adminIsSuperAdmin", admin.IsSuperAdmin)
+		c.Set("adminSessionJTI", claims.ID)
 		c.Next()
 	}
 }
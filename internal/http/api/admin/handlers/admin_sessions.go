@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	"gorm.io/gorm"
+)
+
+// adminSessionCacheTTL bounds how long a session lookup is reused before the DB is checked
+// again. Revocations made by another instance take effect within this window.
+const adminSessionCacheTTL = 30 * time.Second
+
+// adminSessionJTILength is the length of generated session IDs.
+const adminSessionJTILength = 32
+
+// ErrAdminSessionInvalid indicates an admin token whose session is unknown, revoked or expired.
+var ErrAdminSessionInvalid = errors.New("admin session invalid")
+
+// adminSessionCacheEntry is a cached session lookup.
+type adminSessionCacheEntry struct {
+	adminID   uint64
+	expiresAt time.Time
+	revoked   bool
+	checkedAt time.Time
+}
+
+// adminSessions caches session lookups by JTI.
+var adminSessions sync.Map
+
+// adminSessionsSweptAt is when stale cache entries were last dropped, in Unix nanoseconds.
+var adminSessionsSweptAt atomic.Int64
+
+// issueAdminToken records a new session for admin and signs a token carrying its JTI.
+func issueAdminToken(c *gin.Context, db *gorm.DB, jwtCfg config.JWTConfig, admin models.Admin) (string, error) {
+	jti, errJTI := security.GenerateRandomString(adminSessionJTILength)
+	if errJTI != nil {
+		return "", errJTI
+	}
+	now := time.Now().UTC()
+	session := models.AdminSession{
+		JTI:       jti,
+		AdminID:   admin.ID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		ExpiresAt: now.Add(jwtCfg.Expiry),
+		CreatedAt: now,
+	}
+	ctx := c.Request.Context()
+	if errCreate := db.WithContext(ctx).Create(&session).Error; errCreate != nil {
+		return "", errCreate
+	}
+	// Expired sessions are no longer useful; prune them for this admin on each login.
+	_ = db.WithContext(ctx).
+		Where("admin_id = ? AND expires_at < ?", admin.ID, now).
+		Delete(&models.AdminSession{}).Error
+	return security.GenerateAdminToken(jwtCfg.Secret, admin.ID, admin.Username, jti, jwtCfg.Expiry)
+}
+
+// ValidateAdminSession reports whether jti names a live session of adminID. Lookups are
+// cached for adminSessionCacheTTL so authenticated requests do not hit the DB each time.
+func ValidateAdminSession(ctx context.Context, db *gorm.DB, jti string, adminID uint64) error {
+	jti = strings.TrimSpace(jti)
+	if jti == "" || db == nil {
+		return ErrAdminSessionInvalid
+	}
+	now := time.Now().UTC()
+	var entry adminSessionCacheEntry
+	if cached, ok := adminSessions.Load(jti); ok && now.Sub(cached.(adminSessionCacheEntry).checkedAt) < adminSessionCacheTTL {
+		entry = cached.(adminSessionCacheEntry)
+	} else {
+		var session models.AdminSession
+		errFind := db.WithContext(ctx).
+			Select("admin_id", "expires_at", "revoked_at").
+			Where("jti = ?", jti).
+			First(&session).Error
+		if errFind != nil {
+			if errors.Is(errFind, gorm.ErrRecordNotFound) {
+				return ErrAdminSessionInvalid
+			}
+			return errFind
+		}
+		entry = adminSessionCacheEntry{
+			adminID:   session.AdminID,
+			expiresAt: session.ExpiresAt,
+			revoked:   session.RevokedAt != nil,
+			checkedAt: now,
+		}
+		adminSessions.Store(jti, entry)
+		sweepAdminSessions(now)
+	}
+	if entry.revoked || !now.Before(entry.expiresAt) {
+		// A revoked or expired session never becomes valid again.
+		adminSessions.Delete(jti)
+		return ErrAdminSessionInvalid
+	}
+	if entry.adminID != adminID {
+		return ErrAdminSessionInvalid
+	}
+	return nil
+}
+
+// sweepAdminSessions drops cache entries not checked within adminSessionCacheTTL, so
+// tokens that are no longer presented do not stay cached. It runs at most once per TTL.
+func sweepAdminSessions(now time.Time) {
+	last := adminSessionsSweptAt.Load()
+	if now.UnixNano()-last < int64(adminSessionCacheTTL) || !adminSessionsSweptAt.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	adminSessions.Range(func(key, value any) bool {
+		if now.Sub(value.(adminSessionCacheEntry).checkedAt) >= adminSessionCacheTTL {
+			adminSessions.Delete(key)
+		}
+		return true
+	})
+}
+
+// AdminSessionHandler lists and revokes admin login sessions.
+type AdminSessionHandler struct {
+	db *gorm.DB
+}
+
+// NewAdminSessionHandler constructs an AdminSessionHandler.
+func NewAdminSessionHandler(db *gorm.DB) *AdminSessionHandler {
+	return &AdminSessionHandler{db: db}
+}
+
+// adminSessionView is the API representation of a session.
+type adminSessionView struct {
+	ID            uint64    `json:"id"`
+	AdminID       uint64    `json:"admin_id"`
+	AdminUsername string    `json:"admin_username"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	Current       bool      `json:"current"`
+}
+
+// List returns the caller's active sessions. Super admins may pass admin_id to list another
+// admin's sessions, or admin_id=all to list every admin's.
+func (h *AdminSessionHandler) List(c *gin.Context) {
+	adminID := c.GetUint64("adminID")
+	if adminID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	isSuperAdmin := c.GetBool("adminIsSuperAdmin")
+
+	q := h.db.WithContext(c.Request.Context()).Model(&models.AdminSession{}).
+		Preload("Admin").
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now().UTC())
+	switch raw := strings.TrimSpace(c.Query("admin_id")); {
+	case raw == "":
+		q = q.Where("admin_id = ?", adminID)
+	case !isSuperAdmin:
+		c.JSON(http.StatusForbidden, gin.H{"error": "only super admins can list other admins' sessions"})
+		return
+	case raw == "all":
+	default:
+		targetID, errParse := strconv.ParseUint(raw, 10, 64)
+		if errParse != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid admin_id"})
+			return
+		}
+		q = q.Where("admin_id = ?", targetID)
+	}
+
+	var sessions []models.AdminSession
+	if errFind := q.Order("created_at DESC").Find(&sessions).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list sessions failed"})
+		return
+	}
+	currentJTI := c.GetString("adminSessionJTI")
+	out := make([]adminSessionView, 0, len(sessions))
+	for _, session := range sessions {
+		view := adminSessionView{
+			ID:        session.ID,
+			AdminID:   session.AdminID,
+			IP:        session.IP,
+			UserAgent: session.UserAgent,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   session.JTI == currentJTI,
+		}
+		if session.Admin != nil {
+			view.AdminUsername = session.Admin.Username
+		}
+		out = append(out, view)
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": out})
+}
+
+// Revoke invalidates a session. Admins may revoke their own sessions; super admins may
+// revoke any session.
+func (h *AdminSessionHandler) Revoke(c *gin.Context) {
+	adminID := c.GetUint64("adminID")
+	if adminID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var session models.AdminSession
+	if errFind := h.db.WithContext(ctx).First(&session, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query session failed"})
+		return
+	}
+	if session.AdminID != adminID && !c.GetBool("adminIsSuperAdmin") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	if session.RevokedAt == nil {
+		now := time.Now().UTC()
+		if errUpdate := h.db.WithContext(ctx).Model(&models.AdminSession{}).
+			Where("id = ? AND revoked_at IS NULL", session.ID).
+			Update("revoked_at", now).Error; errUpdate != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "revoke session failed"})
+			return
+		}
+	}
+	adminSessions.Delete(session.JTI)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	"gorm.io/gorm"
+)
+
+func setupAdminSessions(t *testing.T) (*gorm.DB, models.Admin, models.Admin) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:admin_sessions_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Admin{}, &models.AdminSession{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	alice := models.Admin{Username: "alice", Password: "x", Active: true, Permissions: []byte("[]")}
+	root := models.Admin{Username: "root", Password: "x", Active: true, IsSuperAdmin: true, Permissions: []byte("[]")}
+	for _, admin := range []*models.Admin{&alice, &root} {
+		if errCreate := db.Create(admin).Error; errCreate != nil {
+			t.Fatalf("create admin: %v", errCreate)
+		}
+	}
+	return db, alice, root
+}
+
+// loginAdmin issues a token for admin and returns its session JTI.
+func loginAdmin(t *testing.T, db *gorm.DB, admin models.Admin) string {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/admin/login", nil)
+	c.Request.Header.Set("User-Agent", "test-agent")
+	token, errToken := issueAdminToken(c, db, config.JWTConfig{Secret: "secret", Expiry: time.Hour}, admin)
+	if errToken != nil {
+		t.Fatalf("issue token: %v", errToken)
+	}
+	claims, errParse := security.ParseAdminToken("secret", token)
+	if errParse != nil || claims.ID == "" {
+		t.Fatalf("expected token with jti, got %+v %v", claims, errParse)
+	}
+	return claims.ID
+}
+
+// sessionRouter serves the session endpoints as the given admin.
+func sessionRouter(db *gorm.DB, admin models.Admin, jti string) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("adminID", admin.ID)
+		c.Set("adminIsSuperAdmin", admin.IsSuperAdmin)
+		c.Set("adminSessionJTI", jti)
+		c.Next()
+	})
+	h := NewAdminSessionHandler(db)
+	r.GET("/v0/admin/sessions", h.List)
+	r.DELETE("/v0/admin/sessions/:id", h.Revoke)
+	return r
+}
+
+func sessionIDByJTI(t *testing.T, db *gorm.DB, jti string) uint64 {
+	t.Helper()
+	var session models.AdminSession
+	if errFind := db.Where("jti = ?", jti).First(&session).Error; errFind != nil {
+		t.Fatalf("find session: %v", errFind)
+	}
+	return session.ID
+}
+
+func TestAdminSessionRevokeInvalidatesToken(t *testing.T) {
+	db, alice, _ := setupAdminSessions(t)
+	current := loginAdmin(t, db, alice)
+	other := loginAdmin(t, db, alice)
+	ctx := context.Background()
+
+	if errSession := ValidateAdminSession(ctx, db, other, alice.ID); errSession != nil {
+		t.Fatalf("expected live session, got %v", errSession)
+	}
+	if errSession := ValidateAdminSession(ctx, db, "unknown", alice.ID); !errors.Is(errSession, ErrAdminSessionInvalid) {
+		t.Fatalf("expected unknown jti to be rejected, got %v", errSession)
+	}
+
+	r := sessionRouter(db, alice, current)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/sessions", nil))
+	var listed struct {
+		Sessions []adminSessionView `json:"sessions"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &listed); errDecode != nil || len(listed.Sessions) != 2 {
+		t.Fatalf("expected two sessions, got %d: %s", w.Code, w.Body.String())
+	}
+	currentCount := 0
+	for _, session := range listed.Sessions {
+		if session.Current {
+			currentCount++
+		}
+		if session.UserAgent != "test-agent" {
+			t.Fatalf("expected recorded user agent, got %+v", session)
+		}
+	}
+	if currentCount != 1 {
+		t.Fatalf("expected exactly one current session, got %d", currentCount)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/v0/admin/sessions/%d", sessionIDByJTI(t, db, other)), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected revoke, got %d: %s", w.Code, w.Body.String())
+	}
+	// The cached lookup from above must not keep the revoked token alive.
+	if errSession := ValidateAdminSession(ctx, db, other, alice.ID); !errors.Is(errSession, ErrAdminSessionInvalid) {
+		t.Fatalf("expected revoked session to be rejected, got %v", errSession)
+	}
+	if errSession := ValidateAdminSession(ctx, db, current, alice.ID); errSession != nil {
+		t.Fatalf("expected current session to stay live, got %v", errSession)
+	}
+}
+
+func TestAdminSessionOwnershipRules(t *testing.T) {
+	db, alice, root := setupAdminSessions(t)
+	aliceJTI := loginAdmin(t, db, alice)
+	rootJTI := loginAdmin(t, db, root)
+
+	aliceRouter := sessionRouter(db, alice, aliceJTI)
+	w := httptest.NewRecorder()
+	aliceRouter.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/v0/admin/sessions/%d", sessionIDByJTI(t, db, rootJTI)), nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected admin to be unable to revoke another admin's session, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	aliceRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/sessions?admin_id=all", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected admin to be unable to list all sessions, got %d", w.Code)
+	}
+
+	rootRouter := sessionRouter(db, root, rootJTI)
+	w = httptest.NewRecorder()
+	rootRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/sessions?admin_id=all", nil))
+	var listed struct {
+		Sessions []adminSessionView `json:"sessions"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &listed); errDecode != nil || len(listed.Sessions) != 2 {
+		t.Fatalf("expected super admin to list every session, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	rootRouter.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/v0/admin/sessions/%d", sessionIDByJTI(t, db, aliceJTI)), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected super admin to revoke any session, got %d: %s", w.Code, w.Body.String())
+	}
+	if errSession := ValidateAdminSession(context.Background(), db, aliceJTI, alice.ID); !errors.Is(errSession, ErrAdminSessionInvalid) {
+		t.Fatalf("expected revoked session to be rejected, got %v", errSession)
+	}
+}
+
+func TestAdminSessionCacheDropsDeadEntries(t *testing.T) {
+	db, alice, _ := setupAdminSessions(t)
+	ctx := context.Background()
+	live := loginAdmin(t, db, alice)
+	expired := loginAdmin(t, db, alice)
+	if errUpdate := db.Model(&models.AdminSession{}).Where("jti = ?", expired).
+		Update("expires_at", time.Now().UTC().Add(-time.Minute)).Error; errUpdate != nil {
+		t.Fatalf("expire session: %v", errUpdate)
+	}
+	stale := "stale-" + live
+	adminSessions.Store(stale, adminSessionCacheEntry{adminID: alice.ID, checkedAt: time.Now().UTC().Add(-2 * adminSessionCacheTTL)})
+	adminSessionsSweptAt.Store(0)
+
+	if errSession := ValidateAdminSession(ctx, db, expired, alice.ID); !errors.Is(errSession, ErrAdminSessionInvalid) {
+		t.Fatalf("expected expired session to be rejected, got %v", errSession)
+	}
+	if _, ok := adminSessions.Load(expired); ok {
+		t.Fatal("expected the expired session to leave the cache")
+	}
+	if _, ok := adminSessions.Load(stale); ok {
+		t.Fatal("expected an entry unchecked for a TTL to be swept")
+	}
+	if errSession := ValidateAdminSession(ctx, db, live, alice.ID); errSession != nil {
+		t.Fatalf("expected live session, got %v", errSession)
+	}
+	if _, ok := adminSessions.Load(live); !ok {
+		t.Fatal("expected the live session to stay cached")
+	}
+}
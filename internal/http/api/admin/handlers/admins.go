@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
@@ -29,6 +30,7 @@ func NewAdminHandler(db *gorm.DB) *AdminHandler {
 // createAdminRequest defines the request body for admin creation.
 type createAdminRequest struct {
 	Username     string   `json:"username"`
+	Email        string   `json:"email"`
 	Password     string   `json:"password"`
 	Permissions  []string `json:"permissions"`
 	IsSuperAdmin bool     `json:"is_super_admin"`
@@ -51,6 +53,20 @@ func (h *AdminHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing password"})
 		return
 	}
+	if taken, errTaken := dbutil.IdentityTaken(c.Request.Context(), h.db, &models.Admin{}, "username", username, 0); errTaken != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+		return
+	}
+	email := normalizeAdminEmail(body.Email)
+	if email != "" {
+		if errEmail := h.ensureAdminEmailAvailable(c.Request.Context(), email, 0); errEmail != nil {
+			respondAdminEmailError(c, errEmail)
+			return
+		}
+	}
 
 	hash, errHash := security.HashPassword(password)
 	if errHash != nil {
@@ -63,6 +79,14 @@ func (h *AdminHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid permissions"})
 		return
 	}
+	if body.IsSuperAdmin && !c.GetBool("adminIsSuperAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only super admins can create super admins"})
+		return
+	}
+	if missing := ungrantablePermissions(c, normalizedPermissions, nil); len(missing) > 0 {
+		respondUngrantablePermissions(c, missing)
+		return
+	}
 	permissionsJSON, errMarshal := permissions.MarshalPermissions(normalizedPermissions)
 	if errMarshal != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "marshal permissions failed"})
@@ -72,6 +96,7 @@ func (h *AdminHandler) Create(c *gin.Context) {
 	now := time.Now().UTC()
 	admin := models.Admin{
 		Username:     username,
+		Email:        email,
 		Password:     hash,
 		Active:       true,
 		IsSuperAdmin: body.IsSuperAdmin,
@@ -80,12 +105,17 @@ func (h *AdminHandler) Create(c *gin.Context) {
 		UpdatedAt:    now,
 	}
 	if errCreate := h.db.WithContext(c.Request.Context()).Create(&admin).Error; errCreate != nil {
+		if dbutil.IsUniqueViolation(errCreate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create admin failed"})
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{
 		"id":             admin.ID,
 		"username":       admin.Username,
+		"email":          admin.Email,
 		"active":         admin.Active,
 		"is_super_admin": admin.IsSuperAdmin,
 		"permissions":    permissions.ParsePermissions(admin.Permissions),
@@ -120,6 +150,7 @@ func (h *AdminHandler) List(c *gin.Context) {
 		out = append(out, gin.H{
 			"id":             row.ID,
 			"username":       row.Username,
+			"email":          row.Email,
 			"active":         row.Active,
 			"is_super_admin": row.IsSuperAdmin,
 			"permissions":    permissions.ParsePermissions(row.Permissions),
@@ -149,6 +180,7 @@ func (h *AdminHandler) Get(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"id":             admin.ID,
 		"username":       admin.Username,
+		"email":          admin.Email,
 		"active":         admin.Active,
 		"is_super_admin": admin.IsSuperAdmin,
 		"permissions":    permissions.ParsePermissions(admin.Permissions),
@@ -160,6 +192,7 @@ func (h *AdminHandler) Get(c *gin.Context) {
 // updateAdminRequest defines the request body for admin updates.
 type updateAdminRequest struct {
 	Username     *string   `json:"username"`
+	Email        *string   `json:"email"`
 	Permissions  *[]string `json:"permissions"`
 	IsSuperAdmin *bool     `json:"is_super_admin"`
 }
@@ -177,6 +210,34 @@ func (h *AdminHandler) Update(c *gin.Context) {
 		return
 	}
 
+	actorIsSuperAdmin := c.GetBool("adminIsSuperAdmin")
+	var target models.Admin
+	if errFind := h.db.WithContext(c.Request.Context()).Select("id", "permissions", "is_super_admin").First(&target, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	if !actorIsSuperAdmin {
+		if target.IsSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only super admins can modify super admins"})
+			return
+		}
+		if body.IsSuperAdmin != nil && *body.IsSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only super admins can grant super admin"})
+			return
+		}
+	}
+	// Username and email decide which account an SSO login binds to, so only admins that
+	// hold every permission of the target may change them.
+	if body.Username != nil || body.Email != nil {
+		if !ensureAdminManageable(c, target) {
+			return
+		}
+	}
+
 	updates := map[string]any{"updated_at": time.Now().UTC()}
 	if body.Username != nil {
 		username := strings.TrimSpace(*body.Username)
@@ -184,14 +245,35 @@ func (h *AdminHandler) Update(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "username cannot be empty"})
 			return
 		}
+		if taken, errTaken := dbutil.IdentityTaken(c.Request.Context(), h.db, &models.Admin{}, "username", username, id); errTaken != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+			return
+		} else if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+			return
+		}
 		updates["username"] = username
 	}
+	if body.Email != nil {
+		email := normalizeAdminEmail(*body.Email)
+		if email != "" {
+			if errEmail := h.ensureAdminEmailAvailable(c.Request.Context(), email, id); errEmail != nil {
+				respondAdminEmailError(c, errEmail)
+				return
+			}
+		}
+		updates["email"] = email
+	}
 	if body.Permissions != nil {
 		normalizedPermissions := permissions.NormalizePermissions(*body.Permissions)
 		if errValidate := permissions.ValidatePermissions(normalizedPermissions); errValidate != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid permissions"})
 			return
 		}
+		if missing := ungrantablePermissions(c, normalizedPermissions, permissions.ParsePermissions(target.Permissions)); len(missing) > 0 {
+			respondUngrantablePermissions(c, missing)
+			return
+		}
 		permissionsJSON, errMarshal := permissions.MarshalPermissions(normalizedPermissions)
 		if errMarshal != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "marshal permissions failed"})
@@ -222,6 +304,9 @@ func (h *AdminHandler) Delete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	if _, ok := h.loadManageableAdmin(c, id); !ok {
+		return
+	}
 	res := h.db.WithContext(c.Request.Context()).Delete(&models.Admin{}, id)
 	if res.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
@@ -241,6 +326,9 @@ func (h *AdminHandler) Disable(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	if _, ok := h.loadManageableAdmin(c, id); !ok {
+		return
+	}
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Admin{}).
 		Where("id = ?", id).
 		Updates(map[string]any{"active": false, "updated_at": time.Now().UTC()})
@@ -262,6 +350,9 @@ func (h *AdminHandler) Enable(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	if _, ok := h.loadManageableAdmin(c, id); !ok {
+		return
+	}
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Admin{}).
 		Where("id = ?", id).
 		Updates(map[string]any{"active": true, "updated_at": time.Now().UTC()})
@@ -298,20 +389,15 @@ func (h *AdminHandler) ChangePassword(c *gin.Context) {
 	oldPassword := strings.TrimSpace(body.OldPassword)
 	newPassword := strings.TrimSpace(body.NewPassword)
 	password := strings.TrimSpace(body.Password)
+	admin, ok := h.loadManageableAdmin(c, id)
+	if !ok {
+		return
+	}
 	if oldPassword != "" || newPassword != "" {
 		if oldPassword == "" || newPassword == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "missing password"})
 			return
 		}
-		var admin models.Admin
-		if errFind := h.db.WithContext(c.Request.Context()).Select("id", "password").First(&admin, id).Error; errFind != nil {
-			if errors.Is(errFind, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
-			return
-		}
 		if !security.CheckPassword(admin.Password, oldPassword) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 			return
@@ -340,3 +426,106 @@ func (h *AdminHandler) ChangePassword(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
+
+// ungrantablePermissions returns the permissions in perms that the acting admin may not
+// grant: those it does not hold itself, unless the target already has them. Super admins
+// may grant every permission.
+func ungrantablePermissions(c *gin.Context, perms, targetPerms []string) []string {
+	if c.GetBool("adminIsSuperAdmin") {
+		return nil
+	}
+	held, _ := c.Get("adminPermissions")
+	actorPerms, _ := held.([]string)
+	granted := permissions.Missing(perms, targetPerms)
+	return permissions.Missing(granted, actorPerms)
+}
+
+// loadManageableAdmin loads admin id and checks the acting admin may manage it. On failure it
+// writes the error response and returns false.
+func (h *AdminHandler) loadManageableAdmin(c *gin.Context, id uint64) (models.Admin, bool) {
+	var target models.Admin
+	if errFind := h.db.WithContext(c.Request.Context()).
+		Select("id", "password", "permissions", "is_super_admin").
+		First(&target, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return models.Admin{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return models.Admin{}, false
+	}
+	if !ensureAdminManageable(c, target) {
+		return models.Admin{}, false
+	}
+	return target, true
+}
+
+// ensureAdminManageable rejects with 403 unless the acting admin is a super admin, or target is
+// not a super admin and holds no permission the acting admin lacks.
+func ensureAdminManageable(c *gin.Context, target models.Admin) bool {
+	if c.GetBool("adminIsSuperAdmin") {
+		return true
+	}
+	if target.IsSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only super admins can manage super admins"})
+		return false
+	}
+	if missing := ungrantablePermissions(c, permissions.ParsePermissions(target.Permissions), nil); len(missing) > 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":       "cannot manage an admin holding permissions you do not hold",
+			"permissions": missing,
+		})
+		return false
+	}
+	return true
+}
+
+// respondUngrantablePermissions rejects an attempt to grant permissions the acting admin
+// does not hold.
+func respondUngrantablePermissions(c *gin.Context, missing []string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":       "cannot grant permissions you do not hold",
+		"permissions": missing,
+	})
+}
+
+// errAdminEmailInvalid and errAdminEmailTaken report rejected admin email addresses.
+var (
+	errAdminEmailInvalid = errors.New("invalid email")
+	errAdminEmailTaken   = errors.New("email already in use")
+)
+
+// normalizeAdminEmail trims and lowercases an admin email address.
+func normalizeAdminEmail(raw string) string {
+	return strings.ToLower(strings.TrimSpace(raw))
+}
+
+// ensureAdminEmailAvailable validates email and checks no other admin uses it.
+func (h *AdminHandler) ensureAdminEmailAvailable(ctx context.Context, email string, excludeID uint64) error {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 || strings.ContainsAny(email, " \t") {
+		return errAdminEmailInvalid
+	}
+	var count int64
+	if errCount := h.db.WithContext(ctx).Model(&models.Admin{}).
+		Where("email = ? AND id <> ?", email, excludeID).
+		Count(&count).Error; errCount != nil {
+		return errCount
+	}
+	if count > 0 {
+		return errAdminEmailTaken
+	}
+	return nil
+}
+
+// respondAdminEmailError writes the response for an ensureAdminEmailAvailable failure.
+func respondAdminEmailError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errAdminEmailInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+	case errors.Is(err, errAdminEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "email already in use"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+	}
+}
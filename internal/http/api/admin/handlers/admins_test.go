@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/permissions"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestAdminHandlerRejectsPermissionEscalation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:admin_escalation_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Admin{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	updateAdmin := permissions.Key("PUT", "/v0/admin/admins/:id")
+	createAdmin := permissions.Key("POST", "/v0/admin/admins")
+	listUsers := permissions.Key("GET", "/v0/admin/users")
+	listBills := permissions.Key("GET", "/v0/admin/bills")
+	actorPerms := []string{createAdmin, updateAdmin, listUsers}
+
+	actor := models.Admin{Username: "actor", Password: "x", Active: true, Permissions: []byte(`["` + strings.Join(actorPerms, `","`) + `"]`)}
+	peer := models.Admin{Username: "peer", Password: "x", Active: true, Permissions: []byte(`["` + listBills + `"]`)}
+	root := models.Admin{Username: "root", Password: "x", Active: true, IsSuperAdmin: true, Permissions: []byte("[]")}
+	junior := models.Admin{Username: "junior", Password: "x", Active: true, Permissions: []byte(`["` + listUsers + `"]`)}
+	for _, admin := range []*models.Admin{&actor, &peer, &root, &junior} {
+		if errCreate := db.Create(admin).Error; errCreate != nil {
+			t.Fatalf("create admin: %v", errCreate)
+		}
+	}
+
+	h := NewAdminHandler(db)
+	newRouter := func(id uint64, perms []string, super bool) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("adminID", id)
+			c.Set("adminPermissions", perms)
+			c.Set("adminIsSuperAdmin", super)
+		})
+		r.POST("/v0/admin/admins", h.Create)
+		r.PUT("/v0/admin/admins/:id", h.Update)
+		r.PUT("/v0/admin/admins/:id/password", h.ChangePassword)
+		r.DELETE("/v0/admin/admins/:id", h.Delete)
+		r.POST("/v0/admin/admins/:id/disable", h.Disable)
+		r.POST("/v0/admin/admins/:id/enable", h.Enable)
+		return r
+	}
+	send := func(r *gin.Engine, method, path, body string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	asActor := newRouter(actor.ID, actorPerms, false)
+	asRoot := newRouter(root.ID, nil, true)
+	permsJSON := func(perms ...string) string {
+		return `{"permissions":["` + strings.Join(perms, `","`) + `"]}`
+	}
+
+	cases := []struct {
+		name   string
+		r      *gin.Engine
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"grant self a missing permission", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d", actor.ID), permsJSON(updateAdmin, listBills), http.StatusForbidden},
+		{"promote self to super admin", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d", actor.ID), `{"is_super_admin":true}`, http.StatusForbidden},
+		{"create a super admin", asActor, "POST", "/v0/admin/admins", `{"username":"x","password":"pw","is_super_admin":true}`, http.StatusForbidden},
+		{"create an admin with a missing permission", asActor, "POST", "/v0/admin/admins", `{"username":"y","password":"pw","permissions":["` + listBills + `"]}`, http.StatusForbidden},
+		{"modify a super admin", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d", root.ID), `{"email":"me@example.com"}`, http.StatusForbidden},
+		{"reset a super admin password", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d/password", root.ID), `{"password":"pw"}`, http.StatusForbidden},
+		{"delete a super admin", asActor, "DELETE", fmt.Sprintf("/v0/admin/admins/%d", root.ID), "", http.StatusForbidden},
+		{"disable a super admin", asActor, "POST", fmt.Sprintf("/v0/admin/admins/%d/disable", root.ID), "", http.StatusForbidden},
+		{"enable a super admin", asActor, "POST", fmt.Sprintf("/v0/admin/admins/%d/enable", root.ID), "", http.StatusForbidden},
+		{"delete a more privileged admin", asActor, "DELETE", fmt.Sprintf("/v0/admin/admins/%d", peer.ID), "", http.StatusForbidden},
+		{"disable a more privileged admin", asActor, "POST", fmt.Sprintf("/v0/admin/admins/%d/disable", peer.ID), "", http.StatusForbidden},
+		{"enable a more privileged admin", asActor, "POST", fmt.Sprintf("/v0/admin/admins/%d/enable", peer.ID), "", http.StatusForbidden},
+		{"reset a more privileged admin password", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d/password", peer.ID), `{"password":"pw"}`, http.StatusForbidden},
+		{"rename a more privileged admin", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d", peer.ID), `{"username":"peer2"}`, http.StatusForbidden},
+		{"change a more privileged admin email", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d", peer.ID), `{"email":"actor@example.com"}`, http.StatusForbidden},
+		{"keep a peer permission the actor lacks", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d", peer.ID), permsJSON(listBills, listUsers), http.StatusOK},
+		{"create an admin with held permissions", asActor, "POST", "/v0/admin/admins", `{"username":"z","password":"pw","permissions":["` + listUsers + `"]}`, http.StatusCreated},
+		{"disable a less privileged admin", asActor, "POST", fmt.Sprintf("/v0/admin/admins/%d/disable", junior.ID), "", http.StatusOK},
+		{"reset a less privileged admin password", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d/password", junior.ID), `{"password":"pw"}`, http.StatusOK},
+		{"change a less privileged admin email", asActor, "PUT", fmt.Sprintf("/v0/admin/admins/%d", junior.ID), `{"email":"junior@example.com"}`, http.StatusOK},
+		{"super admin disables a super admin", asRoot, "POST", fmt.Sprintf("/v0/admin/admins/%d/disable", root.ID), "", http.StatusOK},
+		{"super admin grants anything", asRoot, "PUT", fmt.Sprintf("/v0/admin/admins/%d", actor.ID), `{"is_super_admin":true,"permissions":["` + listBills + `"]}`, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if got := send(tc.r, tc.method, tc.path, tc.body); got != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, got)
+		}
+	}
+
+	var reloaded models.Admin
+	if errFind := db.First(&reloaded, peer.ID).Error; errFind != nil {
+		t.Fatalf("reload peer: %v", errFind)
+	}
+	if got := permissions.ParsePermissions(reloaded.Permissions); len(got) != 2 {
+		t.Fatalf("expected the peer to keep its permission and gain a held one, got %v", got)
+	}
+}
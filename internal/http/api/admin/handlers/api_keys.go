@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
 	"gorm.io/gorm"
@@ -151,10 +152,93 @@ func (h *APIKeyHandler) ListByUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"api_keys": out})
 }
 
-// List returns all API keys.
+// apiKeyListQuery defines paging, filters and sorting for the admin API key list.
+type apiKeyListQuery struct {
+	Page        int    `form:"page,default=1"`       // Page number.
+	PageSize    int    `form:"page_size,default=20"` // Page size.
+	Keyword     string `form:"keyword"`              // Key name or owner username/email keyword.
+	UserID      string `form:"user_id"`              // Owning user filter.
+	UserGroupID string `form:"user_group_id"`        // Owner user group filter.
+	Sort        string `form:"sort"`                 // Sort field: created_at or last_used_at.
+	Order       string `form:"order"`                // Sort direction: asc or desc.
+}
+
+// List returns API keys with paging, optional filters and sorting.
 func (h *APIKeyHandler) List(c *gin.Context) {
+	var lq apiKeyListQuery
+	if errBind := c.ShouldBindQuery(&lq); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+		return
+	}
+	if lq.Page < 1 {
+		lq.Page = 1
+	}
+	if lq.PageSize < 1 || lq.PageSize > 100 {
+		lq.PageSize = 20
+	}
+	sortField := strings.ToLower(strings.TrimSpace(lq.Sort))
+	if sortField != "last_used_at" {
+		sortField = "created_at"
+	}
+	sortOrder := strings.ToLower(strings.TrimSpace(lq.Order))
+	if sortOrder != "asc" {
+		sortOrder = "desc"
+	}
+
+	keywordQ := strings.TrimSpace(lq.Keyword)
+	var userID uint64
+	if raw := strings.TrimSpace(lq.UserID); raw != "" {
+		parsed, errParse := strconv.ParseUint(raw, 10, 64)
+		if errParse != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		userID = parsed
+	}
+	var groupID uint64
+	if raw := strings.TrimSpace(lq.UserGroupID); raw != "" {
+		parsed, errParse := strconv.ParseUint(raw, 10, 64)
+		if errParse != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_group_id"})
+			return
+		}
+		groupID = parsed
+	}
+
+	q := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{})
+	if keywordQ != "" {
+		pattern := dbutil.NormalizeLikePattern(h.db, "%"+keywordQ+"%")
+		owners := h.db.Model(&models.User{}).Select("id").Where(
+			dbutil.CaseInsensitiveLikeExpr(h.db, "username")+" OR "+dbutil.CaseInsensitiveLikeExpr(h.db, "email"),
+			pattern,
+			pattern,
+		)
+		q = q.Where(dbutil.CaseInsensitiveLikeExpr(h.db, "name")+" OR user_id IN (?)", pattern, owners)
+	}
+	if userID > 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	if groupID > 0 {
+		members := h.db.Model(&models.User{}).Select("id").
+			Where(dbutil.JSONArrayContainsExpr(h.db, "user_group_id"), dbutil.JSONArrayContainsValue(h.db, groupID))
+		q = q.Where("user_id IN (?)", members)
+	}
+
+	var total int64
+	if errCount := q.Count(&total).Error; errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "count api keys failed"})
+		return
+	}
+
+	orderBy := sortField + " " + strings.ToUpper(sortOrder)
+	if sortField == "last_used_at" {
+		orderBy += " NULLS LAST"
+	}
+	orderBy += ", id " + strings.ToUpper(sortOrder)
+
 	var rows []models.APIKey
-	if errFind := h.db.WithContext(c.Request.Context()).Order("created_at DESC").Find(&rows).Error; errFind != nil {
+	offset := (lq.Page - 1) * lq.PageSize
+	if errFind := q.Order(orderBy).Offset(offset).Limit(lq.PageSize).Find(&rows).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "list api keys failed"})
 		return
 	}
@@ -162,6 +246,7 @@ func (h *APIKeyHandler) List(c *gin.Context) {
 	for _, row := range rows {
 		out = append(out, gin.H{
 			"id":           row.ID,
+			"user_id":      row.UserID,
 			"name":         row.Name,
 			"admin":        row.IsAdmin,
 			"active":       row.Active,
@@ -171,7 +256,107 @@ func (h *APIKeyHandler) List(c *gin.Context) {
 			"updated_at":   row.UpdatedAt,
 		})
 	}
-	c.JSON(http.StatusOK, gin.H{"api_keys": out})
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys":  out,
+		"total":     total,
+		"page":      lq.Page,
+		"page_size": lq.PageSize,
+		"filters": gin.H{
+			"keyword":       keywordQ,
+			"user_id":       userID,
+			"user_group_id": groupID,
+			"sort":          sortField,
+			"order":         sortOrder,
+		},
+	})
+}
+
+// Idle key report defaults and bounds.
+const (
+	// defaultIdleAPIKeyDays is the idle window used when days is omitted.
+	defaultIdleAPIKeyDays = 30
+	// maxIdleAPIKeyDays caps the idle window.
+	maxIdleAPIKeyDays = 3650
+)
+
+// idleAPIKeyQuery defines the idle window and paging for the idle key report.
+type idleAPIKeyQuery struct {
+	Days     int `form:"days"`                 // Idle window in days.
+	Page     int `form:"page,default=1"`       // Page number.
+	PageSize int `form:"page_size,default=50"` // Page size.
+}
+
+// Idle lists active keys with no usage in the last N days together with their owners.
+// Keys that were never used count as idle once they are older than the window.
+func (h *APIKeyHandler) Idle(c *gin.Context) {
+	var iq idleAPIKeyQuery
+	if errBind := c.ShouldBindQuery(&iq); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+		return
+	}
+	if _, ok := c.GetQuery("days"); !ok {
+		iq.Days = defaultIdleAPIKeyDays
+	}
+	if iq.Days < 1 || iq.Days > maxIdleAPIKeyDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+		return
+	}
+	if iq.Page < 1 {
+		iq.Page = 1
+	}
+	if iq.PageSize < 1 || iq.PageSize > 200 {
+		iq.PageSize = 50
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -iq.Days)
+	q := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+		Where("active = ? AND revoked_at IS NULL", true).
+		Where("(last_used_at < ? OR (last_used_at IS NULL AND created_at < ?))", cutoff, cutoff)
+
+	var total int64
+	if errCount := q.Count(&total).Error; errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "count api keys failed"})
+		return
+	}
+	var rows []models.APIKey
+	offset := (iq.Page - 1) * iq.PageSize
+	if errFind := q.Preload("User").
+		Order("last_used_at ASC NULLS FIRST, created_at ASC, id ASC").
+		Offset(offset).Limit(iq.PageSize).
+		Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list api keys failed"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		var owner gin.H
+		if row.User != nil {
+			owner = gin.H{
+				"id":       row.User.ID,
+				"username": row.User.Username,
+				"email":    row.User.Email,
+				"disabled": row.User.Disabled,
+			}
+		}
+		out = append(out, gin.H{
+			"id":           row.ID,
+			"user_id":      row.UserID,
+			"owner":        owner,
+			"name":         row.Name,
+			"admin":        row.IsAdmin,
+			"last_used_at": row.LastUsedAt,
+			"created_at":   row.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys":  out,
+		"total":     total,
+		"page":      iq.Page,
+		"page_size": iq.PageSize,
+		"days":      iq.Days,
+		"cutoff":    cutoff,
+	})
 }
 
 // Revoke revokes an API key by ID.
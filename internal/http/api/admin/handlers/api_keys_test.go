@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestAPIKeyIdleReportListsUnusedKeysWithOwners(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupUserListDB(t)
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	now := time.Now().UTC()
+	old := now.AddDate(0, 0, -40)
+	recent := now.AddDate(0, 0, -2)
+	revoked := now.AddDate(0, 0, -1)
+	keys := []models.APIKey{
+		{UserID: &user.ID, Name: "stale", APIKey: "sk-stale", Active: true, LastUsedAt: &old, CreatedAt: old},
+		{UserID: &user.ID, Name: "never", APIKey: "sk-never", Active: true, CreatedAt: old},
+		{UserID: &user.ID, Name: "fresh", APIKey: "sk-fresh", Active: true, LastUsedAt: &recent, CreatedAt: old},
+		{UserID: &user.ID, Name: "new", APIKey: "sk-new", Active: true, CreatedAt: recent},
+		{UserID: &user.ID, Name: "revoked", APIKey: "sk-revoked", Active: true, LastUsedAt: &old, RevokedAt: &revoked, CreatedAt: old},
+	}
+	if errCreate := db.Create(&keys).Error; errCreate != nil {
+		t.Fatalf("create keys: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.GET("/v0/admin/api-keys/idle", NewAPIKeyHandler(db).Idle)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/api-keys/idle?days=30", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Total   int64 `json:"total"`
+		APIKeys []struct {
+			Name  string `json:"name"`
+			Owner *struct {
+				Username string `json:"username"`
+				Email    string `json:"email"`
+			} `json:"owner"`
+		} `json:"api_keys"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode response: %v", errDecode)
+	}
+	if resp.Total != 2 || len(resp.APIKeys) != 2 {
+		t.Fatalf("expected 2 idle keys, got %s", w.Body.String())
+	}
+	if resp.APIKeys[0].Name != "never" || resp.APIKeys[1].Name != "stale" {
+		t.Fatalf("unexpected idle keys %s", w.Body.String())
+	}
+	if resp.APIKeys[0].Owner == nil || resp.APIKeys[0].Owner.Email != "alice@example.com" {
+		t.Fatalf("expected owner details, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/api-keys/idle?days=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid days to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	internalauth "github.com/router-for-me/CLIProxyAPIBusiness/internal/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
+	"gorm.io/gorm"
+)
+
+// authRuntime is the subset of the SDK auth manager used here.
+type authRuntime interface {
+	List() []*coreauth.Auth
+	GetByID(id string) (*coreauth.Auth, bool)
+}
+
+// AuthAvailabilityHandler explains why auths are skipped for a model and clears
+// cooldowns once the upstream account is fixed.
+type AuthAvailabilityHandler struct {
+	db       *gorm.DB
+	runtime  authRuntime
+	dispatch func(*coreauth.Auth) bool
+	now      func() time.Time
+}
+
+// NewAuthAvailabilityHandler constructs an AuthAvailabilityHandler backed by the core
+// auth manager, which holds the runtime cooldown state.
+func NewAuthAvailabilityHandler(db *gorm.DB, manager *coreauth.Manager) *AuthAvailabilityHandler {
+	h := &AuthAvailabilityHandler{db: db, dispatch: watcher.DispatchAuthModify, now: time.Now}
+	if manager != nil {
+		h.runtime = manager
+	}
+	return h
+}
+
+// clearCooldownRequest optionally limits the reset to one model.
+type clearCooldownRequest struct {
+	Model string `json:"model"` // Model whose state is reset; empty resets the whole auth.
+}
+
+// ModelAvailability lists each auth serving the model with its block reason and the
+// time it can be retried.
+func (h *AuthAvailabilityHandler) ModelAvailability(c *gin.Context) {
+	model := strings.TrimSpace(c.Param("model"))
+	if model == "" {
+		apierror.Write(c, apierror.Validation("model", "model is required"))
+		return
+	}
+	if h.runtime == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "auth manager not running"})
+		return
+	}
+
+	now := h.now().UTC()
+	auths := internalauth.ExplainModelAvailability(h.runtime.List(), model, now)
+	available := 0
+	var earliest *time.Time
+	for _, entry := range auths {
+		if entry.Available {
+			available++
+			continue
+		}
+		if entry.NextRetryAt != nil && (earliest == nil || entry.NextRetryAt.Before(*earliest)) {
+			earliest = entry.NextRetryAt
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"model":             model,
+		"available":         available,
+		"total":             len(auths),
+		"earliest_retry_at": earliest,
+		"auths":             auths,
+	})
+}
+
+// ClearCooldown resets the unavailable and retry-after state of one auth, or of one
+// model on it, and sends the change through the watcher's auth update queue.
+func (h *AuthAvailabilityHandler) ClearCooldown(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return
+	}
+	var body clearCooldownRequest
+	if c.Request.ContentLength != 0 {
+		if errBind := c.ShouldBindJSON(&body); errBind != nil {
+			apierror.Write(c, apierror.InvalidJSON())
+			return
+		}
+	}
+	if h.runtime == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "auth manager not running"})
+		return
+	}
+
+	var row models.Auth
+	if errFind := h.db.WithContext(c.Request.Context()).Select("id", "key").First(&row, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("not found"))
+			return
+		}
+		apierror.Write(c, apierror.Internal("query failed"))
+		return
+	}
+	auth, ok := h.runtime.GetByID(strings.TrimSpace(row.Key))
+	if !ok || auth == nil {
+		apierror.Write(c, apierror.NotFound("auth not loaded"))
+		return
+	}
+
+	model := strings.TrimSpace(body.Model)
+	cleared := internalauth.ClearCooldown(auth, model)
+	if cleared {
+		auth.UpdatedAt = h.now().UTC()
+		if h.dispatch == nil || !h.dispatch(auth) {
+			if manager, okManager := h.runtime.(*coreauth.Manager); okManager {
+				if _, errUpdate := manager.Update(c.Request.Context(), auth); errUpdate != nil {
+					apierror.Write(c, apierror.Internal("update auth failed"))
+					return
+				}
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "id": row.ID, "auth_id": auth.ID, "model": model, "cleared": cleared})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/jobs"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// jobTypeAuthFileCheck validates stored auth file content in the background.
+	jobTypeAuthFileCheck = "auth_files.check"
+	// authFileCheckBatchSize bounds the auth files loaded per query.
+	authFileCheckBatchSize = 100
+)
+
+func init() {
+	jobs.Register(jobTypeAuthFileCheck, runAuthFileCheck)
+}
+
+// authFileCheckParams selects the auth files to check; empty fields match every file.
+type authFileCheckParams struct {
+	IDs  []uint64 `json:"ids"`  // Auth file IDs.
+	Type string   `json:"type"` // Auth type from the content.
+}
+
+// authFileProblem is an auth file whose content failed validation.
+type authFileProblem struct {
+	ID    uint64 `json:"id"`    // Auth file ID.
+	Key   string `json:"key"`   // Auth file key.
+	Error string `json:"error"` // Validation failure.
+}
+
+// Check queues a job that validates the content of the selected auth files against the
+// token fields their provider needs, regardless of AUTH_FILE_VALIDATION. The body is
+// optional and may narrow the check to ids or one type.
+func (h *AuthFileHandler) Check(c *gin.Context) {
+	jobs.Async(h.db, jobTypeAuthFileCheck, func(c *gin.Context) (any, bool) {
+		var params authFileCheckParams
+		if c.Request.ContentLength != 0 {
+			if errBind := c.ShouldBindJSON(&params); errBind != nil {
+				apierror.Write(c, apierror.InvalidJSON())
+				return nil, false
+			}
+		}
+		return params, true
+	})(c)
+}
+
+// runAuthFileCheck validates auth file content and reports the files that fail.
+func runAuthFileCheck(ctx context.Context, run *jobs.Run) (any, error) {
+	var params authFileCheckParams
+	if errParams := run.Params(&params); errParams != nil {
+		return nil, errParams
+	}
+	db := run.DB()
+	q := db.WithContext(ctx).Model(&models.Auth{})
+	if len(params.IDs) > 0 {
+		q = q.Where("id IN ?", params.IDs)
+	}
+	if params.Type != "" {
+		q = q.Where(dbutil.JSONExtractTextExpr(db, "content", "type")+" = ?", params.Type)
+	}
+	var total int64
+	if errCount := q.Session(&gorm.Session{}).Count(&total).Error; errCount != nil {
+		return nil, errCount
+	}
+
+	checked := 0
+	problems := make([]authFileProblem, 0)
+	var rows []models.Auth
+	errBatches := q.Select("id", "key", "content").FindInBatches(&rows, authFileCheckBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, row := range rows {
+			var content map[string]any
+			errCheck := json.Unmarshal(row.Content, &content)
+			if errCheck == nil {
+				errCheck = validateAuthContent(content)
+			}
+			if errCheck != nil {
+				problems = append(problems, authFileProblem{ID: row.ID, Key: row.Key, Error: errCheck.Error()})
+			}
+			checked++
+		}
+		run.SetProgress(ctx, checked, int(total))
+		return ctx.Err()
+	}).Error
+	if errBatches != nil {
+		return nil, errBatches
+	}
+	return gin.H{
+		"checked":  checked,
+		"invalid":  len(problems),
+		"problems": problems,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// authFileRef names a stored auth file.
+type authFileRef struct {
+	ID  uint64 `json:"id"`
+	Key string `json:"key"`
+}
+
+// authFileDuplicate reports an auth file that would add an account already stored under
+// other keys.
+type authFileDuplicate struct {
+	Key      string        `json:"key"`            // Key of the rejected auth file.
+	File     string        `json:"file,omitempty"` // Uploaded file name, for imports.
+	Existing []authFileRef `json:"existing"`       // Auth files holding the same account.
+}
+
+// allowDuplicateAuth reports whether the allow_duplicate query parameter skips the
+// duplicate account check.
+func allowDuplicateAuth(c *gin.Context) bool {
+	allow, _ := strconv.ParseBool(strings.TrimSpace(c.Query("allow_duplicate")))
+	return allow
+}
+
+// duplicateAuthConflict reports auth files rejected because their account is already stored.
+func duplicateAuthConflict(duplicates []authFileDuplicate) *apierror.Error {
+	return apierror.Conflict("auth file duplicates an existing account; retry with allow_duplicate=1 to keep both").
+		With("duplicates", duplicates)
+}
+
+// loadAuthsByFingerprint returns the auth files holding each of fingerprints.
+func loadAuthsByFingerprint(ctx context.Context, db *gorm.DB, fingerprints []string) (map[string][]authFileRef, error) {
+	out := make(map[string][]authFileRef)
+	if len(fingerprints) == 0 {
+		return out, nil
+	}
+	var rows []models.Auth
+	if errFind := db.WithContext(ctx).
+		Select("id", "key", "fingerprint").
+		Where("fingerprint IN ?", fingerprints).
+		Order("id ASC").
+		Find(&rows).Error; errFind != nil {
+		return nil, errFind
+	}
+	for _, row := range rows {
+		out[row.Fingerprint] = append(out[row.Fingerprint], authFileRef{ID: row.ID, Key: row.Key})
+	}
+	return out, nil
+}
+
+// otherAuthFiles returns refs stored under a key other than key.
+func otherAuthFiles(refs []authFileRef, key string) []authFileRef {
+	var out []authFileRef
+	for _, ref := range refs {
+		if ref.Key != key {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+// authDuplicateItem is one auth file in a duplicate group.
+type authDuplicateItem struct {
+	ID          uint64    `json:"id"`
+	Key         string    `json:"key"`
+	IsAvailable bool      `json:"is_available"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Duplicates lists groups of auth files that hold the same account, judged by their
+// content fingerprint. Groups are ordered by their oldest auth file and list it first,
+// so the rest are the usual candidates for removal.
+func (h *AuthFileHandler) Duplicates(c *gin.Context) {
+	ctx := c.Request.Context()
+	var fingerprints []string
+	if errFind := h.db.WithContext(ctx).Model(&models.Auth{}).
+		Where("fingerprint <> ''").
+		Group("fingerprint").
+		Having("COUNT(*) > 1").
+		Pluck("fingerprint", &fingerprints).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("query duplicate auth files failed"))
+		return
+	}
+
+	// row is an auth file of a duplicate group.
+	type row struct {
+		ID          uint64
+		Key         string
+		Type        string
+		Fingerprint string
+		IsAvailable bool
+		CreatedAt   time.Time
+	}
+	var rows []row
+	if len(fingerprints) > 0 {
+		if errFind := h.db.WithContext(ctx).Model(&models.Auth{}).
+			Select("id, key, COALESCE("+dbutil.JSONExtractTextExpr(h.db, "content", "type")+", '') AS type, fingerprint, is_available, created_at").
+			Where("fingerprint IN ?", fingerprints).
+			Order("id ASC").
+			Scan(&rows).Error; errFind != nil {
+			apierror.Write(c, apierror.Internal("load duplicate auth files failed"))
+			return
+		}
+	}
+
+	groups := make([]gin.H, 0, len(fingerprints))
+	index := make(map[string]int, len(fingerprints))
+	for _, r := range rows {
+		item := authDuplicateItem{ID: r.ID, Key: r.Key, IsAvailable: r.IsAvailable, CreatedAt: r.CreatedAt}
+		if i, ok := index[r.Fingerprint]; ok {
+			groups[i]["auths"] = append(groups[i]["auths"].([]authDuplicateItem), item)
+			continue
+		}
+		index[r.Fingerprint] = len(groups)
+		groups = append(groups, gin.H{
+			"fingerprint": r.Fingerprint,
+			"type":        r.Type,
+			"auths":       []authDuplicateItem{item},
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": groups, "total": len(groups)})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestAuthFingerprintIdentifiesAccount(t *testing.T) {
+	a := models.AuthFingerprint([]byte(`{"type":"gemini","email":"Dev@Example.com","token":{"client_id":"c1","access_token":"x"}}`))
+	b := models.AuthFingerprint([]byte(`{"type":"gemini","email":"dev@example.com","token":{"client_id":"c1","access_token":"y"}}`))
+	if a == "" || a != b {
+		t.Fatalf("expected the same account to share a fingerprint, got %q and %q", a, b)
+	}
+	if other := models.AuthFingerprint([]byte(`{"type":"gemini","email":"dev@example.com","token":{"client_id":"c2"}}`)); other == a {
+		t.Fatal("expected another client id to change the fingerprint")
+	}
+	if got := models.AuthFingerprint([]byte(`{"type":"codex","access_token":"x"}`)); got != "" {
+		t.Fatalf("expected no fingerprint without an account, got %q", got)
+	}
+}
+
+func TestAuthFileDuplicateDetection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authdup_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	content := `{"type":"codex","email":"dev@example.com","account_id":"acct-1","access_token":"%s"}`
+	existing := models.Auth{
+		Key:         "first.json",
+		Content:     datatypes.JSON(fmt.Sprintf(content, "a")),
+		Fingerprint: models.AuthFingerprint([]byte(fmt.Sprintf(content, "a"))),
+		IsAvailable: true,
+	}
+	if errCreate := db.Create(&existing).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+
+	h := NewAuthFileHandler(db)
+	r := gin.New()
+	r.POST("/v0/admin/auth-files", h.Create)
+	r.POST("/v0/admin/auth-files/import", h.Import)
+	r.GET("/v0/admin/auth-files/duplicates", h.Duplicates)
+	create := func(query, key string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"key":%q,"content":%s}`, key, fmt.Sprintf(content, key))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files"+query, strings.NewReader(body)))
+		return w
+	}
+	importFiles := func(query string, names ...string) *httptest.ResponseRecorder {
+		var form bytes.Buffer
+		writer := multipart.NewWriter(&form)
+		for _, name := range names {
+			part, _ := writer.CreateFormFile("files", name)
+			_, _ = part.Write([]byte(fmt.Sprintf(`{"id":%q,"type":"codex","email":"other@example.com","access_token":"x"}`, name)))
+		}
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files/import"+query, &form)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := create("", "second.json")
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"first.json"`) {
+		t.Fatalf("expected 409 naming the existing auth, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = create("?allow_duplicate=1", "second.json"); w.Code != http.StatusCreated {
+		t.Fatalf("expected allow_duplicate to create, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = importFiles("", "x.json", "y.json"); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"y.json"`) {
+		t.Fatalf("expected files of one upload to collide, got %d: %s", w.Code, w.Body.String())
+	}
+	var count int64
+	if errCount := db.Model(&models.Auth{}).Where("key IN ?", []string{"x.json", "y.json"}).Count(&count).Error; errCount != nil || count != 0 {
+		t.Fatalf("expected a rejected import to write nothing, got %d (%v)", count, errCount)
+	}
+	if w = importFiles("", "x.json"); w.Code != http.StatusOK {
+		t.Fatalf("expected a single new account to import, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = importFiles("", "x.json"); w.Code != http.StatusOK {
+		t.Fatalf("expected a re-import of the same key to pass, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/auth-files/duplicates", nil))
+	var resp struct {
+		Total  int `json:"total"`
+		Groups []struct {
+			Type  string              `json:"type"`
+			Auths []authDuplicateItem `json:"auths"`
+		} `json:"groups"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode duplicates: %v", errDecode)
+	}
+	if resp.Total != 1 || resp.Groups[0].Type != "codex" || len(resp.Groups[0].Auths) != 2 ||
+		resp.Groups[0].Auths[0].Key != "first.json" || resp.Groups[0].Auths[1].Key != "second.json" {
+		t.Fatalf("unexpected duplicate groups %s", w.Body.String())
+	}
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultAuthStatsWindow is the window used when window is omitted.
+	defaultAuthStatsWindow = 24 * time.Hour
+	// minAuthStatsWindow is the narrowest accepted window.
+	minAuthStatsWindow = time.Minute
+	// maxAuthStatsWindow is the widest accepted window.
+	maxAuthStatsWindow = 90 * 24 * time.Hour
+)
+
+// authStatsSelect aggregates usage rows into the columns of authStatsRow. Rows recorded
+// before durations were tracked are left out of the latency figures.
+const authStatsSelect = `
+	auth_id,
+	COUNT(*) AS requests,
+	SUM(CASE WHEN failed THEN 1 ELSE 0 END) AS failed,
+	COALESCE(SUM(input_tokens), 0) AS input_tokens,
+	COALESCE(SUM(output_tokens), 0) AS output_tokens,
+	COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COUNT(duration_ms) AS latency_samples,
+	COALESCE(AVG(duration_ms), 0) AS avg_latency_ms
+`
+
+// authLatencyPercentiles lists the nearest-rank latency percentiles reported by Stats.
+var authLatencyPercentiles = []int{50, 90, 99}
+
+// authStatsRow is the usage aggregate of one auth over a window.
+type authStatsRow struct {
+	AuthID         uint64
+	Requests       int64
+	Failed         int64
+	InputTokens    int64
+	OutputTokens   int64
+	TotalTokens    int64
+	LatencySamples int64   // Requests with a recorded duration.
+	AvgLatencyMs   float64 // Mean duration of those requests.
+}
+
+// failureRate returns the failed share of requests as a percentage.
+func (r authStatsRow) failureRate() float64 {
+	return percentOf(r.Failed, r.Requests)
+}
+
+// authStatsItem is one row of the auth file stats overview.
+type authStatsItem struct {
+	AuthID         uint64  `json:"auth_id"`         // Auth file ID.
+	Key            string  `json:"key"`             // Auth file key.
+	Type           string  `json:"type"`            // Auth type from the content.
+	Requests       int64   `json:"requests"`        // Request count.
+	Failed         int64   `json:"failed"`          // Failed request count.
+	FailureRate    float64 `json:"failure_rate"`    // Failed share of requests in percent.
+	AvgLatencyMs   int64   `json:"avg_latency_ms"`  // Mean request duration in ms.
+	LatencySamples int64   `json:"latency_samples"` // Requests with a recorded duration.
+	TotalTokens    int64   `json:"total_tokens"`    // Total token count.
+}
+
+// authStatsAuth is the subset of an auth file shown next to its stats.
+type authStatsAuth struct {
+	ID   uint64
+	Key  string
+	Type string
+}
+
+// authStatsWindow parses the window query parameter, a duration such as 1h or 168h.
+func authStatsWindow(c *gin.Context) (time.Duration, bool) {
+	raw := strings.TrimSpace(c.Query("window"))
+	if raw == "" {
+		return defaultAuthStatsWindow, true
+	}
+	window, errParse := time.ParseDuration(raw)
+	if errParse != nil || window < minAuthStatsWindow || window > maxAuthStatsWindow {
+		apierror.Write(c, apierror.Validation("window", "window must be a duration between 1m and 2160h, e.g. 1h or 168h"))
+		return 0, false
+	}
+	return window, true
+}
+
+// authStatsAuths selects auth files with their content type.
+func authStatsAuths(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Auth{}).
+		Select("id, key, COALESCE(" + dbutil.JSONExtractTextExpr(db, "content", "type") + ", '') AS type")
+}
+
+// Stats returns the request count, failure rate, latency and token totals of one auth
+// file over the window ending now. Latency percentiles use the nearest-rank method.
+func (h *AuthFileHandler) Stats(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return
+	}
+	window, ok := authStatsWindow(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var auth authStatsAuth
+	if errFind := authStatsAuths(h.db.WithContext(ctx)).Where("id = ?", id).Take(&auth).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("not found"))
+			return
+		}
+		apierror.Write(c, apierror.Internal("query failed"))
+		return
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-window)
+	var stats authStatsRow
+	if errStats := h.db.WithContext(ctx).Model(&models.Usage{}).
+		Select(authStatsSelect).
+		Where("auth_id = ? AND requested_at >= ?", id, from).
+		Group("auth_id").
+		Scan(&stats).Error; errStats != nil {
+		apierror.Write(c, apierror.Internal("query auth stats failed"))
+		return
+	}
+
+	latency := gin.H{
+		"samples": stats.LatencySamples,
+		"avg":     int64(math.Round(stats.AvgLatencyMs)),
+	}
+	for _, percentile := range authLatencyPercentiles {
+		value, errPercentile := loadLatencyPercentile(ctx, h.db, id, from, stats.LatencySamples, percentile)
+		if errPercentile != nil {
+			apierror.Write(c, apierror.Internal("query auth latency failed"))
+			return
+		}
+		latency[fmt.Sprintf("p%d", percentile)] = value
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"auth_id":        auth.ID,
+		"key":            auth.Key,
+		"type":           auth.Type,
+		"from":           from,
+		"to":             to,
+		"window_seconds": int64(window / time.Second),
+		"requests":       stats.Requests,
+		"failed":         stats.Failed,
+		"failure_rate":   stats.failureRate(),
+		"input_tokens":   stats.InputTokens,
+		"output_tokens":  stats.OutputTokens,
+		"total_tokens":   stats.TotalTokens,
+		"latency_ms":     latency,
+	})
+}
+
+// loadLatencyPercentile returns the nearest-rank percentile of the durations recorded for an
+// auth since from, given the number of such durations. It is 0 without samples.
+func loadLatencyPercentile(ctx context.Context, db *gorm.DB, authID uint64, from time.Time, samples int64, percentile int) (int64, error) {
+	if samples <= 0 {
+		return 0, nil
+	}
+	rank := int64(math.Ceil(float64(percentile) / 100 * float64(samples)))
+	if rank < 1 {
+		rank = 1
+	}
+	var values []int64
+	if errFind := db.WithContext(ctx).Model(&models.Usage{}).
+		Where("auth_id = ? AND requested_at >= ? AND duration_ms IS NOT NULL", authID, from).
+		Order("duration_ms ASC").
+		Offset(int(rank-1)).
+		Limit(1).
+		Pluck("duration_ms", &values).Error; errFind != nil {
+		return 0, errFind
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return values[0], nil
+}
+
+// StatsOverview ranks auth files with traffic in the window ending now, worst first by
+// default: sort accepts failure_rate, latency, requests or tokens, order accepts desc or
+// asc, and type limits the list to one auth type.
+func (h *AuthFileHandler) StatsOverview(c *gin.Context) {
+	window, ok := authStatsWindow(c)
+	if !ok {
+		return
+	}
+	limit, ok := topLimit(c)
+	if !ok {
+		return
+	}
+	sortField := strings.ToLower(strings.TrimSpace(c.Query("sort")))
+	switch sortField {
+	case "":
+		sortField = "failure_rate"
+	case "failure_rate", "latency", "requests", "tokens":
+	default:
+		apierror.Write(c, apierror.Validation("sort", "sort must be failure_rate, latency, requests or tokens"))
+		return
+	}
+	order := strings.ToLower(strings.TrimSpace(c.Query("order")))
+	switch order {
+	case "":
+		order = "desc"
+	case "desc", "asc":
+	default:
+		apierror.Write(c, apierror.Validation("order", "order must be desc or asc"))
+		return
+	}
+	typeQ := strings.TrimSpace(c.Query("type"))
+
+	ctx := c.Request.Context()
+	to := time.Now().UTC()
+	from := to.Add(-window)
+	var rows []authStatsRow
+	if errStats := h.db.WithContext(ctx).Model(&models.Usage{}).
+		Select(authStatsSelect).
+		Where("auth_id IS NOT NULL AND requested_at >= ?", from).
+		Group("auth_id").
+		Scan(&rows).Error; errStats != nil {
+		apierror.Write(c, apierror.Internal("query auth stats failed"))
+		return
+	}
+
+	ids := make([]uint64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.AuthID)
+	}
+	auths := make(map[uint64]authStatsAuth, len(ids))
+	if len(ids) > 0 {
+		var found []authStatsAuth
+		if errFind := authStatsAuths(h.db.WithContext(ctx)).Where("id IN ?", ids).Scan(&found).Error; errFind != nil {
+			apierror.Write(c, apierror.Internal("load auth files failed"))
+			return
+		}
+		for _, auth := range found {
+			auths[auth.ID] = auth
+		}
+	}
+
+	items := make([]authStatsItem, 0, len(rows))
+	for _, row := range rows {
+		// Usage of deleted auth files is left out.
+		auth, okAuth := auths[row.AuthID]
+		if !okAuth || (typeQ != "" && auth.Type != typeQ) {
+			continue
+		}
+		items = append(items, authStatsItem{
+			AuthID:         row.AuthID,
+			Key:            auth.Key,
+			Type:           auth.Type,
+			Requests:       row.Requests,
+			Failed:         row.Failed,
+			FailureRate:    row.failureRate(),
+			AvgLatencyMs:   int64(math.Round(row.AvgLatencyMs)),
+			LatencySamples: row.LatencySamples,
+			TotalTokens:    row.TotalTokens,
+		})
+	}
+	sortAuthStats(items, sortField, order == "asc")
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":          items,
+		"from":           from,
+		"to":             to,
+		"window_seconds": int64(window / time.Second),
+		"sort":           sortField,
+		"order":          order,
+	})
+}
+
+// sortAuthStats orders items by field, descending unless asc is set. Ties fall back to
+// request count and then auth ID so the order is stable between calls.
+func sortAuthStats(items []authStatsItem, field string, asc bool) {
+	value := func(item authStatsItem) float64 {
+		switch field {
+		case "latency":
+			return float64(item.AvgLatencyMs)
+		case "requests":
+			return float64(item.Requests)
+		case "tokens":
+			return float64(item.TotalTokens)
+		default:
+			return item.FailureRate
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		vi, vj := value(items[i]), value(items[j])
+		if vi != vj {
+			if asc {
+				return vi < vj
+			}
+			return vi > vj
+		}
+		if items[i].Requests != items[j].Requests {
+			return items[i].Requests > items[j].Requests
+		}
+		return items[i].AuthID < items[j].AuthID
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+)
+
+func TestAuthFileStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auths := []models.Auth{
+		{Key: "fast.json", Content: datatypes.JSON(`{"type":"claude"}`), IsAvailable: true},
+		{Key: "slow.json", Content: datatypes.JSON(`{"type":"claude"}`), IsAvailable: true},
+		{Key: "codex.json", Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true},
+	}
+	if errCreate := db.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+	now := time.Now().UTC()
+	usage := func(auth models.Auth, failed bool, durationMs *int64, tokens int64, age time.Duration) models.Usage {
+		return models.Usage{
+			Provider:    "claude",
+			Model:       "claude-sonnet",
+			AuthID:      &auth.ID,
+			AuthKey:     auth.Key,
+			RequestedAt: now.Add(-age),
+			Failed:      failed,
+			DurationMs:  durationMs,
+			TotalTokens: tokens,
+		}
+	}
+	ms := func(v int64) *int64 { return &v }
+	var usages []models.Usage
+	for i := int64(1); i <= 10; i++ {
+		usages = append(usages, usage(auths[1], i <= 3, ms(i*1000), 10, time.Minute))
+	}
+	usages = append(usages,
+		usage(auths[0], false, ms(100), 40, time.Minute),
+		usage(auths[0], false, nil, 40, time.Minute),
+		usage(auths[0], true, ms(9000), 50, 48*time.Hour),
+		usage(auths[2], true, ms(200), 5, time.Minute),
+	)
+	if errCreate := db.Create(&usages).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	h := NewAuthFileHandler(db)
+	r := gin.New()
+	r.GET("/v0/admin/auth-files/stats", h.StatsOverview)
+	r.GET("/v0/admin/auth-files/:id/stats", h.Stats)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get(fmt.Sprintf("/v0/admin/auth-files/%d/stats", auths[1].ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats struct {
+		Requests    int64   `json:"requests"`
+		Failed      int64   `json:"failed"`
+		FailureRate float64 `json:"failure_rate"`
+		TotalTokens int64   `json:"total_tokens"`
+		LatencyMs   struct {
+			Samples int64 `json:"samples"`
+			Avg     int64 `json:"avg"`
+			P50     int64 `json:"p50"`
+			P90     int64 `json:"p90"`
+			P99     int64 `json:"p99"`
+		} `json:"latency_ms"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &stats); errDecode != nil {
+		t.Fatalf("decode stats: %v", errDecode)
+	}
+	if stats.Requests != 10 || stats.Failed != 3 || stats.FailureRate != 30 || stats.TotalTokens != 100 {
+		t.Fatalf("unexpected counts %s", w.Body.String())
+	}
+	if l := stats.LatencyMs; l.Samples != 10 || l.Avg != 5500 || l.P50 != 5000 || l.P90 != 9000 || l.P99 != 10000 {
+		t.Fatalf("unexpected latency %+v", l)
+	}
+
+	w = get(fmt.Sprintf("/v0/admin/auth-files/%d/stats?window=72h", auths[0].ID))
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &stats); errDecode != nil {
+		t.Fatalf("decode stats: %v", errDecode)
+	}
+	if stats.Requests != 3 || stats.Failed != 1 || stats.LatencyMs.Samples != 2 || stats.LatencyMs.P50 != 100 {
+		t.Fatalf("expected the wider window and rows without a duration, got %s", w.Body.String())
+	}
+	if w = get("/v0/admin/auth-files/4242/stats"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown auth, got %d", w.Code)
+	}
+	if w = get(fmt.Sprintf("/v0/admin/auth-files/%d/stats?window=1s", auths[0].ID)); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a too narrow window to be rejected, got %d", w.Code)
+	}
+
+	overview := func(query string) []authStatsItem {
+		t.Helper()
+		w := get("/v0/admin/auth-files/stats" + query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("overview %q: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Items []authStatsItem `json:"items"`
+		}
+		if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+			t.Fatalf("decode overview: %v", errDecode)
+		}
+		return resp.Items
+	}
+	keys := func(items []authStatsItem) []string {
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			out = append(out, item.Key)
+		}
+		return out
+	}
+	if got := keys(overview("")); fmt.Sprint(got) != "[codex.json slow.json fast.json]" {
+		t.Fatalf("expected worst failure rate first, got %v", got)
+	}
+	if got := keys(overview("?sort=latency&type=claude")); fmt.Sprint(got) != "[slow.json fast.json]" {
+		t.Fatalf("expected claude auths by latency, got %v", got)
+	}
+	if got := keys(overview("?sort=tokens&order=asc&limit=2")); fmt.Sprint(got) != "[codex.json fast.json]" {
+		t.Fatalf("expected the two lowest token counts, got %v", got)
+	}
+	if w = get("/v0/admin/auth-files/stats?sort=cost"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown sort to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+// trashedAuthFile is an auth file entry waiting in the trash.
+type trashedAuthFile struct {
+	ID          uint64              `json:"id"`
+	Key         string              `json:"key"`
+	Type        string              `json:"type"`
+	AuthGroupID models.AuthGroupIDs `json:"auth_group_id"`
+	Tags        models.Tags         `json:"tags"`
+	CreatedAt   time.Time           `json:"created_at"`
+	DeletedAt   time.Time           `json:"deleted_at"`
+	PurgeAt     *time.Time          `json:"purge_at"` // Nil when AUTH_TRASH_RETENTION_DAYS keeps the trash forever.
+}
+
+// Trash lists auth files moved to the trash, most recently deleted first.
+func (h *AuthFileHandler) Trash(c *gin.Context) {
+	var rows []models.Auth
+	if errFind := h.db.WithContext(c.Request.Context()).Unscoped().
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC, id DESC").
+		Find(&rows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("list trashed auth files failed"))
+		return
+	}
+
+	retentionDays := internalsettings.GetInt(internalsettings.AuthTrashRetentionDaysKey)
+	out := make([]trashedAuthFile, 0, len(rows))
+	for _, row := range rows {
+		var content struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(row.Content, &content)
+		item := trashedAuthFile{
+			ID:          row.ID,
+			Key:         row.Key,
+			Type:        content.Type,
+			AuthGroupID: row.AuthGroupID.Clean(),
+			Tags:        row.Tags,
+			CreatedAt:   row.CreatedAt,
+			DeletedAt:   row.DeletedAt.Time,
+		}
+		if retentionDays > 0 {
+			purgeAt := row.DeletedAt.Time.AddDate(0, 0, retentionDays)
+			item.PurgeAt = &purgeAt
+		}
+		out = append(out, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"auth_files": out})
+}
+
+// Restore moves an auth file entry out of the trash. It fails with a conflict when a live
+// entry has taken its key in the meantime.
+func (h *AuthFileHandler) Restore(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return
+	}
+
+	ctx := c.Request.Context()
+	var auth models.Auth
+	if errFind := h.db.WithContext(ctx).Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&auth).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("not found"))
+			return
+		}
+		apierror.Write(c, apierror.Internal("query failed"))
+		return
+	}
+
+	var taken int64
+	if errCount := h.db.WithContext(ctx).Model(&models.Auth{}).
+		Where("key = ?", auth.Key).
+		Count(&taken).Error; errCount != nil {
+		apierror.Write(c, apierror.Internal("query failed"))
+		return
+	}
+	if taken > 0 {
+		apierror.Write(c, apierror.Conflict("key already exists").WithField("key"))
+		return
+	}
+
+	now := time.Now().UTC()
+	res := h.db.WithContext(ctx).Unscoped().Model(&models.Auth{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]any{"deleted_at": nil, "updated_at": now})
+	if res.Error != nil {
+		if dbutil.IsUniqueViolation(res.Error) {
+			apierror.Write(c, apierror.Conflict("key already exists").WithField("key"))
+			return
+		}
+		apierror.Write(c, apierror.Internal("restore failed"))
+		return
+	}
+	if res.RowsAffected == 0 {
+		apierror.Write(c, apierror.NotFound("not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": auth.ID, "key": auth.Key})
+}
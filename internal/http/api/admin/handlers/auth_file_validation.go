@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+// validateAuthContent checks that auth file content carries the token fields its provider
+// needs at request time. Types that do not map to a known provider are not checked.
+func validateAuthContent(content map[string]any) error {
+	authType, _ := content["type"].(string)
+	authType = strings.TrimSpace(authType)
+	if authType == "" {
+		return errors.New("type is required")
+	}
+	var errField error
+	switch normalizeProvider(authType) {
+	case providerGemini:
+		token, okToken := content["token"].(map[string]any)
+		if !okToken {
+			errField = errors.New("token is required")
+		} else if !hasContentString(token, "access_token") && !hasContentString(token, "refresh_token") {
+			errField = errors.New("token.access_token or token.refresh_token is required")
+		}
+	case providerCodex, providerClaude, providerQwen:
+		if !hasContentString(content, "access_token") {
+			errField = errors.New("access_token is required")
+		}
+	case providerIFlow:
+		if !hasContentString(content, "api_key") {
+			errField = errors.New("api_key is required")
+		}
+	}
+	if errField != nil {
+		return fmt.Errorf("%s auth: %w", authType, errField)
+	}
+	return nil
+}
+
+// hasContentString reports whether content holds a non-empty string under key.
+func hasContentString(content map[string]any, key string) bool {
+	value, ok := content[key].(string)
+	return ok && strings.TrimSpace(value) != ""
+}
+
+// checkAuthContent validates content before it is stored under key. In warn mode invalid
+// content is logged and accepted so deployments with nonstandard auth files keep working.
+func checkAuthContent(ctx context.Context, key string, content map[string]any) error {
+	errValidate := validateAuthContent(content)
+	if errValidate == nil {
+		return nil
+	}
+	if internalsettings.GetString(internalsettings.AuthFileValidationKey) == internalsettings.AuthFileValidationWarn {
+		logging.FromContext(ctx).WithField("auth_key", key).Warnf("auth file: accepting invalid content: %v", errValidate)
+		return nil
+	}
+	return errValidate
+}
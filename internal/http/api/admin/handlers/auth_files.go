@@ -14,10 +14,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 // AuthFileHandler manages auth file endpoints.
@@ -34,6 +34,7 @@ func NewAuthFileHandler(db *gorm.DB) *AuthFileHandler {
 type createAuthFileRequest struct {
 	Key         string              `json:"key"`
 	AuthGroupID models.AuthGroupIDs `json:"auth_group_id"`
+	Tags        []string            `json:"tags"`
 	ProxyURL    *string             `json:"proxy_url"`
 	Content     map[string]any      `json:"content"`
 	IsAvailable *bool               `json:"is_available"`
@@ -47,43 +48,65 @@ type importAuthFilesFailure struct {
 }
 
 type importAuthFilesResponse struct {
-	Imported int                      `json:"imported"`
-	Failed   []importAuthFilesFailure `json:"failed"`
+	Imported    int                      `json:"imported"`
+	Skipped     int                      `json:"skipped"`
+	Overwritten int                      `json:"overwritten"`
+	Failed      []importAuthFilesFailure `json:"failed"`
 }
 
-// Create creates a new auth file entry.
+// Import modes deciding what happens when an imported key already exists.
+const (
+	// importModeSkip leaves existing auths untouched and reports them as skipped.
+	importModeSkip = "skip"
+	// importModeOverwrite replaces content, auth groups and proxy of existing auths.
+	importModeOverwrite = "overwrite"
+	// importModeMerge replaces only the content of existing auths, keeping their proxy,
+	// auth groups, rate limit and priority.
+	importModeMerge = "merge"
+)
+
+// parseImportMode reads the import mode, defaulting to skip.
+func parseImportMode(value string) (string, bool) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "":
+		return importModeSkip, true
+	case importModeSkip, importModeOverwrite, importModeMerge:
+		return mode, true
+	default:
+		return "", false
+	}
+}
+
+// Create creates a new auth file entry. Content holding an account already stored under
+// another key is rejected with 409 unless allow_duplicate is set.
 func (h *AuthFileHandler) Create(c *gin.Context) {
 	var body createAuthFileRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 	key := strings.TrimSpace(body.Key)
 	if key == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing key"})
+		apierror.Write(c, apierror.Validation("key", "missing key"))
+		return
+	}
+	tags, errTags := models.NormalizeTags(body.Tags)
+	if errTags != nil {
+		apierror.Write(c, apierror.Validation("tags", errTags.Error()))
 		return
 	}
+	if body.Content != nil {
+		if errContent := checkAuthContent(c.Request.Context(), key, body.Content); errContent != nil {
+			apierror.Write(c, apierror.Validation("content", errContent.Error()))
+			return
+		}
+	}
 
 	isAvailable := true
 	if body.IsAvailable != nil {
 		isAvailable = *body.IsAvailable
 	}
 
-	proxyURL := ""
-	if body.ProxyURL != nil {
-		proxyURL = strings.TrimSpace(*body.ProxyURL)
-	}
-	if proxyURL == "" && autoAssignProxyEnabled() {
-		assignedProxyURL, errAssignProxy := pickRandomProxyURL(c.Request.Context(), h.db)
-		if errAssignProxy != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "auto assign proxy failed"})
-			return
-		}
-		if assignedProxyURL != "" {
-			proxyURL = assignedProxyURL
-		}
-	}
-
 	contentJSON := datatypes.JSON("{}")
 	if body.Content != nil {
 		contentBytes, errMarshal := json.Marshal(body.Content)
@@ -92,6 +115,19 @@ func (h *AuthFileHandler) Create(c *gin.Context) {
 		}
 	}
 
+	fingerprint := models.AuthFingerprint(contentJSON)
+	if fingerprint != "" && !allowDuplicateAuth(c) {
+		existing, errExisting := loadAuthsByFingerprint(c.Request.Context(), h.db, []string{fingerprint})
+		if errExisting != nil {
+			apierror.Write(c, apierror.Internal("query duplicate auth files failed"))
+			return
+		}
+		if others := otherAuthFiles(existing[fingerprint], key); len(others) > 0 {
+			apierror.Write(c, duplicateAuthConflict([]authFileDuplicate{{Key: key, Existing: others}}))
+			return
+		}
+	}
+
 	now := time.Now().UTC()
 	authGroupIDs := body.AuthGroupID.Clean()
 	if body.AuthGroupID == nil {
@@ -102,15 +138,40 @@ func (h *AuthFileHandler) Create(c *gin.Context) {
 			defaultGroupID := defaultGroup.ID
 			authGroupIDs = models.AuthGroupIDs{&defaultGroupID}
 		} else if !errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "query default auth group failed"})
+			apierror.Write(c, apierror.Internal("query default auth group failed"))
+			return
+		}
+	}
+
+	proxyURL := ""
+	if body.ProxyURL != nil {
+		proxyURL = strings.TrimSpace(*body.ProxyURL)
+	}
+	if proxyURL == "" {
+		groupProxyURL, errGroupProxy := authGroupDefaultProxyURL(c.Request.Context(), h.db, authGroupIDs)
+		if errGroupProxy != nil {
+			apierror.Write(c, apierror.Internal("query auth group proxy failed"))
 			return
 		}
+		// Leave proxy_url empty so the auth keeps following its group's default.
+		if groupProxyURL == "" && autoAssignProxyEnabled() {
+			assignedProxyURL, errAssignProxy := pickRandomProxyURL(c.Request.Context(), h.db)
+			if errAssignProxy != nil {
+				apierror.Write(c, apierror.Internal("auto assign proxy failed"))
+				return
+			}
+			if assignedProxyURL != "" {
+				proxyURL = assignedProxyURL
+			}
+		}
 	}
 	auth := models.Auth{
 		Key:         key,
 		AuthGroupID: authGroupIDs,
+		Tags:        tags,
 		ProxyURL:    proxyURL,
 		Content:     contentJSON,
+		Fingerprint: fingerprint,
 		IsAvailable: isAvailable,
 		RateLimit:   body.RateLimit,
 		Priority:    body.Priority,
@@ -119,39 +180,51 @@ func (h *AuthFileHandler) Create(c *gin.Context) {
 	}
 
 	if errCreate := h.db.WithContext(c.Request.Context()).Create(&auth).Error; errCreate != nil {
-		if strings.Contains(errCreate.Error(), "duplicate") || strings.Contains(errCreate.Error(), "unique") {
-			c.JSON(http.StatusConflict, gin.H{"error": "key already exists"})
+		if dbutil.IsUniqueViolation(errCreate) {
+			apierror.Write(c, apierror.Conflict("key already exists").WithField("key"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "create auth file failed"})
+		apierror.Write(c, apierror.Internal("create auth file failed"))
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"id":            auth.ID,
-		"key":           auth.Key,
-		"auth_group_id": auth.AuthGroupID.Clean(),
-		"proxy_url":     auth.ProxyURL,
-		"content":       auth.Content,
-		"is_available":  auth.IsAvailable,
-		"rate_limit":    auth.RateLimit,
-		"priority":      auth.Priority,
-		"created_at":    auth.CreatedAt,
-		"updated_at":    auth.UpdatedAt,
+		"id":                 auth.ID,
+		"key":                auth.Key,
+		"auth_group_id":      auth.AuthGroupID.Clean(),
+		"tags":               auth.Tags,
+		"proxy_url":          auth.ProxyURL,
+		"content":            auth.Content,
+		"is_available":       auth.IsAvailable,
+		"rate_limit":         auth.RateLimit,
+		"priority":           auth.Priority,
+		"effective_priority": auth.EffectivePriority,
+		"created_at":         auth.CreatedAt,
+		"updated_at":         auth.UpdatedAt,
 	})
 }
 
-// Import uploads multiple auth json files and persists them into the auth table.
+// Import uploads multiple auth json files and persists them into the auth table. The
+// mode form field decides how keys that already exist are handled: skip (default),
+// overwrite or merge. Keys repeated within one upload collide with the earlier file.
+// Files whose content fails validateAuthContent are reported as failures. When a new key
+// would hold an account already stored under another key, or given by another file of the
+// upload, nothing is imported and 409 lists the duplicates unless allow_duplicate is set.
 func (h *AuthFileHandler) Import(c *gin.Context) {
 	form, errForm := c.MultipartForm()
 	if errForm != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid multipart form"})
+		apierror.Write(c, apierror.InvalidRequest("invalid multipart form"))
+		return
+	}
+	mode, okMode := parseImportMode(c.PostForm("mode"))
+	if !okMode {
+		apierror.Write(c, apierror.Validation("mode", "mode must be skip, overwrite or merge"))
 		return
 	}
 
 	files := form.File["files"]
 	if len(files) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no files provided"})
+		apierror.Write(c, apierror.Validation("files", "no files provided"))
 		return
 	}
 
@@ -161,7 +234,7 @@ func (h *AuthFileHandler) Import(c *gin.Context) {
 	if groupProvided {
 		parsedIDs, errParse := parseAuthGroupIDsInput(groupValue)
 		if errParse != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid auth group id"})
+			apierror.Write(c, apierror.Validation("auth_group_id", "invalid auth group id"))
 			return
 		}
 		authGroupIDs = parsedIDs.Clean()
@@ -175,14 +248,32 @@ func (h *AuthFileHandler) Import(c *gin.Context) {
 			defaultGroupID := defaultGroup.ID
 			authGroupIDs = models.AuthGroupIDs{&defaultGroupID}
 		} else if !errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "query default auth group failed"})
+			apierror.Write(c, apierror.Internal("query default auth group failed"))
 			return
 		}
 	}
+	groupProxyURL, errGroupProxy := authGroupDefaultProxyURL(c.Request.Context(), h.db, authGroupIDs)
+	if errGroupProxy != nil {
+		apierror.Write(c, apierror.Internal("query auth group proxy failed"))
+		return
+	}
+	// Re-imported keys keep their tags unless the form sets tags explicitly.
+	tagsValue, tagsProvided := c.GetPostForm("tags")
+	tags, errTags := models.NormalizeTags(strings.Split(tagsValue, ","))
+	if errTags != nil {
+		apierror.Write(c, apierror.Validation("tags", errTags.Error()))
+		return
+	}
 
 	now := time.Now().UTC()
-	imported := 0
+	imported, skipped, overwritten := 0, 0, 0
 	failures := make([]importAuthFilesFailure, 0)
+	// pendingAuth is a parsed file waiting to be written.
+	type pendingAuth struct {
+		file string
+		auth models.Auth
+	}
+	var pending []pendingAuth
 
 	for _, file := range files {
 		if file == nil {
@@ -258,12 +349,19 @@ func (h *AuthFileHandler) Import(c *gin.Context) {
 				}
 			}
 		}
+		if errContent := checkAuthContent(c.Request.Context(), key, payload); errContent != nil {
+			failures = append(failures, importAuthFilesFailure{
+				File:  file.Filename,
+				Error: errContent.Error(),
+			})
+			continue
+		}
 
 		proxyURL := ""
 		if proxyValue, okProxy := payload["proxy_url"].(string); okProxy {
 			proxyURL = strings.TrimSpace(proxyValue)
 		}
-		if proxyURL == "" && autoAssignProxyEnabled() {
+		if proxyURL == "" && groupProxyURL == "" && autoAssignProxyEnabled() {
 			assignedProxyURL, errAssignProxy := pickRandomProxyURL(c.Request.Context(), h.db)
 			if errAssignProxy != nil {
 				failures = append(failures, importAuthFilesFailure{
@@ -286,73 +384,176 @@ func (h *AuthFileHandler) Import(c *gin.Context) {
 			continue
 		}
 
-		auth := models.Auth{
+		pending = append(pending, pendingAuth{file: file.Filename, auth: models.Auth{
 			Key:         key,
 			AuthGroupID: authGroupIDs,
+			Tags:        tags,
 			ProxyURL:    proxyURL,
 			Content:     datatypes.JSON(contentBytes),
+			Fingerprint: models.AuthFingerprint(contentBytes),
 			IsAvailable: true,
 			CreatedAt:   now,
 			UpdatedAt:   now,
+		}})
+	}
+
+	if !allowDuplicateAuth(c) {
+		keys := make([]string, 0, len(pending))
+		fingerprints := make([]string, 0, len(pending))
+		for _, entry := range pending {
+			keys = append(keys, entry.auth.Key)
+			if entry.auth.Fingerprint != "" {
+				fingerprints = append(fingerprints, entry.auth.Fingerprint)
+			}
+		}
+		var existingKeys []string
+		if len(fingerprints) > 0 {
+			if errFind := h.db.WithContext(c.Request.Context()).Model(&models.Auth{}).
+				Where("key IN ?", keys).
+				Pluck("key", &existingKeys).Error; errFind != nil {
+				apierror.Write(c, apierror.Internal("query duplicate auth files failed"))
+				return
+			}
+		}
+		stored, errStored := loadAuthsByFingerprint(c.Request.Context(), h.db, fingerprints)
+		if errStored != nil {
+			apierror.Write(c, apierror.Internal("query duplicate auth files failed"))
+			return
+		}
+		isStored := make(map[string]bool, len(existingKeys))
+		for _, key := range existingKeys {
+			isStored[key] = true
 		}
+		duplicates := make([]authFileDuplicate, 0)
+		for _, entry := range pending {
+			// Files for stored keys update a row instead of adding one.
+			if entry.auth.Fingerprint == "" || isStored[entry.auth.Key] {
+				continue
+			}
+			if others := otherAuthFiles(stored[entry.auth.Fingerprint], entry.auth.Key); len(others) > 0 {
+				duplicates = append(duplicates, authFileDuplicate{Key: entry.auth.Key, File: entry.file, Existing: others})
+			}
+			// Later files of the upload with the same account collide with this one.
+			stored[entry.auth.Fingerprint] = append(stored[entry.auth.Fingerprint], authFileRef{Key: entry.auth.Key})
+		}
+		if len(duplicates) > 0 {
+			apierror.Write(c, duplicateAuthConflict(duplicates))
+			return
+		}
+	}
 
-		errCreate := h.db.WithContext(c.Request.Context()).Clauses(clause.OnConflict{
-			Columns: []clause.Column{{Name: "key"}},
-			DoUpdates: clause.Assignments(map[string]any{
-				"auth_group_id": auth.AuthGroupID,
-				"proxy_url":     auth.ProxyURL,
-				"content":       auth.Content,
-				"updated_at":    now,
-			}),
-		}).Create(&auth).Error
-		if errCreate != nil {
+	for _, entry := range pending {
+		auth, file, key := entry.auth, entry.file, entry.auth.Key
+		onConflict := models.AuthKeyConflict()
+		onConflict.DoNothing = true
+		created := h.db.WithContext(c.Request.Context()).Clauses(onConflict).Create(&auth)
+		if created.Error != nil {
 			failures = append(failures, importAuthFilesFailure{
-				File:  file.Filename,
+				File:  file,
+				Error: "import auth file failed",
+			})
+			continue
+		}
+		if created.RowsAffected > 0 {
+			imported++
+			continue
+		}
+		if mode == importModeSkip {
+			skipped++
+			continue
+		}
+
+		conflictUpdates := map[string]any{
+			"content":     auth.Content,
+			"fingerprint": auth.Fingerprint,
+			"updated_at":  now,
+		}
+		if mode == importModeOverwrite {
+			conflictUpdates["auth_group_id"] = auth.AuthGroupID
+			conflictUpdates["proxy_url"] = auth.ProxyURL
+		}
+		if tagsProvided {
+			conflictUpdates["tags"] = auth.Tags
+		}
+		if errUpdate := h.db.WithContext(c.Request.Context()).
+			Model(&models.Auth{}).
+			Where("key = ?", key).
+			Updates(conflictUpdates).Error; errUpdate != nil {
+			failures = append(failures, importAuthFilesFailure{
+				File:  file,
 				Error: "import auth file failed",
 			})
 			continue
 		}
-		imported++
+		overwritten++
 	}
 
 	c.JSON(http.StatusOK, importAuthFilesResponse{
-		Imported: imported,
-		Failed:   failures,
+		Imported:    imported,
+		Skipped:     skipped,
+		Overwritten: overwritten,
+		Failed:      failures,
 	})
 }
 
-// List returns auth files with optional filters.
+// List returns auth files with optional key, auth group, tag, type, routing prefix and
+// account email filters. The email filter is an exact, case-insensitive match.
+// auth_group_id and tags accept comma-separated lists and match auths in any of the values.
 func (h *AuthFileHandler) List(c *gin.Context) {
 	var (
 		keyQ         = strings.TrimSpace(c.Query("key"))
 		authGroupIDQ = strings.TrimSpace(c.Query("auth_group_id"))
 		typeQ        = strings.TrimSpace(c.Query("type"))
+		prefixQ      = strings.Trim(strings.TrimSpace(c.Query("prefix")), "/")
+		emailQ       = strings.TrimSpace(c.Query("email"))
 	)
+	tagsQ, errTags := parseTagsQuery(c.Query("tags"))
+	if errTags != nil {
+		apierror.Write(c, apierror.Validation("tags", errTags.Error()))
+		return
+	}
 
 	q := h.db.WithContext(c.Request.Context()).Model(&models.Auth{})
 	if keyQ != "" {
 		pattern := dbutil.NormalizeLikePattern(h.db, "%"+keyQ+"%")
 		q = q.Where(dbutil.CaseInsensitiveLikeExpr(h.db, "key"), pattern)
 	}
-	if authGroupIDQ != "" {
-		if id, errParse := strconv.ParseUint(authGroupIDQ, 10, 64); errParse == nil {
-			q = q.Where(dbutil.JSONArrayContainsExpr(h.db, "auth_group_id"), dbutil.JSONArrayContainsValue(h.db, id))
-		}
+	if groupIDs := parseUintList(authGroupIDQ); len(groupIDs) > 0 {
+		expr, args := dbutil.JSONArrayContainsAny(h.db, "auth_group_id", groupIDs)
+		q = q.Where(expr, args...)
+	}
+	if len(tagsQ) > 0 {
+		expr, args := dbutil.JSONArrayContainsAnyText(h.db, "tags", tagsQ)
+		q = q.Where(expr, args...)
 	}
 	if typeQ != "" {
 		typeExpr := dbutil.JSONExtractTextExpr(h.db, "content", "type")
 		q = q.Where(typeExpr+" = ?", typeQ)
 	}
+	if prefixQ != "" {
+		prefixExpr := dbutil.JSONExtractTextExpr(h.db, "content", "prefix")
+		q = q.Where(prefixExpr+" = ?", prefixQ)
+	}
+	if emailQ != "" {
+		// Matches the idx_auths_content_email expression index.
+		emailExpr := "LOWER(" + dbutil.JSONExtractTextExpr(h.db, "content", "email") + ")"
+		q = q.Where(emailExpr+" = ?", strings.ToLower(emailQ))
+	}
 
 	var rows []models.Auth
 	if errFind := q.Order("created_at DESC").Find(&rows).Error; errFind != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list auth files failed"})
+		apierror.Write(c, apierror.Internal("list auth files failed"))
 		return
 	}
 
 	groupMap, errGroups := loadAuthGroupMap(c.Request.Context(), h.db, rows)
 	if errGroups != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "load auth groups failed"})
+		apierror.Write(c, apierror.Internal("load auth groups failed"))
+		return
+	}
+	usageStats, errUsage := loadAuthUsageStats(c.Request.Context(), h.db, rows, time.Now().UTC().Add(-authRecentUsageWindow))
+	if errUsage != nil {
+		apierror.Write(c, apierror.Internal("load auth usage failed"))
 		return
 	}
 
@@ -360,18 +561,21 @@ func (h *AuthFileHandler) List(c *gin.Context) {
 	for _, row := range rows {
 		authGroupIDs := row.AuthGroupID.Clean()
 		item := gin.H{
-			"id":            row.ID,
-			"key":           row.Key,
-			"auth_group_id": authGroupIDs,
-			"proxy_url":     row.ProxyURL,
-			"content":       row.Content,
-			"is_available":  row.IsAvailable,
-			"rate_limit":    row.RateLimit,
-			"priority":      row.Priority,
-			"created_at":    row.CreatedAt,
-			"updated_at":    row.UpdatedAt,
+			"id":                 row.ID,
+			"key":                row.Key,
+			"auth_group_id":      authGroupIDs,
+			"tags":               row.Tags,
+			"proxy_url":          row.ProxyURL,
+			"content":            row.Content,
+			"is_available":       row.IsAvailable,
+			"rate_limit":         row.RateLimit,
+			"priority":           row.Priority,
+			"effective_priority": row.EffectivePriority,
+			"created_at":         row.CreatedAt,
+			"updated_at":         row.UpdatedAt,
 		}
 		item["auth_group"] = buildAuthGroupSummaries(authGroupIDs, groupMap)
+		addAuthUsageStats(item, usageStats[row.ID])
 		out = append(out, item)
 	}
 	c.JSON(http.StatusOK, gin.H{"auth_files": out})
@@ -381,39 +585,47 @@ func (h *AuthFileHandler) List(c *gin.Context) {
 func (h *AuthFileHandler) Get(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
 	var auth models.Auth
 	if errFind := h.db.WithContext(c.Request.Context()).First(&auth, id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			apierror.Write(c, apierror.NotFound("not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		apierror.Write(c, apierror.Internal("query failed"))
 		return
 	}
 
 	authGroupIDs := auth.AuthGroupID.Clean()
 	groupMap, errGroups := loadAuthGroupMap(c.Request.Context(), h.db, []models.Auth{auth})
 	if errGroups != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "load auth groups failed"})
+		apierror.Write(c, apierror.Internal("load auth groups failed"))
+		return
+	}
+	usageStats, errUsage := loadAuthUsageStats(c.Request.Context(), h.db, []models.Auth{auth}, time.Now().UTC().Add(-authRecentUsageWindow))
+	if errUsage != nil {
+		apierror.Write(c, apierror.Internal("load auth usage failed"))
 		return
 	}
 	item := gin.H{
-		"id":            auth.ID,
-		"key":           auth.Key,
-		"auth_group_id": authGroupIDs,
-		"proxy_url":     auth.ProxyURL,
-		"content":       auth.Content,
-		"is_available":  auth.IsAvailable,
-		"rate_limit":    auth.RateLimit,
-		"priority":      auth.Priority,
-		"created_at":    auth.CreatedAt,
-		"updated_at":    auth.UpdatedAt,
+		"id":                 auth.ID,
+		"key":                auth.Key,
+		"auth_group_id":      authGroupIDs,
+		"tags":               auth.Tags,
+		"proxy_url":          auth.ProxyURL,
+		"content":            auth.Content,
+		"is_available":       auth.IsAvailable,
+		"rate_limit":         auth.RateLimit,
+		"priority":           auth.Priority,
+		"effective_priority": auth.EffectivePriority,
+		"created_at":         auth.CreatedAt,
+		"updated_at":         auth.UpdatedAt,
 	}
 	item["auth_group"] = buildAuthGroupSummaries(authGroupIDs, groupMap)
+	addAuthUsageStats(item, usageStats[auth.ID])
 	c.JSON(http.StatusOK, item)
 }
 
@@ -421,6 +633,7 @@ func (h *AuthFileHandler) Get(c *gin.Context) {
 type updateAuthFileRequest struct {
 	Key         *string              `json:"key"`
 	AuthGroupID *models.AuthGroupIDs `json:"auth_group_id"`
+	Tags        *[]string            `json:"tags"`
 	ProxyURL    *string              `json:"proxy_url"`
 	Content     map[string]any       `json:"content"`
 	IsAvailable *bool                `json:"is_available"`
@@ -432,13 +645,13 @@ type updateAuthFileRequest struct {
 func (h *AuthFileHandler) Update(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
 	var body updateAuthFileRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
@@ -451,6 +664,14 @@ func (h *AuthFileHandler) Update(c *gin.Context) {
 	if body.AuthGroupID != nil {
 		updates["auth_group_id"] = body.AuthGroupID.Clean()
 	}
+	if body.Tags != nil {
+		tags, errTags := models.NormalizeTags(*body.Tags)
+		if errTags != nil {
+			apierror.Write(c, apierror.Validation("tags", errTags.Error()))
+			return
+		}
+		updates["tags"] = tags
+	}
 	if body.ProxyURL != nil {
 		updates["proxy_url"] = strings.TrimSpace(*body.ProxyURL)
 	}
@@ -458,6 +679,7 @@ func (h *AuthFileHandler) Update(c *gin.Context) {
 		contentBytes, errMarshal := json.Marshal(body.Content)
 		if errMarshal == nil {
 			updates["content"] = datatypes.JSON(contentBytes)
+			updates["fingerprint"] = models.AuthFingerprint(contentBytes)
 		}
 	}
 	if body.IsAvailable != nil {
@@ -468,45 +690,233 @@ func (h *AuthFileHandler) Update(c *gin.Context) {
 	}
 	if body.Priority != nil {
 		updates["priority"] = *body.Priority
+		updates["effective_priority"] = nil
+		updates["quota_strikes"] = 0
 	}
 
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Auth{}).Where("id = ?", id).Updates(updates)
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
-// Delete removes an auth file entry.
+// maxBulkPriorityItems caps the entries accepted by one bulk priority update.
+const maxBulkPriorityItems = 1000
+
+// authPriorityItem sets the priority of a single auth file.
+type authPriorityItem struct {
+	ID       uint64 `json:"id"`
+	Priority *int   `json:"priority"`
+}
+
+// UpdatePriorities sets the priority of many auth files in one transaction. Setting a
+// priority also clears any automatic decay so the new value takes effect immediately.
+func (h *AuthFileHandler) UpdatePriorities(c *gin.Context) {
+	var body struct {
+		Items []authPriorityItem `json:"items"`
+	}
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		apierror.Write(c, apierror.InvalidJSON())
+		return
+	}
+	if len(body.Items) == 0 {
+		apierror.Write(c, apierror.Validation("items", "no items"))
+		return
+	}
+	if len(body.Items) > maxBulkPriorityItems {
+		apierror.Write(c, apierror.Validation("items", fmt.Sprintf("too many items, limit is %d", maxBulkPriorityItems)))
+		return
+	}
+	seen := make(map[uint64]struct{}, len(body.Items))
+	for _, item := range body.Items {
+		if item.ID == 0 || item.Priority == nil {
+			apierror.Write(c, apierror.Validation("items", "each item needs id and priority"))
+			return
+		}
+		if _, dup := seen[item.ID]; dup {
+			apierror.Write(c, apierror.Validation("items", fmt.Sprintf("duplicate id %d", item.ID)))
+			return
+		}
+		seen[item.ID] = struct{}{}
+	}
+
+	var missing []uint64
+	now := time.Now().UTC()
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		for _, item := range body.Items {
+			res := tx.Model(&models.Auth{}).Where("id = ?", item.ID).Updates(map[string]any{
+				"priority":           *item.Priority,
+				"effective_priority": nil,
+				"quota_strikes":      0,
+				"updated_at":         now,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				missing = append(missing, item.ID)
+			}
+		}
+		if len(missing) > 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errTx != nil {
+		if len(missing) > 0 {
+			apierror.Write(c, apierror.NotFound("auth files not found").With("missing_ids", missing))
+			return
+		}
+		apierror.Write(c, apierror.Internal("update priorities failed"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": len(body.Items)})
+}
+
+// maxBatchMoveGroupItems caps the auths accepted by one batch group move.
+const maxBatchMoveGroupItems = 1000
+
+// batchMoveGroupRequest defines the request body for moving auths between auth groups.
+type batchMoveGroupRequest struct {
+	IDs         []uint64            `json:"ids"`
+	AuthGroupID models.AuthGroupIDs `json:"auth_group_id"`
+}
+
+// BatchMoveGroup replaces the auth groups of many auth files in one update. Unknown auth
+// IDs are reported instead of failing the move; unknown target groups reject it. An empty
+// auth_group_id list removes the listed auths from every group.
+func (h *AuthFileHandler) BatchMoveGroup(c *gin.Context) {
+	var body batchMoveGroupRequest
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		apierror.Write(c, apierror.InvalidJSON())
+		return
+	}
+	if len(body.IDs) == 0 {
+		apierror.Write(c, apierror.Validation("ids", "no ids"))
+		return
+	}
+	if len(body.IDs) > maxBatchMoveGroupItems {
+		apierror.Write(c, apierror.Validation("ids", fmt.Sprintf("too many ids, limit is %d", maxBatchMoveGroupItems)))
+		return
+	}
+	if body.AuthGroupID == nil {
+		apierror.Write(c, apierror.Validation("auth_group_id", "missing auth_group_id"))
+		return
+	}
+	ids := make([]uint64, 0, len(body.IDs))
+	seen := make(map[uint64]struct{}, len(body.IDs))
+	for _, id := range body.IDs {
+		if id == 0 {
+			apierror.Write(c, apierror.Validation("ids", "invalid id 0"))
+			return
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	ctx := c.Request.Context()
+	groupIDs := body.AuthGroupID.Clean()
+	if values := groupIDs.Values(); len(values) > 0 {
+		var found []uint64
+		if errFind := h.db.WithContext(ctx).Model(&models.AuthGroup{}).
+			Where("id IN ?", values).
+			Pluck("id", &found).Error; errFind != nil {
+			apierror.Write(c, apierror.Internal("query auth groups failed"))
+			return
+		}
+		if missingGroups := missingIDs(values, found); len(missingGroups) > 0 {
+			apierror.Write(c, apierror.Validation("auth_group_id", "auth groups not found").With("missing_ids", missingGroups))
+			return
+		}
+	}
+
+	var existing []uint64
+	if errFind := h.db.WithContext(ctx).Model(&models.Auth{}).
+		Where("id IN ?", ids).
+		Pluck("id", &existing).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("query auth files failed"))
+		return
+	}
+	var updated int64
+	if len(existing) > 0 {
+		// One statement with one updated_at, so the watcher reloads the auths once.
+		res := h.db.WithContext(ctx).Model(&models.Auth{}).
+			Where("id IN ?", existing).
+			Updates(map[string]any{"auth_group_id": groupIDs, "updated_at": time.Now().UTC()})
+		if res.Error != nil {
+			apierror.Write(c, apierror.Internal("move auth files failed"))
+			return
+		}
+		updated = res.RowsAffected
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": updated, "missing_ids": missingIDs(ids, existing)})
+}
+
+// missingIDs returns the entries of want that are not in found, in want order.
+func missingIDs(want, found []uint64) []uint64 {
+	present := make(map[uint64]struct{}, len(found))
+	for _, id := range found {
+		present[id] = struct{}{}
+	}
+	missing := make([]uint64, 0)
+	for _, id := range want {
+		if _, ok := present[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// Delete moves an auth file entry to the trash, from where it can be restored until
+// AUTH_TRASH_RETENTION_DAYS have passed.
 func (h *AuthFileHandler) Delete(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
-	res := h.db.WithContext(c.Request.Context()).Delete(&models.Auth{}, id)
-	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+	if errDelete := h.DeleteByID(c.Request.Context(), id); errDelete != nil {
+		if errors.Is(errDelete, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("not found"))
+			return
+		}
+		apierror.Write(c, apierror.Internal("delete failed"))
 		return
 	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteByID moves a live auth file entry to the trash, returning gorm.ErrRecordNotFound
+// when there is none. It also runs approved delete_auth_file pending actions.
+func (h *AuthFileHandler) DeleteByID(ctx context.Context, id uint64) error {
+	// updated_at is bumped so the watcher notices the entry left the live set.
+	now := time.Now().UTC()
+	res := h.db.WithContext(ctx).Model(&models.Auth{}).Where("id = ?", id).
+		Updates(map[string]any{"deleted_at": now, "updated_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
+		return gorm.ErrRecordNotFound
 	}
-	c.Status(http.StatusNoContent)
+	return nil
 }
 
 // SetAvailable marks an auth file as available.
 func (h *AuthFileHandler) SetAvailable(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
@@ -514,11 +924,11 @@ func (h *AuthFileHandler) SetAvailable(c *gin.Context) {
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Auth{}).Where("id = ?", id).
 		Updates(map[string]any{"is_available": true, "updated_at": now})
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -528,7 +938,7 @@ func (h *AuthFileHandler) SetAvailable(c *gin.Context) {
 func (h *AuthFileHandler) SetUnavailable(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
@@ -536,11 +946,11 @@ func (h *AuthFileHandler) SetUnavailable(c *gin.Context) {
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Auth{}).Where("id = ?", id).
 		Updates(map[string]any{"is_available": false, "updated_at": now})
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -556,7 +966,7 @@ func (h *AuthFileHandler) ListTypes(c *gin.Context) {
 		Where(fmt.Sprintf("%s IS NOT NULL AND %s != ''", typeExpr, typeExpr)).
 		Order("content_type").
 		Pluck("content_type", &types).Error; errQuery != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list types failed"})
+		apierror.Write(c, apierror.Internal("list types failed"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"types": types})
@@ -625,6 +1035,48 @@ func loadAuthGroupMap(ctx context.Context, db *gorm.DB, rows []models.Auth) (map
 	return groupMap, nil
 }
 
+// authRecentUsageWindow is the window counted by the recent_requests field of auth files.
+const authRecentUsageWindow = 24 * time.Hour
+
+// authUsageStats summarizes the usage rows recorded against one auth.
+type authUsageStats struct {
+	AuthID         uint64
+	LastUsedAt     dbutil.AggregateTime // Newest requested_at.
+	RecentRequests int64                // Requests at or after the window start.
+}
+
+// loadAuthUsageStats aggregates usage per auth in one grouped query over usages.auth_id.
+// Usage recorded while an auth key had no record only counts once it is relinked.
+func loadAuthUsageStats(ctx context.Context, db *gorm.DB, rows []models.Auth, since time.Time) (map[uint64]authUsageStats, error) {
+	out := make(map[uint64]authUsageStats, len(rows))
+	if len(rows) == 0 {
+		return out, nil
+	}
+	ids := make([]uint64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	var stats []authUsageStats
+	if errFind := db.WithContext(ctx).Model(&models.Usage{}).
+		Select("auth_id, MAX(requested_at) AS last_used_at, SUM(CASE WHEN requested_at >= ? THEN 1 ELSE 0 END) AS recent_requests", since).
+		Where("auth_id IN ?", ids).
+		Group("auth_id").
+		Scan(&stats).Error; errFind != nil {
+		return nil, errFind
+	}
+	for _, stat := range stats {
+		out[stat.AuthID] = stat
+	}
+	return out, nil
+}
+
+// addAuthUsageStats sets the usage fields of an auth file response item.
+func addAuthUsageStats(item gin.H, stats authUsageStats) {
+	item["last_used_at"] = stats.LastUsedAt.Ptr()
+	item["recent_requests"] = stats.RecentRequests
+	item["in_use"] = stats.RecentRequests > 0
+}
+
 func buildAuthGroupSummaries(ids models.AuthGroupIDs, groupMap map[uint64]models.AuthGroup) []gin.H {
 	values := ids.Values()
 	if len(values) == 0 {
@@ -643,3 +1095,22 @@ func buildAuthGroupSummaries(ids models.AuthGroupIDs, groupMap map[uint64]models
 	}
 	return out
 }
+
+// parseUintList parses a comma-separated list of IDs, skipping blank, invalid and duplicate entries.
+func parseUintList(raw string) []uint64 {
+	parts := strings.Split(raw, ",")
+	ids := make([]uint64, 0, len(parts))
+	seen := make(map[uint64]struct{}, len(parts))
+	for _, part := range parts {
+		id, errParse := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if errParse != nil || id == 0 {
+			continue
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	return ids
+}
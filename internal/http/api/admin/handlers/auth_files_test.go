@@ -0,0 +1,696 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestAuthFileUpdatePrioritiesIsAtomic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authprio_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	decayed := -10
+	auths := []models.Auth{
+		{Key: "a.json", Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true, EffectivePriority: &decayed, QuotaStrikes: 2},
+		{Key: "b.json", Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true},
+	}
+	if errCreate := db.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.PATCH("/v0/admin/auth-files/priorities", NewAuthFileHandler(db).UpdatePriorities)
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/v0/admin/auth-files/priorities", strings.NewReader(body)))
+		return w
+	}
+
+	body := fmt.Sprintf(`{"items":[{"id":%d,"priority":3},{"id":999,"priority":1}]}`, auths[0].ID)
+	if w := patch(body); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "999") {
+		t.Fatalf("expected unknown id to fail the batch, got %d: %s", w.Code, w.Body.String())
+	}
+	var first models.Auth
+	if errFind := db.First(&first, auths[0].ID).Error; errFind != nil || first.Priority != 0 {
+		t.Fatalf("expected rollback, got priority %d %v", first.Priority, errFind)
+	}
+
+	body = fmt.Sprintf(`{"items":[{"id":%d,"priority":3},{"id":%d,"priority":7}]}`, auths[0].ID, auths[1].ID)
+	if w := patch(body); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var rows []models.Auth
+	if errFind := db.Order("id ASC").Find(&rows).Error; errFind != nil {
+		t.Fatalf("load auths: %v", errFind)
+	}
+	if rows[0].Priority != 3 || rows[1].Priority != 7 {
+		t.Fatalf("unexpected priorities %d %d", rows[0].Priority, rows[1].Priority)
+	}
+	if rows[0].EffectivePriority != nil || rows[0].QuotaStrikes != 0 {
+		t.Fatalf("expected decay to be cleared, got %v %d", rows[0].EffectivePriority, rows[0].QuotaStrikes)
+	}
+
+	if w := patch(`{"items":[{"id":1}]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected missing priority to be rejected, got %d", w.Code)
+	}
+}
+
+func TestAuthFileListFiltersByAnyGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authgroups_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}, &models.AuthGroup{}, &models.Usage{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	group := func(id uint64) *uint64 { return &id }
+	auths := []models.Auth{
+		{Key: "a.json", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{group(1)}},
+		{Key: "b.json", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{group(2), group(3)}},
+		{Key: "c.json", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{group(4)}},
+	}
+	if errCreate := db.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.GET("/v0/admin/auth-files", NewAuthFileHandler(db).List)
+	list := func(query string) []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/auth-files"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			AuthFiles []struct {
+				Key string `json:"key"`
+			} `json:"auth_files"`
+		}
+		if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+			t.Fatalf("decode: %v", errDecode)
+		}
+		keys := make([]string, 0, len(resp.AuthFiles))
+		for _, item := range resp.AuthFiles {
+			keys = append(keys, item.Key)
+		}
+		return keys
+	}
+
+	if got := strings.Join(list("?auth_group_id=1,3"), ","); got != "a.json,b.json" {
+		t.Fatalf("expected auths in either group, got %s", got)
+	}
+	if got := strings.Join(list("?auth_group_id=4"), ","); got != "c.json" {
+		t.Fatalf("expected single group filter to keep working, got %s", got)
+	}
+	if got := list("?auth_group_id=x,,"); len(got) != 3 {
+		t.Fatalf("expected invalid ids to be ignored, got %v", got)
+	}
+}
+
+func TestAuthFileCreateDuplicateKeyReturnsConflictCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authdup_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	r := gin.New()
+	r.POST("/v0/admin/auth-files", NewAuthFileHandler(db).Create)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files", strings.NewReader(body)))
+		return w
+	}
+
+	if w := post(`{"key":"a.json","content":{"type":"codex","access_token":"t"}}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	w := post(`{"key":"a.json","content":{"type":"codex","access_token":"t"}}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	var payload map[string]string
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &payload); errDecode != nil {
+		t.Fatalf("decode response: %v", errDecode)
+	}
+	if payload["code"] != "conflict" || payload["field"] != "key" || payload["error"] != "key already exists" {
+		t.Fatalf("unexpected error body %v", payload)
+	}
+
+	w = post(`{"content":{}}`)
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &payload); errDecode != nil {
+		t.Fatalf("decode response: %v", errDecode)
+	}
+	if w.Code != http.StatusBadRequest || payload["code"] != "validation_failed" || payload["field"] != "key" {
+		t.Fatalf("unexpected validation error %d %v", w.Code, payload)
+	}
+}
+
+func TestAuthFileTagsNormalizeFilterAndCount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authtags_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}, &models.AuthGroup{}, &models.ProviderAPIKey{}, &models.Usage{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	if errCreate := db.Create(&models.ProviderAPIKey{Provider: "openai", Tags: models.Tags{"team-a"}}).Error; errCreate != nil {
+		t.Fatalf("create provider key: %v", errCreate)
+	}
+
+	authHandler := NewAuthFileHandler(db)
+	r := gin.New()
+	r.POST("/v0/admin/auth-files", authHandler.Create)
+	r.GET("/v0/admin/auth-files", authHandler.List)
+	r.GET("/v0/admin/tags", NewTagHandler(db).List)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := serve(http.MethodPost, "/v0/admin/auth-files", `{"key":"a.json","tags":[" Team-A ","team-a","owner:bob",""]}`); w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"tags":["team-a","owner:bob"]`) {
+		t.Fatalf("expected normalized tags, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodPost, "/v0/admin/auth-files", `{"key":"b.json"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	long := strings.Repeat("x", models.MaxTagLength+1)
+	if w := serve(http.MethodPost, "/v0/admin/auth-files", `{"key":"c.json","tags":["`+long+`"]}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"tags"`) {
+		t.Fatalf("expected tags validation error, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := serve(http.MethodGet, "/v0/admin/auth-files?tags=OWNER:bob,missing", "")
+	var listed struct {
+		AuthFiles []struct {
+			Key string `json:"key"`
+		} `json:"auth_files"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &listed); errDecode != nil {
+		t.Fatalf("decode list: %v", errDecode)
+	}
+	if len(listed.AuthFiles) != 1 || listed.AuthFiles[0].Key != "a.json" {
+		t.Fatalf("expected only a.json, got %s", w.Body.String())
+	}
+
+	w = serve(http.MethodGet, "/v0/admin/tags", "")
+	var tags struct {
+		Tags []tagSummary `json:"tags"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &tags); errDecode != nil {
+		t.Fatalf("decode tags: %v", errDecode)
+	}
+	want := []tagSummary{
+		{Tag: "team-a", AuthFiles: 1, ProviderAPIKeys: 1, Count: 2},
+		{Tag: "owner:bob", AuthFiles: 1, Count: 1},
+	}
+	if fmt.Sprint(tags.Tags) != fmt.Sprint(want) {
+		t.Fatalf("unexpected tags %+v", tags.Tags)
+	}
+}
+
+func TestAuthFileImportModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authimport_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	groupID := uint64(7)
+	existing := models.Auth{
+		Key:         "a.json",
+		AuthGroupID: models.AuthGroupIDs{&groupID},
+		ProxyURL:    "http://proxy.local:8080",
+		Content:     datatypes.JSON(`{"id":"a.json","type":"codex","access_token":"old"}`),
+		IsAvailable: true,
+		RateLimit:   5,
+		Priority:    3,
+	}
+	if errCreate := db.Create(&existing).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+
+	type upload struct{ name, body string }
+	importFiles := func(mode string, files ...upload) importAuthFilesResponse {
+		var form bytes.Buffer
+		writer := multipart.NewWriter(&form)
+		if mode != "" {
+			_ = writer.WriteField("mode", mode)
+		}
+		for _, f := range files {
+			part, errPart := writer.CreateFormFile("files", f.name)
+			if errPart != nil {
+				t.Fatalf("create form file: %v", errPart)
+			}
+			_, _ = part.Write([]byte(f.body))
+		}
+		_ = writer.Close()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files/import", &form)
+		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+		NewAuthFileHandler(db).Import(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("mode %q: expected 200, got %d: %s", mode, w.Code, w.Body.String())
+		}
+		var resp importAuthFilesResponse
+		if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+			t.Fatalf("decode response: %v", errDecode)
+		}
+		return resp
+	}
+	load := func(key string) models.Auth {
+		var row models.Auth
+		if errFind := db.Where("key = ?", key).First(&row).Error; errFind != nil {
+			t.Fatalf("load %s: %v", key, errFind)
+		}
+		return row
+	}
+	token := func(row models.Auth) string {
+		var content map[string]any
+		_ = json.Unmarshal(row.Content, &content)
+		value, _ := content["access_token"].(string)
+		return value
+	}
+
+	// Default skip mode leaves the existing row alone; the repeated key in the batch
+	// collides with the first copy and is skipped too.
+	resp := importFiles("",
+		upload{"a.json", `{"id":"a.json","type":"codex","access_token":"new"}`},
+		upload{"b.json", `{"id":"b.json","type":"codex","access_token":"first"}`},
+		upload{"b-copy.json", `{"id":"b.json","type":"codex","access_token":"second"}`},
+	)
+	if resp.Imported != 1 || resp.Skipped != 2 || resp.Overwritten != 0 || len(resp.Failed) != 0 {
+		t.Fatalf("skip: unexpected response %+v", resp)
+	}
+	if got := token(load("a.json")); got != "old" {
+		t.Fatalf("skip: existing content replaced with %q", got)
+	}
+	if got := token(load("b.json")); got != "first" {
+		t.Fatalf("skip: batch duplicate replaced content with %q", got)
+	}
+
+	// Merge replaces content but keeps routing settings.
+	resp = importFiles("merge", upload{"a.json", `{"id":"a.json","type":"codex","access_token":"merged"}`})
+	if resp.Imported != 0 || resp.Overwritten != 1 || resp.Skipped != 0 {
+		t.Fatalf("merge: unexpected response %+v", resp)
+	}
+	merged := load("a.json")
+	if token(merged) != "merged" || merged.ProxyURL != "http://proxy.local:8080" || merged.RateLimit != 5 || merged.Priority != 3 {
+		t.Fatalf("merge: unexpected row %+v", merged)
+	}
+	if primary := merged.AuthGroupID.Primary(); primary == nil || *primary != groupID {
+		t.Fatalf("merge: auth group changed to %v", merged.AuthGroupID.Values())
+	}
+
+	// Overwrite replaces content, proxy and auth groups; within one batch the later
+	// file wins.
+	resp = importFiles("overwrite",
+		upload{"a.json", `{"id":"a.json","type":"codex","access_token":"over-1"}`},
+		upload{"a-copy.json", `{"id":"a.json","type":"codex","access_token":"over-2"}`},
+	)
+	if resp.Imported != 0 || resp.Overwritten != 2 || resp.Skipped != 0 {
+		t.Fatalf("overwrite: unexpected response %+v", resp)
+	}
+	overwritten := load("a.json")
+	if token(overwritten) != "over-2" || overwritten.ProxyURL != "" || len(overwritten.AuthGroupID.Values()) != 0 {
+		t.Fatalf("overwrite: unexpected row %+v", overwritten)
+	}
+
+	// Unknown modes are rejected before any file is read.
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	_ = writer.WriteField("mode", "replace")
+	part, _ := writer.CreateFormFile("files", "c.json")
+	_, _ = part.Write([]byte(`{"id":"c.json"}`))
+	_ = writer.Close()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files/import", &form)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	NewAuthFileHandler(db).Import(c)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid mode to be rejected, got %d", w.Code)
+	}
+}
+
+func TestAuthFileContentValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authvalidate_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	r := gin.New()
+	r.POST("/v0/admin/auth-files", NewAuthFileHandler(db).Create)
+	r.POST("/v0/admin/auth-files/import", NewAuthFileHandler(db).Import)
+	post := func(body string) (int, map[string]string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files", strings.NewReader(body)))
+		var payload map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &payload)
+		return w.Code, payload
+	}
+
+	cases := []struct{ content, err string }{
+		{`{}`, "type is required"},
+		{`{"type":"codex","refresh_token":"r"}`, "codex auth: access_token is required"},
+		{`{"type":"claude-code","access_token":" "}`, "claude-code auth: access_token is required"},
+		{`{"type":"gemini","token":"raw"}`, "gemini auth: token is required"},
+		{`{"type":"gemini","token":{"expiry":"soon"}}`, "gemini auth: token.access_token or token.refresh_token is required"},
+		{`{"type":"iflow","access_token":"a"}`, "iflow auth: api_key is required"},
+	}
+	for i, tc := range cases {
+		code, payload := post(fmt.Sprintf(`{"key":"bad-%d.json","content":%s}`, i, tc.content))
+		if code != http.StatusBadRequest || payload["field"] != "content" || payload["error"] != tc.err {
+			t.Fatalf("content %s: unexpected response %d %v", tc.content, code, payload)
+		}
+	}
+	for i, content := range []string{
+		`{"type":"gemini","token":{"refresh_token":"r"}}`,
+		`{"type":"qwen","access_token":"a"}`,
+		`{"type":"antigravity"}`,
+	} {
+		if code, payload := post(fmt.Sprintf(`{"key":"good-%d.json","content":%s}`, i, content)); code != http.StatusCreated {
+			t.Fatalf("content %s: expected 201, got %d %v", content, code, payload)
+		}
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for name, body := range map[string]string{
+		"ok.json":  `{"type":"codex","access_token":"a"}`,
+		"bad.json": `{"provider":"qwen","refresh_token":"r"}`,
+	} {
+		part, _ := writer.CreateFormFile("files", name)
+		_, _ = part.Write([]byte(body))
+	}
+	_ = writer.Close()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files/import", &form)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	r.ServeHTTP(w, req)
+	var resp importAuthFilesResponse
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode import response: %v", errDecode)
+	}
+	if resp.Imported != 1 || len(resp.Failed) != 1 || resp.Failed[0].File != "bad.json" || resp.Failed[0].Error != "qwen auth: access_token is required" {
+		t.Fatalf("unexpected import response %+v", resp)
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.AuthFileValidationKey: json.RawMessage(`"warn"`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	if code, payload := post(`{"key":"warned.json","content":{"type":"codex"}}`); code != http.StatusCreated {
+		t.Fatalf("warn mode: expected 201, got %d %v", code, payload)
+	}
+}
+
+func TestAuthFileListFiltersByEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auths := []models.Auth{
+		{Key: "alice.json", Content: datatypes.JSON(`{"type":"gemini","email":"Alice@Example.com"}`), IsAvailable: true},
+		{Key: "bob.json", Content: datatypes.JSON(`{"type":"claude","email":"bob@example.com"}`), IsAvailable: true},
+		{Key: "alice-old.json", Content: datatypes.JSON(`{"type":"codex","email":"alice@example.com.au"}`), IsAvailable: true},
+	}
+	if errCreate := db.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.GET("/v0/admin/auth-files", NewAuthFileHandler(db).List)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/auth-files?email=alice@example.COM", nil))
+	var listed struct {
+		AuthFiles []struct {
+			Key string `json:"key"`
+		} `json:"auth_files"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &listed); errDecode != nil {
+		t.Fatalf("decode list: %v", errDecode)
+	}
+	if len(listed.AuthFiles) != 1 || listed.AuthFiles[0].Key != "alice.json" {
+		t.Fatalf("expected only alice.json, got %s", w.Body.String())
+	}
+}
+
+func TestAuthFileBatchMoveGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authmove_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	groups := []models.AuthGroup{{Name: "old"}, {Name: "team-a"}, {Name: "team-b"}}
+	if errCreate := db.Create(&groups).Error; errCreate != nil {
+		t.Fatalf("create groups: %v", errCreate)
+	}
+	past := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	auths := []models.Auth{
+		{Key: "a.json", AuthGroupID: models.AuthGroupIDs{&groups[0].ID}, Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true, UpdatedAt: past},
+		{Key: "b.json", AuthGroupID: models.AuthGroupIDs{&groups[0].ID}, Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true, UpdatedAt: past},
+		{Key: "c.json", AuthGroupID: models.AuthGroupIDs{&groups[0].ID}, Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true, UpdatedAt: past},
+	}
+	if errCreate := db.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.POST("/v0/admin/auth-files/batch-move-group", NewAuthFileHandler(db).BatchMoveGroup)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/admin/auth-files/batch-move-group", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(fmt.Sprintf(`{"ids":[%d],"auth_group_id":[%d,999]}`, auths[0].ID, groups[1].ID))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "999") {
+		t.Fatalf("expected an unknown group to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = post(fmt.Sprintf(`{"ids":[%d,%d,%d,4242],"auth_group_id":[%d,%d,%d]}`, auths[0].ID, auths[1].ID, auths[0].ID, groups[1].ID, groups[2].ID, groups[1].ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Updated    int64    `json:"updated"`
+		MissingIDs []uint64 `json:"missing_ids"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode response: %v", errDecode)
+	}
+	if resp.Updated != 2 || len(resp.MissingIDs) != 1 || resp.MissingIDs[0] != 4242 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	var rows []models.Auth
+	if errFind := db.Order("id ASC").Find(&rows).Error; errFind != nil {
+		t.Fatalf("load auths: %v", errFind)
+	}
+	for _, row := range rows[:2] {
+		values := row.AuthGroupID.Values()
+		if len(values) != 2 || values[0] != groups[1].ID || values[1] != groups[2].ID || !row.UpdatedAt.After(past) {
+			t.Fatalf("expected %s moved with a new updated_at, got %v %s", row.Key, values, row.UpdatedAt)
+		}
+	}
+	if values := rows[2].AuthGroupID.Values(); len(values) != 1 || values[0] != groups[0].ID || rows[2].UpdatedAt.After(past) {
+		t.Fatalf("expected c.json untouched, got %v %s", values, rows[2].UpdatedAt)
+	}
+}
+
+func TestAuthFileListReportsUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auths := []models.Auth{
+		{Key: "busy.json", Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true},
+		{Key: "stale.json", Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true},
+		{Key: "idle.json", Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true},
+	}
+	if errCreate := db.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	usages := []models.Usage{
+		{Provider: "codex", Model: "gpt-5", AuthID: &auths[0].ID, AuthKey: "busy.json", RequestedAt: now.Add(-2 * time.Hour)},
+		{Provider: "codex", Model: "gpt-5", AuthID: &auths[0].ID, AuthKey: "busy.json", RequestedAt: now.Add(-time.Minute)},
+		{Provider: "codex", Model: "gpt-5", AuthID: &auths[0].ID, AuthKey: "busy.json", RequestedAt: now.Add(-48 * time.Hour)},
+		{Provider: "codex", Model: "gpt-5", AuthID: &auths[1].ID, AuthKey: "stale.json", RequestedAt: now.Add(-72 * time.Hour)},
+	}
+	if errCreate := db.Create(&usages).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	type usageItem struct {
+		Key            string     `json:"key"`
+		LastUsedAt     *time.Time `json:"last_used_at"`
+		RecentRequests int64      `json:"recent_requests"`
+		InUse          bool       `json:"in_use"`
+	}
+	h := NewAuthFileHandler(db)
+	r := gin.New()
+	r.GET("/v0/admin/auth-files", h.List)
+	r.GET("/v0/admin/auth-files/:id", h.Get)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/auth-files", nil))
+	var listed struct {
+		AuthFiles []usageItem `json:"auth_files"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &listed); errDecode != nil {
+		t.Fatalf("decode list: %v: %s", errDecode, w.Body.String())
+	}
+	byKey := make(map[string]usageItem, len(listed.AuthFiles))
+	for _, item := range listed.AuthFiles {
+		byKey[item.Key] = item
+	}
+	busy := byKey["busy.json"]
+	if busy.LastUsedAt == nil || !busy.LastUsedAt.Equal(now.Add(-time.Minute)) || busy.RecentRequests != 2 || !busy.InUse {
+		t.Fatalf("unexpected busy.json usage %+v", busy)
+	}
+	stale := byKey["stale.json"]
+	if stale.LastUsedAt == nil || !stale.LastUsedAt.Equal(now.Add(-72*time.Hour)) || stale.RecentRequests != 0 || stale.InUse {
+		t.Fatalf("unexpected stale.json usage %+v", stale)
+	}
+	if idle := byKey["idle.json"]; idle.LastUsedAt != nil || idle.RecentRequests != 0 || idle.InUse {
+		t.Fatalf("unexpected idle.json usage %+v", idle)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v0/admin/auth-files/%d", auths[0].ID), nil))
+	var got usageItem
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &got); errDecode != nil {
+		t.Fatalf("decode get: %v", errDecode)
+	}
+	if got.LastUsedAt == nil || got.RecentRequests != 2 {
+		t.Fatalf("unexpected get usage %s", w.Body.String())
+	}
+}
+
+func TestAuthFileTrashAndRestore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authtrash_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.AuthGroup{}, &models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	h := NewAuthFileHandler(db)
+	r := gin.New()
+	r.POST("/v0/admin/auth-files", h.Create)
+	r.GET("/v0/admin/auth-files", h.List)
+	r.GET("/v0/admin/auth-files/trash", h.Trash)
+	r.DELETE("/v0/admin/auth-files/:id", h.Delete)
+	r.POST("/v0/admin/auth-files/:id/restore", h.Restore)
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	const body = `{"key":"a.json","content":{"type":"codex","access_token":"t"}}`
+	if w := send(http.MethodPost, "/v0/admin/auth-files", body); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var original models.Auth
+	if errFind := db.Where("key = ?", "a.json").First(&original).Error; errFind != nil {
+		t.Fatalf("load auth: %v", errFind)
+	}
+	if w := send(http.MethodDelete, fmt.Sprintf("/v0/admin/auth-files/%d", original.ID), ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodDelete, fmt.Sprintf("/v0/admin/auth-files/%d", original.ID), ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected deleting a trashed entry to 404, got %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/v0/admin/auth-files", ""); strings.Contains(w.Body.String(), "a.json") {
+		t.Fatalf("expected trashed entries to be hidden from the list, got %s", w.Body.String())
+	}
+	w := send(http.MethodGet, "/v0/admin/auth-files/trash", "")
+	var trash struct {
+		AuthFiles []trashedAuthFile `json:"auth_files"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &trash); errDecode != nil {
+		t.Fatalf("decode trash: %v", errDecode)
+	}
+	if len(trash.AuthFiles) != 1 || trash.AuthFiles[0].ID != original.ID || trash.AuthFiles[0].Type != "codex" || trash.AuthFiles[0].PurgeAt == nil {
+		t.Fatalf("unexpected trash %s", w.Body.String())
+	}
+
+	if w := send(http.MethodPost, "/v0/admin/auth-files", body); w.Code != http.StatusCreated {
+		t.Fatalf("expected the key to be reusable while trashed, got %d: %s", w.Code, w.Body.String())
+	}
+	restore := fmt.Sprintf("/v0/admin/auth-files/%d/restore", original.ID)
+	if w := send(http.MethodPost, restore, ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected restoring over a live key to conflict, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if errDelete := db.Where("key = ? AND id <> ?", "a.json", original.ID).Delete(&models.Auth{}).Error; errDelete != nil {
+		t.Fatalf("trash replacement: %v", errDelete)
+	}
+	if w := send(http.MethodPost, restore, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected restore to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var restored models.Auth
+	if errFind := db.First(&restored, original.ID).Error; errFind != nil || !restored.UpdatedAt.After(original.UpdatedAt) {
+		t.Fatalf("expected the restored entry to be live and touched, got %+v (%v)", restored, errFind)
+	}
+	if w := send(http.MethodPost, restore, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected restoring a live entry to 404, got %d", w.Code)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
@@ -25,10 +26,41 @@ func NewAuthGroupHandler(db *gorm.DB) *AuthGroupHandler {
 
 // createAuthGroupRequest defines the request body for auth group creation.
 type createAuthGroupRequest struct {
-	Name        string              `json:"name"`
-	IsDefault   bool                `json:"is_default"`
-	RateLimit   int                 `json:"rate_limit"`
-	UserGroupID models.UserGroupIDs `json:"user_group_id"`
+	Name            string                `json:"name"`
+	IsDefault       bool                  `json:"is_default"`
+	RateLimit       int                   `json:"rate_limit"`
+	DefaultProxyURL string                `json:"default_proxy_url"`
+	UserGroupID     models.UserGroupIDs   `json:"user_group_id"`
+	ActiveHours     *authGroupActiveHours `json:"active_hours"`
+}
+
+// authGroupActiveHours is the daily window of an auth group in requests and responses.
+// Empty start and end clear the window.
+type authGroupActiveHours struct {
+	Start    string `json:"start"`    // Opening time as HH:MM.
+	End      string `json:"end"`      // Closing time as HH:MM, exclusive; before start to span midnight.
+	Timezone string `json:"timezone"` // IANA time zone; empty means UTC.
+}
+
+// normalizeActiveHours validates requested active hours; nil or empty start and end
+// clear them.
+func normalizeActiveHours(in *authGroupActiveHours) (authGroupActiveHours, error) {
+	if in == nil || (strings.TrimSpace(in.Start) == "" && strings.TrimSpace(in.End) == "") {
+		return authGroupActiveHours{}, nil
+	}
+	hours, errParse := models.ParseActiveHours(in.Start, in.End, in.Timezone)
+	if errParse != nil {
+		return authGroupActiveHours{}, errParse
+	}
+	return authGroupActiveHours{Start: hours.Start, End: hours.End, Timezone: hours.Timezone}, nil
+}
+
+// activeHoursResponse returns the group's active hours, or nil when it has none.
+func activeHoursResponse(group models.AuthGroup) *authGroupActiveHours {
+	if group.ActiveHoursStart == "" && group.ActiveHoursEnd == "" {
+		return nil
+	}
+	return &authGroupActiveHours{Start: group.ActiveHoursStart, End: group.ActiveHoursEnd, Timezone: group.ActiveHoursTimezone}
 }
 
 // Create creates a new auth group.
@@ -43,15 +75,29 @@ func (h *AuthGroupHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
 		return
 	}
+	defaultProxyURL, errProxy := normalizeDefaultProxyURL(body.DefaultProxyURL)
+	if errProxy != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid default_proxy_url"})
+		return
+	}
+	activeHours, errHours := normalizeActiveHours(body.ActiveHours)
+	if errHours != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid active_hours: " + errHours.Error()})
+		return
+	}
 
 	now := time.Now().UTC()
 	group := models.AuthGroup{
-		Name:        name,
-		IsDefault:   body.IsDefault,
-		RateLimit:   body.RateLimit,
-		UserGroupID: body.UserGroupID.Clean(),
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		Name:                name,
+		IsDefault:           body.IsDefault,
+		RateLimit:           body.RateLimit,
+		DefaultProxyURL:     defaultProxyURL,
+		UserGroupID:         body.UserGroupID.Clean(),
+		ActiveHoursStart:    activeHours.Start,
+		ActiveHoursEnd:      activeHours.End,
+		ActiveHoursTimezone: activeHours.Timezone,
+		CreatedAt:           now,
+		UpdatedAt:           now,
 	}
 
 	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
@@ -68,13 +114,15 @@ func (h *AuthGroupHandler) Create(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{
-		"id":            group.ID,
-		"name":          group.Name,
-		"is_default":    group.IsDefault,
-		"rate_limit":    group.RateLimit,
-		"user_group_id": group.UserGroupID.Clean(),
-		"created_at":    group.CreatedAt,
-		"updated_at":    group.UpdatedAt,
+		"id":                group.ID,
+		"name":              group.Name,
+		"is_default":        group.IsDefault,
+		"rate_limit":        group.RateLimit,
+		"default_proxy_url": group.DefaultProxyURL,
+		"user_group_id":     group.UserGroupID.Clean(),
+		"active_hours":      activeHoursResponse(group),
+		"created_at":        group.CreatedAt,
+		"updated_at":        group.UpdatedAt,
 	})
 }
 
@@ -104,13 +152,15 @@ func (h *AuthGroupHandler) List(c *gin.Context) {
 	out := make([]gin.H, 0, len(rows))
 	for _, row := range rows {
 		out = append(out, gin.H{
-			"id":            row.ID,
-			"name":          row.Name,
-			"is_default":    row.IsDefault,
-			"rate_limit":    row.RateLimit,
-			"user_group_id": row.UserGroupID.Clean(),
-			"created_at":    row.CreatedAt,
-			"updated_at":    row.UpdatedAt,
+			"id":                row.ID,
+			"name":              row.Name,
+			"is_default":        row.IsDefault,
+			"rate_limit":        row.RateLimit,
+			"default_proxy_url": row.DefaultProxyURL,
+			"user_group_id":     row.UserGroupID.Clean(),
+			"active_hours":      activeHoursResponse(row),
+			"created_at":        row.CreatedAt,
+			"updated_at":        row.UpdatedAt,
 		})
 	}
 	c.JSON(http.StatusOK, gin.H{"auth_groups": out})
@@ -133,22 +183,26 @@ func (h *AuthGroupHandler) Get(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"id":            group.ID,
-		"name":          group.Name,
-		"is_default":    group.IsDefault,
-		"rate_limit":    group.RateLimit,
-		"user_group_id": group.UserGroupID.Clean(),
-		"created_at":    group.CreatedAt,
-		"updated_at":    group.UpdatedAt,
+		"id":                group.ID,
+		"name":              group.Name,
+		"is_default":        group.IsDefault,
+		"rate_limit":        group.RateLimit,
+		"default_proxy_url": group.DefaultProxyURL,
+		"user_group_id":     group.UserGroupID.Clean(),
+		"active_hours":      activeHoursResponse(group),
+		"created_at":        group.CreatedAt,
+		"updated_at":        group.UpdatedAt,
 	})
 }
 
 // updateAuthGroupRequest defines the request body for auth group updates.
 type updateAuthGroupRequest struct {
-	Name        *string              `json:"name"`
-	IsDefault   *bool                `json:"is_default"`
-	RateLimit   *int                 `json:"rate_limit"`
-	UserGroupID *models.UserGroupIDs `json:"user_group_id"`
+	Name            *string               `json:"name"`
+	IsDefault       *bool                 `json:"is_default"`
+	RateLimit       *int                  `json:"rate_limit"`
+	DefaultProxyURL *string               `json:"default_proxy_url"`
+	UserGroupID     *models.UserGroupIDs  `json:"user_group_id"`
+	ActiveHours     *authGroupActiveHours `json:"active_hours"`
 }
 
 // Update modifies an auth group.
@@ -163,6 +217,24 @@ func (h *AuthGroupHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
 		return
 	}
+	var defaultProxyURL string
+	if body.DefaultProxyURL != nil {
+		normalized, errProxy := normalizeDefaultProxyURL(*body.DefaultProxyURL)
+		if errProxy != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid default_proxy_url"})
+			return
+		}
+		defaultProxyURL = normalized
+	}
+	var activeHours authGroupActiveHours
+	if body.ActiveHours != nil {
+		normalized, errHours := normalizeActiveHours(body.ActiveHours)
+		if errHours != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid active_hours: " + errHours.Error()})
+			return
+		}
+		activeHours = normalized
+	}
 
 	now := time.Now().UTC()
 	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
@@ -186,6 +258,14 @@ func (h *AuthGroupHandler) Update(c *gin.Context) {
 		if body.UserGroupID != nil {
 			updates["user_group_id"] = body.UserGroupID.Clean()
 		}
+		if body.DefaultProxyURL != nil {
+			updates["default_proxy_url"] = defaultProxyURL
+		}
+		if body.ActiveHours != nil {
+			updates["active_hours_start"] = activeHours.Start
+			updates["active_hours_end"] = activeHours.End
+			updates["active_hours_timezone"] = activeHours.Timezone
+		}
 
 		res := tx.Model(&models.AuthGroup{}).Where("id = ?", id).Updates(updates)
 		if res.Error != nil {
@@ -194,6 +274,9 @@ func (h *AuthGroupHandler) Update(c *gin.Context) {
 		if res.RowsAffected == 0 {
 			return gorm.ErrRecordNotFound
 		}
+		if body.DefaultProxyURL != nil || body.ActiveHours != nil {
+			return touchAuthGroupMembers(tx, id, now)
+		}
 		return nil
 	})
 	if errTx != nil {
@@ -214,15 +297,27 @@ func (h *AuthGroupHandler) Delete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	res := h.db.WithContext(c.Request.Context()).Delete(&models.AuthGroup{}, id)
-	if res.Error != nil {
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var group models.AuthGroup
+		if errFind := tx.Select("id", "default_proxy_url", "active_hours_start", "active_hours_end").First(&group, id).Error; errFind != nil {
+			return errFind
+		}
+		if errDelete := tx.Delete(&models.AuthGroup{}, id).Error; errDelete != nil {
+			return errDelete
+		}
+		if group.DefaultProxyURL != "" || activeHoursResponse(group) != nil {
+			return touchAuthGroupMembers(tx, id, time.Now().UTC())
+		}
+		return nil
+	})
+	if errTx != nil {
+		if errors.Is(errTx, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
 		return
 	}
-	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
-	}
 	c.Status(http.StatusNoContent)
 }
 
@@ -261,3 +356,46 @@ func (h *AuthGroupHandler) SetDefault(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
+
+// normalizeDefaultProxyURL validates a group default proxy; an empty value clears it.
+func normalizeDefaultProxyURL(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	return normalizeProxyURL(raw)
+}
+
+// touchAuthGroupMembers bumps updated_at on the group's auths so the watcher re-resolves
+// their proxy and active hours after the group changes.
+func touchAuthGroupMembers(tx *gorm.DB, groupID uint64, now time.Time) error {
+	return tx.Model(&models.Auth{}).
+		Where(dbutil.JSONArrayContainsExpr(tx, "auth_group_id"), dbutil.JSONArrayContainsValue(tx, groupID)).
+		Update("updated_at", now).Error
+}
+
+// authGroupDefaultProxyURL returns the first default proxy configured on the given groups,
+// in ascending group ID order.
+func authGroupDefaultProxyURL(ctx context.Context, db *gorm.DB, ids models.AuthGroupIDs) (string, error) {
+	groupIDs := make([]uint64, 0, len(ids))
+	for _, id := range ids.Clean() {
+		if id != nil {
+			groupIDs = append(groupIDs, *id)
+		}
+	}
+	if len(groupIDs) == 0 {
+		return "", nil
+	}
+	var group models.AuthGroup
+	errFind := db.WithContext(ctx).
+		Select("id", "default_proxy_url").
+		Where("id IN ? AND default_proxy_url <> ''", groupIDs).
+		Order("id ASC").
+		Take(&group).Error
+	if errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", errFind
+	}
+	return group.DefaultProxyURL, nil
+}
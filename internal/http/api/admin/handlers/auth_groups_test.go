@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestAuthGroupDefaultProxyURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authgroupproxy_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}, &models.AuthGroup{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	h := NewAuthGroupHandler(db)
+	r := gin.New()
+	r.POST("/auth-groups", h.Create)
+	r.PUT("/auth-groups/:id", h.Update)
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(http.MethodPost, "/auth-groups", `{"name":"eu","default_proxy_url":"ftp://nope"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid proxy to be rejected, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/auth-groups", `{"name":"eu","default_proxy_url":"socks5://eu-proxy:1080"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected create to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var group models.AuthGroup
+	if errFind := db.Where("name = ?", "eu").First(&group).Error; errFind != nil {
+		t.Fatalf("load group: %v", errFind)
+	}
+	if group.DefaultProxyURL != "socks5://eu-proxy:1080/" {
+		t.Fatalf("expected normalized proxy, got %q", group.DefaultProxyURL)
+	}
+
+	stale := time.Now().UTC().Add(-time.Hour)
+	member := models.Auth{Key: "member.json", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{&group.ID}, CreatedAt: stale, UpdatedAt: stale}
+	outsider := models.Auth{Key: "outsider.json", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{}, CreatedAt: stale, UpdatedAt: stale}
+	if errCreate := db.Create(&[]*models.Auth{&member, &outsider}).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	proxyURL, errProxy := authGroupDefaultProxyURL(t.Context(), db, models.AuthGroupIDs{&group.ID})
+	if errProxy != nil || proxyURL != group.DefaultProxyURL {
+		t.Fatalf("expected group proxy for members, got %q (%v)", proxyURL, errProxy)
+	}
+
+	if w := send(http.MethodPut, fmt.Sprintf("/auth-groups/%d", group.ID), `{"default_proxy_url":""}`); w.Code != http.StatusOK {
+		t.Fatalf("expected clearing the proxy to succeed, got %d", w.Code)
+	}
+	if errFind := db.First(&member, member.ID).Error; errFind != nil || !member.UpdatedAt.After(stale) {
+		t.Fatalf("expected member auth to be touched, got %v (%v)", member.UpdatedAt, errFind)
+	}
+	if errFind := db.First(&outsider, outsider.ID).Error; errFind != nil || !outsider.UpdatedAt.Equal(stale) {
+		t.Fatalf("expected other auths to stay untouched, got %v (%v)", outsider.UpdatedAt, errFind)
+	}
+}
+
+func TestAuthGroupActiveHours(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:authgrouphours_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}, &models.AuthGroup{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	h := NewAuthGroupHandler(db)
+	r := gin.New()
+	r.POST("/auth-groups", h.Create)
+	r.PUT("/auth-groups/:id", h.Update)
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"name":"night","active_hours":{"start":"00:00","end":"00:00"}}`,
+		`{"name":"night","active_hours":{"start":"0:00am","end":"08:00"}}`,
+		`{"name":"night","active_hours":{"start":"00:00","end":"08:00","timezone":"Nowhere/City"}}`,
+	} {
+		if w := send(http.MethodPost, "/auth-groups", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected %s to be rejected, got %d", body, w.Code)
+		}
+	}
+	w := send(http.MethodPost, "/auth-groups", `{"name":"night","active_hours":{"start":"00:00","end":"08:00","timezone":"UTC"}}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"active_hours":{"start":"00:00","end":"08:00","timezone":"UTC"}`) {
+		t.Fatalf("expected create to return the schedule, got %d: %s", w.Code, w.Body.String())
+	}
+	var group models.AuthGroup
+	if errFind := db.Where("name = ?", "night").First(&group).Error; errFind != nil {
+		t.Fatalf("load group: %v", errFind)
+	}
+	if _, ok := group.ActiveHours(); !ok {
+		t.Fatalf("expected stored active hours, got %+v", group)
+	}
+
+	if w := send(http.MethodPut, fmt.Sprintf("/auth-groups/%d", group.ID), `{"active_hours":{"start":"","end":""}}`); w.Code != http.StatusOK {
+		t.Fatalf("expected clearing the schedule to succeed, got %d", w.Code)
+	}
+	var cleared models.AuthGroup
+	if errFind := db.First(&cleared, group.ID).Error; errFind != nil {
+		t.Fatalf("reload group: %v", errFind)
+	}
+	if _, ok := cleared.ActiveHours(); ok {
+		t.Fatalf("expected the schedule to be cleared, got %+v", cleared)
+	}
+}
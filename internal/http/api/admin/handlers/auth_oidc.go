@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	// oidcStateCookie stores the signed login state between start and callback.
+	oidcStateCookie = "cpab_admin_oidc_state"
+	// oidcStateCookiePath limits the state cookie to the SSO endpoints.
+	oidcStateCookiePath = "/v0/admin/login/oidc"
+	// oidcStateTTL bounds how long an SSO attempt may take.
+	oidcStateTTL = 10 * time.Minute
+	// oidcProviderTTL controls how long discovery results are reused.
+	oidcProviderTTL = time.Hour
+)
+
+// oidcProviderEntry is a cached discovery result.
+type oidcProviderEntry struct {
+	provider *security.OIDCProvider
+	loadedAt time.Time
+}
+
+// oidcProviderCache reuses discovery and JWKS lookups across logins.
+type oidcProviderCache struct {
+	mu     sync.Mutex
+	items  map[string]oidcProviderEntry
+	client *http.Client
+}
+
+// oidcProviders caches providers by issuer.
+var oidcProviders = &oidcProviderCache{items: make(map[string]oidcProviderEntry)}
+
+// get returns the provider for issuer, running discovery when missing or stale.
+func (c *oidcProviderCache) get(ctx context.Context, issuer string) (*security.OIDCProvider, error) {
+	c.mu.Lock()
+	entry, ok := c.items[issuer]
+	client := c.client
+	c.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < oidcProviderTTL {
+		return entry.provider, nil
+	}
+	provider, errProvider := security.NewOIDCProvider(ctx, client, issuer)
+	if errProvider != nil {
+		return nil, errProvider
+	}
+	c.mu.Lock()
+	c.items[issuer] = oidcProviderEntry{provider: provider, loadedAt: time.Now()}
+	c.mu.Unlock()
+	return provider, nil
+}
+
+// oidcOAuthConfig builds the OAuth client configuration for provider.
+func oidcOAuthConfig(cfg security.OIDCSettings, provider *security.OIDCProvider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// LoginOIDCStart redirects the browser to the configured identity provider.
+func (h *AuthHandler) LoginOIDCStart(c *gin.Context) {
+	cfg := security.LoadOIDCSettings()
+	if !cfg.Configured() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sso login is not enabled"})
+		return
+	}
+	provider, errProvider := oidcProviders.get(c.Request.Context(), cfg.Issuer)
+	if errProvider != nil {
+		log.WithError(errProvider).Warn("admin oidc: load provider failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "sso provider unavailable"})
+		return
+	}
+
+	state, errState := security.GenerateRandomString(32)
+	nonce, errNonce := security.GenerateRandomString(32)
+	if errState != nil || errNonce != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sso login"})
+		return
+	}
+	loginState := security.OIDCLoginState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+		ReturnTo: sanitizeOIDCReturnTo(c.Query("return_to")),
+	}
+	stateToken, errToken := security.GenerateOIDCStateToken(h.jwtCfg.Secret, loginState, oidcStateTTL)
+	if errToken != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sso login"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, stateToken, int(oidcStateTTL.Seconds()), oidcStateCookiePath, "", oidcSecureCookie(cfg), true)
+	authURL := oidcOAuthConfig(cfg, provider).AuthCodeURL(
+		state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.S256ChallengeOption(loginState.Verifier),
+	)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// LoginOIDCCallback validates the identity provider response and issues an admin JWT.
+// MFA is left to the identity provider, so local TOTP and passkey checks do not apply.
+func (h *AuthHandler) LoginOIDCCallback(c *gin.Context) {
+	cfg := security.LoadOIDCSettings()
+	if !cfg.Configured() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sso login is not enabled"})
+		return
+	}
+
+	stateToken, errCookie := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcStateCookiePath, "", oidcSecureCookie(cfg), true)
+	if errCookie != nil || stateToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing sso state"})
+		return
+	}
+	loginState, errState := security.ParseOIDCStateToken(h.jwtCfg.Secret, stateToken)
+	if errState != nil || subtle.ConstantTimeCompare([]byte(loginState.State), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired sso state"})
+		return
+	}
+	if errParam := strings.TrimSpace(c.Query("error")); errParam != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sso login was denied"})
+		return
+	}
+	code := strings.TrimSpace(c.Query("code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing authorization code"})
+		return
+	}
+
+	provider, errProvider := oidcProviders.get(c.Request.Context(), cfg.Issuer)
+	if errProvider != nil {
+		log.WithError(errProvider).Warn("admin oidc: load provider failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "sso provider unavailable"})
+		return
+	}
+	ctx := context.WithValue(c.Request.Context(), oauth2.HTTPClient, provider.Client())
+	token, errExchange := oidcOAuthConfig(cfg, provider).Exchange(ctx, code, oauth2.VerifierOption(loginState.Verifier))
+	if errExchange != nil {
+		log.WithError(errExchange).Warn("admin oidc: code exchange failed")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sso code exchange failed"})
+		return
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing id token"})
+		return
+	}
+	identity, errVerify := provider.VerifyIDToken(ctx, rawIDToken, cfg.ClientID, loginState.Nonce)
+	if errVerify != nil {
+		log.WithError(errVerify).Warn("admin oidc: id token rejected")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid id token"})
+		return
+	}
+	if identity.Subject == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid id token"})
+		return
+	}
+	if !identity.EmailTrusted(cfg.TrustMissingEmailVerified) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "sso email is not verified"})
+		return
+	}
+	if !cfg.AllowsEmail(identity.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "email domain is not allowed"})
+		return
+	}
+
+	admin, errAdmin := h.findOIDCAdmin(c.Request.Context(), identity, cfg.AutoProvision)
+	if errAdmin != nil {
+		if errors.Is(errAdmin, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no admin account for this email"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query admin failed"})
+		return
+	}
+	if !admin.Active {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin account is disabled"})
+		return
+	}
+
+	if loginState.ReturnTo == "" {
+		h.respondWithAdminToken(c, admin)
+		return
+	}
+	adminToken, errToken := issueAdminToken(c, h.db, h.jwtCfg, admin)
+	if errToken != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	c.Redirect(http.StatusFound, loginState.ReturnTo+"#token="+url.QueryEscape(adminToken))
+}
+
+// findOIDCAdmin returns the admin bound to the identity issuer and subject. On a first SSO login it
+// matches an unbound admin by email case-insensitively and binds the identity to it. Usernames are
+// never matched, so an admin must have its email set to be reachable through SSO. When
+// autoProvision is set, a missing admin is created without permissions.
+func (h *AuthHandler) findOIDCAdmin(ctx context.Context, identity *security.OIDCIdentity, autoProvision bool) (models.Admin, error) {
+	db := h.db.WithContext(ctx)
+	var admin models.Admin
+	errFind := db.Where("oidc_issuer = ? AND oidc_subject = ?", identity.Issuer, identity.Subject).First(&admin).Error
+	if !errors.Is(errFind, gorm.ErrRecordNotFound) {
+		return admin, errFind
+	}
+
+	email := identity.Email
+	errFind = db.Where("LOWER(email) = ? AND COALESCE(oidc_subject, '') = ''", email).Order("id ASC").First(&admin).Error
+	if errFind == nil {
+		return admin, h.bindOIDCAdmin(ctx, &admin, identity)
+	}
+	if !errors.Is(errFind, gorm.ErrRecordNotFound) || !autoProvision {
+		return admin, errFind
+	}
+
+	// SSO admins never use the local password, so store an unguessable one.
+	password, errPassword := security.GenerateRandomString(48)
+	if errPassword != nil {
+		return models.Admin{}, errPassword
+	}
+	hash, errHash := security.HashPassword(password)
+	if errHash != nil {
+		return models.Admin{}, errHash
+	}
+	now := time.Now().UTC()
+	admin = models.Admin{
+		Username:    email,
+		Email:       email,
+		OIDCIssuer:  identity.Issuer,
+		OIDCSubject: identity.Subject,
+		Password:    hash,
+		Active:      true,
+		Permissions: datatypes.JSON("[]"),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if errCreate := db.Create(&admin).Error; errCreate != nil {
+		return models.Admin{}, errCreate
+	}
+	log.WithField("email", email).Info("admin oidc: provisioned admin account")
+	return admin, nil
+}
+
+// bindOIDCAdmin stores the identity issuer and subject on admin so later logins skip email matching.
+// It reports gorm.ErrRecordNotFound when a concurrent login bound the admin first.
+func (h *AuthHandler) bindOIDCAdmin(ctx context.Context, admin *models.Admin, identity *security.OIDCIdentity) error {
+	res := h.db.WithContext(ctx).Model(&models.Admin{}).
+		Where("id = ? AND COALESCE(oidc_subject, '') = ''", admin.ID).
+		Updates(map[string]any{
+			"oidc_issuer":  identity.Issuer,
+			"oidc_subject": identity.Subject,
+			"updated_at":   time.Now().UTC(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	admin.OIDCIssuer = identity.Issuer
+	admin.OIDCSubject = identity.Subject
+	log.WithFields(log.Fields{"admin_id": admin.ID, "issuer": identity.Issuer}).Info("admin oidc: bound sso identity")
+	return nil
+}
+
+// sanitizeOIDCReturnTo keeps only same-origin absolute paths to avoid open redirects.
+func sanitizeOIDCReturnTo(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.Contains(raw, "\\") {
+		return ""
+	}
+	parsed, errParse := url.Parse(raw)
+	if errParse != nil || parsed.Scheme != "" || parsed.Host != "" {
+		return ""
+	}
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// oidcSecureCookie reports whether the state cookie should be marked Secure.
+func oidcSecureCookie(cfg security.OIDCSettings) bool {
+	return strings.HasPrefix(strings.ToLower(cfg.RedirectURL), "https://")
+}
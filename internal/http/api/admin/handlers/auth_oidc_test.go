@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+// fakeIdP is a minimal OpenID Connect issuer for tests.
+type fakeIdP struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	subject       string
+	email         string
+	emailVerified *bool // Omitted from the ID token when nil.
+	nonce         string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, errKey := rsa.GenerateKey(rand.Reader, 2048)
+	if errKey != nil {
+		t.Fatalf("generate key: %v", errKey)
+	}
+	verified := true
+	idp := &fakeIdP{key: key, subject: "user-1", emailVerified: &verified}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if errParse := r.ParseForm(); errParse != nil || r.PostForm.Get("code") != "good-code" || r.PostForm.Get("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iss":   idp.server.URL,
+			"aud":   "client-1",
+			"sub":   idp.subject,
+			"email": idp.email,
+			"nonce": idp.nonce,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Minute).Unix(),
+		}
+		if idp.emailVerified != nil {
+			claims["email_verified"] = *idp.emailVerified
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "k1"
+		signed, errSign := token.SignedString(key)
+		if errSign != nil {
+			http.Error(w, errSign.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     signed,
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func setupOIDCLogin(t *testing.T, idp *fakeIdP, autoProvision bool, extra ...map[string]json.RawMessage) (*gorm.DB, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:oidc_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Admin{}, &models.AdminSession{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	values := map[string]json.RawMessage{
+		internalsettings.OIDCEnabledKey:        json.RawMessage(`true`),
+		internalsettings.OIDCIssuerKey:         json.RawMessage(`"` + idp.server.URL + `"`),
+		internalsettings.OIDCClientIDKey:       json.RawMessage(`"client-1"`),
+		internalsettings.OIDCClientSecretKey:   json.RawMessage(`"secret-1"`),
+		internalsettings.OIDCRedirectURLKey:    json.RawMessage(`"https://admin.example.com/v0/admin/login/oidc/callback"`),
+		internalsettings.OIDCAllowedDomainsKey: json.RawMessage(`["Example.com"]`),
+		internalsettings.OIDCAutoProvisionKey:  json.RawMessage(fmt.Sprintf("%t", autoProvision)),
+	}
+	for _, more := range extra {
+		for key, value := range more {
+			values[key] = value
+		}
+	}
+	internalsettings.StoreDBConfig(time.Now(), values)
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	handler := NewAuthHandler(db, config.JWTConfig{Secret: "jwt-secret", Expiry: time.Hour}, nil)
+	r := gin.New()
+	r.GET("/v0/admin/login/oidc/start", handler.LoginOIDCStart)
+	r.GET("/v0/admin/login/oidc/callback", handler.LoginOIDCCallback)
+	return db, r
+}
+
+// startOIDCLogin runs the start endpoint and returns the state cookie and authorize URL.
+func startOIDCLogin(t *testing.T, r *gin.Engine, idp *fakeIdP) (*http.Cookie, url.Values) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/login/oidc/start", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d: %s", w.Code, w.Body.String())
+	}
+	location, errParse := url.Parse(w.Header().Get("Location"))
+	if errParse != nil || !strings.HasPrefix(location.String(), idp.server.URL+"/authorize") {
+		t.Fatalf("unexpected authorize url %q", w.Header().Get("Location"))
+	}
+	query := location.Query()
+	if query.Get("client_id") != "client-1" || query.Get("code_challenge_method") != "S256" || query.Get("nonce") == "" {
+		t.Fatalf("unexpected authorize params %v", query)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("unexpected state cookie %+v", cookies)
+	}
+	idp.nonce = query.Get("nonce")
+	return cookies[0], query
+}
+
+func callbackOIDCLogin(r *gin.Engine, cookie *http.Cookie, state string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v0/admin/login/oidc/callback?code=good-code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCLoginMatchesAdminByEmail(t *testing.T) {
+	idp := newFakeIdP(t)
+	db, r := setupOIDCLogin(t, idp, false)
+	admin := models.Admin{Username: "alice", Email: "Alice@example.com", Password: "x", Active: true, Permissions: []byte("[]")}
+	if errCreate := db.Create(&admin).Error; errCreate != nil {
+		t.Fatalf("create admin: %v", errCreate)
+	}
+
+	idp.email = "alice@EXAMPLE.com"
+	cookie, query := startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, "forged"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected forged state to be rejected, got %d", w.Code)
+	}
+
+	w := callbackOIDCLogin(r, cookie, query.Get("state"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected login, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode response: %v", errDecode)
+	}
+	claims, errToken := security.ParseAdminToken("jwt-secret", resp.Token)
+	if errToken != nil || claims.AdminID != admin.ID {
+		t.Fatalf("expected token for admin %d, got %+v %v", admin.ID, claims, errToken)
+	}
+	if errSession := ValidateAdminSession(context.Background(), db, claims.ID, admin.ID); errSession != nil {
+		t.Fatalf("expected login to record a session, got %v", errSession)
+	}
+
+	idp.subject = "user-2"
+	idp.email = "bob@example.com"
+	cookie, query = startOIDCLogin(t, r, idp)
+	if w = callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusForbidden {
+		t.Fatalf("expected unknown admin to be rejected without auto-provisioning, got %d", w.Code)
+	}
+
+	// An admin whose username merely looks like the email is not matched.
+	if errCreate := db.Create(&models.Admin{Username: "frank@example.com", Password: "x", Active: true, Permissions: []byte("[]")}).Error; errCreate != nil {
+		t.Fatalf("create admin: %v", errCreate)
+	}
+	idp.subject = "user-3"
+	idp.email = "frank@example.com"
+	cookie, query = startOIDCLogin(t, r, idp)
+	if w = callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusForbidden {
+		t.Fatalf("expected username-only admin to be unreachable through sso, got %d", w.Code)
+	}
+}
+
+func TestOIDCLoginAutoProvisionsAllowedDomain(t *testing.T) {
+	idp := newFakeIdP(t)
+	db, r := setupOIDCLogin(t, idp, true)
+
+	idp.email = "carol@other.com"
+	cookie, query := startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusForbidden {
+		t.Fatalf("expected disallowed domain to be rejected, got %d", w.Code)
+	}
+
+	idp.email = "carol@example.com"
+	cookie, query = startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusOK {
+		t.Fatalf("expected login, got %d: %s", w.Code, w.Body.String())
+	}
+	var admin models.Admin
+	if errFind := db.Where("email = ?", "carol@example.com").First(&admin).Error; errFind != nil {
+		t.Fatalf("expected provisioned admin: %v", errFind)
+	}
+	if admin.IsSuperAdmin || string(admin.Permissions) != "[]" || !admin.Active {
+		t.Fatalf("expected provisioned admin without permissions, got %+v", admin)
+	}
+}
+
+func TestOIDCLoginRejectsMissingEmailVerified(t *testing.T) {
+	idp := newFakeIdP(t)
+	db, r := setupOIDCLogin(t, idp, true)
+	admin := models.Admin{Username: "root", Email: "root@example.com", Password: "x", Active: true, IsSuperAdmin: true, Permissions: []byte("[]")}
+	if errCreate := db.Create(&admin).Error; errCreate != nil {
+		t.Fatalf("create admin: %v", errCreate)
+	}
+
+	idp.email = "root@example.com"
+	idp.emailVerified = nil
+	cookie, query := startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusForbidden {
+		t.Fatalf("expected token without email_verified to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	verified := false
+	idp.emailVerified = &verified
+	cookie, query = startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusForbidden {
+		t.Fatalf("expected unverified email to be rejected, got %d", w.Code)
+	}
+	var count int64
+	db.Model(&models.Admin{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected no admin to be provisioned, got %d admins", count)
+	}
+}
+
+func TestOIDCLoginTrustsMissingEmailVerifiedWhenEnabled(t *testing.T) {
+	idp := newFakeIdP(t)
+	db, r := setupOIDCLogin(t, idp, false, map[string]json.RawMessage{
+		internalsettings.OIDCTrustMissingEmailVerifiedKey: json.RawMessage(`true`),
+	})
+	admin := models.Admin{Username: "dave", Email: "dave@example.com", Password: "x", Active: true, Permissions: []byte("[]")}
+	if errCreate := db.Create(&admin).Error; errCreate != nil {
+		t.Fatalf("create admin: %v", errCreate)
+	}
+
+	idp.email = "dave@example.com"
+	idp.emailVerified = nil
+	cookie, query := startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusOK {
+		t.Fatalf("expected opt-in to accept missing email_verified, got %d: %s", w.Code, w.Body.String())
+	}
+
+	verified := false
+	idp.emailVerified = &verified
+	cookie, query = startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusForbidden {
+		t.Fatalf("expected email_verified=false to stay rejected, got %d", w.Code)
+	}
+}
+
+func TestOIDCLoginBindsIssuerAndSubject(t *testing.T) {
+	idp := newFakeIdP(t)
+	db, r := setupOIDCLogin(t, idp, false)
+	admin := models.Admin{Username: "erin", Email: "erin@example.com", Password: "x", Active: true, Permissions: []byte("[]")}
+	if errCreate := db.Create(&admin).Error; errCreate != nil {
+		t.Fatalf("create admin: %v", errCreate)
+	}
+
+	idp.email = "erin@example.com"
+	cookie, query := startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusOK {
+		t.Fatalf("expected first login to match by email, got %d: %s", w.Code, w.Body.String())
+	}
+	var bound models.Admin
+	if errFind := db.First(&bound, admin.ID).Error; errFind != nil {
+		t.Fatalf("reload admin: %v", errFind)
+	}
+	if bound.OIDCIssuer != idp.server.URL || bound.OIDCSubject != "user-1" {
+		t.Fatalf("expected identity to be bound, got issuer %q subject %q", bound.OIDCIssuer, bound.OIDCSubject)
+	}
+
+	// Another subject claiming the same email cannot take over the bound admin.
+	idp.subject = "user-2"
+	cookie, query = startOIDCLogin(t, r, idp)
+	if w := callbackOIDCLogin(r, cookie, query.Get("state")); w.Code != http.StatusForbidden {
+		t.Fatalf("expected other subject to be rejected, got %d", w.Code)
+	}
+
+	// The bound subject keeps its admin after changing email at the issuer.
+	idp.subject = "user-1"
+	idp.email = "erin.new@example.com"
+	cookie, query = startOIDCLogin(t, r, idp)
+	w := callbackOIDCLogin(r, cookie, query.Get("state"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected bound subject to log in, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode response: %v", errDecode)
+	}
+	claims, errToken := security.ParseAdminToken("jwt-secret", resp.Token)
+	if errToken != nil || claims.AdminID != admin.ID {
+		t.Fatalf("expected token for admin %d, got %+v %v", admin.ID, claims, errToken)
+	}
+}
+
+func TestSanitizeOIDCReturnTo(t *testing.T) {
+	cases := map[string]string{
+		"/admin/sso?x=1#frag":      "/admin/sso?x=1",
+		"//evil.example.com":       "",
+		"https://evil.example.com": "",
+		"/\\evil.example.com":      "",
+		"relative":                 "",
+	}
+	for raw, want := range cases {
+		if got := sanitizeOIDCReturnTo(raw); got != want {
+			t.Fatalf("sanitize %q: expected %q, got %q", raw, want, got)
+		}
+	}
+}
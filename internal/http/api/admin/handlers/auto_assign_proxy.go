@@ -1,9 +1,7 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"strings"
 
@@ -15,11 +13,7 @@ import (
 
 // autoAssignProxyEnabled reports whether auto proxy assignment is enabled.
 func autoAssignProxyEnabled() bool {
-	raw, ok := internalsettings.DBConfigValue(internalsettings.AutoAssignProxyKey)
-	if !ok {
-		return internalsettings.DefaultAutoAssignProxy
-	}
-	return parseDBConfigBool(raw)
+	return internalsettings.GetBool(internalsettings.AutoAssignProxyKey)
 }
 
 // pickRandomProxyURL selects a random proxy URL from the proxy table.
@@ -46,39 +40,3 @@ func randomOrderExpr(db *gorm.DB) string {
 		return "RANDOM()"
 	}
 }
-
-// parseDBConfigBool parses a boolean from JSON config payloads.
-func parseDBConfigBool(raw json.RawMessage) bool {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return false
-	}
-	var parsedBool bool
-	if errUnmarshalBool := json.Unmarshal(raw, &parsedBool); errUnmarshalBool == nil {
-		return parsedBool
-	}
-	var parsedString string
-	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
-		trimmed := strings.TrimSpace(parsedString)
-		if trimmed == "" {
-			return false
-		}
-		if strings.EqualFold(trimmed, "true") || trimmed == "1" {
-			return true
-		}
-		return false
-	}
-	var parsedNumber float64
-	if errUnmarshalNumber := json.Unmarshal(raw, &parsedNumber); errUnmarshalNumber == nil {
-		return parsedNumber != 0
-	}
-	var wrapper struct {
-		Value json.RawMessage `json:"value"`
-	}
-	if errUnmarshalWrapper := json.Unmarshal(raw, &wrapper); errUnmarshalWrapper == nil {
-		if len(wrapper.Value) > 0 {
-			return parseDBConfigBool(wrapper.Value)
-		}
-	}
-	return false
-}
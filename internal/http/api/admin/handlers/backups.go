@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/audit"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/backup"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/jobs"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// BackupHandler takes database backups and serves the backup files kept in dir.
+type BackupHandler struct {
+	db  *gorm.DB
+	dir string
+}
+
+// NewBackupHandler constructs a BackupHandler for the backups in dir.
+func NewBackupHandler(db *gorm.DB, dir string) *BackupHandler {
+	return &BackupHandler{db: db, dir: dir}
+}
+
+// Create queues a backup job; the backup is listed once the job succeeds.
+func (h *BackupHandler) Create(c *gin.Context) {
+	jobs.Async(h.db, jobs.TypeBackup, func(c *gin.Context) (any, bool) {
+		return nil, true
+	})(c)
+}
+
+// List returns the kept backups, newest first, with the schedule that produces them.
+func (h *BackupHandler) List(c *gin.Context) {
+	files, errList := backup.List(h.dir)
+	if errList != nil {
+		apierror.Write(c, apierror.Internal("list backups failed"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"backups":         files,
+		"interval_hours":  internalsettings.GetInt(internalsettings.BackupIntervalHoursKey),
+		"retention_count": internalsettings.GetInt(internalsettings.BackupRetentionCountKey),
+	})
+}
+
+// Download serves one backup file and records an audit entry, since a backup holds every
+// credential stored in the database.
+func (h *BackupHandler) Download(c *gin.Context) {
+	name := strings.TrimSpace(c.Param("name"))
+	path, errPath := backup.Path(h.dir, name)
+	if errPath != nil {
+		switch {
+		case errors.Is(errPath, backup.ErrInvalidName):
+			apierror.Write(c, apierror.Validation("name", "invalid backup name"))
+		case errors.Is(errPath, os.ErrNotExist):
+			apierror.Write(c, apierror.NotFound("backup not found"))
+		default:
+			apierror.Write(c, apierror.Internal("read backup failed"))
+		}
+		return
+	}
+	if errAudit := audit.Record(c.Request.Context(), h.db, audit.Entry{
+		ActorType:  audit.ActorAdmin,
+		ActorID:    c.GetUint64("adminID"),
+		Action:     audit.ActionBackupDownload,
+		TargetType: audit.TargetBackup,
+		Detail: map[string]any{
+			"admin_username": c.GetString("adminUsername"),
+			"name":           name,
+		},
+		IP: c.ClientIP(),
+	}); errAudit != nil {
+		log.WithError(errAudit).Error("backup download: record audit entry failed")
+		apierror.Write(c, apierror.Internal("record audit failed"))
+		return
+	}
+	c.FileAttachment(path, name)
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalusage "github.com/router-for-me/CLIProxyAPIBusiness/internal/usage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// adjustmentEpsilon tolerates float rounding when comparing against original charges.
+const adjustmentEpsilon = 0.000001
+
+var (
+	// errAdjustmentUserNotFound reports an unknown user.
+	errAdjustmentUserNotFound = errors.New("user not found")
+	// errAdjustmentUsageNotFound reports a usage row that does not belong to the user.
+	errAdjustmentUsageNotFound = errors.New("usage not found")
+	// errAdjustmentExceedsCharges reports a credit larger than the user's original charges.
+	errAdjustmentExceedsCharges = errors.New("credit exceeds original charges")
+	// errAdjustmentNoTarget reports that no bill or prepaid card can absorb the adjustment.
+	errAdjustmentNoTarget = errors.New("no bill or prepaid card to adjust")
+)
+
+// BalanceAdjustmentHandler manages manual balance credits and debits.
+type BalanceAdjustmentHandler struct {
+	db *gorm.DB // Database handle for adjustment records.
+}
+
+// NewBalanceAdjustmentHandler constructs a balance adjustment handler.
+func NewBalanceAdjustmentHandler(db *gorm.DB) *BalanceAdjustmentHandler {
+	return &BalanceAdjustmentHandler{db: db}
+}
+
+// createAdjustmentRequest captures the payload for a balance adjustment.
+type createAdjustmentRequest struct {
+	Amount             float64 `json:"amount"`               // Positive credit or negative debit.
+	Reason             string  `json:"reason"`               // Support-facing explanation.
+	UsageID            *uint64 `json:"usage_id"`             // Optional usage row being refunded.
+	AllowExceedCharges bool    `json:"allow_exceed_charges"` // Permit credits beyond original charges.
+}
+
+// Create applies a credit or debit to the user's current bill or prepaid card and records it.
+func (h *BalanceAdjustmentHandler) Create(c *gin.Context) {
+	userID, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var body createAdjustmentRequest
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+	if body.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount cannot be zero"})
+		return
+	}
+	reason := strings.TrimSpace(body.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing reason"})
+		return
+	}
+
+	now := time.Now().UTC()
+	adjustment := models.BalanceAdjustment{
+		UserID:    userID,
+		AdminID:   c.GetUint64("adminID"),
+		UsageID:   body.UsageID,
+		Amount:    body.Amount,
+		Reason:    reason,
+		CreatedAt: now,
+	}
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if errFind := tx.Select("id").First(&user, userID).Error; errFind != nil {
+			if errors.Is(errFind, gorm.ErrRecordNotFound) {
+				return errAdjustmentUserNotFound
+			}
+			return errFind
+		}
+		if body.Amount > 0 {
+			remaining, chargedMicros, errCharges := creditableCharges(c.Request.Context(), tx, userID, body.UsageID, now)
+			if errCharges != nil {
+				return errCharges
+			}
+			if body.UsageID != nil {
+				adjustment.ChargedMicros = chargedMicros
+			}
+			if body.Amount > remaining+adjustmentEpsilon {
+				if !body.AllowExceedCharges {
+					return errAdjustmentExceedsCharges
+				}
+				adjustment.ExceedsCharges = true
+			}
+		}
+		targetType, targetID, errApply := applyAdjustment(tx, userID, body.Amount, now)
+		if errApply != nil {
+			return errApply
+		}
+		adjustment.TargetType = targetType
+		adjustment.TargetID = targetID
+		if body.Amount > 0 {
+			if errPay := payOverageDebtFromTarget(c.Request.Context(), tx, targetType, targetID, body.Amount); errPay != nil {
+				return errPay
+			}
+		}
+		return tx.Create(&adjustment).Error
+	})
+	if errTx != nil {
+		switch {
+		case errors.Is(errTx, errAdjustmentUserNotFound), errors.Is(errTx, errAdjustmentUsageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": errTx.Error()})
+		case errors.Is(errTx, errAdjustmentExceedsCharges):
+			c.JSON(http.StatusBadRequest, gin.H{"error": errTx.Error()})
+		case errors.Is(errTx, errAdjustmentNoTarget):
+			c.JSON(http.StatusConflict, gin.H{"error": errTx.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create adjustment failed"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, formatAdjustment(&adjustment))
+}
+
+// adjustmentListQuery defines paging for adjustment listings.
+type adjustmentListQuery struct {
+	Page     int `form:"page,default=1"`       // Page number (1-based).
+	PageSize int `form:"page_size,default=20"` // Page size.
+}
+
+// List returns a user's adjustments, newest first.
+func (h *BalanceAdjustmentHandler) List(c *gin.Context) {
+	userID, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var query adjustmentListQuery
+	if errBind := c.ShouldBindQuery(&query); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	q := h.db.WithContext(c.Request.Context()).Model(&models.BalanceAdjustment{}).Where("user_id = ?", userID)
+	var total int64
+	if errCount := q.Count(&total).Error; errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "count adjustments failed"})
+		return
+	}
+	var rows []models.BalanceAdjustment
+	if errFind := q.Order("created_at DESC, id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list adjustments failed"})
+		return
+	}
+	out := make([]gin.H, 0, len(rows))
+	for i := range rows {
+		out = append(out, formatAdjustment(&rows[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"adjustments": out,
+		"total":       total,
+		"page":        query.Page,
+		"page_size":   query.PageSize,
+	})
+}
+
+// creditableCharges returns how much can still be credited: the original charges
+// (for one usage row, or all of the user's usage) minus prior credits against them,
+// along with those charges in micros. Debits never raise the limit.
+//
+// USAGE_RETENTION_DAYS deletes raw usage rows, so charges are read from what it keeps:
+// the account total comes from usage_daily_rollups for purged days, and a refund of a
+// purged usage row uses the charge recorded on an earlier refund of that row. A row
+// purged before it was ever refunded cannot be checked and reports not found.
+func creditableCharges(ctx context.Context, tx *gorm.DB, userID uint64, usageID *uint64, now time.Time) (float64, int64, error) {
+	var chargedMicros int64
+	credited := tx.Model(&models.BalanceAdjustment{}).Where("user_id = ? AND amount > 0", userID)
+	if usageID != nil {
+		var usage models.Usage
+		errFind := tx.Select("id", "cost_micros").
+			Where("id = ? AND user_id = ?", *usageID, userID).
+			Take(&usage).Error
+		switch {
+		case errFind == nil:
+			chargedMicros = usage.CostMicros
+		case errors.Is(errFind, gorm.ErrRecordNotFound):
+			var recorded []int64
+			if errPluck := tx.Model(&models.BalanceAdjustment{}).
+				Where("user_id = ? AND usage_id = ? AND amount > 0", userID, *usageID).
+				Order("id ASC").
+				Limit(1).
+				Pluck("charged_micros", &recorded).Error; errPluck != nil {
+				return 0, 0, errPluck
+			}
+			if len(recorded) == 0 {
+				return 0, 0, errAdjustmentUsageNotFound
+			}
+			chargedMicros = recorded[0]
+		default:
+			return 0, 0, errFind
+		}
+		credited = credited.Where("usage_id = ?", *usageID)
+	} else {
+		byModel, errUsage := internalusage.UserUsageByModel(ctx, tx, userID, now)
+		if errUsage != nil {
+			return 0, 0, errUsage
+		}
+		for _, row := range byModel {
+			chargedMicros += row.CostMicros
+		}
+	}
+	var creditedTotal float64
+	if errSum := credited.Select("COALESCE(SUM(amount), 0)").Scan(&creditedTotal).Error; errSum != nil {
+		return 0, 0, errSum
+	}
+	return float64(chargedMicros)/1_000_000 - creditedTotal, chargedMicros, nil
+}
+
+// applyAdjustment moves amount into (or out of) the user's current paid bill, falling
+// back to their newest usable prepaid card, and returns the adjusted target.
+func applyAdjustment(tx *gorm.DB, userID uint64, amount float64, now time.Time) (string, uint64, error) {
+	var bills []models.Bill
+	if errFind := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND is_enabled = ? AND status = ?", userID, true, models.BillStatusPaid).
+		Where("period_start <= ? AND period_end >= ?", now, now).
+		Order("period_end DESC, id DESC").
+		Find(&bills).Error; errFind != nil {
+		return "", 0, errFind
+	}
+	for _, bill := range bills {
+		if amount < 0 && bill.LeftQuota+adjustmentEpsilon < -amount {
+			continue
+		}
+		q := tx.Model(&models.Bill{}).Where("id = ?", bill.ID)
+		if amount < 0 {
+			q = q.Where("left_quota >= ?", -amount-adjustmentEpsilon)
+		}
+		res := q.Updates(map[string]any{
+			"left_quota": gorm.Expr("left_quota + ?", amount),
+			"used_quota": gorm.Expr("CASE WHEN used_quota > ? THEN used_quota - ? ELSE 0 END", amount, amount),
+			"updated_at": now,
+		})
+		if res.Error != nil {
+			return "", 0, res.Error
+		}
+		if res.RowsAffected > 0 {
+			return models.AdjustmentTargetBill, bill.ID, nil
+		}
+	}
+
+	var cards []models.PrepaidCard
+	if errFind := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("redeemed_user_id = ? AND is_enabled = ? AND redeemed_at IS NOT NULL", userID, true).
+		Where("(expires_at IS NULL OR expires_at >= ?)", now).
+		Order("redeemed_at DESC, id DESC").
+		Find(&cards).Error; errFind != nil {
+		return "", 0, errFind
+	}
+	for _, card := range cards {
+		if amount < 0 && card.Balance+adjustmentEpsilon < -amount {
+			continue
+		}
+		q := tx.Model(&models.PrepaidCard{}).Where("id = ?", card.ID)
+		if amount < 0 {
+			q = q.Where("balance >= ?", -amount-adjustmentEpsilon)
+		}
+		res := q.Update("balance", gorm.Expr("balance + ?", amount))
+		if res.Error != nil {
+			return "", 0, res.Error
+		}
+		if res.RowsAffected > 0 {
+			return models.AdjustmentTargetPrepaidCard, card.ID, nil
+		}
+	}
+	return "", 0, errAdjustmentNoTarget
+}
+
+// payOverageDebtFromTarget settles the user's overage debt from up to amount just
+// credited to the adjusted bill or prepaid card.
+func payOverageDebtFromTarget(ctx context.Context, tx *gorm.DB, targetType string, targetID uint64, amount float64) error {
+	switch targetType {
+	case models.AdjustmentTargetBill:
+		var bill models.Bill
+		if errFind := tx.WithContext(ctx).First(&bill, targetID).Error; errFind != nil {
+			return errFind
+		}
+		_, errPay := billing.PayOverageDebtFromBill(ctx, tx, &bill, amount)
+		return errPay
+	case models.AdjustmentTargetPrepaidCard:
+		var card models.PrepaidCard
+		if errFind := tx.WithContext(ctx).First(&card, targetID).Error; errFind != nil {
+			return errFind
+		}
+		_, errPay := billing.PayOverageDebtFromCard(ctx, tx, &card, amount)
+		return errPay
+	default:
+		return nil
+	}
+}
+
+// formatAdjustment maps an adjustment model into a response payload.
+func formatAdjustment(row *models.BalanceAdjustment) gin.H {
+	return gin.H{
+		"id":              row.ID,
+		"user_id":         row.UserID,
+		"admin_id":        row.AdminID,
+		"usage_id":        row.UsageID,
+		"amount":          row.Amount,
+		"reason":          row.Reason,
+		"target_type":     row.TargetType,
+		"target_id":       row.TargetID,
+		"exceeds_charges": row.ExceedsCharges,
+		"charged_micros":  row.ChargedMicros,
+		"created_at":      row.CreatedAt,
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalusage "github.com/router-for-me/CLIProxyAPIBusiness/internal/usage"
+	"gorm.io/gorm"
+)
+
+func setupBalanceAdjustmentDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:adjustments_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(
+		&models.User{}, &models.Plan{}, &models.Bill{}, &models.PrepaidCard{}, &models.Usage{},
+		&models.UsageDailyRollup{}, &models.UsageRollupDay{}, &models.BalanceAdjustment{},
+	); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	return db
+}
+
+// postAdjustment returns a helper that posts adjustment bodies for userID.
+func postAdjustment(handler *BalanceAdjustmentHandler, userID uint64) func(body string) int {
+	return func(body string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: strconv.FormatUint(userID, 10)}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("adminID", uint64(1))
+		handler.Create(c)
+		return w.Code
+	}
+}
+
+func TestBalanceAdjustmentCreditsBillWithinCharges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupBalanceAdjustmentDB(t)
+
+	now := time.Now().UTC()
+	user := models.User{Username: "u", Email: "u@example.com", Password: "x"}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	bill := models.Bill{
+		PlanID: 1, UserID: user.ID, PeriodType: models.BillPeriodTypeMonthly,
+		PeriodStart: now.Add(-time.Hour), PeriodEnd: now.Add(time.Hour),
+		TotalQuota: 10, UsedQuota: 4, LeftQuota: 6, IsEnabled: true, Status: models.BillStatusPaid,
+	}
+	if errCreate := db.Create(&bill).Error; errCreate != nil {
+		t.Fatalf("create bill: %v", errCreate)
+	}
+	uid := user.ID
+	if errCreate := db.Create(&models.Usage{UserID: &uid, Provider: "p", Model: "m", RequestedAt: now, CostMicros: 2_000_000}).Error; errCreate != nil {
+		t.Fatalf("create usage: %v", errCreate)
+	}
+
+	post := postAdjustment(NewBalanceAdjustmentHandler(db), user.ID)
+
+	if code := post(`{"amount":1.5,"reason":"provider error"}`); code != http.StatusCreated {
+		t.Fatalf("expected credit within charges to succeed, got %d", code)
+	}
+	if code := post(`{"amount":1,"reason":"again"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected credit beyond charges to be rejected, got %d", code)
+	}
+	if code := post(`{"amount":1,"reason":"goodwill","allow_exceed_charges":true}`); code != http.StatusCreated {
+		t.Fatalf("expected flagged credit to succeed, got %d", code)
+	}
+
+	var reloaded models.Bill
+	if errFind := db.First(&reloaded, bill.ID).Error; errFind != nil {
+		t.Fatalf("reload bill: %v", errFind)
+	}
+	if reloaded.LeftQuota != 8.5 || reloaded.UsedQuota != 1.5 {
+		t.Fatalf("unexpected bill quotas left=%v used=%v", reloaded.LeftQuota, reloaded.UsedQuota)
+	}
+
+	// A credit after overage pays the outstanding debt before topping up the bill.
+	if errUpdate := db.Model(&user).Update("overage_debt", 0.5).Error; errUpdate != nil {
+		t.Fatalf("set debt: %v", errUpdate)
+	}
+	if code := post(`{"amount":1,"reason":"after overage","allow_exceed_charges":true}`); code != http.StatusCreated {
+		t.Fatalf("expected credit to succeed, got %d", code)
+	}
+	if errFind := db.First(&reloaded, bill.ID).Error; errFind != nil {
+		t.Fatalf("reload bill: %v", errFind)
+	}
+	var debtor models.User
+	if errFind := db.First(&debtor, user.ID).Error; errFind != nil {
+		t.Fatalf("reload user: %v", errFind)
+	}
+	if reloaded.LeftQuota != 9 || reloaded.UsedQuota != 1 || debtor.OverageDebt != 0 {
+		t.Fatalf("unexpected quotas left=%v used=%v debt=%v", reloaded.LeftQuota, reloaded.UsedQuota, debtor.OverageDebt)
+	}
+
+	var count int64
+	if errCount := db.Model(&models.BalanceAdjustment{}).Where("user_id = ?", user.ID).Count(&count).Error; errCount != nil {
+		t.Fatalf("count adjustments: %v", errCount)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 ledger entries, got %d", count)
+	}
+}
+
+func TestBalanceAdjustmentChargesSurviveUsageRetention(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupBalanceAdjustmentDB(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	user := models.User{Username: "u", Email: "u@example.com", Password: "x"}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	if errCreate := db.Create(&models.Bill{
+		PlanID: 1, UserID: user.ID, PeriodType: models.BillPeriodTypeMonthly,
+		PeriodStart: now.Add(-time.Hour), PeriodEnd: now.Add(time.Hour),
+		TotalQuota: 10, UsedQuota: 4, LeftQuota: 6, IsEnabled: true, Status: models.BillStatusPaid,
+	}).Error; errCreate != nil {
+		t.Fatalf("create bill: %v", errCreate)
+	}
+	uid := user.ID
+	usage := models.Usage{UserID: &uid, Provider: "p", Model: "m", RequestedAt: now.AddDate(0, 0, -10), CostMicros: 3_000_000}
+	if errCreate := db.Create(&usage).Error; errCreate != nil {
+		t.Fatalf("create usage: %v", errCreate)
+	}
+	post := postAdjustment(NewBalanceAdjustmentHandler(db), user.ID)
+	usageRefund := func(amount string) string {
+		return fmt.Sprintf(`{"amount":%s,"reason":"refund","usage_id":%d}`, amount, usage.ID)
+	}
+
+	if code := post(usageRefund("1")); code != http.StatusCreated {
+		t.Fatalf("expected usage refund to succeed, got %d", code)
+	}
+	job := internalusage.NewRetentionJob(db)
+	if _, errRun := job.RunOnce(ctx, 5); errRun != nil {
+		t.Fatalf("run retention: %v", errRun)
+	}
+	var remaining int64
+	if errCount := db.Model(&models.Usage{}).Count(&remaining).Error; errCount != nil || remaining != 0 {
+		t.Fatalf("expected retention to delete the usage row, got %d (%v)", remaining, errCount)
+	}
+
+	// A debit must not make more of the charge refundable.
+	if code := post(`{"amount":-1,"reason":"correction"}`); code != http.StatusCreated {
+		t.Fatalf("expected debit to succeed, got %d", code)
+	}
+	if code := post(`{"amount":1,"reason":"account credit"}`); code != http.StatusCreated {
+		t.Fatalf("expected account credit within the rolled-up charges to succeed, got %d", code)
+	}
+	if code := post(usageRefund("2.5")); code != http.StatusBadRequest {
+		t.Fatalf("expected refund beyond the recorded charge to be rejected, got %d", code)
+	}
+	if code := post(usageRefund("2")); code != http.StatusCreated {
+		t.Fatalf("expected refund within the recorded charge to succeed after retention, got %d", code)
+	}
+	if code := post(`{"amount":0.5,"reason":"account credit"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected credits beyond the rolled-up account charges to be rejected, got %d", code)
+	}
+}
@@ -2,34 +2,55 @@ package handlers
 
 import (
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/gorm"
 )
 
+// Billing summary breakdowns accepted by group_by.
+const (
+	billingGroupByPlan  = "plan"
+	billingGroupByUser  = "user"
+	billingGroupByMonth = "month"
+)
+
 // BillingHandler handles billing summary endpoints.
 type BillingHandler struct {
-	db *gorm.DB
+	dbs dbutil.DBProvider // Primary connection and optional read replica.
 }
 
 // NewBillingHandler constructs a BillingHandler.
-func NewBillingHandler(db *gorm.DB) *BillingHandler {
-	return &BillingHandler{db: db}
+func NewBillingHandler(dbs dbutil.DBProvider) *BillingHandler {
+	return &BillingHandler{dbs: dbs}
 }
 
-// Summary returns aggregated billing usage by API key.
+// Summary returns aggregated billing usage by API key. With group_by=plan, user or month
+// it returns a billing breakdown instead, see breakdown.
 func (h *BillingHandler) Summary(c *gin.Context) {
 	var (
 		apiKeyIDStr = strings.TrimSpace(c.Query("api_key_id"))
 		fromStr     = strings.TrimSpace(c.Query("from"))
 		toStr       = strings.TrimSpace(c.Query("to"))
+		groupBy     = strings.ToLower(strings.TrimSpace(c.Query("group_by")))
 	)
+	switch groupBy {
+	case "":
+	case billingGroupByPlan, billingGroupByUser, billingGroupByMonth:
+		h.breakdown(c, groupBy, fromStr, toStr)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be plan, user or month"})
+		return
+	}
 
-	q := h.db.WithContext(c.Request.Context()).Model(&models.Usage{})
+	q := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{})
 	if apiKeyIDStr != "" {
 		if id, errParseUint := strconv.ParseUint(apiKeyIDStr, 10, 64); errParseUint == nil {
 			q = q.Where("api_key_id = ?", id)
@@ -54,12 +75,216 @@ func (h *BillingHandler) Summary(c *gin.Context) {
 		Currency    string  `json:"currency"`
 	}
 	var rows []row
-	if errScan := q.Select("api_key_id, currency, SUM(total_tokens) AS total_tokens, SUM(cost_micros) AS cost_micros").
-		Group("api_key_id, currency").
+	if errScan := q.Select("api_key_id, SUM(total_tokens) AS total_tokens, SUM(cost_micros) AS cost_micros").
+		Group("api_key_id").
 		Order("cost_micros DESC").
 		Scan(&rows).Error; errScan != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
 		return
 	}
+	currency := billing.DisplayCurrency()
+	for i := range rows {
+		rows[i].Currency = currency
+	}
 	c.JSON(http.StatusOK, gin.H{"billing": rows})
 }
+
+// billingBreakdownItem is one bucket of a billing breakdown. Every bucket carries every
+// field, so charts can read the response without checking for missing values.
+type billingBreakdownItem struct {
+	Key             string  `json:"key"`               // Plan ID, user ID or UTC month (YYYY-MM).
+	Label           string  `json:"label"`             // Plan name, username or month.
+	Bills           int64   `json:"bills"`             // Bills created in the range.
+	BilledAmount    float64 `json:"billed_amount"`     // Amount of those bills in any status.
+	PaidAmount      float64 `json:"paid_amount"`       // Amount of paid bills, including refund requests.
+	PendingAmount   float64 `json:"pending_amount"`    // Amount of bills awaiting payment.
+	RefundedAmount  float64 `json:"refunded_amount"`   // Amount of refunded bills.
+	Requests        int64   `json:"requests"`          // Requests made in the range.
+	TotalTokens     int64   `json:"total_tokens"`      // Tokens used by those requests.
+	UsageCostMicros int64   `json:"usage_cost_micros"` // Cost charged for those requests in micros.
+}
+
+// add accumulates other into i.
+func (i *billingBreakdownItem) add(other billingBreakdownItem) {
+	i.Bills += other.Bills
+	i.BilledAmount += other.BilledAmount
+	i.PaidAmount += other.PaidAmount
+	i.PendingAmount += other.PendingAmount
+	i.RefundedAmount += other.RefundedAmount
+	i.Requests += other.Requests
+	i.TotalTokens += other.TotalTokens
+	i.UsageCostMicros += other.UsageCostMicros
+}
+
+// billAmountsSelect aggregates bills into the bill columns of billingBreakdownItem.
+const billAmountsSelect = `
+	COUNT(*) AS bills,
+	COALESCE(SUM(amount), 0) AS billed_amount,
+	COALESCE(SUM(CASE WHEN status IN (?, ?) THEN amount ELSE 0 END), 0) AS paid_amount,
+	COALESCE(SUM(CASE WHEN status = ? THEN amount ELSE 0 END), 0) AS pending_amount,
+	COALESCE(SUM(CASE WHEN status = ? THEN amount ELSE 0 END), 0) AS refunded_amount
+`
+
+// usageAmountsSelect aggregates usages into the usage columns of billingBreakdownItem.
+const usageAmountsSelect = `
+	COUNT(*) AS requests,
+	COALESCE(SUM(usages.total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(usages.cost_micros), 0) AS usage_cost_micros
+`
+
+// breakdown writes bill amounts and actual usage per plan, user or UTC month. Bills are
+// bucketed by creation time and usage by request time, both within from and to. Usage is
+// attributed to the plan users are on now, since usage rows do not record the plan.
+// Month buckets are ordered by month; plan and user buckets by billed amount, largest first.
+func (h *BillingHandler) breakdown(c *gin.Context, groupBy, fromStr, toStr string) {
+	var from, to time.Time
+	if fromStr != "" {
+		t, errParse := time.Parse(time.RFC3339, fromStr)
+		if errParse != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+		from = t.UTC()
+	}
+	if toStr != "" {
+		t, errParse := time.Parse(time.RFC3339, toStr)
+		if errParse != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+		to = t.UTC()
+	}
+
+	db := h.dbs.Read().WithContext(c.Request.Context())
+	inRange := func(q *gorm.DB, column string) *gorm.DB {
+		if !from.IsZero() {
+			q = q.Where(column+" >= ?", from)
+		}
+		if !to.IsZero() {
+			q = q.Where(column+" <= ?", to)
+		}
+		return q
+	}
+
+	var billKey, usageKey string
+	usages := db.Model(&models.Usage{})
+	switch groupBy {
+	case billingGroupByPlan:
+		billKey, usageKey = "plan_id", "users.plan_id"
+		usages = usages.Joins("JOIN users ON users.id = usages.user_id").Where("users.plan_id IS NOT NULL")
+	case billingGroupByUser:
+		billKey, usageKey = "user_id", "usages.user_id"
+		usages = usages.Where("usages.user_id IS NOT NULL")
+	default:
+		billKey, usageKey = dbutil.UTCMonthExpr(db, "created_at"), dbutil.UTCMonthExpr(db, "usages.requested_at")
+	}
+
+	// bucketRow is an aggregate keyed by the group_by column.
+	type bucketRow struct {
+		Key             string
+		Bills           int64
+		BilledAmount    float64
+		PaidAmount      float64
+		PendingAmount   float64
+		RefundedAmount  float64
+		Requests        int64
+		TotalTokens     int64
+		UsageCostMicros int64
+	}
+	var billRows []bucketRow
+	if errScan := inRange(db.Model(&models.Bill{}), "created_at").
+		Select(billKey+" AS key, "+billAmountsSelect,
+			models.BillStatusPaid, models.BillStatusRefundRequested, models.BillStatusPending, models.BillStatusRefunded).
+		Group(billKey).
+		Scan(&billRows).Error; errScan != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query bills failed"})
+		return
+	}
+	var usageRows []bucketRow
+	if errScan := inRange(usages, "usages.requested_at").
+		Select(usageKey + " AS key, " + usageAmountsSelect).
+		Group(usageKey).
+		Scan(&usageRows).Error; errScan != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query usage failed"})
+		return
+	}
+
+	buckets := make(map[string]*billingBreakdownItem)
+	bucket := func(key string) *billingBreakdownItem {
+		item, ok := buckets[key]
+		if !ok {
+			item = &billingBreakdownItem{Key: key, Label: key}
+			buckets[key] = item
+		}
+		return item
+	}
+	for _, row := range billRows {
+		item := bucket(row.Key)
+		item.Bills, item.BilledAmount = row.Bills, row.BilledAmount
+		item.PaidAmount, item.PendingAmount, item.RefundedAmount = row.PaidAmount, row.PendingAmount, row.RefundedAmount
+	}
+	for _, row := range usageRows {
+		item := bucket(row.Key)
+		item.Requests, item.TotalTokens, item.UsageCostMicros = row.Requests, row.TotalTokens, row.UsageCostMicros
+	}
+	if errLabels := h.labelBreakdown(c, groupBy, buckets); errLabels != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load labels failed"})
+		return
+	}
+
+	items := make([]billingBreakdownItem, 0, len(buckets))
+	totals := billingBreakdownItem{Key: "total", Label: "total"}
+	for _, item := range buckets {
+		items = append(items, *item)
+		totals.add(*item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if groupBy != billingGroupByMonth && items[i].BilledAmount != items[j].BilledAmount {
+			return items[i].BilledAmount > items[j].BilledAmount
+		}
+		return items[i].Key < items[j].Key
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_by": groupBy,
+		"currency": billing.DisplayCurrency(),
+		"from":     fromStr,
+		"to":       toStr,
+		"items":    items,
+		"totals":   totals,
+	})
+}
+
+// labelBreakdown names plan and user buckets. Buckets of deleted records keep their ID.
+func (h *BillingHandler) labelBreakdown(c *gin.Context, groupBy string, buckets map[string]*billingBreakdownItem) error {
+	if groupBy == billingGroupByMonth || len(buckets) == 0 {
+		return nil
+	}
+	ids := make([]uint64, 0, len(buckets))
+	for key := range buckets {
+		if id, errParse := strconv.ParseUint(key, 10, 64); errParse == nil {
+			ids = append(ids, id)
+		}
+	}
+	// label is a record ID and its display name.
+	type label struct {
+		ID   uint64
+		Name string
+	}
+	var labels []label
+	q := h.dbs.Read().WithContext(c.Request.Context())
+	if groupBy == billingGroupByPlan {
+		q = q.Model(&models.Plan{}).Select("id, name")
+	} else {
+		q = q.Model(&models.User{}).Select("id, username AS name")
+	}
+	if errFind := q.Where("id IN ?", ids).Scan(&labels).Error; errFind != nil {
+		return errFind
+	}
+	for _, l := range labels {
+		if item, ok := buckets[strconv.FormatUint(l.ID, 10)]; ok {
+			item.Label = l.Name
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/gorm"
 )
@@ -34,6 +35,10 @@ type createBillingRuleRequest struct {
 	PriceOutputToken      *float64 `json:"price_output_token"`       // Price per output token.
 	PriceCacheCreateToken *float64 `json:"price_cache_create_token"` // Price per cache create token.
 	PriceCacheReadToken   *float64 `json:"price_cache_read_token"`   // Price per cache read token.
+	PriceUnit             string   `json:"price_unit"`               // Tokens covered by each token price; defaults to per_1m.
+	StreamPriceMultiplier *float64 `json:"stream_price_multiplier"`  // Optional multiplier for streamed requests.
+	BillFailed            string   `json:"bill_failed"`              // Failed-request policy; defaults to none.
+	PreferUpstreamCost    bool     `json:"prefer_upstream_cost"`     // Bill the provider-reported cost when available.
 	IsEnabled             *bool    `json:"is_enabled"`               // Required enabled flag.
 }
 
@@ -88,6 +93,22 @@ func (h *BillingRuleHandler) Create(c *gin.Context) {
 		}
 	}
 
+	streamMultiplier, okMultiplier := normalizeStreamPriceMultiplier(body.StreamPriceMultiplier)
+	if !okMultiplier {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stream_price_multiplier cannot be negative"})
+		return
+	}
+	billFailed, okBillFailed := parseBillFailedPolicy(body.BillFailed)
+	if !okBillFailed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": billFailedPolicyError})
+		return
+	}
+	priceUnit, okPriceUnit := parsePriceUnit(body.PriceUnit)
+	if !okPriceUnit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": priceUnitError})
+		return
+	}
+
 	provider := strings.TrimSpace(body.Provider)
 	if provider == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
@@ -111,6 +132,10 @@ func (h *BillingRuleHandler) Create(c *gin.Context) {
 		PriceOutputToken:      body.PriceOutputToken,
 		PriceCacheCreateToken: body.PriceCacheCreateToken,
 		PriceCacheReadToken:   body.PriceCacheReadToken,
+		PriceUnit:             priceUnit,
+		StreamPriceMultiplier: streamMultiplier,
+		BillFailed:            billFailed,
+		PreferUpstreamCost:    body.PreferUpstreamCost,
 		IsEnabled:             *body.IsEnabled,
 		CreatedAt:             now,
 		UpdatedAt:             now,
@@ -123,14 +148,22 @@ func (h *BillingRuleHandler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, h.formatRule(&rule))
 }
 
-// List returns billing rules filtered by query parameters.
+// List returns billing rules filtered by query parameters. With effective=true it returns
+// the enabled rules that apply to user_group_id, including those inherited from its parent
+// chain, each marked with the group it comes from.
 func (h *BillingRuleHandler) List(c *gin.Context) {
 	var (
 		authGroupIDQ = strings.TrimSpace(c.Query("auth_group_id"))
 		userGroupIDQ = strings.TrimSpace(c.Query("user_group_id"))
 		isEnabledQ   = strings.TrimSpace(c.Query("is_enabled"))
+		effectiveQ   = strings.TrimSpace(c.Query("effective"))
 	)
 
+	if effectiveQ == "true" || effectiveQ == "1" {
+		h.listEffective(c, authGroupIDQ, userGroupIDQ)
+		return
+	}
+
 	q := h.db.WithContext(c.Request.Context()).Model(&models.BillingRule{})
 	if authGroupIDQ != "" {
 		if id, errParse := strconv.ParseUint(authGroupIDQ, 10, 64); errParse == nil {
@@ -162,6 +195,66 @@ func (h *BillingRuleHandler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"billing_rules": out})
 }
 
+// listEffective writes the enabled rules that apply to a user group. A rule of an ancestor
+// group is shown only when no nearer group has a rule for the same auth group, provider
+// and model, mirroring how billing walks the parent chain.
+func (h *BillingRuleHandler) listEffective(c *gin.Context, authGroupIDQ, userGroupIDQ string) {
+	userGroupID, errParse := strconv.ParseUint(userGroupIDQ, 10, 64)
+	if errParse != nil || userGroupID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_group_id is required for effective rules"})
+		return
+	}
+	ctx := c.Request.Context()
+	ancestors, errAncestors := billing.UserGroupAncestors(ctx, h.db, userGroupID)
+	if errAncestors != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query user groups failed"})
+		return
+	}
+	chain := append([]uint64{userGroupID}, ancestors...)
+
+	q := h.db.WithContext(ctx).Model(&models.BillingRule{}).
+		Where("user_group_id IN ? AND is_enabled = ?", chain, true)
+	if authGroupIDQ != "" {
+		if id, errParseAuth := strconv.ParseUint(authGroupIDQ, 10, 64); errParseAuth == nil {
+			q = q.Where("auth_group_id = ?", id)
+		}
+	}
+	var rows []models.BillingRule
+	if errFind := q.Order("created_at DESC").Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list billing rules failed"})
+		return
+	}
+
+	type ruleKey struct {
+		authGroupID     uint64
+		provider, model string
+	}
+	claimedBy := make(map[ruleKey]uint64)
+	out := make([]gin.H, 0, len(rows))
+	for _, groupID := range chain {
+		for i := range rows {
+			row := &rows[i]
+			if row.UserGroupID != groupID {
+				continue
+			}
+			key := ruleKey{
+				authGroupID: row.AuthGroupID,
+				provider:    strings.ToLower(strings.TrimSpace(row.Provider)),
+				model:       strings.TrimSpace(row.Model),
+			}
+			if owner, claimed := claimedBy[key]; claimed && owner != groupID {
+				continue
+			}
+			claimedBy[key] = groupID
+			item := h.formatRule(row)
+			item["inherited"] = groupID != userGroupID
+			item["source_user_group_id"] = groupID
+			out = append(out, item)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"billing_rules": out})
+}
+
 // Get fetches a billing rule by ID.
 func (h *BillingRuleHandler) Get(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
@@ -193,6 +286,10 @@ type updateBillingRuleRequest struct {
 	PriceOutputToken      *float64 `json:"price_output_token"`       // Optional output token price.
 	PriceCacheCreateToken *float64 `json:"price_cache_create_token"` // Optional cache create price.
 	PriceCacheReadToken   *float64 `json:"price_cache_read_token"`   // Optional cache read price.
+	PriceUnit             *string  `json:"price_unit"`               // Optional token price unit.
+	StreamPriceMultiplier *float64 `json:"stream_price_multiplier"`  // Optional stream multiplier (0 clears it).
+	BillFailed            *string  `json:"bill_failed"`              // Optional failed-request policy.
+	PreferUpstreamCost    *bool    `json:"prefer_upstream_cost"`     // Optional upstream cost preference.
 	IsEnabled             *bool    `json:"is_enabled"`               // Optional enabled flag.
 }
 
@@ -326,6 +423,33 @@ func (h *BillingRuleHandler) Update(c *gin.Context) {
 		"price_cache_create_token": newPriceCacheCreateToken,
 		"price_cache_read_token":   newPriceCacheReadToken,
 	}
+	if body.StreamPriceMultiplier != nil {
+		streamMultiplier, okMultiplier := normalizeStreamPriceMultiplier(body.StreamPriceMultiplier)
+		if !okMultiplier {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stream_price_multiplier cannot be negative"})
+			return
+		}
+		updates["stream_price_multiplier"] = streamMultiplier
+	}
+	if body.BillFailed != nil {
+		billFailed, okBillFailed := parseBillFailedPolicy(*body.BillFailed)
+		if !okBillFailed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": billFailedPolicyError})
+			return
+		}
+		updates["bill_failed"] = billFailed
+	}
+	if body.PriceUnit != nil {
+		priceUnit, okPriceUnit := parsePriceUnit(*body.PriceUnit)
+		if !okPriceUnit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": priceUnitError})
+			return
+		}
+		updates["price_unit"] = priceUnit
+	}
+	if body.PreferUpstreamCost != nil {
+		updates["prefer_upstream_cost"] = *body.PreferUpstreamCost
+	}
 	if body.IsEnabled != nil {
 		updates["is_enabled"] = *body.IsEnabled
 	}
@@ -389,6 +513,67 @@ func (h *BillingRuleHandler) SetEnabled(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// Resolve shows which billing rule usage costing would apply to provider/model for the
+// given auth and user groups, with every candidate considered and why the winner won.
+func (h *BillingRuleHandler) Resolve(c *gin.Context) {
+	provider := strings.TrimSpace(c.Query("provider"))
+	model := strings.TrimSpace(c.Query("model"))
+	if provider == "" || model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider and model are required"})
+		return
+	}
+	var authGroupID, userGroupID *uint64
+	for _, param := range []struct {
+		name   string
+		target **uint64
+	}{{"auth_group_id", &authGroupID}, {"user_group_id", &userGroupID}} {
+		raw := strings.TrimSpace(c.Query(param.name))
+		if raw == "" {
+			continue
+		}
+		value, errParse := strconv.ParseUint(raw, 10, 64)
+		if errParse != nil || value == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name})
+			return
+		}
+		*param.target = &value
+	}
+
+	res, errResolve := billing.ResolveBillingRule(c.Request.Context(), h.db, authGroupID, userGroupID, provider, model)
+	if errResolve != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "resolve billing rule failed"})
+		return
+	}
+
+	candidates := make([]gin.H, 0, len(res.Candidates))
+	for _, candidate := range res.Candidates {
+		candidates = append(candidates, gin.H{
+			"stage":    candidate.Stage,
+			"tier":     candidate.Tier,
+			"selected": candidate.Rule == res.Rule,
+			"rule":     h.formatRule(candidate.Rule),
+		})
+	}
+	var winner gin.H
+	if res.Rule != nil {
+		winner = h.formatRule(res.Rule)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"provider":              provider,
+		"model":                 model,
+		"rule":                  winner,
+		"stage":                 res.Stage,
+		"tier":                  res.Tier,
+		"reason":                res.Reason,
+		"auth_group_id":         res.AuthGroupID,
+		"user_group_id":         res.UserGroupID,
+		"inherited_from_id":     res.InheritedFromID,
+		"default_auth_group_id": res.DefaultAuthGroupID,
+		"default_user_group_id": res.DefaultUserGroupID,
+		"candidates":            candidates,
+	})
+}
+
 // formatRule converts a billing rule into a response payload.
 func (h *BillingRuleHandler) formatRule(rule *models.BillingRule) gin.H {
 	return gin.H{
@@ -403,20 +588,81 @@ func (h *BillingRuleHandler) formatRule(rule *models.BillingRule) gin.H {
 		"price_output_token":       rule.PriceOutputToken,
 		"price_cache_create_token": rule.PriceCacheCreateToken,
 		"price_cache_read_token":   rule.PriceCacheReadToken,
+		"price_unit":               priceUnitOf(rule),
+		"stream_price_multiplier":  rule.StreamPriceMultiplier,
+		"bill_failed":              billFailedPolicyOf(rule),
+		"prefer_upstream_cost":     rule.PreferUpstreamCost,
 		"is_enabled":               rule.IsEnabled,
 		"created_at":               rule.CreatedAt,
 		"updated_at":               rule.UpdatedAt,
 	}
 }
 
+// billFailedPolicyError is returned for an unknown bill_failed value.
+const billFailedPolicyError = "bill_failed must be none, input_only or full"
+
+// parseBillFailedPolicy validates a bill_failed value, treating empty as none.
+func parseBillFailedPolicy(value string) (models.BillFailedPolicy, bool) {
+	policy := models.BillFailedPolicy(strings.ToLower(strings.TrimSpace(value)))
+	if policy == "" {
+		return models.BillFailedNone, true
+	}
+	return policy, policy.Valid()
+}
+
+// billFailedPolicyOf returns the rule's policy, reporting rows created before the
+// column existed as none.
+func billFailedPolicyOf(rule *models.BillingRule) models.BillFailedPolicy {
+	if rule.BillFailed == "" {
+		return models.BillFailedNone
+	}
+	return rule.BillFailed
+}
+
+// priceUnitError is returned for an unknown price_unit value.
+const priceUnitError = "price_unit must be per_token, per_1k or per_1m"
+
+// parsePriceUnit validates a price_unit value, accepting dashes for underscores and
+// treating empty as per_1m.
+func parsePriceUnit(value string) (models.PriceUnit, bool) {
+	unit := models.PriceUnit(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(value)), "-", "_"))
+	if unit == "" {
+		return models.PriceUnitPer1M, true
+	}
+	return unit, unit.Valid()
+}
+
+// priceUnitOf returns the rule's price unit, reporting rows created before the column
+// existed as per_1m.
+func priceUnitOf(rule *models.BillingRule) models.PriceUnit {
+	if rule.PriceUnit == "" {
+		return models.PriceUnitPer1M
+	}
+	return rule.PriceUnit
+}
+
+// normalizeStreamPriceMultiplier maps zero to "no multiplier" and rejects negative values.
+func normalizeStreamPriceMultiplier(value *float64) (*float64, bool) {
+	if value == nil || *value == 0 {
+		return nil, true
+	}
+	if *value < 0 {
+		return nil, false
+	}
+	multiplier := *value
+	return &multiplier, true
+}
+
 // batchImportRequest captures the payload for batch importing billing rules.
 type batchImportRequest struct {
-	AuthGroupID uint64 `json:"auth_group_id"` // Auth group ID.
-	UserGroupID uint64 `json:"user_group_id"` // User group ID.
-	BillingType int    `json:"billing_type"`  // Billing type.
+	AuthGroupID uint64  `json:"auth_group_id"` // Auth group ID.
+	UserGroupID uint64  `json:"user_group_id"` // User group ID.
+	BillingType int     `json:"billing_type"`  // Billing type.
+	BillFailed  *string `json:"bill_failed"`   // Optional failed-request policy; existing rules keep theirs when omitted.
 }
 
-// BatchImport imports billing rules for all enabled model mappings.
+// BatchImport imports billing rules for all enabled model mappings. Per-token prices
+// come from model references, which are priced per 1,000,000 tokens.
 func (h *BillingRuleHandler) BatchImport(c *gin.Context) {
 	var body batchImportRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
@@ -437,6 +683,15 @@ func (h *BillingRuleHandler) BatchImport(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "billing_type must be 1 (per_request) or 2 (per_token)"})
 		return
 	}
+	billFailed := models.BillFailedNone
+	if body.BillFailed != nil {
+		policy, okBillFailed := parseBillFailedPolicy(*body.BillFailed)
+		if !okBillFailed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": billFailedPolicyError})
+			return
+		}
+		billFailed = policy
+	}
 
 	ctx := c.Request.Context()
 
@@ -518,9 +773,13 @@ func (h *BillingRuleHandler) BatchImport(c *gin.Context) {
 				"price_output_token":       priceOutputToken,
 				"price_cache_create_token": priceCacheCreate,
 				"price_cache_read_token":   priceCacheRead,
+				"price_unit":               models.PriceUnitPer1M,
 				"is_enabled":               true,
 				"updated_at":               now,
 			}
+			if body.BillFailed != nil {
+				updates["bill_failed"] = billFailed
+			}
 			if errUpd := h.db.WithContext(ctx).Model(&models.BillingRule{}).Where("id = ?", existing.ID).Updates(updates).Error; errUpd == nil {
 				updated++
 			}
@@ -536,6 +795,8 @@ func (h *BillingRuleHandler) BatchImport(c *gin.Context) {
 				PriceOutputToken:      priceOutputToken,
 				PriceCacheCreateToken: priceCacheCreate,
 				PriceCacheReadToken:   priceCacheRead,
+				PriceUnit:             models.PriceUnitPer1M,
+				BillFailed:            billFailed,
 				IsEnabled:             true,
 				CreatedAt:             now,
 				UpdatedAt:             now,
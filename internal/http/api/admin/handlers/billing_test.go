@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestBillingSummaryBreakdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	plans := []models.Plan{{Name: "Basic"}, {Name: "Pro"}}
+	if errCreate := db.Create(&plans).Error; errCreate != nil {
+		t.Fatalf("create plans: %v", errCreate)
+	}
+	users := []models.User{
+		{Username: "alice", Email: "alice@example.com", Password: "x", PlanID: &plans[0].ID},
+		{Username: "bob", Email: "bob@example.com", Password: "x", PlanID: &plans[1].ID},
+	}
+	if errCreate := db.Create(&users).Error; errCreate != nil {
+		t.Fatalf("create users: %v", errCreate)
+	}
+	march := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	april := time.Date(2026, 4, 2, 8, 0, 0, 0, time.UTC)
+	bill := func(plan models.Plan, user models.User, amount float64, status models.BillStatus, at time.Time) models.Bill {
+		return models.Bill{
+			PlanID:      plan.ID,
+			UserID:      user.ID,
+			PeriodType:  models.BillPeriodTypeMonthly,
+			Amount:      amount,
+			PeriodStart: at,
+			PeriodEnd:   at.AddDate(0, 1, 0),
+			Status:      status,
+			CreatedAt:   at,
+		}
+	}
+	bills := []models.Bill{
+		bill(plans[0], users[0], 10, models.BillStatusPaid, march),
+		bill(plans[0], users[0], 10, models.BillStatusPending, april),
+		bill(plans[1], users[1], 30, models.BillStatusPaid, march),
+		bill(plans[1], users[1], 30, models.BillStatusRefunded, april),
+	}
+	if errCreate := db.Create(&bills).Error; errCreate != nil {
+		t.Fatalf("create bills: %v", errCreate)
+	}
+	usages := []models.Usage{
+		{Provider: "claude", Model: "claude-sonnet", UserID: &users[0].ID, RequestedAt: march, TotalTokens: 100, CostMicros: 1000},
+		{Provider: "claude", Model: "claude-sonnet", UserID: &users[1].ID, RequestedAt: april, TotalTokens: 50, CostMicros: 500},
+		{Provider: "claude", Model: "claude-sonnet", UserID: &users[1].ID, RequestedAt: april, TotalTokens: 50, CostMicros: 500},
+	}
+	if errCreate := db.Create(&usages).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	h := NewBillingHandler(dbutil.NewDBProvider(db, nil))
+	r := gin.New()
+	r.GET("/v0/admin/billing/summary", h.Summary)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/billing/summary"+query, nil))
+		return w
+	}
+	type response struct {
+		GroupBy string                 `json:"group_by"`
+		Items   []billingBreakdownItem `json:"items"`
+		Totals  billingBreakdownItem   `json:"totals"`
+	}
+	breakdown := func(query string) response {
+		t.Helper()
+		w := get(query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp response
+		if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+			t.Fatalf("decode: %v", errDecode)
+		}
+		return resp
+	}
+
+	byPlan := breakdown("?group_by=plan")
+	if len(byPlan.Items) != 2 {
+		t.Fatalf("expected two plans, got %+v", byPlan.Items)
+	}
+	pro, basic := byPlan.Items[0], byPlan.Items[1]
+	if pro.Label != "Pro" || pro.BilledAmount != 60 || pro.PaidAmount != 30 || pro.RefundedAmount != 30 || pro.Requests != 2 {
+		t.Fatalf("unexpected pro bucket %+v", pro)
+	}
+	if basic.Label != "Basic" || basic.PaidAmount != 10 || basic.PendingAmount != 10 || basic.UsageCostMicros != 1000 {
+		t.Fatalf("unexpected basic bucket %+v", basic)
+	}
+	if byPlan.Totals.BilledAmount != 80 || byPlan.Totals.Bills != 4 || byPlan.Totals.TotalTokens != 200 {
+		t.Fatalf("unexpected totals %+v", byPlan.Totals)
+	}
+
+	byMonth := breakdown("?group_by=month")
+	if len(byMonth.Items) != 2 || byMonth.Items[0].Key != "2026-03" || byMonth.Items[1].Key != "2026-04" {
+		t.Fatalf("expected months in order, got %+v", byMonth.Items)
+	}
+	if m := byMonth.Items[0]; m.PaidAmount != 40 || m.PendingAmount != 0 || m.Requests != 1 {
+		t.Fatalf("unexpected march bucket %+v", m)
+	}
+
+	byUser := breakdown("?group_by=user&from=2026-04-01T00:00:00Z")
+	if len(byUser.Items) != 2 || byUser.Items[0].Label != "bob" || byUser.Items[1].PendingAmount != 10 || byUser.Items[1].Requests != 0 {
+		t.Fatalf("expected april bills per user, got %+v", byUser.Items)
+	}
+
+	if w := get("?group_by=model"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown group_by to be rejected, got %d", w.Code)
+	}
+	if w := get("?group_by=plan&from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid from to be rejected, got %d", w.Code)
+	}
+	if w := get(""); w.Code != http.StatusOK {
+		t.Fatalf("expected the per key summary to keep working, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 	"gorm.io/gorm"
 )
 
@@ -22,71 +25,88 @@ func NewBillHandler(db *gorm.DB) *BillHandler {
 	return &BillHandler{db: db}
 }
 
-// createBillRequest captures the payload for creating a bill.
+// createBillRequest captures the payload for creating a bill. Without period_end the
+// period end is derived from period_type and unset quotas are copied from the plan.
 type createBillRequest struct {
-	PlanID      uint64  `json:"plan_id"`      // Plan ID.
-	UserID      uint64  `json:"user_id"`      // User ID.
-	PeriodType  int     `json:"period_type"`  // Billing period type.
-	Amount      float64 `json:"amount"`       // Billing amount.
-	PeriodStart string  `json:"period_start"` // RFC3339 period start.
-	PeriodEnd   string  `json:"period_end"`   // RFC3339 period end.
-	TotalQuota  float64 `json:"total_quota"`  // Total quota.
-	DailyQuota  float64 `json:"daily_quota"`  // Daily quota.
-	UsedQuota   float64 `json:"used_quota"`   // Used quota.
-	LeftQuota   float64 `json:"left_quota"`   // Remaining quota.
-	UsedCount   int     `json:"used_count"`   // Usage count.
-	RateLimit   *int    `json:"rate_limit"`   // Optional rate limit per second.
-	IsEnabled   *bool   `json:"is_enabled"`   // Optional active flag.
-	Status      int     `json:"status"`       // Bill status.
+	PlanID      uint64   `json:"plan_id"`      // Plan ID.
+	UserID      uint64   `json:"user_id"`      // User ID.
+	PeriodType  int      `json:"period_type"`  // Billing period type.
+	Amount      float64  `json:"amount"`       // Billing amount.
+	PeriodStart string   `json:"period_start"` // RFC3339 period start.
+	PeriodEnd   string   `json:"period_end"`   // Optional RFC3339 period end.
+	TotalQuota  *float64 `json:"total_quota"`  // Optional total quota.
+	DailyQuota  *float64 `json:"daily_quota"`  // Optional daily quota.
+	UsedQuota   float64  `json:"used_quota"`   // Used quota.
+	LeftQuota   *float64 `json:"left_quota"`   // Optional remaining quota.
+	UsedCount   int      `json:"used_count"`   // Usage count.
+	RateLimit   *int     `json:"rate_limit"`   // Optional rate limit per second.
+	IsEnabled   *bool    `json:"is_enabled"`   // Optional active flag.
+	Status      int      `json:"status"`       // Bill status.
+	AutoRenew   bool     `json:"auto_renew"`   // Renew into the next period at period end.
+}
+
+// billPeriodEnd returns the end of a billing period of the given type starting at start.
+func billPeriodEnd(start time.Time, periodType models.BillPeriodType) time.Time {
+	if periodType == models.BillPeriodTypeYearly {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
 }
 
-// Create validates input and inserts a bill record.
+// Create validates input and inserts a bill record. When period_end is omitted the
+// period end is computed from period_type, and total_quota, daily_quota and left_quota
+// default to the plan's quotas.
 func (h *BillHandler) Create(c *gin.Context) {
 	var body createBillRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	if body.PlanID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan_id is required"})
+		apierror.Write(c, apierror.Validation("plan_id", "plan_id is required"))
 		return
 	}
 	if body.UserID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		apierror.Write(c, apierror.Validation("user_id", "user_id is required"))
 		return
 	}
 
 	periodType := models.BillPeriodType(body.PeriodType)
 	if periodType != models.BillPeriodTypeMonthly && periodType != models.BillPeriodTypeYearly {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "period_type must be 1 (monthly) or 2 (yearly)"})
+		apierror.Write(c, apierror.Validation("period_type", "period_type must be 1 (monthly) or 2 (yearly)"))
 		return
 	}
 
 	status := models.BillStatus(body.Status)
 	if status < models.BillStatusPending || status > models.BillStatusRefunded {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 1-4"})
+		apierror.Write(c, apierror.Validation("status", "status must be 1-4"))
 		return
 	}
 
 	periodStart, errParseStart := time.Parse(time.RFC3339, body.PeriodStart)
 	if errParseStart != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_start format, use RFC3339"})
+		apierror.Write(c, apierror.Validation("period_start", "invalid period_start format, use RFC3339"))
 		return
 	}
-	periodEnd, errParseEnd := time.Parse(time.RFC3339, body.PeriodEnd)
-	if errParseEnd != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_end format, use RFC3339"})
-		return
+	derived := strings.TrimSpace(body.PeriodEnd) == ""
+	periodEnd := billPeriodEnd(periodStart, periodType)
+	if !derived {
+		parsedEnd, errParseEnd := time.Parse(time.RFC3339, body.PeriodEnd)
+		if errParseEnd != nil {
+			apierror.Write(c, apierror.Validation("period_end", "invalid period_end format, use RFC3339"))
+			return
+		}
+		periodEnd = parsedEnd
 	}
 
 	var plan models.Plan
 	if errFindPlan := h.db.WithContext(c.Request.Context()).First(&plan, body.PlanID).Error; errFindPlan != nil {
 		if errors.Is(errFindPlan, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+			apierror.Write(c, apierror.NotFound("plan not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query plan failed"})
+		apierror.Write(c, apierror.Internal("query plan failed"))
 		return
 	}
 
@@ -98,6 +118,24 @@ func (h *BillHandler) Create(c *gin.Context) {
 	if body.RateLimit != nil {
 		rateLimit = *body.RateLimit
 	}
+	// The fully specified mode keeps treating omitted quotas as zero.
+	var totalQuota, dailyQuota float64
+	if derived {
+		totalQuota, dailyQuota = plan.TotalQuota, plan.DailyQuota
+	}
+	if body.TotalQuota != nil {
+		totalQuota = *body.TotalQuota
+	}
+	if body.DailyQuota != nil {
+		dailyQuota = *body.DailyQuota
+	}
+	var leftQuota float64
+	if derived {
+		leftQuota = totalQuota - body.UsedQuota
+	}
+	if body.LeftQuota != nil {
+		leftQuota = *body.LeftQuota
+	}
 
 	now := time.Now().UTC()
 	bill := models.Bill{
@@ -108,32 +146,100 @@ func (h *BillHandler) Create(c *gin.Context) {
 		Amount:      body.Amount,
 		PeriodStart: periodStart,
 		PeriodEnd:   periodEnd,
-		TotalQuota:  body.TotalQuota,
-		DailyQuota:  body.DailyQuota,
+		TotalQuota:  totalQuota,
+		DailyQuota:  dailyQuota,
 		UsedQuota:   body.UsedQuota,
-		LeftQuota:   body.LeftQuota,
+		LeftQuota:   leftQuota,
 		UsedCount:   body.UsedCount,
 		RateLimit:   rateLimit,
 		IsEnabled:   isEnabled,
 		Status:      status,
+		AutoRenew:   body.AutoRenew,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
 
-	if errCreate := h.db.WithContext(c.Request.Context()).Create(&bill).Error; errCreate != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "create bill failed"})
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if errCreate := tx.Create(&bill).Error; errCreate != nil {
+			return errCreate
+		}
+		_, errPay := billing.PayOverageDebtFromBill(c.Request.Context(), tx, &bill, bill.LeftQuota)
+		return errPay
+	})
+	if errTx != nil {
+		apierror.Write(c, apierror.Internal("create bill failed"))
 		return
 	}
+	if bill.Status == models.BillStatusPaid {
+		billing.NotifyBillEvent(billing.BillEventPaid, &bill)
+	}
 	c.JSON(http.StatusCreated, h.formatBill(&bill))
 }
 
-// List returns bills filtered by query parameters.
+// billListQuery defines paging, the period range filter and sorting for the bill list.
+type billListQuery struct {
+	Page            int    `form:"page,default=1"`       // Page number.
+	PageSize        int    `form:"page_size,default=20"` // Page size.
+	PeriodStartFrom string `form:"period_start_from"`    // Earliest period start (RFC3339 or YYYY-MM-DD).
+	PeriodEndTo     string `form:"period_end_to"`        // Latest period end (RFC3339 or inclusive YYYY-MM-DD).
+	Sort            string `form:"sort"`                 // Sort field: created_at, period_end or amount.
+	Order           string `form:"order"`                // Sort direction: asc or desc.
+}
+
+// billSortColumns maps accepted sort values to bill columns.
+var billSortColumns = map[string]string{
+	"created_at": "created_at",
+	"period_end": "period_end",
+	"amount":     "amount",
+}
+
+// parseBillRangeTime parses an RFC3339 timestamp or a YYYY-MM-DD date in BILLING_TIMEZONE.
+// A date used as an upper bound covers the whole day. Times are returned in UTC to match
+// stored values.
+func parseBillRangeTime(raw string, endOfDay bool) (time.Time, bool) {
+	if parsed, errParse := time.Parse(time.RFC3339, raw); errParse == nil {
+		return parsed.UTC(), true
+	}
+	parsed, errParse := time.ParseInLocation("2006-01-02", raw, internalsettings.BillingLocation())
+	if errParse != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return parsed.UTC(), true
+}
+
+// List returns bills filtered by query parameters, with paging and sorting.
 func (h *BillHandler) List(c *gin.Context) {
+	var lq billListQuery
+	if errBind := c.ShouldBindQuery(&lq); errBind != nil {
+		apierror.Write(c, apierror.Validation("query", "invalid query"))
+		return
+	}
+	if lq.Page < 1 {
+		lq.Page = 1
+	}
+	if lq.PageSize < 1 || lq.PageSize > 100 {
+		lq.PageSize = 20
+	}
+	sortField := strings.ToLower(strings.TrimSpace(lq.Sort))
+	sortColumn, okSort := billSortColumns[sortField]
+	if !okSort {
+		sortField, sortColumn = "created_at", "created_at"
+	}
+	sortOrder := strings.ToLower(strings.TrimSpace(lq.Order))
+	if sortOrder != "asc" {
+		sortOrder = "desc"
+	}
+
 	var (
-		planIDQ  = strings.TrimSpace(c.Query("plan_id"))
-		userIDQ  = strings.TrimSpace(c.Query("user_id"))
-		statusQ  = strings.TrimSpace(c.Query("status"))
-		enabledQ = strings.TrimSpace(c.Query("is_enabled"))
+		planIDQ    = strings.TrimSpace(c.Query("plan_id"))
+		userIDQ    = strings.TrimSpace(c.Query("user_id"))
+		statusQ    = strings.TrimSpace(c.Query("status"))
+		enabledQ   = strings.TrimSpace(c.Query("is_enabled"))
+		startFromQ = strings.TrimSpace(lq.PeriodStartFrom)
+		endToQ     = strings.TrimSpace(lq.PeriodEndTo)
 	)
 
 	q := h.db.WithContext(c.Request.Context()).Model(&models.Bill{})
@@ -159,33 +265,70 @@ func (h *BillHandler) List(c *gin.Context) {
 			q = q.Where("is_enabled = ?", false)
 		}
 	}
+	if startFromQ != "" {
+		startFrom, okParse := parseBillRangeTime(startFromQ, false)
+		if !okParse {
+			apierror.Write(c, apierror.Validation("period_start_from", "invalid period_start_from, use RFC3339 or YYYY-MM-DD"))
+			return
+		}
+		q = q.Where("period_start >= ?", startFrom)
+	}
+	if endToQ != "" {
+		endTo, okParse := parseBillRangeTime(endToQ, true)
+		if !okParse {
+			apierror.Write(c, apierror.Validation("period_end_to", "invalid period_end_to, use RFC3339 or YYYY-MM-DD"))
+			return
+		}
+		q = q.Where("period_end <= ?", endTo)
+	}
 
+	var total int64
+	if errCount := q.Count(&total).Error; errCount != nil {
+		apierror.Write(c, apierror.Internal("count bills failed"))
+		return
+	}
+
+	direction := strings.ToUpper(sortOrder)
 	var rows []models.Bill
-	if errFind := q.Order("created_at DESC").Find(&rows).Error; errFind != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list bills failed"})
+	if errFind := q.Order(sortColumn + " " + direction + ", id " + direction).
+		Offset((lq.Page - 1) * lq.PageSize).
+		Limit(lq.PageSize).
+		Find(&rows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("list bills failed"))
 		return
 	}
 	out := make([]gin.H, 0, len(rows))
 	for _, row := range rows {
 		out = append(out, h.formatBill(&row))
 	}
-	c.JSON(http.StatusOK, gin.H{"bills": out})
+	c.JSON(http.StatusOK, gin.H{
+		"bills":     out,
+		"total":     total,
+		"page":      lq.Page,
+		"page_size": lq.PageSize,
+		"filters": gin.H{
+			"period_start_from": startFromQ,
+			"period_end_to":     endToQ,
+			"sort":              sortField,
+			"order":             sortOrder,
+		},
+	})
 }
 
 // Get returns a bill by ID.
 func (h *BillHandler) Get(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	var bill models.Bill
 	if errFind := h.db.WithContext(c.Request.Context()).First(&bill, id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			apierror.Write(c, apierror.NotFound("not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		apierror.Write(c, apierror.Internal("query failed"))
 		return
 	}
 	c.JSON(http.StatusOK, h.formatBill(&bill))
@@ -207,28 +350,29 @@ type updateBillRequest struct {
 	RateLimit   *int     `json:"rate_limit"`   // Optional rate limit per second.
 	IsEnabled   *bool    `json:"is_enabled"`   // Optional active flag.
 	Status      *int     `json:"status"`       // Optional bill status.
+	AutoRenew   *bool    `json:"auto_renew"`   // Optional auto-renew flag.
 }
 
 // Update validates and applies bill field updates.
 func (h *BillHandler) Update(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	var body updateBillRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	var existing models.Bill
 	if errFind := h.db.WithContext(c.Request.Context()).First(&existing, id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			apierror.Write(c, apierror.NotFound("not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		apierror.Write(c, apierror.Internal("query failed"))
 		return
 	}
 
@@ -238,14 +382,14 @@ func (h *BillHandler) Update(c *gin.Context) {
 
 	if body.PlanID != nil {
 		if *body.PlanID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "plan_id cannot be 0"})
+			apierror.Write(c, apierror.Validation("plan_id", "plan_id cannot be 0"))
 			return
 		}
 		updates["plan_id"] = *body.PlanID
 	}
 	if body.UserID != nil {
 		if *body.UserID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id cannot be 0"})
+			apierror.Write(c, apierror.Validation("user_id", "user_id cannot be 0"))
 			return
 		}
 		updates["user_id"] = *body.UserID
@@ -253,7 +397,7 @@ func (h *BillHandler) Update(c *gin.Context) {
 	if body.PeriodType != nil {
 		pt := models.BillPeriodType(*body.PeriodType)
 		if pt != models.BillPeriodTypeMonthly && pt != models.BillPeriodTypeYearly {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "period_type must be 1 (monthly) or 2 (yearly)"})
+			apierror.Write(c, apierror.Validation("period_type", "period_type must be 1 (monthly) or 2 (yearly)"))
 			return
 		}
 		updates["period_type"] = pt
@@ -264,7 +408,7 @@ func (h *BillHandler) Update(c *gin.Context) {
 	if body.PeriodStart != nil {
 		t, errParseTime := time.Parse(time.RFC3339, *body.PeriodStart)
 		if errParseTime != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_start format"})
+			apierror.Write(c, apierror.Validation("period_start", "invalid period_start format"))
 			return
 		}
 		updates["period_start"] = t
@@ -272,7 +416,7 @@ func (h *BillHandler) Update(c *gin.Context) {
 	if body.PeriodEnd != nil {
 		t, errParseTime := time.Parse(time.RFC3339, *body.PeriodEnd)
 		if errParseTime != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_end format"})
+			apierror.Write(c, apierror.Validation("period_end", "invalid period_end format"))
 			return
 		}
 		updates["period_end"] = t
@@ -298,20 +442,43 @@ func (h *BillHandler) Update(c *gin.Context) {
 	if body.IsEnabled != nil {
 		updates["is_enabled"] = *body.IsEnabled
 	}
+	if body.AutoRenew != nil {
+		updates["auto_renew"] = *body.AutoRenew
+	}
 	if body.Status != nil {
 		s := models.BillStatus(*body.Status)
 		if s < models.BillStatusPending || s > models.BillStatusRefunded {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 1-4"})
+			apierror.Write(c, apierror.Validation("status", "status must be 1-4"))
 			return
 		}
 		updates["status"] = s
 	}
 
-	res := h.db.WithContext(c.Request.Context()).Model(&models.Bill{}).Where("id = ?", id).Updates(updates)
-	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if errUpdate := tx.Model(&models.Bill{}).Where("id = ?", id).Updates(updates).Error; errUpdate != nil {
+			return errUpdate
+		}
+		if existing.Status == models.BillStatusPaid {
+			return nil
+		}
+		// A bill that becomes paid settles outstanding overage debt first.
+		var updated models.Bill
+		if errFind := tx.First(&updated, id).Error; errFind != nil {
+			return errFind
+		}
+		_, errPay := billing.PayOverageDebtFromBill(c.Request.Context(), tx, &updated, updated.LeftQuota)
+		return errPay
+	})
+	if errTx != nil {
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
+	if status, ok := updates["status"].(models.BillStatus); ok && status != existing.Status {
+		var updated models.Bill
+		if errFind := h.db.WithContext(c.Request.Context()).First(&updated, id).Error; errFind == nil {
+			billing.NotifyBillEvent(billing.BillStatusEvent(status), &updated)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -319,16 +486,16 @@ func (h *BillHandler) Update(c *gin.Context) {
 func (h *BillHandler) Delete(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	res := h.db.WithContext(c.Request.Context()).Delete(&models.Bill{}, id)
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		apierror.Write(c, apierror.Internal("delete failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -348,7 +515,7 @@ func (h *BillHandler) Disable(c *gin.Context) {
 func (h *BillHandler) setEnabled(c *gin.Context, enabled bool) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
@@ -356,11 +523,11 @@ func (h *BillHandler) setEnabled(c *gin.Context, enabled bool) {
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Bill{}).Where("id = ?", id).
 		Updates(map[string]any{"is_enabled": enabled, "updated_at": now})
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -385,6 +552,8 @@ func (h *BillHandler) formatBill(bill *models.Bill) gin.H {
 		"rate_limit":    bill.RateLimit,
 		"is_enabled":    bill.IsEnabled,
 		"status":        bill.Status,
+		"auto_renew":    bill.AutoRenew,
+		"renewed_from":  bill.RenewedFromBillID,
 		"created_at":    bill.CreatedAt,
 		"updated_at":    bill.UpdatedAt,
 	}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestBillListPagesFiltersAndSorts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:bills_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Bill{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		start := base.AddDate(0, i, 0)
+		bill := models.Bill{
+			PlanID:      1,
+			UserID:      uint64(1 + i%2),
+			PeriodType:  models.BillPeriodTypeMonthly,
+			Amount:      float64(10 * (5 - i)),
+			PeriodStart: start,
+			PeriodEnd:   start.AddDate(0, 1, 0).Add(-time.Second),
+			IsEnabled:   true,
+			Status:      models.BillStatusPaid,
+			CreatedAt:   base.Add(time.Duration(i) * time.Hour),
+			UpdatedAt:   base.Add(time.Duration(i) * time.Hour),
+		}
+		if errCreate := db.Create(&bill).Error; errCreate != nil {
+			t.Fatalf("create bill: %v", errCreate)
+		}
+	}
+
+	h := NewBillHandler(db)
+	r := gin.New()
+	r.GET("/bills", h.List)
+	list := func(target string) (int, []float64, int64) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var body struct {
+			Bills []struct {
+				Amount float64 `json:"amount"`
+			} `json:"bills"`
+			Total int64 `json:"total"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		amounts := make([]float64, 0, len(body.Bills))
+		for _, bill := range body.Bills {
+			amounts = append(amounts, bill.Amount)
+		}
+		return w.Code, amounts, body.Total
+	}
+
+	code, amounts, total := list("/bills?page=2&page_size=2")
+	if code != http.StatusOK || total != 5 || fmt.Sprint(amounts) != "[30 40]" {
+		t.Fatalf("expected the second page by created_at desc, got %d %v total=%d", code, amounts, total)
+	}
+	code, amounts, total = list("/bills?sort=amount&order=asc&page_size=3")
+	if code != http.StatusOK || total != 5 || fmt.Sprint(amounts) != "[10 20 30]" {
+		t.Fatalf("expected amount ascending, got %d %v total=%d", code, amounts, total)
+	}
+	// February through April, composed with user_id=2 (the February and April bills).
+	code, amounts, total = list("/bills?period_start_from=2026-02-01&period_end_to=2026-04-30T23:59:59Z&user_id=2&sort=period_end")
+	if code != http.StatusOK || total != 2 || fmt.Sprint(amounts) != "[20 40]" {
+		t.Fatalf("expected the filtered range, got %d %v total=%d", code, amounts, total)
+	}
+	if code, _, _ = list("/bills?period_end_to=yesterday"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid date, got %d", code)
+	}
+}
+
+func TestBillCreateDerivesPeriodAndQuotasFromPlan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:billcreate_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Plan{}, &models.Bill{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	plan := models.Plan{Name: "pro", TotalQuota: 100, DailyQuota: 10, RateLimit: 5, UserGroupID: models.UserGroupIDs{}}
+	if errCreate := db.Create(&plan).Error; errCreate != nil {
+		t.Fatalf("create plan: %v", errCreate)
+	}
+
+	h := NewBillHandler(db)
+	r := gin.New()
+	r.POST("/bills", h.Create)
+	create := func(body string) (int, models.Bill) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/bills", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		var created struct {
+			ID uint64 `json:"id"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &created)
+		var bill models.Bill
+		if w.Code == http.StatusCreated {
+			if errFind := db.First(&bill, created.ID).Error; errFind != nil {
+				t.Fatalf("load bill: %v", errFind)
+			}
+		}
+		return w.Code, bill
+	}
+
+	code, bill := create(fmt.Sprintf(`{"plan_id":%d,"user_id":1,"period_type":2,"status":2,"period_start":"2026-03-01T00:00:00Z","daily_quota":20}`, plan.ID))
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if !bill.PeriodEnd.Equal(time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected a yearly period end, got %s", bill.PeriodEnd)
+	}
+	if bill.TotalQuota != 100 || bill.LeftQuota != 100 || bill.DailyQuota != 20 || bill.RateLimit != 5 {
+		t.Fatalf("expected plan quotas with the daily override, got %+v", bill)
+	}
+
+	code, bill = create(fmt.Sprintf(`{"plan_id":%d,"user_id":1,"period_type":1,"status":2,"period_start":"2026-03-01T00:00:00Z","period_end":"2026-03-15T00:00:00Z"}`, plan.ID))
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if !bill.PeriodEnd.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)) || bill.TotalQuota != 0 {
+		t.Fatalf("expected the fully specified bill to be kept as given, got %+v", bill)
+	}
+}
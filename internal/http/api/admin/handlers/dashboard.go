@@ -1,23 +1,31 @@
 package handlers
 
 import (
+	"fmt"
 	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
-	"gorm.io/gorm"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	internalusage "github.com/router-for-me/CLIProxyAPIBusiness/internal/usage"
 )
 
 // DashboardHandler serves admin dashboard analytics endpoints.
 type DashboardHandler struct {
-	db *gorm.DB // Database handle for usage analytics.
+	dbs dbutil.DBProvider // Primary connection and optional read replica.
 }
 
 // NewDashboardHandler constructs a dashboard handler with database access.
-func NewDashboardHandler(db *gorm.DB) *DashboardHandler {
-	return &DashboardHandler{db: db}
+func NewDashboardHandler(dbs dbutil.DBProvider) *DashboardHandler {
+	return &DashboardHandler{dbs: dbs}
 }
 
 // kpiResponse defines the KPI response payload.
@@ -30,11 +38,12 @@ type kpiResponse struct {
 	SuccessRateTrend float64 `json:"success_rate_trend"`  // Trend vs yesterday.
 	MtdCostMicros    int64   `json:"mtd_cost_micros"`     // Month-to-date cost in micros.
 	CostTrend        float64 `json:"cost_trend"`          // Trend vs last month.
+	Currency         string  `json:"currency"`            // Display currency of the cost figures.
 }
 
 // KPI returns global KPI data for all users
 func (h *DashboardHandler) KPI(c *gin.Context) {
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
@@ -45,8 +54,8 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 		Failed           int64
 		AvgRequestTimeMs float64
 	}
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("requested_at >= ?", today).
+	h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+		Where("requested_at >= ?", today.UTC()).
 		Select(`
 			COUNT(*) AS total,
 			SUM(CASE WHEN failed THEN 1 ELSE 0 END) AS failed,
@@ -59,8 +68,8 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 		Failed           int64
 		AvgRequestTimeMs float64
 	}
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("requested_at >= ? AND requested_at < ?", yesterday, today).
+	h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+		Where("requested_at >= ? AND requested_at < ?", yesterday.UTC(), today.UTC()).
 		Select(`
 			COUNT(*) AS total,
 			SUM(CASE WHEN failed THEN 1 ELSE 0 END) AS failed,
@@ -68,19 +77,12 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 		`).
 		Scan(&yesterdayStats)
 
-	var mtdCost int64
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("requested_at >= ?", monthStart).
-		Select("COALESCE(SUM(cost_micros), 0)").
-		Scan(&mtdCost)
+	// Month-to-date costs read daily rollups for finished days and raw usages for the rest.
+	mtdCost, _ := internalusage.SumCost(c.Request.Context(), h.dbs.Read(), monthStart, today.AddDate(0, 0, 1), now)
 
 	lastMonthStart := monthStart.AddDate(0, -1, 0)
 	lastMonthSameDay := lastMonthStart.AddDate(0, 0, now.Day()-1)
-	var lastMtdCost int64
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("requested_at >= ? AND requested_at < ?", lastMonthStart, lastMonthSameDay).
-		Select("COALESCE(SUM(cost_micros), 0)").
-		Scan(&lastMtdCost)
+	lastMtdCost, _ := internalusage.SumCost(c.Request.Context(), h.dbs.Read(), lastMonthStart, lastMonthSameDay, now)
 
 	requestsTrend := calcTrend(float64(yesterdayStats.Total), float64(todayStats.Total))
 	successRate := 100.0
@@ -106,19 +108,22 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 		SuccessRateTrend: successRateTrend,
 		MtdCostMicros:    mtdCost,
 		CostTrend:        costTrend,
+		Currency:         billing.DisplayCurrency(),
 	})
 }
 
 // trafficPoint represents hourly traffic metrics.
 type trafficPoint struct {
-	Time     string `json:"time"`     // Hour label.
-	Requests int64  `json:"requests"` // Request count.
-	Errors   int64  `json:"errors"`   // Failed request count.
+	Time      string `json:"time"`       // Hour label.
+	Requests  int64  `json:"requests"`   // Request count.
+	Errors    int64  `json:"errors"`     // Failed request count.
+	Stream    int64  `json:"stream"`     // Streamed request count.
+	NonStream int64  `json:"non_stream"` // Non-streamed request count.
 }
 
 // Traffic returns global traffic data (hourly requests for 24 hours)
 func (h *DashboardHandler) Traffic(c *gin.Context) {
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 
@@ -129,17 +134,23 @@ func (h *DashboardHandler) Traffic(c *gin.Context) {
 
 		var count int64
 		var errCount int64
-		h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-			Where("requested_at >= ? AND requested_at < ?", hourStart, hourEnd).
+		var streamCount int64
+		h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+			Where("requested_at >= ? AND requested_at < ?", hourStart.UTC(), hourEnd.UTC()).
 			Count(&count)
-		h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-			Where("requested_at >= ? AND requested_at < ? AND failed = true", hourStart, hourEnd).
+		h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+			Where("requested_at >= ? AND requested_at < ? AND failed = true", hourStart.UTC(), hourEnd.UTC()).
 			Count(&errCount)
+		h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+			Where("requested_at >= ? AND requested_at < ? AND stream = ?", hourStart.UTC(), hourEnd.UTC(), true).
+			Count(&streamCount)
 
 		points[i] = trafficPoint{
-			Time:     hourStart.Format("15:04"),
-			Requests: count,
-			Errors:   errCount,
+			Time:      hourStart.Format("15:04"),
+			Requests:  count,
+			Errors:    errCount,
+			Stream:    streamCount,
+			NonStream: count - streamCount,
 		}
 	}
 
@@ -148,33 +159,31 @@ func (h *DashboardHandler) Traffic(c *gin.Context) {
 
 // costItem represents cost distribution for a model.
 type costItem struct {
-	Model      string  `json:"model"`       // Model identifier.
-	CostMicros int64   `json:"cost_micros"` // Cost in micros.
-	Percentage float64 `json:"percentage"`  // Share of total cost.
+	Model             string  `json:"model"`               // Model identifier.
+	CostMicros        int64   `json:"cost_micros"`         // Cost in micros.
+	SuccessCostMicros int64   `json:"success_cost_micros"` // Cost of successful requests in micros.
+	FailedCostMicros  int64   `json:"failed_cost_micros"`  // Cost of failed requests in micros.
+	Percentage        float64 `json:"percentage"`          // Share of total cost.
 }
 
 // CostDistribution returns global cost distribution grouped by model
 func (h *DashboardHandler) CostDistribution(c *gin.Context) {
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
 
-	// modelCost captures aggregated costs per model.
-	type modelCost struct {
-		Model      string // Model identifier.
-		CostMicros int64  // Aggregated cost in micros.
+	// Finished days come from daily rollups; only the current UTC day scans raw usages.
+	results, errCost := internalusage.CostByModel(c.Request.Context(), h.dbs.Read(), monthStart, tomorrow, now)
+	if errCost != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query cost distribution failed"})
+		return
 	}
-	var results []modelCost
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("requested_at >= ?", monthStart).
-		Select("model, COALESCE(SUM(cost_micros), 0) AS cost_micros").
-		Group("model").
-		Order("cost_micros DESC").
-		Scan(&results)
 
-	var totalCost int64
+	var totalCost, failedCost int64
 	for _, r := range results {
 		totalCost += r.CostMicros
+		failedCost += r.FailedCostMicros
 	}
 
 	items := make([]costItem, 0, len(results))
@@ -184,13 +193,257 @@ func (h *DashboardHandler) CostDistribution(c *gin.Context) {
 			pct = float64(r.CostMicros) / float64(totalCost) * 100
 		}
 		items = append(items, costItem{
-			Model:      r.Model,
-			CostMicros: r.CostMicros,
-			Percentage: pct,
+			Model:             r.Model,
+			CostMicros:        r.CostMicros,
+			SuccessCostMicros: r.CostMicros - r.FailedCostMicros,
+			FailedCostMicros:  r.FailedCostMicros,
+			Percentage:        pct,
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	c.JSON(http.StatusOK, gin.H{
+		"items":               items,
+		"total_cost_micros":   totalCost,
+		"success_cost_micros": totalCost - failedCost,
+		"failed_cost_micros":  failedCost,
+	})
+}
+
+const (
+	// defaultTopLimit is the number of entries returned by the top-N endpoints.
+	defaultTopLimit = 10
+	// maxTopLimit bounds the limit accepted by the top-N endpoints.
+	maxTopLimit = 100
+)
+
+// topRange parses the from/to query range (RFC3339) of the top-N endpoints. It
+// defaults to month-to-date, matching the cost distribution.
+func topRange(c *gin.Context, now time.Time) (time.Time, time.Time, bool) {
+	loc := internalsettings.BillingLocation()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+	if raw := strings.TrimSpace(c.Query("from")); raw != "" {
+		parsed, errParse := time.Parse(time.RFC3339, raw)
+		if errParse != nil {
+			apierror.Write(c, apierror.Validation("from", "invalid from format, use RFC3339"))
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	if raw := strings.TrimSpace(c.Query("to")); raw != "" {
+		parsed, errParse := time.Parse(time.RFC3339, raw)
+		if errParse != nil {
+			apierror.Write(c, apierror.Validation("to", "invalid to format, use RFC3339"))
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+	if !from.Before(to) {
+		apierror.Write(c, apierror.Validation("from", "from must be before to"))
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// topLimit parses the limit query parameter of the top-N endpoints.
+func topLimit(c *gin.Context) (int, bool) {
+	raw := strings.TrimSpace(c.Query("limit"))
+	if raw == "" {
+		return defaultTopLimit, true
+	}
+	limit, errParse := strconv.Atoi(raw)
+	if errParse != nil || limit < 1 || limit > maxTopLimit {
+		apierror.Write(c, apierror.Validation("limit", fmt.Sprintf("limit must be between 1 and %d", maxTopLimit)))
+		return 0, false
+	}
+	return limit, true
+}
+
+// percentOf returns part as a percentage of total.
+func percentOf(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// topConsumerItem represents one user's share of spend and traffic.
+type topConsumerItem struct {
+	UserID            uint64  `json:"user_id"`            // User ID; 0 for usage without a user.
+	Username          string  `json:"username"`           // Username; empty when the user is gone.
+	RequestCount      int64   `json:"request_count"`      // Request count.
+	FailedCount       int64   `json:"failed_count"`       // Failed request count.
+	TotalTokens       int64   `json:"total_tokens"`       // Total token count.
+	CostMicros        int64   `json:"cost_micros"`        // Cost in micros.
+	CostPercentage    float64 `json:"cost_percentage"`    // Share of total cost.
+	RequestPercentage float64 `json:"request_percentage"` // Share of total requests.
+}
+
+// TopConsumers returns the users with the highest spend, or request count when
+// sort=requests, in the from/to range.
+func (h *DashboardHandler) TopConsumers(c *gin.Context) {
+	from, to, ok := topRange(c, time.Now().In(internalsettings.BillingLocation()))
+	if !ok {
+		return
+	}
+	limit, ok := topLimit(c)
+	if !ok {
+		return
+	}
+	sortField := strings.ToLower(strings.TrimSpace(c.Query("sort")))
+	switch sortField {
+	case "":
+		sortField = "cost"
+	case "cost", "requests":
+	default:
+		apierror.Write(c, apierror.Validation("sort", "sort must be cost or requests"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	db := h.dbs.Read()
+	results, errUsage := internalusage.UsageByUser(ctx, db, from, to, time.Now())
+	if errUsage != nil {
+		apierror.Write(c, apierror.Internal("query top consumers failed"))
+		return
+	}
+	var totalCost, totalRequests int64
+	for _, r := range results {
+		totalCost += r.CostMicros
+		totalRequests += r.RequestCount
+	}
+	if sortField == "requests" {
+		sort.SliceStable(results, func(i, k int) bool { return results[i].RequestCount > results[k].RequestCount })
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	userIDs := make([]uint64, 0, len(results))
+	for _, r := range results {
+		if r.UserID != 0 {
+			userIDs = append(userIDs, r.UserID)
+		}
+	}
+	usernames := make(map[uint64]string, len(userIDs))
+	if len(userIDs) > 0 {
+		var users []models.User
+		if errFind := db.WithContext(ctx).Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; errFind != nil {
+			apierror.Write(c, apierror.Internal("query top consumers failed"))
+			return
+		}
+		for _, user := range users {
+			usernames[user.ID] = user.Username
+		}
+	}
+
+	items := make([]topConsumerItem, 0, len(results))
+	for _, r := range results {
+		items = append(items, topConsumerItem{
+			UserID:            r.UserID,
+			Username:          usernames[r.UserID],
+			RequestCount:      r.RequestCount,
+			FailedCount:       r.ErrorCount,
+			TotalTokens:       r.TotalTokens,
+			CostMicros:        r.CostMicros,
+			CostPercentage:    percentOf(r.CostMicros, totalCost),
+			RequestPercentage: percentOf(r.RequestCount, totalRequests),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":             items,
+		"total_cost_micros": totalCost,
+		"total_requests":    totalRequests,
+		"from":              from,
+		"to":                to,
+		"sort":              sortField,
+	})
+}
+
+// topModelItem represents one model's share of spend and tokens with its failure rate.
+type topModelItem struct {
+	Provider        string  `json:"provider"`         // Provider name.
+	Model           string  `json:"model"`            // Model identifier.
+	RequestCount    int64   `json:"request_count"`    // Request count.
+	FailedCount     int64   `json:"failed_count"`     // Failed request count.
+	FailureRate     float64 `json:"failure_rate"`     // Failed requests as a percentage of requests.
+	InputTokens     int64   `json:"input_tokens"`     // Input token total.
+	OutputTokens    int64   `json:"output_tokens"`    // Output token total.
+	TotalTokens     int64   `json:"total_tokens"`     // Total token count.
+	CostMicros      int64   `json:"cost_micros"`      // Cost in micros.
+	CostPercentage  float64 `json:"cost_percentage"`  // Share of total cost.
+	TokenPercentage float64 `json:"token_percentage"` // Share of total tokens.
+}
+
+// TopModels returns the models with the highest spend in the from/to range, or the
+// most tokens or highest failure rate when sort=tokens or sort=failure_rate.
+func (h *DashboardHandler) TopModels(c *gin.Context) {
+	from, to, ok := topRange(c, time.Now().In(internalsettings.BillingLocation()))
+	if !ok {
+		return
+	}
+	limit, ok := topLimit(c)
+	if !ok {
+		return
+	}
+	sortField := strings.ToLower(strings.TrimSpace(c.Query("sort")))
+	switch sortField {
+	case "":
+		sortField = "cost"
+	case "cost", "tokens", "failure_rate":
+	default:
+		apierror.Write(c, apierror.Validation("sort", "sort must be cost, tokens or failure_rate"))
+		return
+	}
+
+	results, errUsage := internalusage.UsageByModel(c.Request.Context(), h.dbs.Read(), from, to, time.Now())
+	if errUsage != nil {
+		apierror.Write(c, apierror.Internal("query top models failed"))
+		return
+	}
+	var totalCost, totalTokens, totalRequests, totalFailed int64
+	items := make([]topModelItem, 0, len(results))
+	for _, r := range results {
+		totalCost += r.CostMicros
+		totalTokens += r.TotalTokens
+		totalRequests += r.RequestCount
+		totalFailed += r.ErrorCount
+		items = append(items, topModelItem{
+			Provider:     r.Provider,
+			Model:        r.Model,
+			RequestCount: r.RequestCount,
+			FailedCount:  r.ErrorCount,
+			FailureRate:  percentOf(r.ErrorCount, r.RequestCount),
+			InputTokens:  r.InputTokens,
+			OutputTokens: r.OutputTokens,
+			TotalTokens:  r.TotalTokens,
+			CostMicros:   r.CostMicros,
+		})
+	}
+	for i := range items {
+		items[i].CostPercentage = percentOf(items[i].CostMicros, totalCost)
+		items[i].TokenPercentage = percentOf(items[i].TotalTokens, totalTokens)
+	}
+	switch sortField {
+	case "tokens":
+		sort.SliceStable(items, func(i, k int) bool { return items[i].TotalTokens > items[k].TotalTokens })
+	case "failure_rate":
+		sort.SliceStable(items, func(i, k int) bool { return items[i].FailureRate > items[k].FailureRate })
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":             items,
+		"total_cost_micros": totalCost,
+		"total_tokens":      totalTokens,
+		"total_requests":    totalRequests,
+		"failure_rate":      percentOf(totalFailed, totalRequests),
+		"from":              from,
+		"to":                to,
+		"sort":              sortField,
+	})
 }
 
 // healthItem represents a provider health status entry.
@@ -224,7 +477,7 @@ type transactionItem struct {
 // RecentTransactions returns recent transactions for all users
 func (h *DashboardHandler) RecentTransactions(c *gin.Context) {
 	var usages []models.Usage
-	if errFind := h.db.WithContext(c.Request.Context()).
+	if errFind := h.dbs.Read().WithContext(c.Request.Context()).
 		Order("requested_at DESC").
 		Limit(20).
 		Find(&usages).Error; errFind != nil {
@@ -243,7 +496,7 @@ func (h *DashboardHandler) RecentTransactions(c *gin.Context) {
 		transactions = append(transactions, transactionItem{
 			Status:     status,
 			StatusType: statusType,
-			Timestamp:  u.RequestedAt.In(time.Local).Format("2006-01-02 15:04:05"),
+			Timestamp:  u.RequestedAt.In(internalsettings.BillingLocation()).Format("2006-01-02 15:04:05"),
 			Method:     "POST",
 			Model:      u.Model,
 			Tokens:     u.TotalTokens,
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestDashboardTopConsumersAndModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:dashboard_top_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.Usage{}, &models.UsageDailyRollup{}, &models.UsageRollupDay{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if errCreate := db.Create(&models.User{Username: name, Email: name + "@example.com", Password: "x"}).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+	}
+
+	now := time.Now().UTC()
+	userID := func(id uint64) *uint64 { return &id }
+	rows := []models.Usage{
+		{Provider: "openai", Model: "gpt-a", UserID: userID(1), RequestedAt: now, TotalTokens: 100, CostMicros: 100},
+		{Provider: "openai", Model: "gpt-a", UserID: userID(2), RequestedAt: now, TotalTokens: 100, CostMicros: 200},
+		{Provider: "openai", Model: "gpt-b", UserID: userID(2), RequestedAt: now, TotalTokens: 900, CostMicros: 100, Failed: true},
+		{Provider: "openai", Model: "gpt-b", UserID: userID(1), RequestedAt: now, TotalTokens: 100, CostMicros: 0},
+		{Provider: "openai", Model: "gpt-b", UserID: userID(1), RequestedAt: now, TotalTokens: 100, CostMicros: 0},
+		{Provider: "openai", Model: "gpt-a", UserID: userID(1), RequestedAt: now.AddDate(-1, 0, 0), CostMicros: 1000},
+	}
+	if errCreate := db.Create(&rows).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	h := NewDashboardHandler(dbutil.NewDBProvider(db, nil))
+	r := gin.New()
+	r.GET("/top-consumers", h.TopConsumers)
+	r.GET("/top-models", h.TopModels)
+	get := func(target string, out any) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if out != nil && w.Code == http.StatusOK {
+			if errDecode := json.Unmarshal(w.Body.Bytes(), out); errDecode != nil {
+				t.Fatalf("decode %s: %v", target, errDecode)
+			}
+		}
+		return w.Code
+	}
+	rangeQuery := "from=" + url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339)) + "&to=" + url.QueryEscape(now.Add(time.Hour).Format(time.RFC3339))
+
+	var consumers struct {
+		Items           []topConsumerItem `json:"items"`
+		TotalCostMicros int64             `json:"total_cost_micros"`
+	}
+	if code := get("/top-consumers?"+rangeQuery, &consumers); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if consumers.TotalCostMicros != 400 || len(consumers.Items) != 2 {
+		t.Fatalf("unexpected consumers: %+v", consumers)
+	}
+	top := consumers.Items[0]
+	if top.Username != "bob" || top.CostMicros != 300 || top.CostPercentage != 75 {
+		t.Fatalf("expected bob first with 75%% of cost, got %+v", top)
+	}
+
+	consumers.Items = nil
+	if code := get("/top-consumers?sort=requests&limit=1&"+rangeQuery, &consumers); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(consumers.Items) != 1 || consumers.Items[0].Username != "alice" || consumers.Items[0].RequestPercentage != 60 {
+		t.Fatalf("expected alice first by requests, got %+v", consumers.Items)
+	}
+
+	var modelsResp struct {
+		Items []topModelItem `json:"items"`
+	}
+	if code := get("/top-models?sort=failure_rate&"+rangeQuery, &modelsResp); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(modelsResp.Items) != 2 || modelsResp.Items[0].Model != "gpt-b" {
+		t.Fatalf("expected gpt-b first by failure rate, got %+v", modelsResp.Items)
+	}
+	gptB := modelsResp.Items[0]
+	if gptB.FailedCount != 1 || gptB.TokenPercentage != 1100.0/1300*100 || gptB.CostPercentage != 25 {
+		t.Fatalf("unexpected gpt-b stats: %+v", gptB)
+	}
+
+	for _, target := range []string{"/top-models?limit=101", "/top-models?limit=0", "/top-consumers?sort=tokens", "/top-consumers?from=yesterday"} {
+		if code := get(target, nil); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, code)
+		}
+	}
+}
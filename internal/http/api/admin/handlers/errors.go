@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+)
+
+// ListErrorCodes returns the error codes admin endpoints may return.
+func ListErrorCodes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"codes": apierror.Codes})
+}
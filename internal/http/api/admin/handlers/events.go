@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/events"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+)
+
+const (
+	// eventStreamBuffer is how many events a client may fall behind before it is dropped.
+	eventStreamBuffer = 256
+	// eventStreamKeepAlive is the interval of comment lines that keep idle proxies from
+	// closing the stream.
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+// EventHandler streams live events to the admin panel.
+type EventHandler struct {
+	broker *events.Broker
+}
+
+// NewEventHandler constructs an EventHandler for broker.
+func NewEventHandler(broker *events.Broker) *EventHandler {
+	return &EventHandler{broker: broker}
+}
+
+// eventStreamQuery defines the event stream filters.
+type eventStreamQuery struct {
+	Types       string `form:"types"`        // Comma-separated event types; empty streams every type.
+	AccessToken string `form:"access_token"` // Admin JWT for clients that cannot set headers; read by the auth middleware.
+}
+
+// Stream serves server-sent events until the client disconnects. A client that falls
+// more than eventStreamBuffer events behind receives a final "dropped" event and is
+// disconnected; it should reconnect.
+func (h *EventHandler) Stream(c *gin.Context) {
+	var query eventStreamQuery
+	if errBind := c.ShouldBindQuery(&query); errBind != nil {
+		apierror.Write(c, apierror.InvalidRequest("invalid query"))
+		return
+	}
+	wanted := make(map[string]bool)
+	for _, raw := range strings.Split(query.Types, ",") {
+		eventType := strings.TrimSpace(raw)
+		if eventType == "" {
+			continue
+		}
+		if !isEventType(eventType) {
+			apierror.Write(c, apierror.Validation("types", "unknown event type "+eventType))
+			return
+		}
+		wanted[eventType] = true
+	}
+
+	sub := h.broker.Subscribe(eventStreamBuffer)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if _, errWrite := c.Writer.WriteString(": keep-alive\n\n"); errWrite != nil {
+				return
+			}
+		case evt, ok := <-sub.Events():
+			if !ok {
+				c.SSEvent("dropped", gin.H{"reason": "client fell behind"})
+				c.Writer.Flush()
+				return
+			}
+			if len(wanted) > 0 && !wanted[evt.Type] {
+				continue
+			}
+			c.SSEvent(evt.Type, evt)
+		}
+		c.Writer.Flush()
+	}
+}
+
+// isEventType reports whether eventType is a known event type.
+func isEventType(eventType string) bool {
+	for _, known := range events.Types {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
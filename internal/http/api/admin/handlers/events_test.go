@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/events"
+)
+
+func TestEventStreamFiltersByType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	broker := events.NewBroker()
+	r := gin.New()
+	r.GET("/v0/admin/events/stream", NewEventHandler(broker).Stream)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	if resp, errGet := http.Get(srv.URL + "/v0/admin/events/stream?types=usage,nope"); errGet != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown type to be rejected, got %v %v", resp, errGet)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v0/admin/events/stream?types=usage", nil)
+	resp, errDo := http.DefaultClient.Do(req)
+	if errDo != nil {
+		t.Fatalf("open stream: %v", errDo)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected stream response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// Headers are flushed after subscribing, so these events reach the stream.
+	broker.Publish(events.TypeRateLimit, events.RateLimitData{UserID: 1})
+	broker.Publish(events.TypeUsage, events.UsageData{ID: 42, Model: "gpt"})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, errRead := reader.ReadString('\n')
+		if errRead != nil {
+			t.Fatalf("read stream: %v", errRead)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if lines[0] != "event:usage" || !strings.Contains(lines[1], `"id":42`) || !strings.Contains(lines[1], `"type":"usage"`) {
+		t.Fatalf("expected only the usage event, got %v", lines)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelcache"
+	internalusage "github.com/router-for-me/CLIProxyAPIBusiness/internal/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
 	"gorm.io/gorm"
 )
 
@@ -17,7 +20,8 @@ func NewHealthHandler(db *gorm.DB) *HealthHandler {
 	return &HealthHandler{db: db}
 }
 
-// Healthz checks database connectivity and returns status.
+// Healthz checks database connectivity and reports the usage retry buffer depth, the
+// watcher dispatch queue state and the model list cache counters.
 func (h *HealthHandler) Healthz(c *gin.Context) {
 	sqlDB, err := h.db.DB()
 	if err != nil {
@@ -25,8 +29,20 @@ func (h *HealthHandler) Healthz(c *gin.Context) {
 		return
 	}
 	if errPing := sqlDB.PingContext(c.Request.Context()); errPing != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"ok": false})
+		c.JSON(http.StatusServiceUnavailable, healthBody(false))
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+	c.JSON(http.StatusOK, healthBody(true))
+}
+
+// healthBody builds the health response with the queue metrics.
+func healthBody(ok bool) gin.H {
+	return gin.H{
+		"ok":                       ok,
+		"usage_retry_buffer_depth": internalusage.RetryBufferDepth(),
+		"watcher_dispatch_pending": watcher.DispatchPendingDepth(),
+		"watcher_dispatch_dropped": watcher.DispatchDropped(),
+		"model_list_cache_hits":    modelcache.Hits(),
+		"model_list_cache_misses":  modelcache.Misses(),
+	}
 }
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/audit"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ImpersonationHandler issues short-lived user tokens to support staff.
+type ImpersonationHandler struct {
+	db     *gorm.DB
+	jwtCfg config.JWTConfig
+}
+
+// NewImpersonationHandler constructs an ImpersonationHandler.
+func NewImpersonationHandler(db *gorm.DB, jwtCfg config.JWTConfig) *ImpersonationHandler {
+	return &ImpersonationHandler{db: db, jwtCfg: jwtCfg}
+}
+
+// Impersonate issues a user JWT marked with the calling admin and records an audit entry.
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	adminID := c.GetUint64("adminID")
+	if adminID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var user models.User
+	if errFind := h.db.WithContext(ctx).First(&user, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user disabled"})
+		return
+	}
+
+	ttl := impersonationTokenTTL()
+	token, errToken := security.GenerateImpersonationToken(h.jwtCfg.Secret, user.ID, user.Username, user.Name, user.Email, adminID, ttl)
+	if errToken != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	expiresAt := time.Now().UTC().Add(ttl)
+
+	targetID := user.ID
+	if errAudit := audit.Record(ctx, h.db, audit.Entry{
+		ActorType:  audit.ActorAdmin,
+		ActorID:    adminID,
+		Action:     audit.ActionImpersonateGrant,
+		TargetType: audit.TargetUser,
+		TargetID:   &targetID,
+		Detail: map[string]any{
+			"admin_username": c.GetString("adminUsername"),
+			"expires_at":     expiresAt,
+		},
+		IP: c.ClientIP(),
+	}); errAudit != nil {
+		log.WithError(errAudit).Error("impersonation: record audit entry failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "record audit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":         user.ID,
+		"username":        user.Username,
+		"name":            user.Name,
+		"email":           user.Email,
+		"token":           token,
+		"impersonated_by": adminID,
+		"expires_at":      expiresAt,
+	})
+}
+
+// impersonationTokenTTL reads IMPERSONATION_TOKEN_TTL_SECONDS, capped at one hour.
+func impersonationTokenTTL() time.Duration {
+	return internalsettings.GetDuration(internalsettings.ImpersonationTokenTTLSecondsKey)
+}
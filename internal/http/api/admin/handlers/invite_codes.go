@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// InviteCodeHandler handles admin operations for registration invite codes.
+type InviteCodeHandler struct {
+	db *gorm.DB // Database handle for invite code queries.
+}
+
+// NewInviteCodeHandler wires an invite code handler with its database dependency.
+func NewInviteCodeHandler(db *gorm.DB) *InviteCodeHandler {
+	return &InviteCodeHandler{db: db}
+}
+
+// createInviteCodeRequest captures the payload for creating invite codes.
+type createInviteCodeRequest struct {
+	Code      string     `json:"code"`       // Optional explicit code; generated when empty.
+	Count     int        `json:"count"`      // Number of generated codes (ignored with code).
+	Note      string     `json:"note"`       // Optional admin note.
+	MaxUses   *int       `json:"max_uses"`   // Optional max registrations (0 = unlimited).
+	ExpiresAt *time.Time `json:"expires_at"` // Optional expiration time.
+	IsEnabled *bool      `json:"is_enabled"` // Optional active flag.
+}
+
+// Create persists one explicit or several generated invite codes.
+func (h *InviteCodeHandler) Create(c *gin.Context) {
+	var body createInviteCodeRequest
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+	code := strings.TrimSpace(body.Code)
+	count := body.Count
+	if code != "" {
+		count = 1
+	} else if count == 0 {
+		count = 1
+	}
+	if count < 1 || count > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and 1000"})
+		return
+	}
+	maxUses := 1
+	if body.MaxUses != nil {
+		if *body.MaxUses < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses cannot be negative"})
+			return
+		}
+		maxUses = *body.MaxUses
+	}
+	isEnabled := true
+	if body.IsEnabled != nil {
+		isEnabled = *body.IsEnabled
+	}
+
+	now := time.Now().UTC()
+	created := make([]gin.H, 0, count)
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		for i := 0; i < count; i++ {
+			value := code
+			if value == "" {
+				generated, errGen := generateCode(12)
+				if errGen != nil {
+					return errGen
+				}
+				value = generated
+			}
+			invite := models.InviteCode{
+				Code:      value,
+				Note:      strings.TrimSpace(body.Note),
+				MaxUses:   maxUses,
+				ExpiresAt: body.ExpiresAt,
+				IsEnabled: isEnabled,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if errCreate := tx.Create(&invite).Error; errCreate != nil {
+				return errCreate
+			}
+			created = append(created, formatInviteCode(&invite))
+		}
+		return nil
+	})
+	if errTx != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create invite code failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"invite_codes": created})
+}
+
+// List returns invite codes, newest first.
+func (h *InviteCodeHandler) List(c *gin.Context) {
+	var rows []models.InviteCode
+	if errFind := h.db.WithContext(c.Request.Context()).Order("created_at DESC").Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list invite codes failed"})
+		return
+	}
+	out := make([]gin.H, 0, len(rows))
+	for i := range rows {
+		out = append(out, formatInviteCode(&rows[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"invite_codes": out})
+}
+
+// updateInviteCodeRequest captures optional fields for invite code updates.
+type updateInviteCodeRequest struct {
+	Note      *string    `json:"note"`       // Optional updated note.
+	MaxUses   *int       `json:"max_uses"`   // Optional updated max registrations.
+	ExpiresAt *time.Time `json:"expires_at"` // Optional updated expiration time.
+	IsEnabled *bool      `json:"is_enabled"` // Optional active flag.
+}
+
+// Update applies validated field changes to an invite code.
+func (h *InviteCodeHandler) Update(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var body updateInviteCodeRequest
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	updates := map[string]any{}
+	if body.Note != nil {
+		updates["note"] = strings.TrimSpace(*body.Note)
+	}
+	if body.MaxUses != nil {
+		if *body.MaxUses < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses cannot be negative"})
+			return
+		}
+		updates["max_uses"] = *body.MaxUses
+	}
+	if body.ExpiresAt != nil {
+		updates["expires_at"] = body.ExpiresAt.UTC()
+	}
+	if body.IsEnabled != nil {
+		updates["is_enabled"] = *body.IsEnabled
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+	updates["updated_at"] = time.Now().UTC()
+
+	res := h.db.WithContext(c.Request.Context()).Model(&models.InviteCode{}).Where("id = ?", id).Updates(updates)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var invite models.InviteCode
+	if errFind := h.db.WithContext(c.Request.Context()).First(&invite, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, formatInviteCode(&invite))
+}
+
+// Delete removes an invite code by ID.
+func (h *InviteCodeHandler) Delete(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	res := h.db.WithContext(c.Request.Context()).Delete(&models.InviteCode{}, id)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// formatInviteCode maps an invite code model into a response payload.
+func formatInviteCode(invite *models.InviteCode) gin.H {
+	return gin.H{
+		"id":         invite.ID,
+		"code":       invite.Code,
+		"note":       invite.Note,
+		"max_uses":   invite.MaxUses,
+		"used_count": invite.UsedCount,
+		"expires_at": invite.ExpiresAt,
+		"is_enabled": invite.IsEnabled,
+		"created_at": invite.CreatedAt,
+		"updated_at": invite.UpdatedAt,
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/jobs"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// jobListColumns are the job columns shown by List and Get; file contents are only read
+// by Download.
+var jobListColumns = []string{
+	"id", "type", "status", "progress", "params", "result", "error", "file_name",
+	"created_by", "started_at", "finished_at", "created_at", "updated_at",
+}
+
+// JobHandler exposes background job status.
+type JobHandler struct {
+	db *gorm.DB
+}
+
+// NewJobHandler constructs a JobHandler.
+func NewJobHandler(db *gorm.DB) *JobHandler {
+	return &JobHandler{db: db}
+}
+
+// jobListQuery defines filters for the job list.
+type jobListQuery struct {
+	Type      string `form:"type"`                 // Job type filter.
+	Status    string `form:"status"`               // Job status filter.
+	CreatedBy uint64 `form:"created_by"`           // Admin ID filter.
+	Page      int    `form:"page,default=1"`       // Page number (1-based).
+	PageSize  int    `form:"page_size,default=20"` // Page size.
+}
+
+// List returns jobs newest first, filtered by type, status and created_by.
+func (h *JobHandler) List(c *gin.Context) {
+	var query jobListQuery
+	if errBind := c.ShouldBindQuery(&query); errBind != nil {
+		apierror.Write(c, apierror.InvalidRequest("invalid query"))
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	q := h.db.WithContext(c.Request.Context()).Model(&models.Job{})
+	if jobType := strings.TrimSpace(query.Type); jobType != "" {
+		q = q.Where("type = ?", jobType)
+	}
+	switch status := strings.TrimSpace(query.Status); status {
+	case "":
+	case models.JobStatusQueued, models.JobStatusRunning, models.JobStatusSucceeded, models.JobStatusFailed:
+		q = q.Where("status = ?", status)
+	default:
+		apierror.Write(c, apierror.Validation("status", "status must be queued, running, succeeded or failed"))
+		return
+	}
+	if query.CreatedBy != 0 {
+		q = q.Where("created_by = ?", query.CreatedBy)
+	}
+
+	var total int64
+	if errCount := q.Count(&total).Error; errCount != nil {
+		apierror.Write(c, apierror.Internal("count jobs failed"))
+		return
+	}
+	var rows []models.Job
+	if errFind := q.Select(jobListColumns).
+		Order("created_at DESC, id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&rows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("list jobs failed"))
+		return
+	}
+	out := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, jobs.View(row))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":      out,
+		"types":     jobs.Types(),
+		"total":     total,
+		"page":      query.Page,
+		"page_size": query.PageSize,
+	})
+}
+
+// Get returns one job.
+func (h *JobHandler) Get(c *gin.Context) {
+	job, ok := h.find(c, jobListColumns)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, jobs.View(job))
+}
+
+// Download serves the file produced by a succeeded job.
+func (h *JobHandler) Download(c *gin.Context) {
+	job, ok := h.find(c, []string{"id", "status", "file_name", "file_content_type", "file_data"})
+	if !ok {
+		return
+	}
+	if job.Status != models.JobStatusSucceeded || job.FileName == "" {
+		apierror.Write(c, apierror.NotFound("job has no file"))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+job.FileName+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, job.FileContentType, job.FileData)
+}
+
+// find loads the columns of the job named by the id path parameter, writing the error
+// response when it cannot.
+func (h *JobHandler) find(c *gin.Context, columns []string) (models.Job, bool) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return models.Job{}, false
+	}
+	var job models.Job
+	if errFind := h.db.WithContext(c.Request.Context()).Select(columns).Take(&job, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("not found"))
+			return models.Job{}, false
+		}
+		apierror.Write(c, apierror.Internal("query failed"))
+		return models.Job{}, false
+	}
+	return job, true
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/jobs"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+)
+
+func TestJobsRunUsageExportAndAuthFileCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auths := []models.Auth{
+		{Key: "good.json", Content: datatypes.JSON(`{"type":"claude","access_token":"tok"}`)},
+		{Key: "bad.json", Content: datatypes.JSON(`{"type":"claude"}`)},
+		{Key: "other.json", Content: datatypes.JSON(`{"type":"codex"}`)},
+	}
+	if errCreate := db.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	usages := []models.Usage{
+		{Provider: "openai", Model: "gpt-large", RequestedModel: "acme-gpt-large", RequestedAt: day, TotalTokens: 10, CostMicros: 1500},
+		{Provider: "openai", Model: "gpt-large", RequestedAt: day.Add(time.Hour), TotalTokens: 20},
+		{Provider: "openai", Model: "gpt-large", RequestedAt: day.AddDate(0, 0, 2), TotalTokens: 30},
+	}
+	if errCreate := db.Create(&usages).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("adminID", uint64(3)) })
+	r.POST("/v0/admin/usage/export", NewUsageHandler(dbutil.NewDBProvider(db, nil)).Export)
+	r.POST("/v0/admin/auth-files/check", NewAuthFileHandler(db).Check)
+	jobHandler := NewJobHandler(db)
+	r.GET("/v0/admin/jobs", jobHandler.List)
+	r.GET("/v0/admin/jobs/:id", jobHandler.Get)
+	r.GET("/v0/admin/jobs/:id/download", jobHandler.Download)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	queue := func(path, body string) uint64 {
+		t.Helper()
+		w := serve(http.MethodPost, path, body)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("queue %s: expected 202, got %d: %s", path, w.Code, w.Body.String())
+		}
+		var job struct {
+			ID     uint64 `json:"id"`
+			Status string `json:"status"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &job)
+		if job.Status != models.JobStatusQueued || w.Header().Get("Location") != fmt.Sprintf("/v0/admin/jobs/%d", job.ID) {
+			t.Fatalf("expected a queued job with its location, got %s %v", w.Body.String(), w.Header())
+		}
+		return job.ID
+	}
+
+	exportID := queue("/v0/admin/usage/export", `{"from":"2026-03-01T00:00:00Z","to":"2026-03-01T23:59:59Z"}`)
+	checkID := queue("/v0/admin/auth-files/check", `{"type":"claude"}`)
+	if w := serve(http.MethodPost, "/v0/admin/usage/export", `{"from":"2026-03-02T00:00:00Z","to":"2026-03-01T00:00:00Z"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an inverted range to be rejected, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, fmt.Sprintf("/v0/admin/jobs/%d/download", exportID), ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected no file before the job ran, got %d", w.Code)
+	}
+
+	runner := jobs.NewRunner(db)
+	for i := 0; i < 2; i++ {
+		if ran, errRun := runner.RunOnce(context.Background()); errRun != nil || !ran {
+			t.Fatalf("run job: ran=%t err=%v", ran, errRun)
+		}
+	}
+
+	w := serve(http.MethodGet, fmt.Sprintf("/v0/admin/jobs/%d/download", exportID), "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected a csv download, got %d %v", w.Code, w.Header())
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "acme-gpt-large") {
+		t.Fatalf("expected a header and the two rows of the day, got %q", lines)
+	}
+
+	w = serve(http.MethodGet, fmt.Sprintf("/v0/admin/jobs/%d", checkID), "")
+	var check struct {
+		Status   string `json:"status"`
+		Progress int    `json:"progress"`
+		Result   struct {
+			Checked  int               `json:"checked"`
+			Invalid  int               `json:"invalid"`
+			Problems []authFileProblem `json:"problems"`
+		} `json:"result"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &check); errDecode != nil {
+		t.Fatalf("decode job: %v", errDecode)
+	}
+	if check.Status != models.JobStatusSucceeded || check.Progress != 100 || check.Result.Checked != 2 || check.Result.Invalid != 1 {
+		t.Fatalf("unexpected check job %s", w.Body.String())
+	}
+	if problem := check.Result.Problems[0]; problem.Key != "bad.json" || !strings.Contains(problem.Error, "access_token") {
+		t.Fatalf("unexpected problem %+v", problem)
+	}
+
+	w = serve(http.MethodGet, "/v0/admin/jobs?type=usage.export_csv&status=succeeded&created_by=3", "")
+	var list struct {
+		Jobs  []map[string]any `json:"jobs"`
+		Total int64            `json:"total"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Total != 1 || len(list.Jobs) != 1 || list.Jobs[0]["type"] != "usage.export_csv" {
+		t.Fatalf("expected the export job, got %s", w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/v0/admin/jobs?status=done", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown status to be rejected, got %d", w.Code)
+	}
+	if w = serve(http.MethodGet, "/v0/admin/jobs/999", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown job, got %d", w.Code)
+	}
+}
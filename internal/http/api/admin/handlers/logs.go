@@ -8,18 +8,20 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
-	"gorm.io/gorm"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 )
 
 // AdminLogsHandler serves admin usage log endpoints.
 type AdminLogsHandler struct {
-	db *gorm.DB // Database handle for usage queries.
+	dbs dbutil.DBProvider // Primary connection and optional read replica.
 }
 
 // NewAdminLogsHandler constructs an admin logs handler.
-func NewAdminLogsHandler(db *gorm.DB) *AdminLogsHandler {
-	return &AdminLogsHandler{db: db}
+func NewAdminLogsHandler(dbs dbutil.DBProvider) *AdminLogsHandler {
+	return &AdminLogsHandler{dbs: dbs}
 }
 
 // adminLogsListQuery defines filters for the aggregated list view.
@@ -52,22 +54,28 @@ type adminLogEntry struct {
 
 // adminLogDetailQuery defines filters for log detail entries.
 type adminLogDetailQuery struct {
-	Date     string `form:"date" binding:"required"` // Target date.
-	Model    string `form:"model"`                   // Model filter.
-	Provider string `form:"provider"`                // Provider filter.
-	Project  string `form:"project"`                 // Project/source filter.
+	Date      string `form:"date"`       // Target date; required unless request_id is set.
+	RequestID string `form:"request_id"` // X-Request-ID lookup key.
+	Model     string `form:"model"`      // Model filter.
+	Provider  string `form:"provider"`   // Provider filter.
+	Project   string `form:"project"`    // Project/source filter.
 }
 
 // adminLogDetailEntry represents a single usage record in detail view.
 type adminLogDetailEntry struct {
-	RequestedAt  time.Time `json:"requested_at"`  // Request timestamp.
-	InputTokens  int64     `json:"input_tokens"`  // Input token count.
-	OutputTokens int64     `json:"output_tokens"` // Output token count.
-	CachedTokens int64     `json:"cached_tokens"` // Cached token count.
-	TotalTokens  int64     `json:"total_tokens"`  // Total token count.
-	CostMicros   int64     `json:"cost_micros"`   // Cost in micros.
-	Failed       bool      `json:"failed"`        // Failure flag.
-	Username     string    `json:"username"`      // Username.
+	RequestedAt        time.Time `json:"requested_at"`         // Request timestamp.
+	RequestID          *string   `json:"request_id"`           // X-Request-ID of the request.
+	Model              string    `json:"model"`                // Canonical model used for billing.
+	RequestedModel     string    `json:"requested_model"`      // Model sent by the client, e.g. a branded name.
+	InputTokens        int64     `json:"input_tokens"`         // Input token count.
+	OutputTokens       int64     `json:"output_tokens"`        // Output token count.
+	CachedTokens       int64     `json:"cached_tokens"`        // Cached token count.
+	TotalTokens        int64     `json:"total_tokens"`         // Total token count.
+	CostMicros         int64     `json:"cost_micros"`          // Cost in micros.
+	ComputedCostMicros int64     `json:"computed_cost_micros"` // Rule-based cost estimate in micros.
+	UpstreamCostMicros *int64    `json:"upstream_cost_micros"` // Provider-reported cost in micros, when known.
+	Failed             bool      `json:"failed"`               // Failure flag.
+	Username           string    `json:"username"`             // Username.
 }
 
 // List returns aggregated usage logs with paging and filters.
@@ -86,17 +94,17 @@ func (h *AdminLogsHandler) List(c *gin.Context) {
 
 	ctx := c.Request.Context()
 
-	query := h.db.WithContext(ctx).
+	query := h.dbs.Read().WithContext(ctx).
 		Model(&models.Usage{})
 
 	if q.StartDate != "" {
-		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, time.Local); errParse == nil {
-			query = query.Where("requested_at >= ?", startTime)
+		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, internalsettings.BillingLocation()); errParse == nil {
+			query = query.Where("requested_at >= ?", startTime.UTC())
 		}
 	}
 	if q.EndDate != "" {
-		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, time.Local); errParse == nil {
-			query = query.Where("requested_at < ?", endTime.AddDate(0, 0, 1))
+		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, internalsettings.BillingLocation()); errParse == nil {
+			query = query.Where("requested_at < ?", endTime.AddDate(0, 0, 1).UTC())
 		}
 	}
 	if q.Project != "" {
@@ -120,16 +128,16 @@ func (h *AdminLogsHandler) List(c *gin.Context) {
 	}
 
 	var total int64
-	countQuery := h.db.WithContext(ctx).
+	countQuery := h.dbs.Read().WithContext(ctx).
 		Model(&models.Usage{})
 	if q.StartDate != "" {
-		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, time.Local); errParse == nil {
-			countQuery = countQuery.Where("requested_at >= ?", startTime)
+		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, internalsettings.BillingLocation()); errParse == nil {
+			countQuery = countQuery.Where("requested_at >= ?", startTime.UTC())
 		}
 	}
 	if q.EndDate != "" {
-		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, time.Local); errParse == nil {
-			countQuery = countQuery.Where("requested_at < ?", endTime.AddDate(0, 0, 1))
+		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, internalsettings.BillingLocation()); errParse == nil {
+			countQuery = countQuery.Where("requested_at < ?", endTime.AddDate(0, 0, 1).UTC())
 		}
 	}
 	if q.Project != "" {
@@ -193,7 +201,7 @@ func (h *AdminLogsHandler) List(c *gin.Context) {
 			OutputTokens: a.OutputTokens,
 			TotalTokens:  a.TotalTokens,
 			CostMicros:   a.CostMicros,
-			Cost:         fmt.Sprintf("$%.2f", float64(a.CostMicros)/1_000_000),
+			Cost:         billing.FormatMicros(a.CostMicros, 2),
 			FailedCount:  a.FailedCount,
 			Status:       status,
 			StatusText:   statusText,
@@ -208,7 +216,8 @@ func (h *AdminLogsHandler) List(c *gin.Context) {
 	})
 }
 
-// Detail returns per-request usage entries for a date and filters.
+// Detail returns per-request usage entries for a date and filters, or the entries of
+// one request when request_id is given.
 func (h *AdminLogsHandler) Detail(c *gin.Context) {
 	var q adminLogDetailQuery
 	if errBind := c.ShouldBindQuery(&q); errBind != nil {
@@ -217,35 +226,44 @@ func (h *AdminLogsHandler) Detail(c *gin.Context) {
 	}
 
 	dateKey := strings.TrimSpace(q.Date)
-	if dateKey == "" {
+	requestID := strings.TrimSpace(q.RequestID)
+	if dateKey == "" && requestID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing date"})
 		return
 	}
 
-	day, errParse := time.ParseInLocation("2006-01-02", dateKey, time.Local)
-	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date"})
-		return
-	}
-
-	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
-	end := start.AddDate(0, 0, 1)
-
 	ctx := c.Request.Context()
-	query := h.db.WithContext(ctx).
+	query := h.dbs.Read().WithContext(ctx).
 		Model(&models.Usage{}).
 		Select(`
 			requested_at,
+			request_id,
+			model,
+			COALESCE(requested_model, '') AS requested_model,
 			input_tokens,
 			output_tokens,
 			cached_tokens,
 			total_tokens,
 			cost_micros,
+			computed_cost_micros,
+			upstream_cost_micros,
 			failed,
 			COALESCE(users.username, '') AS username
 		`).
-		Joins("LEFT JOIN users ON users.id = usages.user_id").
-		Where("requested_at >= ? AND requested_at < ?", start, end)
+		Joins("LEFT JOIN users ON users.id = usages.user_id")
+
+	if dateKey != "" {
+		day, errParse := time.ParseInLocation("2006-01-02", dateKey, internalsettings.BillingLocation())
+		if errParse != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date"})
+			return
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+		query = query.Where("requested_at >= ? AND requested_at < ?", start.UTC(), start.AddDate(0, 0, 1).UTC())
+	}
+	if requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
 
 	if strings.TrimSpace(q.Model) != "" {
 		query = query.Where("model = ?", strings.TrimSpace(q.Model))
@@ -268,24 +286,47 @@ func (h *AdminLogsHandler) Detail(c *gin.Context) {
 	details := make([]gin.H, 0, len(rows))
 	for _, row := range rows {
 		details = append(details, gin.H{
-			"requested_at":  row.RequestedAt.In(time.Local).Format(time.RFC3339),
-			"username":      row.Username,
-			"input_tokens":  row.InputTokens,
-			"output_tokens": row.OutputTokens,
-			"cached_tokens": row.CachedTokens,
-			"total_tokens":  row.TotalTokens,
-			"cost":          fmt.Sprintf("$%.4f", float64(row.CostMicros)/1_000_000),
-			"success":       !row.Failed,
+			"requested_at":    row.RequestedAt.In(time.Local).Format(time.RFC3339),
+			"request_id":      row.RequestID,
+			"username":        row.Username,
+			"model":           row.Model,
+			"requested_model": requestedModelOf(row.RequestedModel, row.Model),
+			"input_tokens":    row.InputTokens,
+			"output_tokens":   row.OutputTokens,
+			"cached_tokens":   row.CachedTokens,
+			"total_tokens":    row.TotalTokens,
+			"cost":            billing.FormatMicros(row.CostMicros, 4),
+			"computed_cost":   billing.FormatMicros(row.ComputedCostMicros, 4),
+			"upstream_cost":   formatUpstreamCost(row.UpstreamCostMicros),
+			"success":         !row.Failed,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"details": details})
 }
 
+// requestedModelOf returns the model a client asked for. Rows written before the
+// requested model was recorded fall back to the canonical model.
+func requestedModelOf(requested, model string) string {
+	if requested == "" {
+		return model
+	}
+	return requested
+}
+
+// formatUpstreamCost formats a provider-reported cost, or returns nil when none was reported.
+func formatUpstreamCost(micros *int64) *string {
+	if micros == nil {
+		return nil
+	}
+	formatted := billing.FormatMicros(*micros, 4)
+	return &formatted
+}
+
 // Stats returns aggregated KPIs for today vs yesterday.
 func (h *AdminLogsHandler) Stats(c *gin.Context) {
 	ctx := c.Request.Context()
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 	yesterdayStart := todayStart.AddDate(0, 0, -1)
@@ -301,8 +342,8 @@ func (h *AdminLogsHandler) Stats(c *gin.Context) {
 
 	var todayStats, yesterdayStats statResult
 
-	h.db.WithContext(ctx).Model(&models.Usage{}).
-		Where("requested_at >= ?", todayStart).
+	h.dbs.Read().WithContext(ctx).Model(&models.Usage{}).
+		Where("requested_at >= ?", todayStart.UTC()).
 		Select(`
 			COUNT(*) AS requests,
 			COALESCE(SUM(total_tokens), 0) AS total_tokens,
@@ -311,8 +352,8 @@ func (h *AdminLogsHandler) Stats(c *gin.Context) {
 			COALESCE(AVG(GREATEST(EXTRACT(EPOCH FROM (created_at - requested_at)) * 1000, 0)), 0) AS avg_request_time_ms
 		`).Scan(&todayStats)
 
-	h.db.WithContext(ctx).Model(&models.Usage{}).
-		Where("requested_at >= ? AND requested_at < ?", yesterdayStart, todayStart).
+	h.dbs.Read().WithContext(ctx).Model(&models.Usage{}).
+		Where("requested_at >= ? AND requested_at < ?", yesterdayStart.UTC(), todayStart.UTC()).
 		Select(`
 			COUNT(*) AS requests,
 			COALESCE(SUM(total_tokens), 0) AS total_tokens,
@@ -349,7 +390,7 @@ func (h *AdminLogsHandler) Stats(c *gin.Context) {
 // Trend returns a seven-day trend of requests and tokens.
 func (h *AdminLogsHandler) Trend(c *gin.Context) {
 	ctx := c.Request.Context()
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 	sevenDaysAgo := todayStart.AddDate(0, 0, -6)
@@ -374,10 +415,10 @@ func (h *AdminLogsHandler) Trend(c *gin.Context) {
 	}
 
 	var rows []dailyTrend
-	if errFind := h.db.WithContext(ctx).
+	if errFind := h.dbs.Read().WithContext(ctx).
 		Model(&models.Usage{}).
 		Select(`TO_CHAR(requested_at, 'YYYY-MM-DD') AS date, COUNT(*) AS requests, COALESCE(SUM(total_tokens), 0) AS total_tokens`).
-		Where("requested_at >= ?", sevenDaysAgo).
+		Where("requested_at >= ?", sevenDaysAgo.UTC()).
 		Group("TO_CHAR(requested_at, 'YYYY-MM-DD')").
 		Order("TO_CHAR(requested_at, 'YYYY-MM-DD')").
 		Scan(&rows).Error; errFind != nil {
@@ -404,7 +445,7 @@ func (h *AdminLogsHandler) Trend(c *gin.Context) {
 // Models returns the distinct model names from usage logs.
 func (h *AdminLogsHandler) Models(c *gin.Context) {
 	var modelList []string
-	if errModels := h.db.WithContext(c.Request.Context()).Table("usages").
+	if errModels := h.dbs.Read().WithContext(c.Request.Context()).Table("usages").
 		Distinct("model").
 		Pluck("model", &modelList).Error; errModels != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query models failed"})
@@ -417,7 +458,7 @@ func (h *AdminLogsHandler) Models(c *gin.Context) {
 // Projects returns the distinct project/source names from usage logs.
 func (h *AdminLogsHandler) Projects(c *gin.Context) {
 	var projects []string
-	if errProjects := h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
+	if errProjects := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
 		Where("source != ''").
 		Distinct("source").
 		Pluck("source", &projects).Error; errProjects != nil {
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestAdminLogsDetailLooksUpRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:logs_detail_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.Usage{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	requestID := func(id string) *string { return &id }
+	rows := []models.Usage{
+		{Provider: "openai", Model: "gpt-a", RequestID: requestID("req-1"), RequestedAt: time.Now().AddDate(0, 0, -3), TotalTokens: 10},
+		{Provider: "openai", Model: "gpt-a", RequestID: requestID("req-2"), RequestedAt: time.Now(), TotalTokens: 20},
+	}
+	if errCreate := db.Create(&rows).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	h := NewAdminLogsHandler(dbutil.NewDBProvider(db, nil))
+	r := gin.New()
+	r.GET("/logs/detail", h.Detail)
+	get := func(target string) (int, []map[string]any) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var body struct {
+			Details []map[string]any `json:"details"`
+		}
+		if w.Code == http.StatusOK {
+			if errDecode := json.Unmarshal(w.Body.Bytes(), &body); errDecode != nil {
+				t.Fatalf("decode %s: %v", target, errDecode)
+			}
+		}
+		return w.Code, body.Details
+	}
+
+	code, details := get("/logs/detail?request_id=req-1")
+	if code != http.StatusOK || len(details) != 1 || details[0]["request_id"] != "req-1" {
+		t.Fatalf("expected the req-1 row without a date, got %d %v", code, details)
+	}
+	today := time.Now().Format("2006-01-02")
+	if code, details = get("/logs/detail?request_id=req-1&date=" + today); code != http.StatusOK || len(details) != 0 {
+		t.Fatalf("expected the date to narrow the lookup, got %d %v", code, details)
+	}
+	if code, details = get("/logs/detail?date=" + today); code != http.StatusOK || len(details) != 1 {
+		t.Fatalf("expected one row for today, got %d %v", code, details)
+	}
+	if code, _ = get("/logs/detail"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without date or request_id, got %d", code)
+	}
+}
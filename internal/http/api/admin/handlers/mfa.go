@@ -713,7 +713,7 @@ func (h *AuthHandler) LoginPasskeyVerify(c *gin.Context) {
 
 // respondWithAdminToken generates a JWT and responds with admin info.
 func (h *AuthHandler) respondWithAdminToken(c *gin.Context, admin models.Admin) {
-	token, errToken := security.GenerateAdminToken(h.jwtCfg.Secret, admin.ID, admin.Username, h.jwtCfg.Expiry)
+	token, errToken := issueAdminToken(c, h.db, h.jwtCfg, admin)
 	if errToken != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
@@ -731,7 +731,7 @@ func (h *AuthHandler) respondWithAdminToken(c *gin.Context, admin models.Admin)
 		"user_id":        admin.ID,
 		"username":       admin.Username,
 		"name":           "",
-		"email":          "",
+		"email":          admin.Email,
 		"permissions":    adminPermissions,
 		"is_super_admin": admin.IsSuperAdmin,
 	})
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelcache"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
+)
+
+// catalogRegistry is the subset of the SDK model registry used by the catalog cache.
+type catalogRegistry interface {
+	GetAvailableModelsByProvider(provider string) []*cliproxy.ModelInfo
+}
+
+// modelCatalog caches provider model lists read from the registry, so UIs polling the
+// available models endpoint do not walk the registry on every request. Entries expire
+// after MODEL_LIST_CACHE_TTL_SECONDS or as soon as the watcher sees provider keys or
+// mappings change.
+type modelCatalog struct {
+	registry catalogRegistry
+	auths    func() ([]*coreauth.Auth, bool)
+	cache    *modelcache.Cache[[]string]
+}
+
+// newModelCatalog constructs a catalog backed by the global registry and watcher.
+func newModelCatalog() *modelCatalog {
+	return &modelCatalog{
+		registry: cliproxy.GlobalModelRegistry(),
+		auths:    watcher.CurrentAuths,
+		cache:    modelcache.New[[]string](),
+	}
+}
+
+// Models returns the model IDs of provider and the age of the cached list.
+func (m *modelCatalog) Models(provider string) ([]string, time.Duration) {
+	models, age, _ := m.cache.Get(provider, func() ([]string, error) {
+		return m.fetch(provider), nil
+	})
+	return models, age
+}
+
+// All returns the model IDs of every provider with a loaded auth, keyed by provider,
+// and the age of the oldest cached list.
+func (m *modelCatalog) All() (map[string][]string, time.Duration) {
+	out := make(map[string][]string)
+	var oldest time.Duration
+	for _, provider := range m.providers() {
+		models, age := m.Models(provider)
+		out[provider] = models
+		if age > oldest {
+			oldest = age
+		}
+	}
+	return out, oldest
+}
+
+// fetch reads provider's models from the registry.
+func (m *modelCatalog) fetch(provider string) []string {
+	if m.registry == nil {
+		return []string{}
+	}
+	infos := m.registry.GetAvailableModelsByProvider(provider)
+	result := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if info != nil && info.ID != "" {
+			result = append(result, info.ID)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// providers lists the distinct providers of the watcher's current auths.
+func (m *modelCatalog) providers() []string {
+	if m.auths == nil {
+		return nil
+	}
+	auths, _ := m.auths()
+	seen := make(map[string]struct{})
+	out := make([]string, 0)
+	for _, a := range auths {
+		if a == nil {
+			continue
+		}
+		provider := strings.ToLower(strings.TrimSpace(a.Provider))
+		if provider == "" {
+			continue
+		}
+		if _, ok := seen[provider]; ok {
+			continue
+		}
+		seen[provider] = struct{}{}
+		out = append(out, provider)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelcache"
+)
+
+func TestModelCatalogCachesUntilTTLOrProviderChange(t *testing.T) {
+	registry := &fakeEffectiveRegistry{byProvider: map[string][]string{"claude": {"sonnet", "haiku"}, "codex": {"gpt-5"}}}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	changedAt := now.Add(-time.Hour)
+	const ttl = 15 * time.Second
+	catalog := &modelCatalog{
+		registry: registry,
+		auths: func() ([]*coreauth.Auth, bool) {
+			return []*coreauth.Auth{{ID: "a", Provider: "Claude"}, {ID: "b", Provider: "codex"}, {ID: "c", Provider: "claude"}}, true
+		},
+		cache: modelcache.NewWith[[]string](
+			func() time.Duration { return ttl },
+			func() time.Time { return changedAt },
+			func() time.Time { return now },
+		),
+	}
+
+	models, age := catalog.Models("claude")
+	if len(models) != 2 || models[0] != "haiku" || models[1] != "sonnet" || age != 0 {
+		t.Fatalf("unexpected first read: %v age=%s", models, age)
+	}
+
+	registry.byProvider["claude"] = []string{"opus"}
+	now = now.Add(5 * time.Second)
+	models, age = catalog.Models("claude")
+	if len(models) != 2 || age != 5*time.Second {
+		t.Fatalf("expected cached list aged 5s, got %v age=%s", models, age)
+	}
+
+	changedAt = now
+	models, age = catalog.Models("claude")
+	if len(models) != 1 || models[0] != "opus" || age != 0 {
+		t.Fatalf("expected refresh after provider change, got %v age=%s", models, age)
+	}
+
+	registry.byProvider["claude"] = []string{"opus", "sonnet"}
+	now = now.Add(ttl)
+	if models, _ = catalog.Models("claude"); len(models) != 2 {
+		t.Fatalf("expected refresh after ttl, got %v", models)
+	}
+
+	all, _ := catalog.All()
+	if len(all) != 2 || len(all["claude"]) != 2 || len(all["codex"]) != 1 {
+		t.Fatalf("unexpected full catalog: %v", all)
+	}
+}
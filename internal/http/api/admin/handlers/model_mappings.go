@@ -1,26 +1,31 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // ModelMappingHandler manages admin CRUD endpoints for model mappings.
 type ModelMappingHandler struct {
-	db *gorm.DB // Database handle for model mapping records.
+	db      *gorm.DB      // Database handle for model mapping records.
+	catalog *modelCatalog // Cached provider model lists.
 }
 
 // NewModelMappingHandler constructs a model mapping handler.
 func NewModelMappingHandler(db *gorm.DB) *ModelMappingHandler {
-	return &ModelMappingHandler{db: db}
+	return &ModelMappingHandler{db: db, catalog: newModelCatalog()}
 }
 
 // createModelMappingRequest captures the payload for creating a model mapping.
@@ -33,26 +38,32 @@ type createModelMappingRequest struct {
 	Fork         *bool               `json:"fork"`           // Optional fork flag.
 	Selector     *int                `json:"selector"`       // Optional routing selector.
 	RateLimit    *int                `json:"rate_limit"`     // Optional rate limit per second.
+
+	TimeoutSeconds  *int `json:"timeout_seconds"`   // Optional upstream timeout (0 = inherit).
+	MaxOutputTokens *int `json:"max_output_tokens"` // Optional output token limit (0 = inherit).
+
+	FallbackEnabled *bool          `json:"fallback_enabled"` // Optional cooldown fallback flag.
+	FallbackTargets datatypes.JSON `json:"fallback_targets"` // Optional ordered fallback targets.
 }
 
 // Create validates input and inserts a new model mapping.
 func (h *ModelMappingHandler) Create(c *gin.Context) {
 	var body createModelMappingRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	if strings.TrimSpace(body.Provider) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
+		apierror.Write(c, apierror.Validation("provider", "provider is required"))
 		return
 	}
 	if strings.TrimSpace(body.ModelName) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "model_name is required"})
+		apierror.Write(c, apierror.Validation("model_name", "model_name is required"))
 		return
 	}
 	if strings.TrimSpace(body.NewModelName) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "new_model_name is required"})
+		apierror.Write(c, apierror.Validation("new_model_name", "new_model_name is required"))
 		return
 	}
 
@@ -68,7 +79,7 @@ func (h *ModelMappingHandler) Create(c *gin.Context) {
 	if body.Selector != nil {
 		selector = *body.Selector
 		if selector < 0 || selector > 2 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "selector must be 0, 1, or 2"})
+			apierror.Write(c, apierror.Validation("selector", "selector must be 0, 1, or 2"))
 			return
 		}
 	}
@@ -76,6 +87,31 @@ func (h *ModelMappingHandler) Create(c *gin.Context) {
 	if body.RateLimit != nil {
 		rateLimit = *body.RateLimit
 	}
+	timeoutSeconds := 0
+	if body.TimeoutSeconds != nil {
+		timeoutSeconds = *body.TimeoutSeconds
+		if errTimeout := modelmapping.ValidateTimeoutSeconds(timeoutSeconds); errTimeout != nil {
+			apierror.Write(c, apierror.Validation("timeout_seconds", errTimeout.Error()))
+			return
+		}
+	}
+	maxOutputTokens := 0
+	if body.MaxOutputTokens != nil {
+		maxOutputTokens = *body.MaxOutputTokens
+		if errTokens := modelmapping.ValidateMaxOutputTokens(maxOutputTokens); errTokens != nil {
+			apierror.Write(c, apierror.Validation("max_output_tokens", errTokens.Error()))
+			return
+		}
+	}
+	fallbackEnabled := false
+	if body.FallbackEnabled != nil {
+		fallbackEnabled = *body.FallbackEnabled
+	}
+	fallbackTargets, errFallback := normalizeFallbackTargets(body.FallbackTargets)
+	if errFallback != nil {
+		apierror.Write(c, apierror.Validation("fallback_targets", errFallback.Error()))
+		return
+	}
 
 	now := time.Now().UTC()
 	mapping := models.ModelMapping{
@@ -89,10 +125,19 @@ func (h *ModelMappingHandler) Create(c *gin.Context) {
 		IsEnabled:    isEnabled,
 		CreatedAt:    now,
 		UpdatedAt:    now,
+
+		TimeoutSeconds:  timeoutSeconds,
+		MaxOutputTokens: maxOutputTokens,
+
+		FallbackEnabled: fallbackEnabled,
+		FallbackTargets: fallbackTargets,
 	}
 
+	if !h.checkLoop(c, mapping) {
+		return
+	}
 	if errCreate := h.db.WithContext(c.Request.Context()).Create(&mapping).Error; errCreate != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "create model mapping failed"})
+		apierror.Write(c, apierror.Internal("create model mapping failed"))
 		return
 	}
 	c.JSON(http.StatusCreated, h.formatMapping(&mapping))
@@ -123,7 +168,7 @@ func (h *ModelMappingHandler) List(c *gin.Context) {
 
 	var rows []models.ModelMapping
 	if errFind := q.Order("created_at DESC").Find(&rows).Error; errFind != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list model mappings failed"})
+		apierror.Write(c, apierror.Internal("list model mappings failed"))
 		return
 	}
 	out := make([]gin.H, 0, len(rows))
@@ -137,16 +182,16 @@ func (h *ModelMappingHandler) List(c *gin.Context) {
 func (h *ModelMappingHandler) Get(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	var mapping models.ModelMapping
 	if errFind := h.db.WithContext(c.Request.Context()).First(&mapping, id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			apierror.Write(c, apierror.NotFound("not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		apierror.Write(c, apierror.Internal("query failed"))
 		return
 	}
 	c.JSON(http.StatusOK, h.formatMapping(&mapping))
@@ -162,28 +207,34 @@ type updateModelMappingRequest struct {
 	Fork         *bool                `json:"fork"`           // Optional fork flag.
 	Selector     *int                 `json:"selector"`       // Optional routing selector.
 	RateLimit    *int                 `json:"rate_limit"`     // Optional rate limit per second.
+
+	TimeoutSeconds  *int `json:"timeout_seconds"`   // Optional upstream timeout (0 = inherit).
+	MaxOutputTokens *int `json:"max_output_tokens"` // Optional output token limit (0 = inherit).
+
+	FallbackEnabled *bool          `json:"fallback_enabled"` // Optional cooldown fallback flag.
+	FallbackTargets datatypes.JSON `json:"fallback_targets"` // Optional ordered fallback targets.
 }
 
 // Update validates and applies model mapping field updates.
 func (h *ModelMappingHandler) Update(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	var body updateModelMappingRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	var existing models.ModelMapping
 	if errFind := h.db.WithContext(c.Request.Context()).First(&existing, id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			apierror.Write(c, apierror.NotFound("not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		apierror.Write(c, apierror.Internal("query failed"))
 		return
 	}
 
@@ -194,7 +245,7 @@ func (h *ModelMappingHandler) Update(c *gin.Context) {
 	if body.Provider != nil {
 		p := strings.TrimSpace(*body.Provider)
 		if p == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "provider cannot be empty"})
+			apierror.Write(c, apierror.Validation("provider", "provider cannot be empty"))
 			return
 		}
 		updates["provider"] = p
@@ -202,7 +253,7 @@ func (h *ModelMappingHandler) Update(c *gin.Context) {
 	if body.ModelName != nil {
 		m := strings.TrimSpace(*body.ModelName)
 		if m == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "model_name cannot be empty"})
+			apierror.Write(c, apierror.Validation("model_name", "model_name cannot be empty"))
 			return
 		}
 		updates["model_name"] = m
@@ -210,7 +261,7 @@ func (h *ModelMappingHandler) Update(c *gin.Context) {
 	if body.NewModelName != nil {
 		n := strings.TrimSpace(*body.NewModelName)
 		if n == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "new_model_name cannot be empty"})
+			apierror.Write(c, apierror.Validation("new_model_name", "new_model_name cannot be empty"))
 			return
 		}
 		updates["new_model_name"] = n
@@ -224,7 +275,7 @@ func (h *ModelMappingHandler) Update(c *gin.Context) {
 	if body.Selector != nil {
 		selector := *body.Selector
 		if selector < 0 || selector > 2 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "selector must be 0, 1, or 2"})
+			apierror.Write(c, apierror.Validation("selector", "selector must be 0, 1, or 2"))
 			return
 		}
 		updates["selector"] = selector
@@ -232,13 +283,55 @@ func (h *ModelMappingHandler) Update(c *gin.Context) {
 	if body.RateLimit != nil {
 		updates["rate_limit"] = *body.RateLimit
 	}
+	if body.TimeoutSeconds != nil {
+		if errTimeout := modelmapping.ValidateTimeoutSeconds(*body.TimeoutSeconds); errTimeout != nil {
+			apierror.Write(c, apierror.Validation("timeout_seconds", errTimeout.Error()))
+			return
+		}
+		updates["timeout_seconds"] = *body.TimeoutSeconds
+	}
+	if body.MaxOutputTokens != nil {
+		if errTokens := modelmapping.ValidateMaxOutputTokens(*body.MaxOutputTokens); errTokens != nil {
+			apierror.Write(c, apierror.Validation("max_output_tokens", errTokens.Error()))
+			return
+		}
+		updates["max_output_tokens"] = *body.MaxOutputTokens
+	}
 	if body.UserGroupID != nil {
 		updates["user_group_id"] = body.UserGroupID.Clean()
 	}
+	if body.FallbackEnabled != nil {
+		updates["fallback_enabled"] = *body.FallbackEnabled
+	}
+	if body.FallbackTargets != nil {
+		fallbackTargets, errFallback := normalizeFallbackTargets(body.FallbackTargets)
+		if errFallback != nil {
+			apierror.Write(c, apierror.Validation("fallback_targets", errFallback.Error()))
+			return
+		}
+		updates["fallback_targets"] = fallbackTargets
+	}
+
+	candidate := existing
+	if v, ok := updates["provider"].(string); ok {
+		candidate.Provider = v
+	}
+	if v, ok := updates["model_name"].(string); ok {
+		candidate.ModelName = v
+	}
+	if v, ok := updates["new_model_name"].(string); ok {
+		candidate.NewModelName = v
+	}
+	if v, ok := updates["is_enabled"].(bool); ok {
+		candidate.IsEnabled = v
+	}
+	if !h.checkLoop(c, candidate) {
+		return
+	}
 
 	res := h.db.WithContext(c.Request.Context()).Model(&models.ModelMapping{}).Where("id = ?", id).Updates(updates)
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -248,16 +341,16 @@ func (h *ModelMappingHandler) Update(c *gin.Context) {
 func (h *ModelMappingHandler) Delete(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	res := h.db.WithContext(c.Request.Context()).Delete(&models.ModelMapping{}, id)
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		apierror.Write(c, apierror.Internal("delete failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -277,24 +370,78 @@ func (h *ModelMappingHandler) Disable(c *gin.Context) {
 func (h *ModelMappingHandler) setEnabled(c *gin.Context, enabled bool) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
+	if enabled {
+		var existing models.ModelMapping
+		if errFind := h.db.WithContext(c.Request.Context()).First(&existing, id).Error; errFind != nil {
+			if errors.Is(errFind, gorm.ErrRecordNotFound) {
+				apierror.Write(c, apierror.NotFound("not found"))
+				return
+			}
+			apierror.Write(c, apierror.Internal("query failed"))
+			return
+		}
+		existing.IsEnabled = true
+		if !h.checkLoop(c, existing) {
+			return
+		}
+	}
 
 	now := time.Now().UTC()
 	res := h.db.WithContext(c.Request.Context()).Model(&models.ModelMapping{}).Where("id = ?", id).
 		Updates(map[string]any{"is_enabled": enabled, "updated_at": now})
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// checkLoop rejects candidate when its alias would lead back to its own model name
+// through the provider's other enabled mappings. It writes the error response and
+// returns false when the mapping must not be saved.
+func (h *ModelMappingHandler) checkLoop(c *gin.Context, candidate models.ModelMapping) bool {
+	if !candidate.IsEnabled {
+		return true
+	}
+	var rows []models.ModelMapping
+	if errFind := h.db.WithContext(c.Request.Context()).
+		Select("id", "provider", "model_name", "new_model_name", "is_enabled").
+		Where("is_enabled = ? AND LOWER(provider) = ?", true, strings.ToLower(strings.TrimSpace(candidate.Provider))).
+		Find(&rows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("query failed"))
+		return false
+	}
+	if loop := modelmapping.FindMappingLoop(rows, candidate); loop != nil {
+		apierror.Write(c, apierror.Validation("new_model_name", "mapping would create an alias loop: "+strings.Join(loop, " -> ")).With("loop", loop))
+		return false
+	}
+	return true
+}
+
+// Validate reports enabled mappings whose aliases loop back to their own model names.
+func (h *ModelMappingHandler) Validate(c *gin.Context) {
+	var rows []models.ModelMapping
+	if errFind := h.db.WithContext(c.Request.Context()).
+		Select("id", "provider", "model_name", "new_model_name", "is_enabled").
+		Where("is_enabled = ?", true).
+		Find(&rows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("list model mappings failed"))
+		return
+	}
+	loops := modelmapping.FindMappingLoops(rows)
+	if loops == nil {
+		loops = []modelmapping.MappingLoop{}
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": len(loops) == 0, "loops": loops})
+}
+
 // formatMapping converts a model mapping into a response payload.
 func (h *ModelMappingHandler) formatMapping(m *models.ModelMapping) gin.H {
 	return gin.H{
@@ -309,19 +456,55 @@ func (h *ModelMappingHandler) formatMapping(m *models.ModelMapping) gin.H {
 		"is_enabled":     m.IsEnabled,
 		"created_at":     m.CreatedAt,
 		"updated_at":     m.UpdatedAt,
+
+		"timeout_seconds":   m.TimeoutSeconds,
+		"max_output_tokens": m.MaxOutputTokens,
+
+		"fallback_enabled": m.FallbackEnabled,
+		"fallback_targets": formatFallbackTargets(m.FallbackTargets),
+	}
+}
+
+// normalizeFallbackTargets validates fallback targets and returns their canonical JSON.
+func normalizeFallbackTargets(raw datatypes.JSON) (datatypes.JSON, error) {
+	targets, errParse := modelmapping.ParseFallbackTargets(raw)
+	if errParse != nil {
+		return nil, fmt.Errorf("invalid fallback_targets: %w", errParse)
+	}
+	if targets == nil {
+		targets = []modelmapping.FallbackTarget{}
+	}
+	data, errMarshal := json.Marshal(targets)
+	if errMarshal != nil {
+		return nil, fmt.Errorf("invalid fallback_targets: %w", errMarshal)
+	}
+	return datatypes.JSON(data), nil
+}
+
+// formatFallbackTargets decodes stored fallback targets for responses.
+func formatFallbackTargets(raw datatypes.JSON) []modelmapping.FallbackTarget {
+	targets, errParse := modelmapping.ParseFallbackTargets(raw)
+	if errParse != nil || targets == nil {
+		return []modelmapping.FallbackTarget{}
 	}
+	return targets
 }
 
-// AvailableModels lists mapped or provider-supported models based on query.
+// AvailableModels lists mapped or provider-supported models based on query. With all=1
+// it returns the provider-supported models of every provider in one response.
 func (h *ModelMappingHandler) AvailableModels(c *gin.Context) {
+	if isTruthyQuery(c.Query("all")) {
+		catalog, age := h.catalog.All()
+		c.JSON(http.StatusOK, gin.H{"providers": catalog, "cache_age_ms": age.Milliseconds()})
+		return
+	}
 	provider := strings.ToLower(strings.TrimSpace(c.Query("provider")))
 	if provider == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
+		apierror.Write(c, apierror.Validation("provider", "provider is required"))
 		return
 	}
 
-	mappedQ := strings.TrimSpace(c.Query("mapped"))
-	if mappedQ == "1" || strings.EqualFold(mappedQ, "true") {
+	if isTruthyQuery(c.Query("mapped")) {
 		var result []string
 		if errFind := h.db.WithContext(c.Request.Context()).
 			Model(&models.ModelMapping{}).
@@ -330,18 +513,18 @@ func (h *ModelMappingHandler) AvailableModels(c *gin.Context) {
 			Where("new_model_name <> ''").
 			Order("new_model_name ASC").
 			Pluck("new_model_name", &result).Error; errFind != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "list mapped models failed"})
+			apierror.Write(c, apierror.Internal("list mapped models failed"))
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"models": result})
 		return
 	}
-	infos := cliproxy.GlobalModelRegistry().GetAvailableModelsByProvider(provider)
-	result := make([]string, 0, len(infos))
-	for _, info := range infos {
-		if info != nil && info.ID != "" {
-			result = append(result, info.ID)
-		}
-	}
-	c.JSON(http.StatusOK, gin.H{"models": result})
+	result, age := h.catalog.Models(provider)
+	c.JSON(http.StatusOK, gin.H{"models": result, "cache_age_ms": age.Milliseconds()})
+}
+
+// isTruthyQuery reports whether a query flag is set to 1 or true.
+func isTruthyQuery(value string) bool {
+	value = strings.TrimSpace(value)
+	return value == "1" || strings.EqualFold(value, "true")
 }
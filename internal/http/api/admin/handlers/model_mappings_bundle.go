@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/payloadrule"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// modelMappingBundleVersion identifies the export document format.
+const modelMappingBundleVersion = 1
+
+// modelMappingBundle is the portable export/import document for model mappings.
+type modelMappingBundle struct {
+	Version    int                       `json:"version"`               // Document format version.
+	ExportedAt *time.Time                `json:"exported_at,omitempty"` // Export timestamp.
+	Mappings   []modelMappingBundleEntry `json:"mappings"`              // Exported mappings.
+}
+
+// modelMappingBundleEntry is one mapping in a bundle, without database IDs.
+type modelMappingBundleEntry struct {
+	Provider        string              `json:"provider"`          // Provider identifier.
+	ModelName       string              `json:"model_name"`        // Source model name.
+	NewModelName    string              `json:"new_model_name"`    // Exposed model name.
+	Fork            bool                `json:"fork"`              // Fork flag.
+	Selector        int                 `json:"selector"`          // Routing selector.
+	RateLimit       int                 `json:"rate_limit"`        // Rate limit per second.
+	TimeoutSeconds  int                 `json:"timeout_seconds"`   // Upstream timeout (0 = inherit).
+	MaxOutputTokens int                 `json:"max_output_tokens"` // Output token limit (0 = inherit).
+	UserGroupID     models.UserGroupIDs `json:"user_group_id"`     // Allowed user group IDs.
+	IsEnabled       *bool               `json:"is_enabled"`        // Active flag; defaults to true.
+	FallbackEnabled bool                `json:"fallback_enabled"`  // Cooldown fallback flag.
+	FallbackTargets datatypes.JSON      `json:"fallback_targets"`  // Ordered fallback targets.
+
+	PayloadRule *modelPayloadRuleBundleEntry `json:"payload_rule,omitempty"` // Optional payload rule.
+}
+
+// modelPayloadRuleBundleEntry is a payload rule in a bundle, without database IDs.
+type modelPayloadRuleBundleEntry struct {
+	Protocol    string          `json:"protocol"`    // Protocol name.
+	Params      json.RawMessage `json:"params"`      // Injection parameters.
+	IsEnabled   *bool           `json:"is_enabled"`  // Active flag; defaults to true.
+	Description string          `json:"description"` // Human-readable description.
+}
+
+// bundleImportCounts reports the outcome of an import for one record type.
+type bundleImportCounts struct {
+	Created int `json:"created"` // Newly inserted records.
+	Updated int `json:"updated"` // Existing records that changed.
+	Skipped int `json:"skipped"` // Existing records left unchanged.
+}
+
+// Export returns every model mapping and its payload rule as a portable bundle.
+func (h *ModelMappingHandler) Export(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var mappings []models.ModelMapping
+	if errFind := h.db.WithContext(ctx).Order("id ASC").Find(&mappings).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list model mappings failed"})
+		return
+	}
+	var rules []models.ModelPayloadRule
+	if errFind := h.db.WithContext(ctx).Order("id ASC").Find(&rules).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list payload rules failed"})
+		return
+	}
+	rulesByMapping := make(map[uint64]*models.ModelPayloadRule, len(rules))
+	for i := range rules {
+		rulesByMapping[rules[i].ModelMappingID] = &rules[i]
+	}
+
+	now := time.Now().UTC()
+	bundle := modelMappingBundle{
+		Version:    modelMappingBundleVersion,
+		ExportedAt: &now,
+		Mappings:   make([]modelMappingBundleEntry, 0, len(mappings)),
+	}
+	for i := range mappings {
+		m := &mappings[i]
+		isEnabled := m.IsEnabled
+		entry := modelMappingBundleEntry{
+			Provider:        m.Provider,
+			ModelName:       m.ModelName,
+			NewModelName:    m.NewModelName,
+			Fork:            m.Fork,
+			Selector:        m.Selector,
+			RateLimit:       m.RateLimit,
+			TimeoutSeconds:  m.TimeoutSeconds,
+			MaxOutputTokens: m.MaxOutputTokens,
+			UserGroupID:     m.UserGroupID.Clean(),
+			IsEnabled:       &isEnabled,
+			FallbackEnabled: m.FallbackEnabled,
+			FallbackTargets: m.FallbackTargets,
+		}
+		if rule, ok := rulesByMapping[m.ID]; ok {
+			ruleEnabled := rule.IsEnabled
+			entry.PayloadRule = &modelPayloadRuleBundleEntry{
+				Protocol:    rule.Protocol,
+				Params:      json.RawMessage(rule.Params),
+				IsEnabled:   &ruleEnabled,
+				Description: rule.Description,
+			}
+		}
+		bundle.Mappings = append(bundle.Mappings, entry)
+	}
+	c.JSON(http.StatusOK, bundle)
+}
+
+// Import upserts model mappings keyed by (provider, model_name, new_model_name) and
+// their payload rules keyed by mapping, all in one transaction.
+func (h *ModelMappingHandler) Import(c *gin.Context) {
+	var body modelMappingBundle
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+	if body.Version != 0 && body.Version != modelMappingBundleVersion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported bundle version %d", body.Version)})
+		return
+	}
+
+	entries := make([]normalizedBundleEntry, 0, len(body.Mappings))
+	for i := range body.Mappings {
+		entry, errEntry := normalizeBundleEntry(&body.Mappings[i])
+		if errEntry != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("mappings[%d]: %s", i, errEntry.Error())})
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	var mappingCounts, ruleCounts bundleImportCounts
+	now := time.Now().UTC()
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		for i := range entries {
+			mappingID, errMapping := importBundleMapping(tx, &entries[i].mapping, now, &mappingCounts)
+			if errMapping != nil {
+				return errMapping
+			}
+			if entries[i].rule == nil {
+				continue
+			}
+			entries[i].rule.ModelMappingID = mappingID
+			if errRule := importBundlePayloadRule(tx, entries[i].rule, now, &ruleCounts); errRule != nil {
+				return errRule
+			}
+		}
+		return nil
+	})
+	if errTx != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "import model mappings failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mappings":      mappingCounts,
+		"payload_rules": ruleCounts,
+	})
+}
+
+// normalizedBundleEntry holds a validated bundle entry ready to persist.
+type normalizedBundleEntry struct {
+	mapping models.ModelMapping      // Mapping fields to upsert.
+	rule    *models.ModelPayloadRule // Optional payload rule fields to upsert.
+}
+
+// normalizeBundleEntry validates an imported entry using the same rules as Create.
+func normalizeBundleEntry(in *modelMappingBundleEntry) (normalizedBundleEntry, error) {
+	var out normalizedBundleEntry
+	provider := strings.TrimSpace(in.Provider)
+	modelName := strings.TrimSpace(in.ModelName)
+	newModelName := strings.TrimSpace(in.NewModelName)
+	if provider == "" {
+		return out, errors.New("provider is required")
+	}
+	if modelName == "" {
+		return out, errors.New("model_name is required")
+	}
+	if newModelName == "" {
+		return out, errors.New("new_model_name is required")
+	}
+	if in.Selector < 0 || in.Selector > 2 {
+		return out, errors.New("selector must be 0, 1, or 2")
+	}
+	if errTimeout := modelmapping.ValidateTimeoutSeconds(in.TimeoutSeconds); errTimeout != nil {
+		return out, errTimeout
+	}
+	if errTokens := modelmapping.ValidateMaxOutputTokens(in.MaxOutputTokens); errTokens != nil {
+		return out, errTokens
+	}
+	fallbackTargets, errFallback := normalizeFallbackTargets(in.FallbackTargets)
+	if errFallback != nil {
+		return out, errFallback
+	}
+	isEnabled := true
+	if in.IsEnabled != nil {
+		isEnabled = *in.IsEnabled
+	}
+	out.mapping = models.ModelMapping{
+		Provider:        provider,
+		ModelName:       modelName,
+		NewModelName:    newModelName,
+		Fork:            in.Fork,
+		Selector:        in.Selector,
+		RateLimit:       in.RateLimit,
+		TimeoutSeconds:  in.TimeoutSeconds,
+		MaxOutputTokens: in.MaxOutputTokens,
+		UserGroupID:     in.UserGroupID.Clean(),
+		IsEnabled:       isEnabled,
+		FallbackEnabled: in.FallbackEnabled,
+		FallbackTargets: fallbackTargets,
+	}
+
+	if in.PayloadRule == nil {
+		return out, nil
+	}
+	protocol := protocolFromProvider(provider)
+	if protocol == "" {
+		protocol = strings.TrimSpace(in.PayloadRule.Protocol)
+	}
+	if fieldErrs := payloadrule.Validate(protocol, in.PayloadRule.Params); len(fieldErrs) > 0 {
+		return out, fmt.Errorf("payload_rule: %w", fieldErrs[0])
+	}
+	params, errParams := normalizePayloadParams(in.PayloadRule.Params)
+	if errParams != nil {
+		return out, fmt.Errorf("payload_rule: %w", errParams)
+	}
+	ruleEnabled := true
+	if in.PayloadRule.IsEnabled != nil {
+		ruleEnabled = *in.PayloadRule.IsEnabled
+	}
+	out.rule = &models.ModelPayloadRule{
+		Protocol:    protocol,
+		Params:      params,
+		IsEnabled:   ruleEnabled,
+		Description: strings.TrimSpace(in.PayloadRule.Description),
+	}
+	return out, nil
+}
+
+// importBundleMapping creates or updates one mapping and returns its ID. Writes set
+// updated_at to now so the watcher picks the change up on its next poll.
+func importBundleMapping(tx *gorm.DB, in *models.ModelMapping, now time.Time, counts *bundleImportCounts) (uint64, error) {
+	var existing models.ModelMapping
+	errFind := tx.
+		Where("provider = ? AND model_name = ? AND new_model_name = ?", in.Provider, in.ModelName, in.NewModelName).
+		Order("id ASC").
+		First(&existing).Error
+	switch {
+	case errors.Is(errFind, gorm.ErrRecordNotFound):
+		in.CreatedAt = now
+		in.UpdatedAt = now
+		isEnabled := in.IsEnabled
+		if errCreate := tx.Create(in).Error; errCreate != nil {
+			return 0, errCreate
+		}
+		// Create replaces false with the column's true default, so persist it explicitly.
+		if !isEnabled {
+			if errUpdate := tx.Model(in).Update("is_enabled", false).Error; errUpdate != nil {
+				return 0, errUpdate
+			}
+		}
+		counts.Created++
+		return in.ID, nil
+	case errFind != nil:
+		return 0, errFind
+	}
+
+	if sameBundleMapping(&existing, in) {
+		counts.Skipped++
+		return existing.ID, nil
+	}
+	if errUpdate := tx.Model(&existing).Updates(map[string]any{
+		"fork":              in.Fork,
+		"selector":          in.Selector,
+		"rate_limit":        in.RateLimit,
+		"timeout_seconds":   in.TimeoutSeconds,
+		"max_output_tokens": in.MaxOutputTokens,
+		"user_group_id":     in.UserGroupID,
+		"is_enabled":        in.IsEnabled,
+		"fallback_enabled":  in.FallbackEnabled,
+		"fallback_targets":  in.FallbackTargets,
+		"updated_at":        now,
+	}).Error; errUpdate != nil {
+		return 0, errUpdate
+	}
+	counts.Updated++
+	return existing.ID, nil
+}
+
+// importBundlePayloadRule creates or updates the payload rule for in.ModelMappingID.
+func importBundlePayloadRule(tx *gorm.DB, in *models.ModelPayloadRule, now time.Time, counts *bundleImportCounts) error {
+	var existing models.ModelPayloadRule
+	errFind := tx.Where("model_mapping_id = ?", in.ModelMappingID).First(&existing).Error
+	switch {
+	case errors.Is(errFind, gorm.ErrRecordNotFound):
+		in.CreatedAt = now
+		in.UpdatedAt = now
+		isEnabled := in.IsEnabled
+		if errCreate := tx.Create(in).Error; errCreate != nil {
+			return errCreate
+		}
+		if !isEnabled {
+			if errUpdate := tx.Model(in).Update("is_enabled", false).Error; errUpdate != nil {
+				return errUpdate
+			}
+		}
+		counts.Created++
+		return nil
+	case errFind != nil:
+		return errFind
+	}
+
+	if existing.Protocol == in.Protocol &&
+		existing.IsEnabled == in.IsEnabled &&
+		existing.Description == in.Description &&
+		sameJSON(existing.Params, in.Params) {
+		counts.Skipped++
+		return nil
+	}
+	if errUpdate := tx.Model(&existing).Updates(map[string]any{
+		"protocol":    in.Protocol,
+		"params":      in.Params,
+		"is_enabled":  in.IsEnabled,
+		"description": in.Description,
+		"updated_at":  now,
+	}).Error; errUpdate != nil {
+		return errUpdate
+	}
+	counts.Updated++
+	return nil
+}
+
+// sameBundleMapping reports whether an imported mapping matches the stored one.
+func sameBundleMapping(existing, in *models.ModelMapping) bool {
+	if existing.Fork != in.Fork ||
+		existing.Selector != in.Selector ||
+		existing.RateLimit != in.RateLimit ||
+		existing.TimeoutSeconds != in.TimeoutSeconds ||
+		existing.MaxOutputTokens != in.MaxOutputTokens ||
+		existing.IsEnabled != in.IsEnabled ||
+		existing.FallbackEnabled != in.FallbackEnabled {
+		return false
+	}
+	existingGroups, errExisting := existing.UserGroupID.Value()
+	inGroups, errIn := in.UserGroupID.Value()
+	if errExisting != nil || errIn != nil || !bytes.Equal(existingGroups.([]byte), inGroups.([]byte)) {
+		return false
+	}
+	return sameJSON(existing.FallbackTargets, in.FallbackTargets)
+}
+
+// sameJSON compares two JSON documents after compacting whitespace; empty equals null.
+func sameJSON(a, b datatypes.JSON) bool {
+	return bytes.Equal(compactJSON(a), compactJSON(b))
+}
+
+// compactJSON strips insignificant whitespace, mapping empty input to "null".
+func compactJSON(raw datatypes.JSON) []byte {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return []byte("null")
+	}
+	var buf bytes.Buffer
+	if errCompact := json.Compact(&buf, trimmed); errCompact != nil {
+		return trimmed
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestModelMappingBundleImportExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:mapping_bundle_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.ModelMapping{}, &models.ModelPayloadRule{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	handler := NewModelMappingHandler(db)
+
+	importBundle := func(body string) (int, map[string]bundleImportCounts) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		handler.Import(c)
+		var resp map[string]bundleImportCounts
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	bundle := `{"version":1,"mappings":[
+		{"provider":"openai","model_name":"gpt-4","new_model_name":"smart","is_enabled":false,
+		 "payload_rule":{"params":[{"path":"temperature","rule_type":"default","value":0}],"description":"pin temp"}},
+		{"provider":"claude","model_name":"sonnet","new_model_name":"writer"}
+	]}`
+	code, counts := importBundle(bundle)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if counts["mappings"].Created != 2 || counts["payload_rules"].Created != 1 {
+		t.Fatalf("unexpected first import counts: %+v", counts)
+	}
+
+	code, counts = importBundle(bundle)
+	if code != http.StatusOK || counts["mappings"].Skipped != 2 || counts["payload_rules"].Skipped != 1 {
+		t.Fatalf("expected re-import to skip everything, got %d %+v", code, counts)
+	}
+
+	code, counts = importBundle(`{"mappings":[{"provider":"openai","model_name":"gpt-4","new_model_name":"smart","rate_limit":5,
+		"payload_rule":{"params":[],"description":"pin temp"}}]}`)
+	if code != http.StatusOK || counts["mappings"].Updated != 1 || counts["payload_rules"].Updated != 1 {
+		t.Fatalf("expected update, got %d %+v", code, counts)
+	}
+
+	if code, _ = importBundle(`{"mappings":[{"provider":"openai","model_name":"","new_model_name":"x"}]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected invalid entry to be rejected, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.Export(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected export 200, got %d", w.Code)
+	}
+	var exported modelMappingBundle
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &exported); errDecode != nil {
+		t.Fatalf("decode export: %v", errDecode)
+	}
+	if len(exported.Mappings) != 2 {
+		t.Fatalf("expected 2 exported mappings, got %d", len(exported.Mappings))
+	}
+	first := exported.Mappings[0]
+	if first.RateLimit != 5 || first.IsEnabled == nil || !*first.IsEnabled || first.PayloadRule == nil || first.PayloadRule.Protocol != "openai" {
+		t.Fatalf("unexpected exported mapping: %+v", first)
+	}
+	if exported.Mappings[1].PayloadRule != nil {
+		t.Fatalf("expected no payload rule for second mapping")
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestModelMappingRejectsAliasLoops(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:mapping_loops_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.ModelMapping{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	h := NewModelMappingHandler(db)
+	r := gin.New()
+	r.POST("/model-mappings", h.Create)
+	r.GET("/model-mappings/validate", h.Validate)
+	r.PUT("/model-mappings/:id", h.Update)
+	r.POST("/model-mappings/:id/enable", h.Enable)
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"provider":"claude","model_name":"a","new_model_name":"b"}`,
+		`{"provider":"claude","model_name":"b","new_model_name":"c"}`,
+		`{"provider":"claude","model_name":"c","new_model_name":"c"}`,
+	} {
+		if w := send(http.MethodPost, "/model-mappings", body); w.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected 201, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+	w := send(http.MethodPost, "/model-mappings", `{"provider":"Claude","model_name":"c","new_model_name":"a"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected loop to be rejected, got %d", w.Code)
+	}
+	var rejected struct {
+		Loop []string `json:"loop"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &rejected)
+	if len(rejected.Loop) != 4 {
+		t.Fatalf("expected the loop path in the response, got %s", w.Body.String())
+	}
+	if w := send(http.MethodPut, "/model-mappings/2", `{"new_model_name":"a"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected update closing a loop to be rejected, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/model-mappings", `{"provider":"claude","model_name":"c","new_model_name":"a","is_enabled":false}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected disabled mapping to be accepted, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/model-mappings/4/enable", ``); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected enabling a looping mapping to be rejected, got %d", w.Code)
+	}
+
+	// Rows written around the handler still show up in the report.
+	if errUpdate := db.Model(&models.ModelMapping{}).Where("id = ?", 4).Update("is_enabled", true).Error; errUpdate != nil {
+		t.Fatalf("enable mapping: %v", errUpdate)
+	}
+	w = send(http.MethodGet, "/model-mappings/validate", ``)
+	var report struct {
+		OK    bool `json:"ok"`
+		Loops []struct {
+			Provider   string   `json:"provider"`
+			MappingIDs []uint64 `json:"mapping_ids"`
+		} `json:"loops"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &report); errDecode != nil {
+		t.Fatalf("decode report: %v", errDecode)
+	}
+	if report.OK || len(report.Loops) != 1 || len(report.Loops[0].MappingIDs) != 3 {
+		t.Fatalf("unexpected report: %s", w.Body.String())
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/payloadrule"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -93,6 +94,14 @@ func (h *ModelPayloadRuleHandler) Create(c *gin.Context) {
 		return
 	}
 
+	protocol := protocolFromProvider(provider)
+	if protocol == "" {
+		protocol = strings.TrimSpace(body.Protocol)
+	}
+	if fieldErrs := payloadrule.Validate(protocol, body.Params); len(fieldErrs) > 0 {
+		writePayloadRuleFieldErrors(c, fieldErrs)
+		return
+	}
 	params, errParams := normalizePayloadParams(body.Params)
 	if errParams != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": errParams.Error()})
@@ -103,10 +112,6 @@ func (h *ModelPayloadRuleHandler) Create(c *gin.Context) {
 	if body.IsEnabled != nil {
 		isEnabled = *body.IsEnabled
 	}
-	protocol := protocolFromProvider(provider)
-	if protocol == "" {
-		protocol = strings.TrimSpace(body.Protocol)
-	}
 	description := ""
 	if body.Description != nil {
 		description = strings.TrimSpace(*body.Description)
@@ -171,7 +176,18 @@ func (h *ModelPayloadRuleHandler) Update(c *gin.Context) {
 	if protocol != "" {
 		updates["protocol"] = protocol
 	} else if body.Protocol != nil {
-		updates["protocol"] = strings.TrimSpace(*body.Protocol)
+		protocol = strings.TrimSpace(*body.Protocol)
+		updates["protocol"] = protocol
+	} else {
+		protocol = existing.Protocol
+	}
+	var rawParams json.RawMessage
+	if body.Params != nil {
+		rawParams = *body.Params
+	}
+	if fieldErrs := payloadrule.Validate(protocol, rawParams); len(fieldErrs) > 0 {
+		writePayloadRuleFieldErrors(c, fieldErrs)
+		return
 	}
 	if body.Params != nil {
 		params, errParams := normalizePayloadParams(*body.Params)
@@ -229,6 +245,75 @@ func (h *ModelPayloadRuleHandler) Delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// previewPayloadRuleRequest carries the sample payload for a preview.
+type previewPayloadRuleRequest struct {
+	Payload json.RawMessage `json:"payload"` // Sample request body to transform.
+}
+
+// Preview applies a payload rule to a sample payload and returns the transformed JSON.
+// The sample is read from the "payload" query parameter or the request body, and the
+// rule is applied even when disabled so it can be checked before enabling.
+func (h *ModelPayloadRuleHandler) Preview(c *gin.Context) {
+	mappingID, errParse := parseUintParam(c.Param("id"))
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model mapping id"})
+		return
+	}
+	ruleID, errParse := parseUintParam(c.Param("rule_id"))
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	sample := []byte(strings.TrimSpace(c.Query("payload")))
+	if len(sample) == 0 && c.Request.Body != nil && c.Request.ContentLength != 0 {
+		var body previewPayloadRuleRequest
+		if errBind := c.ShouldBindJSON(&body); errBind != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+			return
+		}
+		sample = bytes.TrimSpace(body.Payload)
+	}
+	if len(sample) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload is required"})
+		return
+	}
+
+	var rule models.ModelPayloadRule
+	if errFind := h.db.WithContext(c.Request.Context()).
+		Where("id = ? AND model_mapping_id = ?", ruleID, mappingID).
+		First(&rule).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+
+	entries, errEntries := payloadrule.ParseEntries(rule.Params)
+	if errEntries != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "stored params are invalid"})
+		return
+	}
+	out, changes, errApply := payloadrule.Apply(entries, sample)
+	if errApply != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errApply.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"protocol":   rule.Protocol,
+		"is_enabled": rule.IsEnabled,
+		"payload":    json.RawMessage(out),
+		"changes":    changes,
+	})
+}
+
+// writePayloadRuleFieldErrors responds with field-level payload rule validation errors.
+func writePayloadRuleFieldErrors(c *gin.Context, fieldErrs []payloadrule.FieldError) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload rule", "fields": fieldErrs})
+}
+
 // loadModelMappingProvider loads the provider name for a mapping.
 func (h *ModelPayloadRuleHandler) loadModelMappingProvider(c *gin.Context, mappingID uint64) (string, error) {
 	if h == nil || h.db == nil {
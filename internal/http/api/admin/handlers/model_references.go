@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -20,62 +22,159 @@ func NewModelReferenceHandler(db *gorm.DB) *ModelReferenceHandler {
 	return &ModelReferenceHandler{db: db}
 }
 
-// GetPrice returns model reference pricing for a model (and optional provider).
+// maxPriceLookupModels bounds the models resolved by one bulk price lookup.
+const maxPriceLookupModels = 200
+
+// GetPrice returns model reference pricing for a model (and optional provider). With
+// models=a,b,c it returns a map of the requested models to their prices instead.
+// Models without an exact reference fall back to the closest shorter model name, so
+// dated releases such as gpt-4o-2024-05-13 resolve to gpt-4o.
 func (h *ModelReferenceHandler) GetPrice(c *gin.Context) {
+	provider := strings.TrimSpace(c.Query("provider"))
+	if modelsQ := strings.TrimSpace(c.Query("models")); modelsQ != "" {
+		h.getPrices(c, provider, modelsQ)
+		return
+	}
 	modelID := strings.TrimSpace(c.Query("model_id"))
 	if modelID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "model_id is required"})
 		return
 	}
-	provider := strings.TrimSpace(c.Query("provider"))
 
+	ref, errLookup := h.lookupPrice(c.Request.Context(), provider, modelID)
+	if errLookup != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query model reference failed"})
+		return
+	}
+	if ref == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, formatModelReferencePrice(ref))
+}
+
+// getPrices resolves a comma-separated model list and lists models without a price.
+func (h *ModelReferenceHandler) getPrices(c *gin.Context, provider, modelsQ string) {
+	requested := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, part := range strings.Split(modelsQ, ",") {
+		modelID := strings.TrimSpace(part)
+		if modelID == "" {
+			continue
+		}
+		if _, ok := seen[modelID]; ok {
+			continue
+		}
+		seen[modelID] = struct{}{}
+		requested = append(requested, modelID)
+	}
+	if len(requested) > maxPriceLookupModels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d models per request", maxPriceLookupModels)})
+		return
+	}
+
+	prices := make(map[string]gin.H, len(requested))
+	missing := make([]string, 0)
+	for _, modelID := range requested {
+		ref, errLookup := h.lookupPrice(c.Request.Context(), provider, modelID)
+		if errLookup != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "query model reference failed"})
+			return
+		}
+		if ref == nil {
+			missing = append(missing, modelID)
+			continue
+		}
+		prices[modelID] = formatModelReferencePrice(ref)
+	}
+	c.JSON(http.StatusOK, gin.H{"prices": prices, "missing": missing})
+}
+
+// lookupPrice finds the reference for modelID, trying the exact model first and then
+// the fuzzy candidates. It returns nil when nothing matches.
+func (h *ModelReferenceHandler) lookupPrice(ctx context.Context, provider, modelID string) (*models.ModelReference, error) {
+	ref, errExact := h.findReference(ctx, provider, modelID, true)
+	if errExact != nil || ref != nil {
+		return ref, errExact
+	}
+	for _, candidate := range fuzzyModelCandidates(modelID) {
+		ref, errFuzzy := h.findReference(ctx, provider, candidate, false)
+		if errFuzzy != nil || ref != nil {
+			return ref, errFuzzy
+		}
+	}
+	return nil, nil
+}
+
+// findReference matches modelID against model_id, preferring provider when set, and
+// then against model_name. Exact name matches backfill the missing model_id.
+func (h *ModelReferenceHandler) findReference(ctx context.Context, provider, modelID string, backfill bool) (*models.ModelReference, error) {
 	var ref models.ModelReference
 	if provider != "" {
-		errFind := h.db.WithContext(c.Request.Context()).
+		errFind := h.db.WithContext(ctx).
 			Where("provider_name = ? AND model_id = ?", provider, modelID).
 			First(&ref).Error
 		if errFind == nil {
-			c.JSON(http.StatusOK, formatModelReferencePrice(&ref))
-			return
+			return &ref, nil
 		}
 		if !errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "query model reference failed"})
-			return
+			return nil, errFind
 		}
 	}
 
-	errFind := h.db.WithContext(c.Request.Context()).
+	errFind := h.db.WithContext(ctx).
 		Where("model_id = ?", modelID).
 		Order("provider_name ASC").
 		First(&ref).Error
-	if errFind != nil {
-		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			if errFallback := h.db.WithContext(c.Request.Context()).
-				Where("model_name = ?", modelID).
-				Order("provider_name ASC").
-				First(&ref).Error; errFallback != nil {
-				if errors.Is(errFallback, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "query model reference failed"})
-				return
-			}
-			if strings.TrimSpace(ref.ModelID) == "" {
-				_ = h.db.WithContext(c.Request.Context()).
-					Model(&models.ModelReference{}).
-					Where("provider_name = ? AND model_name = ?", ref.ProviderName, ref.ModelName).
-					Update("model_id", modelID).Error
-				ref.ModelID = modelID
-			}
-			c.JSON(http.StatusOK, formatModelReferencePrice(&ref))
-			return
+	if errFind == nil {
+		return &ref, nil
+	}
+	if !errors.Is(errFind, gorm.ErrRecordNotFound) {
+		return nil, errFind
+	}
+
+	if errFallback := h.db.WithContext(ctx).
+		Where("model_name = ?", modelID).
+		Order("provider_name ASC").
+		First(&ref).Error; errFallback != nil {
+		if errors.Is(errFallback, gorm.ErrRecordNotFound) {
+			return nil, nil
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query model reference failed"})
-		return
+		return nil, errFallback
+	}
+	if backfill && strings.TrimSpace(ref.ModelID) == "" {
+		_ = h.db.WithContext(ctx).
+			Model(&models.ModelReference{}).
+			Where("provider_name = ? AND model_name = ?", ref.ProviderName, ref.ModelName).
+			Update("model_id", modelID).Error
+		ref.ModelID = modelID
 	}
+	return &ref, nil
+}
 
-	c.JSON(http.StatusOK, formatModelReferencePrice(&ref))
+// fuzzyModelCandidates returns shorter names to try when modelID has no reference,
+// longest first: a "vendor/" prefix is dropped, then trailing "-" segments one at a
+// time. Single-segment names are only tried when they contain a digit (o1, o3), so
+// gpt-4o never falls back to gpt.
+func fuzzyModelCandidates(modelID string) []string {
+	name := strings.TrimSpace(modelID)
+	out := make([]string, 0)
+	if idx := strings.LastIndex(name, "/"); idx >= 0 && idx < len(name)-1 {
+		name = name[idx+1:]
+		out = append(out, name)
+	}
+	segments := strings.Split(name, "-")
+	for n := len(segments) - 1; n >= 1; n-- {
+		candidate := strings.Join(segments[:n], "-")
+		if candidate == "" {
+			continue
+		}
+		if n == 1 && !strings.ContainsAny(candidate, "0123456789") {
+			continue
+		}
+		out = append(out, candidate)
+	}
+	return out
 }
 
 func formatModelReferencePrice(ref *models.ModelReference) gin.H {
@@ -178,3 +178,72 @@ func TestModelReferencePriceBackfillModelID(t *testing.T) {
 		t.Fatalf("expected model id backfill")
 	}
 }
+
+func TestModelReferencePriceFuzzyAndBulk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupModelReferenceDB(t)
+	gpt4o := 0.005
+	mini := 0.0006
+	rows := []models.ModelReference{
+		{ProviderName: "OpenAI", ModelName: "GPT-4o", ModelID: "gpt-4o", InputPrice: &gpt4o, LastSeenAt: time.Now().UTC()},
+		{ProviderName: "OpenAI", ModelName: "GPT-4o mini", ModelID: "gpt-4o-mini", InputPrice: &mini, LastSeenAt: time.Now().UTC()},
+	}
+	if errCreate := db.Create(&rows).Error; errCreate != nil {
+		t.Fatalf("create: %v", errCreate)
+	}
+	handler := NewModelReferenceHandler(db)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v0/admin/model-references/price?"+query, nil)
+		handler.GetPrice(c)
+		return w
+	}
+
+	w := get("model_id=gpt-4o-2024-05-13")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected fuzzy match, got %d", w.Code)
+	}
+	var single modelReferencePriceResponse
+	if errDecode := json.NewDecoder(w.Body).Decode(&single); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if single.Model != "gpt-4o" || single.PriceInputToken == nil || *single.PriceInputToken != gpt4o {
+		t.Fatalf("unexpected fuzzy match %+v", single)
+	}
+
+	w = get("models=gpt-4o-mini-2024-07-18,openai/gpt-4o,gpt-4o,claude-3-opus")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected bulk 200, got %d", w.Code)
+	}
+	var bulk struct {
+		Prices  map[string]modelReferencePriceResponse `json:"prices"`
+		Missing []string                               `json:"missing"`
+	}
+	if errDecode := json.NewDecoder(w.Body).Decode(&bulk); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if len(bulk.Prices) != 3 || bulk.Prices["gpt-4o-mini-2024-07-18"].Model != "gpt-4o-mini" ||
+		bulk.Prices["openai/gpt-4o"].Model != "gpt-4o" || bulk.Prices["gpt-4o"].Model != "gpt-4o" {
+		t.Fatalf("unexpected bulk prices %+v", bulk.Prices)
+	}
+	if len(bulk.Missing) != 1 || bulk.Missing[0] != "claude-3-opus" {
+		t.Fatalf("unexpected missing %v", bulk.Missing)
+	}
+}
+
+func TestFuzzyModelCandidates(t *testing.T) {
+	got := fuzzyModelCandidates("gpt-4o-2024-05-13")
+	want := []string{"gpt-4o-2024-05", "gpt-4o-2024", "gpt-4o"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if got = fuzzyModelCandidates("o1-preview"); len(got) != 1 || got[0] != "o1" {
+		t.Fatalf("unexpected o1 candidates %v", got)
+	}
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
+	"gorm.io/gorm"
+)
+
+// Reasons reported when a model is hidden or degraded.
+const (
+	// effectiveReasonMappingDisabled marks models whose only mappings are disabled.
+	effectiveReasonMappingDisabled = "mapping_disabled"
+	// effectiveReasonNotMapped marks models hidden by ONLY_MAPPED_MODELS.
+	effectiveReasonNotMapped = "not_mapped"
+	// effectiveReasonExcluded marks models listed in a provider key's excluded_models.
+	effectiveReasonExcluded = "excluded_models"
+	// effectiveReasonNoAuth marks models with no enabled auth serving them.
+	effectiveReasonNoAuth = "no_available_auth"
+)
+
+// effectiveModelRegistry is the subset of the SDK model registry used here.
+type effectiveModelRegistry interface {
+	ClientSupportsModel(clientID, modelID string) bool
+	GetAvailableModelsByProvider(provider string) []*cliproxy.ModelInfo
+}
+
+// EffectiveModelHandler explains which models are visible and why.
+type EffectiveModelHandler struct {
+	db       *gorm.DB
+	registry effectiveModelRegistry
+	auths    func() ([]*coreauth.Auth, bool)
+}
+
+// NewEffectiveModelHandler constructs an EffectiveModelHandler backed by the global
+// registry and the running watcher's auth snapshot.
+func NewEffectiveModelHandler(db *gorm.DB) *EffectiveModelHandler {
+	return &EffectiveModelHandler{db: db, registry: cliproxy.GlobalModelRegistry(), auths: watcher.CurrentAuths}
+}
+
+// effectiveMapping describes one mapping that exposes a model name.
+type effectiveMapping struct {
+	ID        uint64 `json:"id"`         // Mapping ID.
+	Provider  string `json:"provider"`   // Mapping provider.
+	ModelName string `json:"model_name"` // Upstream model name.
+	IsEnabled bool   `json:"is_enabled"` // Whether the mapping is active.
+}
+
+// effectiveAuthGroup identifies an auth group able to serve a model.
+type effectiveAuthGroup struct {
+	ID   uint64 `json:"id"`   // Auth group ID.
+	Name string `json:"name"` // Auth group name.
+}
+
+// effectiveExclusion identifies a provider key that excludes a model.
+type effectiveExclusion struct {
+	ProviderKeyID uint64 `json:"provider_key_id"` // Provider API key ID.
+	Provider      string `json:"provider"`        // Provider name.
+	Pattern       string `json:"pattern"`         // Matching excluded_models entry.
+}
+
+// effectiveModel is one entry in the effective model list.
+type effectiveModel struct {
+	Model          string               `json:"model"`           // Public model name.
+	Visible        bool                 `json:"visible"`         // Whether clients can list and use it.
+	Providers      []string             `json:"providers"`       // Providers serving it.
+	Mappings       []effectiveMapping   `json:"mappings"`        // Mappings exposing it.
+	AuthGroups     []effectiveAuthGroup `json:"auth_groups"`     // Auth groups able to serve it.
+	AvailableAuths int                  `json:"available_auths"` // Enabled auths backing it.
+	TotalAuths     int                  `json:"total_auths"`     // All auths backing it.
+	ExcludedBy     []effectiveExclusion `json:"excluded_by"`     // Provider keys excluding it.
+	Reasons        []string             `json:"reasons"`         // Why it is hidden or degraded.
+}
+
+// List returns every model name known to the registry or the mappings, with the
+// providers, auth groups and auths behind it and the reasons it may be hidden.
+func (h *EffectiveModelHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var mappingRows []models.ModelMapping
+	if errFind := h.db.WithContext(ctx).Order("id ASC").Find(&mappingRows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list model mappings failed"})
+		return
+	}
+	var keyRows []models.ProviderAPIKey
+	if errFind := h.db.WithContext(ctx).Select("id", "provider", "excluded_models").Order("id ASC").Find(&keyRows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list provider api keys failed"})
+		return
+	}
+	var authRows []models.Auth
+	if errFind := h.db.WithContext(ctx).Select("key", "auth_group_id").Find(&authRows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list auths failed"})
+		return
+	}
+	var groupRows []models.AuthGroup
+	if errFind := h.db.WithContext(ctx).Select("id", "name").Find(&groupRows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list auth groups failed"})
+		return
+	}
+
+	var auths []*coreauth.Auth
+	if h.auths != nil {
+		auths, _ = h.auths()
+	}
+	onlyMapped := internalsettings.GetBool(internalsettings.OnlyMappedModelsKey)
+
+	groupNames := make(map[uint64]string, len(groupRows))
+	for _, g := range groupRows {
+		groupNames[g.ID] = g.Name
+	}
+	groupByAuthKey := make(map[string]uint64, len(authRows))
+	for _, row := range authRows {
+		if primary := row.AuthGroupID.Primary(); primary != nil && *primary != 0 {
+			groupByAuthKey[strings.TrimSpace(row.Key)] = *primary
+		}
+	}
+
+	entries := make(map[string]*effectiveModel)
+	entryFor := func(name string) *effectiveModel {
+		key := strings.ToLower(name)
+		if entry, ok := entries[key]; ok {
+			return entry
+		}
+		entry := &effectiveModel{Model: name}
+		entries[key] = entry
+		return entry
+	}
+
+	// Models registered by the providers of the current auths.
+	seenProviders := make(map[string]struct{})
+	for _, a := range auths {
+		if a == nil {
+			continue
+		}
+		provider := strings.ToLower(strings.TrimSpace(a.Provider))
+		if provider == "" {
+			continue
+		}
+		if _, ok := seenProviders[provider]; ok {
+			continue
+		}
+		seenProviders[provider] = struct{}{}
+		if h.registry == nil {
+			continue
+		}
+		for _, info := range h.registry.GetAvailableModelsByProvider(provider) {
+			if info != nil && strings.TrimSpace(info.ID) != "" {
+				entryFor(strings.TrimSpace(info.ID))
+			}
+		}
+	}
+
+	// Models exposed by mappings, enabled or not.
+	for _, row := range mappingRows {
+		name := strings.TrimSpace(row.NewModelName)
+		if name == "" {
+			continue
+		}
+		entry := entryFor(name)
+		entry.Mappings = append(entry.Mappings, effectiveMapping{
+			ID:        row.ID,
+			Provider:  row.Provider,
+			ModelName: row.ModelName,
+			IsEnabled: row.IsEnabled,
+		})
+	}
+
+	// Models explicitly excluded by provider keys.
+	for _, row := range keyRows {
+		for _, pattern := range decodeExcludedModels(row.ExcludedModels) {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if !strings.ContainsAny(pattern, "*?[") {
+				entryFor(pattern)
+			}
+		}
+	}
+
+	out := make([]effectiveModel, 0, len(entries))
+	for _, entry := range entries {
+		h.resolveEffectiveModel(entry, auths, groupByAuthKey, groupNames, keyRows, onlyMapped)
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Model < out[j].Model })
+
+	c.JSON(http.StatusOK, gin.H{
+		"only_mapped_models": onlyMapped,
+		"auth_snapshot":      h.auths != nil && auths != nil,
+		"models":             out,
+	})
+}
+
+// resolveEffectiveModel fills in providers, auth coverage, exclusions and visibility for entry.
+func (h *EffectiveModelHandler) resolveEffectiveModel(
+	entry *effectiveModel,
+	auths []*coreauth.Auth,
+	groupByAuthKey map[string]uint64,
+	groupNames map[uint64]string,
+	keyRows []models.ProviderAPIKey,
+	onlyMapped bool,
+) {
+	hasEnabledMapping := false
+	upstreamByProvider := make(map[string][]string)
+	for _, m := range entry.Mappings {
+		if !m.IsEnabled {
+			continue
+		}
+		hasEnabledMapping = true
+		provider := strings.ToLower(strings.TrimSpace(m.Provider))
+		upstreamByProvider[provider] = append(upstreamByProvider[provider], strings.TrimSpace(m.ModelName))
+	}
+
+	providers := make(map[string]struct{})
+	groups := make(map[uint64]struct{})
+	for _, a := range auths {
+		if a == nil || !h.authServesModel(a, entry.Model, upstreamByProvider) {
+			continue
+		}
+		entry.TotalAuths++
+		providers[strings.ToLower(strings.TrimSpace(a.Provider))] = struct{}{}
+		if a.Disabled || a.Status == coreauth.StatusDisabled {
+			continue
+		}
+		entry.AvailableAuths++
+		if groupID, ok := groupByAuthKey[strings.TrimSpace(a.ID)]; ok {
+			groups[groupID] = struct{}{}
+		}
+	}
+	for provider := range upstreamByProvider {
+		providers[provider] = struct{}{}
+	}
+
+	lowerModel := strings.ToLower(entry.Model)
+	for _, row := range keyRows {
+		for _, pattern := range decodeExcludedModels(row.ExcludedModels) {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if matched, errMatch := path.Match(strings.ToLower(pattern), lowerModel); errMatch == nil && matched {
+				entry.ExcludedBy = append(entry.ExcludedBy, effectiveExclusion{
+					ProviderKeyID: row.ID,
+					Provider:      row.Provider,
+					Pattern:       pattern,
+				})
+				break
+			}
+		}
+	}
+
+	entry.Providers = make([]string, 0, len(providers))
+	for provider := range providers {
+		if provider != "" {
+			entry.Providers = append(entry.Providers, provider)
+		}
+	}
+	sort.Strings(entry.Providers)
+	entry.AuthGroups = make([]effectiveAuthGroup, 0, len(groups))
+	for id := range groups {
+		entry.AuthGroups = append(entry.AuthGroups, effectiveAuthGroup{ID: id, Name: groupNames[id]})
+	}
+	sort.Slice(entry.AuthGroups, func(i, j int) bool { return entry.AuthGroups[i].ID < entry.AuthGroups[j].ID })
+	if entry.Mappings == nil {
+		entry.Mappings = []effectiveMapping{}
+	}
+	if entry.ExcludedBy == nil {
+		entry.ExcludedBy = []effectiveExclusion{}
+	}
+
+	entry.Reasons = []string{}
+	if len(entry.Mappings) > 0 && !hasEnabledMapping {
+		entry.Reasons = append(entry.Reasons, effectiveReasonMappingDisabled)
+	}
+	if onlyMapped && !hasEnabledMapping {
+		entry.Reasons = append(entry.Reasons, effectiveReasonNotMapped)
+	}
+	if len(entry.ExcludedBy) > 0 {
+		entry.Reasons = append(entry.Reasons, effectiveReasonExcluded)
+	}
+	if entry.AvailableAuths == 0 {
+		entry.Reasons = append(entry.Reasons, effectiveReasonNoAuth)
+	}
+	entry.Visible = entry.AvailableAuths > 0 &&
+		(!onlyMapped || hasEnabledMapping) &&
+		(len(entry.Mappings) == 0 || hasEnabledMapping)
+}
+
+// authServesModel reports whether the registry lists model (or an enabled mapping's
+// upstream name for the auth's provider) for the auth.
+func (h *EffectiveModelHandler) authServesModel(a *coreauth.Auth, model string, upstreamByProvider map[string][]string) bool {
+	if h.registry == nil {
+		return false
+	}
+	id := strings.TrimSpace(a.ID)
+	if id == "" {
+		return false
+	}
+	if h.registry.ClientSupportsModel(id, model) {
+		return true
+	}
+	for _, upstream := range upstreamByProvider[strings.ToLower(strings.TrimSpace(a.Provider))] {
+		if upstream != "" && h.registry.ClientSupportsModel(id, upstream) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// fakeEffectiveRegistry serves a fixed set of models per client.
+type fakeEffectiveRegistry struct {
+	byClient   map[string][]string
+	byProvider map[string][]string
+}
+
+// ClientSupportsModel reports whether clientID lists modelID.
+func (r *fakeEffectiveRegistry) ClientSupportsModel(clientID, modelID string) bool {
+	for _, m := range r.byClient[clientID] {
+		if m == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAvailableModelsByProvider returns the models registered for provider.
+func (r *fakeEffectiveRegistry) GetAvailableModelsByProvider(provider string) []*cliproxy.ModelInfo {
+	out := make([]*cliproxy.ModelInfo, 0, len(r.byProvider[provider]))
+	for _, m := range r.byProvider[provider] {
+		out = append(out, &cliproxy.ModelInfo{ID: m})
+	}
+	return out
+}
+
+func TestEffectiveModelsReportsReasons(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:effective_models_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.ModelMapping{}, &models.ProviderAPIKey{}, &models.Auth{}, &models.AuthGroup{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	disabled := models.ModelMapping{Provider: "openai", ModelName: "gpt-4", NewModelName: "legacy", IsEnabled: true}
+	if errCreate := db.Create(&disabled).Error; errCreate != nil {
+		t.Fatalf("create mapping: %v", errCreate)
+	}
+	if errUpdate := db.Model(&disabled).Update("is_enabled", false).Error; errUpdate != nil {
+		t.Fatalf("disable mapping: %v", errUpdate)
+	}
+	if errCreate := db.Create(&models.ModelMapping{Provider: "openai", ModelName: "gpt-4", NewModelName: "smart", IsEnabled: true}).Error; errCreate != nil {
+		t.Fatalf("create mapping: %v", errCreate)
+	}
+	if errCreate := db.Create(&models.ProviderAPIKey{Provider: "openai", ExcludedModels: datatypes.JSON(`["gpt-3*"]`)}).Error; errCreate != nil {
+		t.Fatalf("create provider key: %v", errCreate)
+	}
+
+	registry := &fakeEffectiveRegistry{
+		byClient:   map[string][]string{"a1": {"gpt-4", "gpt-3.5"}, "a2": {"gpt-4"}},
+		byProvider: map[string][]string{"openai": {"gpt-4", "gpt-3.5"}},
+	}
+	auths := []*coreauth.Auth{
+		{ID: "a1", Provider: "openai", Status: coreauth.StatusActive},
+		{ID: "a2", Provider: "openai", Disabled: true, Status: coreauth.StatusDisabled},
+	}
+	handler := &EffectiveModelHandler{
+		db:       db,
+		registry: registry,
+		auths:    func() ([]*coreauth.Auth, bool) { return auths, true },
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v0/admin/models/effective", nil)
+	handler.List(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Models []effectiveModel `json:"models"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	byName := make(map[string]effectiveModel, len(resp.Models))
+	for _, m := range resp.Models {
+		byName[m.Model] = m
+	}
+
+	if m := byName["gpt-4"]; !m.Visible || m.AvailableAuths != 1 || m.TotalAuths != 2 {
+		t.Fatalf("unexpected gpt-4 entry: %+v", m)
+	}
+	if m := byName["smart"]; !m.Visible || m.AvailableAuths != 1 || len(m.Mappings) != 1 {
+		t.Fatalf("unexpected smart entry: %+v", m)
+	}
+	if m := byName["legacy"]; m.Visible || len(m.Reasons) == 0 || m.Reasons[0] != effectiveReasonMappingDisabled {
+		t.Fatalf("unexpected legacy entry: %+v", m)
+	}
+	if m := byName["gpt-3.5"]; len(m.ExcludedBy) != 1 || m.Reasons[0] != effectiveReasonExcluded {
+		t.Fatalf("unexpected gpt-3.5 entry: %+v", m)
+	}
+}
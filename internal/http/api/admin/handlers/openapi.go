@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/backup"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/openapi"
+	permissions "github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/permissions"
+)
+
+// openAPISelfRoutes lists admin routes outside the permission definitions: sign-in, which
+// needs no session, and the routes every signed-in admin may call.
+var openAPISelfRoutes = []openapi.Route{
+	{Method: "POST", Path: "/v0/admin/login", Summary: "Log In", Tag: "Session", Public: true},
+	{Method: "POST", Path: "/v0/admin/login/prepare", Summary: "Prepare Login", Tag: "Session", Public: true},
+	{Method: "POST", Path: "/v0/admin/login/totp", Summary: "Log In With TOTP", Tag: "Session", Public: true},
+	{Method: "POST", Path: "/v0/admin/login/passkey/options", Summary: "Start Passkey Login", Tag: "Session", Public: true},
+	{Method: "POST", Path: "/v0/admin/login/passkey/verify", Summary: "Finish Passkey Login", Tag: "Session", Public: true},
+	{Method: "GET", Path: "/v0/admin/login/oidc/start", Summary: "Start OIDC Login", Tag: "Session", Public: true},
+	{Method: "GET", Path: "/v0/admin/login/oidc/callback", Summary: "Finish OIDC Login", Tag: "Session", Public: true},
+	{Method: "GET", Path: "/v0/admin/mfa/status", Summary: "View MFA Status", Tag: "Session"},
+	{Method: "POST", Path: "/v0/admin/mfa/totp/prepare", Summary: "Prepare TOTP", Tag: "Session"},
+	{Method: "POST", Path: "/v0/admin/mfa/totp/confirm", Summary: "Confirm TOTP", Tag: "Session"},
+	{Method: "POST", Path: "/v0/admin/mfa/totp/disable", Summary: "Disable TOTP", Tag: "Session"},
+	{Method: "POST", Path: "/v0/admin/mfa/passkey/options", Summary: "Start Passkey Registration", Tag: "Session"},
+	{Method: "POST", Path: "/v0/admin/mfa/passkey/verify", Summary: "Finish Passkey Registration", Tag: "Session"},
+	{Method: "POST", Path: "/v0/admin/mfa/passkey/disable", Summary: "Disable Passkey", Tag: "Session"},
+	{Method: "GET", Path: "/v0/admin/sessions", Summary: "List Sessions", Tag: "Session"},
+	{Method: "DELETE", Path: "/v0/admin/sessions/:id", Summary: "Revoke Session", Tag: "Session"},
+	{Method: "POST", Path: "/v0/admin/system/reload", Summary: "Reload System", Tag: "System"},
+	{Method: "GET", Path: "/v0/admin/errors", Summary: "List Error Codes", Tag: "System"},
+	{Method: "GET", Path: "/v0/admin/openapi.json", Summary: "Get OpenAPI Document", Tag: "System"},
+}
+
+// openAPIWindowQuery is the query of auth file stats endpoints.
+type openAPIWindowQuery struct {
+	Window string `form:"window"` // Duration such as 1h or 168h; defaults to 24h.
+}
+
+// openAPIOperations registers the request and response types of admin routes. Routes
+// missing here are documented with free-form JSON bodies.
+var openAPIOperations = map[string]openapi.Operation{
+	openapi.OperationKey("POST", "/v0/admin/login"):                 {Body: loginRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/login/prepare"):         {Body: loginRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/login/totp"):            {Body: loginTotpRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/login/passkey/options"): {Body: loginPasskeyRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/mfa/totp/confirm"):      {Body: totpConfirmRequest{}},
+	openapi.OperationKey("GET", "/v0/admin/openapi.json"):           {Response: openapi.Document{}},
+
+	openapi.OperationKey("GET", "/v0/admin/dashboard/kpi"): {Response: kpiResponse{}},
+	openapi.OperationKey("GET", "/v0/admin/dashboard/traffic"): {Response: struct {
+		Points []trafficPoint `json:"points"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/dashboard/cost-distribution"): {Response: struct {
+		Items             []costItem `json:"items"`
+		TotalCostMicros   int64      `json:"total_cost_micros"`
+		SuccessCostMicros int64      `json:"success_cost_micros"`
+		FailedCostMicros  int64      `json:"failed_cost_micros"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/dashboard/top-consumers"): {Response: struct {
+		Items []topConsumerItem `json:"items"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/dashboard/top-models"): {Response: struct {
+		Items []topModelItem `json:"items"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/dashboard/model-health"): {Response: struct {
+		Items []healthItem `json:"items"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/dashboard/transactions"): {Response: struct {
+		Transactions []transactionItem `json:"transactions"`
+	}{}},
+
+	openapi.OperationKey("POST", "/v0/admin/users"): {Body: createUserRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("POST", "/v0/admin/users/import"): {Body: struct {
+		File openapi.File `json:"file"`
+	}{}, Multipart: true, Response: importUsersResponse{}},
+	openapi.OperationKey("GET", "/v0/admin/users"):                  {Query: userListQuery{}},
+	openapi.OperationKey("PUT", "/v0/admin/users/:id"):              {Body: updateUserRequest{}},
+	openapi.OperationKey("PUT", "/v0/admin/users/:id/password"):     {Body: changePasswordRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/users/:id/adjustments"): {Body: createAdjustmentRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("GET", "/v0/admin/users/:id/adjustments"):  {Query: adjustmentListQuery{}},
+	openapi.OperationKey("POST", "/v0/admin/users/:id/purge"):       {Body: userPurgeRequest{}},
+
+	openapi.OperationKey("POST", "/v0/admin/user-groups"):    {Body: createUserGroupRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/user-groups/:id"): {Body: updateUserGroupRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/auth-groups"):    {Body: createAuthGroupRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/auth-groups/:id"): {Body: updateAuthGroupRequest{}},
+	openapi.OperationKey("GET", "/v0/admin/tags"): {Response: struct {
+		Tags []tagSummary `json:"tags"`
+	}{}},
+
+	openapi.OperationKey("POST", "/v0/admin/auth-files"): {Body: createAuthFileRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("POST", "/v0/admin/auth-files/import"): {Body: struct {
+		Files       []openapi.File `json:"files"`
+		Mode        string         `json:"mode"`
+		AuthGroupID string         `json:"auth_group_id"`
+		Tags        string         `json:"tags"`
+	}{}, Multipart: true, Response: importAuthFilesResponse{}},
+	openapi.OperationKey("PUT", "/v0/admin/auth-files/:id"):                 {Body: updateAuthFileRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/auth-files/:id/clear-cooldown"): {Body: clearCooldownRequest{}},
+	openapi.OperationKey("GET", "/v0/admin/auth-files/:id/stats"):           {Query: openAPIWindowQuery{}},
+	openapi.OperationKey("POST", "/v0/admin/auth-files/batch-move-group"):   {Body: batchMoveGroupRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/auth-files/check"):              {Body: authFileCheckParams{}, Status: http.StatusAccepted},
+	openapi.OperationKey("PATCH", "/v0/admin/auth-files/priorities"): {Body: struct {
+		Items []authPriorityItem `json:"items"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/auth-files/stats"): {Query: struct {
+		Window string `form:"window"`
+		Sort   string `form:"sort"`
+		Order  string `form:"order"`
+		Type   string `form:"type"`
+		Limit  int    `form:"limit"`
+	}{}, Response: struct {
+		Items []authStatsItem `json:"items"`
+		From  time.Time       `json:"from"`
+		To    time.Time       `json:"to"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/auth-files/duplicates"): {Response: struct {
+		Total  int `json:"total"`
+		Groups []struct {
+			Fingerprint string              `json:"fingerprint"`
+			Type        string              `json:"type"`
+			Auths       []authDuplicateItem `json:"auths"`
+		} `json:"groups"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/events/stream"): {Query: eventStreamQuery{}},
+	openapi.OperationKey("GET", "/v0/admin/pending-actions"): {Query: pendingActionListQuery{}, Response: struct {
+		PendingActions []pendingActionView `json:"pending_actions"`
+		Total          int64               `json:"total"`
+		Page           int                 `json:"page"`
+		PageSize       int                 `json:"page_size"`
+	}{}},
+	openapi.OperationKey("GET", "/v0/admin/auth-files/trash"): {Response: struct {
+		AuthFiles []trashedAuthFile `json:"auth_files"`
+	}{}},
+	openapi.OperationKey("POST", "/v0/admin/backups"): {Status: http.StatusAccepted},
+	openapi.OperationKey("GET", "/v0/admin/backups"): {Response: struct {
+		Backups        []backup.File `json:"backups"`
+		IntervalHours  int           `json:"interval_hours"`
+		RetentionCount int           `json:"retention_count"`
+	}{}},
+
+	openapi.OperationKey("GET", "/v0/admin/quotas"):                                    {Query: quotaListQuery{}},
+	openapi.OperationKey("POST", "/v0/admin/model-mappings"):                           {Body: createModelMappingRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/model-mappings/:id"):                        {Body: updateModelMappingRequest{}},
+	openapi.OperationKey("GET", "/v0/admin/model-mappings/export"):                     {Response: modelMappingBundle{}},
+	openapi.OperationKey("POST", "/v0/admin/model-mappings/import"):                    {Body: modelMappingBundle{}},
+	openapi.OperationKey("POST", "/v0/admin/model-mappings/:id/payload-rules"):         {Body: createPayloadRuleRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/model-mappings/:id/payload-rules/:rule_id"): {Body: updatePayloadRuleRequest{}},
+
+	openapi.OperationKey("GET", "/v0/admin/api-keys"):              {Query: apiKeyListQuery{}},
+	openapi.OperationKey("GET", "/v0/admin/api-keys/idle"):         {Query: idleAPIKeyQuery{}},
+	openapi.OperationKey("POST", "/v0/admin/provider-api-keys"):    {Body: createProviderAPIKeyRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/provider-api-keys/:id"): {Body: updateProviderAPIKeyRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/proxies"):              {Body: createProxyRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("POST", "/v0/admin/proxies/batch"):        {Body: batchCreateProxyRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/proxies/:id"):           {Body: updateProxyRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/prepaid-cards"):        {Body: createPrepaidCardRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("POST", "/v0/admin/prepaid-cards/batch"):  {Body: batchCreatePrepaidCardRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/prepaid-cards/:id"):     {Body: updatePrepaidCardRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/invite-codes"):         {Body: createInviteCodeRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/invite-codes/:id"):      {Body: updateInviteCodeRequest{}},
+
+	openapi.OperationKey("POST", "/v0/admin/bills"):                      {Body: createBillRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("GET", "/v0/admin/bills"):                       {Query: billListQuery{}},
+	openapi.OperationKey("PUT", "/v0/admin/bills/:id"):                   {Body: updateBillRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/billing-rules"):              {Body: createBillingRuleRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/billing-rules/:id"):           {Body: updateBillingRuleRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/billing-rules/:id/enabled"):  {Body: setEnabledRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/billing-rules/batch-import"): {Body: batchImportRequest{}},
+	openapi.OperationKey("GET", "/v0/admin/billing/summary"): {Query: struct {
+		APIKeyID string `form:"api_key_id"`
+		From     string `form:"from"`
+		To       string `form:"to"`
+		GroupBy  string `form:"group_by"`
+	}{}, Response: struct {
+		GroupBy  string                 `json:"group_by"`
+		Currency string                 `json:"currency"`
+		Items    []billingBreakdownItem `json:"items"`
+		Totals   billingBreakdownItem   `json:"totals"`
+	}{}},
+
+	openapi.OperationKey("GET", "/v0/admin/logs"):          {Query: adminLogsListQuery{}},
+	openapi.OperationKey("GET", "/v0/admin/logs/detail"):   {Query: adminLogDetailQuery{}},
+	openapi.OperationKey("POST", "/v0/admin/settings"):     {Body: createSettingRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/settings/:key"): {Body: updateSettingRequest{}},
+	openapi.OperationKey("POST", "/v0/admin/usage/export"): {Body: usageExportParams{}, Status: http.StatusAccepted},
+	openapi.OperationKey("GET", "/v0/admin/jobs"):          {Query: jobListQuery{}},
+
+	openapi.OperationKey("POST", "/v0/admin/admins"):             {Body: createAdminRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/admins/:id"):          {Body: updateAdminRequest{}},
+	openapi.OperationKey("PUT", "/v0/admin/admins/:id/password"): {Body: changeAdminPasswordRequest{}},
+	openapi.OperationKey("GET", "/v0/admin/permissions"): {Query: permissionListQuery{}, Response: struct {
+		Permissions []permissionItem   `json:"permissions"`
+		Modules     []permissionModule `json:"modules"` // Set instead of permissions with grouped=1.
+		Total       int                `json:"total"`
+	}{}},
+	openapi.OperationKey("POST", "/v0/admin/plans"):    {Body: createPlanRequest{}, Status: http.StatusCreated},
+	openapi.OperationKey("PUT", "/v0/admin/plans/:id"): {Body: updatePlanRequest{}},
+}
+
+// openAPIRoutes returns every documented admin route: the permission definitions followed
+// by the routes outside them.
+func openAPIRoutes() []openapi.Route {
+	defs := permissions.Definitions()
+	routes := make([]openapi.Route, 0, len(defs)+len(openAPISelfRoutes))
+	for _, def := range defs {
+		routes = append(routes, openapi.Route{
+			Method:         def.Method,
+			Path:           def.Path,
+			Summary:        def.Label,
+			Tag:            def.Module,
+			Permission:     def.Key,
+			SuperAdminOnly: def.SuperAdminOnly,
+		})
+	}
+	return append(routes, openAPISelfRoutes...)
+}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument openapi.Document
+)
+
+// OpenAPISpec returns the OpenAPI 3 document of the admin API. It is built once from the
+// permission definitions and the types registered in openAPIOperations.
+func OpenAPISpec(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPIDocument = openapi.Build("CLIProxyAPI Business Admin API", buildinfo.Version, openAPIRoutes(), openAPIOperations)
+	})
+	c.JSON(http.StatusOK, openAPIDocument)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/openapi"
+	permissions "github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/permissions"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := make(map[string]bool)
+	for _, route := range openAPIRoutes() {
+		routes[openapi.OperationKey(route.Method, route.Path)] = true
+	}
+	for key := range openAPIOperations {
+		if !routes[key] {
+			t.Errorf("operation %q does not match an admin route", key)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/v0/admin/openapi.json", OpenAPISpec)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &doc); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Fatalf("unexpected openapi version %q", doc.OpenAPI)
+	}
+	for _, def := range permissions.Definitions() {
+		path := def.Path
+		for _, segment := range strings.Split(path, "/") {
+			if name, ok := strings.CutPrefix(segment, ":"); ok {
+				path = strings.Replace(path, segment, "{"+name+"}", 1)
+			}
+		}
+		op, ok := doc.Paths[path][strings.ToLower(def.Method)]
+		if !ok {
+			t.Errorf("missing operation for %s", def.Key)
+			continue
+		}
+		if op["x-permission"] != def.Key {
+			t.Errorf("%s: unexpected x-permission %v", def.Key, op["x-permission"])
+		}
+	}
+	users := doc.Paths["/v0/admin/users"]["post"]
+	if users["requestBody"] == nil || users["responses"].(map[string]any)["201"] == nil {
+		t.Fatalf("expected the create user operation to carry its registered types, got %v", users)
+	}
+}
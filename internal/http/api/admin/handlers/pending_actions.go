@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/permissions"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+// PendingActionExecutor runs an approved action against the record with targetID.
+type PendingActionExecutor func(ctx context.Context, targetID uint64) error
+
+// PendingActionHandler holds back destructive admin requests listed in
+// APPROVAL_REQUIRED_ACTIONS until a second admin approves them, then runs them.
+type PendingActionHandler struct {
+	db        *gorm.DB
+	executors map[string]PendingActionExecutor
+}
+
+// NewPendingActionHandler constructs a PendingActionHandler without executors.
+func NewPendingActionHandler(db *gorm.DB) *PendingActionHandler {
+	return &PendingActionHandler{db: db, executors: make(map[string]PendingActionExecutor)}
+}
+
+// Register sets the executor that runs action once approved. It must be called while
+// routes are registered, before requests are served.
+func (h *PendingActionHandler) Register(action string, executor PendingActionExecutor) {
+	h.executors[action] = executor
+}
+
+// pendingActionListQuery defines filters for the pending action list.
+type pendingActionListQuery struct {
+	Status   string `form:"status"`               // Status filter.
+	Action   string `form:"action"`               // Action filter.
+	Page     int    `form:"page,default=1"`       // Page number (1-based).
+	PageSize int    `form:"page_size,default=20"` // Page size.
+}
+
+// pendingActionView is the JSON form of a pending action.
+type pendingActionView struct {
+	ID          uint64     `json:"id"`
+	Action      string     `json:"action"`
+	TargetID    uint64     `json:"target_id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	RequestedBy uint64     `json:"requested_by"`
+	DecidedBy   *uint64    `json:"decided_by"`
+	DecidedAt   *time.Time `json:"decided_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// newPendingActionView converts a pending action row for responses.
+func newPendingActionView(row models.PendingAction) pendingActionView {
+	return pendingActionView{
+		ID:          row.ID,
+		Action:      row.Action,
+		TargetID:    row.TargetID,
+		Status:      row.Status,
+		Error:       row.Error,
+		RequestedBy: row.RequestedBy,
+		DecidedBy:   row.DecidedBy,
+		DecidedAt:   row.DecidedAt,
+		CreatedAt:   row.CreatedAt,
+	}
+}
+
+// Require returns middleware for a route with an :id parameter that records the request
+// as a pending action and answers 202 when APPROVAL_REQUIRED_ACTIONS lists action. A
+// repeated request for the same target returns the action already waiting.
+func (h *PendingActionHandler) Require(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !internalsettings.ApprovalRequired(action) {
+			c.Next()
+			return
+		}
+		targetID, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+		if errParse != nil {
+			apierror.Write(c, apierror.InvalidID())
+			c.Abort()
+			return
+		}
+		adminID, ok := readAdminIDFromContext(c)
+		if !ok {
+			apierror.Write(c, apierror.Unauthorized("admin not found"))
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		var row models.PendingAction
+		errFind := h.db.WithContext(ctx).
+			Where("action = ? AND target_id = ? AND status = ?", action, targetID, models.PendingActionStatusPending).
+			First(&row).Error
+		if errFind != nil && !errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.Internal("query pending actions failed"))
+			c.Abort()
+			return
+		}
+		if errFind != nil {
+			row = models.PendingAction{
+				Action:      action,
+				TargetID:    targetID,
+				Permission:  permissions.Key(c.Request.Method, c.FullPath()),
+				Status:      models.PendingActionStatusPending,
+				RequestedBy: adminID,
+			}
+			if errCreate := h.db.WithContext(ctx).Create(&row).Error; errCreate != nil {
+				apierror.Write(c, apierror.Internal("create pending action failed"))
+				c.Abort()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusAccepted, gin.H{"pending_action": newPendingActionView(row)})
+	}
+}
+
+// List returns pending actions newest first, filtered by status and action.
+func (h *PendingActionHandler) List(c *gin.Context) {
+	var query pendingActionListQuery
+	if errBind := c.ShouldBindQuery(&query); errBind != nil {
+		apierror.Write(c, apierror.InvalidRequest("invalid query"))
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	q := h.db.WithContext(c.Request.Context()).Model(&models.PendingAction{})
+	if status := strings.TrimSpace(query.Status); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if action := strings.TrimSpace(query.Action); action != "" {
+		q = q.Where("action = ?", action)
+	}
+
+	var total int64
+	if errCount := q.Count(&total).Error; errCount != nil {
+		apierror.Write(c, apierror.Internal("count pending actions failed"))
+		return
+	}
+	var rows []models.PendingAction
+	if errFind := q.Order("created_at DESC, id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&rows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("list pending actions failed"))
+		return
+	}
+	out := make([]pendingActionView, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, newPendingActionView(row))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"pending_actions": out,
+		"total":           total,
+		"page":            query.Page,
+		"page_size":       query.PageSize,
+	})
+}
+
+// Approve runs a pending action on behalf of the admin that requested it. The approver
+// must be a different admin and must hold the permission of the deferred route.
+func (h *PendingActionHandler) Approve(c *gin.Context) {
+	row, adminID, ok := h.decide(c)
+	if !ok {
+		return
+	}
+	if adminID == row.RequestedBy {
+		apierror.Write(c, apierror.Forbidden("an action cannot be approved by the admin who requested it"))
+		return
+	}
+	if !adminHoldsPermission(c, row.Permission) {
+		apierror.Write(c, apierror.Forbidden("permission denied").With("permission", row.Permission))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if !h.transition(c, &row, models.PendingActionStatusApproved, adminID) {
+		return
+	}
+	status, errMessage := models.PendingActionStatusExecuted, ""
+	if executor, okExecutor := h.executors[row.Action]; !okExecutor {
+		status, errMessage = models.PendingActionStatusFailed, "unknown action"
+	} else if errRun := executor(ctx, row.TargetID); errRun != nil {
+		status, errMessage = models.PendingActionStatusFailed, errRun.Error()
+		if errors.Is(errRun, gorm.ErrRecordNotFound) {
+			errMessage = "target not found"
+		}
+	}
+	if errUpdate := h.db.WithContext(ctx).Model(&models.PendingAction{}).Where("id = ?", row.ID).
+		Updates(map[string]any{"status": status, "error": errMessage, "updated_at": time.Now().UTC()}).Error; errUpdate != nil {
+		apierror.Write(c, apierror.Internal("update pending action failed"))
+		return
+	}
+	row.Status, row.Error = status, errMessage
+	c.JSON(http.StatusOK, gin.H{"pending_action": newPendingActionView(row)})
+}
+
+// Reject turns down a pending action. The requesting admin may reject it to withdraw it.
+func (h *PendingActionHandler) Reject(c *gin.Context) {
+	row, adminID, ok := h.decide(c)
+	if !ok {
+		return
+	}
+	if !h.transition(c, &row, models.PendingActionStatusRejected, adminID) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pending_action": newPendingActionView(row)})
+}
+
+// decide loads the pending action named by the :id parameter along with the acting admin.
+// It writes the error response and returns false when the action cannot be decided.
+func (h *PendingActionHandler) decide(c *gin.Context) (models.PendingAction, uint64, bool) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return models.PendingAction{}, 0, false
+	}
+	adminID, ok := readAdminIDFromContext(c)
+	if !ok {
+		apierror.Write(c, apierror.Unauthorized("admin not found"))
+		return models.PendingAction{}, 0, false
+	}
+	var row models.PendingAction
+	if errFind := h.db.WithContext(c.Request.Context()).First(&row, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("not found"))
+			return models.PendingAction{}, 0, false
+		}
+		apierror.Write(c, apierror.Internal("query failed"))
+		return models.PendingAction{}, 0, false
+	}
+	if row.Status != models.PendingActionStatusPending {
+		apierror.Write(c, apierror.Conflict("action already decided").With("status", row.Status))
+		return models.PendingAction{}, 0, false
+	}
+	return row, adminID, true
+}
+
+// transition moves a pending action to status, recording the deciding admin. It fails
+// with a conflict when a concurrent request decided the action first.
+func (h *PendingActionHandler) transition(c *gin.Context, row *models.PendingAction, status string, adminID uint64) bool {
+	now := time.Now().UTC()
+	res := h.db.WithContext(c.Request.Context()).Model(&models.PendingAction{}).
+		Where("id = ? AND status = ?", row.ID, models.PendingActionStatusPending).
+		Updates(map[string]any{"status": status, "decided_by": adminID, "decided_at": now, "updated_at": now})
+	if res.Error != nil {
+		apierror.Write(c, apierror.Internal("update pending action failed"))
+		return false
+	}
+	if res.RowsAffected == 0 {
+		apierror.Write(c, apierror.Conflict("action already decided"))
+		return false
+	}
+	row.Status, row.DecidedBy, row.DecidedAt = status, &adminID, &now
+	return true
+}
+
+// adminHoldsPermission reports whether the acting admin is a super admin or holds key.
+func adminHoldsPermission(c *gin.Context, key string) bool {
+	if c.GetBool("adminIsSuperAdmin") {
+		return true
+	}
+	held, _ := c.Get("adminPermissions")
+	perms, _ := held.([]string)
+	return permissions.HasPermission(perms, key)
+}
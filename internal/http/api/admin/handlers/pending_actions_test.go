@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/permissions"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+func TestPendingActionRequiresSecondAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:pending_actions_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.APIKey{}, &models.PendingAction{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.ApprovalRequiredActionsKey: json.RawMessage(`["delete_user"]`),
+	})
+
+	pending := NewPendingActionHandler(db)
+	users := NewUserHandler(db)
+	pending.Register(internalsettings.ApprovalActionDeleteUser, users.DeleteByID)
+	deleteUser := permissions.Key("DELETE", "/v0/admin/users/:id")
+	newRouter := func(id uint64, perms []string, super bool) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("adminID", id)
+			c.Set("adminPermissions", perms)
+			c.Set("adminIsSuperAdmin", super)
+		})
+		r.DELETE("/v0/admin/users/:id", pending.Require(internalsettings.ApprovalActionDeleteUser), users.Delete)
+		r.POST("/v0/admin/pending-actions/:id/approve", pending.Approve)
+		r.POST("/v0/admin/pending-actions/:id/reject", pending.Reject)
+		return r
+	}
+	type result struct {
+		PendingAction pendingActionView `json:"pending_action"`
+	}
+	send := func(r *gin.Engine, method, path string) (int, pendingActionView) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		var out result
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out.PendingAction
+	}
+	maker := newRouter(1, []string{deleteUser}, false)
+	checker := newRouter(2, []string{deleteUser}, false)
+	bystander := newRouter(3, nil, false)
+
+	code, action := send(maker, http.MethodDelete, fmt.Sprintf("/v0/admin/users/%d", user.ID))
+	if code != http.StatusAccepted || action.Status != models.PendingActionStatusPending || action.TargetID != user.ID {
+		t.Fatalf("expected the delete to wait for approval, got %d %+v", code, action)
+	}
+	if errFind := db.First(&models.User{}, user.ID).Error; errFind != nil {
+		t.Fatalf("expected the user to survive until approval: %v", errFind)
+	}
+	if again, repeat := send(maker, http.MethodDelete, fmt.Sprintf("/v0/admin/users/%d", user.ID)); again != http.StatusAccepted || repeat.ID != action.ID {
+		t.Fatalf("expected a repeated request to return the waiting action, got %d %+v", again, repeat)
+	}
+
+	approve := fmt.Sprintf("/v0/admin/pending-actions/%d/approve", action.ID)
+	if code, _ := send(maker, http.MethodPost, approve); code != http.StatusForbidden {
+		t.Fatalf("expected the requester to be refused, got %d", code)
+	}
+	refused := httptest.NewRecorder()
+	bystander.ServeHTTP(refused, httptest.NewRequest(http.MethodPost, approve, nil))
+	var refusal struct {
+		Code       apierror.Code `json:"code"`
+		Permission string        `json:"permission"`
+	}
+	if errDecode := json.Unmarshal(refused.Body.Bytes(), &refusal); refused.Code != http.StatusForbidden || errDecode != nil ||
+		refusal.Code != apierror.CodeForbidden || refusal.Permission != deleteUser {
+		t.Fatalf("expected an approver without the route permission to be refused, got %d %s", refused.Code, refused.Body.String())
+	}
+	code, action = send(checker, http.MethodPost, approve)
+	if code != http.StatusOK || action.Status != models.PendingActionStatusExecuted || action.DecidedBy == nil || *action.DecidedBy != 2 {
+		t.Fatalf("expected approval to execute the delete, got %d %+v", code, action)
+	}
+	if errFind := db.First(&models.User{}, user.ID).Error; errFind == nil {
+		t.Fatal("expected the user to be deleted after approval")
+	}
+	if code, _ := send(checker, http.MethodPost, approve); code != http.StatusConflict {
+		t.Fatalf("expected a decided action to conflict, got %d", code)
+	}
+
+	_, missing := send(maker, http.MethodDelete, "/v0/admin/users/999")
+	if code, failed := send(checker, http.MethodPost, fmt.Sprintf("/v0/admin/pending-actions/%d/approve", missing.ID)); code != http.StatusOK || failed.Status != models.PendingActionStatusFailed || failed.Error != "target not found" {
+		t.Fatalf("expected a missing target to fail the action, got %d %+v", code, failed)
+	}
+
+	_, withdrawn := send(maker, http.MethodDelete, "/v0/admin/users/998")
+	if code, rejected := send(maker, http.MethodPost, fmt.Sprintf("/v0/admin/pending-actions/%d/reject", withdrawn.ID)); code != http.StatusOK || rejected.Status != models.PendingActionStatusRejected {
+		t.Fatalf("expected the requester to withdraw the action, got %d %+v", code, rejected)
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), nil)
+	if code, _ := send(maker, http.MethodDelete, "/v0/admin/users/997"); code != http.StatusNotFound {
+		t.Fatalf("expected deletes to run directly without the setting, got %d", code)
+	}
+}
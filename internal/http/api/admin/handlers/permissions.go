@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	permissions "github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/admin/permissions"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 )
 
 // PermissionHandler exposes permission definitions for admins.
@@ -15,18 +18,70 @@ func NewPermissionHandler() *PermissionHandler {
 	return &PermissionHandler{}
 }
 
-// List returns all permission definitions.
+// permissionItem is one permission definition in list responses.
+type permissionItem struct {
+	Key            string `json:"key"`
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	Label          string `json:"label"`
+	Module         string `json:"module"`
+	Write          bool   `json:"write"` // Whether the permission allows changes rather than reads.
+	SuperAdminOnly bool   `json:"super_admin_only"`
+}
+
+// permissionModule groups the permissions of one module.
+type permissionModule struct {
+	Module      string           `json:"module"`
+	Count       int              `json:"count"`
+	WriteCount  int              `json:"write_count"`
+	Permissions []permissionItem `json:"permissions"`
+}
+
+// permissionListQuery is the query of the permission list.
+type permissionListQuery struct {
+	Grouped string `form:"grouped"` // Truthy to group permissions by module.
+}
+
+// List returns all permission definitions, leaving out token-request flows of OAuth
+// providers disabled by ENABLED_OAUTH_PROVIDERS. With grouped=1 the permissions are
+// grouped by module, in definition order, with counts.
 func (h *PermissionHandler) List(c *gin.Context) {
 	defs := permissions.Definitions()
-	out := make([]gin.H, 0, len(defs))
+	out := make([]permissionItem, 0, len(defs))
 	for _, def := range defs {
-		out = append(out, gin.H{
-			"key":    def.Key,
-			"method": def.Method,
-			"path":   def.Path,
-			"label":  def.Label,
-			"module": def.Module,
+		if provider, ok := permissions.TokenFlowProvider(def.Path); ok && !internalsettings.OAuthProviderEnabled(provider) {
+			continue
+		}
+		out = append(out, permissionItem{
+			Key:            def.Key,
+			Method:         def.Method,
+			Path:           def.Path,
+			Label:          def.Label,
+			Module:         def.Module,
+			Write:          def.Write(),
+			SuperAdminOnly: def.SuperAdminOnly,
 		})
 	}
-	c.JSON(http.StatusOK, gin.H{"permissions": out})
+
+	grouped, _ := strconv.ParseBool(strings.TrimSpace(c.Query("grouped")))
+	if !grouped {
+		c.JSON(http.StatusOK, gin.H{"permissions": out})
+		return
+	}
+	modules := make([]permissionModule, 0)
+	index := make(map[string]int)
+	for _, item := range out {
+		i, ok := index[item.Module]
+		if !ok {
+			i = len(modules)
+			index[item.Module] = i
+			modules = append(modules, permissionModule{Module: item.Module})
+		}
+		modules[i].Count++
+		if item.Write {
+			modules[i].WriteCount++
+		}
+		modules[i].Permissions = append(modules[i].Permissions, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"modules": modules, "total": len(out)})
 }
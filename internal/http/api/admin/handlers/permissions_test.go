@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPermissionListGrouped(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v0/admin/permissions", NewPermissionHandler().List)
+	get := func(path string, out any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, w.Code)
+		}
+		if errDecode := json.Unmarshal(w.Body.Bytes(), out); errDecode != nil {
+			t.Fatalf("%s: decode: %v", path, errDecode)
+		}
+	}
+
+	var flat struct {
+		Permissions []permissionItem `json:"permissions"`
+	}
+	get("/v0/admin/permissions", &flat)
+	var grouped struct {
+		Modules []permissionModule `json:"modules"`
+		Total   int                `json:"total"`
+	}
+	get("/v0/admin/permissions?grouped=1", &grouped)
+
+	if grouped.Total != len(flat.Permissions) {
+		t.Fatalf("expected %d grouped permissions, got %d", len(flat.Permissions), grouped.Total)
+	}
+	seen := make(map[string]bool)
+	sum := 0
+	for _, module := range grouped.Modules {
+		if seen[module.Module] {
+			t.Fatalf("module %q listed twice", module.Module)
+		}
+		seen[module.Module] = true
+		writes := 0
+		for _, item := range module.Permissions {
+			if item.Module != module.Module {
+				t.Fatalf("permission %s listed under %q", item.Key, module.Module)
+			}
+			if item.Write != (item.Method != http.MethodGet) {
+				t.Fatalf("permission %s: unexpected write flag %t", item.Key, item.Write)
+			}
+			if item.Write {
+				writes++
+			}
+		}
+		if module.Count != len(module.Permissions) || module.WriteCount != writes {
+			t.Fatalf("module %q: unexpected counts %d/%d", module.Module, module.Count, module.WriteCount)
+		}
+		sum += module.Count
+	}
+	if sum != grouped.Total || grouped.Modules[0].Module != flat.Permissions[0].Module {
+		t.Fatalf("expected modules in definition order covering every permission, got %+v", grouped.Modules[0])
+	}
+}
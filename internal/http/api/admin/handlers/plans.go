@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -78,32 +79,37 @@ func normalizePlanSupportModels(raw json.RawMessage) (datatypes.JSON, error) {
 
 // createPlanRequest captures the payload for creating a plan.
 type createPlanRequest struct {
-	Name          string              `json:"name"`           // Plan name.
-	MonthPrice    float64             `json:"month_price"`    // Monthly price.
-	Description   string              `json:"description"`    // Plan description.
-	SupportModels json.RawMessage     `json:"support_models"` // Supported models payload.
-	UserGroupID   models.UserGroupIDs `json:"user_group_id"`  // Included user group IDs.
-	Feature1      string              `json:"feature1"`       // Feature line 1.
-	Feature2      string              `json:"feature2"`       // Feature line 2.
-	Feature3      string              `json:"feature3"`       // Feature line 3.
-	Feature4      string              `json:"feature4"`       // Feature line 4.
-	SortOrder     int                 `json:"sort_order"`     // Display order.
-	TotalQuota    float64             `json:"total_quota"`    // Total quota value.
-	DailyQuota    float64             `json:"daily_quota"`    // Daily quota value.
-	RateLimit     int                 `json:"rate_limit"`     // Rate limit per second.
-	IsEnabled     *bool               `json:"is_enabled"`     // Optional active flag.
+	Name           string              `json:"name"`             // Plan name.
+	MonthPrice     float64             `json:"month_price"`      // Monthly price.
+	Description    string              `json:"description"`      // Plan description.
+	SupportModels  json.RawMessage     `json:"support_models"`   // Supported models payload.
+	UserGroupID    models.UserGroupIDs `json:"user_group_id"`    // Included user group IDs.
+	Feature1       string              `json:"feature1"`         // Feature line 1.
+	Feature2       string              `json:"feature2"`         // Feature line 2.
+	Feature3       string              `json:"feature3"`         // Feature line 3.
+	Feature4       string              `json:"feature4"`         // Feature line 4.
+	SortOrder      int                 `json:"sort_order"`       // Display order.
+	TotalQuota     float64             `json:"total_quota"`      // Total quota value.
+	DailyQuota     float64             `json:"daily_quota"`      // Daily quota value.
+	RateLimit      int                 `json:"rate_limit"`       // Rate limit per second.
+	AlertAtPercent int                 `json:"alert_at_percent"` // Usage percent that alerts plan users (0 = off).
+	IsEnabled      *bool               `json:"is_enabled"`       // Optional active flag.
 }
 
 // Create validates input and inserts a new plan.
 func (h *PlanHandler) Create(c *gin.Context) {
 	var body createPlanRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	if strings.TrimSpace(body.Name) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		apierror.Write(c, apierror.Validation("name", "name is required"))
+		return
+	}
+	if body.AlertAtPercent < 0 || body.AlertAtPercent > 100 {
+		apierror.Write(c, apierror.Validation("alert_at_percent", "alert_at_percent must be between 0 and 100"))
 		return
 	}
 
@@ -114,32 +120,33 @@ func (h *PlanHandler) Create(c *gin.Context) {
 
 	supportModels, errSupportModels := normalizePlanSupportModels(body.SupportModels)
 	if errSupportModels != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid support_models"})
+		apierror.Write(c, apierror.Validation("support_models", "invalid support_models"))
 		return
 	}
 
 	now := time.Now().UTC()
 	plan := models.Plan{
-		Name:          strings.TrimSpace(body.Name),
-		MonthPrice:    body.MonthPrice,
-		Description:   body.Description,
-		SupportModels: supportModels,
-		UserGroupID:   body.UserGroupID.Clean(),
-		Feature1:      body.Feature1,
-		Feature2:      body.Feature2,
-		Feature3:      body.Feature3,
-		Feature4:      body.Feature4,
-		SortOrder:     body.SortOrder,
-		TotalQuota:    body.TotalQuota,
-		DailyQuota:    body.DailyQuota,
-		RateLimit:     body.RateLimit,
-		IsEnabled:     isEnabled,
-		CreatedAt:     now,
-		UpdatedAt:     now,
+		Name:           strings.TrimSpace(body.Name),
+		MonthPrice:     body.MonthPrice,
+		Description:    body.Description,
+		SupportModels:  supportModels,
+		UserGroupID:    body.UserGroupID.Clean(),
+		Feature1:       body.Feature1,
+		Feature2:       body.Feature2,
+		Feature3:       body.Feature3,
+		Feature4:       body.Feature4,
+		SortOrder:      body.SortOrder,
+		TotalQuota:     body.TotalQuota,
+		DailyQuota:     body.DailyQuota,
+		RateLimit:      body.RateLimit,
+		AlertAtPercent: body.AlertAtPercent,
+		IsEnabled:      isEnabled,
+		CreatedAt:      now,
+		UpdatedAt:      now,
 	}
 
 	if errCreate := h.db.WithContext(c.Request.Context()).Create(&plan).Error; errCreate != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "create plan failed"})
+		apierror.Write(c, apierror.Internal("create plan failed"))
 		return
 	}
 	c.JSON(http.StatusCreated, h.formatPlan(&plan))
@@ -160,7 +167,7 @@ func (h *PlanHandler) List(c *gin.Context) {
 
 	var rows []models.Plan
 	if errFind := q.Order("sort_order ASC, created_at DESC").Find(&rows).Error; errFind != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list plans failed"})
+		apierror.Write(c, apierror.Internal("list plans failed"))
 		return
 	}
 	out := make([]gin.H, 0, len(rows))
@@ -174,16 +181,16 @@ func (h *PlanHandler) List(c *gin.Context) {
 func (h *PlanHandler) Get(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	var plan models.Plan
 	if errFind := h.db.WithContext(c.Request.Context()).First(&plan, id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			apierror.Write(c, apierror.NotFound("not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		apierror.Write(c, apierror.Internal("query failed"))
 		return
 	}
 	c.JSON(http.StatusOK, h.formatPlan(&plan))
@@ -191,42 +198,43 @@ func (h *PlanHandler) Get(c *gin.Context) {
 
 // updatePlanRequest captures optional fields for plan updates.
 type updatePlanRequest struct {
-	Name          *string              `json:"name"`           // Optional name update.
-	MonthPrice    *float64             `json:"month_price"`    // Optional monthly price.
-	Description   *string              `json:"description"`    // Optional description.
-	SupportModels *json.RawMessage     `json:"support_models"` // Optional supported models payload.
-	UserGroupID   *models.UserGroupIDs `json:"user_group_id"`  // Optional included user group IDs.
-	Feature1      *string              `json:"feature1"`       // Optional feature line 1.
-	Feature2      *string              `json:"feature2"`       // Optional feature line 2.
-	Feature3      *string              `json:"feature3"`       // Optional feature line 3.
-	Feature4      *string              `json:"feature4"`       // Optional feature line 4.
-	SortOrder     *int                 `json:"sort_order"`     // Optional display order.
-	TotalQuota    *float64             `json:"total_quota"`    // Optional total quota.
-	DailyQuota    *float64             `json:"daily_quota"`    // Optional daily quota.
-	RateLimit     *int                 `json:"rate_limit"`     // Optional rate limit per second.
-	IsEnabled     *bool                `json:"is_enabled"`     // Optional active flag.
+	Name           *string              `json:"name"`             // Optional name update.
+	MonthPrice     *float64             `json:"month_price"`      // Optional monthly price.
+	Description    *string              `json:"description"`      // Optional description.
+	SupportModels  *json.RawMessage     `json:"support_models"`   // Optional supported models payload.
+	UserGroupID    *models.UserGroupIDs `json:"user_group_id"`    // Optional included user group IDs.
+	Feature1       *string              `json:"feature1"`         // Optional feature line 1.
+	Feature2       *string              `json:"feature2"`         // Optional feature line 2.
+	Feature3       *string              `json:"feature3"`         // Optional feature line 3.
+	Feature4       *string              `json:"feature4"`         // Optional feature line 4.
+	SortOrder      *int                 `json:"sort_order"`       // Optional display order.
+	TotalQuota     *float64             `json:"total_quota"`      // Optional total quota.
+	DailyQuota     *float64             `json:"daily_quota"`      // Optional daily quota.
+	RateLimit      *int                 `json:"rate_limit"`       // Optional rate limit per second.
+	AlertAtPercent *int                 `json:"alert_at_percent"` // Optional usage alert percent.
+	IsEnabled      *bool                `json:"is_enabled"`       // Optional active flag.
 }
 
 // Update validates and applies plan field updates.
 func (h *PlanHandler) Update(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	var body updatePlanRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	var existing models.Plan
 	if errFind := h.db.WithContext(c.Request.Context()).First(&existing, id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			apierror.Write(c, apierror.NotFound("not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		apierror.Write(c, apierror.Internal("query failed"))
 		return
 	}
 
@@ -237,7 +245,7 @@ func (h *PlanHandler) Update(c *gin.Context) {
 	if body.Name != nil {
 		n := strings.TrimSpace(*body.Name)
 		if n == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			apierror.Write(c, apierror.Validation("name", "name cannot be empty"))
 			return
 		}
 		updates["name"] = n
@@ -251,7 +259,7 @@ func (h *PlanHandler) Update(c *gin.Context) {
 	if body.SupportModels != nil {
 		supportModels, errSupportModels := normalizePlanSupportModels(*body.SupportModels)
 		if errSupportModels != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid support_models"})
+			apierror.Write(c, apierror.Validation("support_models", "invalid support_models"))
 			return
 		}
 		updates["support_models"] = supportModels
@@ -283,13 +291,20 @@ func (h *PlanHandler) Update(c *gin.Context) {
 	if body.RateLimit != nil {
 		updates["rate_limit"] = *body.RateLimit
 	}
+	if body.AlertAtPercent != nil {
+		if *body.AlertAtPercent < 0 || *body.AlertAtPercent > 100 {
+			apierror.Write(c, apierror.Validation("alert_at_percent", "alert_at_percent must be between 0 and 100"))
+			return
+		}
+		updates["alert_at_percent"] = *body.AlertAtPercent
+	}
 	if body.IsEnabled != nil {
 		updates["is_enabled"] = *body.IsEnabled
 	}
 
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Plan{}).Where("id = ?", id).Updates(updates)
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -299,16 +314,16 @@ func (h *PlanHandler) Update(c *gin.Context) {
 func (h *PlanHandler) Delete(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 	res := h.db.WithContext(c.Request.Context()).Delete(&models.Plan{}, id)
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		apierror.Write(c, apierror.Internal("delete failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -328,7 +343,7 @@ func (h *PlanHandler) Disable(c *gin.Context) {
 func (h *PlanHandler) setEnabled(c *gin.Context, enabled bool) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
@@ -336,11 +351,11 @@ func (h *PlanHandler) setEnabled(c *gin.Context, enabled bool) {
 	res := h.db.WithContext(c.Request.Context()).Model(&models.Plan{}).Where("id = ?", id).
 		Updates(map[string]any{"is_enabled": enabled, "updated_at": now})
 	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		apierror.Write(c, apierror.Internal("update failed"))
 		return
 	}
 	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		apierror.Write(c, apierror.NotFound("not found"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -349,22 +364,23 @@ func (h *PlanHandler) setEnabled(c *gin.Context, enabled bool) {
 // formatPlan converts a plan model into a response payload.
 func (h *PlanHandler) formatPlan(p *models.Plan) gin.H {
 	return gin.H{
-		"id":             p.ID,
-		"name":           p.Name,
-		"month_price":    p.MonthPrice,
-		"description":    p.Description,
-		"support_models": p.SupportModels,
-		"user_group_id":  p.UserGroupID.Clean(),
-		"feature1":       p.Feature1,
-		"feature2":       p.Feature2,
-		"feature3":       p.Feature3,
-		"feature4":       p.Feature4,
-		"sort_order":     p.SortOrder,
-		"total_quota":    p.TotalQuota,
-		"daily_quota":    p.DailyQuota,
-		"rate_limit":     p.RateLimit,
-		"is_enabled":     p.IsEnabled,
-		"created_at":     p.CreatedAt,
-		"updated_at":     p.UpdatedAt,
+		"id":               p.ID,
+		"name":             p.Name,
+		"month_price":      p.MonthPrice,
+		"description":      p.Description,
+		"support_models":   p.SupportModels,
+		"user_group_id":    p.UserGroupID.Clean(),
+		"feature1":         p.Feature1,
+		"feature2":         p.Feature2,
+		"feature3":         p.Feature3,
+		"feature4":         p.Feature4,
+		"sort_order":       p.SortOrder,
+		"total_quota":      p.TotalQuota,
+		"daily_quota":      p.DailyQuota,
+		"rate_limit":       p.RateLimit,
+		"alert_at_percent": p.AlertAtPercent,
+		"is_enabled":       p.IsEnabled,
+		"created_at":       p.CreatedAt,
+		"updated_at":       p.UpdatedAt,
 	}
 }
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/csv"
 	"errors"
 	"net/http"
 	"strconv"
@@ -105,6 +107,7 @@ type batchCreatePrepaidCardRequest struct {
 	Amount         float64 `json:"amount"`          // Amount to assign to each card.
 	Count          int     `json:"count"`           // Number of cards to create.
 	CardSNPrefix   string  `json:"card_sn_prefix"`  // Optional card serial prefix.
+	Format         string  `json:"format"`          // Response format: "json" (default) or "csv".
 	PasswordLength int     `json:"password_length"` // Length of generated passwords.
 	UserGroupID    *uint64 `json:"user_group_id"`   // Optional user group constraint.
 	ValidDays      *int    `json:"valid_days"`      // Optional validity period in days.
@@ -150,6 +153,15 @@ func (h *PrepaidCardHandler) BatchCreate(c *gin.Context) {
 	}
 
 	prefix := strings.TrimSpace(body.CardSNPrefix)
+	if len(prefix) > maxCardSNPrefixLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "card_sn_prefix is too long"})
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(body.Format))
+	if format != "" && format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
 	isEnabled := true
 	if body.IsEnabled != nil {
 		isEnabled = *body.IsEnabled
@@ -160,20 +172,20 @@ func (h *PrepaidCardHandler) BatchCreate(c *gin.Context) {
 		userGroupID = &idCopy
 	}
 	now := time.Now().UTC()
-	created := make([]gin.H, 0, body.Count)
+	cards := make([]models.PrepaidCard, 0, body.Count)
 	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
-		for i := 0; i < body.Count; i++ {
-			cardSN, errSN := generateCode(16)
-			if errSN != nil {
-				return errSN
-			}
+		cardSNs, errSNs := generateUniqueCardSNs(tx, prefix, body.Count)
+		if errSNs != nil {
+			return errSNs
+		}
+		for _, cardSN := range cardSNs {
 			password, errPass := generateCode(passwordLength)
 			if errPass != nil {
 				return errPass
 			}
 			card := models.PrepaidCard{
 				Name:        name,
-				CardSN:      prefix + cardSN,
+				CardSN:      cardSN,
 				Password:    password,
 				Amount:      body.Amount,
 				Balance:     body.Amount,
@@ -185,7 +197,7 @@ func (h *PrepaidCardHandler) BatchCreate(c *gin.Context) {
 			if errCreate := tx.Create(&card).Error; errCreate != nil {
 				return errCreate
 			}
-			created = append(created, h.formatCard(&card))
+			cards = append(cards, card)
 		}
 		return nil
 	})
@@ -193,11 +205,69 @@ func (h *PrepaidCardHandler) BatchCreate(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "batch create prepaid cards failed"})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"prepaid_cards": created})
+
+	if format == "csv" {
+		records := make([][]string, 0, len(cards)+1)
+		records = append(records, []string{"card_sn", "password", "name", "amount", "valid_days"})
+		for _, card := range cards {
+			records = append(records, []string{
+				card.CardSN,
+				card.Password,
+				card.Name,
+				strconv.FormatFloat(card.Amount, 'f', -1, 64),
+				strconv.Itoa(card.ValidDays),
+			})
+		}
+		writeCSVAttachment(c, http.StatusCreated, "prepaid-cards-"+now.Format("20060102150405")+".csv", records)
+		return
+	}
+	created := make([]gin.H, 0, len(cards))
+	cardSNs := make([]string, 0, len(cards))
+	for i := range cards {
+		created = append(created, h.formatCard(&cards[i]))
+		cardSNs = append(cardSNs, cards[i].CardSN)
+	}
+	c.JSON(http.StatusCreated, gin.H{"prepaid_cards": created, "card_sns": cardSNs})
 }
 
 // List returns prepaid cards filtered by query parameters.
 func (h *PrepaidCardHandler) List(c *gin.Context) {
+	var rows []models.PrepaidCard
+	if errFind := h.filteredCards(c).Order("created_at DESC").Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list prepaid cards failed"})
+		return
+	}
+	out := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, h.formatCard(&row))
+	}
+	c.JSON(http.StatusOK, gin.H{"prepaid_cards": out})
+}
+
+// Export downloads the prepaid cards matching the list filters as CSV.
+func (h *PrepaidCardHandler) Export(c *gin.Context) {
+	var rows []models.PrepaidCard
+	if errFind := h.filteredCards(c).Order("created_at DESC, id DESC").Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export prepaid cards failed"})
+		return
+	}
+	records := make([][]string, 0, len(rows)+1)
+	records = append(records, []string{"card_sn", "name", "amount", "valid_days", "created_at", "redeemed"})
+	for _, row := range rows {
+		records = append(records, []string{
+			row.CardSN,
+			row.Name,
+			strconv.FormatFloat(row.Amount, 'f', -1, 64),
+			strconv.Itoa(row.ValidDays),
+			row.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatBool(row.RedeemedAt != nil),
+		})
+	}
+	writeCSVAttachment(c, http.StatusOK, "prepaid-cards.csv", records)
+}
+
+// filteredCards applies the name, card_sn, redeemed and redeemed_user query filters.
+func (h *PrepaidCardHandler) filteredCards(c *gin.Context) *gorm.DB {
 	var (
 		nameQ         = strings.TrimSpace(c.Query("name"))
 		cardSNQ       = strings.TrimSpace(c.Query("card_sn"))
@@ -226,17 +296,7 @@ func (h *PrepaidCardHandler) List(c *gin.Context) {
 	} else if redeemedQ == "false" || redeemedQ == "0" {
 		q = q.Where("redeemed_at IS NULL")
 	}
-
-	var rows []models.PrepaidCard
-	if errFind := q.Order("created_at DESC").Find(&rows).Error; errFind != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list prepaid cards failed"})
-		return
-	}
-	out := make([]gin.H, 0, len(rows))
-	for _, row := range rows {
-		out = append(out, h.formatCard(&row))
-	}
-	c.JSON(http.StatusOK, gin.H{"prepaid_cards": out})
+	return q
 }
 
 // Get fetches a single prepaid card by ID.
@@ -408,6 +468,68 @@ func (h *PrepaidCardHandler) formatCard(card *models.PrepaidCard) gin.H {
 	return item
 }
 
+const (
+	// cardSNRandomLength is the number of random characters after the card serial prefix.
+	cardSNRandomLength = 16
+	// maxCardSNPrefixLength bounds the optional batch card serial prefix.
+	maxCardSNPrefixLength = 32
+	// maxCardSNAttempts bounds regeneration rounds when serials collide.
+	maxCardSNAttempts = 5
+)
+
+// generateUniqueCardSNs returns count distinct prefixed serials that no existing card uses.
+func generateUniqueCardSNs(tx *gorm.DB, prefix string, count int) ([]string, error) {
+	out := make([]string, 0, count)
+	seen := make(map[string]struct{}, count)
+	for attempt := 0; attempt < maxCardSNAttempts && len(out) < count; attempt++ {
+		candidates := make([]string, 0, count-len(out))
+		for len(candidates) < count-len(out) {
+			code, errCode := generateCode(cardSNRandomLength)
+			if errCode != nil {
+				return nil, errCode
+			}
+			cardSN := prefix + code
+			if _, dup := seen[cardSN]; dup {
+				continue
+			}
+			seen[cardSN] = struct{}{}
+			candidates = append(candidates, cardSN)
+		}
+		var taken []string
+		if errFind := tx.Model(&models.PrepaidCard{}).
+			Where("card_sn IN ?", candidates).
+			Pluck("card_sn", &taken).Error; errFind != nil {
+			return nil, errFind
+		}
+		takenSet := make(map[string]struct{}, len(taken))
+		for _, cardSN := range taken {
+			takenSet[cardSN] = struct{}{}
+		}
+		for _, cardSN := range candidates {
+			if _, exists := takenSet[cardSN]; !exists {
+				out = append(out, cardSN)
+			}
+		}
+	}
+	if len(out) < count {
+		return nil, errors.New("generate unique card serials failed")
+	}
+	return out, nil
+}
+
+// writeCSVAttachment writes records as a downloadable CSV file with the given status.
+func writeCSVAttachment(c *gin.Context, status int, filename string, records [][]string) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if errWrite := writer.WriteAll(records); errWrite != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "write csv failed"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/csv; charset=utf-8", buf.Bytes())
+}
+
 // generateCode returns a random uppercase token of the requested length.
 func generateCode(length int) (string, error) {
 	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestPrepaidCardBatchCreateAndExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:prepaidcards_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.PrepaidCard{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	h := NewPrepaidCardHandler(db)
+	r := gin.New()
+	r.POST("/prepaid-cards/batch", h.BatchCreate)
+	r.GET("/prepaid-cards/export", h.Export)
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "/prepaid-cards/batch", `{"name":"promo","amount":5,"count":3,"card_sn_prefix":"PROMO-"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		CardSNs []string `json:"card_sns"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &created); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if len(created.CardSNs) != 3 {
+		t.Fatalf("expected 3 card numbers inline, got %v", created.CardSNs)
+	}
+	for _, cardSN := range created.CardSNs {
+		if !strings.HasPrefix(cardSN, "PROMO-") || len(cardSN) != len("PROMO-")+cardSNRandomLength {
+			t.Fatalf("unexpected card number %q", cardSN)
+		}
+	}
+
+	w = send(http.MethodPost, "/prepaid-cards/batch", `{"name":"print","amount":10,"count":2,"format":"csv"}`)
+	if w.Code != http.StatusCreated || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected csv download, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, errRead := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if errRead != nil || len(records) != 3 || records[0][0] != "card_sn" || records[0][1] != "password" {
+		t.Fatalf("unexpected batch csv: %v (%v)", records, errRead)
+	}
+
+	now := time.Now().UTC()
+	if errRedeem := db.Model(&models.PrepaidCard{}).Where("card_sn = ?", created.CardSNs[0]).
+		Update("redeemed_at", now).Error; errRedeem != nil {
+		t.Fatalf("redeem: %v", errRedeem)
+	}
+	w = send(http.MethodGet, "/prepaid-cards/export?name=promo&redeemed=false", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	records, errRead = csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if errRead != nil {
+		t.Fatalf("read export: %v", errRead)
+	}
+	if want := []string{"card_sn", "name", "amount", "valid_days", "created_at", "redeemed"}; strings.Join(records[0], ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected header %v", records[0])
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected the two unredeemed promo cards, got %v", records)
+	}
+	for _, record := range records[1:] {
+		if record[1] != "promo" || record[2] != "5" || record[5] != "false" {
+			t.Fatalf("unexpected export row %v", record)
+		}
+	}
+}
+
+func TestGenerateUniqueCardSNsAreDistinct(t *testing.T) {
+	dsn := fmt.Sprintf("file:prepaidsn_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.PrepaidCard{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	cardSNs, errGenerate := generateUniqueCardSNs(db, "X", 200)
+	if errGenerate != nil {
+		t.Fatalf("generate: %v", errGenerate)
+	}
+	seen := make(map[string]struct{}, len(cardSNs))
+	for _, cardSN := range cardSNs {
+		if _, dup := seen[cardSN]; dup {
+			t.Fatalf("duplicate card number %q", cardSN)
+		}
+		seen[cardSN] = struct{}{}
+	}
+	if len(seen) != 200 {
+		t.Fatalf("expected 200 card numbers, got %d", len(seen))
+	}
+}
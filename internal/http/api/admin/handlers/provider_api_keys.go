@@ -14,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -25,6 +26,8 @@ const (
 	providerCodex  = "codex"
 	providerClaude = "claude"
 	providerOpenAI = "openai-compatibility"
+	providerQwen   = "qwen"
+	providerIFlow  = "iflow"
 )
 
 // providerAliases maps provider inputs to canonical identifiers.
@@ -41,6 +44,8 @@ var providerAliases = map[string]string{
 	"openai-chat-completion":    providerOpenAI,
 	"openai-chatcompletions":    providerOpenAI,
 	"openai-chat-completion-v1": providerOpenAI,
+	"qwen":                      providerQwen,
+	"iflow":                     providerIFlow,
 }
 
 // ProviderAPIKeyHandler manages admin CRUD for provider API keys.
@@ -82,6 +87,7 @@ type createProviderAPIKeyRequest struct {
 	Models         []modelAlias      `json:"models"`          // Model aliases.
 	ExcludedModels []string          `json:"excluded_models"` // Excluded models.
 	APIKeyEntries  []apiKeyEntry     `json:"api_key_entries"` // API key entries.
+	Tags           []string          `json:"tags"`            // Labels such as owner or team.
 }
 
 // updateProviderAPIKeyRequest captures optional fields for updates.
@@ -97,19 +103,20 @@ type updateProviderAPIKeyRequest struct {
 	Models         *[]modelAlias      `json:"models"`          // Optional model aliases.
 	ExcludedModels *[]string          `json:"excluded_models"` // Optional excluded models.
 	APIKeyEntries  *[]apiKeyEntry     `json:"api_key_entries"` // Optional API key entries.
+	Tags           *[]string          `json:"tags"`            // Optional labels; replaces existing tags.
 }
 
 // Create validates and inserts a provider API key record, then syncs config.
 func (h *ProviderAPIKeyHandler) Create(c *gin.Context) {
 	var body createProviderAPIKeyRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	provider := normalizeProvider(body.Provider)
 	if provider == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
+		apierror.Write(c, apierror.Validation("provider", "provider is required"))
 		return
 	}
 
@@ -117,7 +124,7 @@ func (h *ProviderAPIKeyHandler) Create(c *gin.Context) {
 	if proxyURL == "" && autoAssignProxyEnabled() {
 		assignedProxyURL, errAssignProxy := pickRandomProxyURL(c.Request.Context(), h.db)
 		if errAssignProxy != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "auto assign proxy failed"})
+			apierror.Write(c, apierror.Internal("auto assign proxy failed"))
 			return
 		}
 		if assignedProxyURL != "" {
@@ -140,22 +147,27 @@ func (h *ProviderAPIKeyHandler) Create(c *gin.Context) {
 
 	headersJSON, errHeaders := marshalJSON(body.Headers)
 	if errHeaders != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid headers"})
+		apierror.Write(c, apierror.Validation("headers", "invalid headers"))
 		return
 	}
 	modelsJSON, errModels := marshalJSON(body.Models)
 	if errModels != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid models"})
+		apierror.Write(c, apierror.Validation("models", "invalid models"))
 		return
 	}
 	excludedJSON, errExcluded := marshalJSON(body.ExcludedModels)
 	if errExcluded != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid excluded_models"})
+		apierror.Write(c, apierror.Validation("excluded_models", "invalid excluded_models"))
 		return
 	}
 	apiKeyEntriesJSON, errKeyEntries := marshalJSON(body.APIKeyEntries)
 	if errKeyEntries != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api_key_entries"})
+		apierror.Write(c, apierror.Validation("api_key_entries", "invalid api_key_entries"))
+		return
+	}
+	tags, errTags := models.NormalizeTags(body.Tags)
+	if errTags != nil {
+		apierror.Write(c, apierror.Validation("tags", errTags.Error()))
 		return
 	}
 
@@ -163,27 +175,29 @@ func (h *ProviderAPIKeyHandler) Create(c *gin.Context) {
 	row.Models = modelsJSON
 	row.ExcludedModels = excludedJSON
 	row.APIKeyEntries = apiKeyEntriesJSON
+	row.Tags = tags
 
 	normalizeProviderFields(&row)
 	if errValidate := validateProviderRow(&row); errValidate != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": errValidate.Error()})
+		apierror.Write(c, apierror.Validation("", errValidate.Error()))
 		return
 	}
 
 	if errCreate := h.db.WithContext(c.Request.Context()).Create(&row).Error; errCreate != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "create api key failed"})
+		apierror.Write(c, apierror.Internal("create api key failed"))
 		return
 	}
 
 	if errSync := h.syncSDKConfig(c.Request.Context()); errSync != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "sync config failed"})
+		apierror.Write(c, apierror.Internal("sync config failed"))
 		return
 	}
 
 	c.JSON(http.StatusCreated, formatProviderRow(&row))
 }
 
-// List returns provider API keys or provider options based on query flags.
+// List returns provider API keys or provider options based on query flags. tags accepts a
+// comma-separated list and matches keys carrying any of the tags.
 func (h *ProviderAPIKeyHandler) List(c *gin.Context) {
 	optionsQ := strings.TrimSpace(c.Query("options"))
 	if optionsQ == "1" || strings.EqualFold(optionsQ, "true") {
@@ -193,7 +207,7 @@ func (h *ProviderAPIKeyHandler) List(c *gin.Context) {
 			Select("provider", "models", "excluded_models").
 			Order("provider ASC, id ASC").
 			Find(&rows).Error; errFind != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "list api key providers failed"})
+			apierror.Write(c, apierror.Internal("list api key providers failed"))
 			return
 		}
 
@@ -261,7 +275,12 @@ func (h *ProviderAPIKeyHandler) List(c *gin.Context) {
 	keywordQ := strings.TrimSpace(c.Query("keyword"))
 
 	if rawProvider != "" && providerQ == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid provider"})
+		apierror.Write(c, apierror.Validation("provider", "invalid provider"))
+		return
+	}
+	tagsQ, errTags := parseTagsQuery(c.Query("tags"))
+	if errTags != nil {
+		apierror.Write(c, apierror.Validation("tags", errTags.Error()))
 		return
 	}
 
@@ -277,10 +296,14 @@ func (h *ProviderAPIKeyHandler) List(c *gin.Context) {
 			pattern,
 		)
 	}
+	if len(tagsQ) > 0 {
+		expr, args := dbutil.JSONArrayContainsAnyText(h.db, "tags", tagsQ)
+		q = q.Where(expr, args...)
+	}
 
 	var rows []models.ProviderAPIKey
 	if errFind := q.Order("created_at DESC").Find(&rows).Error; errFind != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list api keys failed"})
+		apierror.Write(c, apierror.Internal("list api keys failed"))
 		return
 	}
 
@@ -295,30 +318,30 @@ func (h *ProviderAPIKeyHandler) List(c *gin.Context) {
 func (h *ProviderAPIKeyHandler) Update(c *gin.Context) {
 	id, errID := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errID != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
 	var row models.ProviderAPIKey
 	if errFind := h.db.WithContext(c.Request.Context()).First(&row, "id = ?", id).Error; errFind != nil {
 		if errors.Is(errFind, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			apierror.Write(c, apierror.NotFound("api key not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch api key failed"})
+		apierror.Write(c, apierror.Internal("fetch api key failed"))
 		return
 	}
 
 	var body updateProviderAPIKeyRequest
 	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		apierror.Write(c, apierror.InvalidJSON())
 		return
 	}
 
 	if body.Provider != nil {
 		normalized := normalizeProvider(*body.Provider)
 		if normalized == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid provider"})
+			apierror.Write(c, apierror.Validation("provider", "invalid provider"))
 			return
 		}
 		row.Provider = normalized
@@ -344,7 +367,7 @@ func (h *ProviderAPIKeyHandler) Update(c *gin.Context) {
 	if body.Headers != nil {
 		headersJSON, errHeaders := marshalJSON(*body.Headers)
 		if errHeaders != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid headers"})
+			apierror.Write(c, apierror.Validation("headers", "invalid headers"))
 			return
 		}
 		row.Headers = headersJSON
@@ -352,7 +375,7 @@ func (h *ProviderAPIKeyHandler) Update(c *gin.Context) {
 	if body.Models != nil {
 		modelsJSON, errModels := marshalJSON(*body.Models)
 		if errModels != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid models"})
+			apierror.Write(c, apierror.Validation("models", "invalid models"))
 			return
 		}
 		row.Models = modelsJSON
@@ -360,7 +383,7 @@ func (h *ProviderAPIKeyHandler) Update(c *gin.Context) {
 	if body.ExcludedModels != nil {
 		excludedJSON, errExcluded := marshalJSON(*body.ExcludedModels)
 		if errExcluded != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid excluded_models"})
+			apierror.Write(c, apierror.Validation("excluded_models", "invalid excluded_models"))
 			return
 		}
 		row.ExcludedModels = excludedJSON
@@ -368,26 +391,34 @@ func (h *ProviderAPIKeyHandler) Update(c *gin.Context) {
 	if body.APIKeyEntries != nil {
 		apiKeyEntriesJSON, errEntries := marshalJSON(*body.APIKeyEntries)
 		if errEntries != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api_key_entries"})
+			apierror.Write(c, apierror.Validation("api_key_entries", "invalid api_key_entries"))
 			return
 		}
 		row.APIKeyEntries = apiKeyEntriesJSON
 	}
+	if body.Tags != nil {
+		tags, errTags := models.NormalizeTags(*body.Tags)
+		if errTags != nil {
+			apierror.Write(c, apierror.Validation("tags", errTags.Error()))
+			return
+		}
+		row.Tags = tags
+	}
 
 	normalizeProviderFields(&row)
 	if errValidate := validateProviderRow(&row); errValidate != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": errValidate.Error()})
+		apierror.Write(c, apierror.Validation("", errValidate.Error()))
 		return
 	}
 
 	row.UpdatedAt = time.Now().UTC()
 	if errSave := h.db.WithContext(c.Request.Context()).Save(&row).Error; errSave != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update api key failed"})
+		apierror.Write(c, apierror.Internal("update api key failed"))
 		return
 	}
 
 	if errSync := h.syncSDKConfig(c.Request.Context()); errSync != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "sync config failed"})
+		apierror.Write(c, apierror.Internal("sync config failed"))
 		return
 	}
 
@@ -398,23 +429,71 @@ func (h *ProviderAPIKeyHandler) Update(c *gin.Context) {
 func (h *ProviderAPIKeyHandler) Delete(c *gin.Context) {
 	id, errID := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errID != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		apierror.Write(c, apierror.InvalidID())
 		return
 	}
 
 	if errDelete := h.db.WithContext(c.Request.Context()).Delete(&models.ProviderAPIKey{}, "id = ?", id).Error; errDelete != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete api key failed"})
+		apierror.Write(c, apierror.Internal("delete api key failed"))
 		return
 	}
 
 	if errSync := h.syncSDKConfig(c.Request.Context()); errSync != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "sync config failed"})
+		apierror.Write(c, apierror.Internal("sync config failed"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"deleted": true})
 }
 
+// DeleteByID deletes a provider API key and syncs config. It runs approved
+// delete_provider_api_key pending actions.
+func (h *ProviderAPIKeyHandler) DeleteByID(ctx context.Context, id uint64) error {
+	if errDelete := h.db.WithContext(ctx).Delete(&models.ProviderAPIKey{}, "id = ?", id).Error; errDelete != nil {
+		return errDelete
+	}
+	return h.syncSDKConfig(ctx)
+}
+
+// Reactivate clears the degraded flag and failure count of a provider API key, typically
+// after the upstream key was replaced, and syncs config.
+func (h *ProviderAPIKeyHandler) Reactivate(c *gin.Context) {
+	id, errID := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errID != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return
+	}
+
+	var row models.ProviderAPIKey
+	if errFind := h.db.WithContext(c.Request.Context()).First(&row, "id = ?", id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("api key not found"))
+			return
+		}
+		apierror.Write(c, apierror.Internal("fetch api key failed"))
+		return
+	}
+
+	row.Degraded = false
+	row.AuthFailureCount = 0
+	row.DegradedAt = nil
+	row.UpdatedAt = time.Now().UTC()
+	if errSave := h.db.WithContext(c.Request.Context()).
+		Model(&row).
+		Select("degraded", "auth_failure_count", "degraded_at", "updated_at").
+		Updates(&row).Error; errSave != nil {
+		apierror.Write(c, apierror.Internal("reactivate api key failed"))
+		return
+	}
+
+	if errSync := h.syncSDKConfig(c.Request.Context()); errSync != nil {
+		apierror.Write(c, apierror.Internal("sync config failed"))
+		return
+	}
+
+	c.JSON(http.StatusOK, formatProviderRow(&row))
+}
+
 // syncSDKConfig rebuilds SDK config based on DB records and saves it.
 func (h *ProviderAPIKeyHandler) syncSDKConfig(ctx context.Context) error {
 	if h == nil || h.db == nil {
@@ -432,7 +511,7 @@ func (h *ProviderAPIKeyHandler) syncSDKConfig(ctx context.Context) error {
 	}
 
 	var rows []models.ProviderAPIKey
-	if errFind := h.db.WithContext(ctx).Order("id ASC").Find(&rows).Error; errFind != nil {
+	if errFind := h.db.WithContext(ctx).Where("degraded = ?", false).Order("id ASC").Find(&rows).Error; errFind != nil {
 		return errFind
 	}
 
@@ -514,6 +593,9 @@ func (h *ProviderAPIKeyHandler) syncSDKConfig(ctx context.Context) error {
 			if entry.BaseURL != "" && entry.Name != "" {
 				openAIProviders = append(openAIProviders, entry)
 			}
+		case providerQwen, providerIFlow:
+			// The SDK config has no Qwen/iFlow key sections; the DB watcher
+			// synthesizes these auths directly from the rows.
 		}
 	}
 
@@ -615,6 +697,17 @@ func validateProviderRow(row *models.ProviderAPIKey) error {
 		if strings.TrimSpace(row.APIKey) == "" {
 			return errors.New("api_key is required")
 		}
+	case providerQwen:
+		if strings.TrimSpace(row.APIKey) == "" {
+			return errors.New("api_key is required")
+		}
+	case providerIFlow:
+		if strings.TrimSpace(row.APIKey) == "" {
+			return errors.New("api_key is required")
+		}
+		if strings.TrimSpace(row.BaseURL) == "" {
+			return errors.New("base_url is required")
+		}
 	case providerOpenAI:
 		if strings.TrimSpace(row.Name) == "" {
 			return errors.New("name is required")
@@ -732,19 +825,23 @@ func formatProviderRow(row *models.ProviderAPIKey) gin.H {
 		return gin.H{}
 	}
 	return gin.H{
-		"id":              row.ID,
-		"provider":        row.Provider,
-		"name":            row.Name,
-		"priority":        row.Priority,
-		"api_key":         row.APIKey,
-		"prefix":          row.Prefix,
-		"base_url":        row.BaseURL,
-		"proxy_url":       row.ProxyURL,
-		"headers":         decodeHeaders(row.Headers),
-		"models":          decodeModels(row.Models),
-		"excluded_models": decodeExcludedModels(row.ExcludedModels),
-		"api_key_entries": decodeAPIKeyEntries(row.APIKeyEntries),
-		"created_at":      row.CreatedAt,
-		"updated_at":      row.UpdatedAt,
+		"id":                 row.ID,
+		"provider":           row.Provider,
+		"name":               row.Name,
+		"priority":           row.Priority,
+		"api_key":            row.APIKey,
+		"prefix":             row.Prefix,
+		"base_url":           row.BaseURL,
+		"proxy_url":          row.ProxyURL,
+		"headers":            decodeHeaders(row.Headers),
+		"models":             decodeModels(row.Models),
+		"excluded_models":    decodeExcludedModels(row.ExcludedModels),
+		"api_key_entries":    decodeAPIKeyEntries(row.APIKeyEntries),
+		"tags":               row.Tags,
+		"degraded":           row.Degraded,
+		"auth_failure_count": row.AuthFailureCount,
+		"degraded_at":        row.DegradedAt,
+		"created_at":         row.CreatedAt,
+		"updated_at":         row.UpdatedAt,
 	}
 }
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultQuotaHistoryRange is the window returned when from is omitted.
+	defaultQuotaHistoryRange = 7 * 24 * time.Hour
+	// defaultQuotaHistoryBucket is the bucket width used when bucket is omitted.
+	defaultQuotaHistoryBucket = time.Hour
+	// minQuotaHistoryBucket is the narrowest accepted bucket.
+	minQuotaHistoryBucket = time.Minute
+	// maxQuotaHistoryBuckets caps the number of points in one response.
+	maxQuotaHistoryBuckets = 2000
+)
+
+// quotaHistoryPoint is one chart point covering [Time, Time+bucket).
+type quotaHistoryPoint struct {
+	Time          time.Time  `json:"time"`
+	RemainingMin  float64    `json:"remaining_min"`
+	RemainingAvg  float64    `json:"remaining_avg"`
+	RemainingLast float64    `json:"remaining_last"`
+	Limit         float64    `json:"limit"`
+	ResetAt       *time.Time `json:"reset_at"`
+	Samples       int        `json:"samples"`
+}
+
+// History returns the quota snapshots of one auth grouped into fixed-width time buckets.
+// Snapshots are only stored when values change, so a bucket without samples repeats the
+// last known value with samples set to 0.
+func (h *QuotaHandler) History(c *gin.Context) {
+	authID, errParseID := strconv.ParseUint(c.Param("auth_id"), 10, 64)
+	if errParseID != nil || authID == 0 {
+		apierror.Write(c, apierror.InvalidID())
+		return
+	}
+
+	to := time.Now().UTC()
+	if raw := strings.TrimSpace(c.Query("to")); raw != "" {
+		parsed, errParse := time.Parse(time.RFC3339, raw)
+		if errParse != nil {
+			apierror.Write(c, apierror.Validation("to", "invalid to format, use RFC3339"))
+			return
+		}
+		to = parsed.UTC()
+	}
+	from := to.Add(-defaultQuotaHistoryRange)
+	if raw := strings.TrimSpace(c.Query("from")); raw != "" {
+		parsed, errParse := time.Parse(time.RFC3339, raw)
+		if errParse != nil {
+			apierror.Write(c, apierror.Validation("from", "invalid from format, use RFC3339"))
+			return
+		}
+		from = parsed.UTC()
+	}
+	if !from.Before(to) {
+		apierror.Write(c, apierror.Validation("from", "from must be before to"))
+		return
+	}
+	bucket := defaultQuotaHistoryBucket
+	if raw := strings.TrimSpace(c.Query("bucket")); raw != "" {
+		parsed, errParse := time.ParseDuration(raw)
+		if errParse != nil || parsed < minQuotaHistoryBucket {
+			apierror.Write(c, apierror.Validation("bucket", "bucket must be a duration of at least 1m, e.g. 15m or 1h"))
+			return
+		}
+		bucket = parsed
+	}
+	start := from.Truncate(bucket)
+	if to.Sub(start)/bucket >= maxQuotaHistoryBuckets {
+		apierror.Write(c, apierror.Validation("bucket", "too many buckets, widen bucket or narrow the range"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	var auth models.Auth
+	if errFind := h.db.WithContext(ctx).Select("id").Where("id = ?", authID).First(&auth).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("auth not found"))
+			return
+		}
+		apierror.Write(c, apierror.Internal("query auth failed"))
+		return
+	}
+
+	var seed []models.QuotaHistory
+	if errSeed := h.db.WithContext(ctx).
+		Where("auth_id = ? AND captured_at < ?", authID, start).
+		Order("captured_at DESC").
+		Limit(1).
+		Find(&seed).Error; errSeed != nil {
+		apierror.Write(c, apierror.Internal("query quota history failed"))
+		return
+	}
+	var rows []models.QuotaHistory
+	if errRows := h.db.WithContext(ctx).
+		Where("auth_id = ? AND captured_at >= ? AND captured_at <= ?", authID, start, to).
+		Order("captured_at ASC").
+		Find(&rows).Error; errRows != nil {
+		apierror.Write(c, apierror.Internal("query quota history failed"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"auth_id":        authID,
+		"from":           start,
+		"to":             to,
+		"bucket_seconds": int64(bucket / time.Second),
+		"points":         bucketQuotaHistory(seed, rows, start, to, bucket),
+	})
+}
+
+// bucketQuotaHistory groups rows into buckets starting at start. Leading buckets before
+// the first known value are omitted; later empty buckets carry the last value forward.
+func bucketQuotaHistory(seed, rows []models.QuotaHistory, start, to time.Time, bucket time.Duration) []quotaHistoryPoint {
+	var last *models.QuotaHistory
+	if len(seed) > 0 {
+		last = &seed[0]
+	}
+	points := make([]quotaHistoryPoint, 0)
+	next := 0
+	for bucketStart := start; !bucketStart.After(to); bucketStart = bucketStart.Add(bucket) {
+		bucketEnd := bucketStart.Add(bucket)
+		point := quotaHistoryPoint{Time: bucketStart}
+		sum := 0.0
+		for next < len(rows) && rows[next].CapturedAt.Before(bucketEnd) {
+			row := rows[next]
+			if point.Samples == 0 || row.Remaining < point.RemainingMin {
+				point.RemainingMin = row.Remaining
+			}
+			sum += row.Remaining
+			point.Samples++
+			last = &rows[next]
+			next++
+		}
+		if last == nil {
+			continue
+		}
+		if point.Samples > 0 {
+			point.RemainingAvg = sum / float64(point.Samples)
+		} else {
+			point.RemainingMin = last.Remaining
+			point.RemainingAvg = last.Remaining
+		}
+		point.RemainingLast = last.Remaining
+		point.Limit = last.Limit
+		point.ResetAt = last.ResetAt
+		points = append(points, point)
+	}
+	return points
+}
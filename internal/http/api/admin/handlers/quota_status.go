@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// quotaStatus is the capacity state derived from a stored quota payload.
+type quotaStatus struct {
+	Exhausted bool       // No capacity remains until RecoverAt.
+	RecoverAt *time.Time // When capacity returns; nil when not exhausted or unknown.
+}
+
+// deriveQuotaStatus computes whether the auth behind a quota payload is out of capacity.
+// Codex windows gate every request, so the auth recovers when the last exhausted window
+// resets. Gemini CLI and Antigravity buckets are per model, so the auth is exhausted only
+// when every bucket is and recovers when the first one resets. Resets already in the past
+// mean the stored payload is stale and the quota has been replenished.
+func deriveQuotaStatus(quotaType string, data datatypes.JSON, fetchedAt, now time.Time) quotaStatus {
+	if len(data) == 0 {
+		return quotaStatus{}
+	}
+	if isAntigravityType(quotaType) {
+		data = normalizeAntigravityQuota(data)
+	}
+	var payload map[string]any
+	if errUnmarshal := json.Unmarshal(data, &payload); errUnmarshal != nil {
+		return quotaStatus{}
+	}
+
+	var status quotaStatus
+	if rateLimit, ok := payload["rate_limit"].(map[string]any); ok {
+		status = codexQuotaStatus(rateLimit, fetchedAt)
+	} else if buckets, ok := payload["buckets"].([]any); ok {
+		status = bucketQuotaStatus(buckets)
+	}
+	if status.Exhausted && status.RecoverAt != nil && !status.RecoverAt.After(now) {
+		return quotaStatus{}
+	}
+	return status
+}
+
+// codexQuotaStatus reads the Codex usage rate_limit object.
+func codexQuotaStatus(rateLimit map[string]any, fetchedAt time.Time) quotaStatus {
+	limitReached, _ := rateLimit["limit_reached"].(bool)
+	if allowed, ok := rateLimit["allowed"].(bool); ok && !allowed {
+		limitReached = true
+	}
+	var recoverAt *time.Time
+	for _, key := range []string{"primary_window", "secondary_window"} {
+		window, ok := rateLimit[key].(map[string]any)
+		if !ok {
+			continue
+		}
+		used, okUsed := quotaNumber(window["used_percent"])
+		if !okUsed || used < 100 {
+			continue
+		}
+		limitReached = true
+		resetAt, okReset := codexWindowReset(window, fetchedAt)
+		if okReset && (recoverAt == nil || resetAt.After(*recoverAt)) {
+			recoverAt = &resetAt
+		}
+	}
+	if !limitReached {
+		return quotaStatus{}
+	}
+	return quotaStatus{Exhausted: true, RecoverAt: recoverAt}
+}
+
+// codexWindowReset returns the reset time of a Codex usage window.
+func codexWindowReset(window map[string]any, fetchedAt time.Time) (time.Time, bool) {
+	if resetAt, ok := quotaNumber(window["reset_at"]); ok && resetAt > 0 {
+		return time.Unix(int64(resetAt), 0).UTC(), true
+	}
+	if after, ok := quotaNumber(window["reset_after_seconds"]); ok && after >= 0 {
+		return fetchedAt.UTC().Add(time.Duration(after) * time.Second), true
+	}
+	return time.Time{}, false
+}
+
+// bucketQuotaStatus reads per-model quota buckets. A bucket with a reset time but no
+// remainingFraction is exhausted: the upstream omits zero values from its JSON.
+func bucketQuotaStatus(buckets []any) quotaStatus {
+	if len(buckets) == 0 {
+		return quotaStatus{}
+	}
+	var recoverAt *time.Time
+	for _, raw := range buckets {
+		bucket, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		resetAt, okReset := parseQuotaTime(bucket["resetTime"])
+		remaining, okRemaining := quotaNumber(bucket["remainingFraction"])
+		if okRemaining && remaining > 0 {
+			return quotaStatus{}
+		}
+		if !okRemaining && !okReset {
+			return quotaStatus{}
+		}
+		if okReset && (recoverAt == nil || resetAt.Before(*recoverAt)) {
+			recoverAt = &resetAt
+		}
+	}
+	return quotaStatus{Exhausted: true, RecoverAt: recoverAt}
+}
+
+// quotaNumber reads a JSON number or numeric string.
+func quotaNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		parsed, errParse := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return parsed, errParse == nil
+	default:
+		return 0, false
+	}
+}
+
+// parseQuotaTime reads an RFC 3339 timestamp.
+func parseQuotaTime(value any) (time.Time, bool) {
+	text, ok := value.(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return time.Time{}, false
+	}
+	parsed, errParse := time.Parse(time.RFC3339, strings.TrimSpace(text))
+	if errParse != nil {
+		return time.Time{}, false
+	}
+	return parsed.UTC(), true
+}
@@ -26,11 +26,14 @@ func NewQuotaHandler(db *gorm.DB) *QuotaHandler {
 
 // quotaListQuery defines filters for the quota list view.
 type quotaListQuery struct {
-	Page  int    `form:"page,default=1"`   // Page number.
-	Limit int    `form:"limit,default=12"` // Page size.
-	Key   string `form:"key"`              // Auth key filter.
-	Type  string `form:"type"`             // Auth type filter.
-	Group string `form:"auth_group_id"`    // Auth group filter.
+	Page      int    `form:"page,default=1"`   // Page number.
+	Limit     int    `form:"limit,default=12"` // Page size.
+	Key       string `form:"key"`              // Auth key filter.
+	Type      string `form:"type"`             // Auth type filter.
+	Provider  string `form:"provider"`         // Alias of type.
+	Group     string `form:"auth_group_id"`    // Auth group filter.
+	Exhausted string `form:"exhausted"`        // Derived exhaustion filter: true or false.
+	Sort      string `form:"sort"`             // "recover_at" orders by soonest recovery.
 }
 
 // quotaListRow defines the query result row for quota list.
@@ -41,9 +44,21 @@ type quotaListRow struct {
 	Data      datatypes.JSON `gorm:"column:data"`
 	UpdatedAt time.Time      `gorm:"column:updated_at"`
 	AuthKey   string         `gorm:"column:auth_key"`
+
+	status quotaStatus
+}
+
+// quotaTypeSummary counts quota rows and exhausted auths for one provider type.
+type quotaTypeSummary struct {
+	Type      string `json:"type"`
+	Total     int    `json:"total"`
+	Exhausted int    `json:"exhausted"`
 }
 
-// List returns quota records with paging and filters.
+// List returns quota records with paging and filters. Every row carries the derived
+// exhausted and recover_at fields, and summary counts exhausted auths per provider type.
+// Because exhaustion is derived from the stored payload, filtering on it and sorting by
+// recovery happen after the rows are loaded.
 func (h *QuotaHandler) List(c *gin.Context) {
 	var q quotaListQuery
 	if errBind := c.ShouldBindQuery(&q); errBind != nil {
@@ -59,6 +74,9 @@ func (h *QuotaHandler) List(c *gin.Context) {
 
 	keyQ := strings.TrimSpace(q.Key)
 	typeQ := strings.TrimSpace(q.Type)
+	if typeQ == "" {
+		typeQ = strings.TrimSpace(q.Provider)
+	}
 	groupQ := strings.TrimSpace(q.Group)
 	var groupID uint64
 	if groupQ != "" {
@@ -69,59 +87,97 @@ func (h *QuotaHandler) List(c *gin.Context) {
 		}
 		groupID = parsed
 	}
+	var exhaustedFilter *bool
+	if raw := strings.TrimSpace(q.Exhausted); raw != "" {
+		parsed, errParse := strconv.ParseBool(raw)
+		if errParse != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid exhausted"})
+			return
+		}
+		exhaustedFilter = &parsed
+	}
+	sortQ := strings.TrimSpace(q.Sort)
+	if sortQ != "" && sortQ != "recover_at" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort"})
+		return
+	}
 
 	ctx := c.Request.Context()
 
+	// Type is filtered in memory so types and summary still cover every provider.
 	base := h.db.WithContext(ctx).
 		Table("quota").
-		Joins("JOIN auths ON auths.id = quota.auth_id")
+		Joins("JOIN auths ON auths.id = quota.auth_id AND auths.deleted_at IS NULL")
 	if keyQ != "" {
 		pattern := dbutil.NormalizeLikePattern(h.db, "%"+keyQ+"%")
 		base = base.Where(dbutil.CaseInsensitiveLikeExpr(h.db, "auths.key"), pattern)
 	}
-	if typeQ != "" {
-		base = base.Where("quota.type = ?", typeQ)
-	}
 	if groupID > 0 {
 		base = base.Where(dbutil.JSONArrayContainsExpr(h.db, "auths.auth_group_id"), dbutil.JSONArrayContainsValue(h.db, groupID))
 	}
 
-	var total int64
-	if errCount := base.Count(&total).Error; errCount != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "count quotas failed"})
-		return
-	}
-
-	typeQuery := h.db.WithContext(ctx).
-		Table("quota").
-		Joins("JOIN auths ON auths.id = quota.auth_id")
-	if keyQ != "" {
-		pattern := dbutil.NormalizeLikePattern(h.db, "%"+keyQ+"%")
-		typeQuery = typeQuery.Where(dbutil.CaseInsensitiveLikeExpr(h.db, "auths.key"), pattern)
-	}
-	if groupID > 0 {
-		typeQuery = typeQuery.Where(dbutil.JSONArrayContainsExpr(h.db, "auths.auth_group_id"), dbutil.JSONArrayContainsValue(h.db, groupID))
-	}
-	var types []string
-	if errTypes := typeQuery.Distinct("quota.type").Order("quota.type ASC").Pluck("quota.type", &types).Error; errTypes != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list quota types failed"})
-		return
-	}
-
-	offset := (q.Page - 1) * q.Limit
 	var rows []quotaListRow
 	if errFind := base.
 		Select("quota.id, quota.auth_id, quota.type, quota.data, quota.updated_at, auths.key AS auth_key").
 		Order("auths.id ASC, quota.updated_at DESC").
-		Offset(offset).
-		Limit(q.Limit).
 		Scan(&rows).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "list quotas failed"})
 		return
 	}
 
-	out := make([]gin.H, 0, len(rows))
+	now := time.Now().UTC()
+	summaryByType := make(map[string]*quotaTypeSummary)
+	filtered := make([]quotaListRow, 0, len(rows))
 	for _, row := range rows {
+		row.status = deriveQuotaStatus(row.Type, row.Data, row.UpdatedAt, now)
+		summary := summaryByType[row.Type]
+		if summary == nil {
+			summary = &quotaTypeSummary{Type: row.Type}
+			summaryByType[row.Type] = summary
+		}
+		summary.Total++
+		if row.status.Exhausted {
+			summary.Exhausted++
+		}
+		if typeQ != "" && row.Type != typeQ {
+			continue
+		}
+		if exhaustedFilter != nil && row.status.Exhausted != *exhaustedFilter {
+			continue
+		}
+		filtered = append(filtered, row)
+	}
+
+	types := make([]string, 0, len(summaryByType))
+	for quotaType := range summaryByType {
+		types = append(types, quotaType)
+	}
+	sort.Strings(types)
+	summary := make([]quotaTypeSummary, 0, len(types))
+	for _, quotaType := range types {
+		summary = append(summary, *summaryByType[quotaType])
+	}
+
+	if sortQ == "recover_at" {
+		// Exhausted rows with a known recovery come first, soonest first; rows that never
+		// recover on a known schedule follow, then rows with capacity left.
+		sort.SliceStable(filtered, func(i, k int) bool {
+			return quotaRecoverRank(filtered[i].status).Before(quotaRecoverRank(filtered[k].status))
+		})
+	}
+
+	total := int64(len(filtered))
+	offset := (q.Page - 1) * q.Limit
+	if offset > len(filtered) {
+		offset = len(filtered)
+	}
+	end := offset + q.Limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+
+	out := make([]gin.H, 0, end-offset)
+	for _, row := range filtered[offset:end] {
 		payload := row.Data
 		if isAntigravityType(row.Type) {
 			payload = normalizeAntigravityQuota(row.Data)
@@ -133,18 +189,33 @@ func (h *QuotaHandler) List(c *gin.Context) {
 			"type":       row.Type,
 			"data":       payload,
 			"updated_at": row.UpdatedAt,
+			"exhausted":  row.status.Exhausted,
+			"recover_at": row.status.RecoverAt,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"quotas": out,
-		"types":  types,
-		"total":  total,
-		"page":   q.Page,
-		"limit":  q.Limit,
+		"quotas":  out,
+		"types":   types,
+		"summary": summary,
+		"total":   total,
+		"page":    q.Page,
+		"limit":   q.Limit,
 	})
 }
 
+// quotaRecoverRank maps a status to a sortable time for the recover_at ordering.
+func quotaRecoverRank(status quotaStatus) time.Time {
+	switch {
+	case status.Exhausted && status.RecoverAt != nil:
+		return *status.RecoverAt
+	case status.Exhausted:
+		return time.Date(9998, 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
 func isAntigravityType(value string) bool {
 	return strings.Contains(strings.ToLower(strings.TrimSpace(value)), "antigravity")
 }
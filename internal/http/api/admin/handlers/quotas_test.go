@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestDeriveQuotaStatus(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	soon := now.Add(time.Hour)
+	later := now.Add(5 * time.Hour)
+	cases := []struct {
+		name      string
+		quotaType string
+		data      string
+		exhausted bool
+		recoverAt *time.Time
+	}{
+		{
+			name:      "codex with capacity",
+			quotaType: "codex",
+			data:      `{"rate_limit":{"allowed":true,"limit_reached":false,"primary_window":{"used_percent":40,"reset_at":1}}}`,
+		},
+		{
+			name:      "codex recovers when the last exhausted window resets",
+			quotaType: "codex",
+			data:      fmt.Sprintf(`{"rate_limit":{"limit_reached":true,"primary_window":{"used_percent":100,"reset_at":%d},"secondary_window":{"used_percent":100,"reset_at":%d}}}`, soon.Unix(), later.Unix()),
+			exhausted: true,
+			recoverAt: &later,
+		},
+		{
+			name:      "codex reset_after_seconds is relative to the fetch",
+			quotaType: "codex",
+			data:      `{"rate_limit":{"primary_window":{"used_percent":100,"reset_after_seconds":3600}}}`,
+			exhausted: true,
+			recoverAt: &soon,
+		},
+		{
+			name:      "stale codex payload already reset",
+			quotaType: "codex",
+			data:      fmt.Sprintf(`{"rate_limit":{"limit_reached":true,"primary_window":{"used_percent":100,"reset_at":%d}}}`, now.Add(-time.Minute).Unix()),
+		},
+		{
+			name:      "gemini with one bucket left",
+			quotaType: "gemini-cli",
+			data:      fmt.Sprintf(`{"buckets":[{"remainingFraction":0,"resetTime":%q},{"remainingFraction":0.3,"resetTime":%q}]}`, soon.Format(time.RFC3339), later.Format(time.RFC3339)),
+		},
+		{
+			name:      "gemini recovers when the first bucket resets",
+			quotaType: "gemini-cli",
+			data:      fmt.Sprintf(`{"buckets":[{"resetTime":%q},{"remainingFraction":0,"resetTime":%q}]}`, later.Format(time.RFC3339), soon.Format(time.RFC3339)),
+			exhausted: true,
+			recoverAt: &soon,
+		},
+		{
+			name:      "antigravity models are normalized first",
+			quotaType: "antigravity",
+			data:      fmt.Sprintf(`{"models":{"gemini-3-flash":{"modelProvider":"google","quotaInfo":{"resetTime":%q}}}}`, soon.Format(time.RFC3339)),
+			exhausted: true,
+			recoverAt: &soon,
+		},
+		{
+			name:      "unknown payload",
+			quotaType: "codex",
+			data:      `"not json object"`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := deriveQuotaStatus(tc.quotaType, datatypes.JSON(tc.data), now, now)
+			if got.Exhausted != tc.exhausted {
+				t.Fatalf("exhausted: expected %t, got %t", tc.exhausted, got.Exhausted)
+			}
+			switch {
+			case tc.recoverAt == nil && got.RecoverAt != nil:
+				t.Fatalf("expected no recover_at, got %s", got.RecoverAt)
+			case tc.recoverAt != nil && (got.RecoverAt == nil || !got.RecoverAt.Equal(*tc.recoverAt)):
+				t.Fatalf("expected recover_at %s, got %v", tc.recoverAt, got.RecoverAt)
+			}
+		})
+	}
+}
+
+func TestQuotaListDerivesExhaustionAndSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:quotas_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}, &models.Quota{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	now := time.Now().UTC()
+	exhaustedCodex := func(reset time.Time) string {
+		return fmt.Sprintf(`{"rate_limit":{"limit_reached":true,"primary_window":{"used_percent":100,"reset_at":%d}}}`, reset.Unix())
+	}
+	fixtures := []struct {
+		key       string
+		quotaType string
+		data      string
+	}{
+		{"codex-late.json", "codex", exhaustedCodex(now.Add(3 * time.Hour))},
+		{"codex-ok.json", "codex", `{"rate_limit":{"allowed":true,"primary_window":{"used_percent":5}}}`},
+		{"codex-soon.json", "codex", exhaustedCodex(now.Add(time.Hour))},
+		{"gemini-ok.json", "gemini-cli", `{"buckets":[{"remainingFraction":1}]}`},
+	}
+	for _, fixture := range fixtures {
+		auth := models.Auth{Key: fixture.key, Content: datatypes.JSON(`{}`), IsAvailable: true}
+		if errCreate := db.Create(&auth).Error; errCreate != nil {
+			t.Fatalf("create auth: %v", errCreate)
+		}
+		row := models.Quota{AuthID: auth.ID, Type: fixture.quotaType, Data: datatypes.JSON(fixture.data)}
+		if errCreate := db.Create(&row).Error; errCreate != nil {
+			t.Fatalf("create quota: %v", errCreate)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/v0/admin/quotas", NewQuotaHandler(db).List)
+	type listResponse struct {
+		Quotas []struct {
+			AuthKey   string     `json:"auth_key"`
+			Exhausted bool       `json:"exhausted"`
+			RecoverAt *time.Time `json:"recover_at"`
+		} `json:"quotas"`
+		Summary []quotaTypeSummary `json:"summary"`
+		Total   int64              `json:"total"`
+	}
+	list := func(query string) listResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/admin/quotas"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp listResponse
+		if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+			t.Fatalf("decode: %v", errDecode)
+		}
+		return resp
+	}
+
+	resp := list("?provider=codex&sort=recover_at")
+	if resp.Total != 3 || len(resp.Quotas) != 3 {
+		t.Fatalf("expected three codex rows, got %+v", resp)
+	}
+	order := []string{resp.Quotas[0].AuthKey, resp.Quotas[1].AuthKey, resp.Quotas[2].AuthKey}
+	if order[0] != "codex-soon.json" || order[1] != "codex-late.json" || order[2] != "codex-ok.json" {
+		t.Fatalf("expected soonest recovery first, got %v", order)
+	}
+	if !resp.Quotas[0].Exhausted || resp.Quotas[0].RecoverAt == nil || resp.Quotas[2].Exhausted {
+		t.Fatalf("unexpected derived fields: %+v", resp.Quotas)
+	}
+	// The summary ignores the provider filter so operators see every provider.
+	if len(resp.Summary) != 2 || resp.Summary[0] != (quotaTypeSummary{Type: "codex", Total: 3, Exhausted: 2}) ||
+		resp.Summary[1] != (quotaTypeSummary{Type: "gemini-cli", Total: 1, Exhausted: 0}) {
+		t.Fatalf("unexpected summary: %+v", resp.Summary)
+	}
+
+	resp = list("?exhausted=false&key=ok")
+	if resp.Total != 2 {
+		t.Fatalf("expected two non-exhausted rows, got %+v", resp)
+	}
+	resp = list("?exhausted=true&limit=1&page=2&sort=recover_at")
+	if resp.Total != 2 || len(resp.Quotas) != 1 || resp.Quotas[0].AuthKey != "codex-late.json" {
+		t.Fatalf("expected paging over exhausted rows, got %+v", resp)
+	}
+}
+
+func TestQuotaHistoryBucketsAndCarriesForward(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:quotahistory_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}, &models.QuotaHistory{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auth := models.Auth{Key: "a.json", Content: datatypes.JSON(`{"type":"gemini-cli"}`)}
+	if errCreate := db.Create(&auth).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []models.QuotaHistory{
+		{AuthID: auth.ID, CapturedAt: start.Add(-30 * time.Minute), Remaining: 95, Limit: 100},
+		{AuthID: auth.ID, CapturedAt: start.Add(70 * time.Minute), Remaining: 80, Limit: 100},
+		{AuthID: auth.ID, CapturedAt: start.Add(100 * time.Minute), Remaining: 60, Limit: 100},
+	}
+	if errCreate := db.Create(&rows).Error; errCreate != nil {
+		t.Fatalf("create history: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.GET("/v0/admin/quotas/:auth_id/history", NewQuotaHandler(db).History)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	path := fmt.Sprintf("/v0/admin/quotas/%d/history?from=%s&to=%s&bucket=1h", auth.ID, start.Format(time.RFC3339), start.Add(3*time.Hour).Format(time.RFC3339))
+	w := get(path)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var payload struct {
+		BucketSeconds int64               `json:"bucket_seconds"`
+		Points        []quotaHistoryPoint `json:"points"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &payload); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if payload.BucketSeconds != 3600 || len(payload.Points) != 4 {
+		t.Fatalf("expected 4 hourly points, got %+v", payload)
+	}
+	want := []struct {
+		min, avg, last float64
+		samples        int
+	}{
+		{95, 95, 95, 0},
+		{60, 70, 60, 2},
+		{60, 60, 60, 0},
+		{60, 60, 60, 0},
+	}
+	for i, point := range payload.Points {
+		if point.RemainingMin != want[i].min || point.RemainingAvg != want[i].avg || point.RemainingLast != want[i].last || point.Samples != want[i].samples {
+			t.Fatalf("point %d: unexpected %+v", i, point)
+		}
+	}
+
+	if w := get(fmt.Sprintf("/v0/admin/quotas/%d/history?bucket=1s", auth.ID)); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected tiny bucket to be rejected, got %d", w.Code)
+	}
+	if w := get("/v0/admin/quotas/999/history"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown auth to be 404, got %d", w.Code)
+	}
+}
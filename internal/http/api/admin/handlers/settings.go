@@ -1,13 +1,10 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"math"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -27,25 +24,15 @@ func NewSettingHandler(db *gorm.DB) *SettingHandler {
 	return &SettingHandler{db: db}
 }
 
+// unknownSettingWarning flags values stored under keys missing from the settings schema.
+const unknownSettingWarning = "unknown setting key; value stored without validation"
+
 // createSettingRequest captures the payload for creating a setting.
 type createSettingRequest struct {
 	Key   string          `json:"key"`   // Setting key.
 	Value json.RawMessage `json:"value"` // JSON value payload.
 }
 
-var positiveIntSettingKeys = map[string]struct{}{
-	internalsettings.QuotaPollIntervalSecondsKey: {},
-	internalsettings.QuotaPollMaxConcurrencyKey:  {},
-}
-
-var nonNegativeIntSettingKeys = map[string]struct{}{
-	internalsettings.RateLimitKey:        {},
-	internalsettings.RateLimitRedisDBKey: {},
-}
-
-var errPositiveIntegerValue = errors.New("value must be a positive integer")
-var errNonNegativeIntegerValue = errors.New("value must be a non-negative integer")
-
 // Create validates and inserts a setting, then refreshes the snapshot.
 func (h *SettingHandler) Create(c *gin.Context) {
 	var body createSettingRequest
@@ -60,7 +47,8 @@ func (h *SettingHandler) Create(c *gin.Context) {
 		return
 	}
 
-	if errValidate := validateSettingValue(key, body.Value); errValidate != nil {
+	known, errValidate := internalsettings.ValidateValue(key, body.Value)
+	if errValidate != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": errValidate.Error()})
 		return
 	}
@@ -84,7 +72,11 @@ func (h *SettingHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh settings snapshot failed"})
 		return
 	}
-	c.JSON(http.StatusCreated, h.formatSetting(&setting))
+	out := h.formatSetting(&setting)
+	if !known {
+		out["warning"] = unknownSettingWarning
+	}
+	c.JSON(http.StatusCreated, out)
 }
 
 // List returns all settings sorted by key.
@@ -138,7 +130,8 @@ func (h *SettingHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if errValidate := validateSettingValue(key, body.Value); errValidate != nil {
+	known, errValidate := internalsettings.ValidateValue(key, body.Value)
+	if errValidate != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": errValidate.Error()})
 		return
 	}
@@ -163,7 +156,11 @@ func (h *SettingHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh settings snapshot failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+	out := gin.H{"ok": true}
+	if !known {
+		out["warning"] = unknownSettingWarning
+	}
+	c.JSON(http.StatusOK, out)
 }
 
 // Delete removes a setting and refreshes the snapshot.
@@ -219,86 +216,17 @@ func (h *SettingHandler) refreshDBConfigSnapshot(ctx context.Context) error {
 	return nil
 }
 
-func validateSettingValue(key string, value json.RawMessage) error {
-	if _, ok := positiveIntSettingKeys[key]; !ok {
-		if _, okNonNegative := nonNegativeIntSettingKeys[key]; !okNonNegative {
-			return nil
-		}
-		if _, ok := parseNonNegativeInt(value); !ok {
-			return errNonNegativeIntegerValue
-		}
-		return nil
-	}
-	if _, ok := parsePositiveInt(value); !ok {
-		return errPositiveIntegerValue
-	}
-	return nil
-}
-
-func parsePositiveInt(raw json.RawMessage) (int, bool) {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return 0, false
-	}
-	var parsedInt int
-	if errUnmarshalInt := json.Unmarshal(raw, &parsedInt); errUnmarshalInt == nil {
-		return parsedInt, parsedInt > 0
-	}
-	var parsedString string
-	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
-		parsed, errParse := strconv.Atoi(strings.TrimSpace(parsedString))
-		if errParse != nil {
-			return 0, false
-		}
-		return parsed, parsed > 0
-	}
-	var parsedFloat float64
-	if errUnmarshalFloat := json.Unmarshal(raw, &parsedFloat); errUnmarshalFloat == nil {
-		if math.IsNaN(parsedFloat) || math.IsInf(parsedFloat, 0) {
-			return 0, false
-		}
-		if parsedFloat <= 0 || parsedFloat != math.Trunc(parsedFloat) {
-			return 0, false
-		}
-		return int(parsedFloat), true
-	}
-	return 0, false
-}
-
-func parseNonNegativeInt(raw json.RawMessage) (int, bool) {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return 0, false
-	}
-	var parsedInt int
-	if errUnmarshalInt := json.Unmarshal(raw, &parsedInt); errUnmarshalInt == nil {
-		return parsedInt, parsedInt >= 0
-	}
-	var parsedString string
-	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
-		parsed, errParse := strconv.Atoi(strings.TrimSpace(parsedString))
-		if errParse != nil {
-			return 0, false
-		}
-		return parsed, parsed >= 0
-	}
-	var parsedFloat float64
-	if errUnmarshalFloat := json.Unmarshal(raw, &parsedFloat); errUnmarshalFloat == nil {
-		if math.IsNaN(parsedFloat) || math.IsInf(parsedFloat, 0) {
-			return 0, false
-		}
-		if parsedFloat < 0 || parsedFloat != math.Trunc(parsedFloat) {
-			return 0, false
-		}
-		return int(parsedFloat), true
-	}
-	return 0, false
-}
-
 // formatSetting formats a setting row into response JSON.
 func (h *SettingHandler) formatSetting(s *models.Setting) gin.H {
 	return gin.H{
 		"key":   s.Key,
 		"value": s.Value,
+		"known": isKnownSetting(s.Key),
 	}
 }
+
+// isKnownSetting reports whether key has a schema entry.
+func isKnownSetting(key string) bool {
+	_, ok := internalsettings.LookupSpec(key)
+	return ok
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
+)
+
+// systemReloadTimeout bounds how long a reload request waits for the watcher.
+const systemReloadTimeout = 30 * time.Second
+
+// SystemHandler serves super-admin system maintenance endpoints.
+type SystemHandler struct {
+	reload func(context.Context) (watcher.ReloadResult, error)
+}
+
+// NewSystemHandler constructs a SystemHandler backed by the running watcher.
+func NewSystemHandler() *SystemHandler {
+	return &SystemHandler{reload: watcher.Reload}
+}
+
+// Reload forces the watcher to re-read the config file, provider keys, auths, settings
+// and payload rules immediately, and returns the subsystems that were reloaded.
+func (h *SystemHandler) Reload(c *gin.Context) {
+	if !c.GetBool("adminIsSuperAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only super admins can reload the system"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), systemReloadTimeout)
+	defer cancel()
+	result, errReload := h.reload(ctx)
+	if errReload != nil {
+		switch {
+		case errors.Is(errReload, watcher.ErrWatcherNotRunning):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "watcher not running"})
+		case errors.Is(errReload, context.DeadlineExceeded):
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "reload timed out"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "reload failed"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reloaded": result.Reloaded})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
+)
+
+func TestSystemReloadRequiresSuperAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	h := &SystemHandler{reload: func(context.Context) (watcher.ReloadResult, error) {
+		calls++
+		return watcher.ReloadResult{Reloaded: []string{watcher.SubsystemAuths, watcher.SubsystemSettings}}, nil
+	}}
+
+	serve := func(superAdmin bool) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v0/admin/system/reload", nil)
+		c.Set("adminIsSuperAdmin", superAdmin)
+		h.Reload(c)
+		return rec
+	}
+
+	if rec := serve(false); rec.Code != http.StatusForbidden || calls != 0 {
+		t.Fatalf("expected 403 without reload, got %d (%d calls)", rec.Code, calls)
+	}
+
+	rec := serve(true)
+	if rec.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("expected 200 with one reload, got %d (%d calls)", rec.Code, calls)
+	}
+	var body struct {
+		Reloaded []string `json:"reloaded"`
+	}
+	if errDecode := json.Unmarshal(rec.Body.Bytes(), &body); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if len(body.Reloaded) != 2 || body.Reloaded[0] != watcher.SubsystemAuths {
+		t.Fatalf("unexpected reloaded subsystems %v", body.Reloaded)
+	}
+}
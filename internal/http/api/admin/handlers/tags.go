@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// TagHandler serves the tags shared by auth files and provider API keys.
+type TagHandler struct {
+	db *gorm.DB
+}
+
+// NewTagHandler constructs a TagHandler.
+func NewTagHandler(db *gorm.DB) *TagHandler {
+	return &TagHandler{db: db}
+}
+
+// tagSummary reports how many records carry one tag.
+type tagSummary struct {
+	Tag             string `json:"tag"`
+	AuthFiles       int    `json:"auth_files"`
+	ProviderAPIKeys int    `json:"provider_api_keys"`
+	Count           int    `json:"count"`
+}
+
+// List returns every distinct tag with usage counts, most used first. q filters by prefix.
+func (h *TagHandler) List(c *gin.Context) {
+	prefix := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	ctx := c.Request.Context()
+
+	var authRows []models.Auth
+	if errFind := h.db.WithContext(ctx).Model(&models.Auth{}).Select("tags").Find(&authRows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("list auth file tags failed"))
+		return
+	}
+	var keyRows []models.ProviderAPIKey
+	if errFind := h.db.WithContext(ctx).Model(&models.ProviderAPIKey{}).Select("tags").Find(&keyRows).Error; errFind != nil {
+		apierror.Write(c, apierror.Internal("list provider api key tags failed"))
+		return
+	}
+
+	byTag := make(map[string]*tagSummary)
+	summary := func(tag string) *tagSummary {
+		if !strings.HasPrefix(tag, prefix) {
+			return nil
+		}
+		item, ok := byTag[tag]
+		if !ok {
+			item = &tagSummary{Tag: tag}
+			byTag[tag] = item
+		}
+		return item
+	}
+	for _, row := range authRows {
+		for _, tag := range row.Tags {
+			if item := summary(tag); item != nil {
+				item.AuthFiles++
+				item.Count++
+			}
+		}
+	}
+	for _, row := range keyRows {
+		for _, tag := range row.Tags {
+			if item := summary(tag); item != nil {
+				item.ProviderAPIKeys++
+				item.Count++
+			}
+		}
+	}
+
+	out := make([]tagSummary, 0, len(byTag))
+	for _, item := range byTag {
+		out = append(out, *item)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Tag < out[j].Tag
+	})
+	c.JSON(http.StatusOK, gin.H{"tags": out})
+}
+
+// parseTagsQuery normalizes a comma-separated tags filter.
+func parseTagsQuery(raw string) (models.Tags, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	return models.NormalizeTags(strings.Split(raw, ","))
+}
@@ -7,18 +7,20 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/usage"
 	"gorm.io/gorm"
 )
 
 // UsageHandler handles admin usage listing endpoints.
 type UsageHandler struct {
-	db *gorm.DB
+	dbs dbutil.DBProvider // Primary connection and optional read replica.
 }
 
 // NewUsageHandler constructs a UsageHandler.
-func NewUsageHandler(db *gorm.DB) *UsageHandler {
-	return &UsageHandler{db: db}
+func NewUsageHandler(dbs dbutil.DBProvider) *UsageHandler {
+	return &UsageHandler{dbs: dbs}
 }
 
 // List returns usage records with optional filters.
@@ -28,6 +30,7 @@ func (h *UsageHandler) List(c *gin.Context) {
 		fromStr     = strings.TrimSpace(c.Query("from"))
 		toStr       = strings.TrimSpace(c.Query("to"))
 		limitStr    = strings.TrimSpace(c.Query("limit"))
+		streamStr   = strings.TrimSpace(c.Query("stream"))
 	)
 
 	limit := 100
@@ -40,7 +43,7 @@ func (h *UsageHandler) List(c *gin.Context) {
 		}
 	}
 
-	q := h.db.WithContext(c.Request.Context()).Model(&models.Usage{})
+	q := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{})
 	if apiKeyIDStr != "" {
 		if id, errParseUint := strconv.ParseUint(apiKeyIDStr, 10, 64); errParseUint == nil {
 			q = q.Where("api_key_id = ?", id)
@@ -57,10 +60,56 @@ func (h *UsageHandler) List(c *gin.Context) {
 		}
 	}
 
+	if streamStr != "" {
+		stream, errParseBool := strconv.ParseBool(streamStr)
+		if errParseBool != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid stream"})
+			return
+		}
+		q = q.Where("stream = ?", stream)
+	}
+
+	// streamBreakdown captures request counts grouped by the stream flag.
+	type streamBreakdown struct {
+		Stream bool  // Stream flag.
+		Count  int64 // Matching request count.
+	}
+	var breakdown []streamBreakdown
+	if errCount := q.Session(&gorm.Session{}).
+		Select("stream, COUNT(*) AS count").
+		Group("stream").
+		Scan(&breakdown).Error; errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	var streamCount, nonStreamCount int64
+	for _, b := range breakdown {
+		if b.Stream {
+			streamCount += b.Count
+		} else {
+			nonStreamCount += b.Count
+		}
+	}
+
 	var rows []models.Usage
 	if errFind := q.Order("requested_at DESC").Limit(limit).Find(&rows).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"usage": rows})
+	c.JSON(http.StatusOK, gin.H{
+		"usage":            rows,
+		"stream_count":     streamCount,
+		"non_stream_count": nonStreamCount,
+	})
+}
+
+// RelinkAuths re-links usage rows whose auth record was missing when they were recorded
+// and reports how many remain orphaned.
+func (h *UsageHandler) RelinkAuths(c *gin.Context) {
+	result, errRelink := usage.RelinkOrphanedAuths(c.Request.Context(), h.dbs.Write(), 0)
+	if errRelink != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "relink usage auths failed"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
 }
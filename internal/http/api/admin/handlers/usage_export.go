@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/jobs"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// jobTypeUsageExport writes usage rows to a CSV file in the background.
+	jobTypeUsageExport = "usage.export_csv"
+	// usageExportBatchSize bounds the usage rows loaded per query.
+	usageExportBatchSize = 1000
+)
+
+func init() {
+	jobs.Register(jobTypeUsageExport, runUsageExport)
+}
+
+// usageExportHeader lists the CSV columns of a usage export.
+var usageExportHeader = []string{
+	"id", "requested_at", "request_id", "user_id", "api_key_id", "auth_key", "provider", "model",
+	"requested_model", "source", "stream", "failed", "input_tokens", "output_tokens",
+	"reasoning_tokens", "cached_tokens", "total_tokens", "cost_micros", "duration_ms",
+}
+
+// usageExportParams filters the exported usage rows; zero values match every row.
+type usageExportParams struct {
+	From     *time.Time `json:"from"`       // Inclusive start, RFC 3339.
+	To       *time.Time `json:"to"`         // Inclusive end, RFC 3339.
+	UserID   uint64     `json:"user_id"`    // User filter.
+	APIKeyID uint64     `json:"api_key_id"` // API key filter.
+	Model    string     `json:"model"`      // Model filter.
+}
+
+// Export queues a job that writes the usage rows matching the body filters to a CSV
+// file, downloadable from the job once it succeeds.
+func (h *UsageHandler) Export(c *gin.Context) {
+	jobs.Async(h.dbs.Write(), jobTypeUsageExport, func(c *gin.Context) (any, bool) {
+		var params usageExportParams
+		if c.Request.ContentLength != 0 {
+			if errBind := c.ShouldBindJSON(&params); errBind != nil {
+				apierror.Write(c, apierror.InvalidJSON())
+				return nil, false
+			}
+		}
+		if params.From != nil && params.To != nil && params.To.Before(*params.From) {
+			apierror.Write(c, apierror.Validation("to", "to must not be before from"))
+			return nil, false
+		}
+		params.Model = strings.TrimSpace(params.Model)
+		return params, true
+	})(c)
+}
+
+// runUsageExport writes the selected usage rows, oldest first, to a CSV file.
+func runUsageExport(ctx context.Context, run *jobs.Run) (any, error) {
+	var params usageExportParams
+	if errParams := run.Params(&params); errParams != nil {
+		return nil, errParams
+	}
+	q := run.DB().WithContext(ctx).Model(&models.Usage{})
+	if params.From != nil {
+		q = q.Where("requested_at >= ?", params.From.UTC())
+	}
+	if params.To != nil {
+		q = q.Where("requested_at <= ?", params.To.UTC())
+	}
+	if params.UserID != 0 {
+		q = q.Where("user_id = ?", params.UserID)
+	}
+	if params.APIKeyID != 0 {
+		q = q.Where("api_key_id = ?", params.APIKeyID)
+	}
+	if params.Model != "" {
+		q = q.Where("model = ?", params.Model)
+	}
+	var total int64
+	if errCount := q.Session(&gorm.Session{}).Count(&total).Error; errCount != nil {
+		return nil, errCount
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if errWrite := writer.Write(usageExportHeader); errWrite != nil {
+		return nil, errWrite
+	}
+	written := 0
+	var rows []models.Usage
+	errBatches := q.FindInBatches(&rows, usageExportBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, row := range rows {
+			if errWrite := writer.Write(usageExportRecord(row)); errWrite != nil {
+				return errWrite
+			}
+		}
+		written += len(rows)
+		run.SetProgress(ctx, written, int(total))
+		return ctx.Err()
+	}).Error
+	if errBatches != nil {
+		return nil, errBatches
+	}
+	writer.Flush()
+	if errFlush := writer.Error(); errFlush != nil {
+		return nil, errFlush
+	}
+
+	run.SetFile("usage-"+strconv.FormatUint(run.ID(), 10)+".csv", "text/csv; charset=utf-8", buf.Bytes())
+	return gin.H{"rows": written}, nil
+}
+
+// usageExportRecord renders a usage row in usageExportHeader order.
+func usageExportRecord(row models.Usage) []string {
+	optionalUint := func(v *uint64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatUint(*v, 10)
+	}
+	requestID := ""
+	if row.RequestID != nil {
+		requestID = *row.RequestID
+	}
+	durationMs := ""
+	if row.DurationMs != nil {
+		durationMs = strconv.FormatInt(*row.DurationMs, 10)
+	}
+	return []string{
+		strconv.FormatUint(row.ID, 10),
+		row.RequestedAt.UTC().Format(time.RFC3339),
+		requestID,
+		optionalUint(row.UserID),
+		optionalUint(row.APIKeyID),
+		row.AuthKey,
+		row.Provider,
+		row.Model,
+		row.RequestedModel,
+		row.Source,
+		strconv.FormatBool(row.Stream),
+		strconv.FormatBool(row.Failed),
+		strconv.FormatInt(row.InputTokens, 10),
+		strconv.FormatInt(row.OutputTokens, 10),
+		strconv.FormatInt(row.ReasoningTokens, 10),
+		strconv.FormatInt(row.CachedTokens, 10),
+		strconv.FormatInt(row.TotalTokens, 10),
+		strconv.FormatInt(row.CostMicros, 10),
+		durationMs,
+	}
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/audit"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/usage"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// userPurgeTokenTTL bounds how long a purge confirmation token stays valid.
+const userPurgeTokenTTL = 5 * time.Minute
+
+// UserDataHandler exports and purges a user's stored data.
+type UserDataHandler struct {
+	db     *gorm.DB
+	jwtCfg config.JWTConfig
+}
+
+// NewUserDataHandler constructs a UserDataHandler.
+func NewUserDataHandler(db *gorm.DB, jwtCfg config.JWTConfig) *UserDataHandler {
+	return &UserDataHandler{db: db, jwtCfg: jwtCfg}
+}
+
+// userPurgeRequest carries the confirmation token returned by the first purge call.
+type userPurgeRequest struct {
+	ConfirmToken string `json:"confirm_token"`
+}
+
+// userPurgeCounts lists the rows a purge removes or anonymizes.
+type userPurgeCounts struct {
+	APIKeys       int64 `json:"api_keys"`
+	Bills         int64 `json:"bills"`
+	Bindings      int64 `json:"bindings"`
+	Usages        int64 `json:"usages"`
+	UsageRollups  int64 `json:"usage_rollups"`
+	PrepaidCards  int64 `json:"prepaid_cards"`
+	BalanceAdjust int64 `json:"balance_adjustments"`
+}
+
+// Export returns a JSON bundle of a user's profile, masked API keys, bills,
+// prepaid cards, and usage aggregates, and records an audit entry.
+func (h *UserDataHandler) Export(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return
+	}
+	adminID := c.GetUint64("adminID")
+
+	ctx := c.Request.Context()
+	user, ok := h.findUser(c, id)
+	if !ok {
+		return
+	}
+
+	var keys []models.APIKey
+	if errKeys := h.db.WithContext(ctx).Where("user_id = ?", id).Order("id ASC").Find(&keys).Error; errKeys != nil {
+		apierror.Write(c, apierror.Internal("query api keys failed"))
+		return
+	}
+	keyOut := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		keyOut = append(keyOut, gin.H{
+			"id":           key.ID,
+			"name":         key.Name,
+			"key_prefix":   maskAPIKey(key.APIKey),
+			"active":       key.Active,
+			"status":       key.Status(),
+			"expires_at":   key.ExpiresAt,
+			"revoked_at":   key.RevokedAt,
+			"last_used_at": key.LastUsedAt,
+			"created_at":   key.CreatedAt,
+		})
+	}
+
+	var bills []models.Bill
+	if errBills := h.db.WithContext(ctx).Where("user_id = ?", id).Order("id ASC").Find(&bills).Error; errBills != nil {
+		apierror.Write(c, apierror.Internal("query bills failed"))
+		return
+	}
+	billOut := make([]gin.H, 0, len(bills))
+	billFormatter := &BillHandler{}
+	for i := range bills {
+		billOut = append(billOut, billFormatter.formatBill(&bills[i]))
+	}
+
+	var cards []models.PrepaidCard
+	if errCards := h.db.WithContext(ctx).Where("redeemed_user_id = ?", id).Order("id ASC").Find(&cards).Error; errCards != nil {
+		apierror.Write(c, apierror.Internal("query prepaid cards failed"))
+		return
+	}
+	cardOut := make([]gin.H, 0, len(cards))
+	for _, card := range cards {
+		cardOut = append(cardOut, gin.H{
+			"id":          card.ID,
+			"name":        card.Name,
+			"card_sn":     card.CardSN,
+			"amount":      card.Amount,
+			"balance":     card.Balance,
+			"expires_at":  card.ExpiresAt,
+			"redeemed_at": card.RedeemedAt,
+			"created_at":  card.CreatedAt,
+		})
+	}
+
+	usageOut, errUsage := usage.UserUsageByModel(ctx, h.db, id, time.Now().UTC())
+	if errUsage != nil {
+		apierror.Write(c, apierror.Internal("query usage failed"))
+		return
+	}
+
+	exportedAt := time.Now().UTC()
+	targetID := user.ID
+	if errAudit := audit.Record(ctx, h.db, audit.Entry{
+		ActorType:  audit.ActorAdmin,
+		ActorID:    adminID,
+		Action:     audit.ActionUserExport,
+		TargetType: audit.TargetUser,
+		TargetID:   &targetID,
+		Detail: map[string]any{
+			"admin_username": c.GetString("adminUsername"),
+			"api_keys":       len(keys),
+			"bills":          len(bills),
+			"prepaid_cards":  len(cards),
+		},
+		IP: c.ClientIP(),
+	}); errAudit != nil {
+		log.WithError(errAudit).Error("user export: record audit entry failed")
+		apierror.Write(c, apierror.Internal("record audit failed"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exported_at": exportedAt,
+		"profile": gin.H{
+			"id":                 user.ID,
+			"username":           user.Username,
+			"name":               user.Name,
+			"email":              user.Email,
+			"user_group_id":      user.UserGroupID.Clean(),
+			"plan_id":            user.PlanID,
+			"daily_max_usage":    user.DailyMaxUsage,
+			"daily_spend_cap":    user.DailySpendCap,
+			"rate_limit":         user.RateLimit,
+			"active":             user.Active,
+			"disabled":           user.Disabled,
+			"email_verified_at":  user.EmailVerifiedAt,
+			"mfa_enabled":        strings.TrimSpace(user.TOTPSecret) != "",
+			"passkey_registered": len(user.PasskeyID) > 0,
+			"created_at":         user.CreatedAt,
+			"updated_at":         user.UpdatedAt,
+		},
+		"api_keys":      keyOut,
+		"bills":         billOut,
+		"prepaid_cards": cardOut,
+		"usage":         usageOut,
+	})
+}
+
+// Purge removes a user and their data in one transaction. The first call
+// returns a confirmation token; repeating the call with that token performs
+// the purge. Usage rows are kept with the user reference cleared so totals
+// stay intact.
+func (h *UserDataHandler) Purge(c *gin.Context) {
+	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if errParse != nil {
+		apierror.Write(c, apierror.InvalidID())
+		return
+	}
+	adminID := c.GetUint64("adminID")
+	if adminID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var body userPurgeRequest
+	if c.Request.ContentLength != 0 {
+		if errBind := c.ShouldBindJSON(&body); errBind != nil {
+			apierror.Write(c, apierror.InvalidJSON())
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	user, ok := h.findUser(c, id)
+	if !ok {
+		return
+	}
+
+	token := strings.TrimSpace(body.ConfirmToken)
+	if token == "" {
+		counts, errCount := h.countPurge(h.db.WithContext(ctx), id)
+		if errCount != nil {
+			apierror.Write(c, apierror.Internal("count user data failed"))
+			return
+		}
+		confirmToken, errToken := security.GenerateUserPurgeToken(h.jwtCfg.Secret, id, adminID, userPurgeTokenTTL)
+		if errToken != nil {
+			apierror.Write(c, apierror.Internal("failed to generate token"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":       user.ID,
+			"username":      user.Username,
+			"confirm_token": confirmToken,
+			"expires_at":    time.Now().UTC().Add(userPurgeTokenTTL),
+			"counts":        counts,
+		})
+		return
+	}
+	if errVerify := security.VerifyUserPurgeToken(h.jwtCfg.Secret, token, id, adminID); errVerify != nil {
+		apierror.Write(c, apierror.Validation("confirm_token", "invalid or expired confirm token"))
+		return
+	}
+
+	var counts userPurgeCounts
+	errTx := h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Usage{}).Where("user_id = ?", id).
+			Updates(map[string]any{"user_id": nil, "api_key_id": nil})
+		if res.Error != nil {
+			return res.Error
+		}
+		counts.Usages = res.RowsAffected
+
+		rollups, errRollups := usage.AnonymizeUserRollups(tx, id)
+		if errRollups != nil {
+			return errRollups
+		}
+		counts.UsageRollups = rollups
+
+		res = tx.Model(&models.PrepaidCard{}).Where("redeemed_user_id = ?", id).Update("redeemed_user_id", nil)
+		if res.Error != nil {
+			return res.Error
+		}
+		counts.PrepaidCards = res.RowsAffected
+
+		deletes := []struct {
+			model any
+			count *int64
+		}{
+			{&models.APIKey{}, &counts.APIKeys},
+			{&models.Bill{}, &counts.Bills},
+			{&models.UserModelAuthBinding{}, &counts.Bindings},
+			{&models.BalanceAdjustment{}, &counts.BalanceAdjust},
+			{&models.EmailVerificationToken{}, nil},
+			{&models.UsageAlert{}, nil},
+		}
+		for _, del := range deletes {
+			res = tx.Where("user_id = ?", id).Delete(del.model)
+			if res.Error != nil {
+				return res.Error
+			}
+			if del.count != nil {
+				*del.count = res.RowsAffected
+			}
+		}
+
+		if errDelete := tx.Delete(&models.User{}, id).Error; errDelete != nil {
+			return errDelete
+		}
+
+		targetID := id
+		return audit.Record(ctx, tx, audit.Entry{
+			ActorType:  audit.ActorAdmin,
+			ActorID:    adminID,
+			Action:     audit.ActionUserPurge,
+			TargetType: audit.TargetUser,
+			TargetID:   &targetID,
+			Detail: map[string]any{
+				"admin_username": c.GetString("adminUsername"),
+				"username":       user.Username,
+				"counts":         counts,
+			},
+			IP: c.ClientIP(),
+		})
+	})
+	if errTx != nil {
+		log.WithError(errTx).Error("user purge: transaction failed")
+		apierror.Write(c, apierror.Internal("purge failed"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "user_id": id, "counts": counts})
+}
+
+// findUser loads the user or writes the error response.
+func (h *UserDataHandler) findUser(c *gin.Context, id uint64) (*models.User, bool) {
+	var user models.User
+	if errFind := h.db.WithContext(c.Request.Context()).First(&user, id).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			apierror.Write(c, apierror.NotFound("user not found"))
+			return nil, false
+		}
+		apierror.Write(c, apierror.Internal("query failed"))
+		return nil, false
+	}
+	return &user, true
+}
+
+// countPurge previews how many rows a purge of userID would touch.
+func (h *UserDataHandler) countPurge(db *gorm.DB, userID uint64) (userPurgeCounts, error) {
+	var counts userPurgeCounts
+	queries := []struct {
+		model  any
+		column string
+		count  *int64
+	}{
+		{&models.APIKey{}, "user_id", &counts.APIKeys},
+		{&models.Bill{}, "user_id", &counts.Bills},
+		{&models.UserModelAuthBinding{}, "user_id", &counts.Bindings},
+		{&models.Usage{}, "user_id", &counts.Usages},
+		{&models.UsageDailyRollup{}, "user_id", &counts.UsageRollups},
+		{&models.PrepaidCard{}, "redeemed_user_id", &counts.PrepaidCards},
+		{&models.BalanceAdjustment{}, "user_id", &counts.BalanceAdjust},
+	}
+	for _, q := range queries {
+		if errCount := db.Model(q.model).Where(q.column+" = ?", userID).Count(q.count).Error; errCount != nil {
+			return counts, errCount
+		}
+	}
+	return counts, nil
+}
+
+// maskAPIKey keeps only the leading and trailing characters of an API key.
+func maskAPIKey(key string) string {
+	if len(key) < 8 {
+		return ""
+	}
+	return key[:8] + "········" + key[len(key)-4:]
+}
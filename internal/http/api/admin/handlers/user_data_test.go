@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func setupUserDataDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:userdata_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if errMigrate := db.AutoMigrate(
+		&models.User{},
+		&models.APIKey{},
+		&models.Bill{},
+		&models.PrepaidCard{},
+		&models.Usage{},
+		&models.UsageDailyRollup{},
+		&models.UsageRollupDay{},
+		&models.UserModelAuthBinding{},
+		&models.BalanceAdjustment{},
+		&models.EmailVerificationToken{},
+		&models.UsageAlert{},
+		&models.AuditLog{},
+	); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	return db
+}
+
+func seedUserData(t *testing.T, db *gorm.DB) (models.User, models.APIKey) {
+	t.Helper()
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "hashed", TOTPSecret: "secret"}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	key := models.APIKey{UserID: &user.ID, Name: "default", APIKey: "sk-abcdefghijklmnop1234", Active: true}
+	if errCreate := db.Create(&key).Error; errCreate != nil {
+		t.Fatalf("create api key: %v", errCreate)
+	}
+	now := time.Now().UTC()
+	bill := models.Bill{UserID: user.ID, PeriodType: models.BillPeriodTypeMonthly, PeriodStart: now, PeriodEnd: now.AddDate(0, 1, 0), Amount: 10}
+	if errCreate := db.Create(&bill).Error; errCreate != nil {
+		t.Fatalf("create bill: %v", errCreate)
+	}
+	card := models.PrepaidCard{Name: "card", CardSN: "SN1", Password: "pin", Amount: 5, Balance: 5, RedeemedUserID: &user.ID}
+	if errCreate := db.Create(&card).Error; errCreate != nil {
+		t.Fatalf("create card: %v", errCreate)
+	}
+	for i := 0; i < 2; i++ {
+		row := models.Usage{
+			Provider:    "openai",
+			Model:       "gpt-4o",
+			UserID:      &user.ID,
+			APIKeyID:    &key.ID,
+			RequestedAt: now.Add(-time.Duration(i) * time.Minute),
+			TotalTokens: 10,
+		}
+		if errCreate := db.Create(&row).Error; errCreate != nil {
+			t.Fatalf("create usage: %v", errCreate)
+		}
+	}
+	return user, key
+}
+
+func callUserData(t *testing.T, fn gin.HandlerFunc, id uint64, body string) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/admin/users/x", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(id)}}
+	c.Set("adminID", uint64(1))
+	fn(c)
+	var payload map[string]any
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &payload); errDecode != nil {
+		t.Fatalf("decode: %v (%s)", errDecode, w.Body.String())
+	}
+	return w.Code, payload
+}
+
+func TestUserDataExportMasksSecrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupUserDataDB(t)
+	user, key := seedUserData(t, db)
+	handler := NewUserDataHandler(db, config.JWTConfig{Secret: "test-secret"})
+
+	code, payload := callUserData(t, handler.Export, user.ID, "")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, body = %v", code, payload)
+	}
+	raw, _ := json.Marshal(payload)
+	for _, secret := range []string{key.APIKey, "hashed", `"pin"`, `"secret"`} {
+		if strings.Contains(string(raw), secret) {
+			t.Fatalf("export leaked %q: %s", secret, raw)
+		}
+	}
+	profile := payload["profile"].(map[string]any)
+	if profile["mfa_enabled"] != true {
+		t.Fatalf("mfa_enabled = %v", profile["mfa_enabled"])
+	}
+	keys := payload["api_keys"].([]any)
+	if len(keys) != 1 || keys[0].(map[string]any)["key_prefix"] != "sk-abcde········1234" {
+		t.Fatalf("api_keys = %v", keys)
+	}
+	if len(payload["bills"].([]any)) != 1 || len(payload["prepaid_cards"].([]any)) != 1 {
+		t.Fatalf("bills/cards = %v %v", payload["bills"], payload["prepaid_cards"])
+	}
+	usageRows := payload["usage"].([]any)
+	if len(usageRows) != 1 || usageRows[0].(map[string]any)["request_count"] != float64(2) {
+		t.Fatalf("usage = %v", usageRows)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ?", "user.export").Count(&audits)
+	if audits != 1 {
+		t.Fatalf("export audit entries = %d", audits)
+	}
+}
+
+func TestUserDataPurgeRequiresTokenAndAnonymizesUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupUserDataDB(t)
+	user, _ := seedUserData(t, db)
+	handler := NewUserDataHandler(db, config.JWTConfig{Secret: "test-secret"})
+
+	code, payload := callUserData(t, handler.Purge, user.ID, `{"confirm_token":"bogus"}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("bogus token status = %d, body = %v", code, payload)
+	}
+
+	code, payload = callUserData(t, handler.Purge, user.ID, "")
+	if code != http.StatusOK {
+		t.Fatalf("preview status = %d, body = %v", code, payload)
+	}
+	token, _ := payload["confirm_token"].(string)
+	if token == "" {
+		t.Fatalf("missing confirm token: %v", payload)
+	}
+	var users int64
+	db.Model(&models.User{}).Count(&users)
+	if users != 1 {
+		t.Fatalf("preview deleted the user")
+	}
+
+	code, payload = callUserData(t, handler.Purge, user.ID, fmt.Sprintf(`{"confirm_token":%q}`, token))
+	if code != http.StatusOK {
+		t.Fatalf("purge status = %d, body = %v", code, payload)
+	}
+
+	db.Model(&models.User{}).Count(&users)
+	var keys, bills, usages, linked, cards int64
+	db.Model(&models.APIKey{}).Count(&keys)
+	db.Model(&models.Bill{}).Count(&bills)
+	db.Model(&models.Usage{}).Count(&usages)
+	db.Model(&models.Usage{}).Where("user_id IS NOT NULL OR api_key_id IS NOT NULL").Count(&linked)
+	db.Model(&models.PrepaidCard{}).Where("redeemed_user_id IS NOT NULL").Count(&cards)
+	if users != 0 || keys != 0 || bills != 0 || cards != 0 {
+		t.Fatalf("rows left: users=%d keys=%d bills=%d cards=%d", users, keys, bills, cards)
+	}
+	if usages != 2 || linked != 0 {
+		t.Fatalf("usages=%d linked=%d, want 2 anonymized rows", usages, linked)
+	}
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ?", "user.purge").Count(&audits)
+	if audits != 1 {
+		t.Fatalf("purge audit entries = %d", audits)
+	}
+}
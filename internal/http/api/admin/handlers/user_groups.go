@@ -2,32 +2,117 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
 	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/gorm"
 )
 
 // UserGroupHandler manages user group endpoints.
 type UserGroupHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	catalog *modelCatalog // Provider model lists checked against model prefixes.
 }
 
 // NewUserGroupHandler constructs a UserGroupHandler.
 func NewUserGroupHandler(db *gorm.DB) *UserGroupHandler {
-	return &UserGroupHandler{db: db}
+	return &UserGroupHandler{db: db, catalog: newModelCatalog()}
+}
+
+var (
+	// errUserGroupParentNotFound is returned when parent_id names a missing group.
+	errUserGroupParentNotFound = errors.New("parent user group not found")
+	// errUserGroupParentCycle is returned when parent_id would make a group its own ancestor.
+	errUserGroupParentCycle = errors.New("parent user group would create a cycle")
+)
+
+// validateUserGroupParent checks that groupID may inherit from parentID: the parent must
+// exist and must not be groupID or have it among its ancestors. groupID is 0 on create.
+func validateUserGroupParent(tx *gorm.DB, groupID, parentID uint64) error {
+	if parentID == groupID {
+		return errUserGroupParentCycle
+	}
+	var count int64
+	if errCount := tx.Model(&models.UserGroup{}).Where("id = ?", parentID).Count(&count).Error; errCount != nil {
+		return errCount
+	}
+	if count == 0 {
+		return errUserGroupParentNotFound
+	}
+	if groupID == 0 {
+		return nil
+	}
+	parents, errParents := billing.LoadUserGroupParents(tx.Statement.Context, tx)
+	if errParents != nil {
+		return errParents
+	}
+	for _, ancestorID := range billing.AncestorUserGroupIDs(parents, parentID) {
+		if ancestorID == groupID {
+			return errUserGroupParentCycle
+		}
+	}
+	return nil
+}
+
+// errUserGroupModelPrefix marks model_prefix validation failures.
+var errUserGroupModelPrefix = errors.New("invalid model_prefix")
+
+// validateUserGroupModelPrefix normalizes prefix and rejects it when a mapped or provider
+// model already starts with it, since branded names must not collide with real ones.
+func (h *UserGroupHandler) validateUserGroupModelPrefix(tx *gorm.DB, prefix string) (string, error) {
+	prefix, errPrefix := modelmapping.NormalizeModelPrefix(prefix)
+	if errPrefix != nil {
+		return "", fmt.Errorf("%w: %s", errUserGroupModelPrefix, errPrefix.Error())
+	}
+	if prefix == "" {
+		return "", nil
+	}
+	var mappings []models.ModelMapping
+	if errFind := tx.Model(&models.ModelMapping{}).Select("model_name", "new_model_name").Find(&mappings).Error; errFind != nil {
+		return "", errFind
+	}
+	names := make([]string, 0, len(mappings)*2)
+	for _, mapping := range mappings {
+		names = append(names, mapping.NewModelName, mapping.ModelName)
+	}
+	if h.catalog != nil {
+		byProvider, _ := h.catalog.All()
+		for _, providerModels := range byProvider {
+			names = append(names, providerModels...)
+		}
+	}
+	if name, conflict := modelmapping.FindModelPrefixConflict(prefix, names); conflict {
+		return "", fmt.Errorf("%w: model %q already starts with %q", errUserGroupModelPrefix, name, prefix)
+	}
+	return prefix, nil
+}
+
+// userGroupRequestError reports whether errTx is caused by the request body.
+func userGroupRequestError(errTx error) bool {
+	return errors.Is(errTx, errUserGroupParentNotFound) ||
+		errors.Is(errTx, errUserGroupParentCycle) ||
+		errors.Is(errTx, errUserGroupModelPrefix)
 }
 
 // createUserGroupRequest defines the request body for user group creation.
 type createUserGroupRequest struct {
-	Name      string `json:"name"`
-	IsDefault bool   `json:"is_default"`
-	RateLimit int    `json:"rate_limit"`
+	Name      string  `json:"name"`
+	IsDefault bool    `json:"is_default"`
+	RateLimit int     `json:"rate_limit"`
+	ParentID  *uint64 `json:"parent_id"`
+
+	ModelPrefix string `json:"model_prefix"` // Prefix shown on model names to members.
+
+	OveragePolicy string  `json:"overage_policy"` // block, allow_grace or pay_as_you_go; empty selects block.
+	OverageGrace  float64 `json:"overage_grace"`  // Debt members may run up under allow_grace.
 }
 
 // Create creates a new user group.
@@ -43,16 +128,42 @@ func (h *UserGroupHandler) Create(c *gin.Context) {
 		return
 	}
 
+	overagePolicy, errPolicy := billing.NormalizeOveragePolicy(body.OveragePolicy)
+	if errPolicy != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPolicy.Error()})
+		return
+	}
+	if body.OverageGrace < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "overage_grace must be >= 0"})
+		return
+	}
+
 	now := time.Now().UTC()
 	group := models.UserGroup{
-		Name:      name,
-		IsDefault: body.IsDefault,
-		RateLimit: body.RateLimit,
-		CreatedAt: now,
-		UpdatedAt: now,
+		Name:          name,
+		IsDefault:     body.IsDefault,
+		RateLimit:     body.RateLimit,
+		OveragePolicy: overagePolicy,
+		OverageGrace:  body.OverageGrace,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if body.ParentID != nil && *body.ParentID != 0 {
+		parentID := *body.ParentID
+		group.ParentID = &parentID
 	}
 
 	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if group.ParentID != nil {
+			if errParent := validateUserGroupParent(tx, 0, *group.ParentID); errParent != nil {
+				return errParent
+			}
+		}
+		prefix, errPrefix := h.validateUserGroupModelPrefix(tx, body.ModelPrefix)
+		if errPrefix != nil {
+			return errPrefix
+		}
+		group.ModelPrefix = prefix
 		if body.IsDefault {
 			if errClear := tx.Model(&models.UserGroup{}).Where("is_default = ?", true).
 				Updates(map[string]any{"is_default": false, "updated_at": now}).Error; errClear != nil {
@@ -62,15 +173,23 @@ func (h *UserGroupHandler) Create(c *gin.Context) {
 		return tx.Create(&group).Error
 	})
 	if errTx != nil {
+		if userGroupRequestError(errTx) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errTx.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create user group failed"})
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{
-		"id":         group.ID,
-		"name":       group.Name,
-		"is_default": group.IsDefault,
-		"created_at": group.CreatedAt,
-		"updated_at": group.UpdatedAt,
+		"id":             group.ID,
+		"name":           group.Name,
+		"is_default":     group.IsDefault,
+		"parent_id":      group.ParentID,
+		"model_prefix":   group.ModelPrefix,
+		"overage_policy": group.OveragePolicy,
+		"overage_grace":  group.OverageGrace,
+		"created_at":     group.CreatedAt,
+		"updated_at":     group.UpdatedAt,
 	})
 }
 
@@ -100,12 +219,16 @@ func (h *UserGroupHandler) List(c *gin.Context) {
 	out := make([]gin.H, 0, len(rows))
 	for _, row := range rows {
 		out = append(out, gin.H{
-			"id":         row.ID,
-			"name":       row.Name,
-			"is_default": row.IsDefault,
-			"rate_limit": row.RateLimit,
-			"created_at": row.CreatedAt,
-			"updated_at": row.UpdatedAt,
+			"id":             row.ID,
+			"name":           row.Name,
+			"is_default":     row.IsDefault,
+			"rate_limit":     row.RateLimit,
+			"parent_id":      row.ParentID,
+			"model_prefix":   row.ModelPrefix,
+			"overage_policy": row.OveragePolicy,
+			"overage_grace":  row.OverageGrace,
+			"created_at":     row.CreatedAt,
+			"updated_at":     row.UpdatedAt,
 		})
 	}
 	c.JSON(http.StatusOK, gin.H{"user_groups": out})
@@ -128,12 +251,16 @@ func (h *UserGroupHandler) Get(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"id":         group.ID,
-		"name":       group.Name,
-		"is_default": group.IsDefault,
-		"rate_limit": group.RateLimit,
-		"created_at": group.CreatedAt,
-		"updated_at": group.UpdatedAt,
+		"id":             group.ID,
+		"name":           group.Name,
+		"is_default":     group.IsDefault,
+		"rate_limit":     group.RateLimit,
+		"parent_id":      group.ParentID,
+		"model_prefix":   group.ModelPrefix,
+		"overage_policy": group.OveragePolicy,
+		"overage_grace":  group.OverageGrace,
+		"created_at":     group.CreatedAt,
+		"updated_at":     group.UpdatedAt,
 	})
 }
 
@@ -142,6 +269,12 @@ type updateUserGroupRequest struct {
 	Name      *string `json:"name"`
 	IsDefault *bool   `json:"is_default"`
 	RateLimit *int    `json:"rate_limit"`
+	ParentID  *uint64 `json:"parent_id"` // 0 clears the parent.
+
+	ModelPrefix *string `json:"model_prefix"` // Empty clears the prefix.
+
+	OveragePolicy *string  `json:"overage_policy"`
+	OverageGrace  *float64 `json:"overage_grace"`
 }
 
 // Update modifies a user group.
@@ -157,7 +290,25 @@ func (h *UserGroupHandler) Update(c *gin.Context) {
 		return
 	}
 
+	updates := map[string]any{}
+	if body.OveragePolicy != nil {
+		overagePolicy, errPolicy := billing.NormalizeOveragePolicy(*body.OveragePolicy)
+		if errPolicy != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errPolicy.Error()})
+			return
+		}
+		updates["overage_policy"] = overagePolicy
+	}
+	if body.OverageGrace != nil {
+		if *body.OverageGrace < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "overage_grace must be >= 0"})
+			return
+		}
+		updates["overage_grace"] = *body.OverageGrace
+	}
+
 	now := time.Now().UTC()
+	updates["updated_at"] = now
 	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
 		if body.IsDefault != nil && *body.IsDefault {
 			if errClear := tx.Model(&models.UserGroup{}).Where("is_default = ? AND id != ?", true, id).
@@ -166,7 +317,6 @@ func (h *UserGroupHandler) Update(c *gin.Context) {
 			}
 		}
 
-		updates := map[string]any{"updated_at": now}
 		if body.Name != nil {
 			updates["name"] = strings.TrimSpace(*body.Name)
 		}
@@ -176,6 +326,23 @@ func (h *UserGroupHandler) Update(c *gin.Context) {
 		if body.RateLimit != nil {
 			updates["rate_limit"] = *body.RateLimit
 		}
+		if body.ParentID != nil {
+			if *body.ParentID == 0 {
+				updates["parent_id"] = nil
+			} else {
+				if errParent := validateUserGroupParent(tx, id, *body.ParentID); errParent != nil {
+					return errParent
+				}
+				updates["parent_id"] = *body.ParentID
+			}
+		}
+		if body.ModelPrefix != nil {
+			prefix, errPrefix := h.validateUserGroupModelPrefix(tx, *body.ModelPrefix)
+			if errPrefix != nil {
+				return errPrefix
+			}
+			updates["model_prefix"] = prefix
+		}
 
 		res := tx.Model(&models.UserGroup{}).Where("id = ?", id).Updates(updates)
 		if res.Error != nil {
@@ -191,28 +358,42 @@ func (h *UserGroupHandler) Update(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		if userGroupRequestError(errTx) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errTx.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
-// Delete removes a user group.
+// Delete removes a user group. Its child groups stop inheriting and keep no parent.
 func (h *UserGroupHandler) Delete(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
 	if errParse != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	res := h.db.WithContext(c.Request.Context()).Delete(&models.UserGroup{}, id)
-	if res.Error != nil {
+	errTx := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models.UserGroup{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.UserGroup{}).Where("parent_id = ?", id).
+			Updates(map[string]any{"parent_id": nil, "updated_at": time.Now().UTC()}).Error
+	})
+	if errTx != nil {
+		if errors.Is(errTx, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
 		return
 	}
-	if res.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
-	}
 	c.Status(http.StatusNoContent)
 }
 
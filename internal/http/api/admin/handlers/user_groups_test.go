@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestUserGroupParentInheritance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:usergroupparent_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.UserGroup{}, &models.BillingRule{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	groups := NewUserGroupHandler(db)
+	r := gin.New()
+	r.POST("/v0/admin/user-groups", groups.Create)
+	r.PUT("/v0/admin/user-groups/:id", groups.Update)
+	r.DELETE("/v0/admin/user-groups/:id", groups.Delete)
+	r.GET("/v0/admin/billing-rules", NewBillingRuleHandler(db).List)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	create := func(body string) uint64 {
+		w := serve(http.MethodPost, "/v0/admin/user-groups", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected 201, got %d: %s", body, w.Code, w.Body.String())
+		}
+		var created struct {
+			ID uint64 `json:"id"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &created)
+		return created.ID
+	}
+
+	base := create(`{"name":"base"}`)
+	partner := create(fmt.Sprintf(`{"name":"partner","parent_id":%d}`, base))
+	reseller := create(fmt.Sprintf(`{"name":"reseller","parent_id":%d}`, partner))
+	if w := serve(http.MethodPost, "/v0/admin/user-groups", `{"name":"orphan","parent_id":999}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a missing parent to be rejected, got %d", w.Code)
+	}
+	for _, parent := range []uint64{base, reseller} {
+		w := serve(http.MethodPut, fmt.Sprintf("/v0/admin/user-groups/%d", base), fmt.Sprintf(`{"parent_id":%d}`, parent))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "cycle") {
+			t.Fatalf("expected parent %d to be rejected as a cycle, got %d: %s", parent, w.Code, w.Body.String())
+		}
+	}
+
+	rules := []models.BillingRule{
+		{AuthGroupID: 1, UserGroupID: base, Provider: "claude", Model: "sonnet", BillingType: models.BillingTypePerRequest, IsEnabled: true},
+		{AuthGroupID: 1, UserGroupID: base, Provider: "claude", Model: "opus", BillingType: models.BillingTypePerRequest, IsEnabled: true},
+		{AuthGroupID: 1, UserGroupID: partner, Provider: "Claude", Model: "sonnet", BillingType: models.BillingTypePerRequest, IsEnabled: true},
+	}
+	if errCreate := db.Create(&rules).Error; errCreate != nil {
+		t.Fatalf("create rules: %v", errCreate)
+	}
+	w := serve(http.MethodGet, fmt.Sprintf("/v0/admin/billing-rules?effective=true&user_group_id=%d", reseller), "")
+	var listed struct {
+		BillingRules []struct {
+			ID                uint64 `json:"id"`
+			Inherited         bool   `json:"inherited"`
+			SourceUserGroupID uint64 `json:"source_user_group_id"`
+		} `json:"billing_rules"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &listed); errDecode != nil || w.Code != http.StatusOK {
+		t.Fatalf("list effective: %d %v", w.Code, errDecode)
+	}
+	sources := make(map[uint64]uint64)
+	for _, rule := range listed.BillingRules {
+		if !rule.Inherited {
+			t.Fatalf("expected every rule of the childless group to be inherited, got %+v", rule)
+		}
+		sources[rule.ID] = rule.SourceUserGroupID
+	}
+	if len(sources) != 2 || sources[rules[2].ID] != partner || sources[rules[1].ID] != base {
+		t.Fatalf("expected the partner sonnet rule to shadow the base one, got %v", sources)
+	}
+
+	if w := serve(http.MethodDelete, fmt.Sprintf("/v0/admin/user-groups/%d", partner), ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete parent: %d", w.Code)
+	}
+	var child models.UserGroup
+	if errFind := db.First(&child, reseller).Error; errFind != nil || child.ParentID != nil {
+		t.Fatalf("expected the child to be detached, got %+v %v", child.ParentID, errFind)
+	}
+}
+
+func TestUserGroupModelPrefixRejectsCollisions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:usergroupprefix_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.UserGroup{}, &models.ModelMapping{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	mapping := models.ModelMapping{Provider: "openai", ModelName: "gpt-5", NewModelName: "acme-gpt-large", IsEnabled: true}
+	if errCreate := db.Create(&mapping).Error; errCreate != nil {
+		t.Fatalf("create mapping: %v", errCreate)
+	}
+
+	groups := NewUserGroupHandler(db)
+	r := gin.New()
+	r.POST("/v0/admin/user-groups", groups.Create)
+	r.PUT("/v0/admin/user-groups/:id", groups.Update)
+	r.GET("/v0/admin/user-groups/:id", groups.Get)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := serve(http.MethodPost, "/v0/admin/user-groups", `{"name":"acme","model_prefix":"acme-"}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "acme-gpt-large") {
+		t.Fatalf("expected a prefix shared with a mapped model to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodPost, "/v0/admin/user-groups", `{"name":"acme","model_prefix":"acme/"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid prefix to be rejected, got %d", w.Code)
+	}
+	w := serve(http.MethodPost, "/v0/admin/user-groups", `{"name":"acme","model_prefix":" globex- "}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ID          uint64 `json:"id"`
+		ModelPrefix string `json:"model_prefix"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ModelPrefix != "globex-" {
+		t.Fatalf("expected a trimmed prefix, got %q", created.ModelPrefix)
+	}
+
+	path := fmt.Sprintf("/v0/admin/user-groups/%d", created.ID)
+	if w := serve(http.MethodPut, path, `{"model_prefix":"ACME-"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the collision check to ignore case on update, got %d", w.Code)
+	}
+	if w := serve(http.MethodPut, path, `{"model_prefix":""}`); w.Code != http.StatusOK {
+		t.Fatalf("expected the prefix to be cleared, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, path, ""); !strings.Contains(w.Body.String(), `"model_prefix":""`) {
+		t.Fatalf("expected a cleared prefix, got %s", w.Body.String())
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
@@ -49,6 +50,10 @@ func (h *UserHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing password"})
 		return
 	}
+	email := strings.TrimSpace(body.Email)
+	if !h.identitiesAvailable(c, username, email, 0) {
+		return
+	}
 
 	hash, errHash := security.HashPassword(password)
 	if errHash != nil {
@@ -59,7 +64,7 @@ func (h *UserHandler) Create(c *gin.Context) {
 	now := time.Now().UTC()
 	user := models.User{
 		Username:  username,
-		Email:     strings.TrimSpace(body.Email),
+		Email:     email,
 		Password:  hash,
 		RateLimit: body.RateLimit,
 		Active:    true,
@@ -68,6 +73,10 @@ func (h *UserHandler) Create(c *gin.Context) {
 		UpdatedAt: now,
 	}
 	if errCreate := h.db.WithContext(c.Request.Context()).Create(&user).Error; errCreate != nil {
+		if dbutil.IsUniqueViolation(errCreate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "username or email already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create user failed"})
 		return
 	}
@@ -79,14 +88,58 @@ func (h *UserHandler) Create(c *gin.Context) {
 	})
 }
 
-// List returns users with optional filters.
+// userListQuery defines paging, filters and sorting for the admin user list.
+type userListQuery struct {
+	Page        int    `form:"page,default=1"`       // Page number.
+	PageSize    int    `form:"page_size,default=20"` // Page size.
+	Keyword     string `form:"keyword"`              // Username/email keyword.
+	UserGroupID string `form:"user_group_id"`        // User group filter.
+	Sort        string `form:"sort"`                 // Sort field: created_at or last_active.
+	Order       string `form:"order"`                // Sort direction: asc or desc.
+}
+
+// userLastActiveExpr resolves a user's last activity from their API keys.
+const userLastActiveExpr = "(SELECT MAX(api_keys.last_used_at) FROM api_keys WHERE api_keys.user_id = users.id)"
+
+// List returns users with paging, optional filters and sorting.
 func (h *UserHandler) List(c *gin.Context) {
+	var lq userListQuery
+	if errBind := c.ShouldBindQuery(&lq); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+		return
+	}
+	if lq.Page < 1 {
+		lq.Page = 1
+	}
+	if lq.PageSize < 1 || lq.PageSize > 100 {
+		lq.PageSize = 20
+	}
+	sortField := strings.ToLower(strings.TrimSpace(lq.Sort))
+	if sortField != "last_active" {
+		sortField = "created_at"
+	}
+	sortOrder := strings.ToLower(strings.TrimSpace(lq.Order))
+	if sortOrder != "asc" {
+		sortOrder = "desc"
+	}
+
 	var (
 		usernameQ = strings.TrimSpace(c.Query("username"))
 		idQ       = strings.TrimSpace(c.Query("id"))
 		emailQ    = strings.TrimSpace(c.Query("email"))
 		searchQ   = strings.TrimSpace(c.Query("search"))
+		keywordQ  = strings.TrimSpace(lq.Keyword)
+		groupQ    = strings.TrimSpace(lq.UserGroupID)
 	)
+	var groupID uint64
+	if groupQ != "" {
+		parsed, errParse := strconv.ParseUint(groupQ, 10, 64)
+		if errParse != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_group_id"})
+			return
+		}
+		groupID = parsed
+	}
 
 	q := h.db.WithContext(c.Request.Context()).Model(&models.User{})
 	if usernameQ != "" {
@@ -113,14 +166,47 @@ func (h *UserHandler) List(c *gin.Context) {
 			searchPattern,
 		)
 	}
+	if keywordQ != "" {
+		pattern := dbutil.NormalizeLikePattern(h.db, "%"+keywordQ+"%")
+		q = q.Where(
+			dbutil.CaseInsensitiveLikeExpr(h.db, "username")+" OR "+dbutil.CaseInsensitiveLikeExpr(h.db, "email"),
+			pattern,
+			pattern,
+		)
+	}
+	if groupID > 0 {
+		q = q.Where(dbutil.JSONArrayContainsExpr(h.db, "user_group_id"), dbutil.JSONArrayContainsValue(h.db, groupID))
+	}
+
+	var total int64
+	if errCount := q.Count(&total).Error; errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "count users failed"})
+		return
+	}
+
+	direction := strings.ToUpper(sortOrder)
+	orderBy := "created_at " + direction + ", id " + direction
+	if sortField == "last_active" {
+		orderBy = userLastActiveExpr + " " + direction + " NULLS LAST, id " + direction
+	}
 
 	var rows []models.User
-	if errFind := q.Order("created_at DESC").Find(&rows).Error; errFind != nil {
+	offset := (lq.Page - 1) * lq.PageSize
+	if errFind := q.Order(orderBy).Offset(offset).Limit(lq.PageSize).Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list users failed"})
+		return
+	}
+	lastActive, errActive := h.loadLastActive(c.Request.Context(), rows)
+	if errActive != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "list users failed"})
 		return
 	}
 	out := make([]gin.H, 0, len(rows))
 	for _, row := range rows {
+		var lastActiveAt *time.Time
+		if ts, ok := lastActive[row.ID]; ok {
+			lastActiveAt = &ts
+		}
 		out = append(out, gin.H{
 			"id":                 row.ID,
 			"username":           row.Username,
@@ -128,14 +214,57 @@ func (h *UserHandler) List(c *gin.Context) {
 			"user_group_id":      row.UserGroupID.Clean(),
 			"bill_user_group_id": row.BillUserGroupID.Clean(),
 			"daily_max_usage":    row.DailyMaxUsage,
+			"daily_spend_cap":    row.DailySpendCap,
+			"overage_debt":       row.OverageDebt,
+			"alert_at_percent":   row.AlertAtPercent,
 			"rate_limit":         row.RateLimit,
 			"active":             row.Active,
 			"disabled":           row.Disabled,
+			"last_active_at":     lastActiveAt,
 			"created_at":         row.CreatedAt,
 			"updated_at":         row.UpdatedAt,
 		})
 	}
-	c.JSON(http.StatusOK, gin.H{"users": out})
+	c.JSON(http.StatusOK, gin.H{
+		"users":     out,
+		"total":     total,
+		"page":      lq.Page,
+		"page_size": lq.PageSize,
+		"filters": gin.H{
+			"keyword":       keywordQ,
+			"user_group_id": groupID,
+			"sort":          sortField,
+			"order":         sortOrder,
+		},
+	})
+}
+
+// loadLastActive returns the most recent API key usage time per user.
+func (h *UserHandler) loadLastActive(ctx context.Context, users []models.User) (map[uint64]time.Time, error) {
+	out := make(map[uint64]time.Time, len(users))
+	if len(users) == 0 {
+		return out, nil
+	}
+	ids := make([]uint64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	var keys []models.APIKey
+	if errFind := h.db.WithContext(ctx).
+		Select("user_id", "last_used_at").
+		Where("user_id IN ? AND last_used_at IS NOT NULL", ids).
+		Find(&keys).Error; errFind != nil {
+		return nil, errFind
+	}
+	for _, key := range keys {
+		if key.UserID == nil || key.LastUsedAt == nil {
+			continue
+		}
+		if prev, ok := out[*key.UserID]; !ok || key.LastUsedAt.After(prev) {
+			out[*key.UserID] = *key.LastUsedAt
+		}
+	}
+	return out, nil
 }
 
 // Get returns a user by ID.
@@ -161,6 +290,9 @@ func (h *UserHandler) Get(c *gin.Context) {
 		"user_group_id":      user.UserGroupID.Clean(),
 		"bill_user_group_id": user.BillUserGroupID.Clean(),
 		"daily_max_usage":    user.DailyMaxUsage,
+		"daily_spend_cap":    user.DailySpendCap,
+		"overage_debt":       user.OverageDebt,
+		"alert_at_percent":   user.AlertAtPercent,
 		"rate_limit":         user.RateLimit,
 		"active":             user.Active,
 		"disabled":           user.Disabled,
@@ -171,12 +303,15 @@ func (h *UserHandler) Get(c *gin.Context) {
 
 // updateUserRequest defines the request body for user updates.
 type updateUserRequest struct {
-	Username      *string              `json:"username"`
-	Email         *string              `json:"email"`
-	UserGroupID   *models.UserGroupIDs `json:"user_group_id"`
-	DailyMaxUsage *float64             `json:"daily_max_usage"`
-	RateLimit     *int                 `json:"rate_limit"`
-	Disabled      *bool                `json:"disabled"`
+	Username       *string              `json:"username"`
+	Email          *string              `json:"email"`
+	UserGroupID    *models.UserGroupIDs `json:"user_group_id"`
+	DailyMaxUsage  *float64             `json:"daily_max_usage"`
+	DailySpendCap  *float64             `json:"daily_spend_cap"`
+	OverageDebt    *float64             `json:"overage_debt"` // Lowered to record debt the user has settled.
+	AlertAtPercent *int                 `json:"alert_at_percent"`
+	RateLimit      *int                 `json:"rate_limit"`
+	Disabled       *bool                `json:"disabled"`
 }
 
 // Update modifies a user account.
@@ -193,14 +328,19 @@ func (h *UserHandler) Update(c *gin.Context) {
 	}
 
 	updates := map[string]any{"updated_at": time.Now().UTC()}
+	var username, email string
 	if body.Username != nil {
-		username := strings.TrimSpace(*body.Username)
+		username = strings.TrimSpace(*body.Username)
 		if username != "" {
 			updates["username"] = username
 		}
 	}
 	if body.Email != nil {
-		updates["email"] = strings.TrimSpace(*body.Email)
+		email = strings.TrimSpace(*body.Email)
+		updates["email"] = email
+	}
+	if !h.identitiesAvailable(c, username, email, id) {
+		return
 	}
 	if body.UserGroupID != nil {
 		updates["user_group_id"] = body.UserGroupID.Clean()
@@ -208,6 +348,27 @@ func (h *UserHandler) Update(c *gin.Context) {
 	if body.DailyMaxUsage != nil {
 		updates["daily_max_usage"] = *body.DailyMaxUsage
 	}
+	if body.DailySpendCap != nil {
+		if *body.DailySpendCap < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "daily_spend_cap must be >= 0"})
+			return
+		}
+		updates["daily_spend_cap"] = *body.DailySpendCap
+	}
+	if body.OverageDebt != nil {
+		if *body.OverageDebt < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "overage_debt must be >= 0"})
+			return
+		}
+		updates["overage_debt"] = *body.OverageDebt
+	}
+	if body.AlertAtPercent != nil {
+		if *body.AlertAtPercent < 0 || *body.AlertAtPercent > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "alert_at_percent must be between 0 and 100"})
+			return
+		}
+		updates["alert_at_percent"] = *body.AlertAtPercent
+	}
 	if body.RateLimit != nil {
 		updates["rate_limit"] = *body.RateLimit
 	}
@@ -227,6 +388,25 @@ func (h *UserHandler) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// identitiesAvailable checks that no other user has username or email, ignoring case.
+// Empty values are not checked. It writes the error response and returns false when
+// either is taken.
+func (h *UserHandler) identitiesAvailable(c *gin.Context, username, email string, excludeID uint64) bool {
+	ctx := c.Request.Context()
+	for _, identity := range []struct{ column, value string }{{"username", username}, {"email", email}} {
+		taken, errTaken := dbutil.IdentityTaken(ctx, h.db, &models.User{}, identity.column, identity.value, excludeID)
+		if errTaken != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+			return false
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": identity.column + " already exists"})
+			return false
+		}
+	}
+	return true
+}
+
 // Delete removes a user account and its API keys.
 func (h *UserHandler) Delete(c *gin.Context) {
 	id, errParse := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
@@ -235,33 +415,31 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
-
-	var user models.User
-	if errFind := h.db.WithContext(ctx).First(&user, id).Error; errFind != nil {
-		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+	if errDelete := h.DeleteByID(c.Request.Context(), id); errDelete != nil {
+		if errors.Is(errDelete, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
 		return
 	}
 
-	errTx := h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteByID deletes a user and its API keys, returning gorm.ErrRecordNotFound when the
+// user does not exist. It also runs approved delete_user pending actions.
+func (h *UserHandler) DeleteByID(ctx context.Context, id uint64) error {
+	var user models.User
+	if errFind := h.db.WithContext(ctx).Select("id").First(&user, id).Error; errFind != nil {
+		return errFind
+	}
+	return h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if errDelKeys := tx.Where("user_id = ?", id).Delete(&models.APIKey{}).Error; errDelKeys != nil {
 			return errDelKeys
 		}
-		if errDelUser := tx.Delete(&models.User{}, id).Error; errDelUser != nil {
-			return errDelUser
-		}
-		return nil
+		return tx.Delete(&models.User{}, id).Error
 	})
-	if errTx != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
-		return
-	}
-
-	c.Status(http.StatusNoContent)
 }
 
 // Disable deactivates a user account.
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	"gorm.io/gorm"
+)
+
+// maxImportUserRows caps the number of data rows accepted in one CSV upload.
+const maxImportUserRows = 5000
+
+// importUsersFailure reports a CSV row that was not imported.
+type importUsersFailure struct {
+	Row      int    `json:"row"`
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+// importUsersResponse summarizes a CSV user import.
+type importUsersResponse struct {
+	Imported int                  `json:"imported"`
+	Failed   []importUsersFailure `json:"failed"`
+}
+
+// Import creates users from an uploaded CSV with the columns username, email, password
+// and user_group. Valid rows are inserted in one transaction; invalid and duplicate rows
+// are skipped and reported.
+func (h *UserHandler) Import(c *gin.Context) {
+	fileHeader, errFile := c.FormFile("file")
+	if errFile != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file provided"})
+		return
+	}
+	file, errOpen := fileHeader.Open()
+	if errOpen != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "open file failed"})
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, errHeader := reader.Read()
+	if errHeader != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid csv header"})
+		return
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, required := range []string{"username", "email", "password"} {
+		if _, ok := columns[required]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing column: " + required})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	var groups []models.UserGroup
+	if errGroups := h.db.WithContext(ctx).Find(&groups).Error; errGroups != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query user groups failed"})
+		return
+	}
+	groupsByName := make(map[string]uint64, len(groups))
+	groupsByID := make(map[uint64]struct{}, len(groups))
+	var defaultGroupID *uint64
+	for i := range groups {
+		groupsByName[strings.ToLower(groups[i].Name)] = groups[i].ID
+		groupsByID[groups[i].ID] = struct{}{}
+		if groups[i].IsDefault {
+			id := groups[i].ID
+			defaultGroupID = &id
+		}
+	}
+
+	type importRow struct {
+		line int
+		user models.User
+	}
+	rows := make([]importRow, 0)
+	failures := make([]importUsersFailure, 0)
+	seenUsernames := make(map[string]struct{})
+	seenEmails := make(map[string]struct{})
+	now := time.Now().UTC()
+	for {
+		record, errRead := reader.Read()
+		if errors.Is(errRead, io.EOF) {
+			break
+		}
+		if errRead != nil {
+			var parseErr *csv.ParseError
+			if errors.As(errRead, &parseErr) {
+				failures = append(failures, importUsersFailure{Row: parseErr.Line, Error: "invalid csv row"})
+				continue
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "read csv failed"})
+			return
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows)+len(failures) >= maxImportUserRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "too many rows, limit is " + strconv.Itoa(maxImportUserRows)})
+			return
+		}
+		field := func(name string) string {
+			idx, ok := columns[name]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+		if isBlankCSVRecord(record) {
+			continue
+		}
+
+		username := field("username")
+		email := field("email")
+		password := field("password")
+		fail := func(message string) {
+			failures = append(failures, importUsersFailure{Row: line, Username: username, Error: message})
+		}
+		switch {
+		case username == "":
+			fail("missing username")
+			continue
+		case email == "":
+			fail("missing email")
+			continue
+		case !strings.Contains(email, "@"):
+			fail("invalid email")
+			continue
+		case password == "":
+			fail("missing password")
+			continue
+		}
+		if _, dup := seenUsernames[strings.ToLower(username)]; dup {
+			fail("duplicate username in file")
+			continue
+		}
+		if _, dup := seenEmails[strings.ToLower(email)]; dup {
+			fail("duplicate email in file")
+			continue
+		}
+
+		var userGroupID models.UserGroupIDs
+		if groupValue := field("user_group"); groupValue != "" {
+			groupID, ok := groupsByName[strings.ToLower(groupValue)]
+			if !ok {
+				if parsed, errParse := strconv.ParseUint(groupValue, 10, 64); errParse == nil {
+					if _, okID := groupsByID[parsed]; okID {
+						groupID, ok = parsed, true
+					}
+				}
+			}
+			if !ok {
+				fail("unknown user group")
+				continue
+			}
+			userGroupID = models.UserGroupIDs{&groupID}
+		} else if defaultGroupID != nil {
+			groupID := *defaultGroupID
+			userGroupID = models.UserGroupIDs{&groupID}
+		}
+
+		seenUsernames[strings.ToLower(username)] = struct{}{}
+		seenEmails[strings.ToLower(email)] = struct{}{}
+		rows = append(rows, importRow{line: line, user: models.User{
+			Username:    username,
+			Email:       email,
+			Password:    password,
+			UserGroupID: userGroupID,
+			Active:      true,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}})
+	}
+
+	// Skip rows that collide with existing accounts.
+	if len(rows) > 0 {
+		usernames := make([]string, 0, len(rows))
+		emails := make([]string, 0, len(rows))
+		for _, row := range rows {
+			usernames = append(usernames, strings.ToLower(row.user.Username))
+			emails = append(emails, strings.ToLower(row.user.Email))
+		}
+		var existing []models.User
+		if errFind := h.db.WithContext(ctx).
+			Select("username", "email").
+			Where("LOWER(username) IN ? OR LOWER(email) IN ?", usernames, emails).
+			Find(&existing).Error; errFind != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "query existing users failed"})
+			return
+		}
+		takenUsernames := make(map[string]struct{}, len(existing))
+		takenEmails := make(map[string]struct{}, len(existing))
+		for _, user := range existing {
+			takenUsernames[strings.ToLower(user.Username)] = struct{}{}
+			takenEmails[strings.ToLower(user.Email)] = struct{}{}
+		}
+		kept := rows[:0]
+		for _, row := range rows {
+			if _, taken := takenUsernames[strings.ToLower(row.user.Username)]; taken {
+				failures = append(failures, importUsersFailure{Row: row.line, Username: row.user.Username, Error: "username already exists"})
+				continue
+			}
+			if _, taken := takenEmails[strings.ToLower(row.user.Email)]; taken {
+				failures = append(failures, importUsersFailure{Row: row.line, Username: row.user.Username, Error: "email already exists"})
+				continue
+			}
+			kept = append(kept, row)
+		}
+		rows = kept
+	}
+
+	users := make([]models.User, 0, len(rows))
+	for _, row := range rows {
+		hash, errHash := security.HashPassword(row.user.Password)
+		if errHash != nil {
+			failures = append(failures, importUsersFailure{Row: row.line, Username: row.user.Username, Error: "hash password failed"})
+			continue
+		}
+		row.user.Password = hash
+		users = append(users, row.user)
+	}
+
+	if len(users) > 0 {
+		if errTx := h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return tx.CreateInBatches(&users, 200).Error
+		}); errTx != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "import users failed"})
+			return
+		}
+	}
+
+	sort.SliceStable(failures, func(i, k int) bool { return failures[i].Row < failures[k].Row })
+	c.JSON(http.StatusOK, importUsersResponse{
+		Imported: len(users),
+		Failed:   failures,
+	})
+}
+
+// isBlankCSVRecord reports whether every field in record is empty.
+func isBlankCSVRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
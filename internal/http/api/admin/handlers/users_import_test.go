@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+)
+
+func TestUserImportCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupUserListDB(t)
+	if errMigrate := db.AutoMigrate(&models.UserGroup{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	defaultGroup := models.UserGroup{Name: "Default", IsDefault: true}
+	vipGroup := models.UserGroup{Name: "VIP"}
+	if errCreate := db.Create(&[]*models.UserGroup{&defaultGroup, &vipGroup}).Error; errCreate != nil {
+		t.Fatalf("create groups: %v", errCreate)
+	}
+	if errCreate := db.Create(&models.User{Username: "taken", Email: "taken@example.com", Password: "x"}).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+
+	csvBody := "Username,Email,Password,User_Group\n" +
+		"alice,alice@example.com,secret1,\n" +
+		"bob,bob@example.com,secret2,vip\n" +
+		"taken,other@example.com,secret3,\n" +
+		"carol,taken@example.com,secret4,\n" +
+		"alice,alice2@example.com,secret5,\n" +
+		"dave,dave@example.com,,\n" +
+		"erin,erin@example.com,secret6,missing\n"
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, errPart := writer.CreateFormFile("file", "users.csv")
+	if errPart != nil {
+		t.Fatalf("create form file: %v", errPart)
+	}
+	_, _ = part.Write([]byte(csvBody))
+	_ = writer.Close()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/admin/users/import", &form)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	NewUserHandler(db).Import(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp importUsersResponse
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); errDecode != nil {
+		t.Fatalf("decode response: %v", errDecode)
+	}
+	if resp.Imported != 2 {
+		t.Fatalf("expected 2 imported users, got %+v", resp)
+	}
+	wantFailures := map[int]string{
+		4: "username already exists",
+		5: "email already exists",
+		6: "duplicate username in file",
+		7: "missing password",
+		8: "unknown user group",
+	}
+	if len(resp.Failed) != len(wantFailures) {
+		t.Fatalf("unexpected failures %+v", resp.Failed)
+	}
+	for _, failure := range resp.Failed {
+		if wantFailures[failure.Row] != failure.Error {
+			t.Fatalf("unexpected failure %+v", failure)
+		}
+	}
+
+	var alice, bob models.User
+	if errFind := db.Where("username = ?", "alice").First(&alice).Error; errFind != nil {
+		t.Fatalf("find alice: %v", errFind)
+	}
+	if !security.CheckPassword(alice.Password, "secret1") {
+		t.Fatalf("expected hashed password for alice")
+	}
+	if len(alice.UserGroupID) != 1 || *alice.UserGroupID[0] != defaultGroup.ID {
+		t.Fatalf("expected default group for alice, got %v", alice.UserGroupID)
+	}
+	if errFind := db.Where("username = ?", "bob").First(&bob).Error; errFind != nil {
+		t.Fatalf("find bob: %v", errFind)
+	}
+	if len(bob.UserGroupID) != 1 || *bob.UserGroupID[0] != vipGroup.ID {
+		t.Fatalf("expected vip group for bob, got %v", bob.UserGroupID)
+	}
+}
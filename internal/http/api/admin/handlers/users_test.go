@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func setupUserListDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:userlist_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.APIKey{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	return db
+}
+
+func TestUserListPagingKeywordAndGroupFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupUserListDB(t)
+	groupID := uint64(7)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		user := models.User{
+			Username:  fmt.Sprintf("Alice%d", i),
+			Email:     fmt.Sprintf("alice%d@example.com", i),
+			Password:  "x",
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: base,
+		}
+		if i%2 == 0 {
+			user.UserGroupID = models.UserGroupIDs{&groupID}
+		}
+		if errCreate := db.Create(&user).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+	}
+	if errCreate := db.Create(&models.User{Username: "bob", Email: "bob@example.com", Password: "x"}).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+
+	handler := NewUserHandler(db)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v0/admin/users?keyword=ALICE&user_group_id=7&page=1&page_size=2&order=asc", nil)
+	handler.List(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var res struct {
+		Users []struct {
+			Username string `json:"username"`
+		} `json:"users"`
+		Total    int64 `json:"total"`
+		PageSize int   `json:"page_size"`
+	}
+	if errDecode := json.NewDecoder(w.Body).Decode(&res); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if res.Total != 3 {
+		t.Fatalf("expected total 3, got %d", res.Total)
+	}
+	if res.PageSize != 2 || len(res.Users) != 2 {
+		t.Fatalf("expected 2 users on page, got %d", len(res.Users))
+	}
+	if res.Users[0].Username != "Alice0" || res.Users[1].Username != "Alice2" {
+		t.Fatalf("unexpected order: %+v", res.Users)
+	}
+}
+
+func TestUserCreateAndUpdateRejectCaseInsensitiveDuplicates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupUserListDB(t)
+	if errCreate := db.Create(&models.User{Username: "Alice", Email: "alice@example.com", Password: "x"}).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	bob := models.User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	if errCreate := db.Create(&bob).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+
+	handler := NewUserHandler(db)
+	r := gin.New()
+	r.POST("/users", handler.Create)
+	r.PUT("/users/:id", handler.Update)
+	send := func(method, target, body string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(http.MethodPost, "/users", `{"username":"alice","password":"pw"}`); code != http.StatusConflict {
+		t.Fatalf("expected username conflict, got %d", code)
+	}
+	if code := send(http.MethodPost, "/users", `{"username":"carol","email":"ALICE@example.com","password":"pw"}`); code != http.StatusConflict {
+		t.Fatalf("expected email conflict, got %d", code)
+	}
+	if code := send(http.MethodPost, "/users", `{"username":"carol","email":"carol@example.com","password":"pw"}`); code != http.StatusCreated {
+		t.Fatalf("expected create to succeed, got %d", code)
+	}
+	target := fmt.Sprintf("/users/%d", bob.ID)
+	if code := send(http.MethodPut, target, `{"username":"ALICE"}`); code != http.StatusConflict {
+		t.Fatalf("expected update conflict, got %d", code)
+	}
+	if code := send(http.MethodPut, target, `{"username":"Bob","email":"BOB@example.com"}`); code != http.StatusOK {
+		t.Fatalf("expected re-casing own identity to succeed, got %d", code)
+	}
+}
+
+func TestUserListLastActiveTieBreakFollowsOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupUserListDB(t)
+	usedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		user := models.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "x"}
+		if errCreate := db.Create(&user).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+		if errCreate := db.Create(&models.APIKey{UserID: &user.ID, Name: "key", APIKey: fmt.Sprintf("sk-%d", i), Active: true, LastUsedAt: &usedAt}).Error; errCreate != nil {
+			t.Fatalf("create key: %v", errCreate)
+		}
+	}
+
+	handler := NewUserHandler(db)
+	list := func(order string) []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v0/admin/users?sort=last_active&order="+order, nil)
+		handler.List(c)
+		var res struct {
+			Users []struct {
+				Username string `json:"username"`
+			} `json:"users"`
+		}
+		if errDecode := json.NewDecoder(w.Body).Decode(&res); w.Code != http.StatusOK || errDecode != nil {
+			t.Fatalf("expected users, got %d: %s", w.Code, w.Body.String())
+		}
+		names := make([]string, 0, len(res.Users))
+		for _, u := range res.Users {
+			names = append(names, u.Username)
+		}
+		return names
+	}
+
+	if got := strings.Join(list("asc"), ","); got != "user0,user1,user2" {
+		t.Fatalf("expected ascending tie-break, got %s", got)
+	}
+	if got := strings.Join(list("desc"), ","); got != "user2,user1,user0" {
+		t.Fatalf("expected descending tie-break, got %s", got)
+	}
+}
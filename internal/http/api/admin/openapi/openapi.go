@@ -0,0 +1,376 @@
+// Package openapi builds an OpenAPI 3 document for the admin API from its route list and
+// the request and response types registered for each route.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Version is the OpenAPI version of built documents.
+const Version = "3.0.3"
+
+// Route is one admin API route.
+type Route struct {
+	Method         string // HTTP method.
+	Path           string // Gin path, e.g. /v0/admin/users/:id.
+	Summary        string // Short description.
+	Tag            string // Group the route is listed under.
+	Permission     string // Permission key required to call the route; empty for routes open to every admin.
+	SuperAdminOnly bool   // Whether only super admins may call the route.
+	Public         bool   // Whether the route is reachable without a session.
+}
+
+// Operation describes the payloads of a route. Fields left nil are documented as free-form
+// JSON objects.
+type Operation struct {
+	Query     any  // Struct whose form tags list the query parameters.
+	Body      any  // JSON request body.
+	Multipart bool // Whether the request is a multipart form instead of JSON.
+	Response  any  // JSON body of the success response.
+	Status    int  // Success status; 0 means 200.
+}
+
+// File marks a file field of a multipart request body.
+type File struct{}
+
+// Document is an OpenAPI document ready to be encoded as JSON.
+type Document map[string]any
+
+// pathParam matches gin path parameters.
+var pathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// Build returns the document for routes. ops holds the payloads of routes keyed by
+// method and path, as built by OperationKey.
+func Build(title, version string, routes []Route, ops map[string]Operation) Document {
+	b := newSchemaBuilder()
+	b.components["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error": map[string]any{"type": "string"},
+			"code":  map[string]any{"type": "string"},
+			"field": map[string]any{"type": "string"},
+		},
+	}
+
+	paths := make(map[string]any)
+	operationIDs := make(map[string]int)
+	for _, route := range routes {
+		op := ops[OperationKey(route.Method, route.Path)]
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		item, _ := paths[path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = b.operation(route, op, operationIDs)
+	}
+
+	return Document{
+		"openapi": Version,
+		"info":    map[string]any{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": b.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []any{}}},
+	}
+}
+
+// OperationKey returns the key of a route in the operations passed to Build.
+func OperationKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// operation builds the operation object of route.
+func (b *schemaBuilder) operation(route Route, op Operation, operationIDs map[string]int) map[string]any {
+	out := map[string]any{
+		"summary":     route.Summary,
+		"operationId": operationID(route, operationIDs),
+	}
+	if route.Tag != "" {
+		out["tags"] = []string{route.Tag}
+	}
+	if route.Public {
+		out["security"] = []any{}
+	}
+	if route.Permission != "" {
+		out["x-permission"] = route.Permission
+	}
+	if route.SuperAdminOnly {
+		out["x-super-admin-only"] = true
+	}
+
+	var params []any
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		params = append(params, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   pathParamSchema(match[1]),
+		})
+	}
+	if op.Query != nil {
+		params = append(params, b.queryParams(reflect.TypeOf(op.Query))...)
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	switch {
+	case op.Multipart:
+		body := map[string]any{"type": "object"}
+		if op.Body != nil {
+			body = b.schema(reflect.TypeOf(op.Body))
+		}
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"multipart/form-data": map[string]any{"schema": body}},
+		}
+	case op.Body != nil:
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(op.Body))}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := map[string]any{"type": "object"}
+	if op.Response != nil {
+		response = b.schema(reflect.TypeOf(op.Response))
+	}
+	out["responses"] = map[string]any{
+		strconv.Itoa(status): map[string]any{
+			"description": http.StatusText(status),
+			"content":     map[string]any{"application/json": map[string]any{"schema": response}},
+		},
+		"default": map[string]any{
+			"description": "Error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": ref("Error")}},
+		},
+	}
+	return out
+}
+
+// operationID derives a unique camel case operation ID from the route summary.
+func operationID(route Route, seen map[string]int) string {
+	var sb strings.Builder
+	for i, word := range strings.FieldsFunc(route.Summary, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if i == 0 {
+			sb.WriteString(strings.ToLower(word[:1]) + word[1:])
+			continue
+		}
+		sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	id := sb.String()
+	if id == "" {
+		id = strings.ToLower(route.Method)
+	}
+	seen[id]++
+	if n := seen[id]; n > 1 {
+		id += strconv.Itoa(n)
+	}
+	return id
+}
+
+// pathParamSchema types ids as integers and every other path parameter as a string.
+func pathParamSchema(name string) map[string]any {
+	if name == "id" || strings.HasSuffix(name, "_id") {
+		return map[string]any{"type": "integer", "format": "int64"}
+	}
+	return map[string]any{"type": "string"}
+}
+
+// schemaBuilder turns Go types into schemas, collecting named structs as components.
+type schemaBuilder struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+// newSchemaBuilder constructs an empty schemaBuilder.
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: make(map[string]any), names: make(map[reflect.Type]string)}
+}
+
+var (
+	fileType      = reflect.TypeOf(File{})
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// ref returns a reference to a component schema.
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// schema returns the schema of t.
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	if t.Kind() == reflect.Pointer {
+		inner := b.schema(t.Elem())
+		if _, isRef := inner["$ref"]; isRef {
+			return map[string]any{"allOf": []any{inner}, "nullable": true}
+		}
+		out := make(map[string]any, len(inner)+1)
+		for k, v := range inner {
+			out[k] = v
+		}
+		out["nullable"] = true
+		return out
+	}
+	if t == fileType {
+		return map[string]any{"type": "string", "format": "binary"}
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	// Types with their own JSON encoding, such as raw JSON columns, are free-form.
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = b.componentName(t)
+			b.names[t] = name
+			// Reserve the name first so recursive types terminate.
+			b.components[name] = map[string]any{}
+			b.components[name] = b.object(t)
+		}
+		return ref(name)
+	default:
+		return map[string]any{}
+	}
+}
+
+// componentName names the component of a struct type, exported and unique.
+func (b *schemaBuilder) componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := b.components[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	for i := 2; ; i++ {
+		candidate := name + strconv.Itoa(i)
+		if _, taken := b.components[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+// object returns the object schema of a struct, following its json tags.
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	b.addFields(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// addFields adds the JSON fields of t to properties, flattening embedded structs.
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+	}
+}
+
+// jsonFieldName returns the name set by the json tag of field and whether the field is
+// left out of the JSON encoding.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
+
+// queryParams lists the query parameters declared by the form tags of t.
+func (b *schemaBuilder) queryParams(t reflect.Type) []any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []any
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("form")
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		schema := b.schema(field.Type)
+		for _, option := range parts[1:] {
+			if value, ok := strings.CutPrefix(option, "default="); ok {
+				schema["default"] = value
+				if n, errAtoi := strconv.Atoi(value); errAtoi == nil {
+					schema["default"] = n
+				}
+			}
+		}
+		params = append(params, map[string]any{"name": parts[0], "in": "query", "schema": schema})
+	}
+	return params
+}
@@ -0,0 +1,95 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type widget struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Note      *string   `json:"note,omitempty"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	Parts     []widget  `json:"parts"`
+}
+
+type widgetQuery struct {
+	Page int    `form:"page,default=1"`
+	Name string `form:"name"`
+}
+
+func TestBuild(t *testing.T) {
+	routes := []Route{
+		{Method: "GET", Path: "/v0/admin/widgets", Summary: "List Widgets", Tag: "Widgets", Permission: "GET /v0/admin/widgets"},
+		{Method: "PUT", Path: "/v0/admin/widgets/:id", Summary: "Update Widget", Tag: "Widgets", SuperAdminOnly: true},
+		{Method: "POST", Path: "/v0/admin/login", Summary: "Log In", Public: true},
+	}
+	ops := map[string]Operation{
+		OperationKey("GET", "/v0/admin/widgets"): {Query: widgetQuery{}, Response: struct {
+			Items []widget `json:"items"`
+		}{}},
+		OperationKey("PUT", "/v0/admin/widgets/:id"): {Body: widget{}, Status: 201},
+	}
+	raw, errMarshal := json.Marshal(Build("Test", "1.0", routes, ops))
+	if errMarshal != nil {
+		t.Fatalf("marshal: %v", errMarshal)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string           `json:"operationId"`
+			Permission  string           `json:"x-permission"`
+			SuperAdmin  bool             `json:"x-super-admin-only"`
+			Security    []any            `json:"security"`
+			Parameters  []map[string]any `json:"parameters"`
+			RequestBody map[string]any   `json:"requestBody"`
+			Responses   map[string]any   `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if errDecode := json.Unmarshal(raw, &doc); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+
+	list := doc.Paths["/v0/admin/widgets"]["get"]
+	if list.OperationID != "listWidgets" || list.Permission != "GET /v0/admin/widgets" {
+		t.Fatalf("unexpected list operation %+v", list)
+	}
+	if len(list.Parameters) != 2 || list.Parameters[0]["name"] != "page" || list.Parameters[0]["schema"].(map[string]any)["default"] != float64(1) {
+		t.Fatalf("unexpected query parameters %v", list.Parameters)
+	}
+
+	update, ok := doc.Paths["/v0/admin/widgets/{id}"]["put"]
+	if !ok {
+		t.Fatalf("expected gin path parameters to be converted, got %v", doc.Paths)
+	}
+	if !update.SuperAdmin || update.RequestBody == nil || update.Responses["201"] == nil || update.Responses["default"] == nil {
+		t.Fatalf("unexpected update operation %+v", update)
+	}
+	if update.Parameters[0]["in"] != "path" || update.Parameters[0]["schema"].(map[string]any)["type"] != "integer" {
+		t.Fatalf("expected an integer id path parameter, got %v", update.Parameters)
+	}
+
+	if login := doc.Paths["/v0/admin/login"]["post"]; login.Security == nil || len(login.Security) != 0 {
+		t.Fatalf("expected public routes to clear security, got %+v", login)
+	}
+
+	w, ok := doc.Components.Schemas["Widget"]
+	if !ok {
+		t.Fatalf("expected a Widget component, got %v", doc.Components.Schemas)
+	}
+	if _, hidden := w.Properties["Secret"]; hidden {
+		t.Fatal("expected json:\"-\" fields to be skipped")
+	}
+	if w.Properties["note"]["nullable"] != true || w.Properties["created_at"]["format"] != "date-time" {
+		t.Fatalf("unexpected widget properties %v", w.Properties)
+	}
+	if w.Properties["parts"]["items"].(map[string]any)["$ref"] != "#/components/schemas/Widget" {
+		t.Fatalf("expected recursive types to reference their component, got %v", w.Properties["parts"])
+	}
+}
@@ -14,6 +14,19 @@ type Definition struct {
 	Path   string `json:"path"`
 	Label  string `json:"label"`
 	Module string `json:"module"`
+	// SuperAdminOnly marks destructive routes that only super admins may call; they
+	// cannot be granted to other admins.
+	SuperAdminOnly bool `json:"super_admin_only"`
+}
+
+// Write reports whether the permission allows changes, judged by its HTTP method.
+func (d Definition) Write() bool {
+	switch d.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	default:
+		return true
+	}
 }
 
 // Key builds a permission key from method and path.
@@ -54,9 +67,13 @@ func ValidatePermissions(perms []string) error {
 		if trimmed == "" {
 			continue
 		}
-		if _, ok := allowed[trimmed]; !ok {
+		def, ok := allowed[trimmed]
+		if !ok {
 			return fmt.Errorf("invalid permission: %s", trimmed)
 		}
+		if def.SuperAdminOnly {
+			return fmt.Errorf("permission is reserved for super admins: %s", trimmed)
+		}
 	}
 	return nil
 }
@@ -92,6 +109,21 @@ func HasPermission(perms []string, key string) bool {
 	return false
 }
 
+// Missing returns the permissions in perms that held does not contain, in order.
+func Missing(perms, held []string) []string {
+	have := make(map[string]struct{}, len(held))
+	for _, perm := range held {
+		have[perm] = struct{}{}
+	}
+	out := make([]string, 0)
+	for _, perm := range perms {
+		if _, ok := have[perm]; !ok {
+			out = append(out, perm)
+		}
+	}
+	return out
+}
+
 // Definitions returns a copy of all permission definitions.
 func Definitions() []Definition {
 	out := make([]Definition, len(definitions))
@@ -108,6 +140,24 @@ func DefinitionMap() map[string]Definition {
 	return out
 }
 
+// tokenFlowProviders maps admin token-request routes to their OAuth provider.
+var tokenFlowProviders = map[string]string{
+	"/v0/admin/tokens/anthropic":    "anthropic",
+	"/v0/admin/tokens/gemini":       "gemini",
+	"/v0/admin/tokens/codex":        "codex",
+	"/v0/admin/tokens/antigravity":  "antigravity",
+	"/v0/admin/tokens/qwen":         "qwen",
+	"/v0/admin/tokens/iflow":        "iflow",
+	"/v0/admin/tokens/iflow-cookie": "iflow",
+}
+
+// TokenFlowProvider returns the OAuth provider served by an admin token-request route.
+// Shared routes such as the auth status check report false.
+func TokenFlowProvider(path string) (string, bool) {
+	provider, ok := tokenFlowProviders[path]
+	return provider, ok
+}
+
 // newDefinition builds a Definition with a normalized key.
 func newDefinition(method, path, label, module string) Definition {
 	upperMethod := strings.ToUpper(method)
@@ -120,15 +170,26 @@ func newDefinition(method, path, label, module string) Definition {
 	}
 }
 
+// newSuperAdminDefinition builds a Definition restricted to super admins.
+func newSuperAdminDefinition(method, path, label, module string) Definition {
+	def := newDefinition(method, path, label, module)
+	def.SuperAdminOnly = true
+	return def
+}
+
 // definitions is the ordered list of permission definitions.
 var definitions = []Definition{
 	newDefinition("GET", "/v0/admin/dashboard/kpi", "View KPI", "Dashboard"),
 	newDefinition("GET", "/v0/admin/dashboard/traffic", "View Traffic", "Dashboard"),
 	newDefinition("GET", "/v0/admin/dashboard/cost-distribution", "View Cost Distribution", "Dashboard"),
+	newDefinition("GET", "/v0/admin/events/stream", "Stream Live Events", "Dashboard"),
+	newDefinition("GET", "/v0/admin/dashboard/top-consumers", "View Top Consumers", "Dashboard"),
+	newDefinition("GET", "/v0/admin/dashboard/top-models", "View Top Models", "Dashboard"),
 	newDefinition("GET", "/v0/admin/dashboard/model-health", "View Model Health", "Dashboard"),
 	newDefinition("GET", "/v0/admin/dashboard/transactions", "View Recent Transactions", "Dashboard"),
 
 	newDefinition("POST", "/v0/admin/users", "Create User", "Users"),
+	newDefinition("POST", "/v0/admin/users/import", "Import Users", "Users"),
 	newDefinition("GET", "/v0/admin/users", "List Users", "Users"),
 	newDefinition("GET", "/v0/admin/users/:id", "Get User", "Users"),
 	newDefinition("PUT", "/v0/admin/users/:id", "Update User", "Users"),
@@ -136,6 +197,11 @@ var definitions = []Definition{
 	newDefinition("POST", "/v0/admin/users/:id/disable", "Disable User", "Users"),
 	newDefinition("POST", "/v0/admin/users/:id/enable", "Enable User", "Users"),
 	newDefinition("PUT", "/v0/admin/users/:id/password", "Change User Password", "Users"),
+	newDefinition("POST", "/v0/admin/users/:id/impersonate", "Impersonate User", "Users"),
+	newDefinition("POST", "/v0/admin/users/:id/adjustments", "Create Balance Adjustment", "Users"),
+	newDefinition("GET", "/v0/admin/users/:id/adjustments", "List Balance Adjustments", "Users"),
+	newSuperAdminDefinition("POST", "/v0/admin/users/:id/export", "Export User Data", "Users"),
+	newSuperAdminDefinition("POST", "/v0/admin/users/:id/purge", "Purge User Data", "Users"),
 
 	newDefinition("POST", "/v0/admin/user-groups", "Create User Group", "User Groups"),
 	newDefinition("GET", "/v0/admin/user-groups", "List User Groups", "User Groups"),
@@ -151,6 +217,8 @@ var definitions = []Definition{
 	newDefinition("DELETE", "/v0/admin/auth-groups/:id", "Delete Auth Group", "Auth Groups"),
 	newDefinition("POST", "/v0/admin/auth-groups/:id/default", "Set Default Auth Group", "Auth Groups"),
 
+	newDefinition("GET", "/v0/admin/tags", "List Tags", "Tags"),
+
 	newDefinition("POST", "/v0/admin/auth-files", "Create Auth File", "Auth Files"),
 	newDefinition("POST", "/v0/admin/auth-files/import", "Import Auth Files", "Auth Files"),
 	newDefinition("GET", "/v0/admin/auth-files", "List Auth Files", "Auth Files"),
@@ -159,14 +227,29 @@ var definitions = []Definition{
 	newDefinition("DELETE", "/v0/admin/auth-files/:id", "Delete Auth File", "Auth Files"),
 	newDefinition("POST", "/v0/admin/auth-files/:id/available", "Set Auth File Available", "Auth Files"),
 	newDefinition("POST", "/v0/admin/auth-files/:id/unavailable", "Set Auth File Unavailable", "Auth Files"),
+	newDefinition("POST", "/v0/admin/auth-files/:id/clear-cooldown", "Clear Auth Cooldown", "Auth Files"),
 	newDefinition("GET", "/v0/admin/auth-files/types", "List Auth File Types", "Auth Files"),
+	newDefinition("GET", "/v0/admin/auth-files/stats", "List Auth File Stats", "Auth Files"),
+	newDefinition("GET", "/v0/admin/auth-files/duplicates", "List Duplicate Auth Files", "Auth Files"),
+	newDefinition("GET", "/v0/admin/auth-files/trash", "List Trashed Auth Files", "Auth Files"),
+	newDefinition("POST", "/v0/admin/auth-files/:id/restore", "Restore Auth File", "Auth Files"),
+	newDefinition("GET", "/v0/admin/auth-files/:id/stats", "Get Auth File Stats", "Auth Files"),
+	newDefinition("PATCH", "/v0/admin/auth-files/priorities", "Update Auth File Priorities", "Auth Files"),
+	newDefinition("POST", "/v0/admin/auth-files/batch-move-group", "Move Auth Files To Groups", "Auth Files"),
+	newDefinition("POST", "/v0/admin/auth-files/check", "Check Auth Files", "Auth Files"),
 
 	newDefinition("GET", "/v0/admin/quotas", "List Quotas", "Quota"),
+	newDefinition("GET", "/v0/admin/quotas/:auth_id/history", "Quota History", "Quota"),
 
 	newDefinition("POST", "/v0/admin/model-mappings", "Create Model Mapping", "Models"),
 	newDefinition("GET", "/v0/admin/model-mappings", "List Model Mappings", "Models"),
 	newDefinition("GET", "/v0/admin/model-mappings/available-models", "List Available Models", "Models"),
+	newDefinition("GET", "/v0/admin/model-mappings/export", "Export Model Mappings", "Models"),
+	newDefinition("GET", "/v0/admin/model-mappings/validate", "Validate Model Mappings", "Models"),
+	newDefinition("POST", "/v0/admin/model-mappings/import", "Import Model Mappings", "Models"),
 	newDefinition("GET", "/v0/admin/model-references/price", "Get Model Reference Price", "Models"),
+	newDefinition("GET", "/v0/admin/models/effective", "View Effective Models", "Models"),
+	newDefinition("GET", "/v0/admin/models/:model/availability", "View Model Availability", "Models"),
 	newDefinition("GET", "/v0/admin/model-mappings/:id", "Get Model Mapping", "Models"),
 	newDefinition("PUT", "/v0/admin/model-mappings/:id", "Update Model Mapping", "Models"),
 	newDefinition("DELETE", "/v0/admin/model-mappings/:id", "Delete Model Mapping", "Models"),
@@ -176,9 +259,11 @@ var definitions = []Definition{
 	newDefinition("POST", "/v0/admin/model-mappings/:id/payload-rules", "Create Model Payload Rule", "Models"),
 	newDefinition("PUT", "/v0/admin/model-mappings/:id/payload-rules/:rule_id", "Update Model Payload Rule", "Models"),
 	newDefinition("DELETE", "/v0/admin/model-mappings/:id/payload-rules/:rule_id", "Delete Model Payload Rule", "Models"),
+	newDefinition("GET", "/v0/admin/model-mappings/:id/payload-rules/:rule_id/preview", "Preview Model Payload Rule", "Models"),
 
 	newDefinition("POST", "/v0/admin/api-keys", "Create API Key", "API Keys"),
 	newDefinition("GET", "/v0/admin/api-keys", "List API Keys", "API Keys"),
+	newDefinition("GET", "/v0/admin/api-keys/idle", "List Idle API Keys", "API Keys"),
 	newDefinition("DELETE", "/v0/admin/api-keys/:id", "Revoke API Key", "API Keys"),
 	newDefinition("POST", "/v0/admin/users/:id/api-keys", "Create User API Key", "API Keys"),
 	newDefinition("GET", "/v0/admin/users/:id/api-keys", "List User API Keys", "API Keys"),
@@ -187,6 +272,7 @@ var definitions = []Definition{
 	newDefinition("GET", "/v0/admin/provider-api-keys", "List Provider API Keys", "Provider API Keys"),
 	newDefinition("PUT", "/v0/admin/provider-api-keys/:id", "Update Provider API Key", "Provider API Keys"),
 	newDefinition("DELETE", "/v0/admin/provider-api-keys/:id", "Delete Provider API Key", "Provider API Keys"),
+	newDefinition("POST", "/v0/admin/provider-api-keys/:id/reactivate", "Reactivate Provider API Key", "Provider API Keys"),
 
 	newDefinition("POST", "/v0/admin/proxies", "Create Proxy", "Proxies"),
 	newDefinition("POST", "/v0/admin/proxies/batch", "Batch Create Proxies", "Proxies"),
@@ -197,10 +283,16 @@ var definitions = []Definition{
 	newDefinition("POST", "/v0/admin/prepaid-cards", "Create Prepaid Card", "Prepaid Cards"),
 	newDefinition("POST", "/v0/admin/prepaid-cards/batch", "Batch Create Prepaid Cards", "Prepaid Cards"),
 	newDefinition("GET", "/v0/admin/prepaid-cards", "List Prepaid Cards", "Prepaid Cards"),
+	newDefinition("GET", "/v0/admin/prepaid-cards/export", "Export Prepaid Cards", "Prepaid Cards"),
 	newDefinition("GET", "/v0/admin/prepaid-cards/:id", "Get Prepaid Card", "Prepaid Cards"),
 	newDefinition("PUT", "/v0/admin/prepaid-cards/:id", "Update Prepaid Card", "Prepaid Cards"),
 	newDefinition("DELETE", "/v0/admin/prepaid-cards/:id", "Delete Prepaid Card", "Prepaid Cards"),
 
+	newDefinition("POST", "/v0/admin/invite-codes", "Create Invite Codes", "Invite Codes"),
+	newDefinition("GET", "/v0/admin/invite-codes", "List Invite Codes", "Invite Codes"),
+	newDefinition("PUT", "/v0/admin/invite-codes/:id", "Update Invite Code", "Invite Codes"),
+	newDefinition("DELETE", "/v0/admin/invite-codes/:id", "Delete Invite Code", "Invite Codes"),
+
 	newDefinition("POST", "/v0/admin/bills", "Create Bill", "Bills"),
 	newDefinition("GET", "/v0/admin/bills", "List Bills", "Bills"),
 	newDefinition("GET", "/v0/admin/bills/:id", "Get Bill", "Bills"),
@@ -211,6 +303,7 @@ var definitions = []Definition{
 
 	newDefinition("POST", "/v0/admin/billing-rules", "Create Billing Rule", "Billing Rules"),
 	newDefinition("GET", "/v0/admin/billing-rules", "List Billing Rules", "Billing Rules"),
+	newDefinition("GET", "/v0/admin/billing-rules/resolve", "Resolve Billing Rule", "Billing Rules"),
 	newDefinition("GET", "/v0/admin/billing-rules/:id", "Get Billing Rule", "Billing Rules"),
 	newDefinition("PUT", "/v0/admin/billing-rules/:id", "Update Billing Rule", "Billing Rules"),
 	newDefinition("DELETE", "/v0/admin/billing-rules/:id", "Delete Billing Rule", "Billing Rules"),
@@ -231,6 +324,17 @@ var definitions = []Definition{
 	newDefinition("DELETE", "/v0/admin/settings/:key", "Delete Setting", "Settings"),
 
 	newDefinition("GET", "/v0/admin/usage", "View Usage", "Usage"),
+	newDefinition("POST", "/v0/admin/usage/relink-auths", "Relink Usage Auths", "Usage"),
+	newDefinition("POST", "/v0/admin/usage/export", "Export Usage", "Usage"),
+	newDefinition("GET", "/v0/admin/pending-actions", "List Pending Actions", "Approvals"),
+	newDefinition("POST", "/v0/admin/pending-actions/:id/approve", "Approve Pending Action", "Approvals"),
+	newDefinition("POST", "/v0/admin/pending-actions/:id/reject", "Reject Pending Action", "Approvals"),
+	newDefinition("GET", "/v0/admin/jobs", "List Jobs", "Jobs"),
+	newDefinition("GET", "/v0/admin/jobs/:id", "Get Job", "Jobs"),
+	newDefinition("GET", "/v0/admin/jobs/:id/download", "Download Job File", "Jobs"),
+	newSuperAdminDefinition("POST", "/v0/admin/backups", "Create Backup", "Backups"),
+	newSuperAdminDefinition("GET", "/v0/admin/backups", "List Backups", "Backups"),
+	newSuperAdminDefinition("GET", "/v0/admin/backups/:name", "Download Backup", "Backups"),
 	newDefinition("GET", "/v0/admin/billing/summary", "View Billing Summary", "Billing"),
 
 	newDefinition("POST", "/v0/admin/admins", "Create Administrator", "Administrators"),
@@ -26,7 +26,8 @@ func adminPermissionMiddleware(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		key := permissions.Key(c.Request.Method, path)
-		if _, ok := permissionMap[key]; !ok {
+		definition, ok := permissionMap[key]
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 			return
 		}
@@ -60,6 +61,10 @@ func adminPermissionMiddleware(db *gorm.DB) gin.HandlerFunc {
 			c.Next()
 			return
 		}
+		if definition.SuperAdminOnly {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			return
+		}
 
 		if !permissions.HasPermission(adminPermissions, key) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
@@ -5,24 +5,39 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/audit"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/front/handlers"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelregistry"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
-// RegisterFrontRoutes registers public and authenticated front-end routes.
-func RegisterFrontRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, modelStore *modelregistry.Store) {
+// RegisterFrontRoutes registers public and authenticated front-end routes. Read-only
+// usage, log and dashboard handlers use the replica from dbs when one is configured.
+func RegisterFrontRoutes(r *gin.Engine, dbs dbutil.DBProvider, jwtCfg config.JWTConfig, modelStore *modelregistry.Store) {
+	db := dbs.Write()
 	if r == nil || db == nil {
 		return
 	}
 
 	front := r.Group("/v0/front")
 
+	registrationHandler := handlers.NewRegistrationHandler(db)
+	front.POST("/register", registrationHandler.Register)
+
+	userGroup := r.Group("/v0/user")
+	userGroup.POST("/register", registrationHandler.Register)
+	userGroup.GET("/verify", registrationHandler.Verify)
+	userGroup.POST("/verify/resend", registrationHandler.ResendVerification)
+
+	alertHandler := handlers.NewAlertFrontHandler(db)
+	userGroup.GET("/alerts", userAuthMiddleware(db, jwtCfg), alertHandler.List)
+
 	authHandler := handlers.NewAuthHandler(db, jwtCfg)
-	front.POST("/register", authHandler.Register)
 	front.POST("/login", authHandler.Login)
 	front.POST("/login/prepare", authHandler.LoginPrepare)
 	front.POST("/login/totp", authHandler.LoginTOTP)
@@ -36,7 +51,7 @@ func RegisterFrontRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, mo
 
 	profileHandler := handlers.NewProfileHandler(db)
 	authed.GET("/profile", profileHandler.Get)
-	authed.PUT("/profile/password", profileHandler.ChangePassword)
+	authed.PUT("/profile/password", denyImpersonation(), profileHandler.ChangePassword)
 
 	webAuthn, errWebAuthn := security.NewWebAuthn()
 	if errWebAuthn != nil {
@@ -44,12 +59,14 @@ func RegisterFrontRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, mo
 	}
 	mfaHandler := handlers.NewMFAHandler(db, webAuthn)
 	authed.GET("/mfa/status", mfaHandler.Status)
-	authed.POST("/mfa/totp/prepare", mfaHandler.PrepareTOTP)
-	authed.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
-	authed.POST("/mfa/totp/disable", mfaHandler.DisableTOTP)
-	authed.POST("/mfa/passkey/options", mfaHandler.BeginPasskeyRegistration)
-	authed.POST("/mfa/passkey/verify", mfaHandler.FinishPasskeyRegistration)
-	authed.POST("/mfa/passkey/disable", mfaHandler.DisablePasskey)
+	mfaWrite := authed.Group("/mfa")
+	mfaWrite.Use(denyImpersonation())
+	mfaWrite.POST("/totp/prepare", mfaHandler.PrepareTOTP)
+	mfaWrite.POST("/totp/confirm", mfaHandler.ConfirmTOTP)
+	mfaWrite.POST("/totp/disable", mfaHandler.DisableTOTP)
+	mfaWrite.POST("/passkey/options", mfaHandler.BeginPasskeyRegistration)
+	mfaWrite.POST("/passkey/verify", mfaHandler.FinishPasskeyRegistration)
+	mfaWrite.POST("/passkey/disable", mfaHandler.DisablePasskey)
 
 	prepaidHandler := handlers.NewPrepaidCardFrontHandler(db)
 	authed.GET("/prepaid-card", prepaidHandler.GetCurrent)
@@ -73,10 +90,10 @@ func RegisterFrontRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, mo
 	authed.POST("/api-keys/:id/renew", apiKeyHandler.Renew)
 	authed.POST("/api-keys/:id/regenerate", apiKeyHandler.Regenerate)
 
-	usageHandler := handlers.NewUsageHandler(db)
+	usageHandler := handlers.NewUsageHandler(dbs)
 	authed.GET("/usage/stats", usageHandler.Stats)
 
-	dashboardHandler := handlers.NewDashboardHandler(db)
+	dashboardHandler := handlers.NewDashboardHandler(dbs)
 	authed.GET("/dashboard/kpi", dashboardHandler.KPI)
 	authed.GET("/dashboard/traffic", dashboardHandler.Traffic)
 	authed.GET("/dashboard/cost-distribution", dashboardHandler.CostDistribution)
@@ -86,7 +103,7 @@ func RegisterFrontRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, mo
 	modelPricingHandler := handlers.NewModelPricingHandler(db, modelStore)
 	authed.GET("/models/pricing", modelPricingHandler.List)
 
-	logsHandler := handlers.NewLogsHandler(db)
+	logsHandler := handlers.NewLogsHandler(dbs)
 	authed.GET("/logs", logsHandler.List)
 	authed.GET("/logs/stats", logsHandler.Stats)
 	authed.GET("/logs/trend", logsHandler.Trend)
@@ -98,40 +115,80 @@ func RegisterFrontRoutes(r *gin.Engine, db *gorm.DB, jwtCfg config.JWTConfig, mo
 // userAuthMiddleware validates user JWTs and loads the user into context.
 func userAuthMiddleware(db *gorm.DB, jwtCfg config.JWTConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+		if !AuthenticateUser(c, db, jwtCfg) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token == authHeader {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization format"})
-			return
-		}
-		token = strings.TrimSpace(token)
-		if token == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "empty token"})
-			return
-		}
+// AuthenticateUser validates the user JWT in the Authorization header and stores the user
+// in c. On failure it aborts c with the error response and returns false.
+func AuthenticateUser(c *gin.Context, db *gorm.DB, jwtCfg config.JWTConfig) bool {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+		return false
+	}
 
-		claims, errJWT := security.ParseToken(jwtCfg.Secret, token)
-		if errJWT != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			return
-		}
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == authHeader {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization format"})
+		return false
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "empty token"})
+		return false
+	}
 
-		var user models.User
-		if errFind := db.WithContext(c.Request.Context()).First(&user, claims.UserID).Error; errFind != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
-			return
+	claims, errJWT := security.ParseToken(jwtCfg.Secret, token)
+	if errJWT != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return false
+	}
+
+	var user models.User
+	if errFind := db.WithContext(c.Request.Context()).First(&user, claims.UserID).Error; errFind != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return false
+	}
+	if reason := user.SignInBlock(); reason != "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": reason})
+		return false
+	}
+
+	c.Set("userID", user.ID)
+	if claims.ImpersonatedBy != 0 {
+		c.Set("impersonatedBy", claims.ImpersonatedBy)
+		targetID := user.ID
+		if errAudit := audit.Record(c.Request.Context(), db, audit.Entry{
+			ActorType:  audit.ActorAdmin,
+			ActorID:    claims.ImpersonatedBy,
+			Action:     audit.ActionImpersonatedRequest,
+			TargetType: audit.TargetUser,
+			TargetID:   &targetID,
+			Detail: map[string]any{
+				"method": c.Request.Method,
+				"path":   c.FullPath(),
+			},
+			IP: c.ClientIP(),
+		}); errAudit != nil {
+			log.WithError(errAudit).Error("front: record impersonation audit failed")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "record audit failed"})
+			return false
 		}
-		if user.Disabled {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "user disabled"})
+	}
+	return true
+}
+
+// denyImpersonation rejects requests made with an admin impersonation token.
+func denyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonated := c.Get("impersonatedBy"); impersonated {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
 			return
 		}
-
-		c.Set("userID", user.ID)
 		c.Next()
 	}
 }
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultAlertListLimit is the number of alerts returned when no limit is given.
+	defaultAlertListLimit = 20
+	// maxAlertListLimit caps the alerts returned in one response.
+	maxAlertListLimit = 100
+)
+
+// AlertFrontHandler handles usage alert endpoints for users.
+type AlertFrontHandler struct {
+	db *gorm.DB
+}
+
+// NewAlertFrontHandler constructs an AlertFrontHandler.
+func NewAlertFrontHandler(db *gorm.DB) *AlertFrontHandler {
+	return &AlertFrontHandler{db: db}
+}
+
+// alertDTO defines the usage alert response payload.
+type alertDTO struct {
+	ID        uint64    `json:"id"`
+	Kind      string    `json:"kind"`
+	Threshold int       `json:"threshold"`
+	Day       string    `json:"day"`
+	Used      float64   `json:"used"`
+	Limit     float64   `json:"limit"`
+	Percent   float64   `json:"percent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// List returns the most recent usage alerts for the authenticated user.
+func (h *AlertFrontHandler) List(c *gin.Context) {
+	userID := getUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit := defaultAlertListLimit
+	if limitQ := strings.TrimSpace(c.Query("limit")); limitQ != "" {
+		parsed, errParse := strconv.Atoi(limitQ)
+		if errParse != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = min(parsed, maxAlertListLimit)
+	}
+
+	var rows []models.UsageAlert
+	if errFind := h.db.WithContext(c.Request.Context()).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&rows).Error; errFind != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query alerts failed"})
+		return
+	}
+
+	out := make([]alertDTO, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, alertDTO{
+			ID:        row.ID,
+			Kind:      row.Kind,
+			Threshold: row.Threshold,
+			Day:       row.Day,
+			Used:      row.UsedAmount,
+			Limit:     row.LimitAmount,
+			Percent:   row.Percent,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": out})
+}
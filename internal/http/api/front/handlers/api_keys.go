@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 	"gorm.io/gorm"
 )
 
@@ -198,7 +199,8 @@ type createAPIKeyRequest struct {
 	ExpiresIn *int   `json:"expires_in_days"`
 }
 
-// Create creates a new API key for the user.
+// Create creates a new API key for the user, up to API_KEY_MAX_PER_USER unrevoked keys.
+// The plaintext token is only returned here.
 func (h *APIKeyHandler) Create(c *gin.Context) {
 	userID := getUserID(c)
 	if userID == 0 {
@@ -217,6 +219,20 @@ func (h *APIKeyHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if limit := internalsettings.GetInt(internalsettings.APIKeyMaxPerUserKey); limit > 0 {
+		var count int64
+		if errCount := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Count(&count).Error; errCount != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "count api keys failed"})
+			return
+		}
+		if count >= int64(limit) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("api key limit reached (%d); revoke an existing key first", limit)})
+			return
+		}
+	}
+
 	token, errGenerate := security.GenerateAPIKey()
 	if errGenerate != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "generate api key failed"})
@@ -24,73 +24,6 @@ func NewAuthHandler(db *gorm.DB, jwtCfg config.JWTConfig) *AuthHandler {
 	return &AuthHandler{db: db, jwtCfg: jwtCfg}
 }
 
-// registerRequest defines the request body for user registration.
-type registerRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-// Register creates a new user account.
-func (h *AuthHandler) Register(c *gin.Context) {
-	var body registerRequest
-	if errBind := c.ShouldBindJSON(&body); errBind != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
-		return
-	}
-	username := strings.TrimSpace(body.Username)
-	if username == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing username"})
-		return
-	}
-	password := strings.TrimSpace(body.Password)
-	if password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing password"})
-		return
-	}
-
-	var exists models.User
-	if errCheck := h.db.WithContext(c.Request.Context()).Where("username = ?", username).First(&exists).Error; errCheck == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
-		return
-	}
-
-	hash, errHash := security.HashPassword(password)
-	if errHash != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "hash password failed"})
-		return
-	}
-
-	now := time.Now().UTC()
-	user := models.User{
-		Username:  username,
-		Email:     strings.TrimSpace(body.Email),
-		Password:  hash,
-		Active:    true,
-		Disabled:  false,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-	var defaultGroup models.UserGroup
-	if errFind := h.db.WithContext(c.Request.Context()).
-		Where("is_default = ?", true).
-		First(&defaultGroup).Error; errFind == nil {
-		user.UserGroupID = models.UserGroupIDs{&defaultGroup.ID}
-	} else if !errors.Is(errFind, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "query default user group failed"})
-		return
-	}
-	if errCreate := h.db.WithContext(c.Request.Context()).Create(&user).Error; errCreate != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "create user failed"})
-		return
-	}
-	c.JSON(http.StatusCreated, gin.H{
-		"id":       user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-	})
-}
-
 // loginRequest defines the request body for login.
 type loginRequest struct {
 	Username string `json:"username"`
@@ -121,13 +54,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	if user.Disabled {
-		c.JSON(http.StatusForbidden, gin.H{"error": "user disabled"})
+	if !security.CheckPassword(user.Password, password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
 
-	if !security.CheckPassword(user.Password, password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	if reason := user.SignInBlock(); reason != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": reason})
 		return
 	}
 
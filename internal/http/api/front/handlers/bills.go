@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -71,7 +72,7 @@ func (h *BillFrontHandler) Create(c *gin.Context) {
 			Clauses(clause.Locking{Strength: "UPDATE"}).
 			Where("redeemed_user_id = ? AND is_enabled = ? AND balance > 0 AND redeemed_at IS NOT NULL", userID, true).
 			Where("(expires_at IS NULL OR expires_at >= ?)", now).
-			Order("expires_at ASC NULLS LAST, redeemed_at ASC NULLS LAST, id ASC").
+			Order(billing.PrepaidDeductionOrder()).
 			Find(&cards).Error; errCards != nil {
 			return errCards
 		}
@@ -129,6 +130,9 @@ func (h *BillFrontHandler) Create(c *gin.Context) {
 		if errCreateBill := tx.WithContext(c.Request.Context()).Create(&bill).Error; errCreateBill != nil {
 			return errCreateBill
 		}
+		if _, errPay := billing.PayOverageDebtFromBill(c.Request.Context(), tx, &bill, bill.LeftQuota); errPay != nil {
+			return errPay
+		}
 		if errRefresh := refreshBillUserGroupIDs(c.Request.Context(), tx, userID); errRefresh != nil {
 			return errRefresh
 		}
@@ -145,6 +149,7 @@ func (h *BillFrontHandler) Create(c *gin.Context) {
 		return
 	}
 
+	billing.NotifyBillEvent(billing.BillEventPaid, &created)
 	c.JSON(http.StatusCreated, h.formatBill(&created))
 }
 
@@ -265,6 +270,7 @@ func (h *BillFrontHandler) formatBill(bill *models.Bill) gin.H {
 		"rate_limit":   bill.RateLimit,
 		"is_enabled":   bill.IsEnabled,
 		"status":       bill.Status,
+		"auto_renew":   bill.AutoRenew,
 		"created_at":   bill.CreatedAt,
 		"updated_at":   bill.UpdatedAt,
 	}
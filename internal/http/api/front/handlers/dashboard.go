@@ -5,18 +5,20 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
-	"gorm.io/gorm"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 )
 
 // DashboardHandler serves dashboard analytics endpoints.
 type DashboardHandler struct {
-	db *gorm.DB
+	dbs dbutil.DBProvider // Primary connection and optional read replica.
 }
 
 // NewDashboardHandler constructs a DashboardHandler.
-func NewDashboardHandler(db *gorm.DB) *DashboardHandler {
-	return &DashboardHandler{db: db}
+func NewDashboardHandler(dbs dbutil.DBProvider) *DashboardHandler {
+	return &DashboardHandler{dbs: dbs}
 }
 
 // kpiResponse defines the KPI response payload.
@@ -29,6 +31,7 @@ type kpiResponse struct {
 	SuccessRateTrend float64 `json:"success_rate_trend"`
 	MtdCostMicros    int64   `json:"mtd_cost_micros"`
 	CostTrend        float64 `json:"cost_trend"`
+	Currency         string  `json:"currency"`
 }
 
 // KPI returns key performance indicators for the dashboard.
@@ -40,7 +43,7 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 	}
 
 	var apiKeyIDs []uint64
-	if errFind := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+	if errFind := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.APIKey{}).
 		Where("user_id = ?", userID).
 		Pluck("id", &apiKeyIDs).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query api keys failed"})
@@ -48,11 +51,11 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 	}
 
 	if len(apiKeyIDs) == 0 {
-		c.JSON(http.StatusOK, kpiResponse{SuccessRate: 100.0})
+		c.JSON(http.StatusOK, kpiResponse{SuccessRate: 100.0, Currency: billing.DisplayCurrency()})
 		return
 	}
 
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
@@ -63,8 +66,8 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 		Failed      int64
 		TotalTokens int64
 	}
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, today).
+	h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+		Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, today.UTC()).
 		Select("COUNT(*) AS total, SUM(CASE WHEN failed THEN 1 ELSE 0 END) AS failed, COALESCE(SUM(total_tokens), 0) AS total_tokens").
 		Scan(&todayStats)
 
@@ -73,21 +76,21 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 		Failed      int64
 		TotalTokens int64
 	}
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("api_key_id IN ? AND requested_at >= ? AND requested_at < ?", apiKeyIDs, yesterday, today).
+	h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+		Where("api_key_id IN ? AND requested_at >= ? AND requested_at < ?", apiKeyIDs, yesterday.UTC(), today.UTC()).
 		Select("COUNT(*) AS total, SUM(CASE WHEN failed THEN 1 ELSE 0 END) AS failed, COALESCE(SUM(total_tokens), 0) AS total_tokens").
 		Scan(&yesterdayStats)
 
 	var mtdCost int64
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, monthStart).
+	h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+		Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, monthStart.UTC()).
 		Select("COALESCE(SUM(cost_micros), 0)").
 		Scan(&mtdCost)
 
 	lastMonthStart := monthStart.AddDate(0, -1, 0)
 	lastMonthSameDay := lastMonthStart.AddDate(0, 0, now.Day()-1)
 	var lastMtdCost int64
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
+	h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
 		Where("api_key_id IN ? AND requested_at >= ? AND requested_at < ?", apiKeyIDs, lastMonthStart, lastMonthSameDay).
 		Select("COALESCE(SUM(cost_micros), 0)").
 		Scan(&lastMtdCost)
@@ -123,6 +126,7 @@ func (h *DashboardHandler) KPI(c *gin.Context) {
 		SuccessRateTrend: successRateTrend,
 		MtdCostMicros:    mtdCost,
 		CostTrend:        costTrend,
+		Currency:         billing.DisplayCurrency(),
 	})
 }
 
@@ -142,14 +146,14 @@ func (h *DashboardHandler) Traffic(c *gin.Context) {
 	}
 
 	var apiKeyIDs []uint64
-	if errFind := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+	if errFind := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.APIKey{}).
 		Where("user_id = ?", userID).
 		Pluck("id", &apiKeyIDs).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query api keys failed"})
 		return
 	}
 
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 
@@ -161,11 +165,11 @@ func (h *DashboardHandler) Traffic(c *gin.Context) {
 		var count int64
 		var errCount int64
 		if len(apiKeyIDs) > 0 {
-			h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-				Where("api_key_id IN ? AND requested_at >= ? AND requested_at < ?", apiKeyIDs, hourStart, hourEnd).
+			h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+				Where("api_key_id IN ? AND requested_at >= ? AND requested_at < ?", apiKeyIDs, hourStart.UTC(), hourEnd.UTC()).
 				Count(&count)
-			h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-				Where("api_key_id IN ? AND requested_at >= ? AND requested_at < ? AND failed = true", apiKeyIDs, hourStart, hourEnd).
+			h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+				Where("api_key_id IN ? AND requested_at >= ? AND requested_at < ? AND failed = true", apiKeyIDs, hourStart.UTC(), hourEnd.UTC()).
 				Count(&errCount)
 		}
 		points[i] = trafficPoint{
@@ -194,7 +198,7 @@ func (h *DashboardHandler) CostDistribution(c *gin.Context) {
 	}
 
 	var apiKeyIDs []uint64
-	if errFind := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+	if errFind := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.APIKey{}).
 		Where("user_id = ?", userID).
 		Pluck("id", &apiKeyIDs).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query api keys failed"})
@@ -206,7 +210,7 @@ func (h *DashboardHandler) CostDistribution(c *gin.Context) {
 		return
 	}
 
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 
@@ -216,8 +220,8 @@ func (h *DashboardHandler) CostDistribution(c *gin.Context) {
 		CostMicros int64
 	}
 	var results []modelCost
-	h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-		Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, monthStart).
+	h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+		Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, monthStart.UTC()).
 		Select("model, COALESCE(SUM(cost_micros), 0) AS cost_micros").
 		Group("model").
 		Order("cost_micros DESC").
@@ -281,7 +285,7 @@ func (h *DashboardHandler) RecentTransactions(c *gin.Context) {
 	}
 
 	var apiKeyIDs []uint64
-	if errFind := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+	if errFind := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.APIKey{}).
 		Where("user_id = ?", userID).
 		Pluck("id", &apiKeyIDs).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query api keys failed"})
@@ -294,7 +298,7 @@ func (h *DashboardHandler) RecentTransactions(c *gin.Context) {
 	}
 
 	var usages []models.Usage
-	h.db.WithContext(c.Request.Context()).
+	h.dbs.Read().WithContext(c.Request.Context()).
 		Where("api_key_id IN ?", apiKeyIDs).
 		Order("requested_at DESC").
 		Limit(20).
@@ -311,7 +315,7 @@ func (h *DashboardHandler) RecentTransactions(c *gin.Context) {
 		transactions = append(transactions, transactionItem{
 			Status:     status,
 			StatusType: statusType,
-			Timestamp:  u.RequestedAt.In(time.Local).Format("2006-01-02 15:04:05"),
+			Timestamp:  u.RequestedAt.In(internalsettings.BillingLocation()).Format("2006-01-02 15:04:05"),
 			Method:     "POST",
 			Model:      u.Model,
 			Tokens:     u.TotalTokens,
@@ -8,18 +8,20 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
-	"gorm.io/gorm"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 )
 
 // LogsHandler handles usage log endpoints.
 type LogsHandler struct {
-	db *gorm.DB
+	dbs dbutil.DBProvider // Primary connection and optional read replica.
 }
 
 // NewLogsHandler constructs a LogsHandler.
-func NewLogsHandler(db *gorm.DB) *LogsHandler {
-	return &LogsHandler{db: db}
+func NewLogsHandler(dbs dbutil.DBProvider) *LogsHandler {
+	return &LogsHandler{dbs: dbs}
 }
 
 // logsListQuery defines query parameters for listing logs.
@@ -72,16 +74,16 @@ func (h *LogsHandler) List(c *gin.Context) {
 
 	ctx := c.Request.Context()
 
-	query := h.db.WithContext(ctx).Model(&models.Usage{}).Where("user_id = ?", userID)
+	query := h.dbs.Read().WithContext(ctx).Model(&models.Usage{}).Where("user_id = ?", userID)
 
 	if q.StartDate != "" {
-		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, time.Local); errParse == nil {
-			query = query.Where("requested_at >= ?", startTime)
+		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, internalsettings.BillingLocation()); errParse == nil {
+			query = query.Where("requested_at >= ?", startTime.UTC())
 		}
 	}
 	if q.EndDate != "" {
-		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, time.Local); errParse == nil {
-			query = query.Where("requested_at < ?", endTime.AddDate(0, 0, 1))
+		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, internalsettings.BillingLocation()); errParse == nil {
+			query = query.Where("requested_at < ?", endTime.AddDate(0, 0, 1).UTC())
 		}
 	}
 	if q.Project != "" {
@@ -105,15 +107,15 @@ func (h *LogsHandler) List(c *gin.Context) {
 	}
 
 	var total int64
-	countQuery := h.db.WithContext(ctx).Model(&models.Usage{}).Where("user_id = ?", userID)
+	countQuery := h.dbs.Read().WithContext(ctx).Model(&models.Usage{}).Where("user_id = ?", userID)
 	if q.StartDate != "" {
-		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, time.Local); errParse == nil {
-			countQuery = countQuery.Where("requested_at >= ?", startTime)
+		if startTime, errParse := time.ParseInLocation("2006-01-02", q.StartDate, internalsettings.BillingLocation()); errParse == nil {
+			countQuery = countQuery.Where("requested_at >= ?", startTime.UTC())
 		}
 	}
 	if q.EndDate != "" {
-		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, time.Local); errParse == nil {
-			countQuery = countQuery.Where("requested_at < ?", endTime.AddDate(0, 0, 1))
+		if endTime, errParse := time.ParseInLocation("2006-01-02", q.EndDate, internalsettings.BillingLocation()); errParse == nil {
+			countQuery = countQuery.Where("requested_at < ?", endTime.AddDate(0, 0, 1).UTC())
 		}
 	}
 	if q.Project != "" {
@@ -177,7 +179,7 @@ func (h *LogsHandler) List(c *gin.Context) {
 			OutputTokens: a.OutputTokens,
 			TotalTokens:  a.TotalTokens,
 			CostMicros:   a.CostMicros,
-			Cost:         fmt.Sprintf("$%.2f", float64(a.CostMicros)/1_000_000),
+			Cost:         billing.FormatMicros(a.CostMicros, 2),
 			FailedCount:  a.FailedCount,
 			Status:       status,
 			StatusText:   statusText,
@@ -201,7 +203,7 @@ func (h *LogsHandler) Stats(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 	yesterdayStart := todayStart.AddDate(0, 0, -1)
@@ -217,8 +219,8 @@ func (h *LogsHandler) Stats(c *gin.Context) {
 
 	var todayStats, yesterdayStats statResult
 
-	h.db.WithContext(ctx).Model(&models.Usage{}).
-		Where("user_id = ? AND requested_at >= ?", userID, todayStart).
+	h.dbs.Read().WithContext(ctx).Model(&models.Usage{}).
+		Where("user_id = ? AND requested_at >= ?", userID, todayStart.UTC()).
 		Select(`
 			COUNT(*) AS requests,
 			COALESCE(SUM(total_tokens), 0) AS total_tokens,
@@ -227,8 +229,8 @@ func (h *LogsHandler) Stats(c *gin.Context) {
 			COALESCE(AVG(GREATEST(EXTRACT(EPOCH FROM (created_at - requested_at)) * 1000, 0)), 0) AS avg_request_time_ms
 		`).Scan(&todayStats)
 
-	h.db.WithContext(ctx).Model(&models.Usage{}).
-		Where("user_id = ? AND requested_at >= ? AND requested_at < ?", userID, yesterdayStart, todayStart).
+	h.dbs.Read().WithContext(ctx).Model(&models.Usage{}).
+		Where("user_id = ? AND requested_at >= ? AND requested_at < ?", userID, yesterdayStart.UTC(), todayStart.UTC()).
 		Select(`
 			COUNT(*) AS requests,
 			COALESCE(SUM(total_tokens), 0) AS total_tokens,
@@ -277,7 +279,7 @@ func (h *LogsHandler) Trend(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 	sevenDaysAgo := todayStart.AddDate(0, 0, -6)
@@ -302,8 +304,8 @@ func (h *LogsHandler) Trend(c *gin.Context) {
 	}
 
 	var dailyData []dailyTrend
-	if errQuery := h.db.WithContext(ctx).Model(&models.Usage{}).
-		Where("user_id = ? AND requested_at >= ?", userID, sevenDaysAgo).
+	if errQuery := h.dbs.Read().WithContext(ctx).Model(&models.Usage{}).
+		Where("user_id = ? AND requested_at >= ?", userID, sevenDaysAgo.UTC()).
 		Select(`
 			TO_CHAR(requested_at, 'YYYY-MM-DD') AS date,
 			COUNT(*) AS requests,
@@ -362,7 +364,7 @@ func (h *LogsHandler) Models(c *gin.Context) {
 	}
 
 	var modelList []string
-	if errModels := h.db.WithContext(c.Request.Context()).Table("usages").
+	if errModels := h.dbs.Read().WithContext(c.Request.Context()).Table("usages").
 		Where("user_id = ?", userID).
 		Distinct("model").
 		Pluck("model", &modelList).Error; errModels != nil {
@@ -382,7 +384,7 @@ func (h *LogsHandler) Projects(c *gin.Context) {
 	}
 
 	var projects []string
-	if errProjects := h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
+	if errProjects := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
 		Where("user_id = ? AND source != ''", userID).
 		Distinct("source").
 		Pluck("source", &projects).Error; errProjects != nil {
@@ -414,13 +416,15 @@ type logDetailQuery struct {
 
 // logDetailEntry defines a detailed usage record.
 type logDetailEntry struct {
-	RequestedAt  time.Time `json:"requested_at"`
-	InputTokens  int64     `json:"input_tokens"`
-	OutputTokens int64     `json:"output_tokens"`
-	CachedTokens int64     `json:"cached_tokens"`
-	TotalTokens  int64     `json:"total_tokens"`
-	CostMicros   int64     `json:"cost_micros"`
-	Failed       bool      `json:"failed"`
+	RequestedAt    time.Time `json:"requested_at"`
+	Model          string    `json:"model"`
+	RequestedModel string    `json:"requested_model"`
+	InputTokens    int64     `json:"input_tokens"`
+	OutputTokens   int64     `json:"output_tokens"`
+	CachedTokens   int64     `json:"cached_tokens"`
+	TotalTokens    int64     `json:"total_tokens"`
+	CostMicros     int64     `json:"cost_micros"`
+	Failed         bool      `json:"failed"`
 }
 
 // Detail returns raw usage details for a given day and filters.
@@ -442,19 +446,19 @@ func (h *LogsHandler) Detail(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing date"})
 		return
 	}
-	day, errParse := time.ParseInLocation("2006-01-02", dateKey, time.Local)
+	day, errParse := time.ParseInLocation("2006-01-02", dateKey, internalsettings.BillingLocation())
 	if errParse != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date"})
 		return
 	}
-	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
 	end := start.AddDate(0, 0, 1)
 
 	ctx := c.Request.Context()
-	query := h.db.WithContext(ctx).
+	query := h.dbs.Read().WithContext(ctx).
 		Model(&models.Usage{}).
 		Where("user_id = ?", userID).
-		Where("requested_at >= ? AND requested_at < ?", start, end)
+		Where("requested_at >= ? AND requested_at < ?", start.UTC(), end.UTC())
 
 	if strings.TrimSpace(q.Model) != "" {
 		query = query.Where("model = ?", strings.TrimSpace(q.Model))
@@ -468,7 +472,7 @@ func (h *LogsHandler) Detail(c *gin.Context) {
 
 	var rows []logDetailEntry
 	if errFind := query.
-		Select("requested_at, input_tokens, output_tokens, cached_tokens, total_tokens, cost_micros, failed").
+		Select("requested_at, model, COALESCE(requested_model, '') AS requested_model, input_tokens, output_tokens, cached_tokens, total_tokens, cost_micros, failed").
 		Order("requested_at DESC").
 		Find(&rows).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query details failed"})
@@ -477,14 +481,21 @@ func (h *LogsHandler) Detail(c *gin.Context) {
 
 	details := make([]gin.H, 0, len(rows))
 	for _, row := range rows {
+		// Rows written before the requested model was recorded show the canonical model.
+		requestedModel := row.RequestedModel
+		if requestedModel == "" {
+			requestedModel = row.Model
+		}
 		details = append(details, gin.H{
-			"requested_at":  row.RequestedAt.In(time.Local).Format(time.RFC3339),
-			"input_tokens":  row.InputTokens,
-			"output_tokens": row.OutputTokens,
-			"cached_tokens": row.CachedTokens,
-			"total_tokens":  row.TotalTokens,
-			"cost":          fmt.Sprintf("$%.4f", float64(row.CostMicros)/1_000_000),
-			"success":       !row.Failed,
+			"requested_at":    row.RequestedAt.In(time.Local).Format(time.RFC3339),
+			"model":           row.Model,
+			"requested_model": requestedModel,
+			"input_tokens":    row.InputTokens,
+			"output_tokens":   row.OutputTokens,
+			"cached_tokens":   row.CachedTokens,
+			"total_tokens":    row.TotalTokens,
+			"cost":            billing.FormatMicros(row.CostMicros, 4),
+			"success":         !row.Failed,
 		})
 	}
 
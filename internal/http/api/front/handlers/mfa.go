@@ -559,8 +559,8 @@ func (h *AuthHandler) LoginTOTP(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-	if user.Disabled {
-		c.JSON(http.StatusForbidden, gin.H{"error": "user disabled"})
+	if reason := user.SignInBlock(); reason != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": reason})
 		return
 	}
 	if strings.TrimSpace(user.TOTPSecret) == "" {
@@ -601,13 +601,13 @@ func (h *AuthHandler) LoginPasskeyOptions(c *gin.Context) {
 
 	var user models.User
 	if errFind := h.db.WithContext(c.Request.Context()).
-		Select("id", "username", "disabled", "passkey_id", "passkey_public_key", "passkey_sign_count", "passkey_backup_eligible", "passkey_backup_state", "name", "email").
+		Select("id", "username", "active", "disabled", "passkey_id", "passkey_public_key", "passkey_sign_count", "passkey_backup_eligible", "passkey_backup_state", "name", "email").
 		Where("username = ?", username).First(&user).Error; errFind != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-	if user.Disabled {
-		c.JSON(http.StatusForbidden, gin.H{"error": "user disabled"})
+	if reason := user.SignInBlock(); reason != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": reason})
 		return
 	}
 	if len(user.PasskeyID) == 0 || len(user.PasskeyPublicKey) == 0 {
@@ -642,13 +642,13 @@ func (h *AuthHandler) LoginPasskeyVerify(c *gin.Context) {
 
 	var user models.User
 	if errFind := h.db.WithContext(c.Request.Context()).
-		Select("id", "username", "disabled", "passkey_id", "passkey_public_key", "passkey_sign_count", "passkey_backup_eligible", "passkey_backup_state", "name", "email").
+		Select("id", "username", "active", "disabled", "passkey_id", "passkey_public_key", "passkey_sign_count", "passkey_backup_eligible", "passkey_backup_state", "name", "email").
 		Where("username = ?", username).First(&user).Error; errFind != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-	if user.Disabled {
-		c.JSON(http.StatusForbidden, gin.H{"error": "user disabled"})
+	if reason := user.SignInBlock(); reason != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": reason})
 		return
 	}
 	if len(user.PasskeyID) == 0 || len(user.PasskeyPublicKey) == 0 {
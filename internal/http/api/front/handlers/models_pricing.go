@@ -1,12 +1,11 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	sdkcliproxy "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
@@ -42,6 +41,7 @@ type modelPricingItem struct {
 	PriceOutputToken      *float64           `json:"price_output_token,omitempty"`
 	PriceCacheCreateToken *float64           `json:"price_cache_create_token,omitempty"`
 	PriceCacheReadToken   *float64           `json:"price_cache_read_token,omitempty"`
+	PriceUnit             models.PriceUnit   `json:"price_unit,omitempty"`
 }
 
 // modelAvailability captures availability metadata for a model.
@@ -135,6 +135,16 @@ func (h *ModelPricingHandler) List(c *gin.Context) {
 	if defaultUserGroupID != nil {
 		addBilling(*defaultUserGroupID)
 	}
+	userGroupParents, errParents := billing.LoadUserGroupParents(ctx, h.db)
+	if errParents != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query user groups failed"})
+		return
+	}
+	for _, id := range append([]uint64(nil), billingUserGroupIDs...) {
+		for _, ancestorID := range billing.AncestorUserGroupIDs(userGroupParents, id) {
+			addBilling(ancestorID)
+		}
+	}
 
 	rules, errRules := h.loadBillingRules(ctx, authGroupIDValue, billingUserGroupIDs, defaultAuthGroupIDValue, defaultUserGroupIDValue)
 	if errRules != nil {
@@ -142,6 +152,12 @@ func (h *ModelPricingHandler) List(c *gin.Context) {
 		return
 	}
 
+	plan, _, errPlan := billing.LoadPlanModels(ctx, h.db, userID, time.Now())
+	if errPlan != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query plan models failed"})
+		return
+	}
+
 	onlyMapped := loadOnlyMapped()
 	available, errModels := h.loadAvailableModels(ctx, onlyMapped)
 	if errModels != nil {
@@ -156,7 +172,7 @@ func (h *ModelPricingHandler) List(c *gin.Context) {
 	for _, item := range available {
 		provider := strings.TrimSpace(item.Provider)
 		modelID := strings.TrimSpace(item.ModelID)
-		if provider == "" || modelID == "" {
+		if provider == "" || modelID == "" || !plan.Allows(modelID) {
 			continue
 		}
 
@@ -215,7 +231,7 @@ func (h *ModelPricingHandler) List(c *gin.Context) {
 			continue
 		}
 
-		rule := billing.SelectBillingRule(rules, authGroupIDValue, *billingUserGroupID, defaultAuthGroupIDValue, defaultUserGroupIDValue, provider, modelID)
+		rule := billing.SelectBillingRule(rules, authGroupIDValue, *billingUserGroupID, billing.AncestorUserGroupIDs(userGroupParents, *billingUserGroupID), defaultAuthGroupIDValue, defaultUserGroupIDValue, provider, modelID)
 		result := modelPricingItem{
 			Provider:      provider,
 			Model:         modelID,
@@ -230,6 +246,12 @@ func (h *ModelPricingHandler) List(c *gin.Context) {
 			result.PriceOutputToken = rule.PriceOutputToken
 			result.PriceCacheCreateToken = rule.PriceCacheCreateToken
 			result.PriceCacheReadToken = rule.PriceCacheReadToken
+			if rule.BillingType == models.BillingTypePerToken {
+				result.PriceUnit = rule.PriceUnit
+				if result.PriceUnit == "" {
+					result.PriceUnit = models.PriceUnitPer1M
+				}
+			}
 		}
 
 		switch result.BillingType {
@@ -401,46 +423,5 @@ func sortModelPricing(list []modelPricingItem) {
 
 // loadOnlyMapped reads the ONLY_MAPPED_MODELS flag from DB config.
 func loadOnlyMapped() bool {
-	raw, ok := internalsettings.DBConfigValue("ONLY_MAPPED_MODELS")
-	if !ok {
-		return false
-	}
-	return parseDBConfigBool(raw)
-}
-
-// parseDBConfigBool parses a boolean from JSON config payloads.
-func parseDBConfigBool(raw json.RawMessage) bool {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return false
-	}
-	var b bool
-	if errUnmarshal := json.Unmarshal(raw, &b); errUnmarshal == nil {
-		return b
-	}
-	var s string
-	if errUnmarshal := json.Unmarshal(raw, &s); errUnmarshal == nil {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return false
-		}
-		if strings.EqualFold(s, "true") || s == "1" {
-			return true
-		}
-		return false
-	}
-	var n float64
-	if errUnmarshal := json.Unmarshal(raw, &n); errUnmarshal == nil {
-		return n != 0
-	}
-	// wrapper allows parsing values wrapped in a { "value": ... } object.
-	var wrapper struct {
-		Value json.RawMessage `json:"value"`
-	}
-	if errUnmarshal := json.Unmarshal(raw, &wrapper); errUnmarshal == nil {
-		if len(wrapper.Value) > 0 {
-			return parseDBConfigBool(wrapper.Value)
-		}
-	}
-	return false
+	return internalsettings.GetBool(internalsettings.OnlyMappedModelsKey)
 }
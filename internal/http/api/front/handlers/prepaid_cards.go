@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -107,6 +108,10 @@ func (h *PrepaidCardFrontHandler) Redeem(c *gin.Context) {
 		card.RedeemedUserID = &userID
 		card.RedeemedAt = &now
 		card.ExpiresAt = expiresAt
+		if _, errPay := billing.PayOverageDebtFromCard(c.Request.Context(), tx, &card, card.Balance); errPay != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "redeem failed"})
+			return errPay
+		}
 		result = gin.H{
 			"id":          card.ID,
 			"name":        card.Name,
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/mail"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// verificationTokenTTL bounds how long an emailed verification link stays valid.
+	verificationTokenTTL = 24 * time.Hour
+	// verificationResendInterval is the minimum gap between verification emails for one user.
+	verificationResendInterval = time.Minute
+)
+
+var (
+	// errInvalidInviteCode reports a missing, disabled, expired or exhausted invite code.
+	errInvalidInviteCode = errors.New("invalid invite code")
+	// errInvalidVerificationToken reports an unknown, used or expired verification token.
+	errInvalidVerificationToken = errors.New("invalid or expired token")
+)
+
+// RegistrationHandler handles self-registration and email verification.
+type RegistrationHandler struct {
+	db        *gorm.DB
+	newSender func() mail.Sender
+}
+
+// NewRegistrationHandler constructs a RegistrationHandler that sends mail using the SMTP settings.
+func NewRegistrationHandler(db *gorm.DB) *RegistrationHandler {
+	return &RegistrationHandler{db: db, newSender: registrationMailSender}
+}
+
+// registrationVerifyURL returns the configured verification link base URL, or "" when unset.
+// The link is built only from configuration: request headers are client controlled
+// and would let a caller point the emailed token at their own host.
+func registrationVerifyURL() string {
+	return strings.TrimSpace(internalsettings.GetString(internalsettings.RegistrationVerifyURLKey))
+}
+
+// registrationOpen reports whether self-registration is enabled and can send verification links.
+func registrationOpen() bool {
+	return internalsettings.GetBool(internalsettings.AllowRegistrationKey) && registrationVerifyURL() != ""
+}
+
+// registerRequest defines the request body for user registration.
+type registerRequest struct {
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	InviteCode string `json:"invite_code"`
+}
+
+// Register creates an inactive user account and emails a verification link.
+func (h *RegistrationHandler) Register(c *gin.Context) {
+	if !internalsettings.GetBool(internalsettings.AllowRegistrationKey) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "registration disabled"})
+		return
+	}
+	verifyURL := registrationVerifyURL()
+	if verifyURL == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "registration verify url not configured"})
+		return
+	}
+
+	var body registerRequest
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+	username := strings.TrimSpace(body.Username)
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing username"})
+		return
+	}
+	email := strings.TrimSpace(body.Email)
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing email"})
+		return
+	}
+	if addr, errAddr := netmail.ParseAddress(email); errAddr != nil || addr.Address != email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+		return
+	}
+	password := strings.TrimSpace(body.Password)
+	if password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing password"})
+		return
+	}
+	inviteCode := strings.TrimSpace(body.InviteCode)
+	if inviteCode == "" && internalsettings.GetBool(internalsettings.RegistrationRequireInviteKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing invite_code"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var exists int64
+	if errCount := h.db.WithContext(ctx).Model(&models.User{}).
+		Where("LOWER(username) = LOWER(?) OR LOWER(email) = LOWER(?)", username, email).
+		Count(&exists).Error; errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	if exists > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "username or email already exists"})
+		return
+	}
+
+	hash, errHash := security.HashPassword(password)
+	if errHash != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "hash password failed"})
+		return
+	}
+
+	now := time.Now().UTC()
+	user := models.User{
+		Username:  username,
+		Email:     email,
+		Password:  hash,
+		Active:    false,
+		Disabled:  false,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	var token string
+	errTx := h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if inviteCode != "" {
+			if errInvite := consumeInviteCode(tx, inviteCode, now); errInvite != nil {
+				return errInvite
+			}
+		}
+		var defaultGroup models.UserGroup
+		if errFind := tx.Where("is_default = ?", true).First(&defaultGroup).Error; errFind == nil {
+			user.UserGroupID = models.UserGroupIDs{&defaultGroup.ID}
+		} else if !errors.Is(errFind, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("query default user group: %w", errFind)
+		}
+		if errCreate := tx.Create(&user).Error; errCreate != nil {
+			return fmt.Errorf("create user: %w", errCreate)
+		}
+		// Active has a true column default, so the zero value is not written on create.
+		if errPending := tx.Model(&user).Update("active", false).Error; errPending != nil {
+			return fmt.Errorf("mark user pending: %w", errPending)
+		}
+		var errToken error
+		token, errToken = issueVerificationToken(tx, user.ID, now)
+		return errToken
+	})
+	if errTx != nil {
+		if errors.Is(errTx, errInvalidInviteCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidInviteCode.Error()})
+			return
+		}
+		log.WithError(errTx).Error("registration: create user failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create user failed"})
+		return
+	}
+
+	msg := verificationMessage(user.Email, verificationLink(verifyURL, token))
+	if errSend := h.newSender().Send(ctx, msg); errSend != nil {
+		log.WithError(errSend).Warnf("registration: send verification email to user %d failed", user.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":                    user.ID,
+		"username":              user.Username,
+		"email":                 user.Email,
+		"verification_required": true,
+	})
+}
+
+// Verify consumes a verification token and activates the owning user.
+func (h *RegistrationHandler) Verify(c *gin.Context) {
+	token := strings.TrimSpace(c.Query("token"))
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing token"})
+		return
+	}
+	userID, errConsume := consumeVerificationToken(c.Request.Context(), h.db, token, time.Now().UTC())
+	if errConsume != nil {
+		if errors.Is(errConsume, errInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidVerificationToken.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "verify failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "user_id": userID})
+}
+
+// resendVerificationRequest defines the request body for resending a verification email.
+type resendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// ResendVerification issues a fresh verification link to a pending account. It
+// responds the same way whether or not the email matches, so it cannot be used
+// to probe for registered addresses.
+func (h *RegistrationHandler) ResendVerification(c *gin.Context) {
+	verifyURL := registrationVerifyURL()
+	if verifyURL == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "registration verify url not configured"})
+		return
+	}
+	var body resendVerificationRequest
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+	email := strings.TrimSpace(body.Email)
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing email"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now().UTC()
+	var user models.User
+	var token string
+	errTx := h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if errFind := tx.Select("id", "email").
+			Where("LOWER(email) = LOWER(?) AND active = ? AND disabled = ? AND email_verified_at IS NULL", email, false, false).
+			First(&user).Error; errFind != nil {
+			return errFind
+		}
+		var recent int64
+		if errCount := tx.Model(&models.EmailVerificationToken{}).
+			Where("user_id = ? AND created_at > ?", user.ID, now.Add(-verificationResendInterval)).
+			Count(&recent).Error; errCount != nil {
+			return fmt.Errorf("count recent verification tokens: %w", errCount)
+		}
+		if recent > 0 {
+			return nil
+		}
+		// Earlier links stop working once a new one is sent.
+		if errExpire := tx.Model(&models.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("expires_at", now).Error; errExpire != nil {
+			return fmt.Errorf("expire verification tokens: %w", errExpire)
+		}
+		var errToken error
+		token, errToken = issueVerificationToken(tx, user.ID, now)
+		return errToken
+	})
+	if errTx != nil && !errors.Is(errTx, gorm.ErrRecordNotFound) {
+		log.WithError(errTx).Error("registration: resend verification failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "resend verification failed"})
+		return
+	}
+	if token != "" {
+		msg := verificationMessage(user.Email, verificationLink(verifyURL, token))
+		if errSend := h.newSender().Send(ctx, msg); errSend != nil {
+			log.WithError(errSend).Warnf("registration: resend verification email to user %d failed", user.ID)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// consumeInviteCode atomically claims one use of an enabled, unexpired invite code.
+func consumeInviteCode(tx *gorm.DB, code string, now time.Time) error {
+	res := tx.Model(&models.InviteCode{}).
+		Where("code = ? AND is_enabled = ?", code, true).
+		Where("max_uses = 0 OR used_count < max_uses").
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Updates(map[string]any{
+			"used_count": gorm.Expr("used_count + 1"),
+			"updated_at": now,
+		})
+	if res.Error != nil {
+		return fmt.Errorf("consume invite code: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return errInvalidInviteCode
+	}
+	return nil
+}
+
+// issueVerificationToken stores a hashed verification token for userID and returns the raw token.
+func issueVerificationToken(tx *gorm.DB, userID uint64, now time.Time) (string, error) {
+	token, errToken := security.GenerateRandomString(64)
+	if errToken != nil {
+		return "", errToken
+	}
+	row := models.EmailVerificationToken{
+		UserID:    userID,
+		Token:     hashVerificationToken(token),
+		ExpiresAt: now.Add(verificationTokenTTL),
+		CreatedAt: now,
+	}
+	if errCreate := tx.Create(&row).Error; errCreate != nil {
+		return "", fmt.Errorf("create verification token: %w", errCreate)
+	}
+	return token, nil
+}
+
+// consumeVerificationToken marks a token used and activates its user, returning the user ID.
+func consumeVerificationToken(ctx context.Context, db *gorm.DB, token string, now time.Time) (uint64, error) {
+	hashed := hashVerificationToken(token)
+	var userID uint64
+	errTx := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.EmailVerificationToken{}).
+			Where("token = ? AND used_at IS NULL AND expires_at > ?", hashed, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return fmt.Errorf("consume verification token: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidVerificationToken
+		}
+		var row models.EmailVerificationToken
+		if errFind := tx.Select("user_id").Where("token = ?", hashed).First(&row).Error; errFind != nil {
+			return fmt.Errorf("load verification token: %w", errFind)
+		}
+		if errUpdate := tx.Model(&models.User{}).Where("id = ?", row.UserID).Updates(map[string]any{
+			"active":            true,
+			"email_verified_at": now,
+			"updated_at":        now,
+		}).Error; errUpdate != nil {
+			return fmt.Errorf("activate user: %w", errUpdate)
+		}
+		userID = row.UserID
+		return nil
+	})
+	if errTx != nil {
+		return 0, errTx
+	}
+	return userID, nil
+}
+
+// hashVerificationToken returns the stored digest for a raw verification token.
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// verificationLink appends token to the REGISTRATION_VERIFY_URL base.
+func verificationLink(base, token string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+// verificationMessage renders the verification email.
+func verificationMessage(to, link string) mail.Message {
+	siteName := internalsettings.GetString(internalsettings.SiteNameKey)
+	if siteName == "" {
+		siteName = internalsettings.DefaultSiteName
+	}
+	return mail.Message{
+		To:      to,
+		Subject: siteName + " email verification",
+		Body: fmt.Sprintf(
+			"Confirm your %s account by opening the link below within %d hours:\n\n%s\n",
+			siteName, int(verificationTokenTTL.Hours()), link,
+		),
+	}
+}
+
+// registrationMailSender returns an SMTP sender when SMTP_HOST is set, otherwise a log-only sender.
+func registrationMailSender() mail.Sender {
+	host := internalsettings.GetString(internalsettings.SMTPHostKey)
+	if host == "" {
+		return mail.LogSender{}
+	}
+	return mail.NewSMTPSender(mail.SMTPConfig{
+		Host:     host,
+		Port:     internalsettings.GetInt(internalsettings.SMTPPortKey),
+		Username: internalsettings.GetString(internalsettings.SMTPUsernameKey),
+		Password: internalsettings.GetString(internalsettings.SMTPPasswordKey),
+		From:     internalsettings.GetString(internalsettings.SMTPFromKey),
+	})
+}
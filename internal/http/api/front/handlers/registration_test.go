@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/pquerna/otp/totp"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/mail"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+func setupRegistrationDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:registration_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.EmailVerificationToken{}, &models.InviteCode{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	return db
+}
+
+func TestVerificationTokenIsSingleUseAndExpires(t *testing.T) {
+	db := setupRegistrationDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	user := models.User{Username: "pending", Email: "pending@example.com", Password: "x", Active: false}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	if errUpdate := db.Model(&user).Update("active", false).Error; errUpdate != nil {
+		t.Fatalf("deactivate user: %v", errUpdate)
+	}
+	token, errIssue := issueVerificationToken(db, user.ID, now)
+	if errIssue != nil {
+		t.Fatalf("issue token: %v", errIssue)
+	}
+
+	if _, errConsume := consumeVerificationToken(ctx, db, token, now.Add(verificationTokenTTL+time.Minute)); !errors.Is(errConsume, errInvalidVerificationToken) {
+		t.Fatalf("expected expired token to be rejected, got %v", errConsume)
+	}
+	userID, errConsume := consumeVerificationToken(ctx, db, token, now.Add(time.Minute))
+	if errConsume != nil {
+		t.Fatalf("consume token: %v", errConsume)
+	}
+	if userID != user.ID {
+		t.Fatalf("expected user %d, got %d", user.ID, userID)
+	}
+	if _, errConsume = consumeVerificationToken(ctx, db, token, now.Add(2*time.Minute)); !errors.Is(errConsume, errInvalidVerificationToken) {
+		t.Fatalf("expected reused token to be rejected, got %v", errConsume)
+	}
+
+	var reloaded models.User
+	if errFind := db.First(&reloaded, user.ID).Error; errFind != nil {
+		t.Fatalf("reload user: %v", errFind)
+	}
+	if !reloaded.Active || reloaded.EmailVerifiedAt == nil {
+		t.Fatalf("expected user to be activated and verified")
+	}
+}
+
+func TestConsumeInviteCodeHonoursMaxUses(t *testing.T) {
+	db := setupRegistrationDB(t)
+	now := time.Now().UTC()
+	if errCreate := db.Create(&models.InviteCode{Code: "JOIN", MaxUses: 1, IsEnabled: true}).Error; errCreate != nil {
+		t.Fatalf("create invite: %v", errCreate)
+	}
+	if errConsume := consumeInviteCode(db, "JOIN", now); errConsume != nil {
+		t.Fatalf("consume invite: %v", errConsume)
+	}
+	if errConsume := consumeInviteCode(db, "JOIN", now); !errors.Is(errConsume, errInvalidInviteCode) {
+		t.Fatalf("expected exhausted invite to be rejected, got %v", errConsume)
+	}
+	if errConsume := consumeInviteCode(db, "MISSING", now); !errors.Is(errConsume, errInvalidInviteCode) {
+		t.Fatalf("expected unknown invite to be rejected, got %v", errConsume)
+	}
+}
+
+// captureSender records the messages it is asked to send.
+type captureSender struct {
+	sent []mail.Message
+}
+
+func (s *captureSender) Send(_ context.Context, msg mail.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestRegisterBuildsLinkOnlyFromConfiguredURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupRegistrationDB(t)
+	if errMigrate := db.AutoMigrate(&models.UserGroup{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	sender := &captureSender{}
+	h := &RegistrationHandler{db: db, newSender: func() mail.Sender { return sender }}
+	r := gin.New()
+	r.POST("/v0/user/register", h.Register)
+	register := func(username string) *httptest.ResponseRecorder {
+		t.Helper()
+		body := fmt.Sprintf(`{"username":%q,"email":"%s@example.com","password":"secret"}`, username, username)
+		req := httptest.NewRequest(http.MethodPost, "http://evil.example/v0/user/register", strings.NewReader(body))
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.AllowRegistrationKey: json.RawMessage(`true`),
+	})
+	if w := register("unset"); w.Code != http.StatusServiceUnavailable || len(sender.sent) != 0 {
+		t.Fatalf("expected registration to be refused without a verify url, got %d and %d emails", w.Code, len(sender.sent))
+	}
+	var count int64
+	if errCount := db.Model(&models.User{}).Count(&count).Error; errCount != nil || count != 0 {
+		t.Fatalf("expected no user to be created, got %d (%v)", count, errCount)
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.AllowRegistrationKey:     json.RawMessage(`true`),
+		internalsettings.RegistrationVerifyURLKey: json.RawMessage(`"https://portal.example.com/verify"`),
+	})
+	if w := register("configured"); w.Code != http.StatusCreated {
+		t.Fatalf("expected registration to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	if len(sender.sent) != 1 || !strings.Contains(sender.sent[0].Body, "https://portal.example.com/verify?token=") || strings.Contains(sender.sent[0].Body, "evil.example") {
+		t.Fatalf("expected the link to use the configured url, got %+v", sender.sent)
+	}
+}
+
+func TestPublicConfigHidesRegistrationWithoutVerifyURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v0/front/config", GetPublicConfig)
+	allowRegistration := func() bool {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/front/config", nil))
+		var resp publicConfigResponse
+		if errDecode := json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusOK || errDecode != nil {
+			t.Fatalf("expected config, got %d: %s", w.Code, w.Body.String())
+		}
+		return resp.AllowRegistration
+	}
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.AllowRegistrationKey: json.RawMessage(`true`),
+	})
+	if allowRegistration() {
+		t.Fatalf("expected registration to be reported closed without a verify url")
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.AllowRegistrationKey:     json.RawMessage(`true`),
+		internalsettings.RegistrationVerifyURLKey: json.RawMessage(`"https://portal.example.com/verify"`),
+	})
+	if !allowRegistration() {
+		t.Fatalf("expected registration to be reported open with a verify url")
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.AllowRegistrationKey:     json.RawMessage(`false`),
+		internalsettings.RegistrationVerifyURLKey: json.RawMessage(`"https://portal.example.com/verify"`),
+	})
+	if allowRegistration() {
+		t.Fatalf("expected disabled registration to be reported closed")
+	}
+}
+
+func TestResendVerificationReplacesPendingLink(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupRegistrationDB(t)
+	sender := &captureSender{}
+	h := &RegistrationHandler{db: db, newSender: func() mail.Sender { return sender }}
+	r := gin.New()
+	r.POST("/v0/user/verify/resend", h.ResendVerification)
+	resend := func(email string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/user/verify/resend", strings.NewReader(fmt.Sprintf(`{"email":%q}`, email))))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected resend to succeed, got %d %s", w.Code, w.Body.String())
+		}
+	}
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.RegistrationVerifyURLKey: json.RawMessage(`"https://portal.example.com/verify"`),
+	})
+
+	pending := models.User{Username: "pending", Email: "pending@example.com", Password: "x"}
+	active := models.User{Username: "active", Email: "active@example.com", Password: "x", Active: true}
+	for _, u := range []*models.User{&pending, &active} {
+		if errCreate := db.Create(u).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+	}
+	if errUpdate := db.Model(&pending).Update("active", false).Error; errUpdate != nil {
+		t.Fatalf("deactivate user: %v", errUpdate)
+	}
+	stale, errIssue := issueVerificationToken(db, pending.ID, time.Now().UTC().Add(-time.Hour))
+	if errIssue != nil {
+		t.Fatalf("issue token: %v", errIssue)
+	}
+
+	resend("Pending@example.com")
+	if len(sender.sent) != 1 || sender.sent[0].To != "pending@example.com" {
+		t.Fatalf("expected one email to the pending user, got %+v", sender.sent)
+	}
+	if _, errConsume := consumeVerificationToken(context.Background(), db, stale, time.Now().UTC()); !errors.Is(errConsume, errInvalidVerificationToken) {
+		t.Fatalf("expected the earlier link to stop working, got %v", errConsume)
+	}
+
+	resend("pending@example.com")
+	resend("active@example.com")
+	resend("nobody@example.com")
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected throttled, verified and unknown addresses to get no email, got %d", len(sender.sent))
+	}
+}
+
+func TestUnverifiedUserCannotSignIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupRegistrationDB(t)
+	hash, errHash := security.HashPassword("secret")
+	if errHash != nil {
+		t.Fatalf("hash password: %v", errHash)
+	}
+	key, errKey := totp.Generate(totp.GenerateOpts{Issuer: "test", AccountName: "pending"})
+	if errKey != nil {
+		t.Fatalf("generate totp: %v", errKey)
+	}
+	user := models.User{Username: "pending", Email: "pending@example.com", Password: hash}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	if errUpdate := db.Model(&user).Updates(map[string]any{"active": false}).Error; errUpdate != nil {
+		t.Fatalf("deactivate user: %v", errUpdate)
+	}
+
+	h := NewAuthHandler(db, config.JWTConfig{Secret: "secret", Expiry: time.Hour})
+	r := gin.New()
+	r.POST("/login", h.Login)
+	r.POST("/login/totp", h.LoginTOTP)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := post("/login", `{"username":"pending","password":"secret"}`); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "email not verified") {
+		t.Fatalf("expected password login to be refused, got %d %s", w.Code, w.Body.String())
+	}
+	if errUpdate := db.Model(&user).Update("totp_secret", key.Secret()).Error; errUpdate != nil {
+		t.Fatalf("enable totp: %v", errUpdate)
+	}
+	code, errCode := totp.GenerateCode(key.Secret(), time.Now())
+	if errCode != nil {
+		t.Fatalf("generate code: %v", errCode)
+	}
+	if w := post("/login/totp", fmt.Sprintf(`{"username":"pending","code":%q}`, code)); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "email not verified") {
+		t.Fatalf("expected totp login to be refused, got %d %s", w.Code, w.Body.String())
+	}
+}
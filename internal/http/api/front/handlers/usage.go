@@ -5,18 +5,19 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
-	"gorm.io/gorm"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 )
 
 // UsageHandler handles usage statistics endpoints.
 type UsageHandler struct {
-	db *gorm.DB
+	dbs dbutil.DBProvider // Primary connection and optional read replica.
 }
 
 // NewUsageHandler constructs a UsageHandler.
-func NewUsageHandler(db *gorm.DB) *UsageHandler {
-	return &UsageHandler{db: db}
+func NewUsageHandler(dbs dbutil.DBProvider) *UsageHandler {
+	return &UsageHandler{dbs: dbs}
 }
 
 // usageSummary aggregates usage statistics.
@@ -35,7 +36,7 @@ func (h *UsageHandler) Stats(c *gin.Context) {
 	}
 
 	var apiKeyIDs []uint64
-	if errFind := h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+	if errFind := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.APIKey{}).
 		Where("user_id = ?", userID).
 		Pluck("id", &apiKeyIDs).Error; errFind != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query api keys failed"})
@@ -53,7 +54,7 @@ func (h *UsageHandler) Stats(c *gin.Context) {
 		return
 	}
 
-	loc := time.Local
+	loc := internalsettings.BillingLocation()
 	now := time.Now().In(loc)
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 
@@ -68,8 +69,8 @@ func (h *UsageHandler) Stats(c *gin.Context) {
 	result := make(map[string]usageSummary)
 	for name, since := range periods {
 		var summary usageSummary
-		if errScan := h.db.WithContext(c.Request.Context()).Model(&models.Usage{}).
-			Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, since).
+		if errScan := h.dbs.Read().WithContext(c.Request.Context()).Model(&models.Usage{}).
+			Where("api_key_id IN ? AND requested_at >= ?", apiKeyIDs, since.UTC()).
 			Select("COUNT(*) AS total_requests, COALESCE(SUM(total_tokens), 0) AS total_tokens, COALESCE(SUM(cost_micros), 0) AS cost_micros").
 			Scan(&summary).Error; errScan != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "query usage failed"})
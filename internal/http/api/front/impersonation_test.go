@@ -0,0 +1,68 @@
+package front
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	"gorm.io/gorm"
+)
+
+func TestImpersonationTokenIsAuditedAndCannotChangeCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:impersonation_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.AuditLog{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "x", Active: true}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+
+	jwtCfg := config.JWTConfig{Secret: "secret", Expiry: time.Hour}
+	token, errToken := security.GenerateImpersonationToken(jwtCfg.Secret, user.ID, user.Username, "", user.Email, 42, time.Minute)
+	if errToken != nil {
+		t.Fatalf("generate token: %v", errToken)
+	}
+
+	r := gin.New()
+	authed := r.Group("", userAuthMiddleware(db, jwtCfg))
+	authed.GET("/profile", func(c *gin.Context) { c.Status(http.StatusOK) })
+	authed.PUT("/profile/password", denyImpersonation(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/profile", http.StatusOK},
+		{http.MethodPut, "/profile/password", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, w.Code)
+		}
+	}
+
+	var audits int64
+	if errCount := db.Model(&models.AuditLog{}).Where("actor_id = ? AND target_id = ?", 42, user.ID).Count(&audits).Error; errCount != nil {
+		t.Fatalf("count audit: %v", errCount)
+	}
+	if audits != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", audits)
+	}
+}
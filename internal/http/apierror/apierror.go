@@ -0,0 +1,159 @@
+// Package apierror defines the typed errors returned by admin API handlers and renders
+// them with a consistent JSON shape: {"error": message, "code": code, "field": field}.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code is a stable, machine-readable error identifier.
+type Code string
+
+const (
+	// CodeInvalidRequest marks a request that could not be decoded or parsed.
+	CodeInvalidRequest Code = "invalid_request"
+	// CodeInvalidID marks a malformed resource id in the path.
+	CodeInvalidID Code = "invalid_id"
+	// CodeValidationFailed marks a request field that failed validation.
+	CodeValidationFailed Code = "validation_failed"
+	// CodeUnauthorized marks a request without an authenticated admin.
+	CodeUnauthorized Code = "unauthorized"
+	// CodeForbidden marks a request the authenticated admin may not perform.
+	CodeForbidden Code = "forbidden"
+	// CodeNotFound marks a missing resource.
+	CodeNotFound Code = "not_found"
+	// CodeConflict marks a write rejected by a unique constraint.
+	CodeConflict Code = "conflict"
+	// CodeInternal marks an unexpected server-side failure.
+	CodeInternal Code = "internal_error"
+)
+
+// CodeInfo documents one error code.
+type CodeInfo struct {
+	Code        Code   `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// Codes lists every error code the admin API returns.
+var Codes = []CodeInfo{
+	{Code: CodeInvalidRequest, Status: http.StatusBadRequest, Description: "The request body or form could not be parsed."},
+	{Code: CodeInvalidID, Status: http.StatusBadRequest, Description: "The resource id in the path is not a valid id."},
+	{Code: CodeValidationFailed, Status: http.StatusBadRequest, Description: "A request field is missing or invalid; see field."},
+	{Code: CodeUnauthorized, Status: http.StatusUnauthorized, Description: "The request is not authenticated as an admin."},
+	{Code: CodeForbidden, Status: http.StatusForbidden, Description: "The admin is not allowed to perform the request."},
+	{Code: CodeNotFound, Status: http.StatusNotFound, Description: "The requested resource does not exist."},
+	{Code: CodeConflict, Status: http.StatusConflict, Description: "The write conflicts with an existing resource."},
+	{Code: CodeInternal, Status: http.StatusInternalServerError, Description: "The server failed to complete the request."},
+}
+
+// Error is a typed API error.
+type Error struct {
+	Code    Code           // Stable error code.
+	Status  int            // HTTP status code.
+	Message string         // Human-readable message.
+	Field   string         // Offending request field, if any.
+	Extra   map[string]any // Additional top-level response fields.
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e == nil {
+		return ""
+	}
+	return e.Message
+}
+
+// New constructs an Error.
+func New(status int, code Code, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+// WithField returns a copy of e that names the offending request field.
+func (e *Error) WithField(field string) *Error {
+	out := *e
+	out.Field = field
+	return &out
+}
+
+// With returns a copy of e that adds key to the response body.
+func (e *Error) With(key string, value any) *Error {
+	out := *e
+	out.Extra = make(map[string]any, len(e.Extra)+1)
+	for k, v := range e.Extra {
+		out.Extra[k] = v
+	}
+	out.Extra[key] = value
+	return &out
+}
+
+// InvalidJSON reports a request body that is not valid JSON.
+func InvalidJSON() *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, "invalid json")
+}
+
+// InvalidRequest reports a request that could not be parsed.
+func InvalidRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+// InvalidID reports a malformed path id.
+func InvalidID() *Error {
+	return New(http.StatusBadRequest, CodeInvalidID, "invalid id")
+}
+
+// Validation reports an invalid request field.
+func Validation(field, message string) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, message).WithField(field)
+}
+
+// Unauthorized reports a request without an authenticated admin.
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// Forbidden reports a request the admin is not allowed to perform.
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound reports a missing resource.
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+// Conflict reports a write rejected by a unique constraint.
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// Internal reports an unexpected server-side failure.
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Write renders err as JSON. Errors that are not *Error are rendered as internal errors
+// without exposing their message.
+func Write(c *gin.Context, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr == nil {
+		apiErr = Internal("internal error")
+	}
+	body := gin.H{"error": apiErr.Message, "code": apiErr.Code}
+	if apiErr.Field != "" {
+		body["field"] = apiErr.Field
+	}
+	for k, v := range apiErr.Extra {
+		if _, reserved := body[k]; !reserved {
+			body[k] = v
+		}
+	}
+	status := apiErr.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	c.JSON(status, body)
+}
@@ -0,0 +1,62 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func render(t *testing.T, err error) (int, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	Write(c, err)
+	var body map[string]any
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &body); errDecode != nil {
+		t.Fatalf("decode body: %v", errDecode)
+	}
+	return w.Code, body
+}
+
+func TestWriteRendersTypedErrors(t *testing.T) {
+	status, body := render(t, Validation("name", "name is required"))
+	if status != http.StatusBadRequest || body["code"] != string(CodeValidationFailed) || body["field"] != "name" || body["error"] != "name is required" {
+		t.Fatalf("unexpected validation error %d %v", status, body)
+	}
+
+	status, body = render(t, NotFound("auth files not found").With("missing_ids", []int{3}).With("code", "ignored"))
+	if status != http.StatusNotFound || body["code"] != string(CodeNotFound) {
+		t.Fatalf("unexpected not found error %d %v", status, body)
+	}
+	if ids, ok := body["missing_ids"].([]any); !ok || len(ids) != 1 {
+		t.Fatalf("expected missing_ids in body, got %v", body)
+	}
+	if _, ok := body["field"]; ok {
+		t.Fatalf("expected no field, got %v", body)
+	}
+
+	status, body = render(t, errors.New("pq: secret detail"))
+	if status != http.StatusInternalServerError || body["code"] != string(CodeInternal) || body["error"] != "internal error" {
+		t.Fatalf("expected untyped errors to be hidden, got %d %v", status, body)
+	}
+}
+
+func TestCodesDocumentsEveryCode(t *testing.T) {
+	seen := make(map[Code]bool, len(Codes))
+	for _, info := range Codes {
+		if seen[info.Code] || info.Status == 0 || info.Description == "" {
+			t.Fatalf("bad code entry %+v", info)
+		}
+		seen[info.Code] = true
+	}
+	for _, code := range []Code{CodeInvalidRequest, CodeInvalidID, CodeValidationFailed, CodeUnauthorized, CodeForbidden, CodeNotFound, CodeConflict, CodeInternal} {
+		if !seen[code] {
+			t.Fatalf("code %s is not documented", code)
+		}
+	}
+}
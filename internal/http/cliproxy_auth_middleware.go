@@ -8,7 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/access"
-	log "github.com/sirupsen/logrus"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
 )
 
 // CLIProxyAuthMiddleware enforces CLIProxyAPI authentication on selected routes.
@@ -47,8 +47,10 @@ func CLIProxyAuthMiddleware(manager *sdkaccess.Manager, websocketAuth bool) gin.
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
 		case errors.Is(err, access.ErrInsufficientBalance):
 			c.AbortWithStatusJSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient balance"})
+		case errors.Is(err, access.ErrDailySpendCapExceeded):
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Daily spend cap exceeded"})
 		default:
-			log.Errorf("authentication middleware error: %v", err)
+			logging.FromContext(c.Request.Context()).WithError(err).Error("authentication middleware error")
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Authentication service error"})
 		}
 	}
@@ -60,6 +62,10 @@ func requiresCLIProxyAuth(path string, websocketAuth bool) bool {
 		if path == "/v1/ws" && !websocketAuth {
 			return false
 		}
+		// Self-service key management is authenticated with a user JWT by CLIProxyMeMiddleware.
+		if isMeAPIKeysPath(normalizeRequestPath(path)) {
+			return false
+		}
 		return true
 	}
 	if hasPathPrefix(path, "/v1beta") {
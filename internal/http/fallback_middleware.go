@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	internalauth "github.com/router-for-me/CLIProxyAPIBusiness/internal/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// fallbackMetadataKey stores the provider/model fallback that served a request.
+const fallbackMetadataKey = "fallback"
+
+// fallbackEligiblePaths lists JSON endpoints whose body carries the requested model.
+var fallbackEligiblePaths = map[string]struct{}{
+	"/v1/chat/completions": {},
+	"/v1/completions":      {},
+	"/v1/messages":         {},
+	"/v1/responses":        {},
+}
+
+// CLIProxyFallbackMiddleware rewrites the requested model to a mapping's configured
+// fallback target when every credential for the requested model is cooling down.
+// Targets outside the support_models of the caller's plan are never chosen, because
+// CLIProxyPlanModelsMiddleware only checked the model before the rewrite.
+func CLIProxyFallbackMiddleware(manager *coreauth.Manager, planModels *billing.PlanModelCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if manager == nil || c == nil || c.Request == nil || c.Request.URL == nil || c.Request.Body == nil {
+			if c != nil {
+				c.Next()
+			}
+			return
+		}
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if _, ok := fallbackEligiblePaths[normalizeRequestPath(c.Request.URL.Path)]; !ok {
+			c.Next()
+			return
+		}
+
+		body, errRead := io.ReadAll(c.Request.Body)
+		if errRead != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read request body failed"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		model := strings.TrimSpace(gjson.GetBytes(body, "model").String())
+		allowed, okPlan := fallbackPlanFilter(c, planModels)
+		if !okPlan {
+			c.Next()
+			return
+		}
+		target, ok := internalauth.ResolveCooldownFallback(model, time.Now(), manager.List, allowed)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		rewritten, errSet := sjson.SetBytes(body, "model", target.Model)
+		if errSet != nil {
+			logging.FromContext(c.Request.Context()).WithError(errSet).Warn("fallback: rewrite request model failed")
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(rewritten))
+		c.Request.ContentLength = int64(len(rewritten))
+		c.Request.Header.Del("Content-Length")
+
+		if v, exists := c.Get("accessMetadata"); exists {
+			if meta, okMeta := v.(map[string]string); okMeta && meta != nil {
+				meta[fallbackMetadataKey] = target.String()
+				if _, overridden := meta[requestedModelMetadataKey]; !overridden {
+					meta[requestedModelMetadataKey] = model
+				}
+			}
+		}
+		logging.FromContext(c.Request.Context()).Infof("fallback: model %s cooling down, routing to %s", model, target.String())
+		c.Next()
+	}
+}
+
+// fallbackPlanFilter returns the plan allowlist fallback targets must pass for the caller,
+// or nil when the caller's plan does not restrict models. It reports false when the plan
+// cannot be resolved, in which case no fallback is attempted.
+func fallbackPlanFilter(c *gin.Context, planModels *billing.PlanModelCache) (func(model string) bool, bool) {
+	if planModels == nil {
+		return nil, true
+	}
+	userID, okUser := accessUserID(c)
+	if !okUser {
+		return nil, true
+	}
+	plan, errPlan := planModels.Get(c.Request.Context(), userID)
+	if errPlan != nil {
+		logging.FromContext(c.Request.Context()).WithError(errPlan).Warn("fallback: resolve plan models failed")
+		return nil, false
+	}
+	if !plan.Restricted {
+		return nil, true
+	}
+	return plan.Allows, true
+}
@@ -0,0 +1,246 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/front"
+	fronthandlers "github.com/router-for-me/CLIProxyAPIBusiness/internal/http/api/front/handlers"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultMeUsageWindow is the usage window used when no "from" is supplied.
+const defaultMeUsageWindow = 30 * 24 * time.Hour
+
+// meAPIKeysPath is the self-service API key collection. It is authenticated with a user
+// JWT rather than an API key, so a leaked or capped key cannot mint or revoke keys.
+const meAPIKeysPath = "/v1/me/api-keys"
+
+// isMeAPIKeysPath reports whether path addresses the self-service API key collection.
+func isMeAPIKeysPath(path string) bool {
+	return path == meAPIKeysPath || strings.HasPrefix(path, meAPIKeysPath+"/")
+}
+
+// CLIProxyMeMiddleware serves usage and balance summaries for the calling API key's user
+// and self-service API key rotation for the user signed in with jwtCfg.
+func CLIProxyMeMiddleware(db *gorm.DB, jwtCfg config.JWTConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c == nil || c.Request == nil || c.Request.URL == nil {
+			if c != nil {
+				c.Next()
+			}
+			return
+		}
+		if db == nil {
+			c.Next()
+			return
+		}
+
+		path := normalizeRequestPath(c.Request.URL.Path)
+		if isMeAPIKeysPath(path) {
+			serveMeAPIKeys(c, db, jwtCfg, path)
+			return
+		}
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		switch path {
+		case "/v1/me/usage":
+			userID, ok := accessUserID(c)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key is not bound to a user"})
+				return
+			}
+			serveMeUsage(c, db, userID)
+		case "/v1/me/balance":
+			userID, ok := accessUserID(c)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key is not bound to a user"})
+				return
+			}
+			serveMeBalance(c, db, userID)
+		default:
+			c.Next()
+		}
+	}
+}
+
+// serveMeAPIKeys creates (POST /v1/me/api-keys) or revokes (DELETE /v1/me/api-keys/:id)
+// keys owned by the user signed in with a front JWT through the front API key handler.
+func serveMeAPIKeys(c *gin.Context, db *gorm.DB, jwtCfg config.JWTConfig, path string) {
+	id := strings.Trim(strings.TrimPrefix(path, meAPIKeysPath), "/")
+	method := c.Request.Method
+	switch {
+	case id == "" && method == http.MethodPost:
+	case id != "" && !strings.Contains(id, "/") && method == http.MethodDelete:
+	default:
+		c.AbortWithStatusJSON(http.StatusMethodNotAllowed, gin.H{"error": "method not allowed"})
+		return
+	}
+	if !front.AuthenticateUser(c, db, jwtCfg) {
+		return
+	}
+
+	handler := fronthandlers.NewAPIKeyHandler(db)
+	if id == "" {
+		handler.Create(c)
+	} else {
+		c.Params = append(c.Params, gin.Param{Key: "id", Value: id})
+		handler.Revoke(c)
+	}
+	c.Abort()
+}
+
+// accessUserID returns the user ID attached to the authenticated API key.
+func accessUserID(c *gin.Context) (uint64, bool) {
+	v, exists := c.Get("accessMetadata")
+	if !exists {
+		return 0, false
+	}
+	meta, ok := v.(map[string]string)
+	if !ok {
+		return 0, false
+	}
+	raw := strings.TrimSpace(meta["user_id"])
+	if raw == "" {
+		return 0, false
+	}
+	parsed, errParse := strconv.ParseUint(raw, 10, 64)
+	if errParse != nil || parsed == 0 {
+		return 0, false
+	}
+	return parsed, true
+}
+
+// meUsageTotals captures aggregated usage for one user.
+type meUsageTotals struct {
+	Model           string `json:"model,omitempty"`  // Model name for per-model rows.
+	Requests        int64  `json:"requests"`         // Request count.
+	FailedRequests  int64  `json:"failed_requests"`  // Failed request count.
+	InputTokens     int64  `json:"input_tokens"`     // Input token total.
+	OutputTokens    int64  `json:"output_tokens"`    // Output token total.
+	ReasoningTokens int64  `json:"reasoning_tokens"` // Reasoning token total.
+	CachedTokens    int64  `json:"cached_tokens"`    // Cached token total.
+	TotalTokens     int64  `json:"total_tokens"`     // Total token count.
+	CostMicros      int64  `json:"cost_micros"`      // Cost in micros.
+}
+
+// meUsageSelect aggregates usage columns into meUsageTotals fields.
+const meUsageSelect = `
+	COUNT(*) AS requests,
+	COALESCE(SUM(CASE WHEN failed THEN 1 ELSE 0 END), 0) AS failed_requests,
+	COALESCE(SUM(input_tokens), 0) AS input_tokens,
+	COALESCE(SUM(output_tokens), 0) AS output_tokens,
+	COALESCE(SUM(reasoning_tokens), 0) AS reasoning_tokens,
+	COALESCE(SUM(cached_tokens), 0) AS cached_tokens,
+	COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(cost_micros), 0) AS cost_micros
+`
+
+// serveMeUsage writes the user's aggregated usage between "from" and "to" (RFC3339).
+func serveMeUsage(c *gin.Context, db *gorm.DB, userID uint64) {
+	now := time.Now().UTC()
+	to := now
+	from := now.Add(-defaultMeUsageWindow)
+	if raw := strings.TrimSpace(c.Query("from")); raw != "" {
+		parsed, errParse := time.Parse(time.RFC3339, raw)
+		if errParse != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+		from = parsed.UTC()
+	}
+	if raw := strings.TrimSpace(c.Query("to")); raw != "" {
+		parsed, errParse := time.Parse(time.RFC3339, raw)
+		if errParse != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+		to = parsed.UTC()
+	}
+	if to.Before(from) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scoped := func() *gorm.DB {
+		return db.WithContext(ctx).Model(&models.Usage{}).
+			Where("user_id = ? AND requested_at >= ? AND requested_at <= ?", userID, from, to)
+	}
+
+	var totals meUsageTotals
+	if errTotals := scoped().Select(meUsageSelect).Scan(&totals).Error; errTotals != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "query usage failed"})
+		return
+	}
+	byModel := make([]meUsageTotals, 0)
+	if errModels := scoped().
+		Select("model, " + meUsageSelect).
+		Group("model").
+		Order("cost_micros DESC").
+		Scan(&byModel).Error; errModels != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "query usage failed"})
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusOK, gin.H{
+		"user_id":  userID,
+		"from":     from,
+		"to":       to,
+		"totals":   totals,
+		"cost":     float64(totals.CostMicros) / 1_000_000,
+		"currency": billing.DisplayCurrency(),
+		"by_model": byModel,
+	})
+}
+
+// serveMeBalance writes the user's remaining bill quota, prepaid balance and overage debt.
+func serveMeBalance(c *gin.Context, db *gorm.DB, userID uint64) {
+	ctx := c.Request.Context()
+	now := time.Now().UTC()
+
+	var billQuota float64
+	if errBills := db.WithContext(ctx).Model(&models.Bill{}).
+		Select("COALESCE(SUM(left_quota), 0)").
+		Where("user_id = ? AND is_enabled = ? AND status = ? AND left_quota > 0", userID, true, models.BillStatusPaid).
+		Where("period_start <= ? AND period_end >= ?", now, now).
+		Scan(&billQuota).Error; errBills != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "query balance failed"})
+		return
+	}
+
+	var prepaidBalance float64
+	if errCards := db.WithContext(ctx).Model(&models.PrepaidCard{}).
+		Select("COALESCE(SUM(balance), 0)").
+		Where("redeemed_user_id = ? AND is_enabled = ? AND balance > 0 AND redeemed_at IS NOT NULL", userID, true).
+		Where("(expires_at IS NULL OR expires_at >= ?)", now).
+		Scan(&prepaidBalance).Error; errCards != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "query balance failed"})
+		return
+	}
+
+	var overageDebt float64
+	if errDebt := db.WithContext(ctx).Model(&models.User{}).
+		Select("overage_debt").
+		Where("id = ?", userID).
+		Scan(&overageDebt).Error; errDebt != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "query balance failed"})
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusOK, gin.H{
+		"user_id":         userID,
+		"bill_quota":      billQuota,
+		"prepaid_balance": prepaidBalance,
+		"total_balance":   billQuota + prepaidBalance,
+		"overage_debt":    overageDebt,
+	})
+}
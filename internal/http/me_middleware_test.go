@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/security"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+// meJWTConfig signs the user tokens accepted by the self-service key endpoints in tests.
+var meJWTConfig = config.JWTConfig{Secret: "me-secret", Expiry: time.Hour}
+
+func setupMeAPIKeys(t *testing.T) (*gorm.DB, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:me_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.APIKey{}, &models.User{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	r := gin.New()
+	r.Use(CLIProxyMeMiddleware(db, meJWTConfig))
+	return db, r
+}
+
+// meUserToken creates a user and returns a front JWT for it.
+func meUserToken(t *testing.T, db *gorm.DB, username string) (models.User, string) {
+	t.Helper()
+	user := models.User{Username: username, Email: username + "@example.com", Password: "x", Active: true}
+	if errCreate := db.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	token, errToken := security.GenerateToken(meJWTConfig.Secret, user.ID, user.Username, "", user.Email, time.Hour)
+	if errToken != nil {
+		t.Fatalf("generate token: %v", errToken)
+	}
+	return user, token
+}
+
+func TestMeAPIKeysCreateAndRevoke(t *testing.T) {
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.APIKeyMaxPerUserKey: json.RawMessage(`2`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	db, r := setupMeAPIKeys(t)
+	user, token := meUserToken(t, db, "owner")
+	other, _ := meUserToken(t, db, "other")
+	foreign := models.APIKey{UserID: &other.ID, Name: "other", APIKey: "sk-other", Active: true}
+	if errCreate := db.Create(&foreign).Error; errCreate != nil {
+		t.Fatalf("create key: %v", errCreate)
+	}
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	create := func() *httptest.ResponseRecorder {
+		return send(http.MethodPost, "/v1/me/api-keys", `{"name":"rotated"}`)
+	}
+	w := create()
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ID    uint64 `json:"id"`
+		Token string `json:"token"`
+	}
+	if errDecode := json.Unmarshal(w.Body.Bytes(), &created); errDecode != nil || created.Token == "" {
+		t.Fatalf("expected plaintext token on create, got %s", w.Body.String())
+	}
+	var row models.APIKey
+	if errFind := db.First(&row, created.ID).Error; errFind != nil || row.UserID == nil || *row.UserID != user.ID {
+		t.Fatalf("expected key owned by user %d, got %+v %v", user.ID, row, errFind)
+	}
+
+	if w = create(); w.Code != http.StatusCreated {
+		t.Fatalf("expected second key, got %d", w.Code)
+	}
+	if w = create(); w.Code != http.StatusConflict {
+		t.Fatalf("expected limit to be enforced, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = send(http.MethodDelete, "/v1/me/api-keys/"+strconv.FormatUint(foreign.ID, 10), "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected foreign key to be hidden, got %d", w.Code)
+	}
+	w = send(http.MethodDelete, "/v1/me/api-keys/"+strconv.FormatUint(created.ID, 10), "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected revoke, got %d: %s", w.Code, w.Body.String())
+	}
+	if errFind := db.First(&row, created.ID).Error; errFind != nil || row.RevokedAt == nil || row.Active {
+		t.Fatalf("expected revoked key, got %+v %v", row, errFind)
+	}
+	if w = create(); w.Code != http.StatusCreated {
+		t.Fatalf("expected revoked keys not to count toward the limit, got %d", w.Code)
+	}
+}
+
+func TestMeAPIKeysRejectsAPIKeyCredentials(t *testing.T) {
+	db, _ := setupMeAPIKeys(t)
+	user, _ := meUserToken(t, db, "keyholder")
+	// Simulate a request already authenticated by a user-bound API key.
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("accessMetadata", map[string]string{"user_id": strconv.FormatUint(user.ID, 10)})
+	}, CLIProxyMeMiddleware(db, meJWTConfig))
+
+	for _, tc := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/me/api-keys"},
+		{http.MethodDelete, "/v1/me/api-keys/1"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"name":"x"}`))
+		req.Header.Set("Authorization", "Bearer sk-leaked")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s %s: expected api key credentials to be rejected, got %d", tc.method, tc.path, w.Code)
+		}
+	}
+	var count int64
+	db.Model(&models.APIKey{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no key to be created, got %d", count)
+	}
+	if requiresCLIProxyAuth("/v1/me/api-keys", false) || !requiresCLIProxyAuth("/v1/me/balance", false) {
+		t.Fatalf("expected only key management to skip api key auth")
+	}
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// headerModelOverride names the request header that replaces the body model.
+const headerModelOverride = "X-Model-Override"
+
+// Access metadata keys describing model rewrites, read by the usage plugin.
+const (
+	// requestedModelMetadataKey stores the model the client sent before any rewrite.
+	requestedModelMetadataKey = "requested_model"
+	// modelOverrideMetadataKey stores the model taken from X-Model-Override.
+	modelOverrideMetadataKey = "model_override"
+)
+
+// CLIProxyModelOverrideMiddleware replaces the request body model with the X-Model-Override
+// header when MODEL_OVERRIDE_HEADER permits the calling API key. It runs before fallback,
+// mapping and selection, so user group allowlists are enforced against the new model.
+// Requests carrying the header without permission, or on an endpoint without a body
+// model, are rejected rather than silently served with the original model.
+func CLIProxyModelOverrideMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c == nil || c.Request == nil || c.Request.URL == nil {
+			if c != nil {
+				c.Next()
+			}
+			return
+		}
+		override := strings.TrimSpace(c.GetHeader(headerModelOverride))
+		if override == "" {
+			c.Next()
+			return
+		}
+		c.Request.Header.Del(headerModelOverride)
+		_, jsonEndpoint := fallbackEligiblePaths[normalizeRequestPath(c.Request.URL.Path)]
+		if c.Request.Method != http.MethodPost || c.Request.Body == nil || !jsonEndpoint {
+			// Routes without a body model, such as the Gemini ones, cannot honour the header.
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "model override not supported on this endpoint"})
+			return
+		}
+
+		meta := accessMetadata(c)
+		if !modelOverrideAllowed(meta) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "model override not permitted"})
+			return
+		}
+
+		body, errRead := io.ReadAll(c.Request.Body)
+		if errRead != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read request body failed"})
+			return
+		}
+		requested := strings.TrimSpace(gjson.GetBytes(body, "model").String())
+		rewritten, errSet := sjson.SetBytes(body, "model", override)
+		if errSet != nil {
+			logging.FromContext(c.Request.Context()).WithError(errSet).Warn("model override: rewrite request model failed")
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(rewritten))
+		c.Request.ContentLength = int64(len(rewritten))
+		c.Request.Header.Del("Content-Length")
+
+		if meta != nil {
+			if _, branded := meta[requestedModelMetadataKey]; !branded {
+				meta[requestedModelMetadataKey] = requested
+			}
+			meta[modelOverrideMetadataKey] = override
+		}
+		logging.FromContext(c.Request.Context()).Infof("model override: api key %s routed model %s to %s", meta["api_key_id"], requested, override)
+		c.Next()
+	}
+}
+
+// modelOverrideAllowed applies MODEL_OVERRIDE_HEADER to the caller's access metadata.
+func modelOverrideAllowed(meta map[string]string) bool {
+	if meta == nil {
+		return false
+	}
+	switch modelOverridePolicy() {
+	case internalsettings.ModelOverrideAllKeys:
+		return true
+	case internalsettings.ModelOverrideAdminKeys:
+		return meta["is_admin"] == "true"
+	default:
+		return false
+	}
+}
+
+// modelOverridePolicy reads MODEL_OVERRIDE_HEADER, falling back to disabled.
+func modelOverridePolicy() string {
+	return internalsettings.GetString(internalsettings.ModelOverrideHeaderKey)
+}
+
+// accessMetadata returns the access metadata set by the auth middleware, if any.
+func accessMetadata(c *gin.Context) map[string]string {
+	v, exists := c.Get("accessMetadata")
+	if !exists {
+		return nil
+	}
+	meta, _ := v.(map[string]string)
+	return meta
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/tidwall/gjson"
+)
+
+func TestModelOverrideMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	type seen struct {
+		model string
+		meta  map[string]string
+	}
+	var last seen
+	newRouter := func(isAdmin string) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("accessMetadata", map[string]string{"api_key_id": "1", "is_admin": isAdmin})
+		}, CLIProxyModelOverrideMiddleware())
+		r.POST("/v1/chat/completions", func(c *gin.Context) {
+			body, _ := io.ReadAll(c.Request.Body)
+			last = seen{model: gjson.GetBytes(body, "model").String(), meta: accessMetadata(c)}
+			c.Status(http.StatusOK)
+		})
+		r.POST("/v1beta/models/*action", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return r
+	}
+	sendTo := func(r *gin.Engine, path, override string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"model":"gpt-4o","messages":[]}`))
+		if override != "" {
+			req.Header.Set(headerModelOverride, override)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	send := func(r *gin.Engine, override string) int {
+		return sendTo(r, "/v1/chat/completions", override)
+	}
+	setPolicy := func(policy string) {
+		raw, _ := json.Marshal(policy)
+		internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{internalsettings.ModelOverrideHeaderKey: raw})
+	}
+
+	userRouter := newRouter("false")
+	adminRouter := newRouter("true")
+
+	if code := send(userRouter, ""); code != http.StatusOK || last.model != "gpt-4o" || last.meta[requestedModelMetadataKey] != "" {
+		t.Fatalf("expected untouched request without header, got %d %+v", code, last)
+	}
+	if code := send(adminRouter, "claude-sonnet-4-5"); code != http.StatusForbidden {
+		t.Fatalf("expected override to be rejected while disabled, got %d", code)
+	}
+
+	setPolicy(internalsettings.ModelOverrideAdminKeys)
+	if code := send(userRouter, "claude-sonnet-4-5"); code != http.StatusForbidden {
+		t.Fatalf("expected user key to be rejected under admin_keys, got %d", code)
+	}
+	if code := send(adminRouter, "claude-sonnet-4-5"); code != http.StatusOK || last.model != "claude-sonnet-4-5" {
+		t.Fatalf("expected admin key override, got %d %+v", code, last)
+	}
+	if last.meta[requestedModelMetadataKey] != "gpt-4o" || last.meta[modelOverrideMetadataKey] != "claude-sonnet-4-5" {
+		t.Fatalf("expected requested and override models in metadata, got %+v", last.meta)
+	}
+
+	setPolicy(internalsettings.ModelOverrideAllKeys)
+	if code := send(userRouter, "claude-sonnet-4-5"); code != http.StatusOK || last.model != "claude-sonnet-4-5" {
+		t.Fatalf("expected user key override under all_keys, got %d %+v", code, last)
+	}
+	if code := sendTo(userRouter, "/v1beta/models/gemini-pro:generateContent", "gemini-flash"); code != http.StatusBadRequest {
+		t.Fatalf("expected override on a route without a body model to be rejected, got %d", code)
+	}
+	if code := sendTo(userRouter, "/v1beta/models/gemini-pro:generateContent", ""); code != http.StatusOK {
+		t.Fatalf("expected the route to be served without the header, got %d", code)
+	}
+}
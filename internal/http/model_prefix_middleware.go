@@ -0,0 +1,104 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/gorm"
+)
+
+// CLIProxyModelPrefixMiddleware strips the caller's user group model prefix from the
+// request body model, so overrides, fallbacks, mapping, selection and billing all see the
+// canonical name. The branded name is kept as the requested model for the logs. Models
+// sent without the prefix pass through unchanged.
+func CLIProxyModelPrefixMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if db == nil || c == nil || c.Request == nil || c.Request.URL == nil || c.Request.Body == nil {
+			if c != nil {
+				c.Next()
+			}
+			return
+		}
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if _, ok := fallbackEligiblePaths[normalizeRequestPath(c.Request.URL.Path)]; !ok {
+			c.Next()
+			return
+		}
+		userGroups, _, okUser := loadUserGroupMembership(c, db)
+		if !okUser {
+			c.Next()
+			return
+		}
+		prefix, errPrefix := loadUserGroupModelPrefix(c.Request.Context(), db, userGroups)
+		if errPrefix != nil {
+			logging.FromContext(c.Request.Context()).WithError(errPrefix).Warn("model prefix: load user group prefix failed")
+			c.Next()
+			return
+		}
+		if prefix == "" {
+			c.Next()
+			return
+		}
+
+		body, errRead := io.ReadAll(c.Request.Body)
+		if errRead != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read request body failed"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		requested := strings.TrimSpace(gjson.GetBytes(body, "model").String())
+		canonical, stripped := modelmapping.StripModelPrefix(prefix, requested)
+		if !stripped {
+			c.Next()
+			return
+		}
+		rewritten, errSet := sjson.SetBytes(body, "model", canonical)
+		if errSet != nil {
+			logging.FromContext(c.Request.Context()).WithError(errSet).Warn("model prefix: rewrite request model failed")
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(rewritten))
+		c.Request.ContentLength = int64(len(rewritten))
+		c.Request.Header.Del("Content-Length")
+
+		if meta := accessMetadata(c); meta != nil {
+			meta[requestedModelMetadataKey] = requested
+		}
+		c.Next()
+	}
+}
+
+// loadUserGroupModelPrefix returns the model prefix of the caller's user groups. When
+// several groups carry one, the group with the lowest ID wins.
+func loadUserGroupModelPrefix(ctx context.Context, db *gorm.DB, userGroups models.UserGroupIDs) (string, error) {
+	ids := userGroups.Values()
+	if len(ids) == 0 {
+		return "", nil
+	}
+	var prefixes []string
+	if errFind := db.WithContext(ctx).Model(&models.UserGroup{}).
+		Where("id IN ? AND model_prefix <> ''", ids).
+		Order("id ASC").
+		Limit(1).
+		Pluck("model_prefix", &prefixes).Error; errFind != nil {
+		return "", errFind
+	}
+	if len(prefixes) == 0 {
+		return "", nil
+	}
+	return prefixes[0], nil
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+)
+
+func TestModelPrefixMiddlewareBrandsAndStripsModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.OnlyMappedModelsKey:         json.RawMessage(`true`),
+		internalsettings.ModelListCacheTTLSecondsKey: json.RawMessage(`0`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	dsn := fmt.Sprintf("file:modelprefix_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.UserGroup{}, &models.ModelMapping{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	plain := models.UserGroup{Name: "plain"}
+	branded := models.UserGroup{Name: "acme", ModelPrefix: "acme-"}
+	if errCreate := db.Create([]*models.UserGroup{&plain, &branded}).Error; errCreate != nil {
+		t.Fatalf("create groups: %v", errCreate)
+	}
+	reseller := models.User{Username: "reseller", Email: "reseller@example.com", UserGroupID: models.UserGroupIDs{&plain.ID, &branded.ID}}
+	direct := models.User{Username: "direct", Email: "direct@example.com", UserGroupID: models.UserGroupIDs{&plain.ID}}
+	if errCreate := db.Create([]*models.User{&reseller, &direct}).Error; errCreate != nil {
+		t.Fatalf("create users: %v", errCreate)
+	}
+	mapping := models.ModelMapping{Provider: "openai", ModelName: "gpt-5", NewModelName: "gpt-large", IsEnabled: true}
+	if errCreate := db.Create(&mapping).Error; errCreate != nil {
+		t.Fatalf("create mapping: %v", errCreate)
+	}
+
+	var seenModel string
+	var seenMeta map[string]string
+	newRouter := func(userID uint64) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("accessMetadata", map[string]string{"user_id": fmt.Sprint(userID)})
+		}, CLIProxyModelPrefixMiddleware(db), CLIProxyModelsMiddleware(db, nil, nil))
+		r.POST("/v1/chat/completions", func(c *gin.Context) {
+			body, _ := io.ReadAll(c.Request.Body)
+			seenModel, seenMeta = gjson.GetBytes(body, "model").String(), accessMetadata(c)
+			c.Status(http.StatusOK)
+		})
+		return r
+	}
+	chat := func(r *gin.Engine, model string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"`+model+`"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("chat %s: expected 200, got %d", model, w.Code)
+		}
+	}
+	listIDs := func(r *gin.Engine) []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+		var ids []string
+		for _, id := range gjson.GetBytes(w.Body.Bytes(), "data.#.id").Array() {
+			ids = append(ids, id.String())
+		}
+		return ids
+	}
+
+	resellerRouter := newRouter(reseller.ID)
+	if ids := listIDs(resellerRouter); fmt.Sprint(ids) != "[acme-gpt-large]" {
+		t.Fatalf("expected branded model ids, got %v", ids)
+	}
+	chat(resellerRouter, "acme-gpt-large")
+	if seenModel != "gpt-large" || seenMeta[requestedModelMetadataKey] != "acme-gpt-large" {
+		t.Fatalf("expected the prefix to be stripped, got %q %+v", seenModel, seenMeta)
+	}
+	chat(resellerRouter, "gpt-large")
+	if seenModel != "gpt-large" || seenMeta[requestedModelMetadataKey] != "" {
+		t.Fatalf("expected canonical names to pass through, got %q %+v", seenModel, seenMeta)
+	}
+
+	directRouter := newRouter(direct.ID)
+	if ids := listIDs(directRouter); fmt.Sprint(ids) != "[gpt-large]" {
+		t.Fatalf("expected canonical ids without a prefix, got %v", ids)
+	}
+	chat(directRouter, "acme-gpt-large")
+	if seenModel != "acme-gpt-large" {
+		t.Fatalf("expected requests outside the group to be untouched, got %q", seenModel)
+	}
+}
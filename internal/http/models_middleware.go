@@ -1,9 +1,8 @@
 package http
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
@@ -12,6 +11,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	sdkcliproxy "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelcache"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelregistry"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
@@ -19,8 +20,18 @@ import (
 	"gorm.io/gorm"
 )
 
-// CLIProxyModelsMiddleware serves model list responses with optional DB mappings.
-func CLIProxyModelsMiddleware(db *gorm.DB, store *modelregistry.Store) gin.HandlerFunc {
+// Model list flavors served by CLIProxyModelsMiddleware.
+const (
+	modelListOpenAI = "openai"
+	modelListClaude = "claude"
+	modelListGemini = "gemini"
+)
+
+// CLIProxyModelsMiddleware serves model list responses with optional DB mappings, leaving
+// out models the caller's plan does not include. Computed lists are cached per flavor,
+// caller user groups and plan models, see modelcache.
+func CLIProxyModelsMiddleware(db *gorm.DB, store *modelregistry.Store, planModels *billing.PlanModelCache) gin.HandlerFunc {
+	cache := modelcache.New[gin.H]()
 	return func(c *gin.Context) {
 		if c == nil || c.Request == nil || c.Request.URL == nil {
 			if c != nil {
@@ -34,172 +45,279 @@ func CLIProxyModelsMiddleware(db *gorm.DB, store *modelregistry.Store) gin.Handl
 			return
 		}
 
-		path := normalizeRequestPath(c.Request.URL.Path)
-		switch path {
+		var flavor string
+		switch normalizeRequestPath(c.Request.URL.Path) {
 		case "/v1/models":
-			onlyMapped := dbConfigBool("ONLY_MAPPED_MODELS")
-			userGroups, billUserGroups, okUser := loadUserGroupMembership(c, db)
-			userAgent := c.GetHeader("User-Agent")
-			if strings.HasPrefix(userAgent, "claude-cli") {
-				if !onlyMapped {
-					data := sdkcliproxy.GlobalModelRegistry().GetAvailableModels("claude")
-					if okUser {
-						data = filterOpenAIRegistryModelsByUserGroups(data, "claude", userGroups, billUserGroups)
-					}
-					c.AbortWithStatusJSON(http.StatusOK, gin.H{"data": data})
-					return
-				}
-
-				modelInfos, errList := listMappedModelInfos(c.Request.Context(), db, store)
-				if errList != nil {
-					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "list models failed"})
-					return
-				}
-
-				if okUser {
-					modelInfos = filterModelInfosByUserGroups(modelInfos, userGroups, billUserGroups)
-				}
-
-				data := make([]map[string]any, 0, len(modelInfos))
-				for _, info := range modelInfos {
-					m := convertModelToMap(info, "claude")
-					if m != nil {
-						data = append(data, m)
-					}
-				}
-				c.AbortWithStatusJSON(http.StatusOK, gin.H{"data": data})
-				return
+			flavor = modelListOpenAI
+			if strings.HasPrefix(c.GetHeader("User-Agent"), "claude-cli") {
+				flavor = modelListClaude
 			}
+		case "/v1beta/models":
+			flavor = modelListGemini
+		default:
+			c.Next()
+			return
+		}
 
-			if !onlyMapped {
-				allModels := sdkcliproxy.GlobalModelRegistry().GetAvailableModels("openai")
-				filtered := make([]map[string]any, 0, len(allModels))
-				for _, model := range allModels {
-					if okUser {
-						if id, ok := model["id"].(string); ok && strings.TrimSpace(id) != "" {
-							if allowed, okAllowed := modelmapping.LookupUserGroupIDs("openai", strings.TrimSpace(id)); okAllowed && len(allowed.Clean()) > 0 {
-								if !hasAnyAllowedUserGroup(allowed, userGroups, billUserGroups) {
-									continue
-								}
-							}
-						}
-					}
-					filteredModel := map[string]any{
-						"id":     model["id"],
-						"object": model["object"],
-					}
-					if created, exists := model["created"]; exists {
-						filteredModel["created"] = created
-					}
-					if ownedBy, exists := model["owned_by"]; exists {
-						filteredModel["owned_by"] = ownedBy
-					}
-					filtered = append(filtered, filteredModel)
-				}
-				c.AbortWithStatusJSON(http.StatusOK, gin.H{"object": "list", "data": filtered})
+		onlyMapped := internalsettings.GetBool(internalsettings.OnlyMappedModelsKey)
+		userGroups, billUserGroups, okUser := loadUserGroupMembership(c, db)
+		var modelPrefix string
+		if okUser && flavor != modelListGemini {
+			prefix, errPrefix := loadUserGroupModelPrefix(c.Request.Context(), db, userGroups)
+			if errPrefix != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "list models failed"})
 				return
 			}
-
-			modelInfos, errList := listMappedModelInfos(c.Request.Context(), db, store)
-			if errList != nil {
+			modelPrefix = prefix
+		}
+		var plan billing.PlanModels
+		if userID, okID := accessUserID(c); okID && planModels != nil {
+			resolved, errPlan := planModels.Get(c.Request.Context(), userID)
+			if errPlan != nil {
 				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "list models failed"})
 				return
 			}
-
-			if okUser {
-				modelInfos = filterModelInfosByUserGroups(modelInfos, userGroups, billUserGroups)
+			plan = resolved
+		}
+		key := modelListCacheKey(flavor, onlyMapped, okUser, userGroups, billUserGroups, modelPrefix, plan.Key())
+		body, _, errList := cache.Get(key, func() (gin.H, error) {
+			lister := modelLister{
+				ctx:            c.Request.Context(),
+				db:             db,
+				store:          store,
+				onlyMapped:     onlyMapped,
+				filterByGroups: okUser,
+				userGroups:     userGroups,
+				billUserGroups: billUserGroups,
+				modelPrefix:    modelPrefix,
+				plan:           plan,
 			}
-
-			data := make([]map[string]any, 0, len(modelInfos))
-			for _, info := range modelInfos {
-				if info == nil || strings.TrimSpace(info.ID) == "" {
-					continue
-				}
-				item := map[string]any{
-					"id":       info.ID,
-					"object":   "model",
-					"owned_by": info.OwnedBy,
-				}
-				if info.Created > 0 {
-					item["created"] = info.Created
-				}
-				data = append(data, item)
+			switch flavor {
+			case modelListClaude:
+				return lister.claude()
+			case modelListGemini:
+				return lister.gemini()
+			default:
+				return lister.openAI()
 			}
-
-			c.AbortWithStatusJSON(http.StatusOK, gin.H{"object": "list", "data": data})
+		})
+		if errList != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "list models failed"})
 			return
+		}
+		c.AbortWithStatusJSON(http.StatusOK, body)
+	}
+}
 
-		case "/v1beta/models":
-			onlyMapped := dbConfigBool("ONLY_MAPPED_MODELS")
-			userGroups, billUserGroups, okUser := loadUserGroupMembership(c, db)
-			rawModels := make([]map[string]any, 0)
-			if !onlyMapped {
-				rawModels = sdkcliproxy.GlobalModelRegistry().GetAvailableModels("gemini")
-				if okUser {
-					rawModels = filterGeminiRegistryModelsByUserGroups(rawModels, userGroups, billUserGroups)
-				}
-			} else {
-				modelInfos, errList := listMappedModelInfos(c.Request.Context(), db, store)
-				if errList != nil {
-					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "list models failed"})
-					return
-				}
-				if okUser {
-					modelInfos = filterModelInfosByUserGroups(modelInfos, userGroups, billUserGroups)
-				}
+// modelListCacheKey identifies a model list response: visibility and model prefix depend
+// on the caller's user groups and plan models, so callers with the same groups and plan
+// access share an entry. Mapping changes need no part in the key: they move the cache
+// version, see modelcache.New.
+func modelListCacheKey(flavor string, onlyMapped, filterByGroups bool, userGroups, billUserGroups models.UserGroupIDs, modelPrefix, planKey string) string {
+	groups := "*"
+	if filterByGroups {
+		groups = joinSortedGroupIDs(userGroups) + "/" + joinSortedGroupIDs(billUserGroups)
+	}
+	return fmt.Sprintf("%s|%t|%s|%s|%s", flavor, onlyMapped, groups, modelPrefix, planKey)
+}
+
+// joinSortedGroupIDs renders group IDs in ascending order.
+func joinSortedGroupIDs(ids models.UserGroupIDs) string {
+	values := ids.Values()
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	parts := make([]string, 0, len(values))
+	for _, id := range values {
+		parts = append(parts, strconv.FormatUint(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// modelLister builds model list responses for one caller.
+type modelLister struct {
+	ctx            context.Context
+	db             *gorm.DB
+	store          *modelregistry.Store
+	onlyMapped     bool
+	filterByGroups bool // Whether the caller is a user whose groups restrict visibility.
+	userGroups     models.UserGroupIDs
+	billUserGroups models.UserGroupIDs
+	// modelPrefix brands the listed model IDs. Gemini lists are never branded: their
+	// requests carry the model in the route, which CLIProxyModelPrefixMiddleware cannot strip.
+	modelPrefix string
+	plan        billing.PlanModels // Plan models the caller is limited to, when restricted.
+}
+
+// planModels drops the models the caller's plan does not include. field names the
+// model key; Gemini names are matched without their "models/" prefix.
+func (l modelLister) planModels(data []map[string]any, field string) []map[string]any {
+	if !l.plan.Restricted {
+		return data
+	}
+	out := make([]map[string]any, 0, len(data))
+	for _, model := range data {
+		name, _ := model[field].(string)
+		if l.plan.Allows(strings.TrimPrefix(strings.TrimSpace(name), "models/")) {
+			out = append(out, model)
+		}
+	}
+	return out
+}
+
+// brandModels returns copies of data with the model prefix applied to each "id".
+func (l modelLister) brandModels(data []map[string]any) []map[string]any {
+	if l.modelPrefix == "" {
+		return data
+	}
+	out := make([]map[string]any, 0, len(data))
+	for _, model := range data {
+		branded := make(map[string]any, len(model))
+		for k, v := range model {
+			branded[k] = v
+		}
+		if id, ok := model["id"].(string); ok {
+			branded["id"] = modelmapping.ApplyModelPrefix(l.modelPrefix, id)
+		}
+		out = append(out, branded)
+	}
+	return out
+}
+
+// mappedModelInfos lists the mapped models visible to the caller. Mappings are filtered
+// by their own user groups before aliases are de-duplicated, so an alias shared by
+// mappings restricted to different groups lists the caller's mapping and its owner.
+func (l modelLister) mappedModelInfos() ([]*sdkcliproxy.ModelInfo, error) {
+	var visible func(models.ModelMapping) bool
+	if l.filterByGroups {
+		visible = func(row models.ModelMapping) bool {
+			return hasAnyAllowedUserGroup(row.UserGroupID, l.userGroups, l.billUserGroups)
+		}
+	}
+	return listMappedModelInfos(l.ctx, l.db, l.store, visible)
+}
+
+// claude builds the /v1/models response for Claude Code clients.
+func (l modelLister) claude() (gin.H, error) {
+	if !l.onlyMapped {
+		data := sdkcliproxy.GlobalModelRegistry().GetAvailableModels("claude")
+		if l.filterByGroups {
+			data = filterOpenAIRegistryModelsByUserGroups(data, "claude", l.userGroups, l.billUserGroups)
+		}
+		return gin.H{"data": l.brandModels(l.planModels(data, "id"))}, nil
+	}
+
+	modelInfos, errList := l.mappedModelInfos()
+	if errList != nil {
+		return nil, errList
+	}
+	data := make([]map[string]any, 0, len(modelInfos))
+	for _, info := range modelInfos {
+		m := convertModelToMap(info, "claude")
+		if m != nil {
+			data = append(data, m)
+		}
+	}
+	return gin.H{"data": l.brandModels(l.planModels(data, "id"))}, nil
+}
 
-				rawModels = make([]map[string]any, 0, len(modelInfos))
-				for _, info := range modelInfos {
-					m := convertModelToMap(info, "gemini")
-					if m != nil {
-						rawModels = append(rawModels, m)
+// openAI builds the /v1/models response for OpenAI-compatible clients.
+func (l modelLister) openAI() (gin.H, error) {
+	if !l.onlyMapped {
+		allModels := sdkcliproxy.GlobalModelRegistry().GetAvailableModels("openai")
+		filtered := make([]map[string]any, 0, len(allModels))
+		for _, model := range allModels {
+			if l.filterByGroups {
+				if id, ok := model["id"].(string); ok && strings.TrimSpace(id) != "" {
+					if allowed, okAllowed := modelmapping.LookupUserGroupIDs("openai", strings.TrimSpace(id)); okAllowed && len(allowed.Clean()) > 0 {
+						if !hasAnyAllowedUserGroup(allowed, l.userGroups, l.billUserGroups) {
+							continue
+						}
 					}
 				}
 			}
+			filteredModel := map[string]any{
+				"id":     model["id"],
+				"object": model["object"],
+			}
+			if created, exists := model["created"]; exists {
+				filteredModel["created"] = created
+			}
+			if ownedBy, exists := model["owned_by"]; exists {
+				filteredModel["owned_by"] = ownedBy
+			}
+			filtered = append(filtered, filteredModel)
+		}
+		return gin.H{"object": "list", "data": l.brandModels(l.planModels(filtered, "id"))}, nil
+	}
 
-			normalizedModels := make([]map[string]any, 0, len(rawModels))
-			defaultMethods := []string{"generateContent"}
-			for _, model := range rawModels {
-				normalizedModel := make(map[string]any, len(model))
-				for k, v := range model {
-					normalizedModel[k] = v
-				}
-				if name, ok := normalizedModel["name"].(string); ok && name != "" && !strings.HasPrefix(name, "models/") {
-					normalizedModel["name"] = "models/" + name
-				}
-				if _, ok := normalizedModel["supportedGenerationMethods"]; !ok {
-					normalizedModel["supportedGenerationMethods"] = defaultMethods
-				}
-				normalizedModels = append(normalizedModels, normalizedModel)
+	modelInfos, errList := l.mappedModelInfos()
+	if errList != nil {
+		return nil, errList
+	}
+	data := make([]map[string]any, 0, len(modelInfos))
+	for _, info := range modelInfos {
+		if info == nil || strings.TrimSpace(info.ID) == "" {
+			continue
+		}
+		item := map[string]any{
+			"id":       info.ID,
+			"object":   "model",
+			"owned_by": info.OwnedBy,
+		}
+		if info.Created > 0 {
+			item["created"] = info.Created
+		}
+		data = append(data, item)
+	}
+	return gin.H{"object": "list", "data": l.brandModels(l.planModels(data, "id"))}, nil
+}
+
+// gemini builds the /v1beta/models response.
+func (l modelLister) gemini() (gin.H, error) {
+	rawModels := make([]map[string]any, 0)
+	if !l.onlyMapped {
+		rawModels = sdkcliproxy.GlobalModelRegistry().GetAvailableModels("gemini")
+		if l.filterByGroups {
+			rawModels = filterGeminiRegistryModelsByUserGroups(rawModels, l.userGroups, l.billUserGroups)
+		}
+	} else {
+		modelInfos, errList := l.mappedModelInfos()
+		if errList != nil {
+			return nil, errList
+		}
+		rawModels = make([]map[string]any, 0, len(modelInfos))
+		for _, info := range modelInfos {
+			m := convertModelToMap(info, "gemini")
+			if m != nil {
+				rawModels = append(rawModels, m)
 			}
+		}
+	}
 
-			c.AbortWithStatusJSON(http.StatusOK, gin.H{"models": normalizedModels})
-			return
-		default:
-			c.Next()
-			return
+	rawModels = l.planModels(rawModels, "name")
+	normalizedModels := make([]map[string]any, 0, len(rawModels))
+	defaultMethods := []string{"generateContent"}
+	for _, model := range rawModels {
+		normalizedModel := make(map[string]any, len(model))
+		for k, v := range model {
+			normalizedModel[k] = v
+		}
+		if name, ok := normalizedModel["name"].(string); ok && name != "" && !strings.HasPrefix(name, "models/") {
+			normalizedModel["name"] = "models/" + name
+		}
+		if _, ok := normalizedModel["supportedGenerationMethods"]; !ok {
+			normalizedModel["supportedGenerationMethods"] = defaultMethods
 		}
+		normalizedModels = append(normalizedModels, normalizedModel)
 	}
+	return gin.H{"models": normalizedModels}, nil
 }
 
 func loadUserGroupMembership(c *gin.Context, db *gorm.DB) (models.UserGroupIDs, models.UserGroupIDs, bool) {
 	if c == nil || db == nil {
 		return nil, nil, false
 	}
-	v, exists := c.Get("accessMetadata")
-	if !exists {
-		return nil, nil, false
-	}
-	meta, ok := v.(map[string]string)
-	if !ok {
-		return nil, nil, false
-	}
-	rawUserID := strings.TrimSpace(meta["user_id"])
-	if rawUserID == "" {
-		return nil, nil, false
-	}
-	parsed, errParse := strconv.ParseUint(rawUserID, 10, 64)
-	if errParse != nil || parsed == 0 {
+	parsed, okUser := accessUserID(c)
+	if !okUser {
 		return nil, nil, false
 	}
 	var user models.User
@@ -285,32 +403,6 @@ func filterGeminiRegistryModelsByUserGroups(raw []map[string]any, userGroups, bi
 	return filtered
 }
 
-func filterModelInfosByUserGroups(raw []*sdkcliproxy.ModelInfo, userGroups, billUserGroups models.UserGroupIDs) []*sdkcliproxy.ModelInfo {
-	if len(raw) == 0 {
-		return raw
-	}
-	filtered := make([]*sdkcliproxy.ModelInfo, 0, len(raw))
-	for _, info := range raw {
-		if info == nil {
-			continue
-		}
-		modelID := strings.TrimSpace(info.ID)
-		if modelID == "" {
-			continue
-		}
-		provider := strings.ToLower(strings.TrimSpace(info.OwnedBy))
-		if provider != "" {
-			if allowed, okAllowed := modelmapping.LookupUserGroupIDs(provider, modelID); okAllowed && len(allowed.Clean()) > 0 {
-				if !hasAnyAllowedUserGroup(allowed, userGroups, billUserGroups) {
-					continue
-				}
-			}
-		}
-		filtered = append(filtered, info)
-	}
-	return filtered
-}
-
 // normalizeRequestPath trims trailing slashes for route matching.
 func normalizeRequestPath(path string) string {
 	path = strings.TrimSpace(path)
@@ -320,51 +412,6 @@ func normalizeRequestPath(path string) string {
 	return path
 }
 
-// dbConfigBool reads a boolean from the DB config snapshot.
-func dbConfigBool(key string) bool {
-	raw, ok := internalsettings.DBConfigValue(key)
-	if !ok {
-		return false
-	}
-	return parseDBConfigBool(raw)
-}
-
-// parseDBConfigBool parses a JSON boolean from DB config payloads.
-func parseDBConfigBool(raw json.RawMessage) bool {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return false
-	}
-	var b bool
-	if errUnmarshal := json.Unmarshal(raw, &b); errUnmarshal == nil {
-		return b
-	}
-	var s string
-	if errUnmarshal := json.Unmarshal(raw, &s); errUnmarshal == nil {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return false
-		}
-		if strings.EqualFold(s, "true") || s == "1" {
-			return true
-		}
-		return false
-	}
-	var n float64
-	if errUnmarshal := json.Unmarshal(raw, &n); errUnmarshal == nil {
-		return n != 0
-	}
-	var wrapper struct {
-		Value json.RawMessage `json:"value"`
-	}
-	if errUnmarshal := json.Unmarshal(raw, &wrapper); errUnmarshal == nil {
-		if len(wrapper.Value) > 0 {
-			return parseDBConfigBool(wrapper.Value)
-		}
-	}
-	return false
-}
-
 // convertModelToMap converts a ModelInfo into a response map for the handler type.
 func convertModelToMap(model *sdkcliproxy.ModelInfo, handlerType string) map[string]any {
 	if model == nil {
@@ -466,8 +513,9 @@ func convertModelToMap(model *sdkcliproxy.ModelInfo, handlerType string) map[str
 	}
 }
 
-// listMappedModelInfos returns model infos derived from DB mappings.
-func listMappedModelInfos(ctx context.Context, db *gorm.DB, store *modelregistry.Store) ([]*sdkcliproxy.ModelInfo, error) {
+// listMappedModelInfos returns model infos derived from DB mappings. When visible is set,
+// mappings it rejects are skipped.
+func listMappedModelInfos(ctx context.Context, db *gorm.DB, store *modelregistry.Store, visible func(models.ModelMapping) bool) ([]*sdkcliproxy.ModelInfo, error) {
 	if db == nil {
 		return nil, gorm.ErrInvalidDB
 	}
@@ -478,7 +526,7 @@ func listMappedModelInfos(ctx context.Context, db *gorm.DB, store *modelregistry
 	var rows []models.ModelMapping
 	if errFind := db.WithContext(ctx).
 		Model(&models.ModelMapping{}).
-		Select("provider", "model_name", "new_model_name", "is_enabled", "fork").
+		Select("provider", "model_name", "new_model_name", "is_enabled", "fork", "user_group_id").
 		Where("is_enabled = ?", true).
 		Order("provider ASC, new_model_name ASC, model_name ASC").
 		Find(&rows).Error; errFind != nil {
@@ -494,6 +542,9 @@ func listMappedModelInfos(ctx context.Context, db *gorm.DB, store *modelregistry
 		if newName == "" {
 			continue
 		}
+		if visible != nil && !visible(row) {
+			continue
+		}
 		key := strings.ToLower(newName)
 		if _, ok := seen[key]; ok {
 			continue
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+)
+
+func TestModelsMiddlewareFiltersMappedModelsByUserGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.OnlyMappedModelsKey:         json.RawMessage(`true`),
+		internalsettings.ModelListCacheTTLSecondsKey: json.RawMessage(`60`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	dsn := fmt.Sprintf("file:modelsfilter_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.UserGroup{}, &models.ModelMapping{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	groupA := models.UserGroup{Name: "a"}
+	groupB := models.UserGroup{Name: "b"}
+	if errCreate := db.Create([]*models.UserGroup{&groupA, &groupB}).Error; errCreate != nil {
+		t.Fatalf("create groups: %v", errCreate)
+	}
+	userA := models.User{Username: "a", Email: "a@example.com", UserGroupID: models.UserGroupIDs{&groupA.ID}}
+	userB := models.User{Username: "b", Email: "b@example.com", UserGroupID: models.UserGroupIDs{&groupB.ID}}
+	userNone := models.User{Username: "none", Email: "none@example.com"}
+	for _, user := range []*models.User{&userA, &userB, &userNone} {
+		if errCreate := db.Create(user).Error; errCreate != nil {
+			t.Fatalf("create user %s: %v", user.Username, errCreate)
+		}
+	}
+	// Both groups get an exclusive model under the same alias, from different providers.
+	mappings := []models.ModelMapping{
+		{Provider: "claude", ModelName: "claude-opus", NewModelName: "smart", IsEnabled: true, UserGroupID: models.UserGroupIDs{&groupA.ID}},
+		{Provider: "openai", ModelName: "gpt-5", NewModelName: "smart", IsEnabled: true, UserGroupID: models.UserGroupIDs{&groupB.ID}},
+		{Provider: "openai", ModelName: "gpt-5-mini", NewModelName: "shared", IsEnabled: true, UserGroupID: models.UserGroupIDs{}},
+	}
+	if errCreate := db.Create(&mappings).Error; errCreate != nil {
+		t.Fatalf("create mappings: %v", errCreate)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("accessMetadata", map[string]string{"user_id": c.GetHeader("X-Test-User")})
+	}, CLIProxyModelsMiddleware(db, nil, nil))
+	list := func(userID uint64) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("X-Test-User", fmt.Sprint(userID))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list models for %d: expected 200, got %d", userID, w.Code)
+		}
+		var out []string
+		for _, model := range gjson.GetBytes(w.Body.Bytes(), "data").Array() {
+			out = append(out, model.Get("id").String()+"@"+model.Get("owned_by").String())
+		}
+		return fmt.Sprint(out)
+	}
+
+	// Listing twice also exercises the cache, which is keyed by user group.
+	for i := 0; i < 2; i++ {
+		if got := list(userA.ID); got != "[shared@openai smart@claude]" {
+			t.Fatalf("group a: unexpected models %s", got)
+		}
+		if got := list(userB.ID); got != "[shared@openai smart@openai]" {
+			t.Fatalf("group b: unexpected models %s", got)
+		}
+		if got := list(userNone.ID); got != "[shared@openai]" {
+			t.Fatalf("no group: unexpected models %s", got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// geminiModelsPathPrefix starts Gemini routes that carry the model in the path.
+	geminiModelsPathPrefix = "/v1beta/models/"
+	// geminiCLIPathPrefix starts Gemini CLI routes, which carry the model in the body.
+	geminiCLIPathPrefix = "/v1internal:"
+)
+
+// CLIProxyPlanModelsMiddleware rejects requests for models outside the support_models
+// list of the caller's plan, see billing.LoadPlanModels. It runs after model prefix
+// stripping and X-Model-Override, so the model the client asked for is the one checked.
+// Every route that serves a model is covered: the JSON endpoints and the Gemini CLI
+// endpoint by their body model, the Gemini endpoints by the model in their path.
+func CLIProxyPlanModelsMiddleware(cache *billing.PlanModelCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cache == nil || c == nil || c.Request == nil || c.Request.URL == nil {
+			if c != nil {
+				c.Next()
+			}
+			return
+		}
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		path := normalizeRequestPath(c.Request.URL.Path)
+		_, jsonEndpoint := fallbackEligiblePaths[path]
+		jsonEndpoint = jsonEndpoint || strings.HasPrefix(path, geminiCLIPathPrefix)
+		if !jsonEndpoint && !strings.HasPrefix(path, geminiModelsPathPrefix) {
+			c.Next()
+			return
+		}
+		userID, okUser := accessUserID(c)
+		if !okUser {
+			c.Next()
+			return
+		}
+		plan, errPlan := cache.Get(c.Request.Context(), userID)
+		if errPlan != nil {
+			logging.FromContext(c.Request.Context()).WithError(errPlan).Warn("plan models: resolve plan failed")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "resolve plan models failed"})
+			return
+		}
+		if !plan.Restricted {
+			c.Next()
+			return
+		}
+
+		var model string
+		if jsonEndpoint {
+			if c.Request.Body == nil {
+				c.Next()
+				return
+			}
+			body, errRead := io.ReadAll(c.Request.Body)
+			if errRead != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read request body failed"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			model = strings.TrimSpace(gjson.GetBytes(body, "model").String())
+		} else {
+			model = geminiPathModel(path)
+		}
+		if model == "" || plan.Allows(model) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": fmt.Sprintf("model %s is not included in plan %s", model, plan.PlanNames()),
+		})
+	}
+}
+
+// geminiPathModel returns the model of a /v1beta/models/{model}:{method} path.
+func geminiPathModel(path string) string {
+	model := strings.TrimPrefix(path, geminiModelsPathPrefix)
+	if idx := strings.LastIndex(model, ":"); idx >= 0 {
+		model = model[:idx]
+	}
+	return strings.TrimSpace(model)
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/tidwall/gjson"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestPlanModelsMiddlewareEnforcesPlanAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.OnlyMappedModelsKey: json.RawMessage(`true`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	dsn := fmt.Sprintf("file:planmodels_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.User{}, &models.Plan{}, &models.Bill{}, &models.ModelMapping{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	basic := models.Plan{Name: "basic", SupportModels: datatypes.JSON(`[{"provider":"openai","name":"gpt-5-mini"},{"provider":"gemini","name":"gemini-flash"}]`)}
+	if errCreate := db.Create(&basic).Error; errCreate != nil {
+		t.Fatalf("create plan: %v", errCreate)
+	}
+	limited := models.User{Username: "limited", Email: "limited@example.com"}
+	prepaid := models.User{Username: "prepaid", Email: "prepaid@example.com"}
+	for _, user := range []*models.User{&limited, &prepaid} {
+		if errCreate := db.Create(user).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+	}
+	now := time.Now().UTC()
+	bill := models.Bill{PlanID: basic.ID, UserID: limited.ID, PeriodStart: now.Add(-time.Hour), PeriodEnd: now.Add(time.Hour), LeftQuota: 5, IsEnabled: true, Status: models.BillStatusPaid}
+	if errCreate := db.Create(&bill).Error; errCreate != nil {
+		t.Fatalf("create bill: %v", errCreate)
+	}
+	mappings := []models.ModelMapping{
+		{Provider: "openai", ModelName: "gpt-5-mini", NewModelName: "gpt-5-mini", IsEnabled: true},
+		{Provider: "claude", ModelName: "claude-opus", NewModelName: "claude-opus", IsEnabled: true},
+	}
+	if errCreate := db.Create(&mappings).Error; errCreate != nil {
+		t.Fatalf("create mappings: %v", errCreate)
+	}
+
+	cache := billing.NewPlanModelCache(db, nil)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("accessMetadata", map[string]string{"user_id": c.GetHeader("X-Test-User")})
+	}, CLIProxyPlanModelsMiddleware(cache), CLIProxyModelsMiddleware(db, nil, cache))
+	r.POST("/v1/chat/completions", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/v1beta/models/*action", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/v1internal:method", func(c *gin.Context) { c.Status(http.StatusOK) })
+	serve := func(userID uint64, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Test-User", fmt.Sprint(userID))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(limited.ID, http.MethodPost, "/v1/chat/completions", `{"model":"gpt-5-mini"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected a plan model to pass, got %d", w.Code)
+	}
+	w := serve(limited.ID, http.MethodPost, "/v1/chat/completions", `{"model":"claude-opus"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(gjson.GetBytes(w.Body.Bytes(), "error").String(), "plan basic") {
+		t.Fatalf("expected 403 naming the plan, got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(limited.ID, http.MethodPost, "/v1beta/models/gemini-pro:generateContent", `{}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the Gemini route model to be checked, got %d", w.Code)
+	}
+	if w := serve(limited.ID, http.MethodPost, "/v1beta/models/gemini-flash:streamGenerateContent", `{}`); w.Code != http.StatusOK {
+		t.Fatalf("expected a plan Gemini model to pass, got %d", w.Code)
+	}
+	if w := serve(limited.ID, http.MethodPost, "/v1internal:generateContent", `{"model":"gemini-pro"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the Gemini CLI body model to be checked, got %d", w.Code)
+	}
+	if w := serve(limited.ID, http.MethodPost, "/v1internal:streamGenerateContent", `{"model":"gemini-flash"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected a plan Gemini CLI model to pass, got %d", w.Code)
+	}
+	if w := serve(prepaid.ID, http.MethodPost, "/v1/chat/completions", `{"model":"claude-opus"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected a user without bills to be unrestricted, got %d", w.Code)
+	}
+
+	list := func(userID uint64) string {
+		t.Helper()
+		w := serve(userID, http.MethodGet, "/v1/models", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("list models: expected 200, got %d", w.Code)
+		}
+		var ids []string
+		for _, model := range gjson.GetBytes(w.Body.Bytes(), "data").Array() {
+			ids = append(ids, model.Get("id").String())
+		}
+		return fmt.Sprint(ids)
+	}
+	if got := list(limited.ID); got != "[gpt-5-mini]" {
+		t.Fatalf("expected the plan to filter the model list, got %s", got)
+	}
+	if got := list(prepaid.ID); got != "[claude-opus gpt-5-mini]" {
+		t.Fatalf("expected an unfiltered model list, got %s", got)
+	}
+
+	// A cooldown fallback must not route the plan user to a model outside the plan.
+	fallbackFilter := func(userID uint64) func(string) bool {
+		t.Helper()
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		c.Set("accessMetadata", map[string]string{"user_id": fmt.Sprint(userID)})
+		allowed, ok := fallbackPlanFilter(c, cache)
+		if !ok {
+			t.Fatalf("expected the plan to resolve")
+		}
+		return allowed
+	}
+	if allowed := fallbackFilter(limited.ID); allowed == nil || allowed("claude-opus") || !allowed("gpt-5-mini") {
+		t.Fatalf("expected fallback targets to be limited to the plan models")
+	}
+	if allowed := fallbackFilter(prepaid.ID); allowed != nil {
+		t.Fatalf("expected no fallback filter for a user without bills")
+	}
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	internalauth "github.com/router-for-me/CLIProxyAPIBusiness/internal/auth"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// authPrefixCacheTTL controls how often the known auth prefixes are reloaded.
+const authPrefixCacheTTL = 10 * time.Second
+
+// prefixedAPIRoots are the API roots that may follow a routing prefix.
+var prefixedAPIRoots = []string{"/v1/", "/v1beta/"}
+
+// authPrefixCache remembers the prefixes configured on available auths.
+type authPrefixCache struct {
+	db *gorm.DB
+
+	mu       sync.Mutex
+	prefixes map[string]struct{}
+	loadedAt time.Time
+}
+
+// has reports whether prefix belongs to at least one available auth.
+func (c *authPrefixCache) has(ctx context.Context, prefix string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prefixes == nil || time.Since(c.loadedAt) >= authPrefixCacheTTL {
+		prefixes, errLoad := loadAuthPrefixes(ctx, c.db)
+		if errLoad != nil {
+			logging.FromContext(ctx).WithError(errLoad).Warn("prefix routing: load auth prefixes failed")
+		} else {
+			c.prefixes = prefixes
+		}
+		// Failed loads keep the previous snapshot and retry after the TTL.
+		c.loadedAt = time.Now()
+	}
+	_, ok := c.prefixes[prefix]
+	return ok
+}
+
+// loadAuthPrefixes reads the distinct prefixes stored in available auth payloads.
+func loadAuthPrefixes(ctx context.Context, db *gorm.DB) (map[string]struct{}, error) {
+	var values []string
+	expr := dbutil.JSONExtractTextExpr(db, "content", "prefix")
+	if errFind := db.WithContext(ctx).Model(&models.Auth{}).
+		Where("is_available = ?", true).
+		Where(expr+" IS NOT NULL AND "+expr+" <> ''").
+		Distinct().
+		Pluck(expr, &values).Error; errFind != nil {
+		return nil, errFind
+	}
+	prefixes := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		if prefix := internalauth.NormalizePrefix(value); prefix != "" {
+			prefixes[prefix] = struct{}{}
+		}
+	}
+	return prefixes, nil
+}
+
+// CLIProxyPrefixMiddleware routes /<prefix>/v1/... requests to the auths whose metadata
+// prefix matches. The prefix is stripped, recorded on the request context for the
+// selector, and the request is dispatched again on the engine returned by engine.
+// Requests without a known prefix are left untouched and may use every auth.
+// It must be the first middleware so the re-dispatched request runs the full chain once.
+func CLIProxyPrefixMiddleware(db *gorm.DB, engine func() *gin.Engine) gin.HandlerFunc {
+	cache := &authPrefixCache{db: db}
+	return func(c *gin.Context) {
+		if db == nil || engine == nil || c == nil || c.Request == nil || c.Request.URL == nil {
+			if c != nil {
+				c.Next()
+			}
+			return
+		}
+		prefix, rest, ok := splitRoutingPrefix(c.Request.URL.Path)
+		if !ok || !cache.has(c.Request.Context(), prefix) {
+			c.Next()
+			return
+		}
+		target := engine()
+		if target == nil {
+			c.Next()
+			return
+		}
+
+		c.Request = c.Request.WithContext(internalauth.WithRequestPrefix(c.Request.Context(), prefix))
+		c.Request.URL.Path = rest
+		c.Request.URL.RawPath = ""
+		target.HandleContext(c)
+		c.Abort()
+	}
+}
+
+// splitRoutingPrefix splits "/<prefix>/v1/..." into the prefix and the API path.
+func splitRoutingPrefix(requestPath string) (string, string, bool) {
+	trimmed := strings.TrimPrefix(requestPath, "/")
+	slash := strings.Index(trimmed, "/")
+	if slash <= 0 {
+		return "", "", false
+	}
+	prefix := trimmed[:slash]
+	rest := trimmed[slash:]
+	switch prefix {
+	case "v0", "v1", "v1beta", "assets":
+		return "", "", false
+	}
+	for _, root := range prefixedAPIRoots {
+		if strings.HasPrefix(rest, root) {
+			return prefix, rest, true
+		}
+	}
+	return "", "", false
+}
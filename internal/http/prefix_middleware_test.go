@@ -0,0 +1,82 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	internalauth "github.com/router-for-me/CLIProxyAPIBusiness/internal/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestPrefixMiddlewareRoutesKnownPrefixes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := fmt.Sprintf("file:prefix_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	if errCreate := db.Create(&models.Auth{Key: "a.json", Content: datatypes.JSON(`{"type":"codex","prefix":"teamA"}`), IsAvailable: true}).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+
+	r := gin.New()
+	calls := 0
+	r.Use(CLIProxyPrefixMiddleware(db, func() *gin.Engine { return r }), func(c *gin.Context) {
+		calls++
+		c.Next()
+	})
+	r.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.String(http.StatusOK, "prefix=%s", internalauth.RequestPrefixFromContext(c.Request.Context()))
+	})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/teamA/v1/chat/completions", http.StatusOK, "prefix=teamA"},
+		{"/v1/chat/completions", http.StatusOK, "prefix="},
+		{"/teamB/v1/chat/completions", http.StatusNotFound, ""},
+	}
+	for _, tc := range cases {
+		calls = 0
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tc.path, nil))
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d", tc.path, tc.code, w.Code)
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Fatalf("%s: expected %q, got %q", tc.path, tc.body, w.Body.String())
+		}
+		if tc.code == http.StatusOK && calls != 1 {
+			t.Fatalf("%s: expected the chain to run once, ran %d times", tc.path, calls)
+		}
+	}
+}
+
+func TestSplitRoutingPrefix(t *testing.T) {
+	cases := map[string]string{
+		"/teamA/v1/models":         "teamA",
+		"/teamA/v1beta/models/x":   "teamA",
+		"/v1/chat/completions":     "",
+		"/v0/admin/login":          "",
+		"/teamA/admin/v1/anything": "",
+		"/teamA":                   "",
+	}
+	for path, want := range cases {
+		prefix, _, ok := splitRoutingPrefix(path)
+		if ok != (want != "") || prefix != want {
+			t.Fatalf("%s: expected %q, got %q (ok=%v)", path, want, prefix, ok)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/access"
+)
+
+// Response headers reporting the caller's remaining bill quota.
+const (
+	// headerQuotaRemaining reports the remaining quota across active bills.
+	headerQuotaRemaining = "X-Quota-Remaining"
+	// headerQuotaDailyRemaining reports today's remaining quota for daily-limited bills.
+	headerQuotaDailyRemaining = "X-Quota-Daily-Remaining"
+)
+
+// CLIProxyQuotaHeadersMiddleware emits remaining bill quota headers for authenticated
+// user requests. The values are computed during the access balance check and carried in
+// the access metadata, so no extra queries are made here.
+func CLIProxyQuotaHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c == nil {
+			return
+		}
+		v, exists := c.Get("accessMetadata")
+		if !exists {
+			c.Next()
+			return
+		}
+		meta, ok := v.(map[string]string)
+		if !ok || strings.TrimSpace(meta["user_id"]) == "" {
+			c.Next()
+			return
+		}
+		if remaining := strings.TrimSpace(meta[access.MetadataQuotaRemaining]); remaining != "" {
+			c.Header(headerQuotaRemaining, remaining)
+		}
+		if remaining := strings.TrimSpace(meta[access.MetadataQuotaDailyRemaining]); remaining != "" {
+			c.Header(headerQuotaDailyRemaining, remaining)
+		}
+		c.Next()
+	}
+}
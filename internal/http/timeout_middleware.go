@@ -0,0 +1,53 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
+	"github.com/tidwall/gjson"
+)
+
+// CLIProxyMappingTimeoutMiddleware bounds a request by the timeout of the model mapping it
+// targets, so upstream calls for that model are cancelled once it elapses. Mappings
+// without a timeout leave the request untouched.
+func CLIProxyMappingTimeoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c == nil || c.Request == nil || c.Request.URL == nil || c.Request.Body == nil {
+			if c != nil {
+				c.Next()
+			}
+			return
+		}
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		// The fallback endpoints are the JSON endpoints that carry the model in the body.
+		if _, ok := fallbackEligiblePaths[normalizeRequestPath(c.Request.URL.Path)]; !ok {
+			c.Next()
+			return
+		}
+
+		body, errRead := io.ReadAll(c.Request.Body)
+		if errRead != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read request body failed"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		timeout, ok := modelmapping.LookupTimeout(strings.TrimSpace(gjson.GetBytes(body, "model").String()))
+		if !ok {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestMappingTimeoutMiddlewareSetsDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modelmapping.StoreModelMappings(time.Now(), []models.ModelMapping{
+		{ID: 1, Provider: "claude", ModelName: "claude-long", NewModelName: "long", TimeoutSeconds: 600, IsEnabled: true},
+		{ID: 2, Provider: "openai", ModelName: "gpt-mini", NewModelName: "cheap", IsEnabled: true},
+	})
+	t.Cleanup(func() { modelmapping.StoreModelMappings(time.Now(), nil) })
+
+	var remaining time.Duration
+	var hasDeadline bool
+	r := gin.New()
+	r.Use(CLIProxyMappingTimeoutMiddleware())
+	r.POST("/v1/chat/completions", func(c *gin.Context) {
+		deadline, ok := c.Request.Context().Deadline()
+		hasDeadline = ok
+		remaining = time.Until(deadline)
+		c.Status(http.StatusOK)
+	})
+	send := func(model string) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"`+model+`"}`))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("LONG")
+	if !hasDeadline || remaining <= 590*time.Second || remaining > 600*time.Second {
+		t.Fatalf("expected a 600s deadline, got %v (set=%v)", remaining, hasDeadline)
+	}
+	send("cheap")
+	if hasDeadline {
+		t.Fatalf("expected a zero timeout to inherit the default")
+	}
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/http/apierror"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// Async turns a slow admin endpoint into one that answers 202 Accepted with a queued job
+// of jobType. params validates the request and returns the job input; it writes its own
+// error response and returns false to reject the request. The job's URL is returned in
+// the Location header for polling.
+func Async(db *gorm.DB, jobType string, params func(c *gin.Context) (any, bool)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input, ok := params(c)
+		if !ok {
+			return
+		}
+		job, errEnqueue := Enqueue(c.Request.Context(), db, jobType, input, c.GetUint64("adminID"))
+		if errEnqueue != nil {
+			apierror.Write(c, apierror.Internal("queue job failed"))
+			return
+		}
+		c.Header("Location", "/v0/admin/jobs/"+strconv.FormatUint(job.ID, 10))
+		c.JSON(http.StatusAccepted, View(job))
+	}
+}
+
+// View renders a job for API responses. File contents are left out; they are served by
+// the job download endpoint.
+func View(job models.Job) gin.H {
+	return gin.H{
+		"id":          job.ID,
+		"type":        job.Type,
+		"status":      job.Status,
+		"progress":    job.Progress,
+		"params":      job.Params,
+		"result":      job.Result,
+		"error":       job.Error,
+		"file_name":   job.FileName,
+		"created_by":  job.CreatedBy,
+		"started_at":  job.StartedAt,
+		"finished_at": job.FinishedAt,
+		"created_at":  job.CreatedAt,
+		"updated_at":  job.UpdatedAt,
+	}
+}
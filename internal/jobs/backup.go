@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/backup"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// TypeBackup writes a database backup to the backup directory and prunes the backups
+// beyond BACKUP_RETENTION_COUNT.
+const TypeBackup = "backup.create"
+
+func init() {
+	Register(TypeBackup, runBackup)
+}
+
+// runBackup backs up the database to the directory named by BACKUP_DIR.
+func runBackup(ctx context.Context, run *Run) (any, error) {
+	dir := config.LoadBackupDir()
+	file, errCreate := backup.Create(ctx, run.DB(), dir, time.Now())
+	if errCreate != nil {
+		return nil, errCreate
+	}
+	pruned, errPrune := backup.Prune(dir, internalsettings.GetInt(internalsettings.BackupRetentionCountKey))
+	if errPrune != nil {
+		log.WithError(errPrune).Warn("jobs: prune backups failed")
+	}
+	return map[string]any{"backup": file, "pruned": pruned}, nil
+}
+
+// ScheduleBackup queues a backup job when the newest backup in dir is at least interval
+// older than now and no backup job is already queued or running. A non-positive interval
+// disables scheduled backups. It reports whether a job was queued.
+func ScheduleBackup(ctx context.Context, db *gorm.DB, dir string, interval time.Duration, now time.Time) (bool, error) {
+	if db == nil || interval <= 0 {
+		return false, nil
+	}
+	files, errList := backup.List(dir)
+	if errList != nil {
+		return false, errList
+	}
+	if len(files) > 0 && now.Sub(files[0].CreatedAt) < interval {
+		return false, nil
+	}
+	var pending int64
+	if errCount := db.WithContext(ctx).Model(&models.Job{}).
+		Where("type = ? AND status IN ?", TypeBackup, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&pending).Error; errCount != nil {
+		return false, errCount
+	}
+	if pending > 0 {
+		return false, nil
+	}
+	if _, errEnqueue := Enqueue(ctx, db, TypeBackup, nil, 0); errEnqueue != nil {
+		return false, errEnqueue
+	}
+	return true, nil
+}
@@ -0,0 +1,154 @@
+// Package jobs runs long admin tasks in the background. Job types are registered once at
+// startup, queued as rows in the jobs table, and executed by a Runner, so callers can
+// answer with 202 and poll the job instead of holding a request open.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrUnknownType is returned when queueing a job type that was never registered.
+var ErrUnknownType = errors.New("jobs: unknown job type")
+
+// Func executes one job and returns a JSON-encodable result. It should stop early when
+// ctx is canceled.
+type Func func(ctx context.Context, run *Run) (any, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Func)
+)
+
+// Register makes fn the implementation of jobType. Registering a type twice panics.
+func Register(jobType string, fn Func) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if fn == nil {
+		panic("jobs: Register fn is nil")
+	}
+	if _, dup := registry[jobType]; dup {
+		panic("jobs: Register called twice for " + jobType)
+	}
+	registry[jobType] = fn
+}
+
+// Types lists the registered job types in name order.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for jobType := range registry {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// lookup returns the implementation of jobType.
+func lookup(jobType string) (Func, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	fn, ok := registry[jobType]
+	return fn, ok
+}
+
+// Enqueue stores a queued job of jobType with params for the next free worker.
+// createdBy is the admin that asked for it, or 0.
+func Enqueue(ctx context.Context, db *gorm.DB, jobType string, params any, createdBy uint64) (models.Job, error) {
+	if _, ok := lookup(jobType); !ok {
+		return models.Job{}, fmt.Errorf("%w: %s", ErrUnknownType, jobType)
+	}
+	raw, errMarshal := json.Marshal(params)
+	if errMarshal != nil {
+		return models.Job{}, fmt.Errorf("jobs: encode params: %w", errMarshal)
+	}
+	job := models.Job{
+		Type:   jobType,
+		Status: models.JobStatusQueued,
+		Params: datatypes.JSON(raw),
+	}
+	if createdBy != 0 {
+		job.CreatedBy = &createdBy
+	}
+	if errCreate := db.WithContext(ctx).Create(&job).Error; errCreate != nil {
+		return models.Job{}, fmt.Errorf("jobs: create job: %w", errCreate)
+	}
+	return job, nil
+}
+
+// Run is the handle a Func uses to read its input and report progress.
+type Run struct {
+	db  *gorm.DB
+	job models.Job
+
+	mu       sync.Mutex
+	progress int
+	file     *file
+}
+
+// file is a download produced by a job.
+type file struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// ID returns the job ID.
+func (r *Run) ID() uint64 {
+	return r.job.ID
+}
+
+// DB returns the connection the job was queued on.
+func (r *Run) DB() *gorm.DB {
+	return r.db
+}
+
+// Params decodes the job params into v.
+func (r *Run) Params(v any) error {
+	if len(r.job.Params) == 0 {
+		return nil
+	}
+	if errUnmarshal := json.Unmarshal(r.job.Params, v); errUnmarshal != nil {
+		return fmt.Errorf("jobs: decode params: %w", errUnmarshal)
+	}
+	return nil
+}
+
+// SetProgress records that done of total steps are complete. The job row is only
+// written when the whole percentage changes.
+func (r *Run) SetProgress(ctx context.Context, done, total int) {
+	if total <= 0 {
+		return
+	}
+	percent := done * 100 / total
+	if percent > 99 {
+		// 100 is reserved for finished jobs.
+		percent = 99
+	}
+	r.mu.Lock()
+	if percent <= r.progress {
+		r.mu.Unlock()
+		return
+	}
+	r.progress = percent
+	r.mu.Unlock()
+	r.db.WithContext(ctx).Model(&models.Job{}).Where("id = ?", r.job.ID).
+		Updates(map[string]any{"progress": percent, "updated_at": time.Now().UTC()})
+}
+
+// SetFile attaches a file to the job that can be downloaded once it succeeds.
+func (r *Run) SetFile(name, contentType string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file = &file{name: name, contentType: contentType, data: data}
+}
@@ -0,0 +1,173 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/backup"
+	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestRunnerExecutesRegisteredJobs(t *testing.T) {
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	Register("test.sum", func(ctx context.Context, run *Run) (any, error) {
+		var params struct {
+			Values []int `json:"values"`
+		}
+		if errParams := run.Params(&params); errParams != nil {
+			return nil, errParams
+		}
+		sum := 0
+		for i, v := range params.Values {
+			sum += v
+			run.SetProgress(ctx, i+1, len(params.Values))
+		}
+		run.SetFile("sum.txt", "text/plain", []byte("done"))
+		return map[string]int{"sum": sum}, nil
+	})
+	Register("test.fail", func(ctx context.Context, run *Run) (any, error) {
+		return nil, errors.New("boom")
+	})
+	Register("test.panic", func(ctx context.Context, run *Run) (any, error) {
+		panic("kaboom")
+	})
+
+	ctx := context.Background()
+	if _, errEnqueue := Enqueue(ctx, db, "test.missing", nil, 0); !errors.Is(errEnqueue, ErrUnknownType) {
+		t.Fatalf("expected unknown types to be rejected, got %v", errEnqueue)
+	}
+	sum, errEnqueue := Enqueue(ctx, db, "test.sum", map[string]any{"values": []int{1, 2, 3}}, 7)
+	if errEnqueue != nil {
+		t.Fatalf("enqueue: %v", errEnqueue)
+	}
+	failed, _ := Enqueue(ctx, db, "test.fail", nil, 0)
+	panicked, _ := Enqueue(ctx, db, "test.panic", nil, 0)
+
+	runner := NewRunner(db)
+	for i := 0; i < 3; i++ {
+		if ran, errRun := runner.RunOnce(ctx); errRun != nil || !ran {
+			t.Fatalf("run %d: ran=%t err=%v", i, ran, errRun)
+		}
+	}
+	if ran, _ := runner.RunOnce(ctx); ran {
+		t.Fatal("expected the queue to be empty")
+	}
+
+	var got models.Job
+	db.First(&got, sum.ID)
+	var result map[string]int
+	_ = json.Unmarshal(got.Result, &result)
+	if got.Status != models.JobStatusSucceeded || got.Progress != 100 || result["sum"] != 6 {
+		t.Fatalf("unexpected succeeded job %+v", got)
+	}
+	if got.FileName != "sum.txt" || string(got.FileData) != "done" || got.CreatedBy == nil || *got.CreatedBy != 7 {
+		t.Fatalf("expected the file and creator to be stored, got %+v", got)
+	}
+	if got.StartedAt == nil || got.FinishedAt == nil {
+		t.Fatalf("expected start and finish times, got %+v", got)
+	}
+	got = models.Job{}
+	db.First(&got, failed.ID)
+	if got.Status != models.JobStatusFailed || got.Error != "boom" {
+		t.Fatalf("unexpected failed job %+v", got)
+	}
+	got = models.Job{}
+	db.First(&got, panicked.ID)
+	if got.Status != models.JobStatusFailed || got.Error != "job panicked: kaboom" {
+		t.Fatalf("expected a panic to fail the job, got %+v", got)
+	}
+
+	now := time.Now().UTC()
+	stale := models.Job{Type: "test.sum", Status: models.JobStatusRunning}
+	db.Create(&stale)
+	db.Model(&stale).UpdateColumn("updated_at", now.Add(-time.Hour))
+	if n, errStale := FailStaleJobs(ctx, db, now); errStale != nil || n != 1 {
+		t.Fatalf("expected one stale job, got %d %v", n, errStale)
+	}
+
+	db.Model(&models.Job{}).Where("id = ?", sum.ID).Update("finished_at", now.AddDate(0, 0, -10))
+	if n, errPrune := PruneJobs(ctx, db, 7, now); errPrune != nil || n != 1 {
+		t.Fatalf("expected one job past retention, got %d %v", n, errPrune)
+	}
+	if n, _ := PruneJobs(ctx, db, 0, now); n != 0 {
+		t.Fatal("expected retention 0 to keep jobs")
+	}
+}
+
+func TestPurgeTrashedAuths(t *testing.T) {
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, key := range []string{"old.json", "recent.json", "live.json"} {
+		if errCreate := db.Create(&models.Auth{Key: key, Content: []byte(`{}`)}).Error; errCreate != nil {
+			t.Fatalf("create auth: %v", errCreate)
+		}
+	}
+	db.Model(&models.Auth{}).Where("key = ?", "old.json").Update("deleted_at", now.AddDate(0, 0, -31))
+	db.Model(&models.Auth{}).Where("key = ?", "recent.json").Update("deleted_at", now.AddDate(0, 0, -1))
+
+	if n, _ := PurgeTrashedAuths(ctx, db, 0, now); n != 0 {
+		t.Fatal("expected retention 0 to keep the trash")
+	}
+	if n, errPurge := PurgeTrashedAuths(ctx, db, 30, now); errPurge != nil || n != 1 {
+		t.Fatalf("expected one auth past retention, got %d %v", n, errPurge)
+	}
+	var keys []string
+	db.Unscoped().Model(&models.Auth{}).Order("key").Pluck("key", &keys)
+	if strings.Join(keys, ",") != "live.json,recent.json" {
+		t.Fatalf("unexpected remaining auths %v", keys)
+	}
+}
+
+func TestScheduleBackup(t *testing.T) {
+	db, errOpen := dbutil.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := dbutil.Migrate(db); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	if queued, _ := ScheduleBackup(ctx, db, dir, 0, now); queued {
+		t.Fatal("expected interval 0 to disable scheduled backups")
+	}
+	if queued, errSchedule := ScheduleBackup(ctx, db, dir, 24*time.Hour, now); errSchedule != nil || !queued {
+		t.Fatalf("expected a first backup to be queued, got %v %v", queued, errSchedule)
+	}
+	if queued, _ := ScheduleBackup(ctx, db, dir, 24*time.Hour, now); queued {
+		t.Fatal("expected a queued backup job to block another")
+	}
+	db.Where("type = ?", TypeBackup).Delete(&models.Job{})
+
+	if errWrite := os.WriteFile(filepath.Join(dir, backup.Name(now.Add(-time.Hour))), []byte("x"), 0o600); errWrite != nil {
+		t.Fatalf("write backup: %v", errWrite)
+	}
+	if queued, _ := ScheduleBackup(ctx, db, dir, 24*time.Hour, now); queued {
+		t.Fatal("expected a recent backup to postpone the next one")
+	}
+	if queued, _ := ScheduleBackup(ctx, db, dir, time.Hour, now); !queued {
+		t.Fatal("expected a backup once the interval passed")
+	}
+}
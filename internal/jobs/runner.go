@@ -0,0 +1,286 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultWorkers bounds how many jobs one process runs at a time.
+	defaultWorkers = 2
+	// defaultPollInterval is how often the runner looks for queued jobs.
+	defaultPollInterval = time.Second
+	// maintenanceInterval is how often stale jobs are failed and old jobs deleted.
+	maintenanceInterval = time.Minute
+	// heartbeatInterval is how often a running job's updated_at is refreshed.
+	heartbeatInterval = 30 * time.Second
+	// staleJobTimeout fails running jobs whose worker stopped sending heartbeats, e.g.
+	// because the process that claimed them exited.
+	staleJobTimeout = 5 * time.Minute
+)
+
+// Runner claims queued jobs and executes them with a bounded number of workers. Jobs are
+// claimed with a conditional update, so several processes may share one jobs table.
+type Runner struct {
+	db           *gorm.DB
+	workers      int
+	pollInterval time.Duration
+	backupDir    string
+	now          func() time.Time
+}
+
+// NewRunner constructs a jobs runner.
+func NewRunner(db *gorm.DB) *Runner {
+	if db == nil {
+		return nil
+	}
+	return &Runner{
+		db:           db,
+		workers:      defaultWorkers,
+		pollInterval: defaultPollInterval,
+		backupDir:    config.LoadBackupDir(),
+		now:          time.Now,
+	}
+}
+
+// Start runs the runner loop in the background.
+func (r *Runner) Start(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go r.run(ctx)
+	log.Infof("jobs runner started (workers=%d)", r.workers)
+}
+
+// run claims and executes jobs until ctx is canceled.
+func (r *Runner) run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	slots := make(chan struct{}, r.workers)
+	var lastMaintenance time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if now := r.now(); now.Sub(lastMaintenance) >= maintenanceInterval {
+			lastMaintenance = now
+			r.maintain(ctx)
+		}
+		for len(slots) < cap(slots) {
+			job, ok, errClaim := r.claim(ctx)
+			if errClaim != nil {
+				if !errors.Is(errClaim, context.Canceled) {
+					log.WithError(errClaim).Warn("jobs: claim failed")
+				}
+				break
+			}
+			if !ok {
+				break
+			}
+			slots <- struct{}{}
+			go func() {
+				defer func() { <-slots }()
+				r.execute(ctx, job)
+			}()
+		}
+	}
+}
+
+// RunOnce claims one queued job and executes it before returning. It reports whether
+// a job was run.
+func (r *Runner) RunOnce(ctx context.Context) (bool, error) {
+	job, ok, errClaim := r.claim(ctx)
+	if errClaim != nil || !ok {
+		return false, errClaim
+	}
+	r.execute(ctx, job)
+	return true, nil
+}
+
+// claim marks the oldest queued job as running and returns it.
+func (r *Runner) claim(ctx context.Context) (models.Job, bool, error) {
+	for {
+		var job models.Job
+		res := r.db.WithContext(ctx).
+			Where("status = ?", models.JobStatusQueued).
+			Order("id ASC").
+			Limit(1).
+			Find(&job)
+		if res.Error != nil {
+			return models.Job{}, false, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.Job{}, false, nil
+		}
+		now := r.now().UTC()
+		claimed := r.db.WithContext(ctx).Model(&models.Job{}).
+			Where("id = ? AND status = ?", job.ID, models.JobStatusQueued).
+			Updates(map[string]any{"status": models.JobStatusRunning, "started_at": now, "updated_at": now})
+		if claimed.Error != nil {
+			return models.Job{}, false, claimed.Error
+		}
+		if claimed.RowsAffected == 1 {
+			job.Status = models.JobStatusRunning
+			job.StartedAt = &now
+			return job, true, nil
+		}
+		// Another worker claimed it first; try the next one.
+	}
+}
+
+// execute runs a claimed job and stores its outcome.
+func (r *Runner) execute(ctx context.Context, job models.Job) {
+	run := &Run{db: r.db, job: job}
+	stopHeartbeat := r.heartbeat(ctx, job.ID)
+	result, errRun := r.call(ctx, run)
+	stopHeartbeat()
+
+	// The outcome is stored even when ctx was canceled mid-run.
+	storeCtx := context.WithoutCancel(ctx)
+	now := r.now().UTC()
+	updates := map[string]any{"finished_at": now, "updated_at": now}
+	if errRun == nil {
+		raw, errMarshal := json.Marshal(result)
+		if errMarshal != nil {
+			errRun = fmt.Errorf("encode result: %w", errMarshal)
+		} else {
+			updates["status"] = models.JobStatusSucceeded
+			updates["progress"] = 100
+			updates["result"] = datatypes.JSON(raw)
+			if run.file != nil {
+				updates["file_name"] = run.file.name
+				updates["file_content_type"] = run.file.contentType
+				updates["file_data"] = run.file.data
+			}
+		}
+	}
+	if errRun != nil {
+		updates["status"] = models.JobStatusFailed
+		updates["error"] = errRun.Error()
+		log.WithError(errRun).Warnf("jobs: %s job %d failed", job.Type, job.ID)
+	}
+	if errStore := r.db.WithContext(storeCtx).Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates).Error; errStore != nil {
+		log.WithError(errStore).Warnf("jobs: store outcome of job %d failed", job.ID)
+	}
+}
+
+// call runs the job's Func, turning a panic into an error.
+func (r *Runner) call(ctx context.Context, run *Run) (result any, errRun error) {
+	fn, ok := lookup(run.job.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, run.job.Type)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			errRun = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return fn(ctx, run)
+}
+
+// heartbeat refreshes the job's updated_at until the returned func is called, so other
+// runners do not mistake a long job for one whose worker died.
+func (r *Runner) heartbeat(ctx context.Context, jobID uint64) func() {
+	hbCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hbCtx.Done():
+				return
+			case <-ticker.C:
+				r.db.WithContext(hbCtx).Model(&models.Job{}).Where("id = ?", jobID).
+					Update("updated_at", r.now().UTC())
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// maintain fails stale running jobs, deletes jobs past JOB_RETENTION_DAYS, purges auth
+// files that sat in the trash longer than AUTH_TRASH_RETENTION_DAYS and queues a backup
+// once BACKUP_INTERVAL_HOURS have passed since the last one.
+func (r *Runner) maintain(ctx context.Context) {
+	now := r.now().UTC()
+	stale, errStale := FailStaleJobs(ctx, r.db, now)
+	if errStale != nil {
+		log.WithError(errStale).Warn("jobs: fail stale jobs failed")
+	} else if stale > 0 {
+		log.Warnf("jobs: failed %d job(s) whose worker stopped", stale)
+	}
+	retentionDays := internalsettings.GetInt(internalsettings.JobRetentionDaysKey)
+	deleted, errPrune := PruneJobs(ctx, r.db, retentionDays, now)
+	if errPrune != nil {
+		log.WithError(errPrune).Warn("jobs: prune jobs failed")
+	} else if deleted > 0 {
+		log.Infof("jobs: pruned %d job(s)", deleted)
+	}
+	trashDays := internalsettings.GetInt(internalsettings.AuthTrashRetentionDaysKey)
+	purged, errPurge := PurgeTrashedAuths(ctx, r.db, trashDays, now)
+	if errPurge != nil {
+		log.WithError(errPurge).Warn("jobs: purge trashed auths failed")
+	} else if purged > 0 {
+		log.Infof("jobs: purged %d trashed auth file(s)", purged)
+	}
+	backupInterval := internalsettings.GetDuration(internalsettings.BackupIntervalHoursKey)
+	if _, errBackup := ScheduleBackup(ctx, r.db, r.backupDir, backupInterval, now); errBackup != nil {
+		log.WithError(errBackup).Warn("jobs: schedule backup failed")
+	}
+}
+
+// FailStaleJobs marks running jobs without a heartbeat for staleJobTimeout as failed.
+func FailStaleJobs(ctx context.Context, db *gorm.DB, now time.Time) (int64, error) {
+	res := db.WithContext(ctx).Model(&models.Job{}).
+		Where("status = ? AND updated_at < ?", models.JobStatusRunning, now.Add(-staleJobTimeout)).
+		Updates(map[string]any{
+			"status":      models.JobStatusFailed,
+			"error":       "job was interrupted",
+			"finished_at": now,
+			"updated_at":  now,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// PruneJobs deletes jobs that finished more than retentionDays before now. A
+// non-positive retentionDays keeps jobs forever.
+func PruneJobs(ctx context.Context, db *gorm.DB, retentionDays int, now time.Time) (int64, error) {
+	if db == nil || retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := now.UTC().AddDate(0, 0, -retentionDays)
+	res := db.WithContext(ctx).Where("finished_at < ?", cutoff).Delete(&models.Job{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeTrashedAuths permanently deletes auth files moved to the trash more than
+// retentionDays before now. A non-positive retentionDays keeps them forever.
+func PurgeTrashedAuths(ctx context.Context, db *gorm.DB, retentionDays int, now time.Time) (int64, error) {
+	if db == nil || retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := now.UTC().AddDate(0, 0, -retentionDays)
+	res := db.WithContext(ctx).Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.Auth{})
+	return res.RowsAffected, res.Error
+}
@@ -4,15 +4,23 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 )
 
 // requestIDKey is the context key for storing/retrieving request IDs.
 type requestIDKey struct{}
 
-// ginRequestIDKey is the Gin context key for request IDs.
-const ginRequestIDKey = "__request_id__"
+const (
+	// ginRequestIDKey is the Gin context key for request IDs.
+	ginRequestIDKey = "__request_id__"
+	// RequestIDHeader carries the request ID on inbound requests and every response.
+	RequestIDHeader = "X-Request-ID"
+	// maxRequestIDLength caps inbound request IDs so clients cannot bloat logs and rows.
+	maxRequestIDLength = 128
+)
 
 // GenerateRequestID creates a new 8-character hex request ID.
 func GenerateRequestID() string {
@@ -40,6 +48,25 @@ func GetRequestID(ctx context.Context) string {
 	return ""
 }
 
+// FromContext returns a logger carrying the request ID of ctx as the "request_id" field,
+// so every line logged while serving a request can be matched to its access log entry
+// and usage row. The ID is read from the request context or, for contexts handed to
+// SDK plugins, from the Gin context stored under "gin". Without an ID the plain
+// standard logger is returned.
+func FromContext(ctx context.Context) *log.Entry {
+	entry := log.NewEntry(log.StandardLogger())
+	requestID := GetRequestID(ctx)
+	if requestID == "" && ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok {
+			requestID = GetGinRequestID(ginCtx)
+		}
+	}
+	if requestID == "" {
+		return entry
+	}
+	return entry.WithField("request_id", requestID)
+}
+
 // SetGinRequestID stores the request ID in the Gin context.
 func SetGinRequestID(c *gin.Context, requestID string) {
 	if c != nil {
@@ -59,3 +86,40 @@ func GetGinRequestID(c *gin.Context) string {
 	}
 	return ""
 }
+
+// GinRequestIDMiddleware assigns every request an ID, honoring a well-formed inbound
+// X-Request-ID, stores it in the Gin and request contexts, and echoes it on the response.
+// A request re-dispatched through the engine keeps the ID already on its context.
+func GinRequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := GetRequestID(c.Request.Context())
+		if requestID == "" {
+			requestID = sanitizeRequestID(c.GetHeader(RequestIDHeader))
+		}
+		if requestID == "" {
+			requestID = GenerateRequestID()
+		}
+		SetGinRequestID(c, requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// sanitizeRequestID returns raw when it is a short token of letters, digits and
+// "-_.:", or an empty string otherwise.
+func sanitizeRequestID(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > maxRequestIDLength {
+		return ""
+	}
+	for _, r := range raw {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return ""
+		}
+	}
+	return raw
+}
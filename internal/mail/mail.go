@@ -0,0 +1,161 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultSMTPTimeout bounds the dial and the whole SMTP exchange when SMTPConfig.Timeout is unset.
+const defaultSMTPTimeout = 15 * time.Second
+
+// Message is a plain-text email.
+type Message struct {
+	To      string // Recipient address.
+	Subject string // Subject line.
+	Body    string // Plain-text body.
+}
+
+// Sender delivers outgoing email.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig holds the connection settings for SMTPSender.
+type SMTPConfig struct {
+	Host     string // SMTP server host.
+	Port     int    // SMTP server port.
+	Username string // Optional auth username.
+	Password string // Optional auth password.
+	From     string // Sender address.
+
+	Timeout time.Duration // Dial and exchange deadline; defaults to 15s.
+}
+
+// SMTPSender sends mail through an SMTP server using STARTTLS when offered.
+type SMTPSender struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPSender constructs an SMTPSender.
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	return &SMTPSender{cfg: cfg}
+}
+
+// Send delivers msg through the configured SMTP server. The dial and every
+// command share one deadline, so a stalled server fails the send instead of
+// holding the caller.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	host := strings.TrimSpace(s.cfg.Host)
+	if host == "" {
+		return fmt.Errorf("mail: smtp host not configured")
+	}
+	from := strings.TrimSpace(s.cfg.From)
+	if from == "" {
+		from = strings.TrimSpace(s.cfg.Username)
+	}
+	if from == "" {
+		return fmt.Errorf("mail: smtp from not configured")
+	}
+	port := s.cfg.Port
+	if port <= 0 {
+		port = 587
+	}
+	timeout := s.cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultSMTPTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	var dialer net.Dialer
+	conn, errDial := dialer.DialContext(ctx, "tcp", addr)
+	if errDial != nil {
+		return fmt.Errorf("mail: dial: %w", errDial)
+	}
+	deadline, _ := ctx.Deadline()
+	if errDeadline := conn.SetDeadline(deadline); errDeadline != nil {
+		_ = conn.Close()
+		return fmt.Errorf("mail: set deadline: %w", errDeadline)
+	}
+	client, errClient := smtp.NewClient(conn, host)
+	if errClient != nil {
+		_ = conn.Close()
+		return fmt.Errorf("mail: handshake: %w", errClient)
+	}
+	defer func() { _ = client.Close() }()
+
+	if errSend := s.deliver(client, host, from, msg); errSend != nil {
+		return fmt.Errorf("mail: send: %w", errSend)
+	}
+	return nil
+}
+
+// deliver runs the SMTP exchange on client, upgrading to TLS when the server offers STARTTLS.
+func (s *SMTPSender) deliver(client *smtp.Client, host, from string, msg Message) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if errTLS := client.StartTLS(&tls.Config{ServerName: host}); errTLS != nil {
+			return errTLS
+		}
+	}
+	if s.cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("server does not support AUTH")
+		}
+		if errAuth := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)); errAuth != nil {
+			return errAuth
+		}
+	}
+	if errMail := client.Mail(from); errMail != nil {
+		return errMail
+	}
+	if errRcpt := client.Rcpt(msg.To); errRcpt != nil {
+		return errRcpt
+	}
+	w, errData := client.Data()
+	if errData != nil {
+		return errData
+	}
+	if _, errWrite := w.Write(buildMessage(from, msg)); errWrite != nil {
+		return errWrite
+	}
+	if errClose := w.Close(); errClose != nil {
+		return errClose
+	}
+	return client.Quit()
+}
+
+// LogSender writes messages to the log instead of sending them.
+type LogSender struct{}
+
+// Send logs msg at info level.
+func (LogSender) Send(_ context.Context, msg Message) error {
+	log.WithField("to", msg.To).Infof("mail: smtp not configured, message not sent: %s\n%s", msg.Subject, msg.Body)
+	return nil
+}
+
+// buildMessage renders msg as an RFC 5322 payload.
+func buildMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + sanitizeHeader(msg.To) + "\r\n")
+	b.WriteString("Subject: " + sanitizeHeader(msg.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// sanitizeHeader strips line breaks to prevent header injection.
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSMTPSenderTimesOutOnStalledServer(t *testing.T) {
+	ln, errListen := net.Listen("tcp", "127.0.0.1:0")
+	if errListen != nil {
+		t.Fatalf("listen: %v", errListen)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, errAccept := ln.Accept()
+			if errAccept != nil {
+				return
+			}
+			// Accept the connection but never send the greeting.
+			defer func() { _ = conn.Close() }()
+		}
+	}()
+
+	_, portRaw, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portRaw)
+	sender := NewSMTPSender(SMTPConfig{Host: "127.0.0.1", Port: port, From: "noreply@example.com", Timeout: 200 * time.Millisecond})
+
+	start := time.Now()
+	errSend := sender.Send(context.Background(), Message{To: "user@example.com", Subject: "hi", Body: "hello"})
+	if errSend == nil {
+		t.Fatalf("expected a stalled server to fail the send")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the send to give up near the timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,102 @@
+// Package modelcache caches computed model lists in memory for endpoints that clients poll.
+package modelcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/watcher"
+)
+
+var (
+	hits   atomic.Int64 // Lookups served from a cache.
+	misses atomic.Int64 // Lookups that rebuilt a value.
+)
+
+// Hits returns how many model list lookups were served from memory since start.
+func Hits() int64 {
+	return hits.Load()
+}
+
+// Misses returns how many model list lookups rebuilt their value while caching was enabled.
+func Misses() int64 {
+	return misses.Load()
+}
+
+// TTL returns the configured lifetime of cached model lists; 0 disables caching.
+func TTL() time.Duration {
+	return time.Duration(internalsettings.GetInt(internalsettings.ModelListCacheTTLSecondsKey)) * time.Second
+}
+
+// entry is one cached value.
+type entry[T any] struct {
+	value     T
+	fetchedAt time.Time // When the value was built.
+	version   time.Time // Watcher provider change time the value was built against.
+}
+
+// Cache holds computed values by key. Entries expire after the TTL or as soon as the
+// version moves, which the default cache ties to the watcher seeing provider keys or
+// model mappings change. Cached values are shared between callers and must not be
+// modified.
+type Cache[T any] struct {
+	ttl     func() time.Duration
+	version func() time.Time
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry[T]
+}
+
+// New constructs a cache using MODEL_LIST_CACHE_TTL_SECONDS and the watcher's provider
+// change time.
+func New[T any]() *Cache[T] {
+	return NewWith[T](TTL, watcher.ProviderCatalogChangedAt, time.Now)
+}
+
+// NewWith constructs a cache with explicit TTL, version and clock sources.
+func NewWith[T any](ttl func() time.Duration, version, now func() time.Time) *Cache[T] {
+	return &Cache[T]{ttl: ttl, version: version, now: now, entries: make(map[string]entry[T])}
+}
+
+// Get returns the value cached under key and its age, calling build when there is no
+// fresh entry. Errors from build are returned and not cached. With a TTL of 0 every call
+// builds and nothing is stored or counted.
+func (c *Cache[T]) Get(key string, build func() (T, error)) (T, time.Duration, error) {
+	ttl := c.ttl()
+	if ttl <= 0 {
+		c.mu.Lock()
+		clear(c.entries)
+		c.mu.Unlock()
+		value, errBuild := build()
+		return value, 0, errBuild
+	}
+	now := c.now()
+	version := c.version()
+
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && cached.version.Equal(version) && now.Sub(cached.fetchedAt) < ttl {
+		hits.Add(1)
+		return cached.value, now.Sub(cached.fetchedAt), nil
+	}
+
+	misses.Add(1)
+	value, errBuild := build()
+	if errBuild != nil {
+		return value, 0, errBuild
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Drop stale entries so keys of callers that stopped polling do not pile up.
+	for k, e := range c.entries {
+		if !e.version.Equal(version) || now.Sub(e.fetchedAt) >= ttl {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry[T]{value: value, fetchedAt: now, version: version}
+	return value, 0, nil
+}
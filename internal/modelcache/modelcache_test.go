@@ -0,0 +1,66 @@
+package modelcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCacheExpiresOnTTLAndVersion(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	version := now.Add(-time.Hour)
+	ttl := 10 * time.Second
+	cache := NewWith[int](
+		func() time.Duration { return ttl },
+		func() time.Time { return version },
+		func() time.Time { return now },
+	)
+	builds := 0
+	build := func() (int, error) {
+		builds++
+		return builds, nil
+	}
+
+	startHits, startMisses := Hits(), Misses()
+	if value, age, _ := cache.Get("group:1", build); value != 1 || age != 0 {
+		t.Fatalf("unexpected first read %d age=%s", value, age)
+	}
+	now = now.Add(5 * time.Second)
+	if value, age, _ := cache.Get("group:1", build); value != 1 || age != 5*time.Second {
+		t.Fatalf("expected cached value aged 5s, got %d age=%s", value, age)
+	}
+	if value, _, _ := cache.Get("group:2", build); value != 2 {
+		t.Fatalf("expected a separate entry per key, got %d", value)
+	}
+	if Hits()-startHits != 1 || Misses()-startMisses != 2 {
+		t.Fatalf("unexpected counters hits=%d misses=%d", Hits()-startHits, Misses()-startMisses)
+	}
+
+	version = now
+	if value, _, _ := cache.Get("group:1", build); value != 3 {
+		t.Fatalf("expected a rebuild after a provider change, got %d", value)
+	}
+	now = now.Add(ttl)
+	if value, _, _ := cache.Get("group:1", build); value != 4 {
+		t.Fatalf("expected a rebuild after the ttl, got %d", value)
+	}
+
+	if _, _, errGet := cache.Get("group:3", func() (int, error) { return 0, errors.New("boom") }); errGet == nil {
+		t.Fatal("expected the build error to be returned")
+	}
+	if value, _, _ := cache.Get("group:3", build); value != 5 {
+		t.Fatalf("expected failed builds not to be cached, got %d", value)
+	}
+
+	ttl = 0
+	startHits, startMisses = Hits(), Misses()
+	if value, _, _ := cache.Get("group:1", build); value != 6 {
+		t.Fatalf("expected ttl 0 to bypass the cache, got %d", value)
+	}
+	if value, _, _ := cache.Get("group:1", build); value != 7 {
+		t.Fatalf("expected ttl 0 to bypass the cache, got %d", value)
+	}
+	if Hits() != startHits || Misses() != startMisses {
+		t.Fatal("expected a disabled cache not to count lookups")
+	}
+}
@@ -0,0 +1,182 @@
+package modelmapping
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+// MappingLoop describes enabled mappings of one provider whose aliases lead back to
+// their own model names.
+type MappingLoop struct {
+	Provider   string   `json:"provider"`    // Provider name (lowercased).
+	Models     []string `json:"models"`      // Model names on the loop, sorted.
+	MappingIDs []uint64 `json:"mapping_ids"` // Mappings forming the loop, sorted.
+}
+
+// mappingEdge is one model_name -> new_model_name alias within a provider.
+type mappingEdge struct {
+	id uint64
+	to string
+}
+
+// mappingGraph holds alias edges per provider, keyed by lowercased model name.
+type mappingGraph map[string]map[string][]mappingEdge
+
+// buildMappingGraph collects the alias edges of enabled rows. Identity mappings, which
+// only attach settings to an exposed name, are not edges.
+func buildMappingGraph(rows []models.ModelMapping, skipID uint64) mappingGraph {
+	graph := make(mappingGraph)
+	for i := range rows {
+		row := &rows[i]
+		if !row.IsEnabled || (skipID != 0 && row.ID == skipID) {
+			continue
+		}
+		provider, from, to, ok := edgeKey(row)
+		if !ok {
+			continue
+		}
+		edges, exists := graph[provider]
+		if !exists {
+			edges = make(map[string][]mappingEdge)
+			graph[provider] = edges
+		}
+		edges[from] = append(edges[from], mappingEdge{id: row.ID, to: to})
+	}
+	return graph
+}
+
+// edgeKey returns the normalized provider and endpoints of row, or false when the row
+// is incomplete or an identity mapping.
+func edgeKey(row *models.ModelMapping) (string, string, string, bool) {
+	provider := strings.ToLower(strings.TrimSpace(row.Provider))
+	from := strings.ToLower(strings.TrimSpace(row.ModelName))
+	to := strings.ToLower(strings.TrimSpace(row.NewModelName))
+	if provider == "" || from == "" || to == "" || from == to {
+		return "", "", "", false
+	}
+	return provider, from, to, true
+}
+
+// FindMappingLoop reports the chain of model names that candidate would close into a
+// loop when added to rows, which must not already contain it under the same ID. It
+// returns nil when candidate is disabled, an identity mapping or loop-free. The walk
+// is a breadth-first search over the provider's edges, so it is O(edges).
+func FindMappingLoop(rows []models.ModelMapping, candidate models.ModelMapping) []string {
+	if !candidate.IsEnabled {
+		return nil
+	}
+	provider, from, to, ok := edgeKey(&candidate)
+	if !ok {
+		return nil
+	}
+	edges := buildMappingGraph(rows, candidate.ID)[provider]
+
+	// Search for a path to -> ... -> from; with the candidate edge it forms a loop.
+	parent := map[string]string{to: ""}
+	queue := []string{to}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node == from {
+			path := []string{from}
+			for step := parent[from]; step != ""; step = parent[step] {
+				path = append(path, step)
+			}
+			// path runs from -> ... -> to backwards; reverse it and close the loop.
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return append([]string{from}, path...)
+		}
+		for _, edge := range edges[node] {
+			if _, seen := parent[edge.to]; seen {
+				continue
+			}
+			parent[edge.to] = node
+			queue = append(queue, edge.to)
+		}
+	}
+	return nil
+}
+
+// FindMappingLoops returns every loop among the enabled rows, one entry per strongly
+// connected set of model names, using Tarjan's algorithm in O(edges).
+func FindMappingLoops(rows []models.ModelMapping) []MappingLoop {
+	graph := buildMappingGraph(rows, 0)
+	providers := make([]string, 0, len(graph))
+	for provider := range graph {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	var loops []MappingLoop
+	for _, provider := range providers {
+		edges := graph[provider]
+		nodes := make([]string, 0, len(edges))
+		for node := range edges {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+
+		index := make(map[string]int)
+		low := make(map[string]int)
+		onStack := make(map[string]bool)
+		var stack []string
+		next := 0
+		var visit func(node string)
+		visit = func(node string) {
+			index[node] = next
+			low[node] = next
+			next++
+			stack = append(stack, node)
+			onStack[node] = true
+			for _, edge := range edges[node] {
+				if _, seen := index[edge.to]; !seen {
+					visit(edge.to)
+					low[node] = min(low[node], low[edge.to])
+				} else if onStack[edge.to] {
+					low[node] = min(low[node], index[edge.to])
+				}
+			}
+			if low[node] != index[node] {
+				return
+			}
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			if len(component) < 2 {
+				return
+			}
+			members := make(map[string]struct{}, len(component))
+			for _, member := range component {
+				members[member] = struct{}{}
+			}
+			loop := MappingLoop{Provider: provider, Models: component}
+			for _, member := range component {
+				for _, edge := range edges[member] {
+					if _, inside := members[edge.to]; inside {
+						loop.MappingIDs = append(loop.MappingIDs, edge.id)
+					}
+				}
+			}
+			sort.Strings(loop.Models)
+			sort.Slice(loop.MappingIDs, func(i, j int) bool { return loop.MappingIDs[i] < loop.MappingIDs[j] })
+			loops = append(loops, loop)
+		}
+		for _, node := range nodes {
+			if _, seen := index[node]; !seen {
+				visit(node)
+			}
+		}
+	}
+	return loops
+}
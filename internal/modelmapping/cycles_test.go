@@ -0,0 +1,58 @@
+package modelmapping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestFindMappingLoopDetectsClosingEdge(t *testing.T) {
+	rows := []models.ModelMapping{
+		{ID: 1, Provider: "claude", ModelName: "a", NewModelName: "b", IsEnabled: true},
+		{ID: 2, Provider: "claude", ModelName: "B", NewModelName: "c", IsEnabled: true},
+		{ID: 3, Provider: "openai", ModelName: "c", NewModelName: "a", IsEnabled: true},
+		{ID: 4, Provider: "claude", ModelName: "x", NewModelName: "x", IsEnabled: true},
+	}
+
+	loop := FindMappingLoop(rows, models.ModelMapping{Provider: "Claude", ModelName: "c", NewModelName: "a", IsEnabled: true})
+	if want := []string{"c", "a", "b", "c"}; !reflect.DeepEqual(loop, want) {
+		t.Fatalf("expected loop %v, got %v", want, loop)
+	}
+	if loop := FindMappingLoop(rows, models.ModelMapping{Provider: "claude", ModelName: "c", NewModelName: "a"}); loop != nil {
+		t.Fatalf("expected disabled candidate to pass, got %v", loop)
+	}
+	if loop := FindMappingLoop(rows, models.ModelMapping{Provider: "claude", ModelName: "y", NewModelName: "Y", IsEnabled: true}); loop != nil {
+		t.Fatalf("expected identity mapping to pass, got %v", loop)
+	}
+	// An update is checked without the row's old edge.
+	if loop := FindMappingLoop(rows, models.ModelMapping{ID: 2, Provider: "claude", ModelName: "b", NewModelName: "a", IsEnabled: true}); loop == nil {
+		t.Fatal("expected b -> a to close a loop with a -> b")
+	}
+	if loop := FindMappingLoop(rows, models.ModelMapping{ID: 1, Provider: "claude", ModelName: "a", NewModelName: "d", IsEnabled: true}); loop != nil {
+		t.Fatalf("expected replaced edge to be ignored, got %v", loop)
+	}
+}
+
+func TestFindMappingLoopsReportsComponents(t *testing.T) {
+	rows := []models.ModelMapping{
+		{ID: 1, Provider: "claude", ModelName: "a", NewModelName: "b", IsEnabled: true},
+		{ID: 2, Provider: "claude", ModelName: "b", NewModelName: "c", IsEnabled: true},
+		{ID: 3, Provider: "claude", ModelName: "c", NewModelName: "a", IsEnabled: true},
+		{ID: 4, Provider: "claude", ModelName: "c", NewModelName: "d", IsEnabled: true},
+		{ID: 5, Provider: "openai", ModelName: "x", NewModelName: "y", IsEnabled: true},
+		{ID: 6, Provider: "openai", ModelName: "y", NewModelName: "x", IsEnabled: false},
+		{ID: 7, Provider: "gemini", ModelName: "p", NewModelName: "q", IsEnabled: true},
+		{ID: 8, Provider: "Gemini", ModelName: "Q", NewModelName: "p", IsEnabled: true},
+		{ID: 9, Provider: "gemini", ModelName: "s", NewModelName: "s", IsEnabled: true},
+	}
+
+	loops := FindMappingLoops(rows)
+	want := []MappingLoop{
+		{Provider: "claude", Models: []string{"a", "b", "c"}, MappingIDs: []uint64{1, 2, 3}},
+		{Provider: "gemini", Models: []string{"p", "q"}, MappingIDs: []uint64{7, 8}},
+	}
+	if !reflect.DeepEqual(loops, want) {
+		t.Fatalf("expected loops %+v, got %+v", want, loops)
+	}
+}
@@ -0,0 +1,77 @@
+package modelmapping
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"gorm.io/datatypes"
+)
+
+// MaxFallbackTargets bounds the fallback chain length for a single mapping.
+const MaxFallbackTargets = 3
+
+// FallbackTarget identifies a provider + exposed model pair used as a cooldown fallback.
+type FallbackTarget struct {
+	Provider string `json:"provider"` // Provider name.
+	Model    string `json:"model"`    // Exposed model name on the provider.
+}
+
+// String renders the target as provider/model.
+func (t FallbackTarget) String() string {
+	return t.Provider + "/" + t.Model
+}
+
+type fallbackEntry struct {
+	id      uint64
+	targets []FallbackTarget
+}
+
+// ParseFallbackTargets decodes and normalizes fallback targets from JSON.
+// Duplicates are dropped; incomplete entries or more than MaxFallbackTargets are rejected.
+func ParseFallbackTargets(raw datatypes.JSON) ([]FallbackTarget, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, nil
+	}
+	var items []FallbackTarget
+	if errUnmarshal := json.Unmarshal(trimmed, &items); errUnmarshal != nil {
+		return nil, errUnmarshal
+	}
+	out := make([]FallbackTarget, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		provider := strings.ToLower(strings.TrimSpace(item.Provider))
+		model := strings.TrimSpace(item.Model)
+		if provider == "" || model == "" {
+			return nil, errors.New("fallback target requires provider and model")
+		}
+		key := makeLowerKey(provider, model)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, FallbackTarget{Provider: provider, Model: model})
+	}
+	if len(out) > MaxFallbackTargets {
+		return nil, errors.New("too many fallback targets")
+	}
+	return out, nil
+}
+
+// LookupFallbacks returns the ordered fallback targets for an exposed model name.
+func LookupFallbacks(model string) ([]FallbackTarget, bool) {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return nil, false
+	}
+	snap := loadSnapshot()
+	entry, ok := snap.byModelFallback[strings.ToLower(model)]
+	if !ok || len(entry.targets) == 0 {
+		return nil, false
+	}
+	targets := make([]FallbackTarget, len(entry.targets))
+	copy(targets, entry.targets)
+	return targets, true
+}
@@ -0,0 +1,49 @@
+package modelmapping
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxTimeoutSeconds bounds a mapping's upstream request timeout.
+	MaxTimeoutSeconds = 3600
+	// MaxOutputTokens bounds a mapping's forced output token limit.
+	MaxOutputTokens = 1_000_000
+)
+
+type timeoutEntry struct {
+	id      uint64
+	timeout time.Duration
+}
+
+// ValidateTimeoutSeconds checks a mapping timeout; 0 inherits the default.
+func ValidateTimeoutSeconds(seconds int) error {
+	if seconds < 0 || seconds > MaxTimeoutSeconds {
+		return fmt.Errorf("timeout_seconds must be between 0 and %d", MaxTimeoutSeconds)
+	}
+	return nil
+}
+
+// ValidateMaxOutputTokens checks a mapping output token limit; 0 inherits the default.
+func ValidateMaxOutputTokens(tokens int) error {
+	if tokens < 0 || tokens > MaxOutputTokens {
+		return fmt.Errorf("max_output_tokens must be between 0 and %d", MaxOutputTokens)
+	}
+	return nil
+}
+
+// LookupTimeout returns the upstream timeout configured for an exposed model name.
+func LookupTimeout(model string) (time.Duration, bool) {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return 0, false
+	}
+	snap := loadSnapshot()
+	entry, ok := snap.byModelTimeout[strings.ToLower(model)]
+	if !ok || entry.timeout <= 0 {
+		return 0, false
+	}
+	return entry.timeout, true
+}
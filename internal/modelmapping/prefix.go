@@ -0,0 +1,59 @@
+package modelmapping
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxModelPrefixLength bounds a user group's model name prefix.
+const MaxModelPrefixLength = 32
+
+// NormalizeModelPrefix trims a user group model prefix and checks that it only holds
+// letters, digits, '-', '_' and '.'. An empty prefix disables branding.
+func NormalizeModelPrefix(prefix string) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if len(prefix) > MaxModelPrefixLength {
+		return "", fmt.Errorf("model_prefix must be at most %d characters", MaxModelPrefixLength)
+	}
+	for _, r := range prefix {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return "", fmt.Errorf("model_prefix may only contain letters, digits, '-', '_' and '.'")
+		}
+	}
+	return prefix, nil
+}
+
+// ApplyModelPrefix returns the branded name clients see for a canonical model.
+func ApplyModelPrefix(prefix, model string) string {
+	if prefix == "" || model == "" {
+		return model
+	}
+	return prefix + model
+}
+
+// StripModelPrefix returns the canonical model for a branded name. Names without the
+// prefix are returned unchanged with false, so clients may keep using canonical names.
+func StripModelPrefix(prefix, model string) (string, bool) {
+	if prefix == "" || len(model) <= len(prefix) || !strings.EqualFold(model[:len(prefix)], prefix) {
+		return model, false
+	}
+	return model[len(prefix):], true
+}
+
+// FindModelPrefixConflict returns a served model name that starts with prefix. Requests
+// for such a model would lose the prefix on the way in, and its branded name could
+// collide with another model, so the prefix cannot be used while it exists.
+func FindModelPrefixConflict(prefix string, names []string) (string, bool) {
+	if prefix == "" {
+		return "", false
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if _, stripped := StripModelPrefix(prefix, name); stripped || strings.EqualFold(name, prefix) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -30,6 +30,8 @@ type snapshot struct {
 	byProviderNew   map[string]selectorEntry
 	byProviderModel map[string]selectorEntry
 	byProviderAlias map[string]modelAliasEntry
+	byModelFallback map[string]fallbackEntry
+	byModelTimeout  map[string]timeoutEntry
 }
 
 var globalSnapshot atomic.Value
@@ -39,6 +41,8 @@ func init() {
 		byProviderNew:   make(map[string]selectorEntry),
 		byProviderModel: make(map[string]selectorEntry),
 		byProviderAlias: make(map[string]modelAliasEntry),
+		byModelFallback: make(map[string]fallbackEntry),
+		byModelTimeout:  make(map[string]timeoutEntry),
 	})
 }
 
@@ -47,6 +51,8 @@ func StoreModelMappings(updatedAt time.Time, rows []models.ModelMapping) {
 	nextNew := make(map[string]selectorEntry)
 	nextModel := make(map[string]selectorEntry)
 	nextAlias := make(map[string]modelAliasEntry)
+	nextFallback := make(map[string]fallbackEntry)
+	nextTimeout := make(map[string]timeoutEntry)
 
 	for _, row := range rows {
 		if !row.IsEnabled {
@@ -92,6 +98,23 @@ func StoreModelMappings(updatedAt time.Time, rows []models.ModelMapping) {
 				}
 			}
 		}
+
+		if alias != "" && row.FallbackEnabled {
+			targets, errParse := ParseFallbackTargets(row.FallbackTargets)
+			if errParse == nil && len(targets) > 0 {
+				key := strings.ToLower(alias)
+				if prev, ok := nextFallback[key]; !ok || row.ID > prev.id {
+					nextFallback[key] = fallbackEntry{id: row.ID, targets: targets}
+				}
+			}
+		}
+
+		if alias != "" && row.TimeoutSeconds > 0 {
+			key := strings.ToLower(alias)
+			if prev, ok := nextTimeout[key]; !ok || row.ID > prev.id {
+				nextTimeout[key] = timeoutEntry{id: row.ID, timeout: time.Duration(row.TimeoutSeconds) * time.Second}
+			}
+		}
 	}
 
 	globalSnapshot.Store(snapshot{
@@ -99,6 +122,8 @@ func StoreModelMappings(updatedAt time.Time, rows []models.ModelMapping) {
 		byProviderNew:   nextNew,
 		byProviderModel: nextModel,
 		byProviderAlias: nextAlias,
+		byModelFallback: nextFallback,
+		byModelTimeout:  nextTimeout,
 	})
 }
 
@@ -180,6 +205,8 @@ func loadSnapshot() snapshot {
 			byProviderNew:   make(map[string]selectorEntry),
 			byProviderModel: make(map[string]selectorEntry),
 			byProviderAlias: make(map[string]modelAliasEntry),
+			byModelFallback: make(map[string]fallbackEntry),
+			byModelTimeout:  make(map[string]timeoutEntry),
 		}
 	}
 	if snap.byProviderNew == nil {
@@ -191,6 +218,12 @@ func loadSnapshot() snapshot {
 	if snap.byProviderAlias == nil {
 		snap.byProviderAlias = make(map[string]modelAliasEntry)
 	}
+	if snap.byModelFallback == nil {
+		snap.byModelFallback = make(map[string]fallbackEntry)
+	}
+	if snap.byModelTimeout == nil {
+		snap.byModelTimeout = make(map[string]timeoutEntry)
+	}
 	return snap
 }
 
@@ -93,3 +93,28 @@ func TestStoreModelMappings_UsesIdentityAliasWhenNoExplicitAliasExists(t *testin
 		t.Fatalf("expected allowed user groups [456], got %v", values)
 	}
 }
+
+func TestMappingLimitsValidateAndLookupTimeout(t *testing.T) {
+	if ValidateTimeoutSeconds(0) != nil || ValidateTimeoutSeconds(MaxTimeoutSeconds) != nil {
+		t.Fatalf("expected 0 and the maximum timeout to be accepted")
+	}
+	if ValidateTimeoutSeconds(-1) == nil || ValidateTimeoutSeconds(MaxTimeoutSeconds+1) == nil {
+		t.Fatalf("expected out-of-range timeouts to be rejected")
+	}
+	if ValidateMaxOutputTokens(-5) == nil || ValidateMaxOutputTokens(MaxOutputTokens+1) == nil {
+		t.Fatalf("expected out-of-range token limits to be rejected")
+	}
+
+	StoreModelMappings(time.Now().UTC(), []models.ModelMapping{
+		{ID: 1, Provider: "claude", ModelName: "a", NewModelName: "Long", TimeoutSeconds: 30, IsEnabled: true},
+		{ID: 2, Provider: "vertex", ModelName: "b", NewModelName: "long", TimeoutSeconds: 600, IsEnabled: true},
+		{ID: 3, Provider: "openai", ModelName: "c", NewModelName: "fast", IsEnabled: true},
+	})
+	t.Cleanup(func() { StoreModelMappings(time.Now().UTC(), nil) })
+	if timeout, ok := LookupTimeout("long"); !ok || timeout != 600*time.Second {
+		t.Fatalf("expected the newest mapping's timeout, got %v %v", timeout, ok)
+	}
+	if _, ok := LookupTimeout("fast"); ok {
+		t.Fatalf("expected no timeout for a mapping that inherits the default")
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ActiveHours is a daily window, in a given time zone, during which the auths of a group
+// may serve requests. A window whose end is before its start spans midnight.
+type ActiveHours struct {
+	Start    string // Opening time as HH:MM.
+	End      string // Closing time as HH:MM, exclusive.
+	Timezone string // IANA time zone name; empty means UTC.
+
+	startMinute int
+	endMinute   int
+	location    *time.Location
+}
+
+// Attributes carrying the active hours of an auth's primary group on runtime auths.
+const (
+	AuthAttrActiveHoursStart    = "active_hours_start"
+	AuthAttrActiveHoursEnd      = "active_hours_end"
+	AuthAttrActiveHoursTimezone = "active_hours_timezone"
+)
+
+// locations caches loaded time zones by name, as active hours are checked per request.
+var locations sync.Map
+
+// ParseActiveHours validates a daily window. Start and end must differ.
+func ParseActiveHours(start, end, timezone string) (ActiveHours, error) {
+	h := ActiveHours{Start: strings.TrimSpace(start), End: strings.TrimSpace(end), Timezone: strings.TrimSpace(timezone)}
+	var errParse error
+	if h.startMinute, errParse = parseClock(h.Start); errParse != nil {
+		return ActiveHours{}, fmt.Errorf("start: %w", errParse)
+	}
+	if h.endMinute, errParse = parseClock(h.End); errParse != nil {
+		return ActiveHours{}, fmt.Errorf("end: %w", errParse)
+	}
+	if h.startMinute == h.endMinute {
+		return ActiveHours{}, errors.New("start and end must differ")
+	}
+	if h.Timezone == "" {
+		h.location = time.UTC
+		return h, nil
+	}
+	if cached, ok := locations.Load(h.Timezone); ok {
+		h.location = cached.(*time.Location)
+		return h, nil
+	}
+	loc, errLoad := time.LoadLocation(h.Timezone)
+	if errLoad != nil {
+		return ActiveHours{}, fmt.Errorf("timezone: unknown time zone %q", h.Timezone)
+	}
+	locations.Store(h.Timezone, loc)
+	h.location = loc
+	return h, nil
+}
+
+// parseClock parses HH:MM into minutes after midnight.
+func parseClock(raw string) (int, error) {
+	t, errParse := time.Parse("15:04", raw)
+	if errParse != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", raw)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether t falls inside the window.
+func (h ActiveHours) Contains(t time.Time) bool {
+	local := t.In(h.location)
+	minute := local.Hour()*60 + local.Minute()
+	if h.startMinute < h.endMinute {
+		return minute >= h.startMinute && minute < h.endMinute
+	}
+	return minute >= h.startMinute || minute < h.endMinute
+}
+
+// NextOpen returns t when the window is open, else the next time it opens.
+func (h ActiveHours) NextOpen(t time.Time) time.Time {
+	if h.Contains(t) {
+		return t
+	}
+	local := t.In(h.location)
+	open := time.Date(local.Year(), local.Month(), local.Day(), h.startMinute/60, h.startMinute%60, 0, 0, h.location)
+	if !open.After(local) {
+		open = time.Date(local.Year(), local.Month(), local.Day()+1, h.startMinute/60, h.startMinute%60, 0, 0, h.location)
+	}
+	return open
+}
+
+// ActiveHours returns the group's daily window, if one is set and valid.
+func (g AuthGroup) ActiveHours() (ActiveHours, bool) {
+	if g.ActiveHoursStart == "" && g.ActiveHoursEnd == "" {
+		return ActiveHours{}, false
+	}
+	h, errParse := ParseActiveHours(g.ActiveHoursStart, g.ActiveHoursEnd, g.ActiveHoursTimezone)
+	return h, errParse == nil
+}
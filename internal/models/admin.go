@@ -12,6 +12,10 @@ type Admin struct {
 
 	Username string `gorm:"type:text;not null;uniqueIndex"` // Unique login name.
 	Password string `gorm:"type:text;not null"`             // Hashed password.
+	Email    string `gorm:"type:text;index"`                // Lowercased email used to match a first SSO login.
+
+	OIDCIssuer  string `gorm:"column:oidc_issuer;type:text;index:idx_admins_oidc_identity"`  // SSO issuer bound at first login.
+	OIDCSubject string `gorm:"column:oidc_subject;type:text;index:idx_admins_oidc_identity"` // SSO subject bound at first login.
 
 	Active bool `gorm:"not null;default:true"` // Whether the admin can sign in.
 
@@ -26,6 +30,6 @@ type Admin struct {
 	PasskeyBackupEligible *bool   `gorm:"type:boolean"` // WebAuthn backup eligibility flag.
 	PasskeyBackupState    *bool   `gorm:"type:boolean"` // WebAuthn backup state flag.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
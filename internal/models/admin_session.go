@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AdminSession records an issued admin JWT so it can be listed and revoked.
+type AdminSession struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	JTI     string `gorm:"type:text;not null;uniqueIndex"` // JWT ID claim of the issued token.
+	AdminID uint64 `gorm:"not null;index"`                 // Owning admin ID.
+	Admin   *Admin `gorm:"foreignKey:AdminID"`             // Owning admin record.
+
+	IP        string `gorm:"type:text"` // Client IP that logged in.
+	UserAgent string `gorm:"type:text"` // Client user agent that logged in.
+
+	ExpiresAt time.Time  `gorm:"not null;index"` // Token expiration time.
+	RevokedAt *time.Time // Revocation time, if revoked.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+}
@@ -17,10 +17,10 @@ type APIKey struct {
 	Active     bool       `gorm:"not null;default:true"` // Whether the key is enabled.
 	ExpiresAt  *time.Time // Optional expiration timestamp.
 	RevokedAt  *time.Time // Revocation timestamp when disabled.
-	LastUsedAt *time.Time // Last successful usage time.
+	LastUsedAt *time.Time `gorm:"index"` // Last successful usage time, written at most once per minute.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
 
 // Status returns the current key status based on revocation, expiry window, and active flag.
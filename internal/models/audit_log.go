@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AuditLog records a security-relevant action taken by an admin or user.
+type AuditLog struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	ActorType string `gorm:"type:text;not null;index"` // Actor kind, e.g. "admin".
+	ActorID   uint64 `gorm:"not null;index"`           // Actor record ID.
+	Action    string `gorm:"type:text;not null;index"` // Action name.
+
+	TargetType string  `gorm:"type:text"` // Target kind, e.g. "user".
+	TargetID   *uint64 `gorm:"index"`     // Target record ID, if any.
+
+	Detail datatypes.JSON `gorm:"type:jsonb"` // Structured action detail.
+	IP     string         `gorm:"type:text"`  // Client IP address.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;index;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+}
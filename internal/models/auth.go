@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AuthGroupIDs stores auth group identifiers as a JSON array.
@@ -157,20 +159,46 @@ func (ids AuthGroupIDs) Values() []uint64 {
 
 // Auth stores an authentication entry and its content for relay usage.
 type Auth struct {
-	ID  uint64 `gorm:"primaryKey;autoIncrement"`       // Primary key.
-	Key string `gorm:"type:text;not null;uniqueIndex"` // Unique auth key.
+	ID  uint64 `gorm:"primaryKey;autoIncrement"`                                                   // Primary key.
+	Key string `gorm:"type:text;not null;uniqueIndex:idx_auths_key_live,where:deleted_at IS NULL"` // Auth key, unique among live entries.
 
 	ProxyURL string `gorm:"type:text"` // Optional proxy override.
 
 	AuthGroupID AuthGroupIDs `gorm:"type:jsonb;not null;default:'[]'"` // Owning auth group IDs.
 	AuthGroup   []*AuthGroup `gorm:"-"`                                // Owning auth groups.
 
-	Content datatypes.JSON `gorm:"type:jsonb;not null"` // Auth payload content.
+	Tags Tags `gorm:"type:jsonb;not null;default:'[]'"` // Normalized labels such as owner or team.
+
+	Content     datatypes.JSON `gorm:"type:jsonb;not null"`                 // Auth payload content.
+	Fingerprint string         `gorm:"type:text;not null;default:'';index"` // AuthFingerprint of Content; empty when the account is unknown.
 
 	IsAvailable bool `gorm:"type:boolean;not null;default:true"` // Availability flag.
 	RateLimit   int  `gorm:"not null;default:0"`                 // Rate limit per second.
 	Priority    int  `gorm:"not null;default:0;index"`           // Selection priority (higher wins).
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	EffectivePriority   *int       // Decayed priority after repeated quota errors; nil uses Priority.
+	QuotaStrikes        int        `gorm:"not null;default:0"` // Quota errors counted in the current window.
+	QuotaStrikeWindowAt *time.Time // Start of the current quota strike window.
+	LastQuotaExceededAt *time.Time // Most recent quota error.
+
+	CreatedAt time.Time      `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time      `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
+	DeletedAt gorm.DeletedAt `gorm:"index"`                                             // Time the entry was moved to the trash.
+}
+
+// AuthKeyConflict is the ON CONFLICT target for upserts by key. It repeats the predicate
+// of idx_auths_key_live so the database can infer the partial unique index.
+func AuthKeyConflict() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:     []clause.Column{{Name: "key"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "deleted_at IS NULL"}}},
+	}
+}
+
+// SelectionPriority returns the priority exported to the selector, preferring the decayed value.
+func (a *Auth) SelectionPriority() int {
+	if a.EffectivePriority != nil {
+		return *a.EffectivePriority
+	}
+	return a.Priority
 }
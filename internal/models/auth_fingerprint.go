@@ -0,0 +1,32 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// AuthFingerprint returns a hash identifying the account behind auth content, so the same
+// account imported under two keys can be recognized. It covers the type, the account email
+// or ID and the OAuth client ID; content without an email or account ID has no fingerprint.
+func AuthFingerprint(content []byte) string {
+	var payload map[string]any
+	if len(content) == 0 || json.Unmarshal(content, &payload) != nil {
+		return ""
+	}
+	token, _ := payload["token"].(map[string]any)
+	field := func(key string) string {
+		value, _ := payload[key].(string)
+		if strings.TrimSpace(value) == "" && token != nil {
+			value, _ = token[key].(string)
+		}
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+	email, accountID := field("email"), field("account_id")
+	if email == "" && accountID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{field("type"), email, accountID, field("client_id")}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
@@ -10,10 +10,18 @@ type AuthGroup struct {
 	IsDefault bool   `gorm:"not null;default:false"`         // Marks the default group.
 	RateLimit int    `gorm:"not null;default:0"`             // Rate limit per second.
 
+	DefaultProxyURL string `gorm:"type:text;not null;default:''"` // Proxy used by member auths without their own.
+
+	// Daily window during which member auths may serve requests; empty start and end
+	// means always. See ActiveHours.
+	ActiveHoursStart    string `gorm:"type:text;not null;default:''"` // Opening time as HH:MM.
+	ActiveHoursEnd      string `gorm:"type:text;not null;default:''"` // Closing time as HH:MM.
+	ActiveHoursTimezone string `gorm:"type:text;not null;default:''"` // IANA time zone; empty means UTC.
+
 	UserGroupID UserGroupIDs `gorm:"type:jsonb;not null;default:'[]'"` // Allowed user group IDs.
 
 	Auths []Auth `gorm:"-"` // Related auth records (not persisted).
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Balance adjustment target kinds.
+const (
+	// AdjustmentTargetBill marks an adjustment applied to a bill's quota.
+	AdjustmentTargetBill = "bill"
+	// AdjustmentTargetPrepaidCard marks an adjustment applied to a prepaid card balance.
+	AdjustmentTargetPrepaidCard = "prepaid_card"
+)
+
+// BalanceAdjustment is a ledger entry for a manual credit or debit of a user's balance.
+type BalanceAdjustment struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	UserID  uint64  `gorm:"not null;index"` // Adjusted user ID.
+	AdminID uint64  `gorm:"not null;index"` // Admin who issued the adjustment.
+	UsageID *uint64 `gorm:"index"`          // Usage row being refunded, if any.
+
+	Amount float64 `gorm:"type:decimal(20,10);not null"` // Positive credit or negative debit.
+	Reason string  `gorm:"type:text;not null"`           // Support-facing explanation.
+
+	TargetType string `gorm:"type:text;not null"` // Credited object kind: bill or prepaid_card.
+	TargetID   uint64 `gorm:"not null"`           // Credited bill or prepaid card ID.
+
+	ExceedsCharges bool  `gorm:"not null;default:false"` // Whether the credit was allowed past original charges.
+	ChargedMicros  int64 `gorm:"not null;default:0"`     // Original cost of the refunded usage row, kept for after retention deletes it.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;index;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+}
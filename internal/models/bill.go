@@ -58,6 +58,10 @@ type Bill struct {
 	IsEnabled bool       `gorm:"not null;default:true"` // Whether the bill is active.
 	Status    BillStatus `gorm:"not null;default:1"`    // Current bill status.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	AutoRenew         bool       `gorm:"not null;default:false"` // Whether a paid bill renews into the next period at period end.
+	RenewedFromBillID *uint64    `gorm:"uniqueIndex"`            // Bill this one renewed, so each bill renews at most once.
+	ExpiryNotifiedAt  *time.Time // Time the bill.expired webhook event was queued, if any.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -13,6 +13,65 @@ const (
 	BillingTypePerToken BillingType = 2
 )
 
+// BillFailedPolicy defines what a failed request is charged under a rule.
+type BillFailedPolicy string
+
+// BillFailedPolicy constants list the supported failed-request policies.
+const (
+	// BillFailedNone charges nothing for failed requests.
+	BillFailedNone BillFailedPolicy = "none"
+	// BillFailedInputOnly charges the prompt side (input and cached tokens) of failed
+	// per-token requests; per-request rules charge nothing.
+	BillFailedInputOnly BillFailedPolicy = "input_only"
+	// BillFailedFull charges failed requests like successful ones.
+	BillFailedFull BillFailedPolicy = "full"
+)
+
+// Valid reports whether p is a known policy.
+func (p BillFailedPolicy) Valid() bool {
+	switch p {
+	case BillFailedNone, BillFailedInputOnly, BillFailedFull:
+		return true
+	default:
+		return false
+	}
+}
+
+// PriceUnit defines how many tokens a per-token rule price covers.
+type PriceUnit string
+
+// PriceUnit constants list the supported token price units.
+const (
+	// PriceUnitPerToken prices a single token.
+	PriceUnitPerToken PriceUnit = "per_token"
+	// PriceUnitPer1K prices 1,000 tokens.
+	PriceUnitPer1K PriceUnit = "per_1k"
+	// PriceUnitPer1M prices 1,000,000 tokens, the unit of rules created before units existed.
+	PriceUnitPer1M PriceUnit = "per_1m"
+)
+
+// Valid reports whether u is a known unit.
+func (u PriceUnit) Valid() bool {
+	switch u {
+	case PriceUnitPerToken, PriceUnitPer1K, PriceUnitPer1M:
+		return true
+	default:
+		return false
+	}
+}
+
+// Tokens returns the number of tokens one price covers. An empty unit counts as per_1m.
+func (u PriceUnit) Tokens() int64 {
+	switch u {
+	case PriceUnitPerToken:
+		return 1
+	case PriceUnitPer1K:
+		return 1_000
+	default:
+		return 1_000_000
+	}
+}
+
 // BillingRule defines pricing and applicability for a provider/model pair.
 type BillingRule struct {
 	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
@@ -30,11 +89,19 @@ type BillingRule struct {
 	PriceCacheCreateToken *float64 `gorm:"type:decimal(20,10)"` // Cache create token price.
 	PriceCacheReadToken   *float64 `gorm:"type:decimal(20,10)"` // Cache read token price.
 
+	PriceUnit PriceUnit `gorm:"type:varchar(16);not null;default:'per_1m'"` // Tokens covered by each token price.
+
+	StreamPriceMultiplier *float64 `gorm:"type:decimal(10,4)"` // Optional cost multiplier for streamed requests.
+
+	BillFailed BillFailedPolicy `gorm:"type:varchar(16);not null;default:'none'"` // What failed requests are charged.
+
+	PreferUpstreamCost bool `gorm:"not null;default:false"` // Bill the provider-reported cost when the response carries one.
+
 	IsEnabled bool `gorm:"not null;default:true"` // Whether the rule is active.
 
 	AuthGroup AuthGroup `gorm:"foreignKey:AuthGroupID"` // Auth group relation.
 	UserGroup UserGroup `gorm:"foreignKey:UserGroupID"` // User group relation.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// EmailVerificationToken stores a single-use registration verification token.
+type EmailVerificationToken struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	UserID uint64 `gorm:"not null;index"`                 // Owning user ID.
+	User   *User  `gorm:"foreignKey:UserID"`              // Owning user record.
+	Token  string `gorm:"type:text;not null;uniqueIndex"` // SHA-256 hex digest of the emailed token.
+
+	ExpiresAt time.Time  `gorm:"not null"` // Expiration time.
+	UsedAt    *time.Time // Consumption time, if used.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// InviteCode represents a registration invite code.
+type InviteCode struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	Code      string     `gorm:"type:text;not null;uniqueIndex"` // Unique invite code.
+	Note      string     `gorm:"type:text"`                      // Optional admin note.
+	MaxUses   int        `gorm:"not null;default:1"`             // Maximum registrations (0 = unlimited).
+	UsedCount int        `gorm:"not null;default:0"`             // Registrations consumed so far.
+	ExpiresAt *time.Time // Expiration time, if any.
+
+	IsEnabled bool `gorm:"not null;default:true"` // Whether the code can be redeemed.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Job statuses.
+const (
+	// JobStatusQueued marks a job waiting for a worker.
+	JobStatusQueued = "queued"
+	// JobStatusRunning marks a job claimed by a worker.
+	JobStatusRunning = "running"
+	// JobStatusSucceeded marks a job that finished with a result.
+	JobStatusSucceeded = "succeeded"
+	// JobStatusFailed marks a job that returned an error or was interrupted.
+	JobStatusFailed = "failed"
+)
+
+// Job is a long-running admin task executed in the background by the jobs runner.
+type Job struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	Type     string `gorm:"type:text;not null;index"`                  // Registered job type.
+	Status   string `gorm:"type:text;not null;index;default:'queued'"` // Job status.
+	Progress int    `gorm:"not null;default:0"`                        // Completion in percent.
+
+	Params datatypes.JSON `gorm:"type:jsonb"` // Job input.
+	Result datatypes.JSON `gorm:"type:jsonb"` // Job output, set when the job succeeds.
+	Error  string         `gorm:"type:text"`  // Failure message, set when the job fails.
+
+	FileName        string `gorm:"type:text"` // Name of the file produced by the job, if any.
+	FileContentType string `gorm:"type:text"` // Content type of the produced file.
+	FileData        []byte // Produced file contents.
+
+	CreatedBy *uint64 `gorm:"index"` // Admin that queued the job.
+
+	StartedAt  *time.Time // When a worker claimed the job.
+	FinishedAt *time.Time `gorm:"index"` // When the job succeeded or failed.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;index;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"`       // Last update timestamp.
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 // ModelMapping maps provider model names to exposed names.
 type ModelMapping struct {
@@ -16,10 +20,20 @@ type ModelMapping struct {
 	Selector  int `gorm:"not null;default:0"` // Routing selector.
 	RateLimit int `gorm:"not null;default:0"` // Rate limit per second.
 
+	// TimeoutSeconds and MaxOutputTokens tune upstream requests for the exposed model;
+	// 0 inherits the default.
+	TimeoutSeconds  int `gorm:"not null;default:0"` // Upstream request timeout in seconds.
+	MaxOutputTokens int `gorm:"not null;default:0"` // Output token limit forced on requests.
+
 	UserGroupID UserGroupIDs `gorm:"type:jsonb;not null;default:'[]'"` // Allowed user group IDs.
 
+	// FallbackTargets lists ordered {"provider","model"} targets tried when every
+	// credential serving this mapping is cooling down; only used when FallbackEnabled is set.
+	FallbackEnabled bool           `gorm:"not null;default:false"` // Whether cooldown fallback is enabled.
+	FallbackTargets datatypes.JSON `gorm:"type:jsonb"`             // Ordered fallback targets.
+
 	IsEnabled bool `gorm:"not null;default:true"` // Whether mapping is active.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
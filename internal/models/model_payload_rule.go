@@ -17,6 +17,6 @@ type ModelPayloadRule struct {
 	IsEnabled      bool           `gorm:"not null;default:true;index"`                          // Whether rule is active.
 	Description    string         `gorm:"type:text"`                                            // Human-readable description.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -25,10 +25,10 @@ type ModelReference struct {
 	ContextOver200kCacheReadPrice  *float64 `gorm:"column:context_over_200k_cache_read_price;type:decimal(20,10)"`  // Cache read price beyond 200k context.
 	ContextOver200kCacheWritePrice *float64 `gorm:"column:context_over_200k_cache_write_price;type:decimal(20,10)"` // Cache write price beyond 200k context.
 
-	Extra      datatypes.JSON `gorm:"type:jsonb;not null;default:'{}'"` // Extra payload fields.
-	LastSeenAt time.Time      `gorm:"not null;index"`                   // Last sync timestamp.
-	CreatedAt  time.Time      `gorm:"not null;autoCreateTime"`          // Creation timestamp.
-	UpdatedAt  time.Time      `gorm:"not null;autoUpdateTime"`          // Update timestamp.
+	Extra      datatypes.JSON `gorm:"type:jsonb;not null;default:'{}'"`                  // Extra payload fields.
+	LastSeenAt time.Time      `gorm:"not null;index"`                                    // Last sync timestamp.
+	CreatedAt  time.Time      `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt  time.Time      `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Update timestamp.
 }
 
 // TableName overrides the default table name.
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Pending action statuses.
+const (
+	// PendingActionStatusPending marks an action waiting for a second admin.
+	PendingActionStatusPending = "pending"
+	// PendingActionStatusApproved marks an approved action while it executes.
+	PendingActionStatusApproved = "approved"
+	// PendingActionStatusExecuted marks an approved action that ran successfully.
+	PendingActionStatusExecuted = "executed"
+	// PendingActionStatusFailed marks an approved action whose execution returned an error.
+	PendingActionStatusFailed = "failed"
+	// PendingActionStatusRejected marks an action that was turned down or withdrawn.
+	PendingActionStatusRejected = "rejected"
+)
+
+// PendingAction is a destructive admin request held back until a second admin approves it.
+type PendingAction struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	Action     string `gorm:"type:text;not null;index"`                   // Action name from APPROVAL_REQUIRED_ACTIONS.
+	TargetID   uint64 `gorm:"not null"`                                   // ID of the record the action applies to.
+	Permission string `gorm:"type:text;not null"`                         // Permission key of the deferred route.
+	Status     string `gorm:"type:text;not null;index;default:'pending'"` // Pending action status.
+	Error      string `gorm:"type:text"`                                  // Execution failure message.
+
+	RequestedBy uint64     `gorm:"not null;index"` // Admin that made the request.
+	DecidedBy   *uint64    // Admin that approved or rejected the request.
+	DecidedAt   *time.Time // When the request was approved or rejected.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;index;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"`       // Last update timestamp.
+}
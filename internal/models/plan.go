@@ -28,8 +28,10 @@ type Plan struct {
 	DailyQuota float64 `gorm:"type:decimal(20,10);not null;default:0"` // Daily quota allocation.
 	RateLimit  int     `gorm:"not null;default:0"`                     // Rate limit per second.
 
+	AlertAtPercent int `gorm:"not null;default:0"` // Quota usage percent that alerts the plan's users (0 = off).
+
 	IsEnabled bool `gorm:"not null;default:true"` // Whether the plan is active.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -21,6 +21,6 @@ type PrepaidCard struct {
 
 	UserGroupID *uint64 `gorm:"index"` // User group scope for deductions, if any.
 
-	CreatedAt  time.Time  `gorm:"not null;autoCreateTime"` // Creation timestamp.
+	CreatedAt  time.Time  `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
 	RedeemedAt *time.Time // Redemption time, if redeemed.
 }
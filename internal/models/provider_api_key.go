@@ -23,6 +23,12 @@ type ProviderAPIKey struct {
 	ExcludedModels datatypes.JSON `gorm:"type:jsonb"` // Excluded models list.
 	APIKeyEntries  datatypes.JSON `gorm:"type:jsonb"` // Nested API key entries.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	Tags Tags `gorm:"type:jsonb;not null;default:'[]'"` // Normalized labels such as owner or team.
+
+	Degraded         bool       `gorm:"not null;default:false;index"` // Excluded from the config after repeated upstream auth failures.
+	AuthFailureCount int        `gorm:"not null;default:0"`           // Consecutive upstream 401/403 responses.
+	DegradedAt       *time.Time // When the key was degraded, if it is.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -7,6 +7,6 @@ type Proxy struct {
 	ID       uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
 	ProxyURL string `gorm:"type:text;not null"`       // Proxy URL.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// QuotaHistory stores one point-in-time quota snapshot of an auth entry. Remaining and
+// Limit are percentages because providers only report the fraction of capacity left.
+type QuotaHistory struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	AuthID     uint64    `gorm:"not null;index:idx_quota_history_auth_captured,priority:1"`       // Related auth ID.
+	CapturedAt time.Time `gorm:"not null;index:idx_quota_history_auth_captured,priority:2;index"` // Snapshot time.
+
+	Remaining float64    `gorm:"not null;default:0"`                    // Remaining capacity.
+	Limit     float64    `gorm:"column:quota_limit;not null;default:0"` // Total capacity.
+	ResetAt   *time.Time // Next capacity reset, if known.
+}
+
+// TableName overrides the default table name.
+func (QuotaHistory) TableName() string {
+	return "quota_history"
+}
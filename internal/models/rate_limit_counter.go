@@ -0,0 +1,8 @@
+package models
+
+// RateLimitCounter stores the current fixed-window hit count for a rate limit key.
+type RateLimitCounter struct {
+	LimitKey    string `gorm:"type:text;primaryKey"` // Limiter key, e.g. "u:1".
+	WindowStart int64  `gorm:"not null;index"`       // Window start as Unix seconds.
+	Hits        int64  `gorm:"not null;default:0"`   // Hits recorded in the window.
+}
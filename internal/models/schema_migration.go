@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SchemaMigration records a versioned migration step that has been applied.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"` // Step version.
+	Name      string    `gorm:"type:text;not null"`             // Step name.
+	AppliedAt time.Time `gorm:"not null"`                       // When the step was applied or marked.
+}
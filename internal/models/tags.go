@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTags caps the number of tags on one record.
+	MaxTags = 20
+	// MaxTagLength caps the length of a single tag in characters.
+	MaxTagLength = 32
+)
+
+// Tags stores free-form labels as a JSON array of strings.
+type Tags []string
+
+// Value implements driver.Valuer for database serialization.
+func (t Tags) Value() (driver.Value, error) {
+	values := []string(t)
+	if values == nil {
+		values = []string{}
+	}
+	data, errMarshal := json.Marshal(values)
+	if errMarshal != nil {
+		return nil, fmt.Errorf("tags marshal: %w", errMarshal)
+	}
+	return data, nil
+}
+
+// Scan implements sql.Scanner for database deserialization.
+func (t *Tags) Scan(value any) error {
+	if t == nil {
+		return fmt.Errorf("tags scan: nil receiver")
+	}
+	var data []byte
+	switch typed := value.(type) {
+	case nil:
+		*t = Tags{}
+		return nil
+	case []byte:
+		data = typed
+	case string:
+		data = []byte(typed)
+	default:
+		return fmt.Errorf("tags scan: unsupported type %T", value)
+	}
+	if len(data) == 0 {
+		*t = Tags{}
+		return nil
+	}
+	var list []string
+	if errUnmarshal := json.Unmarshal(data, &list); errUnmarshal != nil {
+		return fmt.Errorf("tags scan: invalid json")
+	}
+	*t = Tags(list)
+	return nil
+}
+
+// NormalizeTags trims, lowercases and deduplicates raw, dropping empty values. It fails
+// when more than MaxTags remain or a tag is longer than MaxTagLength characters.
+func NormalizeTags(raw []string) (Tags, error) {
+	out := make(Tags, 0, len(raw))
+	seen := make(map[string]struct{}, len(raw))
+	for _, tag := range raw {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, fmt.Errorf("tag %q exceeds %d characters", tag, MaxTagLength)
+		}
+		seen[tag] = struct{}{}
+		out = append(out, tag)
+	}
+	if len(out) > MaxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", MaxTags)
+	}
+	return out, nil
+}
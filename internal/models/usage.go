@@ -10,20 +10,28 @@ import (
 type Usage struct {
 	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
 
+	IdempotencyKey *string `gorm:"type:text;uniqueIndex"` // Per-record key that keeps retried writes from double-charging.
+	RequestID      *string `gorm:"type:text;index"`       // X-Request-ID of the API request, when known.
+
 	Provider string `gorm:"type:text;not null;index"` // Provider name.
 	Model    string `gorm:"type:text;not null;index"` // Model name.
 
-	UserID      *uint64 `gorm:"index"` // Related user ID.
-	UserGroupID *uint64 `gorm:"index"` // Billing user group ID, when available.
-	APIKeyID    *uint64 `gorm:"index"` // Related API key ID.
-	AuthID      *uint64 `gorm:"index"` // Related auth ID.
+	RequestedModel string `gorm:"type:text"` // Model sent by the client, before overrides and fallbacks.
+
+	UserID      *uint64 `gorm:"index;index:idx_usages_requested_user_cost,priority:2"` // Related user ID.
+	UserGroupID *uint64 `gorm:"index"`                                                 // Billing user group ID, when available.
+	APIKeyID    *uint64 `gorm:"index"`                                                 // Related API key ID.
+	AuthID      *uint64 `gorm:"index"`                                                 // Related auth ID.
 
 	AuthKey   string `gorm:"type:text;index"` // Auth key value.
 	AuthIndex string `gorm:"type:text"`       // Auth index identifier.
 	Source    string `gorm:"type:text"`       // Usage source marker.
 
-	RequestedAt time.Time `gorm:"not null;index"`         // Request timestamp.
-	Failed      bool      `gorm:"not null;default:false"` // Failure flag.
+	RequestedAt time.Time `gorm:"not null;index;index:idx_usages_requested_user_cost,priority:1"` // Request timestamp.
+	Failed      bool      `gorm:"not null;default:false"`                                         // Failure flag.
+	Stream      bool      `gorm:"not null;default:false;index"`                                   // Whether the response was streamed.
+
+	DurationMs *int64 // Milliseconds from the upstream request start until usage was reported; nil when unknown.
 
 	ErrorStatusCode *int           `gorm:"index"`      // HTTP status code for failed requests.
 	ErrorDetail     datatypes.JSON `gorm:"type:jsonb"` // Structured error detail JSON.
@@ -34,7 +42,11 @@ type Usage struct {
 	CachedTokens    int64 `gorm:"not null;default:0"` // Cached token count.
 	TotalTokens     int64 `gorm:"not null;default:0"` // Total token count.
 
-	CostMicros int64 `gorm:"not null;default:0"` // Cost in micros.
+	CostMicros         int64  `gorm:"not null;default:0;index:idx_usages_requested_user_cost,priority:3"` // Cost in micros.
+	ComputedCostMicros int64  `gorm:"not null;default:0"`                                                 // Rule-based cost estimate in micros, kept for reconciliation.
+	UpstreamCostMicros *int64 // Provider-reported cost in micros, when the response carried one.
+
+	Unbilled bool `gorm:"not null;default:false;index:idx_usages_unbilled,where:unbilled = true"` // Cost recorded in deferred billing mode that the billing reconciler has not charged yet.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Usage alert kinds.
+const (
+	// UsageAlertKindDaily fires when today's usage nears the daily bill quota.
+	UsageAlertKindDaily = "daily_quota"
+	// UsageAlertKindTotal fires when used bill quota nears the total bill quota.
+	UsageAlertKindTotal = "total_quota"
+)
+
+// UsageAlert records a quota alert sent to a user. The unique index makes each
+// threshold crossing fire at most once per user, kind and day.
+type UsageAlert struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	UserID    uint64 `gorm:"not null;uniqueIndex:idx_usage_alerts_once,priority:1"`           // Alerted user ID.
+	Kind      string `gorm:"type:text;not null;uniqueIndex:idx_usage_alerts_once,priority:2"` // Alert kind: daily_quota or total_quota.
+	Threshold int    `gorm:"not null;uniqueIndex:idx_usage_alerts_once,priority:3"`           // Percent threshold that was crossed.
+	Day       string `gorm:"type:text;not null;uniqueIndex:idx_usage_alerts_once,priority:4"` // Local day (YYYY-MM-DD) the alert fired.
+
+	UsedAmount  float64 `gorm:"type:decimal(20,10);not null;default:0"` // Quota used when the alert fired.
+	LimitAmount float64 `gorm:"type:decimal(20,10);not null;default:0"` // Quota limit the usage was compared to.
+	Percent     float64 `gorm:"type:decimal(10,4);not null;default:0"`  // Used share of the limit in percent.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;index;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UsageDailyRollup aggregates usage for one UTC day per user, provider, model and source.
+type UsageDailyRollup struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
+
+	Day      time.Time `gorm:"not null;uniqueIndex:idx_usage_daily_rollups_key,priority:1"`                      // UTC day start.
+	UserID   uint64    `gorm:"not null;default:0;uniqueIndex:idx_usage_daily_rollups_key,priority:2"`            // Related user ID; 0 when none.
+	Provider string    `gorm:"type:text;not null;uniqueIndex:idx_usage_daily_rollups_key,priority:3"`            // Provider name.
+	Model    string    `gorm:"type:text;not null;uniqueIndex:idx_usage_daily_rollups_key,priority:4"`            // Model name.
+	Source   string    `gorm:"type:text;not null;default:'';uniqueIndex:idx_usage_daily_rollups_key,priority:5"` // Usage source marker.
+
+	RequestCount    int64 `gorm:"not null;default:0"` // Request count.
+	ErrorCount      int64 `gorm:"not null;default:0"` // Failed request count.
+	InputTokens     int64 `gorm:"not null;default:0"` // Input token total.
+	OutputTokens    int64 `gorm:"not null;default:0"` // Output token total.
+	ReasoningTokens int64 `gorm:"not null;default:0"` // Reasoning token total.
+	CachedTokens    int64 `gorm:"not null;default:0"` // Cached token total.
+	TotalTokens     int64 `gorm:"not null;default:0"` // Total token count.
+	CostMicros      int64 `gorm:"not null;default:0"` // Cost in micros.
+	FailedCost      int64 `gorm:"not null;default:0"` // Cost in micros of failed requests.
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
+}
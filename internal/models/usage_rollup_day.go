@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// UsageRollupDay marks a UTC day whose usage has been rolled up into usage_daily_rollups.
+type UsageRollupDay struct {
+	Day        time.Time `gorm:"primaryKey"`              // UTC day start.
+	MaxUsageID uint64    `gorm:"not null;default:0"`      // Highest usage ID covered; later rows are merged incrementally.
+	RolledUpAt time.Time `gorm:"not null;autoUpdateTime"` // Last time the day was rolled up or merged.
+}
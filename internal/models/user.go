@@ -20,11 +20,17 @@ type User struct {
 	Plan   *Plan   `gorm:"foreignKey:PlanID"` // Active plan.
 
 	DailyMaxUsage float64 `gorm:"type:decimal(20,10);not null;default:0"` // Daily usage cap.
+	DailySpendCap float64 `gorm:"type:decimal(20,10);not null;default:0"` // Hard daily spend cap across bills and prepaid balance (0 = unlimited).
+	OverageDebt   float64 `gorm:"type:decimal(20,10);not null;default:0"` // Usage cost not covered by bills or prepaid cards.
 	RateLimit     int     `gorm:"not null;default:0"`                     // Rate limit per second.
 
+	AlertAtPercent int `gorm:"not null;default:0"` // Quota usage percent that triggers an alert (0 = use the plan's).
+
 	Active   bool `gorm:"not null;default:true"`  // Whether the user can sign in.
 	Disabled bool `gorm:"not null;default:false"` // Explicit disable flag.
 
+	EmailVerifiedAt *time.Time // Time the registration email was confirmed, if any.
+
 	TOTPSecret            string  `gorm:"type:text"`    // TOTP secret for MFA.
 	PasskeyID             []byte  `gorm:"type:bytea"`   // WebAuthn credential ID.
 	PasskeyPublicKey      []byte  `gorm:"type:bytea"`   // WebAuthn public key bytes.
@@ -34,6 +40,18 @@ type User struct {
 
 	APIKeys []APIKey `gorm:"foreignKey:UserID"` // Related API keys.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
+}
+
+// SignInBlock returns the reason u may not sign in or use API keys, or "" when it may.
+func (u *User) SignInBlock() string {
+	switch {
+	case u.Disabled:
+		return "user disabled"
+	case !u.Active:
+		return "email not verified"
+	default:
+		return ""
+	}
 }
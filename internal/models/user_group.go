@@ -2,6 +2,16 @@ package models
 
 import "time"
 
+// Overage policies decide what happens once a user's bills and prepaid cards are exhausted.
+const (
+	// OveragePolicyBlock rejects requests once the balance hits zero.
+	OveragePolicyBlock = "block"
+	// OveragePolicyAllowGrace keeps serving requests until the accrued debt reaches the grace amount.
+	OveragePolicyAllowGrace = "allow_grace"
+	// OveragePolicyPayAsYouGo keeps serving requests and accrues the uncovered cost as debt.
+	OveragePolicyPayAsYouGo = "pay_as_you_go"
+)
+
 // UserGroup groups users for access and billing rules.
 type UserGroup struct {
 	ID uint64 `gorm:"primaryKey;autoIncrement"` // Primary key.
@@ -10,8 +20,15 @@ type UserGroup struct {
 	IsDefault bool   `gorm:"not null;default:false"`         // Marks the default group.
 	RateLimit int    `gorm:"not null;default:0"`             // Rate limit per second.
 
+	ModelPrefix string `gorm:"type:text;not null;default:''"` // Prefix shown on model names to members; empty shows canonical names.
+
+	OveragePolicy string  `gorm:"type:text;not null;default:'block'"`     // What happens once members run out of balance.
+	OverageGrace  float64 `gorm:"type:decimal(20,10);not null;default:0"` // Debt members may run up under allow_grace.
+
+	ParentID *uint64 `gorm:"index"` // Group whose billing rules apply when this group has none.
+
 	Users []User `gorm:"-"` // Related users (not persisted).
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -10,6 +10,6 @@ type UserModelAuthBinding struct {
 	ModelMappingID uint64 `gorm:"not null;uniqueIndex:idx_user_model_auth_bindings_user_model,priority:2;index"` // Bound model mapping ID.
 	AuthIndex      string `gorm:"type:varchar(64);not null"`                                                     // Bound auth index.
 
-	CreatedAt time.Time `gorm:"not null;autoCreateTime"` // Creation timestamp.
-	UpdatedAt time.Time `gorm:"not null;autoUpdateTime"` // Last update timestamp.
+	CreatedAt time.Time `gorm:"not null;autoCreateTime;default:CURRENT_TIMESTAMP"` // Creation timestamp.
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime;default:CURRENT_TIMESTAMP"` // Last update timestamp.
 }
@@ -0,0 +1,86 @@
+package payloadrule
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ErrInvalidPayload indicates the sample payload is not a JSON document.
+var ErrInvalidPayload = errors.New("payload must be valid JSON")
+
+// Change records how one entry affected a previewed payload.
+type Change struct {
+	Path     string `json:"path"`             // Entry path.
+	RuleType string `json:"rule_type"`        // Normalized rule type.
+	Applied  bool   `json:"applied"`          // Whether the payload was modified.
+	Reason   string `json:"reason,omitempty"` // Why the entry was skipped.
+}
+
+// Apply runs entries against payload the same way the proxy does: defaults only fill
+// paths missing from the original payload, then overrides replace values.
+func Apply(entries []Entry, payload []byte) ([]byte, []Change, error) {
+	if !gjson.ValidBytes(payload) {
+		return nil, nil, ErrInvalidPayload
+	}
+	out := append([]byte(nil), payload...)
+	changes := make([]Change, 0, len(entries))
+	appliedDefaults := make(map[string]struct{})
+
+	apply := func(entry Entry, ruleType string) {
+		path := strings.TrimSpace(entry.Path)
+		change := Change{Path: path, RuleType: ruleType}
+		defer func() { changes = append(changes, change) }()
+		if path == "" {
+			change.Reason = "empty path"
+			return
+		}
+		if ruleType == RuleTypeDefault {
+			if gjson.GetBytes(payload, path).Exists() {
+				change.Reason = "path already set in payload"
+				return
+			}
+			if _, ok := appliedDefaults[path]; ok {
+				change.Reason = "path already set by an earlier default"
+				return
+			}
+		}
+		var (
+			updated []byte
+			errSet  error
+		)
+		if strings.EqualFold(strings.TrimSpace(entry.ValueType), ValueTypeJSON) {
+			raw, ok := RawValue(entry.Value)
+			if !ok {
+				change.Reason = "value is not valid JSON"
+				return
+			}
+			updated, errSet = sjson.SetRawBytes(out, path, raw)
+		} else {
+			updated, errSet = sjson.SetBytes(out, path, entry.Value)
+		}
+		if errSet != nil {
+			change.Reason = errSet.Error()
+			return
+		}
+		out = updated
+		change.Applied = true
+		if ruleType == RuleTypeDefault {
+			appliedDefaults[path] = struct{}{}
+		}
+	}
+
+	for _, entry := range entries {
+		if !strings.EqualFold(strings.TrimSpace(entry.RuleType), RuleTypeOverride) {
+			apply(entry, RuleTypeDefault)
+		}
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(strings.TrimSpace(entry.RuleType), RuleTypeOverride) {
+			apply(entry, RuleTypeOverride)
+		}
+	}
+	return out, changes, nil
+}
@@ -0,0 +1,235 @@
+// Package payloadrule parses, validates and previews model payload rule params.
+package payloadrule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"gorm.io/datatypes"
+)
+
+// Rule types accepted in payload params.
+const (
+	// RuleTypeDefault sets a value only when the request does not already carry it.
+	RuleTypeDefault = "default"
+	// RuleTypeOverride always sets the value.
+	RuleTypeOverride = "override"
+)
+
+// ValueTypeJSON marks an entry whose value is raw JSON rather than a plain value.
+const ValueTypeJSON = "json"
+
+// Entry is a single payload param: a path, how to apply it, and the value.
+type Entry struct {
+	Path      string `json:"path"`       // gjson/sjson-style path.
+	RuleType  string `json:"rule_type"`  // "default" or "override".
+	ValueType string `json:"value_type"` // Empty or "json".
+	Value     any    `json:"value"`      // Value to set.
+}
+
+// knownProtocols lists the translator formats a payload rule may be restricted to.
+var knownProtocols = map[string]struct{}{
+	sdktranslator.FormatOpenAI.String():         {},
+	sdktranslator.FormatOpenAIResponse.String(): {},
+	sdktranslator.FormatClaude.String():         {},
+	sdktranslator.FormatGemini.String():         {},
+	sdktranslator.FormatGeminiCLI.String():      {},
+	sdktranslator.FormatCodex.String():          {},
+	sdktranslator.FormatAntigravity.String():    {},
+}
+
+// IsKnownProtocol reports whether protocol is empty or a known translator format.
+func IsKnownProtocol(protocol string) bool {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if protocol == "" {
+		return true
+	}
+	_, ok := knownProtocols[protocol]
+	return ok
+}
+
+// KnownProtocols returns the known protocol names in sorted order.
+func KnownProtocols() []string {
+	out := make([]string, 0, len(knownProtocols))
+	for name := range knownProtocols {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParseEntries parses params stored as either an entry list or a path-keyed object.
+func ParseEntries(raw datatypes.JSON) ([]Entry, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	switch trimmed[0] {
+	case '[':
+		var entries []Entry
+		if errUnmarshal := json.Unmarshal(trimmed, &entries); errUnmarshal != nil {
+			return nil, errUnmarshal
+		}
+		return entries, nil
+	case '{':
+		var obj map[string]any
+		if errUnmarshal := json.Unmarshal(trimmed, &obj); errUnmarshal != nil {
+			return nil, errUnmarshal
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]Entry, 0, len(obj))
+		for _, key := range keys {
+			value := obj[key]
+			entry := Entry{Path: key, RuleType: RuleTypeDefault}
+			if nested, ok := value.(map[string]any); ok {
+				if rt, okRule := nested["rule_type"].(string); okRule {
+					entry.RuleType = rt
+				}
+				if vt, okValueType := nested["value_type"].(string); okValueType {
+					entry.ValueType = vt
+				}
+				if v, okValue := nested["value"]; okValue {
+					entry.Value = v
+				} else {
+					entry.Value = value
+				}
+			} else {
+				entry.Value = value
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	default:
+		return nil, nil
+	}
+}
+
+// FieldError describes a validation failure for one field of the params.
+type FieldError struct {
+	Field   string `json:"field"`   // Location of the invalid field, e.g. params[0].rule_type.
+	Message string `json:"message"` // Human-readable problem description.
+}
+
+// Error implements error.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Validate checks params and protocol and returns every field-level problem found.
+func Validate(protocol string, raw json.RawMessage) []FieldError {
+	var errs []FieldError
+	if !IsKnownProtocol(protocol) {
+		errs = append(errs, FieldError{
+			Field:   "protocol",
+			Message: fmt.Sprintf("must be one of %s", strings.Join(KnownProtocols(), ", ")),
+		})
+	}
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return errs
+	}
+	if !json.Valid(trimmed) {
+		return append(errs, FieldError{Field: "params", Message: "must be valid JSON"})
+	}
+	if trimmed[0] != '[' && trimmed[0] != '{' {
+		return append(errs, FieldError{Field: "params", Message: "must be an array of entries or an object keyed by path"})
+	}
+	entries, errParse := ParseEntries(datatypes.JSON(trimmed))
+	if errParse != nil {
+		return append(errs, FieldError{Field: "params", Message: "entries must be objects with path, rule_type, value_type and value"})
+	}
+	objectForm := trimmed[0] == '{'
+	for i, entry := range entries {
+		prefix := fmt.Sprintf("params[%d]", i)
+		if objectForm {
+			prefix = "params." + entry.Path
+		}
+		errs = append(errs, validateEntry(prefix, entry)...)
+	}
+	return errs
+}
+
+// validateEntry checks a single entry.
+func validateEntry(prefix string, entry Entry) []FieldError {
+	var errs []FieldError
+	if errPath := validatePath(entry.Path); errPath != "" {
+		errs = append(errs, FieldError{Field: prefix + ".path", Message: errPath})
+	}
+	switch strings.ToLower(strings.TrimSpace(entry.RuleType)) {
+	case RuleTypeDefault, RuleTypeOverride:
+	default:
+		errs = append(errs, FieldError{Field: prefix + ".rule_type", Message: `must be "default" or "override"`})
+	}
+	switch strings.ToLower(strings.TrimSpace(entry.ValueType)) {
+	case "":
+	case ValueTypeJSON:
+		if _, ok := RawValue(entry.Value); !ok {
+			errs = append(errs, FieldError{Field: prefix + ".value", Message: "must be valid JSON when value_type is json"})
+		}
+	default:
+		errs = append(errs, FieldError{Field: prefix + ".value_type", Message: `must be empty or "json"`})
+	}
+	return errs
+}
+
+// validatePath checks that path is a settable sjson path and returns a message if not.
+func validatePath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "is required"
+	}
+	if strings.ContainsAny(path, "*?#|@") {
+		return "must not contain wildcards, queries or modifiers"
+	}
+	escaped := false
+	segment := 0
+	for _, r := range path {
+		switch {
+		case escaped:
+			escaped = false
+			segment++
+		case r == '\\':
+			escaped = true
+		case r == '.':
+			if segment == 0 {
+				return "must not contain empty segments"
+			}
+			segment = 0
+		default:
+			segment++
+		}
+	}
+	if escaped || segment == 0 {
+		return "must not end with a separator or escape"
+	}
+	return ""
+}
+
+// RawValue returns the raw JSON for an entry whose value_type is json. String values
+// must themselves hold valid JSON; other values are marshaled as-is.
+func RawValue(value any) ([]byte, bool) {
+	switch typed := value.(type) {
+	case nil:
+		return []byte("null"), true
+	case string:
+		trimmed := strings.TrimSpace(typed)
+		if !json.Valid([]byte(trimmed)) {
+			return nil, false
+		}
+		return []byte(trimmed), true
+	default:
+		data, errMarshal := json.Marshal(typed)
+		if errMarshal != nil {
+			return nil, false
+		}
+		return data, true
+	}
+}
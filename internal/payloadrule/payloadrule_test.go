@@ -0,0 +1,67 @@
+package payloadrule
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateReportsFieldErrors(t *testing.T) {
+	errs := Validate("openai", json.RawMessage(`[
+		{"path":"temperature","rule_type":"overide","value":0},
+		{"path":"","rule_type":"default","value":1},
+		{"path":"tools","rule_type":"override","value_type":"json","value":"[{"},
+		{"path":"a..b","rule_type":"default","value_type":"yaml","value":1}
+	]`))
+	want := map[string]bool{
+		"params[0].rule_type":  true,
+		"params[1].path":       true,
+		"params[2].value":      true,
+		"params[3].path":       true,
+		"params[3].value_type": true,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for _, e := range errs {
+		if !want[e.Field] {
+			t.Fatalf("unexpected field error %v", e)
+		}
+	}
+
+	if errs = Validate("responses", json.RawMessage(`{"temperature":0}`)); len(errs) != 1 || errs[0].Field != "protocol" {
+		t.Fatalf("expected protocol error, got %v", errs)
+	}
+	if errs = Validate("", json.RawMessage(`{"reasoning.effort":{"rule_type":"override","value":"high"}}`)); len(errs) != 0 {
+		t.Fatalf("expected object form to validate, got %v", errs)
+	}
+}
+
+func TestApplyDefaultsThenOverrides(t *testing.T) {
+	entries := []Entry{
+		{Path: "temperature", RuleType: RuleTypeDefault, Value: 0.2},
+		{Path: "top_p", RuleType: RuleTypeDefault, Value: 0.9},
+		{Path: "max_tokens", RuleType: RuleTypeOverride, Value: 100},
+		{Path: "metadata", RuleType: RuleTypeOverride, ValueType: ValueTypeJSON, Value: `{"team":"a"}`},
+	}
+	out, changes, errApply := Apply(entries, []byte(`{"temperature":1,"max_tokens":5}`))
+	if errApply != nil {
+		t.Fatalf("apply: %v", errApply)
+	}
+	var got map[string]any
+	if errDecode := json.Unmarshal(out, &got); errDecode != nil {
+		t.Fatalf("decode: %v", errDecode)
+	}
+	if got["temperature"] != 1.0 || got["top_p"] != 0.9 || got["max_tokens"] != 100.0 {
+		t.Fatalf("unexpected payload %s", out)
+	}
+	if meta, ok := got["metadata"].(map[string]any); !ok || meta["team"] != "a" {
+		t.Fatalf("expected raw metadata, got %s", out)
+	}
+	if len(changes) != 4 || changes[0].Applied || !changes[1].Applied {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+
+	if _, _, errApply = Apply(entries, []byte(`not json`)); errApply != ErrInvalidPayload {
+		t.Fatalf("expected invalid payload error, got %v", errApply)
+	}
+}
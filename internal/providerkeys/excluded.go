@@ -0,0 +1,46 @@
+package providerkeys
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// ExcludedModelsAttribute is the auth attribute that carries a key's excluded_models
+// patterns for providers whose exclusions the SDK does not apply itself.
+const ExcludedModelsAttribute = "excluded_models"
+
+// EncodeExcludedModels lowercases, dedupes and sorts patterns into an attribute value.
+func EncodeExcludedModels(patterns []string) string {
+	seen := make(map[string]struct{}, len(patterns))
+	out := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" || strings.Contains(pattern, ",") {
+			continue
+		}
+		if _, ok := seen[pattern]; ok {
+			continue
+		}
+		seen[pattern] = struct{}{}
+		out = append(out, pattern)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+// ExcludesModel reports whether the excluded_models patterns stored in attrs match model.
+// Patterns use path.Match syntax and compare case-insensitively.
+func ExcludesModel(attrs map[string]string, model string) bool {
+	raw := strings.TrimSpace(attrs[ExcludedModelsAttribute])
+	if raw == "" {
+		return false
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, pattern := range strings.Split(raw, ",") {
+		if matched, errMatch := path.Match(pattern, model); errMatch == nil && matched {
+			return true
+		}
+	}
+	return false
+}
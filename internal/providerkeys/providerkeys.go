@@ -15,6 +15,8 @@ const (
 	providerCodex  = "codex"
 	providerClaude = "claude"
 	providerOpenAI = "openai-compatibility"
+	providerQwen   = "qwen"
+	providerIFlow  = "iflow"
 )
 
 var providerAliases = map[string]string{
@@ -24,6 +26,8 @@ var providerAliases = map[string]string{
 	"claude-code":          providerClaude,
 	"openai":               providerOpenAI,
 	"openai-compatibility": providerOpenAI,
+	"qwen":                 providerQwen,
+	"iflow":                providerIFlow,
 }
 
 type apiKeyEntry struct {
@@ -31,10 +35,42 @@ type apiKeyEntry struct {
 	ProxyURL string `json:"proxy_url"`
 }
 
+// ModelAlias maps an upstream model name to the alias exposed to clients.
+type ModelAlias struct {
+	Name  string `json:"name"`
+	Alias string `json:"alias"`
+}
+
+// GetName returns the upstream model name.
+func (m ModelAlias) GetName() string { return m.Name }
+
+// GetAlias returns the client-facing alias.
+func (m ModelAlias) GetAlias() string { return m.Alias }
+
+// ExtraKey is an API key for a provider that has no section in the SDK config.
+type ExtraKey struct {
+	APIKey         string
+	Priority       int
+	Prefix         string
+	BaseURL        string
+	ProxyURL       string
+	Headers        map[string]string
+	Models         []ModelAlias
+	ExcludedModels []string
+}
+
+// ExtraKeys holds the Qwen and iFlow API keys that ApplyToConfig cannot store on the SDK config.
+type ExtraKeys struct {
+	Qwen  []ExtraKey
+	IFlow []ExtraKey
+}
+
 // ApplyToConfig refreshes provider API key and OAuth model mapping sections on the given SDK config.
-func ApplyToConfig(cfg *sdkconfig.Config, providerRows []models.ProviderAPIKey, mappingRows []models.ModelMapping) {
+// Qwen and iFlow keys are returned separately because the SDK config has no place for them.
+func ApplyToConfig(cfg *sdkconfig.Config, providerRows []models.ProviderAPIKey, mappingRows []models.ModelMapping) ExtraKeys {
+	var extra ExtraKeys
 	if cfg == nil {
-		return
+		return extra
 	}
 
 	geminiKeys := make([]sdkconfig.GeminiKey, 0)
@@ -101,6 +137,14 @@ func ApplyToConfig(cfg *sdkconfig.Config, providerRows []models.ProviderAPIKey,
 			if entry.BaseURL != "" && entry.Name != "" {
 				openAIProviders = append(openAIProviders, entry)
 			}
+		case providerQwen:
+			if entry := toExtraKey(row); entry.APIKey != "" {
+				extra.Qwen = append(extra.Qwen, entry)
+			}
+		case providerIFlow:
+			if entry := toExtraKey(row); entry.APIKey != "" && entry.BaseURL != "" {
+				extra.IFlow = append(extra.IFlow, entry)
+			}
 		}
 	}
 
@@ -114,6 +158,21 @@ func ApplyToConfig(cfg *sdkconfig.Config, providerRows []models.ProviderAPIKey,
 	cfg.SanitizeCodexKeys()
 	cfg.SanitizeClaudeKeys()
 	cfg.SanitizeOpenAICompatibility()
+	return extra
+}
+
+func toExtraKey(row *models.ProviderAPIKey) ExtraKey {
+	entry := ExtraKey{
+		APIKey:         strings.TrimSpace(row.APIKey),
+		Priority:       row.Priority,
+		Prefix:         strings.TrimSpace(row.Prefix),
+		BaseURL:        strings.TrimSpace(row.BaseURL),
+		ProxyURL:       strings.TrimSpace(row.ProxyURL),
+		Headers:        decodeHeaders(row.Headers),
+		ExcludedModels: decodeExcludedModels(row.ExcludedModels),
+	}
+	applyJSON(row.Models, &entry.Models)
+	return entry
 }
 
 func normalizeProvider(value string) string {
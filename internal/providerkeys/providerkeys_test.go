@@ -61,3 +61,24 @@ func TestApplyToConfig_OAuthModelAlias(t *testing.T) {
 		t.Fatalf("unexpected mapping: %+v", mappings[0])
 	}
 }
+
+func TestApplyToConfig_QwenAndIFlow(t *testing.T) {
+	rows := []models.ProviderAPIKey{
+		{Provider: "qwen", APIKey: "qwen-key", Priority: 2},
+		{Provider: "iflow", APIKey: "iflow-key", BaseURL: "https://apis.iflow.cn/v1"},
+		{Provider: "iflow", APIKey: "missing-base"},
+	}
+
+	cfg := &sdkconfig.Config{}
+	extra := ApplyToConfig(cfg, rows, nil)
+
+	if len(extra.Qwen) != 1 || extra.Qwen[0].APIKey != "qwen-key" || extra.Qwen[0].Priority != 2 {
+		t.Fatalf("unexpected qwen keys: %+v", extra.Qwen)
+	}
+	if len(extra.IFlow) != 1 || extra.IFlow[0].BaseURL != "https://apis.iflow.cn/v1" {
+		t.Fatalf("unexpected iflow keys: %+v", extra.IFlow)
+	}
+	if len(cfg.OpenAICompatibility) != 0 {
+		t.Fatalf("expected no openai-compatibility entries, got %d", len(cfg.OpenAICompatibility))
+	}
+}
@@ -0,0 +1,214 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// historyPruneInterval is how often expired quota history rows are deleted.
+	historyPruneInterval = time.Hour
+	// historyResetTolerance absorbs reset times derived from "reset after N seconds"
+	// values, which drift by the poll latency between snapshots.
+	historyResetTolerance = time.Minute
+	// historyPercentEpsilon treats tiny remaining changes as unchanged.
+	historyPercentEpsilon = 0.01
+)
+
+// quotaSnapshot reduces a provider quota payload to the remaining share of its tightest
+// limit. Codex reports used percentages per window; Gemini CLI and Antigravity report a
+// remaining fraction per model bucket. The snapshot is false when nothing is recognized.
+func quotaSnapshot(payload []byte, fetchedAt time.Time) (models.QuotaHistory, bool) {
+	var parsed map[string]any
+	if errUnmarshal := json.Unmarshal(payload, &parsed); errUnmarshal != nil {
+		return models.QuotaHistory{}, false
+	}
+	if rateLimit, ok := parsed["rate_limit"].(map[string]any); ok {
+		return codexSnapshot(rateLimit, fetchedAt)
+	}
+	if buckets, ok := parsed["buckets"].([]any); ok {
+		return bucketSnapshot(buckets)
+	}
+	if modelsRaw, ok := parsed["models"].(map[string]any); ok {
+		buckets := make([]any, 0, len(modelsRaw))
+		for _, entry := range modelsRaw {
+			if info := mapFromAny(mapFromAny(entry)["quotaInfo"]); info != nil {
+				buckets = append(buckets, info)
+			}
+		}
+		return bucketSnapshot(buckets)
+	}
+	return models.QuotaHistory{}, false
+}
+
+// codexSnapshot uses the most consumed Codex usage window.
+func codexSnapshot(rateLimit map[string]any, fetchedAt time.Time) (models.QuotaHistory, bool) {
+	found := false
+	maxUsed := 0.0
+	var resetAt *time.Time
+	for _, key := range []string{"primary_window", "secondary_window"} {
+		window := mapFromAny(rateLimit[key])
+		used, ok := snapshotNumber(window["used_percent"])
+		if !ok {
+			continue
+		}
+		if found && used <= maxUsed {
+			continue
+		}
+		found = true
+		maxUsed = used
+		resetAt = nil
+		if reset, okReset := snapshotNumber(window["reset_at"]); okReset && reset > 0 {
+			t := time.Unix(int64(reset), 0).UTC()
+			resetAt = &t
+		} else if after, okAfter := snapshotNumber(window["reset_after_seconds"]); okAfter && after >= 0 {
+			t := fetchedAt.UTC().Add(time.Duration(after) * time.Second)
+			resetAt = &t
+		}
+	}
+	if !found {
+		return models.QuotaHistory{}, false
+	}
+	return models.QuotaHistory{Remaining: math.Max(0, 100-maxUsed), Limit: 100, ResetAt: resetAt}, true
+}
+
+// bucketSnapshot uses the bucket with the least remaining capacity. A bucket with a reset
+// time but no remainingFraction is exhausted: the upstream omits zero values.
+func bucketSnapshot(buckets []any) (models.QuotaHistory, bool) {
+	found := false
+	minRemaining := 0.0
+	var resetAt *time.Time
+	for _, raw := range buckets {
+		bucket := mapFromAny(raw)
+		if bucket == nil {
+			continue
+		}
+		reset, okReset := snapshotTime(bucket["resetTime"])
+		remaining, okRemaining := snapshotNumber(bucket["remainingFraction"])
+		if !okRemaining {
+			if !okReset {
+				continue
+			}
+			remaining = 0
+		}
+		if found && remaining >= minRemaining {
+			continue
+		}
+		found = true
+		minRemaining = remaining
+		resetAt = nil
+		if okReset {
+			resetAt = &reset
+		}
+	}
+	if !found {
+		return models.QuotaHistory{}, false
+	}
+	return models.QuotaHistory{Remaining: math.Max(0, minRemaining*100), Limit: 100, ResetAt: resetAt}, true
+}
+
+// snapshotNumber reads a JSON number or numeric string.
+func snapshotNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		parsed, errParse := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return parsed, errParse == nil
+	default:
+		return 0, false
+	}
+}
+
+// snapshotTime reads an RFC 3339 timestamp.
+func snapshotTime(value any) (time.Time, bool) {
+	text := normalizeString(value)
+	if text == "" {
+		return time.Time{}, false
+	}
+	parsed, errParse := time.Parse(time.RFC3339, text)
+	if errParse != nil {
+		return time.Time{}, false
+	}
+	return parsed.UTC(), true
+}
+
+// sameSnapshot reports whether two snapshots carry the same values.
+func sameSnapshot(a, b models.QuotaHistory) bool {
+	if math.Abs(a.Remaining-b.Remaining) > historyPercentEpsilon || math.Abs(a.Limit-b.Limit) > historyPercentEpsilon {
+		return false
+	}
+	if a.ResetAt == nil || b.ResetAt == nil {
+		return a.ResetAt == nil && b.ResetAt == nil
+	}
+	diff := a.ResetAt.Sub(*b.ResetAt)
+	return diff <= historyResetTolerance && diff >= -historyResetTolerance
+}
+
+// recordHistory appends the snapshot of payload to quota_history when it differs from the
+// auth's latest row, or when that row is older than QUOTA_HISTORY_INTERVAL_SECONDS.
+func recordHistory(ctx context.Context, db *gorm.DB, authID uint64, payload []byte, now time.Time) error {
+	snapshot, ok := quotaSnapshot(payload, now)
+	if !ok {
+		return nil
+	}
+	now = now.UTC()
+	snapshot.AuthID = authID
+	snapshot.CapturedAt = now
+
+	var last models.QuotaHistory
+	errLast := db.WithContext(ctx).
+		Where("auth_id = ?", authID).
+		Order("captured_at DESC").
+		First(&last).Error
+	switch {
+	case errLast == nil:
+		interval := internalsettings.GetDuration(internalsettings.QuotaHistoryIntervalSecondsKey)
+		stale := interval > 0 && now.Sub(last.CapturedAt) >= interval
+		if sameSnapshot(last, snapshot) && !stale {
+			return nil
+		}
+	case !errors.Is(errLast, gorm.ErrRecordNotFound):
+		return errLast
+	}
+	return db.WithContext(ctx).Create(&snapshot).Error
+}
+
+// PruneHistory deletes quota history captured more than retentionDays before now. A
+// non-positive retentionDays keeps history forever.
+func PruneHistory(ctx context.Context, db *gorm.DB, retentionDays int, now time.Time) (int64, error) {
+	if db == nil || retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := now.UTC().AddDate(0, 0, -retentionDays)
+	res := db.WithContext(ctx).Where("captured_at < ?", cutoff).Delete(&models.QuotaHistory{})
+	return res.RowsAffected, res.Error
+}
+
+// pruneHistory runs PruneHistory at most once per historyPruneInterval.
+func (p *Poller) pruneHistory(ctx context.Context, now time.Time) {
+	if !p.lastHistoryPrune.IsZero() && now.Sub(p.lastHistoryPrune) < historyPruneInterval {
+		return
+	}
+	p.lastHistoryPrune = now
+	retentionDays := internalsettings.GetInt(internalsettings.QuotaHistoryRetentionDaysKey)
+	deleted, errPrune := PruneHistory(ctx, p.db, retentionDays, now)
+	if errPrune != nil {
+		log.WithError(errPrune).Warn("quota poller: prune quota history failed")
+		return
+	}
+	if deleted > 0 {
+		log.Infof("quota poller: pruned %d quota history row(s)", deleted)
+	}
+}
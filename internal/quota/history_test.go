@@ -0,0 +1,121 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+func TestQuotaSnapshotUsesTightestLimit(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(time.Hour)
+	cases := []struct {
+		name      string
+		payload   string
+		remaining float64
+		resetAt   *time.Time
+		ok        bool
+	}{
+		{
+			name:      "codex most used window",
+			payload:   fmt.Sprintf(`{"rate_limit":{"primary_window":{"used_percent":30,"reset_after_seconds":60},"secondary_window":{"used_percent":75,"reset_at":%d}}}`, reset.Unix()),
+			remaining: 25,
+			resetAt:   &reset,
+			ok:        true,
+		},
+		{
+			name:      "gemini lowest bucket",
+			payload:   fmt.Sprintf(`{"buckets":[{"remainingFraction":0.8},{"remainingFraction":0.4,"resetTime":%q}]}`, reset.Format(time.RFC3339)),
+			remaining: 40,
+			resetAt:   &reset,
+			ok:        true,
+		},
+		{
+			name:      "antigravity omitted fraction is exhausted",
+			payload:   fmt.Sprintf(`{"models":{"a":{"quotaInfo":{"remainingFraction":0.5}},"b":{"quotaInfo":{"resetTime":%q}}}}`, reset.Format(time.RFC3339)),
+			remaining: 0,
+			resetAt:   &reset,
+			ok:        true,
+		},
+		{name: "unknown payload", payload: `{"foo":1}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			snapshot, ok := quotaSnapshot([]byte(tc.payload), now)
+			if ok != tc.ok {
+				t.Fatalf("expected ok=%v, got %v", tc.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if snapshot.Remaining != tc.remaining || snapshot.Limit != 100 {
+				t.Fatalf("expected remaining %v/100, got %v/%v", tc.remaining, snapshot.Remaining, snapshot.Limit)
+			}
+			if snapshot.ResetAt == nil || !snapshot.ResetAt.Equal(*tc.resetAt) {
+				t.Fatalf("expected reset %v, got %v", tc.resetAt, snapshot.ResetAt)
+			}
+		})
+	}
+}
+
+func TestRecordHistoryAppendsOnChangeOrInterval(t *testing.T) {
+	dsn := fmt.Sprintf("file:quotahistory_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.QuotaHistory{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.QuotaHistoryIntervalSecondsKey: json.RawMessage(`600`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		offset  time.Duration
+		payload string
+	}{
+		{0, `{"buckets":[{"remainingFraction":0.9}]}`},
+		{3 * time.Minute, `{"buckets":[{"remainingFraction":0.9}]}`},  // unchanged, within interval
+		{6 * time.Minute, `{"buckets":[{"remainingFraction":0.7}]}`},  // changed
+		{9 * time.Minute, `{"buckets":[{"remainingFraction":0.7}]}`},  // unchanged, within interval
+		{17 * time.Minute, `{"buckets":[{"remainingFraction":0.7}]}`}, // unchanged, interval elapsed
+		{18 * time.Minute, `{"unrelated":true}`},                      // not a quota payload
+	}
+	for _, step := range steps {
+		if errRecord := recordHistory(ctx, db, 1, []byte(step.payload), start.Add(step.offset)); errRecord != nil {
+			t.Fatalf("record history: %v", errRecord)
+		}
+	}
+	var rows []models.QuotaHistory
+	if errFind := db.Order("captured_at ASC").Find(&rows).Error; errFind != nil {
+		t.Fatalf("load history: %v", errFind)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 history rows, got %+v", rows)
+	}
+	if rows[0].Remaining != 90 || rows[1].Remaining != 70 || !rows[2].CapturedAt.Equal(start.Add(17*time.Minute)) {
+		t.Fatalf("unexpected history rows %+v", rows)
+	}
+
+	deleted, errPrune := PruneHistory(ctx, db, 1, start.Add(24*time.Hour+10*time.Minute))
+	if errPrune != nil {
+		t.Fatalf("prune history: %v", errPrune)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 expired rows deleted, got %d", deleted)
+	}
+	if deleted, _ = PruneHistory(ctx, db, 0, start.Add(365*24*time.Hour)); deleted != 0 {
+		t.Fatalf("expected zero retention to keep history, deleted %d", deleted)
+	}
+}
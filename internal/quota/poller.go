@@ -10,7 +10,6 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
@@ -25,7 +24,6 @@ import (
 const (
 	defaultPollInterval   = 3 * time.Minute
 	defaultRequestTimeout = 20 * time.Second
-	maxConcurrentRequests = 5
 	noAuthRetryInterval   = 10 * time.Second
 	maxErrorBodyBytes     = 512
 )
@@ -58,6 +56,13 @@ type Poller struct {
 	interval       time.Duration
 	requestTimeout time.Duration
 	hadAuths       bool
+	// quotaEpisodes remembers the recovery time of each auth's current quota error so a
+	// single long cooldown is only counted once toward priority decay.
+	quotaEpisodes map[string]time.Time
+	// lastHistoryPrune is when expired quota history was last deleted.
+	lastHistoryPrune time.Time
+	// intervalChanged wakes the loop when QUOTA_POLL_INTERVAL_SECONDS changes.
+	intervalChanged chan struct{}
 }
 
 // NewPoller constructs a quota poller.
@@ -66,10 +71,11 @@ func NewPoller(db *gorm.DB, manager *coreauth.Manager) *Poller {
 		return nil
 	}
 	return &Poller{
-		db:             db,
-		manager:        manager,
-		interval:       defaultPollInterval,
-		requestTimeout: defaultRequestTimeout,
+		db:              db,
+		manager:         manager,
+		interval:        defaultPollInterval,
+		requestTimeout:  defaultRequestTimeout,
+		intervalChanged: make(chan struct{}, 1),
 	}
 }
 
@@ -86,6 +92,9 @@ func (p *Poller) Start(ctx context.Context) {
 }
 
 func (p *Poller) run(ctx context.Context) {
+	unsubscribe := internalsettings.OnChange(p.onSettingsChange)
+	defer unsubscribe()
+
 	for {
 		if ctx != nil && ctx.Err() != nil {
 			return
@@ -97,18 +106,54 @@ func (p *Poller) run(ctx context.Context) {
 		if interval <= 0 {
 			interval = p.interval
 		}
-		timer := time.NewTimer(interval)
+		if !p.wait(ctx, interval) {
+			return
+		}
+	}
+}
+
+// wait sleeps for interval, waking early when the poll interval setting is lowered
+// meanwhile. It reports false when ctx is canceled.
+func (p *Poller) wait(ctx context.Context, interval time.Duration) bool {
+	started := time.Now()
+	deadline := started.Add(interval)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
 		select {
 		case <-ctx.Done():
-			if !timer.Stop() {
-				<-timer.C
-			}
-			return
+			return false
 		case <-timer.C:
+			return true
+		case <-p.intervalChanged:
+			next := started.Add(p.resolvePollInterval())
+			if !next.Before(deadline) {
+				continue
+			}
+			deadline = next
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return true
+			}
+			timer.Reset(remaining)
 		}
 	}
 }
 
+// onSettingsChange signals the loop when the poll interval setting changes.
+func (p *Poller) onSettingsChange(changed []string) {
+	for _, key := range changed {
+		if key != internalsettings.QuotaPollIntervalSecondsKey {
+			continue
+		}
+		select {
+		case p.intervalChanged <- struct{}{}:
+		default:
+		}
+		return
+	}
+}
+
 func (p *Poller) poll(ctx context.Context) time.Duration {
 	if p == nil {
 		return 0
@@ -117,10 +162,7 @@ func (p *Poller) poll(ctx context.Context) time.Duration {
 		ctx = context.Background()
 	}
 
-	interval, maxConcurrency := p.resolvePollConfig()
-	if maxConcurrency <= 0 {
-		maxConcurrency = 1
-	}
+	interval := p.resolvePollInterval()
 
 	auths := p.manager.List()
 	if len(auths) == 0 {
@@ -136,15 +178,12 @@ func (p *Poller) poll(ctx context.Context) time.Duration {
 		log.WithError(errRows).Warn("quota poller: load auth rows failed")
 		return interval
 	}
+	p.applyPriorityDecay(ctx, auths, rowMap)
 
-	sem := make(chan struct{}, maxConcurrency)
-	var wg sync.WaitGroup
-	shouldStop := false
-
+	// The pool re-reads QUOTA_POLL_MAX_CONCURRENCY per job, so a changed setting applies
+	// to the pass in progress.
+	pool := newWorkerPool(resolvePollConcurrency)
 	for _, auth := range auths {
-		if shouldStop {
-			break
-		}
 		if auth == nil || strings.TrimSpace(auth.ID) == "" {
 			continue
 		}
@@ -161,23 +200,10 @@ func (p *Poller) poll(ctx context.Context) time.Duration {
 			continue
 		}
 
-		select {
-		case sem <- struct{}{}:
-		case <-ctx.Done():
-			shouldStop = true
-			break
-		}
-		if shouldStop {
-			break
-		}
-
-		wg.Add(1)
 		authCopy := auth
 		rowCopy := row
 		providerCopy := provider
-		go func() {
-			defer wg.Done()
-			defer func() { <-sem }()
+		started := pool.Go(ctx, func() {
 			switch providerCopy {
 			case "antigravity":
 				p.pollAntigravity(ctx, authCopy, rowCopy)
@@ -185,13 +211,15 @@ func (p *Poller) poll(ctx context.Context) time.Duration {
 				p.pollCodex(ctx, authCopy, rowCopy)
 			case "gemini-cli":
 				p.pollGeminiCLI(ctx, authCopy, rowCopy)
-			default:
-				return
 			}
-		}()
+		})
+		if !started {
+			break
+		}
 	}
 
-	wg.Wait()
+	pool.Wait()
+	p.pruneHistory(ctx, time.Now().UTC())
 	return interval
 }
 
@@ -223,24 +251,13 @@ func (p *Poller) loadAuthRows(ctx context.Context) (map[string]authRowInfo, erro
 	return rowMap, nil
 }
 
-func (p *Poller) resolvePollConfig() (time.Duration, int) {
-	intervalSeconds := internalsettings.DefaultQuotaPollIntervalSeconds
-	maxConcurrency := internalsettings.DefaultQuotaPollMaxConcurrency
+func (p *Poller) resolvePollInterval() time.Duration {
+	return internalsettings.GetDuration(internalsettings.QuotaPollIntervalSecondsKey)
+}
 
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.QuotaPollIntervalSecondsKey); ok {
-		if parsed, okParse := parseDBConfigInt(raw); okParse && parsed > 0 {
-			intervalSeconds = parsed
-		}
-	}
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.QuotaPollMaxConcurrencyKey); ok {
-		if parsed, okParse := parseDBConfigInt(raw); okParse && parsed > 0 {
-			maxConcurrency = parsed
-		}
-	}
-	if maxConcurrency > maxConcurrentRequests {
-		maxConcurrency = maxConcurrentRequests
-	}
-	return time.Duration(intervalSeconds) * time.Second, maxConcurrency
+// resolvePollConcurrency reads QUOTA_POLL_MAX_CONCURRENCY, clamped to [1, MaxQuotaPollMaxConcurrency].
+func resolvePollConcurrency() int {
+	return internalsettings.GetInt(internalsettings.QuotaPollMaxConcurrencyKey)
 }
 
 func (p *Poller) pollAntigravity(ctx context.Context, auth *coreauth.Auth, row authRowInfo) {
@@ -375,6 +392,10 @@ func (p *Poller) saveQuota(ctx context.Context, authID uint64, authType string,
 	}
 
 	now := time.Now().UTC()
+	if errHistory := recordHistory(ctx, p.db, authID, payload, now); errHistory != nil {
+		log.WithError(errHistory).Warnf("quota poller: record quota history failed (auth_id=%d)", authID)
+	}
+
 	var existing models.Quota
 	errFind := p.db.WithContext(ctx).
 		Where("auth_id = ? AND type = ?", authID, authType).
@@ -610,38 +631,6 @@ func bytesTrimSpace(input []byte) []byte {
 	return input[start:end]
 }
 
-func parseDBConfigInt(raw json.RawMessage) (int, bool) {
-	raw = bytesTrimSpace(raw)
-	if len(raw) == 0 {
-		return 0, false
-	}
-	var n int
-	if errUnmarshal := json.Unmarshal(raw, &n); errUnmarshal == nil {
-		return n, true
-	}
-	var f float64
-	if errUnmarshal := json.Unmarshal(raw, &f); errUnmarshal == nil {
-		if math.IsNaN(f) || math.IsInf(f, 0) {
-			return 0, false
-		}
-		return int(math.Round(f)), true
-	}
-	var s string
-	if errUnmarshal := json.Unmarshal(raw, &s); errUnmarshal == nil {
-		parsed, errParse := strconv.Atoi(strings.TrimSpace(s))
-		if errParse == nil {
-			return parsed, true
-		}
-	}
-	var wrapper struct {
-		Value json.RawMessage `json:"value"`
-	}
-	if errUnmarshal := json.Unmarshal(raw, &wrapper); errUnmarshal == nil && len(wrapper.Value) > 0 {
-		return parseDBConfigInt(wrapper.Value)
-	}
-	return 0, false
-}
-
 func summarizePayload(payload []byte) string {
 	trimmed := bytesTrimSpace(payload)
 	if len(trimmed) == 0 {
@@ -0,0 +1,169 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DecayConfig tunes automatic auth priority decay.
+type DecayConfig struct {
+	Threshold     int           // Quota errors within Window that trigger a decay; 0 disables decay.
+	Window        time.Duration // Window in which quota errors are counted.
+	Step          int           // Amount subtracted from the effective priority per decay.
+	RestorePeriod time.Duration // Clean period after which the admin-set priority is restored.
+}
+
+// LoadDecayConfig reads the decay settings from the DB config snapshot.
+func LoadDecayConfig() DecayConfig {
+	return DecayConfig{
+		Threshold:     internalsettings.GetInt(internalsettings.AuthPriorityDecayThresholdKey),
+		Window:        internalsettings.GetDuration(internalsettings.AuthPriorityDecayWindowSecondsKey),
+		Step:          internalsettings.GetInt(internalsettings.AuthPriorityDecayStepKey),
+		RestorePeriod: internalsettings.GetDuration(internalsettings.AuthPriorityRestoreSecondsKey),
+	}
+}
+
+// RecordQuotaExceeded counts a quota error for the auth row and lowers its effective
+// priority once cfg.Threshold errors land within cfg.Window. The admin-set priority
+// column is never modified. It reports whether the effective priority changed.
+func RecordQuotaExceeded(ctx context.Context, db *gorm.DB, authID uint64, cfg DecayConfig, now time.Time) (bool, error) {
+	if db == nil || authID == 0 || cfg.Threshold <= 0 {
+		return false, nil
+	}
+	now = now.UTC()
+	decayed := false
+	errTx := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var auth models.Auth
+		if errFind := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "priority", "effective_priority", "quota_strikes", "quota_strike_window_at").
+			Where("id = ?", authID).
+			First(&auth).Error; errFind != nil {
+			if errors.Is(errFind, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return errFind
+		}
+
+		strikes := auth.QuotaStrikes + 1
+		windowAt := now
+		if auth.QuotaStrikeWindowAt != nil && now.Sub(*auth.QuotaStrikeWindowAt) <= cfg.Window {
+			windowAt = auth.QuotaStrikeWindowAt.UTC()
+		} else {
+			strikes = 1
+		}
+		updates := map[string]any{
+			"quota_strikes":          strikes,
+			"quota_strike_window_at": &windowAt,
+			"last_quota_exceeded_at": &now,
+		}
+		if strikes >= cfg.Threshold {
+			effective := auth.SelectionPriority() - cfg.Step
+			updates["effective_priority"] = &effective
+			updates["quota_strikes"] = 0
+			updates["quota_strike_window_at"] = nil
+			// Bumping updated_at makes the watcher re-export the auth with the new priority.
+			updates["updated_at"] = now
+			decayed = true
+		}
+		return tx.Model(&models.Auth{}).Where("id = ?", authID).UpdateColumns(updates).Error
+	})
+	if errTx != nil {
+		return false, errTx
+	}
+	return decayed, nil
+}
+
+// RestoreDecayedPriorities clears the effective priority of auths without a quota error
+// for cfg.RestorePeriod. It returns the number of auths restored.
+func RestoreDecayedPriorities(ctx context.Context, db *gorm.DB, cfg DecayConfig, now time.Time) (int64, error) {
+	if db == nil || cfg.RestorePeriod <= 0 {
+		return 0, nil
+	}
+	now = now.UTC()
+	cutoff := now.Add(-cfg.RestorePeriod)
+	res := db.WithContext(ctx).Model(&models.Auth{}).
+		Where("effective_priority IS NOT NULL").
+		Where("last_quota_exceeded_at IS NULL OR last_quota_exceeded_at < ?", cutoff).
+		UpdateColumns(map[string]any{
+			"effective_priority":     nil,
+			"quota_strikes":          0,
+			"quota_strike_window_at": nil,
+			"updated_at":             now,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// quotaExceededEpisode returns the recovery time of the quota error the SDK currently
+// tracks for auth, either on the auth itself or on one of its models.
+func quotaExceededEpisode(auth *coreauth.Auth) (time.Time, bool) {
+	if auth == nil {
+		return time.Time{}, false
+	}
+	if auth.Quota.Exceeded {
+		return auth.Quota.NextRecoverAt, true
+	}
+	var latest time.Time
+	found := false
+	for _, state := range auth.ModelStates {
+		if state == nil || !state.Quota.Exceeded {
+			continue
+		}
+		if !found || state.Quota.NextRecoverAt.After(latest) {
+			latest = state.Quota.NextRecoverAt
+		}
+		found = true
+	}
+	return latest, found
+}
+
+// applyPriorityDecay records a strike for every auth that entered a new quota-exceeded
+// episode since the last poll and restores auths that stayed clean long enough.
+func (p *Poller) applyPriorityDecay(ctx context.Context, auths []*coreauth.Auth, rows map[string]authRowInfo) {
+	cfg := LoadDecayConfig()
+	now := time.Now().UTC()
+	seen := make(map[string]time.Time, len(p.quotaEpisodes))
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		row, ok := rows[auth.ID]
+		if !ok {
+			continue
+		}
+		recoverAt, exceeded := quotaExceededEpisode(auth)
+		if !exceeded {
+			continue
+		}
+		seen[auth.ID] = recoverAt
+		if previous, tracked := p.quotaEpisodes[auth.ID]; tracked && previous.Equal(recoverAt) {
+			continue
+		}
+		decayed, errRecord := RecordQuotaExceeded(ctx, p.db, row.ID, cfg, now)
+		if errRecord != nil {
+			log.WithError(errRecord).Warnf("quota poller: record quota error failed (auth=%s)", auth.ID)
+			continue
+		}
+		if decayed {
+			log.Infof("quota poller: lowered effective priority after repeated quota errors (auth=%s)", auth.ID)
+		}
+	}
+	p.quotaEpisodes = seen
+
+	restored, errRestore := RestoreDecayedPriorities(ctx, p.db, cfg, now)
+	if errRestore != nil {
+		log.WithError(errRestore).Warn("quota poller: restore auth priorities failed")
+		return
+	}
+	if restored > 0 {
+		log.Infof("quota poller: restored priority for %d auth(s)", restored)
+	}
+}
@@ -0,0 +1,104 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func openDecayTestDB(t *testing.T) (*gorm.DB, models.Auth) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:decay_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, errOpen := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.AutoMigrate(&models.Auth{}); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+	auth := models.Auth{Key: "a.json", Content: datatypes.JSON(`{"type":"codex"}`), IsAvailable: true, Priority: 5}
+	if errCreate := db.Create(&auth).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+	return db, auth
+}
+
+func loadAuth(t *testing.T, db *gorm.DB, id uint64) models.Auth {
+	t.Helper()
+	var auth models.Auth
+	if errFind := db.First(&auth, id).Error; errFind != nil {
+		t.Fatalf("load auth: %v", errFind)
+	}
+	return auth
+}
+
+func TestRecordQuotaExceededDecaysAndRestores(t *testing.T) {
+	db, auth := openDecayTestDB(t)
+	cfg := DecayConfig{Threshold: 3, Window: time.Hour, Step: 10, RestorePeriod: 2 * time.Hour}
+	ctx := context.Background()
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Strikes spread beyond the window never accumulate.
+	for i := 0; i < 3; i++ {
+		decayed, errRecord := RecordQuotaExceeded(ctx, db, auth.ID, cfg, base.Add(time.Duration(i)*61*time.Minute))
+		if errRecord != nil || decayed {
+			t.Fatalf("strike %d: expected no decay, got %v %v", i, decayed, errRecord)
+		}
+	}
+
+	start := base.Add(4 * time.Hour)
+	for i := 0; i < 3; i++ {
+		decayed, errRecord := RecordQuotaExceeded(ctx, db, auth.ID, cfg, start.Add(time.Duration(i)*time.Minute))
+		if errRecord != nil {
+			t.Fatalf("record: %v", errRecord)
+		}
+		if decayed != (i == 2) {
+			t.Fatalf("strike %d: unexpected decay=%v", i, decayed)
+		}
+	}
+	got := loadAuth(t, db, auth.ID)
+	if got.Priority != 5 || got.EffectivePriority == nil || *got.EffectivePriority != -5 || got.SelectionPriority() != -5 {
+		t.Fatalf("expected admin priority 5 and effective -5, got %d %v", got.Priority, got.EffectivePriority)
+	}
+
+	restored, errRestore := RestoreDecayedPriorities(ctx, db, cfg, start.Add(time.Hour))
+	if errRestore != nil || restored != 0 {
+		t.Fatalf("expected no restore inside the clean period, got %d %v", restored, errRestore)
+	}
+	restored, errRestore = RestoreDecayedPriorities(ctx, db, cfg, start.Add(3*time.Hour))
+	if errRestore != nil || restored != 1 {
+		t.Fatalf("expected restore after the clean period, got %d %v", restored, errRestore)
+	}
+	got = loadAuth(t, db, auth.ID)
+	if got.EffectivePriority != nil || got.SelectionPriority() != 5 {
+		t.Fatalf("expected restored priority, got %v", got.EffectivePriority)
+	}
+}
+
+func TestApplyPriorityDecayCountsEachEpisodeOnce(t *testing.T) {
+	db, auth := openDecayTestDB(t)
+	p := &Poller{db: db}
+	rows := map[string]authRowInfo{auth.Key: {ID: auth.ID}}
+	recoverAt := time.Now().Add(time.Hour)
+	live := &coreauth.Auth{ID: auth.Key, Quota: coreauth.QuotaState{Exceeded: true, NextRecoverAt: recoverAt}}
+
+	p.applyPriorityDecay(context.Background(), []*coreauth.Auth{live}, rows)
+	p.applyPriorityDecay(context.Background(), []*coreauth.Auth{live}, rows)
+	if got := loadAuth(t, db, auth.ID); got.QuotaStrikes != 1 {
+		t.Fatalf("expected one strike for a single cooldown, got %d", got.QuotaStrikes)
+	}
+
+	live.Quota.NextRecoverAt = recoverAt.Add(time.Hour)
+	p.applyPriorityDecay(context.Background(), []*coreauth.Auth{live}, rows)
+	if got := loadAuth(t, db, auth.ID); got.QuotaStrikes != 2 {
+		t.Fatalf("expected a new episode to add a strike, got %d", got.QuotaStrikes)
+	}
+}
@@ -0,0 +1,92 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// workerPoolRecheckInterval bounds how long a waiting job goes without re-reading the limit,
+// so a raised limit takes effect even when no running job finishes.
+const workerPoolRecheckInterval = time.Second
+
+// workerPool runs jobs with at most limit() of them in flight. The limit is re-read every
+// time a slot is requested, so changing the setting resizes a pass that is already running.
+type workerPool struct {
+	limit func() int
+
+	mu      sync.Mutex
+	active  int
+	changed chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newWorkerPool constructs a pool bounded by limit; values below 1 are treated as 1.
+func newWorkerPool(limit func() int) *workerPool {
+	return &workerPool{limit: limit, changed: make(chan struct{})}
+}
+
+// currentLimit returns the effective limit.
+func (w *workerPool) currentLimit() int {
+	limit := 1
+	if w.limit != nil {
+		limit = w.limit()
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// Go waits for a free slot and runs job in a goroutine. It returns false without running
+// job when ctx is canceled first.
+func (w *workerPool) Go(ctx context.Context, job func()) bool {
+	if !w.acquire(ctx) {
+		return false
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer w.release()
+		job()
+	}()
+	return true
+}
+
+// Wait blocks until every started job has finished.
+func (w *workerPool) Wait() {
+	w.wg.Wait()
+}
+
+// acquire reserves a slot, waiting for a release, a limit change, or ctx cancellation.
+func (w *workerPool) acquire(ctx context.Context) bool {
+	for {
+		w.mu.Lock()
+		if w.active < w.currentLimit() {
+			w.active++
+			w.mu.Unlock()
+			return true
+		}
+		changed := w.changed
+		w.mu.Unlock()
+
+		timer := time.NewTimer(workerPoolRecheckInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// release frees a slot and wakes waiting callers.
+func (w *workerPool) release() {
+	w.mu.Lock()
+	w.active--
+	close(w.changed)
+	w.changed = make(chan struct{})
+	w.mu.Unlock()
+}
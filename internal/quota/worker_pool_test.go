@@ -0,0 +1,123 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+func setPollConcurrency(t *testing.T, value int) {
+	t.Helper()
+	raw, _ := json.Marshal(value)
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.QuotaPollMaxConcurrencyKey: raw,
+	})
+}
+
+// trackPeak returns a job that records the highest number of concurrent runs.
+func trackPeak(running, peak *atomic.Int64, hold <-chan struct{}) func() {
+	return func() {
+		current := running.Add(1)
+		for {
+			seen := peak.Load()
+			if current <= seen || peak.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		<-hold
+		running.Add(-1)
+	}
+}
+
+func TestWorkerPoolNeverExceedsConfiguredConcurrency(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	setPollConcurrency(t, 3)
+
+	var running, peak atomic.Int64
+	hold := make(chan struct{})
+	pool := newWorkerPool(resolvePollConcurrency)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			pool.Go(context.Background(), trackPeak(&running, &peak, hold))
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := running.Load(); got != 3 {
+		t.Fatalf("expected 3 running jobs, got %d", got)
+	}
+	close(hold)
+	<-done
+	pool.Wait()
+	if got := peak.Load(); got > 3 {
+		t.Fatalf("concurrency exceeded bound: peak=%d", got)
+	}
+}
+
+func TestWorkerPoolFollowsSettingChanges(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	setPollConcurrency(t, 2)
+
+	var running, peak atomic.Int64
+	hold := make(chan struct{})
+	pool := newWorkerPool(resolvePollConcurrency)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			pool.Go(context.Background(), trackPeak(&running, &peak, hold))
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := running.Load(); got != 2 {
+		t.Fatalf("expected 2 running jobs, got %d", got)
+	}
+
+	// Raising the limit lets waiting jobs start without any running job finishing.
+	setPollConcurrency(t, 6)
+	deadline := time.Now().Add(3 * workerPoolRecheckInterval)
+	for running.Load() < 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := running.Load(); got != 6 {
+		t.Fatalf("expected 6 running jobs after raising the limit, got %d", got)
+	}
+	close(hold)
+	wg.Wait()
+	pool.Wait()
+	if got := peak.Load(); got > 6 {
+		t.Fatalf("concurrency exceeded bound: peak=%d", got)
+	}
+}
+
+func TestWorkerPoolStopsOnCanceledContext(t *testing.T) {
+	pool := newWorkerPool(func() int { return 1 })
+	hold := make(chan struct{})
+	if !pool.Go(context.Background(), func() { <-hold }) {
+		t.Fatal("expected first job to start")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if pool.Go(ctx, func() {}) {
+		t.Fatal("expected canceled context to stop waiting for a slot")
+	}
+	close(hold)
+	pool.Wait()
+}
+
+func TestResolvePollConcurrencyClampsToMaximum(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	setPollConcurrency(t, internalsettings.MaxQuotaPollMaxConcurrency+100)
+	if got := resolvePollConcurrency(); got != internalsettings.MaxQuotaPollMaxConcurrency {
+		t.Fatalf("expected clamp to %d, got %d", internalsettings.MaxQuotaPollMaxConcurrency, got)
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// dbCleanupInterval is how often stale counter rows are purged.
+	dbCleanupInterval = time.Minute
+	// dbCounterRetention keeps rows for keys seen within this many seconds.
+	dbCounterRetention = 60
+)
+
+// dbIncrSQL atomically starts or advances a key's fixed window and returns its hit count.
+const dbIncrSQL = `
+INSERT INTO rate_limit_counters (limit_key, window_start, hits) VALUES (?, ?, 1)
+ON CONFLICT (limit_key) DO UPDATE SET
+	hits = CASE WHEN rate_limit_counters.window_start = excluded.window_start
+		THEN rate_limit_counters.hits + 1 ELSE 1 END,
+	window_start = excluded.window_start
+RETURNING hits
+`
+
+// DBLimiter implements a fixed-window rate limiter persisted in the database,
+// so counters survive process restarts. Each key occupies a single row.
+type DBLimiter struct {
+	db          *gorm.DB
+	lastCleanup atomic.Int64
+}
+
+// NewDBLimiter constructs a DBLimiter.
+func NewDBLimiter(db *gorm.DB) *DBLimiter {
+	return &DBLimiter{db: db}
+}
+
+// Allow checks whether the request should be allowed in the current second.
+func (l *DBLimiter) Allow(ctx context.Context, key string, limit int, now time.Time) (Result, error) {
+	if limit <= 0 || key == "" || l == nil || l.db == nil {
+		return Result{Allowed: true}, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sec := now.Unix()
+	reset := time.Unix(sec+1, 0).UTC()
+
+	var hits []int64
+	if errIncr := l.db.WithContext(ctx).Raw(dbIncrSQL, key, sec).Scan(&hits).Error; errIncr != nil {
+		return Result{}, errIncr
+	}
+	if len(hits) == 0 {
+		return Result{}, errors.New("rate limit db: empty counter result")
+	}
+	l.maybeCleanup(sec)
+
+	count := hits[0]
+	if count > int64(limit) {
+		return Result{Allowed: false, Remaining: 0, Reset: reset}, nil
+	}
+	return Result{Allowed: true, Remaining: limit - int(count), Reset: reset}, nil
+}
+
+// maybeCleanup purges stale rows at most once per dbCleanupInterval.
+func (l *DBLimiter) maybeCleanup(sec int64) {
+	last := l.lastCleanup.Load()
+	if sec-last < int64(dbCleanupInterval/time.Second) {
+		return
+	}
+	if !l.lastCleanup.CompareAndSwap(last, sec) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if errCleanup := l.cleanupExpired(ctx, sec-dbCounterRetention); errCleanup != nil {
+			log.WithError(errCleanup).Warn("rate limit db: cleanup failed")
+		}
+	}()
+}
+
+// cleanupExpired deletes counters whose window started before the cutoff.
+func (l *DBLimiter) cleanupExpired(ctx context.Context, before int64) error {
+	return l.db.WithContext(ctx).
+		Where("window_start < ?", before).
+		Delete(&models.RateLimitCounter{}).Error
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestDBLimiterHoldsLimitUnderContention(t *testing.T) {
+	conn, errOpen := db.Open(filepath.Join(t.TempDir(), "ratelimit.db"))
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	limiter := NewDBLimiter(conn)
+	now := time.Unix(1_700_000_000, 0)
+	const (
+		workers = 50
+		limit   = 10
+	)
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int64
+		failed  atomic.Int64
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, errAllow := limiter.Allow(context.Background(), "u:1", limit, now)
+			if errAllow != nil {
+				failed.Add(1)
+				return
+			}
+			if result.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed.Load() != 0 {
+		t.Fatalf("expected no backend errors, got %d", failed.Load())
+	}
+	if allowed.Load() != limit {
+		t.Fatalf("expected exactly %d allowed, got %d", limit, allowed.Load())
+	}
+
+	// A new window starts fresh, even from a new limiter (as after a restart).
+	result, errAllow := NewDBLimiter(conn).Allow(context.Background(), "u:1", limit, now.Add(time.Second))
+	if errAllow != nil || !result.Allowed || result.Remaining != limit-1 {
+		t.Fatalf("expected fresh window, got %+v (err=%v)", result, errAllow)
+	}
+}
+
+func TestDBLimiterCleanupRemovesStaleKeys(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	limiter := NewDBLimiter(conn)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	if _, errAllow := limiter.Allow(ctx, "stale", 5, now.Add(-2*time.Minute)); errAllow != nil {
+		t.Fatalf("allow stale: %v", errAllow)
+	}
+	if _, errAllow := limiter.Allow(ctx, "fresh", 5, now); errAllow != nil {
+		t.Fatalf("allow fresh: %v", errAllow)
+	}
+	if errCleanup := limiter.cleanupExpired(ctx, now.Unix()-dbCounterRetention); errCleanup != nil {
+		t.Fatalf("cleanup: %v", errCleanup)
+	}
+	var keys []string
+	if errFind := conn.Model(&models.RateLimitCounter{}).Pluck("limit_key", &keys).Error; errFind != nil {
+		t.Fatalf("list keys: %v", errFind)
+	}
+	if len(keys) != 1 || keys[0] != "fresh" {
+		t.Fatalf("expected only the fresh key to remain, got %v", keys)
+	}
+}
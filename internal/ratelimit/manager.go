@@ -9,6 +9,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 const redisBreakerDuration = 30 * time.Second
@@ -36,12 +37,14 @@ type Manager struct {
 	redisLimiter   *RedisLimiter
 	redisCfg       redisConfig
 	breakerUntil   time.Time
+	dbLimiter      Limiter
+	dbBreakerUntil time.Time
 }
 
 // NewManager constructs a Manager with default dependencies when nil.
 func NewManager(provider SettingsProvider, nowFn func() time.Time, newRedisClient RedisClientFactory) *Manager {
 	if provider == nil {
-		provider = LoadSettingsConfig
+		provider = CurrentSettingsConfig
 	}
 	if nowFn == nil {
 		nowFn = time.Now
@@ -57,6 +60,15 @@ func NewManager(provider SettingsProvider, nowFn func() time.Time, newRedisClien
 	}
 }
 
+// WithDBLimiter enables the database-backed limiter used when RATE_LIMIT_DB_ENABLED is set.
+func (m *Manager) WithDBLimiter(db *gorm.DB) *Manager {
+	if m == nil || db == nil {
+		return m
+	}
+	m.dbLimiter = NewDBLimiter(db)
+	return m
+}
+
 // Allow checks whether the request should be allowed using the best available backend.
 func (m *Manager) Allow(ctx context.Context, key string, limit int) (Result, error) {
 	if limit <= 0 || key == "" {
@@ -73,9 +85,58 @@ func (m *Manager) Allow(ctx context.Context, key string, limit int) (Result, err
 			return result, nil
 		}
 	}
+	if cfg.DBEnabled {
+		if result, ok := m.allowDB(ctx, key, limit, now); ok {
+			return result, nil
+		}
+	}
 	return m.memoryLimiter.Allow(ctx, key, limit, now)
 }
 
+func (m *Manager) allowDB(ctx context.Context, key string, limit int, now time.Time) (Result, bool) {
+	if m == nil || m.dbLimiter == nil {
+		return Result{}, false
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if m.isDBBreakerActive(now) {
+		return Result{}, false
+	}
+	result, errAllow := m.dbLimiter.Allow(ctx, key, limit, now)
+	if errAllow != nil {
+		m.tripDBBreaker(errAllow, now)
+		return Result{}, false
+	}
+	return result, true
+}
+
+func (m *Manager) isDBBreakerActive(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dbBreakerUntil.IsZero() {
+		return false
+	}
+	if now.Before(m.dbBreakerUntil) {
+		return true
+	}
+	m.dbBreakerUntil = time.Time{}
+	return false
+}
+
+func (m *Manager) tripDBBreaker(err error, now time.Time) {
+	if err == nil || m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dbBreakerUntil.IsZero() && now.Before(m.dbBreakerUntil) {
+		return
+	}
+	m.dbBreakerUntil = now.Add(redisBreakerDuration)
+	log.WithError(err).Warn("rate limit: database counters unavailable, falling back to memory")
+}
+
 func (m *Manager) allowRedis(ctx context.Context, key string, limit int, now time.Time, cfg SettingsConfig) (Result, bool) {
 	if m == nil {
 		return Result{}, false
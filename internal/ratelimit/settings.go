@@ -1,11 +1,8 @@
 package ratelimit
 
 import (
-	"bytes"
-	"encoding/json"
-	"math"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 
 	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 )
@@ -13,6 +10,7 @@ import (
 // SettingsConfig captures rate limit settings stored in DB config.
 type SettingsConfig struct {
 	Limit         int
+	DBEnabled     bool
 	RedisEnabled  bool
 	RedisAddr     string
 	RedisPassword string
@@ -20,140 +18,69 @@ type SettingsConfig struct {
 	RedisPrefix   string
 }
 
-// LoadSettingsConfig loads the current rate limit settings snapshot.
+// settingsKeys lists the DB config keys that make up SettingsConfig.
+var settingsKeys = map[string]struct{}{
+	internalsettings.RateLimitKey:              {},
+	internalsettings.RateLimitDBEnabledKey:     {},
+	internalsettings.RateLimitRedisEnabledKey:  {},
+	internalsettings.RateLimitRedisAddrKey:     {},
+	internalsettings.RateLimitRedisPasswordKey: {},
+	internalsettings.RateLimitRedisDBKey:       {},
+	internalsettings.RateLimitRedisPrefixKey:   {},
+}
+
+var (
+	// cachedSettingsOnce subscribes the cache to settings changes on first use.
+	cachedSettingsOnce sync.Once
+	// cachedSettingsMu orders refreshes so an older snapshot never overwrites a newer one.
+	cachedSettingsMu sync.Mutex
+	// cachedSettings holds the SettingsConfig built from the latest DB config.
+	cachedSettings atomic.Pointer[SettingsConfig]
+)
+
+// LoadSettingsConfig builds the rate limit settings from the current DB config snapshot.
 func LoadSettingsConfig() SettingsConfig {
 	cfg := SettingsConfig{
-		Limit:       internalsettings.DefaultRateLimit,
-		RedisPrefix: internalsettings.DefaultRateLimitRedisPrefix,
-	}
-
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.RateLimitKey); ok {
-		if limit, okParse := parseNonNegativeInt(raw); okParse {
-			cfg.Limit = limit
-		}
-	}
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.RateLimitRedisEnabledKey); ok {
-		if enabled, okParse := parseBool(raw); okParse {
-			cfg.RedisEnabled = enabled
-		}
-	}
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.RateLimitRedisAddrKey); ok {
-		if addr, okParse := parseString(raw); okParse {
-			cfg.RedisAddr = addr
-		}
+		Limit:         internalsettings.GetInt(internalsettings.RateLimitKey),
+		DBEnabled:     internalsettings.GetBool(internalsettings.RateLimitDBEnabledKey),
+		RedisEnabled:  internalsettings.GetBool(internalsettings.RateLimitRedisEnabledKey),
+		RedisAddr:     internalsettings.GetString(internalsettings.RateLimitRedisAddrKey),
+		RedisPassword: internalsettings.GetString(internalsettings.RateLimitRedisPasswordKey),
+		RedisDB:       internalsettings.GetInt(internalsettings.RateLimitRedisDBKey),
+		RedisPrefix:   internalsettings.GetString(internalsettings.RateLimitRedisPrefixKey),
 	}
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.RateLimitRedisPasswordKey); ok {
-		if password, okParse := parseString(raw); okParse {
-			cfg.RedisPassword = password
-		}
-	}
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.RateLimitRedisDBKey); ok {
-		if db, okParse := parseNonNegativeInt(raw); okParse {
-			cfg.RedisDB = db
-		}
-	}
-	if raw, ok := internalsettings.DBConfigValue(internalsettings.RateLimitRedisPrefixKey); ok {
-		if prefix, okParse := parseString(raw); okParse {
-			cfg.RedisPrefix = prefix
-		}
-	}
-	cfg.RedisAddr = strings.TrimSpace(cfg.RedisAddr)
-	cfg.RedisPassword = strings.TrimSpace(cfg.RedisPassword)
-	cfg.RedisPrefix = strings.TrimSpace(cfg.RedisPrefix)
 	if cfg.RedisPrefix == "" {
 		cfg.RedisPrefix = internalsettings.DefaultRateLimitRedisPrefix
 	}
-	if cfg.RedisDB < 0 {
-		cfg.RedisDB = 0
-	}
-	if cfg.Limit < 0 {
-		cfg.Limit = 0
-	}
 	return cfg
 }
 
-// DefaultSettingsLimit returns the default rate limit configured in settings.
-func DefaultSettingsLimit() int {
-	cfg := LoadSettingsConfig()
-	if cfg.Limit < 0 {
-		return 0
-	}
-	return cfg.Limit
-}
-
-func parseBool(raw json.RawMessage) (bool, bool) {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return false, false
-	}
-	var parsedBool bool
-	if errUnmarshalBool := json.Unmarshal(raw, &parsedBool); errUnmarshalBool == nil {
-		return parsedBool, true
-	}
-	var parsedString string
-	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
-		switch strings.ToLower(strings.TrimSpace(parsedString)) {
-		case "1", "true", "yes", "y", "on":
-			return true, true
-		case "0", "false", "no", "n", "off":
-			return false, true
-		default:
-			return false, false
-		}
-	}
-	var parsedFloat float64
-	if errUnmarshalFloat := json.Unmarshal(raw, &parsedFloat); errUnmarshalFloat == nil {
-		if math.IsNaN(parsedFloat) || math.IsInf(parsedFloat, 0) {
-			return false, false
-		}
-		if parsedFloat == 1 {
-			return true, true
-		}
-		if parsedFloat == 0 {
-			return false, true
-		}
-	}
-	return false, false
+// CurrentSettingsConfig returns the cached rate limit settings. The cache is rebuilt
+// only when a rate limit key changes, so the per-request path skips re-parsing.
+func CurrentSettingsConfig() SettingsConfig {
+	cachedSettingsOnce.Do(func() {
+		internalsettings.OnChange(func(changed []string) {
+			for _, key := range changed {
+				if _, ok := settingsKeys[key]; ok {
+					refreshCachedSettings()
+					return
+				}
+			}
+		})
+		refreshCachedSettings()
+	})
+	return *cachedSettings.Load()
 }
 
-func parseString(raw json.RawMessage) (string, bool) {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return "", false
-	}
-	var parsedString string
-	if errUnmarshal := json.Unmarshal(raw, &parsedString); errUnmarshal == nil {
-		return strings.TrimSpace(parsedString), true
-	}
-	return "", false
+// refreshCachedSettings rebuilds the cached SettingsConfig from the DB config snapshot.
+func refreshCachedSettings() {
+	cachedSettingsMu.Lock()
+	defer cachedSettingsMu.Unlock()
+	cfg := LoadSettingsConfig()
+	cachedSettings.Store(&cfg)
 }
 
-func parseNonNegativeInt(raw json.RawMessage) (int, bool) {
-	raw = bytes.TrimSpace(raw)
-	if len(raw) == 0 {
-		return 0, false
-	}
-	var parsedInt int
-	if errUnmarshalInt := json.Unmarshal(raw, &parsedInt); errUnmarshalInt == nil {
-		return parsedInt, parsedInt >= 0
-	}
-	var parsedString string
-	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
-		parsed, errParse := strconv.Atoi(strings.TrimSpace(parsedString))
-		if errParse != nil {
-			return 0, false
-		}
-		return parsed, parsed >= 0
-	}
-	var parsedFloat float64
-	if errUnmarshalFloat := json.Unmarshal(raw, &parsedFloat); errUnmarshalFloat == nil {
-		if math.IsNaN(parsedFloat) || math.IsInf(parsedFloat, 0) {
-			return 0, false
-		}
-		if parsedFloat < 0 || parsedFloat != math.Trunc(parsedFloat) {
-			return 0, false
-		}
-		return int(parsedFloat), true
-	}
-	return 0, false
+// DefaultSettingsLimit returns the default rate limit configured in settings.
+func DefaultSettingsLimit() int {
+	return CurrentSettingsConfig().Limit
 }
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+func TestCurrentSettingsConfigRefreshesOnChange(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.RateLimitKey: json.RawMessage(`5`),
+	})
+	if got := CurrentSettingsConfig().Limit; got != 5 {
+		t.Fatalf("expected limit 5, got %d", got)
+	}
+
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.RateLimitKey:            json.RawMessage(`"12"`),
+		internalsettings.RateLimitRedisPrefixKey: json.RawMessage(`"  "`),
+		internalsettings.RateLimitDBEnabledKey:   json.RawMessage(`"on"`),
+	})
+	cfg := CurrentSettingsConfig()
+	if cfg.Limit != 12 || !cfg.DBEnabled {
+		t.Fatalf("expected refreshed settings, got %+v", cfg)
+	}
+	if cfg.RedisPrefix != internalsettings.DefaultRateLimitRedisPrefix {
+		t.Fatalf("expected default redis prefix, got %q", cfg.RedisPrefix)
+	}
+}
+
+func TestCurrentSettingsConfigConcurrentUpdates(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+					internalsettings.RateLimitKey: json.RawMessage(`7`),
+				})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if limit := DefaultSettingsLimit(); limit != 0 && limit != 7 {
+					t.Errorf("unexpected limit %d", limit)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := CurrentSettingsConfig().Limit; got != 7 {
+		t.Fatalf("expected final limit 7, got %d", got)
+	}
+}
@@ -21,6 +21,8 @@ type UserClaims struct {
 	Username string `json:"username"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
+	// ImpersonatedBy is the admin ID that issued an impersonation token, zero otherwise.
+	ImpersonatedBy uint64 `json:"impersonated_by,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -48,6 +50,24 @@ func GenerateToken(secret string, userID uint64, username, name, email string, e
 	return token.SignedString([]byte(secret))
 }
 
+// GenerateImpersonationToken signs a user JWT on behalf of an admin.
+func GenerateImpersonationToken(secret string, userID uint64, username, name, email string, adminID uint64, expiry time.Duration) (string, error) {
+	now := time.Now().UTC()
+	claims := UserClaims{
+		UserID:         userID,
+		Username:       username,
+		Name:           name,
+		Email:          email,
+		ImpersonatedBy: adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
 // ParseToken validates a user JWT and returns its claims.
 func ParseToken(secret string, tokenString string) (*UserClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(t *jwt.Token) (any, error) {
@@ -69,13 +89,15 @@ func ParseToken(secret string, tokenString string) (*UserClaims, error) {
 	return claims, nil
 }
 
-// GenerateAdminToken signs an admin JWT with the configured expiry.
-func GenerateAdminToken(secret string, adminID uint64, username string, expiry time.Duration) (string, error) {
+// GenerateAdminToken signs an admin JWT with the configured expiry. jti becomes the
+// token ID claim and identifies the admin session the token belongs to.
+func GenerateAdminToken(secret string, adminID uint64, username, jti string, expiry time.Duration) (string, error) {
 	now := time.Now().UTC()
 	claims := AdminClaims{
 		AdminID:  adminID,
 		Username: username,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 		},
@@ -0,0 +1,430 @@
+package security
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"golang.org/x/oauth2"
+)
+
+// oidcKeysMinRefresh limits how often an unknown key ID triggers a JWKS refetch.
+const oidcKeysMinRefresh = time.Minute
+
+// oidcMaxResponseBytes caps discovery and JWKS response sizes.
+const oidcMaxResponseBytes = 1 << 20
+
+// OIDC verification errors.
+var (
+	// ErrOIDCNonceMismatch indicates the ID token nonce does not match the login attempt.
+	ErrOIDCNonceMismatch = errors.New("oidc: nonce mismatch")
+	// ErrOIDCUnknownKey indicates the ID token was signed by a key the issuer does not publish.
+	ErrOIDCUnknownKey = errors.New("oidc: unknown signing key")
+)
+
+// oidcStateAudience scopes login state tokens so they cannot be replayed as other JWTs.
+const oidcStateAudience = "admin-oidc-state"
+
+// OIDCSettings holds the DB-backed SSO configuration.
+type OIDCSettings struct {
+	Enabled                   bool     // Whether SSO login is offered.
+	Issuer                    string   // Issuer URL used for discovery.
+	ClientID                  string   // OAuth client ID.
+	ClientSecret              string   // OAuth client secret.
+	RedirectURL               string   // Callback URL registered with the issuer.
+	AllowedDomains            []string // Lowercased email domains allowed to log in; empty allows all.
+	AutoProvision             bool     // Whether unknown admins are created on first login.
+	TrustMissingEmailVerified bool     // Whether ID tokens without email_verified are accepted.
+}
+
+// LoadOIDCSettings reads the SSO configuration from the DB config snapshot.
+func LoadOIDCSettings() OIDCSettings {
+	out := OIDCSettings{
+		Enabled:                   internalsettings.GetBool(internalsettings.OIDCEnabledKey),
+		Issuer:                    internalsettings.GetString(internalsettings.OIDCIssuerKey),
+		ClientID:                  internalsettings.GetString(internalsettings.OIDCClientIDKey),
+		ClientSecret:              internalsettings.GetString(internalsettings.OIDCClientSecretKey),
+		RedirectURL:               internalsettings.GetString(internalsettings.OIDCRedirectURLKey),
+		AutoProvision:             internalsettings.GetBool(internalsettings.OIDCAutoProvisionKey),
+		TrustMissingEmailVerified: internalsettings.GetBool(internalsettings.OIDCTrustMissingEmailVerifiedKey),
+	}
+	for _, domain := range dbConfigStrings(internalsettings.OIDCAllowedDomainsKey) {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			out.AllowedDomains = append(out.AllowedDomains, domain)
+		}
+	}
+	return out
+}
+
+// Configured reports whether SSO is enabled and has the settings needed to run.
+func (s OIDCSettings) Configured() bool {
+	return s.Enabled && s.Issuer != "" && s.ClientID != "" && s.RedirectURL != ""
+}
+
+// AllowsEmail reports whether email belongs to one of the allowed domains.
+func (s OIDCSettings) AllowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return false
+	}
+	if len(s.AllowedDomains) == 0 {
+		return true
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range s.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCLoginState carries the per-attempt values checked by the callback.
+type OIDCLoginState struct {
+	State    string `json:"state"`               // Value echoed back by the issuer.
+	Nonce    string `json:"nonce"`               // Value the ID token must carry.
+	Verifier string `json:"verifier"`            // PKCE code verifier.
+	ReturnTo string `json:"return_to,omitempty"` // Relative path the browser returns to.
+}
+
+// oidcStateClaims wraps OIDCLoginState as signed JWT claims.
+type oidcStateClaims struct {
+	OIDCLoginState
+	jwt.RegisteredClaims
+}
+
+// GenerateOIDCStateToken signs login state for the duration of one SSO attempt.
+func GenerateOIDCStateToken(secret string, state OIDCLoginState, expiry time.Duration) (string, error) {
+	now := time.Now().UTC()
+	claims := oidcStateClaims{
+		OIDCLoginState: state,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcStateAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(oidcStateKey(secret))
+}
+
+// ParseOIDCStateToken validates a login state token and returns its state.
+func ParseOIDCStateToken(secret string, tokenString string) (*OIDCLoginState, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &oidcStateClaims{}, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return oidcStateKey(secret), nil
+	}, jwt.WithAudience(oidcStateAudience))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+	claims, ok := token.Claims.(*oidcStateClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return &claims.OIDCLoginState, nil
+}
+
+// oidcStateKey derives the state signing key so state tokens never verify as admin tokens.
+func oidcStateKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(oidcStateAudience))
+	return mac.Sum(nil)
+}
+
+// OIDCIdentity holds the verified claims of an ID token.
+type OIDCIdentity struct {
+	Issuer               string // Issuer that signed the token.
+	Subject              string // Issuer-scoped subject identifier.
+	Email                string // Email address claim, lowercased.
+	EmailVerified        bool   // Whether the token asserts email_verified=true.
+	EmailVerifiedMissing bool   // Whether the token omitted the email_verified claim.
+	Name                 string // Display name claim.
+}
+
+// EmailTrusted reports whether the identity email may be used to match an admin.
+// A missing email_verified claim only counts when trustMissing is set.
+func (i *OIDCIdentity) EmailTrusted(trustMissing bool) bool {
+	if i == nil || i.Email == "" {
+		return false
+	}
+	return i.EmailVerified || (trustMissing && i.EmailVerifiedMissing)
+}
+
+// oidcIDTokenClaims defines the ID token claims read during verification.
+type oidcIDTokenClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// oidcDiscovery defines the subset of the discovery document in use.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcJWK defines a single JSON Web Key.
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCProvider verifies ID tokens issued by one OpenID Connect issuer.
+type OIDCProvider struct {
+	issuer   string
+	endpoint oauth2.Endpoint
+	jwksURI  string
+	client   *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]any
+	keysFetched time.Time
+}
+
+// NewOIDCProvider loads the issuer discovery document and signing keys.
+func NewOIDCProvider(ctx context.Context, client *http.Client, issuer string) (*OIDCProvider, error) {
+	issuer = strings.TrimRight(strings.TrimSpace(issuer), "/")
+	if issuer == "" {
+		return nil, errors.New("oidc: missing issuer")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	var doc oidcDiscovery
+	if errFetch := fetchOIDCJSON(ctx, client, issuer+"/.well-known/openid-configuration", &doc); errFetch != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", errFetch)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+	provider := &OIDCProvider{
+		issuer: doc.Issuer,
+		endpoint: oauth2.Endpoint{
+			AuthURL:  doc.AuthorizationEndpoint,
+			TokenURL: doc.TokenEndpoint,
+		},
+		jwksURI: doc.JWKSURI,
+		client:  client,
+	}
+	if errKeys := provider.refreshKeys(ctx); errKeys != nil {
+		return nil, errKeys
+	}
+	return provider, nil
+}
+
+// Issuer returns the issuer identifier from the discovery document.
+func (p *OIDCProvider) Issuer() string {
+	return p.issuer
+}
+
+// Endpoint returns the authorization and token endpoints.
+func (p *OIDCProvider) Endpoint() oauth2.Endpoint {
+	return p.endpoint
+}
+
+// Client returns the HTTP client used to talk to the issuer.
+func (p *OIDCProvider) Client() *http.Client {
+	return p.client
+}
+
+// VerifyIDToken validates the signature, issuer, audience, expiry and nonce of rawToken.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, clientID, nonce string) (*OIDCIdentity, error) {
+	claims := &oidcIDTokenClaims{}
+	token, errParse := jwt.ParseWithClaims(rawToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if errParse != nil {
+		return nil, fmt.Errorf("oidc: verify id token: %w", errParse)
+	}
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, ErrOIDCNonceMismatch
+	}
+	identity := &OIDCIdentity{
+		Issuer:               p.issuer,
+		Subject:              claims.Subject,
+		Email:                strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified:        claims.EmailVerified != nil && *claims.EmailVerified,
+		EmailVerifiedMissing: claims.EmailVerified == nil,
+		Name:                 strings.TrimSpace(claims.Name),
+	}
+	return identity, nil
+}
+
+// key returns the public key for kid, refetching the JWKS once when kid is unknown.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	key, ok := p.lookupKeyLocked(kid)
+	stale := time.Since(p.keysFetched) >= oidcKeysMinRefresh
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, ErrOIDCUnknownKey
+	}
+	if errRefresh := p.refreshKeys(ctx); errRefresh != nil {
+		return nil, errRefresh
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok = p.lookupKeyLocked(kid); ok {
+		return key, nil
+	}
+	return nil, ErrOIDCUnknownKey
+}
+
+// lookupKeyLocked finds kid in the cached keys; an empty kid matches a sole key.
+func (p *OIDCProvider) lookupKeyLocked(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// refreshKeys fetches and caches the issuer signing keys.
+func (p *OIDCProvider) refreshKeys(ctx context.Context) error {
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if errFetch := fetchOIDCJSON(ctx, p.client, p.jwksURI, &set); errFetch != nil {
+		return fmt.Errorf("oidc: fetch jwks: %w", errFetch)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, errKey := jwk.publicKey()
+		if errKey != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("oidc: jwks has no usable signing keys")
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// publicKey decodes the JWK into an RSA or ECDSA public key.
+func (k oidcJWK) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, errN := decodeJWKInt(k.N)
+		if errN != nil {
+			return nil, errN
+		}
+		e, errE := decodeJWKInt(k.E)
+		if errE != nil {
+			return nil, errE
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("oidc: invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("oidc: unsupported curve %q", k.Crv)
+		}
+		x, errX := decodeJWKInt(k.X)
+		if errX != nil {
+			return nil, errX
+		}
+		y, errY := decodeJWKInt(k.Y)
+		if errY != nil {
+			return nil, errY
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("oidc: ec point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("oidc: unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeJWKInt decodes a base64url big-endian integer.
+func decodeJWKInt(raw string) (*big.Int, error) {
+	decoded, errDecode := base64.RawURLEncoding.DecodeString(strings.TrimRight(raw, "="))
+	if errDecode != nil || len(decoded) == 0 {
+		return nil, errors.New("oidc: invalid jwk integer")
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+// fetchOIDCJSON GETs url and decodes the JSON response into out.
+func fetchOIDCJSON(ctx context.Context, client *http.Client, url string, out any) error {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if errReq != nil {
+		return errReq
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return errDo
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseBytes)).Decode(out)
+}
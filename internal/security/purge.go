@@ -0,0 +1,64 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// userPurgeAudience scopes purge confirmation tokens.
+const userPurgeAudience = "user-purge"
+
+// userPurgeClaims binds a purge confirmation to one user and the admin who asked for it.
+type userPurgeClaims struct {
+	AdminID uint64 `json:"admin_id"`
+	jwt.RegisteredClaims
+}
+
+// GenerateUserPurgeToken signs a short-lived confirmation for purging userID.
+func GenerateUserPurgeToken(secret string, userID, adminID uint64, expiry time.Duration) (string, error) {
+	now := time.Now().UTC()
+	claims := userPurgeClaims{
+		AdminID: adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(userID, 10),
+			Audience:  jwt.ClaimStrings{userPurgeAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(userPurgeKey(secret))
+}
+
+// VerifyUserPurgeToken checks that tokenString confirms purging userID by adminID.
+func VerifyUserPurgeToken(secret, tokenString string, userID, adminID uint64) error {
+	token, err := jwt.ParseWithClaims(tokenString, &userPurgeClaims{}, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return userPurgeKey(secret), nil
+	}, jwt.WithAudience(userPurgeAudience))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return ErrExpiredToken
+		}
+		return ErrInvalidToken
+	}
+	claims, ok := token.Claims.(*userPurgeClaims)
+	if !ok || !token.Valid || claims.Subject != strconv.FormatUint(userID, 10) || claims.AdminID != adminID {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// userPurgeKey derives the signing key so purge tokens never verify as other tokens.
+func userPurgeKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userPurgeAudience))
+	return mac.Sum(nil)
+}
@@ -23,13 +23,13 @@ const (
 // NewWebAuthn builds a WebAuthn configuration using DB-backed overrides.
 func NewWebAuthn() (*webauthn.WebAuthn, error) {
 	rpName := webAuthnRPName
-	if override := dbConfigString("WEB_AUTHN_RP_NAME"); override != "" {
+	if override := internalsettings.GetString(internalsettings.WebAuthnRPNameKey); override != "" {
 		rpName = override
 	}
 
-	origins := dbConfigStrings("WEB_AUTHN_ORIGINS")
+	origins := dbConfigStrings(internalsettings.WebAuthnOriginsKey)
 	if len(origins) == 0 {
-		if override := dbConfigString("WEB_AUTHN_ORIGIN"); override != "" {
+		if override := internalsettings.GetString(internalsettings.WebAuthnOriginKey); override != "" {
 			origins = []string{override}
 		}
 	}
@@ -38,7 +38,7 @@ func NewWebAuthn() (*webauthn.WebAuthn, error) {
 	}
 
 	rpID := webAuthnRPID
-	if override := dbConfigString("WEB_AUTHN_RPID"); override != "" {
+	if override := internalsettings.GetString(internalsettings.WebAuthnRPIDKey); override != "" {
 		rpID = override
 	} else if derived := deriveRPIDFromOrigins(origins); derived != "" {
 		rpID = derived
@@ -74,15 +74,6 @@ func originHost(origin string) string {
 	return strings.TrimSpace(parsed.Hostname())
 }
 
-// dbConfigString reads a string from the DB config snapshot.
-func dbConfigString(key string) string {
-	raw, ok := internalsettings.DBConfigValue(key)
-	if !ok {
-		return ""
-	}
-	return parseDBConfigString(raw)
-}
-
 // parseDBConfigString extracts a string value from JSON config payloads.
 func parseDBConfigString(raw json.RawMessage) string {
 	raw = bytes.TrimSpace(raw)
@@ -0,0 +1,175 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// resolveValues decodes every schema key from values, substituting the registered
+// default when a key is unset or its value does not fit the spec.
+func resolveValues(values map[string]json.RawMessage) map[string]any {
+	resolved := make(map[string]any, len(schema))
+	for key, spec := range schema {
+		raw, ok := values[key]
+		if !ok {
+			resolved[key] = spec.Default
+			continue
+		}
+		if value, okDecode := decodeValue(spec, raw); okDecode {
+			resolved[key] = value
+			continue
+		}
+		resolved[key] = spec.Default
+	}
+	return resolved
+}
+
+// decodeValue converts raw into the Go type of spec. Legacy {"value": ...} wrappers are
+// unwrapped, integers below Min are rejected, integers above Max are clamped to it and
+// enum values match case-insensitively.
+func decodeValue(spec Spec, raw json.RawMessage) (any, bool) {
+	raw = unwrapValue(raw)
+	switch spec.Type {
+	case TypeBool:
+		return ParseBool(raw)
+	case TypeInt:
+		value, ok := ParseInt(raw)
+		if !ok || value < spec.Min {
+			return nil, false
+		}
+		if spec.Max > 0 && value > spec.Max {
+			value = spec.Max
+		}
+		return value, true
+	case TypeString:
+		var value string
+		if errUnmarshal := json.Unmarshal(raw, &value); errUnmarshal != nil {
+			return nil, false
+		}
+		value = strings.TrimSpace(value)
+		if len(spec.Enum) > 0 {
+			canonical, ok := matchEnum(spec.Enum, value)
+			if !ok {
+				return nil, false
+			}
+			value = canonical
+		}
+		if spec.Check != nil && spec.Check(value) != nil {
+			return nil, false
+		}
+		return value, true
+	case TypeStringList:
+		var items []string
+		if errUnmarshal := json.Unmarshal(raw, &items); errUnmarshal != nil {
+			var single string
+			if errString := json.Unmarshal(raw, &single); errString != nil {
+				return nil, false
+			}
+			items = []string{single}
+		}
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if len(spec.Enum) > 0 {
+				canonical, ok := matchEnum(spec.Enum, item)
+				if !ok {
+					return nil, false
+				}
+				item = canonical
+			}
+			values = append(values, item)
+		}
+		return values, true
+	default:
+		return nil, false
+	}
+}
+
+// unwrapValue returns the inner value of a {"value": ...} object, or raw unchanged.
+func unwrapValue(raw json.RawMessage) json.RawMessage {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return raw
+	}
+	var wrapper struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if errUnmarshal := json.Unmarshal(raw, &wrapper); errUnmarshal != nil || len(wrapper.Value) == 0 {
+		return raw
+	}
+	return unwrapValue(wrapper.Value)
+}
+
+// matchEnum returns the entry of values equal to value, ignoring case.
+func matchEnum(values []string, value string) (string, bool) {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// lookupResolved returns the decoded value of key from the current snapshot. Keys
+// outside the schema are decoded on demand as the given type without a default.
+func lookupResolved(key string, valueType ValueType) (any, bool) {
+	key = strings.TrimSpace(key)
+	cfg := loadDBConfig()
+	if _, known := schema[key]; known {
+		value, ok := cfg.resolved[key]
+		return value, ok
+	}
+	raw, ok := cfg.values[key]
+	if !ok {
+		return nil, false
+	}
+	return decodeValue(Spec{Type: valueType}, raw)
+}
+
+// GetBool returns the boolean value of key, or its registered default when the value
+// is unset or not a boolean.
+func GetBool(key string) bool {
+	value, _ := lookupResolved(key, TypeBool)
+	typed, _ := value.(bool)
+	return typed
+}
+
+// GetInt returns the integer value of key. Unset, malformed and below-minimum values
+// yield the registered default; values above the maximum are clamped to it.
+func GetInt(key string) int {
+	value, _ := lookupResolved(key, TypeInt)
+	typed, _ := value.(int)
+	return typed
+}
+
+// GetString returns the trimmed string value of key, or its registered default when the
+// value is unset, not a string or rejected by the key's enum or check.
+func GetString(key string) string {
+	value, _ := lookupResolved(key, TypeString)
+	typed, _ := value.(string)
+	return typed
+}
+
+// GetStringList returns the trimmed, non-empty entries of key and whether the key holds a
+// valid list. A single string counts as a one-entry list; an explicit empty array yields
+// an empty, non-nil slice.
+func GetStringList(key string) ([]string, bool) {
+	value, _ := lookupResolved(key, TypeStringList)
+	typed, ok := value.([]string)
+	return typed, ok
+}
+
+// GetDuration returns the integer value of key scaled by the key's Unit, seconds when
+// no unit is registered.
+func GetDuration(key string) time.Duration {
+	unit := time.Second
+	if spec, ok := LookupSpec(key); ok && spec.Unit > 0 {
+		unit = spec.Unit
+	}
+	return time.Duration(GetInt(key)) * unit
+}
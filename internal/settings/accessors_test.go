@@ -0,0 +1,225 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchemaDefaultsMatchTypes(t *testing.T) {
+	for key, spec := range schema {
+		if spec.Default == nil {
+			continue
+		}
+		var want reflect.Kind
+		switch spec.Type {
+		case TypeBool:
+			want = reflect.Bool
+		case TypeInt:
+			want = reflect.Int
+		case TypeString:
+			want = reflect.String
+		default:
+			t.Fatalf("%s: default registered for unsupported type %s", key, spec.Type)
+		}
+		if got := reflect.TypeOf(spec.Default).Kind(); got != want {
+			t.Fatalf("%s: default has kind %s, want %s", key, got, want)
+		}
+	}
+}
+
+func TestTypedGettersFallBackToDefaults(t *testing.T) {
+	t.Cleanup(func() { StoreDBConfig(time.Now(), nil) })
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		QuotaPollIntervalSecondsKey: json.RawMessage(`0`),
+		QuotaPollMaxConcurrencyKey:  json.RawMessage(`500`),
+		AllowRegistrationKey:        json.RawMessage(`"maybe"`),
+		AuthCandidateOrderKey:       json.RawMessage(`"random"`),
+		RateLimitKey:                json.RawMessage(`"abc"`),
+	})
+
+	if got := GetDuration(QuotaPollIntervalSecondsKey); got != DefaultQuotaPollIntervalSeconds*time.Second {
+		t.Fatalf("expected below-minimum interval to use the default, got %s", got)
+	}
+	if got := GetInt(QuotaPollMaxConcurrencyKey); got != MaxQuotaPollMaxConcurrency {
+		t.Fatalf("expected concurrency clamped to %d, got %d", MaxQuotaPollMaxConcurrency, got)
+	}
+	if got := GetBool(AllowRegistrationKey); got != DefaultAllowRegistration {
+		t.Fatalf("expected malformed bool to use the default, got %v", got)
+	}
+	if got := GetString(AuthCandidateOrderKey); got != DefaultAuthCandidateOrder {
+		t.Fatalf("expected unknown enum value to use the default, got %q", got)
+	}
+	if got := GetInt(RateLimitKey); got != DefaultRateLimit {
+		t.Fatalf("expected malformed int to use the default, got %d", got)
+	}
+	if got := GetInt(SMTPPortKey); got != DefaultSMTPPort {
+		t.Fatalf("expected unset port to use the default, got %d", got)
+	}
+}
+
+func TestTypedGettersDecodeValues(t *testing.T) {
+	t.Cleanup(func() { StoreDBConfig(time.Now(), nil) })
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		RateLimitDBEnabledKey:        json.RawMessage(`"yes"`),
+		RegistrationRequireInviteKey: json.RawMessage(`1`),
+		AutoAssignProxyKey:           json.RawMessage(`{"value":true}`),
+		RateLimitKey:                 json.RawMessage(`"25"`),
+		AuthCandidateOrderKey:        json.RawMessage(`" Priority "`),
+		UsageRetentionDaysKey:        json.RawMessage(`7`),
+		SMTPHostKey:                  json.RawMessage(`" smtp.example.com "`),
+		"CUSTOM_FLAG":                json.RawMessage(`"on"`),
+	})
+
+	if !GetBool(RateLimitDBEnabledKey) || !GetBool(RegistrationRequireInviteKey) || !GetBool(AutoAssignProxyKey) {
+		t.Fatal("expected boolean-like values to decode as true")
+	}
+	if got := GetInt(RateLimitKey); got != 25 {
+		t.Fatalf("expected numeric string to decode, got %d", got)
+	}
+	if got := GetString(AuthCandidateOrderKey); got != AuthCandidateOrderPriority {
+		t.Fatalf("expected enum to match case-insensitively, got %q", got)
+	}
+	if got := GetDuration(UsageRetentionDaysKey); got != 7*24*time.Hour {
+		t.Fatalf("expected day unit, got %s", got)
+	}
+	if got := GetString(SMTPHostKey); got != "smtp.example.com" {
+		t.Fatalf("expected trimmed string, got %q", got)
+	}
+	if !GetBool("CUSTOM_FLAG") {
+		t.Fatal("expected keys outside the schema to decode on demand")
+	}
+}
+
+func TestOnChangeReportsChangedKeys(t *testing.T) {
+	t.Cleanup(func() { StoreDBConfig(time.Now(), nil) })
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		RateLimitKey: json.RawMessage(`1`),
+		SMTPHostKey:  json.RawMessage(`"a"`),
+	})
+
+	var calls [][]string
+	unsubscribe := OnChange(func(changed []string) { calls = append(calls, changed) })
+
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		RateLimitKey:    json.RawMessage(`2`),
+		SMTPPortKey:     json.RawMessage(`25`),
+		SMTPHostKey:     json.RawMessage(`"a"`),
+		SiteNameKey:     json.RawMessage(`"Acme"`),
+		SMTPFromKey:     json.RawMessage(`"x@example.com"`),
+		WebUIEnabledKey: json.RawMessage(`true`),
+	})
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		RateLimitKey:    json.RawMessage(`2`),
+		SMTPPortKey:     json.RawMessage(`25`),
+		SMTPHostKey:     json.RawMessage(`"a"`),
+		SiteNameKey:     json.RawMessage(`"Acme"`),
+		SMTPFromKey:     json.RawMessage(`"x@example.com"`),
+		WebUIEnabledKey: json.RawMessage(`true`),
+	})
+	unsubscribe()
+	StoreDBConfig(time.Now(), nil)
+
+	if len(calls) != 1 {
+		t.Fatalf("expected one notification, got %d: %v", len(calls), calls)
+	}
+	want := []string{RateLimitKey, SiteNameKey, SMTPFromKey, SMTPPortKey, WebUIEnabledKey}
+	if !reflect.DeepEqual(calls[0], want) {
+		t.Fatalf("expected changed keys %v, got %v", want, calls[0])
+	}
+}
+
+func TestConcurrentStoreAndGet(t *testing.T) {
+	t.Cleanup(func() { StoreDBConfig(time.Now(), nil) })
+
+	var readers sync.WaitGroup
+	var mu sync.Mutex
+	seen := 0
+	unsubscribe := OnChange(func(changed []string) {
+		mu.Lock()
+		seen += len(changed)
+		mu.Unlock()
+		_ = GetInt(RateLimitKey)
+	})
+	defer unsubscribe()
+
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if limit := GetInt(RateLimitKey); limit < 0 {
+					t.Errorf("unexpected negative rate limit %d", limit)
+				}
+				_ = GetBool(RateLimitRedisEnabledKey)
+				_ = GetString(RateLimitRedisPrefixKey)
+				_ = GetDuration(QuotaPollIntervalSecondsKey)
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < 200; i++ {
+				StoreDBConfig(time.Now(), map[string]json.RawMessage{
+					RateLimitKey:             json.RawMessage(fmt.Sprintf("%d", w*1000+i)),
+					RateLimitRedisEnabledKey: json.RawMessage(`true`),
+					RateLimitRedisPrefixKey:  json.RawMessage(fmt.Sprintf(`"p%d"`, i)),
+				})
+			}
+		}(w)
+	}
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if seen == 0 {
+		t.Fatal("expected listeners to observe changes")
+	}
+}
+
+func TestOAuthProviderEnabledFollowsAllowlist(t *testing.T) {
+	t.Cleanup(func() { StoreDBConfig(time.Now(), nil) })
+
+	StoreDBConfig(time.Now(), nil)
+	if !OAuthProviderEnabled("codex") {
+		t.Fatal("expected every flow to be enabled while the allowlist is unset")
+	}
+
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		EnabledOAuthProvidersKey: json.RawMessage(`[" Anthropic ", "gemini", ""]`),
+	})
+	if list, ok := GetStringList(EnabledOAuthProvidersKey); !ok || !reflect.DeepEqual(list, []string{"anthropic", "gemini"}) {
+		t.Fatalf("expected canonical entries, got %v (ok=%v)", list, ok)
+	}
+	if !OAuthProviderEnabled("anthropic") || !OAuthProviderEnabled("Gemini") {
+		t.Fatal("expected listed providers to be enabled")
+	}
+	if OAuthProviderEnabled("codex") || OAuthProviderEnabled("iflow") {
+		t.Fatal("expected unlisted providers to be disabled")
+	}
+
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{EnabledOAuthProvidersKey: json.RawMessage(`[]`)})
+	if OAuthProviderEnabled("anthropic") {
+		t.Fatal("expected an empty allowlist to disable every flow")
+	}
+
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{EnabledOAuthProvidersKey: json.RawMessage(`["openai"]`)})
+	if !OAuthProviderEnabled("codex") {
+		t.Fatal("expected an invalid allowlist to fall back to every flow")
+	}
+}
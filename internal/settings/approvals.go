@@ -0,0 +1,16 @@
+package settings
+
+// ApprovalRequired reports whether APPROVAL_REQUIRED_ACTIONS lists action. No action
+// needs approval while the setting is unset or invalid.
+func ApprovalRequired(action string) bool {
+	actions, ok := GetStringList(ApprovalRequiredActionsKey)
+	if !ok {
+		return false
+	}
+	for _, name := range actions {
+		if name == action {
+			return true
+		}
+	}
+	return false
+}
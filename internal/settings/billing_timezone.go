@@ -0,0 +1,54 @@
+package settings
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	// Embed the zone database so BILLING_TIMEZONE works on hosts without one.
+	_ "time/tzdata"
+)
+
+// billingLocations caches loaded BILLING_TIMEZONE locations by name.
+var billingLocations sync.Map
+
+// CheckTimezone validates an IANA time zone name such as "Europe/Berlin". "Local" is
+// rejected because it would depend on the server's environment.
+func CheckTimezone(value string) error {
+	if value == "" {
+		return nil
+	}
+	if value == "Local" {
+		return errors.New("time zone must be an IANA name, not Local")
+	}
+	if _, errLoad := time.LoadLocation(value); errLoad != nil {
+		return errors.New("time zone must be an IANA name such as Europe/Berlin")
+	}
+	return nil
+}
+
+// BillingLocation returns the BILLING_TIMEZONE location. An empty or invalid value
+// selects UTC.
+func BillingLocation() *time.Location {
+	name := strings.TrimSpace(GetString(BillingTimezoneKey))
+	if name == "" || name == "UTC" {
+		return time.UTC
+	}
+	if cached, ok := billingLocations.Load(name); ok {
+		return cached.(*time.Location)
+	}
+	if CheckTimezone(name) != nil {
+		return time.UTC
+	}
+	loc, _ := time.LoadLocation(name)
+	billingLocations.Store(name, loc)
+	return loc
+}
+
+// BillingDayStart returns the midnight that starts the billing day containing t, in the
+// BILLING_TIMEZONE location.
+func BillingDayStart(t time.Time) time.Time {
+	loc := BillingLocation()
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
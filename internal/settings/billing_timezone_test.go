@@ -0,0 +1,47 @@
+package settings
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBillingDayStartAcrossDST(t *testing.T) {
+	t.Cleanup(func() { StoreDBConfig(time.Now(), nil) })
+
+	StoreDBConfig(time.Now(), nil)
+	if got := BillingDayStart(time.Date(2026, 3, 8, 23, 30, 0, 0, time.UTC)); !got.Equal(time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected UTC days by default, got %s", got)
+	}
+
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{BillingTimezoneKey: json.RawMessage(`"America/New_York"`)})
+	cases := []struct {
+		now       time.Time
+		wantStart time.Time
+		wantHours float64
+	}{
+		// Clocks spring forward at 02:00 on 2026-03-08, so that day has 23 hours.
+		{time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 8, 5, 0, 0, 0, time.UTC), 23},
+		// 00:30 local on the next day is still 04:30 UTC.
+		{time.Date(2026, 3, 9, 4, 30, 0, 0, time.UTC), time.Date(2026, 3, 9, 4, 0, 0, 0, time.UTC), 24},
+		// Clocks fall back at 02:00 on 2026-11-01, so that day has 25 hours.
+		{time.Date(2026, 11, 2, 4, 59, 0, 0, time.UTC), time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC), 25},
+		{time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC), time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC), 24},
+	}
+	for _, tc := range cases {
+		start := BillingDayStart(tc.now)
+		if !start.Equal(tc.wantStart) {
+			t.Fatalf("%s: expected day start %s, got %s", tc.now, tc.wantStart, start.UTC())
+		}
+		// 25 hours after any day start falls within the following day.
+		next := BillingDayStart(start.Add(25 * time.Hour))
+		if hours := next.Sub(start).Hours(); hours != tc.wantHours {
+			t.Fatalf("%s: expected a %v hour day, got %v", tc.now, tc.wantHours, hours)
+		}
+	}
+
+	StoreDBConfig(time.Now(), map[string]json.RawMessage{BillingTimezoneKey: json.RawMessage(`"Mars/Olympus"`)})
+	if BillingLocation() != time.UTC {
+		t.Fatal("expected an invalid zone to fall back to UTC")
+	}
+}
@@ -10,10 +10,17 @@ const (
 	QuotaPollIntervalSecondsKey = "QUOTA_POLL_INTERVAL_SECONDS"
 	// QuotaPollMaxConcurrencyKey controls the max concurrent quota requests.
 	QuotaPollMaxConcurrencyKey = "QUOTA_POLL_MAX_CONCURRENCY"
+	// QuotaHistoryIntervalSecondsKey controls how often an unchanged quota snapshot is
+	// still appended to quota_history.
+	QuotaHistoryIntervalSecondsKey = "QUOTA_HISTORY_INTERVAL_SECONDS"
+	// QuotaHistoryRetentionDaysKey controls how many days of quota history are kept.
+	QuotaHistoryRetentionDaysKey = "QUOTA_HISTORY_RETENTION_DAYS"
 	// AutoAssignProxyKey toggles auto assignment of proxies on create.
 	AutoAssignProxyKey = "AUTO_ASSIGN_PROXY"
 	// RateLimitKey controls the default rate limit per second.
 	RateLimitKey = "RATE_LIMIT"
+	// RateLimitDBEnabledKey toggles database-backed rate limit counters that survive restarts.
+	RateLimitDBEnabledKey = "RATE_LIMIT_DB_ENABLED"
 	// RateLimitRedisEnabledKey toggles Redis-backed rate limiting.
 	RateLimitRedisEnabledKey = "RATE_LIMIT_REDIS_ENABLED"
 	// RateLimitRedisAddrKey defines the Redis address for rate limiting.
@@ -24,14 +31,311 @@ const (
 	RateLimitRedisDBKey = "RATE_LIMIT_REDIS_DB"
 	// RateLimitRedisPrefixKey defines the Redis key prefix for rate limiting.
 	RateLimitRedisPrefixKey = "RATE_LIMIT_REDIS_PREFIX"
+	// AllowRegistrationKey toggles public self-registration.
+	AllowRegistrationKey = "ALLOW_REGISTRATION"
+	// RegistrationRequireInviteKey requires a valid invite code to register.
+	RegistrationRequireInviteKey = "REGISTRATION_REQUIRE_INVITE"
+	// RegistrationVerifyURLKey defines the public base URL used in verification links; registration is refused while unset.
+	RegistrationVerifyURLKey = "REGISTRATION_VERIFY_URL"
+	// SMTPHostKey defines the SMTP server host for outgoing mail.
+	SMTPHostKey = "SMTP_HOST"
+	// SMTPPortKey defines the SMTP server port for outgoing mail.
+	SMTPPortKey = "SMTP_PORT"
+	// SMTPUsernameKey defines the SMTP auth username.
+	SMTPUsernameKey = "SMTP_USERNAME"
+	// SMTPPasswordKey defines the SMTP auth password.
+	SMTPPasswordKey = "SMTP_PASSWORD"
+	// SMTPFromKey defines the sender address for outgoing mail.
+	SMTPFromKey = "SMTP_FROM"
+	// ImpersonationTokenTTLSecondsKey controls the lifetime of admin impersonation tokens.
+	ImpersonationTokenTTLSecondsKey = "IMPERSONATION_TOKEN_TTL_SECONDS"
+	// OnlyMappedModelsKey limits model listings to configured model mappings.
+	OnlyMappedModelsKey = "ONLY_MAPPED_MODELS"
+	// WebAuthnRPIDKey defines the WebAuthn relying party ID.
+	WebAuthnRPIDKey = "WEB_AUTHN_RPID"
+	// WebAuthnRPNameKey defines the WebAuthn relying party display name.
+	WebAuthnRPNameKey = "WEB_AUTHN_RP_NAME"
+	// WebAuthnOriginKey defines a single allowed WebAuthn origin.
+	WebAuthnOriginKey = "WEB_AUTHN_ORIGIN"
+	// WebAuthnOriginsKey defines the list of allowed WebAuthn origins.
+	WebAuthnOriginsKey = "WEB_AUTHN_ORIGINS"
+	// UsageRetentionDaysKey controls how many days of raw usage rows are kept.
+	UsageRetentionDaysKey = "USAGE_RETENTION_DAYS"
+	// OIDCEnabledKey toggles admin login through an external OpenID Connect provider.
+	OIDCEnabledKey = "OIDC_ENABLED"
+	// OIDCIssuerKey defines the OpenID Connect issuer URL used for discovery.
+	OIDCIssuerKey = "OIDC_ISSUER"
+	// OIDCClientIDKey defines the OAuth client ID registered with the issuer.
+	OIDCClientIDKey = "OIDC_CLIENT_ID"
+	// OIDCClientSecretKey defines the OAuth client secret registered with the issuer.
+	OIDCClientSecretKey = "OIDC_CLIENT_SECRET"
+	// OIDCRedirectURLKey defines the public callback URL registered with the issuer.
+	OIDCRedirectURLKey = "OIDC_REDIRECT_URL"
+	// OIDCAllowedDomainsKey limits SSO logins to email addresses in these domains.
+	OIDCAllowedDomainsKey = "OIDC_ALLOWED_DOMAINS"
+	// OIDCAutoProvisionKey creates an admin without permissions on first SSO login.
+	OIDCAutoProvisionKey = "OIDC_AUTO_PROVISION"
+	// OIDCTrustMissingEmailVerifiedKey accepts ID tokens that omit the email_verified claim.
+	OIDCTrustMissingEmailVerifiedKey = "OIDC_TRUST_MISSING_EMAIL_VERIFIED"
+	// APIKeyIdleRevokeDaysKey disables API keys unused for this many days (0 disables).
+	APIKeyIdleRevokeDaysKey = "API_KEY_IDLE_REVOKE_DAYS"
+	// APIKeyMaxPerUserKey caps how many unrevoked API keys a user may create (0 is unlimited).
+	APIKeyMaxPerUserKey = "API_KEY_MAX_PER_USER"
+	// AuthPriorityDecayThresholdKey is how many quota errors within the window lower an auth's priority (0 disables).
+	AuthPriorityDecayThresholdKey = "AUTH_PRIORITY_DECAY_THRESHOLD"
+	// AuthPriorityDecayWindowSecondsKey is the window in which quota errors are counted.
+	AuthPriorityDecayWindowSecondsKey = "AUTH_PRIORITY_DECAY_WINDOW_SECONDS"
+	// AuthPriorityDecayStepKey is how far each decay lowers the effective priority.
+	AuthPriorityDecayStepKey = "AUTH_PRIORITY_DECAY_STEP"
+	// AuthPriorityRestoreSecondsKey is the clean period after which a decayed priority is restored.
+	AuthPriorityRestoreSecondsKey = "AUTH_PRIORITY_RESTORE_SECONDS"
+	// AuthCandidateOrderKey selects how available auths are ordered before the selector runs.
+	AuthCandidateOrderKey = "AUTH_CANDIDATE_ORDER"
+	// ModelOverrideHeaderKey selects which API keys may rewrite the model with X-Model-Override.
+	ModelOverrideHeaderKey = "MODEL_OVERRIDE_HEADER"
+	// WebUIEnabledKey toggles serving the embedded web panel on the main server.
+	WebUIEnabledKey = "WEB_UI_ENABLED"
+	// WebUIPathPrefixKey is the path the web panel is mounted under.
+	WebUIPathPrefixKey = "WEB_UI_PATH_PREFIX"
+	// UsageAlertWebhookURLKey is the URL that receives a JSON POST for every usage alert.
+	UsageAlertWebhookURLKey = "USAGE_ALERT_WEBHOOK_URL"
+	// UsageAlertEmailEnabledKey toggles emailing users when a usage alert fires.
+	UsageAlertEmailEnabledKey = "USAGE_ALERT_EMAIL_ENABLED"
+	// BillWebhookURLKey is the URL that receives a JSON POST for bill paid, renewed and
+	// expired events.
+	BillWebhookURLKey = "BILL_WEBHOOK_URL"
+	// BillWebhookSecretKey signs bill webhook bodies with HMAC-SHA256 when set.
+	BillWebhookSecretKey = "BILL_WEBHOOK_SECRET"
+	// WatcherDispatchMaxPendingKey caps the auth updates waiting for dispatch to the core
+	// manager; updates beyond it are dropped and re-sent by a later auth poll.
+	WatcherDispatchMaxPendingKey = "WATCHER_DISPATCH_MAX_PENDING"
+	// EnabledOAuthProvidersKey lists the providers whose admin token-request OAuth flows
+	// are served; unset serves every flow.
+	EnabledOAuthProvidersKey = "ENABLED_OAUTH_PROVIDERS"
+	// ProviderKeyAuthFailureThresholdKey is how many consecutive upstream 401/403 responses
+	// degrade a provider API key (0 disables).
+	ProviderKeyAuthFailureThresholdKey = "PROVIDER_KEY_AUTH_FAILURE_THRESHOLD"
+	// ProviderKeyWebhookURLKey is the URL that receives a JSON POST when a provider API key
+	// is degraded.
+	ProviderKeyWebhookURLKey = "PROVIDER_KEY_WEBHOOK_URL"
+	// AuthFileValidationKey selects whether auth file content that misses required token
+	// fields is rejected or only logged on create and import.
+	AuthFileValidationKey = "AUTH_FILE_VALIDATION"
+	// ModelListCacheTTLSecondsKey is how long computed model lists are served from memory
+	// (0 disables caching).
+	ModelListCacheTTLSecondsKey = "MODEL_LIST_CACHE_TTL_SECONDS"
+	// DisplayCurrencyKey is the ISO 4217 code that labels formatted amounts in dashboards
+	// and summaries. Amounts are never converted.
+	DisplayCurrencyKey = "DISPLAY_CURRENCY"
+	// PrepaidDeductionOrderKey selects which prepaid cards are drawn down first when usage
+	// or a plan purchase is paid from prepaid balance.
+	PrepaidDeductionOrderKey = "PREPAID_DEDUCTION_ORDER"
+	// UsageBillingModeKey selects whether usage is charged in the transaction that records
+	// it or later, in per-user batches, by the billing reconciler.
+	UsageBillingModeKey = "USAGE_BILLING_MODE"
+	// UsageBillingIntervalSecondsKey controls how often the billing reconciler charges
+	// usage recorded in deferred mode.
+	UsageBillingIntervalSecondsKey = "USAGE_BILLING_INTERVAL_SECONDS"
+	// BillingTimezoneKey is the IANA time zone whose midnight starts a billing day, for
+	// daily quotas and dashboard day buckets.
+	BillingTimezoneKey = "BILLING_TIMEZONE"
+	// JobRetentionDaysKey controls how many days finished background jobs are kept
+	// (0 keeps them forever).
+	JobRetentionDaysKey = "JOB_RETENTION_DAYS"
+	// AuthTrashRetentionDaysKey controls how many days deleted auth files stay in the trash
+	// before they are purged (0 keeps them forever).
+	AuthTrashRetentionDaysKey = "AUTH_TRASH_RETENTION_DAYS"
+	// BackupIntervalHoursKey controls how many hours pass between scheduled database
+	// backups (0 disables scheduled backups).
+	BackupIntervalHoursKey = "BACKUP_INTERVAL_HOURS"
+	// BackupRetentionCountKey controls how many database backups are kept (0 keeps all).
+	BackupRetentionCountKey = "BACKUP_RETENTION_COUNT"
+	// ApprovalRequiredActionsKey lists the destructive admin actions that wait for a second
+	// admin's approval instead of running; unset requires none.
+	ApprovalRequiredActionsKey = "APPROVAL_REQUIRED_ACTIONS"
 	// DefaultQuotaPollIntervalSeconds is the fallback poll interval (seconds).
 	DefaultQuotaPollIntervalSeconds = 180
 	// DefaultQuotaPollMaxConcurrency is the fallback max concurrency.
 	DefaultQuotaPollMaxConcurrency = 5
+	// MaxQuotaPollMaxConcurrency caps concurrent quota requests so providers are not flooded.
+	MaxQuotaPollMaxConcurrency = 50
+	// DefaultQuotaHistoryIntervalSeconds records an unchanged quota snapshot once per hour.
+	DefaultQuotaHistoryIntervalSeconds = 3600
+	// DefaultQuotaHistoryRetentionDays keeps 30 days of quota history (0 keeps it forever).
+	DefaultQuotaHistoryRetentionDays = 30
+	// DefaultWatcherDispatchMaxPending bounds pending auth updates (0 means unbounded).
+	DefaultWatcherDispatchMaxPending = 10000
+	// DefaultProviderKeyAuthFailureThreshold degrades a provider API key after three
+	// consecutive auth failures.
+	DefaultProviderKeyAuthFailureThreshold = 3
+	// DefaultModelListCacheTTLSeconds serves cached model lists for up to 15 seconds.
+	DefaultModelListCacheTTLSeconds = 15
 	// DefaultAutoAssignProxy sets auto-assign proxy default.
 	DefaultAutoAssignProxy = false
 	// DefaultRateLimit is the fallback rate limit (0 means unlimited).
 	DefaultRateLimit = 0
+	// DefaultAllowRegistration opens self-registration once REGISTRATION_VERIFY_URL is set.
+	DefaultAllowRegistration = true
+	// DefaultRegistrationRequireInvite leaves invite codes optional by default.
+	DefaultRegistrationRequireInvite = false
+	// DefaultSMTPPort is the fallback SMTP submission port.
+	DefaultSMTPPort = 587
+	// DefaultImpersonationTokenTTLSeconds is the fallback impersonation token lifetime.
+	DefaultImpersonationTokenTTLSeconds = 900
+	// MaxImpersonationTokenTTLSeconds caps impersonation token lifetime at one hour.
+	MaxImpersonationTokenTTLSeconds = 3600
 	// DefaultRateLimitRedisPrefix is the fallback Redis key prefix.
 	DefaultRateLimitRedisPrefix = "cpab:rl"
+	// DefaultUsageRetentionDays keeps raw usage rows forever (0 disables retention).
+	DefaultUsageRetentionDays = 0
+	// DefaultAPIKeyIdleRevokeDays never revokes idle API keys automatically.
+	DefaultAPIKeyIdleRevokeDays = 0
+	// DefaultAPIKeyMaxPerUser is the fallback per-user API key limit.
+	DefaultAPIKeyMaxPerUser = 20
+	// DefaultAuthPriorityDecayThreshold lowers priority after three quota errors in the window.
+	DefaultAuthPriorityDecayThreshold = 3
+	// DefaultAuthPriorityDecayWindowSeconds counts quota errors over one hour.
+	DefaultAuthPriorityDecayWindowSeconds = 3600
+	// DefaultAuthPriorityDecayStep is the fallback priority decrement per decay.
+	DefaultAuthPriorityDecayStep = 10
+	// DefaultAuthPriorityRestoreSeconds restores priority after one clean hour.
+	DefaultAuthPriorityRestoreSeconds = 3600
+	// DefaultAuthCandidateOrder keeps the stable auth ID ordering.
+	DefaultAuthCandidateOrder = AuthCandidateOrderID
+	// DefaultModelOverrideHeader ignores X-Model-Override until it is enabled.
+	DefaultModelOverrideHeader = ModelOverrideDisabled
+	// DefaultAuthFileValidation rejects auth files missing required token fields.
+	DefaultAuthFileValidation = AuthFileValidationStrict
+	// DefaultDisplayCurrency labels amounts in US dollars.
+	DefaultDisplayCurrency = "USD"
+	// DefaultPrepaidDeductionOrder spends the cards that expire soonest first.
+	DefaultPrepaidDeductionOrder = PrepaidDeductionSoonestExpiry
+	// DefaultUsageBillingMode charges each usage row as it is recorded.
+	DefaultUsageBillingMode = UsageBillingModeSync
+	// DefaultUsageBillingIntervalSeconds charges deferred usage every ten seconds.
+	DefaultUsageBillingIntervalSeconds = 10
+	// DefaultBillingTimezone starts billing days at UTC midnight regardless of the host zone.
+	DefaultBillingTimezone = "UTC"
+	// DefaultJobRetentionDays keeps finished background jobs for a week.
+	DefaultJobRetentionDays = 7
+	// DefaultAuthTrashRetentionDays keeps deleted auth files restorable for a month.
+	DefaultAuthTrashRetentionDays = 30
+	// DefaultBackupIntervalHours leaves scheduled backups off until they are configured.
+	DefaultBackupIntervalHours = 0
+	// DefaultBackupRetentionCount keeps the last week of daily backups.
+	DefaultBackupRetentionCount = 7
+	// DefaultWebUIEnabled serves the web panel unless the deployment is API-only.
+	DefaultWebUIEnabled = true
+	// DefaultWebUIPathPrefix mounts the web panel at the site root.
+	DefaultWebUIPathPrefix = "/"
+	// DefaultUsageAlertEmailEnabled emails users about usage alerts when SMTP is configured.
+	DefaultUsageAlertEmailEnabled = true
+	// DefaultOIDCEnabled keeps SSO login off until it is configured.
+	DefaultOIDCEnabled = false
+	// DefaultOIDCAutoProvision requires SSO admins to exist before they can log in.
+	DefaultOIDCAutoProvision = false
+	// DefaultOIDCTrustMissingEmailVerified treats a missing email_verified claim as unverified.
+	DefaultOIDCTrustMissingEmailVerified = false
 )
+
+// Auth candidate orderings accepted by AUTH_CANDIDATE_ORDER.
+const (
+	// AuthCandidateOrderID sorts candidates by auth ID.
+	AuthCandidateOrderID = "id"
+	// AuthCandidateOrderPriority puts higher priority auths first.
+	AuthCandidateOrderPriority = "priority"
+	// AuthCandidateOrderCreatedAt puts the oldest auths first.
+	AuthCandidateOrderCreatedAt = "created_at"
+	// AuthCandidateOrderLeastRecentlyUsed puts the auths picked longest ago first.
+	AuthCandidateOrderLeastRecentlyUsed = "lru"
+)
+
+// Prepaid card orderings accepted by PREPAID_DEDUCTION_ORDER.
+const (
+	// PrepaidDeductionSoonestExpiry spends the cards that expire soonest first; cards
+	// without an expiry come last.
+	PrepaidDeductionSoonestExpiry = "soonest_expiry"
+	// PrepaidDeductionFIFO spends the earliest redeemed cards first.
+	PrepaidDeductionFIFO = "fifo"
+	// PrepaidDeductionLargestFirst spends the cards with the highest balance first, so
+	// balances are not left spread over many small cards.
+	PrepaidDeductionLargestFirst = "largest_first"
+)
+
+// PrepaidDeductionOrders lists the supported PREPAID_DEDUCTION_ORDER values.
+var PrepaidDeductionOrders = []string{
+	PrepaidDeductionSoonestExpiry,
+	PrepaidDeductionFIFO,
+	PrepaidDeductionLargestFirst,
+}
+
+// Usage billing modes accepted by USAGE_BILLING_MODE.
+const (
+	// UsageBillingModeSync deducts bill or prepaid balance in the transaction that inserts
+	// the usage row.
+	UsageBillingModeSync = "sync"
+	// UsageBillingModeDeferred inserts usage rows on their own and leaves the deduction to
+	// the billing reconciler, which charges each user once per interval.
+	UsageBillingModeDeferred = "deferred"
+)
+
+// UsageBillingModes lists the supported USAGE_BILLING_MODE values.
+var UsageBillingModes = []string{UsageBillingModeSync, UsageBillingModeDeferred}
+
+// Auth file validation modes accepted by AUTH_FILE_VALIDATION.
+const (
+	// AuthFileValidationStrict rejects auth files missing required token fields.
+	AuthFileValidationStrict = "strict"
+	// AuthFileValidationWarn stores such auth files and logs a warning.
+	AuthFileValidationWarn = "warn"
+)
+
+// AuthFileValidationModes lists the supported AUTH_FILE_VALIDATION values.
+var AuthFileValidationModes = []string{
+	AuthFileValidationStrict,
+	AuthFileValidationWarn,
+}
+
+// ReservedPathPrefixes are route groups served by the API that the web UI must not shadow.
+var ReservedPathPrefixes = []string{"/v0", "/v1", "/v1beta", "/healthz"}
+
+// X-Model-Override policies accepted by MODEL_OVERRIDE_HEADER.
+const (
+	// ModelOverrideDisabled rejects requests carrying the header.
+	ModelOverrideDisabled = "disabled"
+	// ModelOverrideAdminKeys honors the header for admin API keys only.
+	ModelOverrideAdminKeys = "admin_keys"
+	// ModelOverrideAllKeys honors the header for every API key.
+	ModelOverrideAllKeys = "all_keys"
+)
+
+// ModelOverridePolicies lists the supported MODEL_OVERRIDE_HEADER values.
+var ModelOverridePolicies = []string{
+	ModelOverrideDisabled,
+	ModelOverrideAdminKeys,
+	ModelOverrideAllKeys,
+}
+
+// OAuthProviders lists the token-request OAuth flows accepted by ENABLED_OAUTH_PROVIDERS.
+var OAuthProviders = []string{"anthropic", "gemini", "codex", "antigravity", "qwen", "iflow"}
+
+// Actions accepted by APPROVAL_REQUIRED_ACTIONS.
+const (
+	// ApprovalActionDeleteUser is DELETE /v0/admin/users/:id.
+	ApprovalActionDeleteUser = "delete_user"
+	// ApprovalActionDeleteAuthFile is DELETE /v0/admin/auth-files/:id.
+	ApprovalActionDeleteAuthFile = "delete_auth_file"
+	// ApprovalActionDeleteProviderAPIKey is DELETE /v0/admin/provider-api-keys/:id.
+	ApprovalActionDeleteProviderAPIKey = "delete_provider_api_key"
+)
+
+// ApprovalActions lists the actions accepted by APPROVAL_REQUIRED_ACTIONS.
+var ApprovalActions = []string{ApprovalActionDeleteUser, ApprovalActionDeleteAuthFile, ApprovalActionDeleteProviderAPIKey}
+
+// AuthCandidateOrders lists the supported AUTH_CANDIDATE_ORDER values.
+var AuthCandidateOrders = []string{
+	AuthCandidateOrderID,
+	AuthCandidateOrderPriority,
+	AuthCandidateOrderCreatedAt,
+	AuthCandidateOrderLeastRecentlyUsed,
+}
@@ -1,8 +1,11 @@
 package settings
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -11,17 +14,29 @@ import (
 type dbConfigSnapshot struct {
 	updatedAt time.Time
 	values    map[string]json.RawMessage
+	// resolved holds the decoded value, or the registered default, of every schema key.
+	resolved map[string]any
 }
 
 // globalDBConfig stores the latest dbConfigSnapshot atomically.
 var globalDBConfig atomic.Value // stores dbConfigSnapshot
 
+var (
+	// storeMu serializes writers so change detection compares consecutive snapshots.
+	storeMu sync.Mutex
+	// listenersMu guards listeners and nextListenerID.
+	listenersMu    sync.Mutex
+	listeners      = map[uint64]func(changed []string){}
+	nextListenerID uint64
+)
+
 // init seeds the global DB config snapshot.
 func init() {
-	globalDBConfig.Store(dbConfigSnapshot{values: map[string]json.RawMessage{}})
+	globalDBConfig.Store(dbConfigSnapshot{values: map[string]json.RawMessage{}, resolved: resolveValues(nil)})
 }
 
-// StoreDBConfig replaces the in-memory snapshot of DB-backed settings.
+// StoreDBConfig replaces the in-memory snapshot of DB-backed settings and notifies
+// OnChange listeners of the keys whose raw value changed.
 func StoreDBConfig(updatedAt time.Time, values map[string]json.RawMessage) {
 	next := make(map[string]json.RawMessage, len(values))
 	for k, v := range values {
@@ -38,10 +53,80 @@ func StoreDBConfig(updatedAt time.Time, values map[string]json.RawMessage) {
 		next[key] = copied
 	}
 
+	storeMu.Lock()
+	previous := loadDBConfig()
 	globalDBConfig.Store(dbConfigSnapshot{
 		updatedAt: updatedAt.UTC(),
 		values:    next,
+		resolved:  resolveValues(next),
 	})
+	storeMu.Unlock()
+
+	if changed := changedKeys(previous.values, next); len(changed) > 0 {
+		notifyListeners(changed)
+	}
+}
+
+// OnChange registers fn to run after StoreDBConfig changes any value. fn receives the
+// sorted changed keys and runs synchronously on the writer's goroutine, so it should
+// only refresh cached state or signal a worker. The returned function unsubscribes.
+func OnChange(fn func(changed []string)) (unsubscribe func()) {
+	if fn == nil {
+		return func() {}
+	}
+	listenersMu.Lock()
+	nextListenerID++
+	id := nextListenerID
+	listeners[id] = fn
+	listenersMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			listenersMu.Lock()
+			delete(listeners, id)
+			listenersMu.Unlock()
+		})
+	}
+}
+
+// notifyListeners calls every registered listener with changed.
+func notifyListeners(changed []string) {
+	listenersMu.Lock()
+	ids := make([]uint64, 0, len(listeners))
+	for id := range listeners {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	fns := make([]func([]string), 0, len(ids))
+	for _, id := range ids {
+		fns = append(fns, listeners[id])
+	}
+	listenersMu.Unlock()
+
+	for _, fn := range fns {
+		keys := make([]string, len(changed))
+		copy(keys, changed)
+		fn(keys)
+	}
+}
+
+// changedKeys returns the sorted keys added, removed or modified between two value maps.
+func changedKeys(previous, next map[string]json.RawMessage) []string {
+	var changed []string
+	for key, value := range next {
+		old, ok := previous[key]
+		if !ok || !bytes.Equal(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := next[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
 }
 
 // DBConfigUpdatedAt returns the last update timestamp for DB config.
@@ -74,10 +159,10 @@ func loadDBConfig() dbConfigSnapshot {
 	v := globalDBConfig.Load()
 	cfg, ok := v.(dbConfigSnapshot)
 	if !ok {
-		return dbConfigSnapshot{values: map[string]json.RawMessage{}}
+		return dbConfigSnapshot{values: map[string]json.RawMessage{}, resolved: resolveValues(nil)}
 	}
 	if cfg.values == nil {
-		return dbConfigSnapshot{updatedAt: cfg.updatedAt, values: map[string]json.RawMessage{}}
+		return dbConfigSnapshot{updatedAt: cfg.updatedAt, values: map[string]json.RawMessage{}, resolved: resolveValues(nil)}
 	}
 	return cfg
 }
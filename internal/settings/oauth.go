@@ -0,0 +1,19 @@
+package settings
+
+import "strings"
+
+// OAuthProviderEnabled reports whether the admin token-request OAuth flow of provider
+// is served. Every flow is served while ENABLED_OAUTH_PROVIDERS is unset or invalid.
+func OAuthProviderEnabled(provider string) bool {
+	enabled, ok := GetStringList(EnabledOAuthProvidersKey)
+	if !ok {
+		return true
+	}
+	provider = strings.TrimSpace(provider)
+	for _, name := range enabled {
+		if strings.EqualFold(name, provider) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueType identifies the JSON shape expected for a setting value.
+type ValueType int
+
+// ValueType constants describe the supported setting value shapes.
+const (
+	// TypeBool accepts JSON booleans or "true"/"false" strings.
+	TypeBool ValueType = iota + 1
+	// TypeInt accepts integers, integral floats or numeric strings.
+	TypeInt
+	// TypeString accepts JSON strings.
+	TypeString
+	// TypeStringList accepts a JSON array of strings or a single string.
+	TypeStringList
+)
+
+// String returns the name used in validation errors.
+func (t ValueType) String() string {
+	switch t {
+	case TypeBool:
+		return "boolean"
+	case TypeInt:
+		return "integer"
+	case TypeString:
+		return "string"
+	case TypeStringList:
+		return "string list"
+	default:
+		return "unknown"
+	}
+}
+
+// Spec describes the expected type and range of a known setting.
+type Spec struct {
+	Type ValueType // Expected value shape.
+	Min  int       // Inclusive lower bound for TypeInt.
+	Max  int       // Inclusive upper bound for TypeInt; 0 means unbounded.
+	Enum []string  // Allowed values for TypeString and TypeStringList items; empty accepts any string.
+	// Check validates a TypeString value beyond its type, when set.
+	Check func(value string) error
+	// Default is returned by the typed getters when the value is unset or invalid.
+	Default any
+	// Unit converts a TypeInt value into a duration for GetDuration; zero means seconds.
+	Unit time.Duration
+}
+
+// schema maps known setting keys to their expected values.
+var schema = map[string]Spec{
+	SiteNameKey:                        {Type: TypeString, Default: DefaultSiteName},
+	OnlyMappedModelsKey:                {Type: TypeBool, Default: false},
+	QuotaPollIntervalSecondsKey:        {Type: TypeInt, Min: 1, Default: DefaultQuotaPollIntervalSeconds, Unit: time.Second},
+	QuotaPollMaxConcurrencyKey:         {Type: TypeInt, Min: 1, Max: MaxQuotaPollMaxConcurrency, Default: DefaultQuotaPollMaxConcurrency},
+	QuotaHistoryIntervalSecondsKey:     {Type: TypeInt, Min: 0, Default: DefaultQuotaHistoryIntervalSeconds, Unit: time.Second},
+	QuotaHistoryRetentionDaysKey:       {Type: TypeInt, Min: 0, Default: DefaultQuotaHistoryRetentionDays, Unit: 24 * time.Hour},
+	WatcherDispatchMaxPendingKey:       {Type: TypeInt, Min: 0, Default: DefaultWatcherDispatchMaxPending},
+	AutoAssignProxyKey:                 {Type: TypeBool, Default: DefaultAutoAssignProxy},
+	RateLimitKey:                       {Type: TypeInt, Min: 0, Default: DefaultRateLimit},
+	RateLimitDBEnabledKey:              {Type: TypeBool, Default: false},
+	RateLimitRedisEnabledKey:           {Type: TypeBool, Default: false},
+	RateLimitRedisAddrKey:              {Type: TypeString},
+	RateLimitRedisPasswordKey:          {Type: TypeString},
+	RateLimitRedisDBKey:                {Type: TypeInt, Min: 0, Default: 0},
+	RateLimitRedisPrefixKey:            {Type: TypeString, Default: DefaultRateLimitRedisPrefix},
+	AllowRegistrationKey:               {Type: TypeBool, Default: DefaultAllowRegistration},
+	RegistrationRequireInviteKey:       {Type: TypeBool, Default: DefaultRegistrationRequireInvite},
+	RegistrationVerifyURLKey:           {Type: TypeString},
+	SMTPHostKey:                        {Type: TypeString},
+	SMTPPortKey:                        {Type: TypeInt, Min: 1, Max: 65535, Default: DefaultSMTPPort},
+	SMTPUsernameKey:                    {Type: TypeString},
+	SMTPPasswordKey:                    {Type: TypeString},
+	SMTPFromKey:                        {Type: TypeString},
+	ImpersonationTokenTTLSecondsKey:    {Type: TypeInt, Min: 1, Max: MaxImpersonationTokenTTLSeconds, Default: DefaultImpersonationTokenTTLSeconds, Unit: time.Second},
+	WebAuthnRPIDKey:                    {Type: TypeString},
+	WebAuthnRPNameKey:                  {Type: TypeString},
+	WebAuthnOriginKey:                  {Type: TypeString},
+	WebAuthnOriginsKey:                 {Type: TypeStringList},
+	UsageRetentionDaysKey:              {Type: TypeInt, Min: 0, Default: DefaultUsageRetentionDays, Unit: 24 * time.Hour},
+	APIKeyIdleRevokeDaysKey:            {Type: TypeInt, Min: 0, Default: DefaultAPIKeyIdleRevokeDays, Unit: 24 * time.Hour},
+	APIKeyMaxPerUserKey:                {Type: TypeInt, Min: 0, Default: DefaultAPIKeyMaxPerUser},
+	AuthPriorityDecayThresholdKey:      {Type: TypeInt, Min: 0, Default: DefaultAuthPriorityDecayThreshold},
+	AuthPriorityDecayWindowSecondsKey:  {Type: TypeInt, Min: 1, Default: DefaultAuthPriorityDecayWindowSeconds, Unit: time.Second},
+	AuthPriorityDecayStepKey:           {Type: TypeInt, Min: 1, Default: DefaultAuthPriorityDecayStep},
+	AuthPriorityRestoreSecondsKey:      {Type: TypeInt, Min: 1, Default: DefaultAuthPriorityRestoreSeconds, Unit: time.Second},
+	OIDCEnabledKey:                     {Type: TypeBool, Default: DefaultOIDCEnabled},
+	OIDCIssuerKey:                      {Type: TypeString},
+	OIDCClientIDKey:                    {Type: TypeString},
+	OIDCClientSecretKey:                {Type: TypeString},
+	OIDCRedirectURLKey:                 {Type: TypeString},
+	OIDCAllowedDomainsKey:              {Type: TypeStringList},
+	OIDCAutoProvisionKey:               {Type: TypeBool, Default: DefaultOIDCAutoProvision},
+	OIDCTrustMissingEmailVerifiedKey:   {Type: TypeBool, Default: DefaultOIDCTrustMissingEmailVerified},
+	AuthCandidateOrderKey:              {Type: TypeString, Enum: AuthCandidateOrders, Default: DefaultAuthCandidateOrder},
+	ModelOverrideHeaderKey:             {Type: TypeString, Enum: ModelOverridePolicies, Default: DefaultModelOverrideHeader},
+	WebUIEnabledKey:                    {Type: TypeBool, Default: DefaultWebUIEnabled},
+	WebUIPathPrefixKey:                 {Type: TypeString, Check: CheckWebUIPathPrefix, Default: DefaultWebUIPathPrefix},
+	UsageAlertWebhookURLKey:            {Type: TypeString},
+	UsageAlertEmailEnabledKey:          {Type: TypeBool, Default: DefaultUsageAlertEmailEnabled},
+	BillWebhookURLKey:                  {Type: TypeString},
+	BillWebhookSecretKey:               {Type: TypeString},
+	EnabledOAuthProvidersKey:           {Type: TypeStringList, Enum: OAuthProviders},
+	ProviderKeyAuthFailureThresholdKey: {Type: TypeInt, Min: 0, Default: DefaultProviderKeyAuthFailureThreshold},
+	ProviderKeyWebhookURLKey:           {Type: TypeString},
+	AuthFileValidationKey:              {Type: TypeString, Enum: AuthFileValidationModes, Default: DefaultAuthFileValidation},
+	DisplayCurrencyKey:                 {Type: TypeString, Check: CheckCurrencyCode, Default: DefaultDisplayCurrency},
+	PrepaidDeductionOrderKey:           {Type: TypeString, Enum: PrepaidDeductionOrders, Default: DefaultPrepaidDeductionOrder},
+	UsageBillingModeKey:                {Type: TypeString, Enum: UsageBillingModes, Default: DefaultUsageBillingMode},
+	UsageBillingIntervalSecondsKey:     {Type: TypeInt, Min: 1, Default: DefaultUsageBillingIntervalSeconds, Unit: time.Second},
+	BillingTimezoneKey:                 {Type: TypeString, Check: CheckTimezone, Default: DefaultBillingTimezone},
+	ModelListCacheTTLSecondsKey:        {Type: TypeInt, Min: 0, Default: DefaultModelListCacheTTLSeconds, Unit: time.Second},
+	JobRetentionDaysKey:                {Type: TypeInt, Min: 0, Default: DefaultJobRetentionDays, Unit: 24 * time.Hour},
+	AuthTrashRetentionDaysKey:          {Type: TypeInt, Min: 0, Default: DefaultAuthTrashRetentionDays, Unit: 24 * time.Hour},
+	BackupIntervalHoursKey:             {Type: TypeInt, Min: 0, Default: DefaultBackupIntervalHours, Unit: time.Hour},
+	BackupRetentionCountKey:            {Type: TypeInt, Min: 0, Default: DefaultBackupRetentionCount},
+	ApprovalRequiredActionsKey:         {Type: TypeStringList, Enum: ApprovalActions},
+}
+
+// LookupSpec returns the schema entry for a key.
+func LookupSpec(key string) (Spec, bool) {
+	spec, ok := schema[strings.TrimSpace(key)]
+	return spec, ok
+}
+
+// ValidateValue checks raw against the schema for key. It reports whether the key is
+// known; unknown keys are accepted as free-form values.
+func ValidateValue(key string, raw json.RawMessage) (bool, error) {
+	spec, ok := LookupSpec(key)
+	if !ok {
+		return false, nil
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return true, fmt.Errorf("%s requires a %s value", key, spec.Type)
+	}
+	switch spec.Type {
+	case TypeBool:
+		if !isBoolValue(raw) {
+			return true, fmt.Errorf("%s must be a boolean", key)
+		}
+	case TypeInt:
+		value, okInt := ParseInt(raw)
+		if !okInt {
+			return true, fmt.Errorf("%s must be an integer", key)
+		}
+		if value < spec.Min {
+			return true, fmt.Errorf("%s must be at least %d", key, spec.Min)
+		}
+		if spec.Max > 0 && value > spec.Max {
+			return true, fmt.Errorf("%s must be at most %d", key, spec.Max)
+		}
+	case TypeString:
+		var s string
+		if errUnmarshal := json.Unmarshal(raw, &s); errUnmarshal != nil {
+			return true, fmt.Errorf("%s must be a string", key)
+		}
+		if len(spec.Enum) > 0 && !containsString(spec.Enum, strings.TrimSpace(s)) {
+			return true, fmt.Errorf("%s must be one of %s", key, strings.Join(spec.Enum, ", "))
+		}
+		if spec.Check != nil {
+			if errCheck := spec.Check(strings.TrimSpace(s)); errCheck != nil {
+				return true, fmt.Errorf("%s: %w", key, errCheck)
+			}
+		}
+	case TypeStringList:
+		var list []string
+		if errList := json.Unmarshal(raw, &list); errList != nil {
+			var s string
+			if errString := json.Unmarshal(raw, &s); errString != nil {
+				return true, fmt.Errorf("%s must be a string or list of strings", key)
+			}
+			list = []string{s}
+		}
+		if len(spec.Enum) > 0 {
+			for _, item := range list {
+				if _, okEnum := matchEnum(spec.Enum, strings.TrimSpace(item)); !okEnum {
+					return true, fmt.Errorf("%s entries must be one of %s", key, strings.Join(spec.Enum, ", "))
+				}
+			}
+		}
+	default:
+		return true, errors.New("unsupported setting type")
+	}
+	return true, nil
+}
+
+// ParseInt decodes an integer from a JSON number, integral float or numeric string.
+func ParseInt(raw json.RawMessage) (int, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var parsedInt int
+	if errUnmarshalInt := json.Unmarshal(raw, &parsedInt); errUnmarshalInt == nil {
+		return parsedInt, true
+	}
+	var parsedString string
+	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
+		parsed, errParse := strconv.Atoi(strings.TrimSpace(parsedString))
+		if errParse != nil {
+			return 0, false
+		}
+		return parsed, true
+	}
+	var parsedFloat float64
+	if errUnmarshalFloat := json.Unmarshal(raw, &parsedFloat); errUnmarshalFloat == nil {
+		if math.IsNaN(parsedFloat) || math.IsInf(parsedFloat, 0) || parsedFloat != math.Trunc(parsedFloat) {
+			return 0, false
+		}
+		return int(parsedFloat), true
+	}
+	return 0, false
+}
+
+// isBoolValue reports whether raw is a JSON boolean or a boolean-like string.
+func isBoolValue(raw json.RawMessage) bool {
+	var parsedBool bool
+	if errUnmarshalBool := json.Unmarshal(raw, &parsedBool); errUnmarshalBool == nil {
+		return true
+	}
+	var parsedString string
+	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
+		_, errParse := strconv.ParseBool(strings.TrimSpace(parsedString))
+		return errParse == nil
+	}
+	return false
+}
+
+// ParseBool decodes a boolean from a JSON boolean, the numbers 0 and 1, or a string
+// such as "true", "yes", "on", "1" and their negations. It is more lenient than
+// validation so values stored before validation existed keep their meaning.
+func ParseBool(raw json.RawMessage) (bool, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return false, false
+	}
+	var parsedBool bool
+	if errUnmarshalBool := json.Unmarshal(raw, &parsedBool); errUnmarshalBool == nil {
+		return parsedBool, true
+	}
+	var parsedString string
+	if errUnmarshalString := json.Unmarshal(raw, &parsedString); errUnmarshalString == nil {
+		switch strings.ToLower(strings.TrimSpace(parsedString)) {
+		case "1", "t", "true", "y", "yes", "on":
+			return true, true
+		case "0", "f", "false", "n", "no", "off":
+			return false, true
+		default:
+			return false, false
+		}
+	}
+	var parsedFloat float64
+	if errUnmarshalFloat := json.Unmarshal(raw, &parsedFloat); errUnmarshalFloat == nil {
+		switch parsedFloat {
+		case 1:
+			return true, true
+		case 0:
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// CheckWebUIPathPrefix rejects web UI prefixes that are not absolute paths or that would
+// shadow the API and health check routes.
+func CheckWebUIPathPrefix(value string) error {
+	if value == "" {
+		return nil
+	}
+	if !strings.HasPrefix(value, "/") || strings.ContainsAny(value, "?#* ") {
+		return errors.New("path prefix must be an absolute path")
+	}
+	cleaned := path.Clean(value)
+	for _, reserved := range ReservedPathPrefixes {
+		if cleaned == reserved || strings.HasPrefix(cleaned, reserved+"/") {
+			return fmt.Errorf("path prefix conflicts with %s", reserved)
+		}
+	}
+	return nil
+}
+
+// CheckCurrencyCode validates a three-letter uppercase ISO 4217 currency code.
+func CheckCurrencyCode(value string) error {
+	if len(value) != 3 {
+		return errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return errors.New("currency must be a three-letter ISO 4217 code")
+		}
+	}
+	return nil
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
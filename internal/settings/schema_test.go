@@ -0,0 +1,51 @@
+package settings
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateValue(t *testing.T) {
+	cases := []struct {
+		key     string
+		value   string
+		known   bool
+		wantErr bool
+	}{
+		{RateLimitKey, `10`, true, false},
+		{RateLimitKey, `"10"`, true, false},
+		{RateLimitKey, `"abc"`, true, true},
+		{RateLimitKey, `-1`, true, true},
+		{QuotaPollIntervalSecondsKey, `0`, true, true},
+		{SMTPPortKey, `70000`, true, true},
+		{AutoAssignProxyKey, `true`, true, false},
+		{AutoAssignProxyKey, `"yes"`, true, true},
+		{SiteNameKey, `"Acme"`, true, false},
+		{SiteNameKey, `42`, true, true},
+		{WebAuthnOriginsKey, `["https://a.example"]`, true, false},
+		{AuthCandidateOrderKey, `"priority"`, true, false},
+		{AuthCandidateOrderKey, `"random"`, true, true},
+		{WebUIPathPrefixKey, `"/panel"`, true, false},
+		{WebUIPathPrefixKey, `"panel"`, true, true},
+		{WebUIPathPrefixKey, `"/v1/panel"`, true, true},
+		{WebUIPathPrefixKey, `"/v0"`, true, true},
+		{WebUIPathPrefixKey, `"/v10"`, true, false},
+		{EnabledOAuthProvidersKey, `["anthropic","Gemini"]`, true, false},
+		{EnabledOAuthProvidersKey, `[]`, true, false},
+		{EnabledOAuthProvidersKey, `["anthropic","openai"]`, true, true},
+		{BillingTimezoneKey, `"Europe/Berlin"`, true, false},
+		{BillingTimezoneKey, `"UTC"`, true, false},
+		{BillingTimezoneKey, `"Local"`, true, true},
+		{BillingTimezoneKey, `"Mars/Olympus"`, true, true},
+		{"CUSTOM_FLAG", `{"anything":1}`, false, false},
+	}
+	for _, tc := range cases {
+		known, errValidate := ValidateValue(tc.key, json.RawMessage(tc.value))
+		if known != tc.known {
+			t.Fatalf("%s=%s: expected known=%v, got %v", tc.key, tc.value, tc.known, known)
+		}
+		if (errValidate != nil) != tc.wantErr {
+			t.Fatalf("%s=%s: expected error=%v, got %v", tc.key, tc.value, tc.wantErr, errValidate)
+		}
+	}
+}
@@ -90,16 +90,16 @@ func (s *GormAuthStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (stri
 
 	now := time.Now().UTC()
 	record := models.Auth{
-		Key:       id,
-		Content:   datatypes.JSON(payload),
-		CreatedAt: now,
-		UpdatedAt: now,
+		Key:         id,
+		Content:     datatypes.JSON(payload),
+		Fingerprint: models.AuthFingerprint(payload),
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "key"}},
-		DoUpdates: clause.AssignmentColumns([]string{"content", "updated_at"}),
-	}).Create(&record).Error; err != nil {
+	onConflict := models.AuthKeyConflict()
+	onConflict.DoUpdates = clause.AssignmentColumns([]string{"content", "fingerprint", "updated_at"})
+	if err := s.db.WithContext(ctx).Clauses(onConflict).Create(&record).Error; err != nil {
 		return "", fmt.Errorf("gorm auth store: upsert: %w", err)
 	}
 
@@ -155,7 +155,7 @@ func (s *GormAuthStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 	return auths, nil
 }
 
-// Delete removes an auth record by ID.
+// Delete moves an auth record to the trash by ID; the maintenance worker purges it later.
 func (s *GormAuthStore) Delete(ctx context.Context, id string) error {
 	if s == nil || s.db == nil {
 		return fmt.Errorf("gorm auth store: not initialized")
@@ -168,7 +168,9 @@ func (s *GormAuthStore) Delete(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if errDelete := s.db.WithContext(ctx).Where("key = ?", id).Delete(&models.Auth{}).Error; errDelete != nil {
+	now := time.Now().UTC()
+	if errDelete := s.db.WithContext(ctx).Model(&models.Auth{}).Where("key = ?", id).
+		Updates(map[string]any{"deleted_at": now, "updated_at": now}).Error; errDelete != nil {
 		return fmt.Errorf("gorm auth store: delete db row: %w", errDelete)
 	}
 	return nil
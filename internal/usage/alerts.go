@@ -0,0 +1,373 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/mail"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultAlertInterval is how often usage thresholds are evaluated.
+	defaultAlertInterval = 5 * time.Minute
+	// alertWebhookTimeout bounds a single webhook delivery.
+	alertWebhookTimeout = 10 * time.Second
+)
+
+// AlertJob notifies users whose bill quota usage crosses their alert threshold. The
+// threshold is the user's alert_at_percent, or the plan's when the user has none.
+type AlertJob struct {
+	db         *gorm.DB
+	interval   time.Duration
+	now        func() time.Time
+	httpClient *http.Client
+	newSender  func() mail.Sender
+}
+
+// NewAlertJob constructs a usage alert job.
+func NewAlertJob(db *gorm.DB) *AlertJob {
+	if db == nil {
+		return nil
+	}
+	return &AlertJob{
+		db:         db,
+		interval:   defaultAlertInterval,
+		now:        time.Now,
+		httpClient: &http.Client{Timeout: alertWebhookTimeout},
+		newSender:  alertMailSender,
+	}
+}
+
+// Start runs the alert loop in the background.
+func (j *AlertJob) Start(ctx context.Context) {
+	if j == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go j.run(ctx)
+	log.Infof("usage alert job started (interval=%s)", j.interval)
+}
+
+// run executes alert passes until ctx is canceled.
+func (j *AlertJob) run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fired, errRun := j.RunOnce(ctx)
+			if errRun != nil {
+				if !errors.Is(errRun, context.Canceled) {
+					log.WithError(errRun).Warn("usage alert: pass failed")
+				}
+				continue
+			}
+			if len(fired) > 0 {
+				log.Infof("usage alert: fired %d alert(s)", len(fired))
+			}
+		}
+	}
+}
+
+// alertCandidate is a user with an effective alert threshold.
+type alertCandidate struct {
+	ID        uint64
+	Username  string
+	Email     string
+	Threshold int
+}
+
+// RunOnce evaluates every user with an alert threshold and returns the alerts that
+// fired during this pass. An alert already recorded for the same day is not sent again.
+func (j *AlertJob) RunOnce(ctx context.Context) ([]models.UsageAlert, error) {
+	if j == nil || j.db == nil {
+		return nil, errors.New("usage alert: nil db")
+	}
+	clock := j.now
+	if clock == nil {
+		clock = time.Now
+	}
+	now := clock().UTC()
+
+	candidates, errLoad := j.loadCandidates(ctx)
+	if errLoad != nil {
+		return nil, errLoad
+	}
+	var fired []models.UsageAlert
+	for _, candidate := range candidates {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return fired, errCtx
+		}
+		alerts, errEval := j.evaluate(ctx, candidate, now)
+		if errEval != nil {
+			return fired, errEval
+		}
+		for i := range alerts {
+			recorded, errRecord := j.record(ctx, &alerts[i])
+			if errRecord != nil {
+				return fired, errRecord
+			}
+			if !recorded {
+				continue
+			}
+			j.notify(ctx, candidate, alerts[i])
+			fired = append(fired, alerts[i])
+		}
+	}
+	return fired, nil
+}
+
+// loadCandidates returns enabled users whose own or plan threshold is set.
+func (j *AlertJob) loadCandidates(ctx context.Context) ([]alertCandidate, error) {
+	var plans []models.Plan
+	if errPlans := j.db.WithContext(ctx).
+		Select("id", "alert_at_percent").
+		Where("alert_at_percent > 0").
+		Find(&plans).Error; errPlans != nil {
+		return nil, errPlans
+	}
+	planThreshold := make(map[uint64]int, len(plans))
+	planIDs := make([]uint64, 0, len(plans))
+	for _, plan := range plans {
+		planThreshold[plan.ID] = plan.AlertAtPercent
+		planIDs = append(planIDs, plan.ID)
+	}
+
+	q := j.db.WithContext(ctx).
+		Select("id", "username", "email", "plan_id", "alert_at_percent").
+		Where("active = ? AND disabled = ?", true, false)
+	if len(planIDs) > 0 {
+		q = q.Where("alert_at_percent > 0 OR plan_id IN ?", planIDs)
+	} else {
+		q = q.Where("alert_at_percent > 0")
+	}
+	var users []models.User
+	if errUsers := q.Order("id ASC").Find(&users).Error; errUsers != nil {
+		return nil, errUsers
+	}
+
+	out := make([]alertCandidate, 0, len(users))
+	for _, user := range users {
+		threshold := user.AlertAtPercent
+		if threshold <= 0 && user.PlanID != nil {
+			threshold = planThreshold[*user.PlanID]
+		}
+		if threshold <= 0 {
+			continue
+		}
+		out = append(out, alertCandidate{
+			ID:        user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			Threshold: threshold,
+		})
+	}
+	return out, nil
+}
+
+// evaluate compares today's usage and the used bill quota against the candidate's threshold.
+func (j *AlertJob) evaluate(ctx context.Context, candidate alertCandidate, now time.Time) ([]models.UsageAlert, error) {
+	var bills []models.Bill
+	if errBills := j.db.WithContext(ctx).
+		Where("user_id = ? AND is_enabled = ? AND status = ?", candidate.ID, true, models.BillStatusPaid).
+		Where("period_start <= ? AND period_end >= ?", now, now).
+		Find(&bills).Error; errBills != nil {
+		return nil, errBills
+	}
+	if len(bills) == 0 {
+		return nil, nil
+	}
+
+	totalQuota := 0.0
+	totalLeft := 0.0
+	totalDaily := 0.0
+	unlimitedDaily := false
+	for _, bill := range bills {
+		totalQuota += bill.TotalQuota
+		if bill.LeftQuota > 0 {
+			totalLeft += bill.LeftQuota
+		}
+		if bill.DailyQuota <= 0 {
+			unlimitedDaily = true
+		} else {
+			totalDaily += bill.DailyQuota
+		}
+	}
+
+	day := now.In(internalsettings.BillingLocation()).Format("2006-01-02")
+	var alerts []models.UsageAlert
+	if !unlimitedDaily && totalDaily > 0 {
+		usedToday, errUsage := loadTodayUsageAmount(ctx, j.db, candidate.ID, nil, now)
+		if errUsage != nil {
+			return nil, errUsage
+		}
+		if alert, ok := thresholdAlert(candidate, models.UsageAlertKindDaily, day, usedToday, totalDaily); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	if totalQuota > 0 {
+		used := totalQuota - totalLeft
+		if alert, ok := thresholdAlert(candidate, models.UsageAlertKindTotal, day, used, totalQuota); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+// thresholdAlert builds an alert when used reaches the candidate's share of limit.
+func thresholdAlert(candidate alertCandidate, kind, day string, used, limit float64) (models.UsageAlert, bool) {
+	if limit <= 0 {
+		return models.UsageAlert{}, false
+	}
+	percent := used / limit * 100
+	if percent+billQuotaEpsilon < float64(candidate.Threshold) {
+		return models.UsageAlert{}, false
+	}
+	return models.UsageAlert{
+		UserID:      candidate.ID,
+		Kind:        kind,
+		Threshold:   candidate.Threshold,
+		Day:         day,
+		UsedAmount:  used,
+		LimitAmount: limit,
+		Percent:     percent,
+	}, true
+}
+
+// record stores alert and reports whether it is new for its user, kind, threshold and day.
+func (j *AlertJob) record(ctx context.Context, alert *models.UsageAlert) (bool, error) {
+	res := j.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(alert)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// alertWebhookPayload is the JSON body posted to USAGE_ALERT_WEBHOOK_URL.
+type alertWebhookPayload struct {
+	UserID    uint64    `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Kind      string    `json:"kind"`
+	Threshold int       `json:"threshold"`
+	Day       string    `json:"day"`
+	Used      float64   `json:"used"`
+	Limit     float64   `json:"limit"`
+	Percent   float64   `json:"percent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// notify delivers alert by webhook and email. Delivery failures are logged only; the
+// alert stays recorded so it is not repeated on the next pass.
+func (j *AlertJob) notify(ctx context.Context, candidate alertCandidate, alert models.UsageAlert) {
+	if url := alertWebhookURL(); url != "" {
+		if errHook := j.postWebhook(ctx, url, candidate, alert); errHook != nil {
+			log.WithError(errHook).WithField("user_id", candidate.ID).Warn("usage alert: webhook delivery failed")
+		}
+	}
+	if candidate.Email == "" || !alertEmailEnabled() || j.newSender == nil {
+		return
+	}
+	if errSend := j.newSender().Send(ctx, alertMessage(candidate, alert)); errSend != nil {
+		log.WithError(errSend).WithField("user_id", candidate.ID).Warn("usage alert: email delivery failed")
+	}
+}
+
+// postWebhook posts alert to url as JSON.
+func (j *AlertJob) postWebhook(ctx context.Context, url string, candidate alertCandidate, alert models.UsageAlert) error {
+	body, errMarshal := json.Marshal(alertWebhookPayload{
+		UserID:    candidate.ID,
+		Username:  candidate.Username,
+		Email:     candidate.Email,
+		Kind:      alert.Kind,
+		Threshold: alert.Threshold,
+		Day:       alert.Day,
+		Used:      alert.UsedAmount,
+		Limit:     alert.LimitAmount,
+		Percent:   alert.Percent,
+		CreatedAt: alert.CreatedAt,
+	})
+	if errMarshal != nil {
+		return errMarshal
+	}
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if errReq != nil {
+		return errReq
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := j.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return errDo
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// alertMessage renders the email sent for alert.
+func alertMessage(candidate alertCandidate, alert models.UsageAlert) mail.Message {
+	siteName := internalsettings.GetString(internalsettings.SiteNameKey)
+	if siteName == "" {
+		siteName = internalsettings.DefaultSiteName
+	}
+	scope := "total"
+	if alert.Kind == models.UsageAlertKindDaily {
+		scope = "daily"
+	}
+	return mail.Message{
+		To:      candidate.Email,
+		Subject: fmt.Sprintf("%s: %d%% of your %s quota used", siteName, alert.Threshold, scope),
+		Body: fmt.Sprintf(
+			"You have used %.2f of your %.2f %s quota (%.1f%%).\nRequests will start failing once the quota is exhausted.\n",
+			alert.UsedAmount, alert.LimitAmount, scope, alert.Percent,
+		),
+	}
+}
+
+// alertMailSender returns an SMTP sender when SMTP_HOST is set, otherwise a log-only sender.
+func alertMailSender() mail.Sender {
+	host := internalsettings.GetString(internalsettings.SMTPHostKey)
+	if host == "" {
+		return mail.LogSender{}
+	}
+	return mail.NewSMTPSender(mail.SMTPConfig{
+		Host:     host,
+		Port:     internalsettings.GetInt(internalsettings.SMTPPortKey),
+		Username: internalsettings.GetString(internalsettings.SMTPUsernameKey),
+		Password: internalsettings.GetString(internalsettings.SMTPPasswordKey),
+		From:     internalsettings.GetString(internalsettings.SMTPFromKey),
+	})
+}
+
+// alertWebhookURL reads USAGE_ALERT_WEBHOOK_URL; empty disables webhook delivery.
+func alertWebhookURL() string {
+	return internalsettings.GetString(internalsettings.UsageAlertWebhookURLKey)
+}
+
+// alertEmailEnabled reads USAGE_ALERT_EMAIL_ENABLED.
+func alertEmailEnabled() bool {
+	return internalsettings.GetBool(internalsettings.UsageAlertEmailEnabledKey)
+}
@@ -0,0 +1,120 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/mail"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+// recordingSender collects messages instead of sending them.
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (s *recordingSender) Send(_ context.Context, msg mail.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestAlertJobFiresOncePerThresholdAndDay(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	var hooks []alertWebhookPayload
+	var hooksMu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload alertWebhookPayload
+		if errDecode := json.NewDecoder(r.Body).Decode(&payload); errDecode != nil {
+			t.Errorf("decode webhook: %v", errDecode)
+		}
+		hooksMu.Lock()
+		hooks = append(hooks, payload)
+		hooksMu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhookURL, _ := json.Marshal(server.URL)
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.UsageAlertWebhookURLKey: webhookURL,
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	now := time.Now().UTC()
+	plan := models.Plan{Name: "pro", AlertAtPercent: 80}
+	if errCreate := conn.Create(&plan).Error; errCreate != nil {
+		t.Fatalf("create plan: %v", errCreate)
+	}
+	planUser := models.User{Username: "plan-user", Email: "plan@example.com", Password: "x", PlanID: &plan.ID}
+	ownUser := models.User{Username: "own-user", Email: "own@example.com", Password: "x", AlertAtPercent: 50}
+	quietUser := models.User{Username: "quiet-user", Email: "quiet@example.com", Password: "x"}
+	for _, user := range []*models.User{&planUser, &ownUser, &quietUser} {
+		if errCreate := conn.Create(user).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+	}
+	bills := []models.Bill{
+		// 90% of the total quota used, daily quota unlimited.
+		{PlanID: plan.ID, UserID: planUser.ID, PeriodStart: now.Add(-time.Hour), PeriodEnd: now.Add(time.Hour), TotalQuota: 10, LeftQuota: 1, IsEnabled: true, Status: models.BillStatusPaid},
+		// 60% of the daily quota used today, 6% of the total quota.
+		{PlanID: plan.ID, UserID: ownUser.ID, PeriodStart: now.Add(-time.Hour), PeriodEnd: now.Add(time.Hour), TotalQuota: 100, LeftQuota: 94, DailyQuota: 10, IsEnabled: true, Status: models.BillStatusPaid},
+		{PlanID: plan.ID, UserID: quietUser.ID, PeriodStart: now.Add(-time.Hour), PeriodEnd: now.Add(time.Hour), TotalQuota: 10, LeftQuota: 0, IsEnabled: true, Status: models.BillStatusPaid},
+	}
+	if errCreate := conn.Create(&bills).Error; errCreate != nil {
+		t.Fatalf("create bills: %v", errCreate)
+	}
+	if errCreate := conn.Create(&models.Usage{Provider: "openai", Model: "gpt-4", UserID: &ownUser.ID, RequestedAt: now, CostMicros: 6_000_000}).Error; errCreate != nil {
+		t.Fatalf("create usage: %v", errCreate)
+	}
+
+	sender := &recordingSender{}
+	job := NewAlertJob(conn)
+	job.now = func() time.Time { return now }
+	job.newSender = func() mail.Sender { return sender }
+
+	fired, errRun := job.RunOnce(context.Background())
+	if errRun != nil {
+		t.Fatalf("run alerts: %v", errRun)
+	}
+	if len(fired) != 2 {
+		t.Fatalf("expected 2 alerts, got %+v", fired)
+	}
+	byUser := map[uint64]models.UsageAlert{}
+	for _, alert := range fired {
+		byUser[alert.UserID] = alert
+	}
+	if alert := byUser[planUser.ID]; alert.Kind != models.UsageAlertKindTotal || alert.Threshold != 80 {
+		t.Fatalf("unexpected plan user alert %+v", alert)
+	}
+	if alert := byUser[ownUser.ID]; alert.Kind != models.UsageAlertKindDaily || alert.Threshold != 50 {
+		t.Fatalf("unexpected own user alert %+v", alert)
+	}
+	if len(sender.sent) != 2 || len(hooks) != 2 {
+		t.Fatalf("expected 2 emails and 2 webhooks, got %d and %d", len(sender.sent), len(hooks))
+	}
+
+	again, errAgain := job.RunOnce(context.Background())
+	if errAgain != nil {
+		t.Fatalf("rerun alerts: %v", errAgain)
+	}
+	if len(again) != 0 || len(sender.sent) != 2 || len(hooks) != 2 {
+		t.Fatalf("expected no repeated alerts, got %d", len(again))
+	}
+}
@@ -0,0 +1,266 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultBillingReconcileBatchSize bounds the usage rows charged per user per pass.
+	defaultBillingReconcileBatchSize = 1000
+	// defaultBillingReconcileUserLimit bounds the users charged per pass.
+	defaultBillingReconcileUserLimit = 500
+)
+
+// errUnbilledRowsClaimed reports that another reconciler charged some of a batch first.
+var errUnbilledRowsClaimed = errors.New("usage billing: unbilled rows claimed concurrently")
+
+// deferredBilling reports whether USAGE_BILLING_MODE leaves deductions to the billing reconciler.
+func deferredBilling() bool {
+	return strings.TrimSpace(internalsettings.GetString(internalsettings.UsageBillingModeKey)) == internalsettings.UsageBillingModeDeferred
+}
+
+// BillingReconcileResult summarizes one billing reconciler pass.
+type BillingReconcileResult struct {
+	Users       int   // Users charged.
+	ChargedRows int   // Usage rows whose cost was deducted.
+	OverCapRows int   // Usage rows flagged instead of charged because of the daily spend cap.
+	CostMicros  int64 // Total cost deducted in micros.
+}
+
+// BillingReconciler charges usage rows recorded in deferred billing mode. Each pass
+// sums every user's unbilled rows and applies one bill or prepaid deduction per user, so
+// request traffic never waits on bill and prepaid card row locks.
+type BillingReconciler struct {
+	db        *gorm.DB
+	batchSize int
+	userLimit int
+	now       func() time.Time
+	interval  func() time.Duration
+}
+
+// NewBillingReconciler constructs a usage billing reconciler.
+func NewBillingReconciler(db *gorm.DB) *BillingReconciler {
+	if db == nil {
+		return nil
+	}
+	return &BillingReconciler{
+		db:        db,
+		batchSize: defaultBillingReconcileBatchSize,
+		userLimit: defaultBillingReconcileUserLimit,
+		now:       time.Now,
+		interval: func() time.Duration {
+			return internalsettings.GetDuration(internalsettings.UsageBillingIntervalSecondsKey)
+		},
+	}
+}
+
+// Start runs the reconciler loop in the background. The loop also runs in sync billing
+// mode, so rows left unbilled when switching back from deferred mode are still charged.
+func (r *BillingReconciler) Start(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go r.run(ctx)
+	log.Infof("usage billing reconciler started (interval=%s)", r.nextInterval())
+}
+
+// nextInterval returns the current USAGE_BILLING_INTERVAL_SECONDS, falling back to the default.
+func (r *BillingReconciler) nextInterval() time.Duration {
+	if interval := r.interval(); interval > 0 {
+		return interval
+	}
+	return internalsettings.DefaultUsageBillingIntervalSeconds * time.Second
+}
+
+// run executes reconciler passes until ctx is canceled. The interval is read again after
+// every pass so setting changes apply without a restart.
+func (r *BillingReconciler) run(ctx context.Context) {
+	timer := time.NewTimer(r.nextInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		result, errRun := r.RunOnce(ctx)
+		if errRun != nil {
+			if !errors.Is(errRun, context.Canceled) {
+				log.WithError(errRun).Warn("usage billing: pass failed")
+			}
+		} else if result.ChargedRows > 0 || result.OverCapRows > 0 {
+			log.Debugf("usage billing: charged %d row(s) for %d user(s), flagged %d over spend cap", result.ChargedRows, result.Users, result.OverCapRows)
+		}
+		timer.Reset(r.nextInterval())
+	}
+}
+
+// unbilledOwner identifies the rows charged together: one user within one billing group.
+type unbilledOwner struct {
+	UserID      uint64
+	UserGroupID *uint64
+}
+
+// RunOnce charges the unbilled usage of every user that has some. A failure for one user
+// is logged and leaves that user's rows unbilled for the next pass.
+func (r *BillingReconciler) RunOnce(ctx context.Context) (BillingReconcileResult, error) {
+	var result BillingReconcileResult
+	if r == nil || r.db == nil {
+		return result, errors.New("usage billing: nil db")
+	}
+	var owners []unbilledOwner
+	if errOwners := r.db.WithContext(ctx).
+		Model(&models.Usage{}).
+		Distinct("user_id", "user_group_id").
+		Where("unbilled = ? AND user_id IS NOT NULL", true).
+		Limit(r.userLimit).
+		Scan(&owners).Error; errOwners != nil {
+		return result, errOwners
+	}
+	for _, owner := range owners {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return result, errCtx
+		}
+		charged, errCharge := r.reconcileOwner(ctx, owner)
+		if errCharge != nil {
+			log.WithError(errCharge).WithField("user_id", owner.UserID).Warn("usage billing: charge user failed")
+			continue
+		}
+		if charged.ChargedRows > 0 || charged.OverCapRows > 0 {
+			result.Users++
+		}
+		result.ChargedRows += charged.ChargedRows
+		result.OverCapRows += charged.OverCapRows
+		result.CostMicros += charged.CostMicros
+	}
+	return result, nil
+}
+
+// reconcileOwner charges one batch of a user's unbilled rows in a single transaction.
+// Clearing the unbilled flag commits together with the deduction, so a crash either
+// charges the batch once or leaves it for the next pass.
+func (r *BillingReconciler) reconcileOwner(ctx context.Context, owner unbilledOwner) (BillingReconcileResult, error) {
+	var result BillingReconcileResult
+	errTx := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result = BillingReconcileResult{}
+		var rows []models.Usage
+		q := tx.Select("id", "requested_at", "cost_micros").
+			Where("unbilled = ? AND user_id = ?", true, owner.UserID).
+			Order("id ASC").
+			Limit(r.batchSize)
+		if owner.UserGroupID == nil {
+			q = q.Where("user_group_id IS NULL")
+		} else {
+			q = q.Where("user_group_id = ?", *owner.UserGroupID)
+		}
+		if errRows := q.Find(&rows).Error; errRows != nil {
+			return errRows
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		ids := make([]uint64, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		claim := tx.Model(&models.Usage{}).
+			Where("id IN ? AND unbilled = ?", ids, true).
+			Update("unbilled", false)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected != int64(len(ids)) {
+			return errUnbilledRowsClaimed
+		}
+
+		charged, overCap, errCap := r.applySpendCap(ctx, tx, owner.UserID, rows)
+		if errCap != nil {
+			return errCap
+		}
+		if len(overCap) > 0 {
+			if errMark := markUsageOverSpendCap(ctx, tx, overCap...); errMark != nil {
+				return errMark
+			}
+		}
+
+		var chargeMicros, todayMicros int64
+		todayStart := internalsettings.BillingDayStart(r.now())
+		for _, row := range charged {
+			chargeMicros += row.CostMicros
+			if !row.RequestedAt.Before(todayStart) {
+				todayMicros += row.CostMicros
+			}
+		}
+		if chargeMicros > 0 {
+			// todayMicros lets the bill daily quota check exclude this batch from today's usage.
+			if errDeduct := deductBalance(ctx, tx, owner.UserID, owner.UserGroupID, float64(chargeMicros)/1_000_000, todayMicros); errDeduct != nil {
+				return errDeduct
+			}
+		}
+		result.ChargedRows = len(charged)
+		result.OverCapRows = len(overCap)
+		result.CostMicros = chargeMicros
+		return nil
+	})
+	if errTx != nil {
+		return BillingReconcileResult{}, errTx
+	}
+	return result, nil
+}
+
+// applySpendCap splits a batch into rows to charge and rows past the user's daily spend
+// cap. Rows are walked in insertion order as sync billing would have charged them, so a
+// row is over the cap when today's charged usage plus the row exceeds the cap. Rows over
+// the cap are not charged and do not count towards the usage of later rows.
+func (r *BillingReconciler) applySpendCap(ctx context.Context, tx *gorm.DB, userID uint64, rows []models.Usage) ([]models.Usage, []uint64, error) {
+	spendCap, errCap := loadDailySpendCap(ctx, tx, userID)
+	if errCap != nil {
+		return nil, nil, errCap
+	}
+	if spendCap <= 0 {
+		return rows, nil, nil
+	}
+	now := r.now()
+	usedToday, errUsage := loadTodayUsageAmount(ctx, tx, userID, nil, now)
+	if errUsage != nil {
+		return nil, nil, errUsage
+	}
+	todayStart := internalsettings.BillingDayStart(now)
+	var batchToday int64
+	for _, row := range rows {
+		if !row.RequestedAt.Before(todayStart) {
+			batchToday += row.CostMicros
+		}
+	}
+	// usedToday already includes this batch; start from the usage recorded before it.
+	used := usedToday - float64(batchToday)/1_000_000
+	charged := make([]models.Usage, 0, len(rows))
+	var overCap []uint64
+	for _, row := range rows {
+		if row.RequestedAt.Before(todayStart) {
+			charged = append(charged, row)
+			continue
+		}
+		cost := float64(row.CostMicros) / 1_000_000
+		if used+cost > spendCap+billQuotaEpsilon {
+			overCap = append(overCap, row.ID)
+			continue
+		}
+		used += cost
+		charged = append(charged, row)
+	}
+	return charged, overCap, nil
+}
@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestBillingReconcilerChargesUnbilledUsageOnce(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	payer := models.User{Username: "payer", Email: "payer@example.com", Password: "x"}
+	capped := models.User{Username: "capped", Email: "capped@example.com", Password: "x", DailySpendCap: 2.5}
+	for _, user := range []*models.User{&payer, &capped} {
+		if errCreate := conn.Create(user).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+		redeemedAt := now.Add(-time.Hour)
+		card := models.PrepaidCard{
+			Name:           user.Username,
+			CardSN:         "sn-" + user.Username,
+			Password:       "pw",
+			Amount:         10,
+			Balance:        10,
+			RedeemedUserID: &user.ID,
+			RedeemedAt:     &redeemedAt,
+			IsEnabled:      true,
+		}
+		if errCreate := conn.Create(&card).Error; errCreate != nil {
+			t.Fatalf("create card: %v", errCreate)
+		}
+		for i := 0; i < 3; i++ {
+			row := models.Usage{UserID: &user.ID, Provider: "p", Model: "m", RequestedAt: now, CostMicros: 1_000_000, Unbilled: true}
+			if errCreate := conn.Create(&row).Error; errCreate != nil {
+				t.Fatalf("create usage: %v", errCreate)
+			}
+		}
+	}
+
+	reconciler := NewBillingReconciler(conn)
+	result, errRun := reconciler.RunOnce(ctx)
+	if errRun != nil {
+		t.Fatalf("reconcile: %v", errRun)
+	}
+	if result.Users != 2 || result.ChargedRows != 5 || result.OverCapRows != 1 || result.CostMicros != 5_000_000 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	balances := map[string]float64{}
+	var cards []models.PrepaidCard
+	if errFind := conn.Find(&cards).Error; errFind != nil {
+		t.Fatalf("load cards: %v", errFind)
+	}
+	for _, card := range cards {
+		balances[card.Name] = card.Balance
+	}
+	if balances["payer"] != 7 || balances["capped"] != 8 {
+		t.Fatalf("unexpected balances %v", balances)
+	}
+	var failed []models.Usage
+	if errFind := conn.Where("failed = ?", true).Find(&failed).Error; errFind != nil {
+		t.Fatalf("load failed usage: %v", errFind)
+	}
+	if len(failed) != 1 || *failed[0].UserID != capped.ID || failed[0].CostMicros != 0 {
+		t.Fatalf("expected the capped user's last row to be flagged without cost, got %+v", failed)
+	}
+	if usedToday, errUsage := loadTodayUsageAmount(ctx, conn, capped.ID, nil, now); errUsage != nil || usedToday != 2 {
+		t.Fatalf("expected only charged rows in today's usage, got %v %v", usedToday, errUsage)
+	}
+	var unbilled int64
+	if errCount := conn.Model(&models.Usage{}).Where("unbilled = ?", true).Count(&unbilled).Error; errCount != nil || unbilled != 0 {
+		t.Fatalf("expected every row billed, %d left (%v)", unbilled, errCount)
+	}
+
+	if again, errAgain := reconciler.RunOnce(ctx); errAgain != nil || again != (BillingReconcileResult{}) {
+		t.Fatalf("expected a second pass to charge nothing, got %+v %v", again, errAgain)
+	}
+}
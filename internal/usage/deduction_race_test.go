@@ -0,0 +1,117 @@
+package usage
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func openDeductionTestDB(t *testing.T, dsn string) *gorm.DB {
+	t.Helper()
+	conn, errOpen := db.Open(dsn)
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	return conn
+}
+
+func createRedeemedCard(t *testing.T, conn *gorm.DB, userID uint64, balance float64) models.PrepaidCard {
+	t.Helper()
+	now := time.Now().UTC()
+	card := models.PrepaidCard{
+		Name:           "c",
+		CardSN:         "sn-" + now.Format("150405.000000000"),
+		Password:       "x",
+		Amount:         balance,
+		Balance:        balance,
+		IsEnabled:      true,
+		RedeemedUserID: &userID,
+		RedeemedAt:     &now,
+		CreatedAt:      now,
+	}
+	if errCreate := conn.Create(&card).Error; errCreate != nil {
+		t.Fatalf("create card: %v", errCreate)
+	}
+	return card
+}
+
+func TestTakePrepaidBalanceReloadsAfterConcurrentDeduction(t *testing.T) {
+	conn := openDeductionTestDB(t, ":memory:")
+	ctx := context.Background()
+	user := models.User{Username: "u", Email: "u@example.com", Password: "x"}
+	if errCreate := conn.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	card := createRedeemedCard(t, conn, user.ID, 3)
+
+	// The caller's snapshot says 10 is available, but only 3 is left.
+	taken, errTake := takePrepaidBalance(ctx, conn, card.ID, 10, 5)
+	if errTake != nil {
+		t.Fatalf("take balance: %v", errTake)
+	}
+	if taken != 3 {
+		t.Fatalf("expected to take the remaining 3, took %v", taken)
+	}
+	var reloaded models.PrepaidCard
+	if errFind := conn.First(&reloaded, card.ID).Error; errFind != nil {
+		t.Fatalf("reload card: %v", errFind)
+	}
+	if reloaded.Balance != 0 {
+		t.Fatalf("expected empty card, got %v", reloaded.Balance)
+	}
+}
+
+func TestConcurrentPrepaidDeductionNeverGoesNegative(t *testing.T) {
+	conn := openDeductionTestDB(t, filepath.Join(t.TempDir(), "race.db"))
+	ctx := context.Background()
+	user := models.User{Username: "u", Email: "u@example.com", Password: "x"}
+	if errCreate := conn.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	card := createRedeemedCard(t, conn, user.ID, 5)
+
+	const workers = 12
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errTx := conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return deductPrepaidBalance(ctx, tx, user.ID, nil, 1)
+			})
+			if errTx == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var reloaded models.PrepaidCard
+	if errFind := conn.First(&reloaded, card.ID).Error; errFind != nil {
+		t.Fatalf("reload card: %v", errFind)
+	}
+	if reloaded.Balance < 0 {
+		t.Fatalf("balance went negative: %v", reloaded.Balance)
+	}
+	if succeeded == 0 {
+		t.Fatalf("expected at least one deduction to succeed")
+	}
+	if want := 5 - float64(min(succeeded, 5)); reloaded.Balance != want {
+		t.Fatalf("expected balance %v after %d deductions, got %v", want, succeeded, reloaded.Balance)
+	}
+}
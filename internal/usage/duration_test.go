@@ -0,0 +1,48 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestUsageRecordsRequestDuration(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	plugin := NewGormUsagePlugin(conn)
+	plugin.HandleUsage(context.Background(), coreusage.Record{
+		Provider:    "openai",
+		Model:       "gpt-4",
+		RequestedAt: time.Now().Add(-1500 * time.Millisecond),
+		Detail:      coreusage.Detail{InputTokens: 1},
+	})
+	plugin.HandleUsage(context.Background(), coreusage.Record{
+		Provider: "openai",
+		Model:    "gpt-4",
+		Detail:   coreusage.Detail{InputTokens: 1},
+	})
+
+	var rows []models.Usage
+	if errFind := conn.Order("id ASC").Find(&rows).Error; errFind != nil {
+		t.Fatalf("load usage: %v", errFind)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 usage rows, got %d", len(rows))
+	}
+	if rows[0].DurationMs == nil || *rows[0].DurationMs < 1500 || *rows[0].DurationMs > 60_000 {
+		t.Fatalf("expected a duration of about 1.5s, got %v", rows[0].DurationMs)
+	}
+	if rows[1].DurationMs != nil {
+		t.Fatalf("expected no duration without a start time, got %d", *rows[1].DurationMs)
+	}
+}
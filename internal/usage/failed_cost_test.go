@@ -0,0 +1,77 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestCalculateCostHonorsBillFailedPolicy(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	ctx := context.Background()
+	authGroupID, errAuthGroup := billing.ResolveDefaultAuthGroupID(ctx, conn)
+	if errAuthGroup != nil || authGroupID == nil {
+		t.Fatalf("resolve default auth group: %v", errAuthGroup)
+	}
+	userGroupID, errUserGroup := billing.ResolveDefaultUserGroupID(ctx, conn)
+	if errUserGroup != nil || userGroupID == nil {
+		t.Fatalf("resolve default user group: %v", errUserGroup)
+	}
+	input, output, cache := 2.0, 10.0, 1.0
+	rule := models.BillingRule{
+		AuthGroupID:           *authGroupID,
+		UserGroupID:           *userGroupID,
+		Provider:              "openai",
+		Model:                 "gpt-4",
+		BillingType:           models.BillingTypePerToken,
+		PriceInputToken:       &input,
+		PriceOutputToken:      &output,
+		PriceCacheCreateToken: &cache,
+		PriceCacheReadToken:   &cache,
+		IsEnabled:             true,
+	}
+	if errCreate := conn.Create(&rule).Error; errCreate != nil {
+		t.Fatalf("create billing rule: %v", errCreate)
+	}
+
+	record := coreusage.Record{
+		Provider:    "openai",
+		Model:       "gpt-4",
+		RequestedAt: time.Now().UTC(),
+		Failed:      true,
+		Detail:      coreusage.Detail{InputTokens: 100, OutputTokens: 50, CachedTokens: 20},
+	}
+	cases := []struct {
+		policy models.BillFailedPolicy
+		want   int64
+	}{
+		{"", 0},
+		{models.BillFailedNone, 0},
+		{models.BillFailedInputOnly, 100*2 + 20*1},
+		{models.BillFailedFull, 100*2 + 50*10 + 20*1},
+	}
+	for _, tc := range cases {
+		if errUpdate := conn.Model(&rule).Update("bill_failed", tc.policy).Error; errUpdate != nil {
+			t.Fatalf("update policy: %v", errUpdate)
+		}
+		if got, _ := calculateCost(ctx, conn, nil, nil, nil, nil, record, false, nil); got != tc.want {
+			t.Fatalf("policy %q: cost = %d, want %d", tc.policy, got, tc.want)
+		}
+	}
+
+	record.Failed = false
+	if got, _ := calculateCost(ctx, conn, nil, nil, nil, nil, record, false, nil); got != 100*2+50*10+20*1 {
+		t.Fatalf("successful record cost = %d", got)
+	}
+}
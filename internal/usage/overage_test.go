@@ -0,0 +1,100 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestDeductPrepaidBalanceAccruesOverageDebt(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	groups := []models.UserGroup{
+		{Name: "strict"},
+		{Name: "grace", OveragePolicy: models.OveragePolicyAllowGrace, OverageGrace: 2},
+		{Name: "metered", OveragePolicy: models.OveragePolicyPayAsYouGo},
+	}
+	if errCreate := conn.Create(&groups).Error; errCreate != nil {
+		t.Fatalf("create groups: %v", errCreate)
+	}
+	users := make([]models.User, len(groups))
+	for i, group := range groups {
+		groupID := group.ID
+		users[i] = models.User{
+			Username:    group.Name,
+			Email:       group.Name + "@example.com",
+			Password:    "x",
+			UserGroupID: models.UserGroupIDs{&groupID},
+		}
+	}
+	if errCreate := conn.Create(&users).Error; errCreate != nil {
+		t.Fatalf("create users: %v", errCreate)
+	}
+	redeemedAt := now.Add(-time.Hour)
+	card := models.PrepaidCard{
+		Name:           "card",
+		CardSN:         "sn-1",
+		Password:       "pw",
+		Amount:         1,
+		Balance:        1,
+		RedeemedUserID: &users[2].ID,
+		RedeemedAt:     &redeemedAt,
+		IsEnabled:      true,
+	}
+	if errCreate := conn.Create(&card).Error; errCreate != nil {
+		t.Fatalf("create card: %v", errCreate)
+	}
+
+	deduct := func(user models.User, amount float64) {
+		t.Helper()
+		if errTx := conn.Transaction(func(tx *gorm.DB) error {
+			return deductPrepaidBalance(ctx, tx, user.ID, nil, amount)
+		}); errTx != nil {
+			t.Fatalf("deduct for %s: %v", user.Username, errTx)
+		}
+	}
+	overage := func(user models.User) (billing.OveragePolicy, float64) {
+		t.Helper()
+		policy, debt, errLoad := billing.LoadUserOverage(ctx, conn, user.ID)
+		if errLoad != nil {
+			t.Fatalf("load overage: %v", errLoad)
+		}
+		return policy, debt
+	}
+
+	deduct(users[0], 3)
+	if policy, debt := overage(users[0]); debt != 0 || policy.AllowsRequest(debt) {
+		t.Fatalf("expected block to write off the cost and reject, got %+v debt=%v", policy, debt)
+	}
+
+	deduct(users[1], 1.5)
+	if policy, debt := overage(users[1]); debt != 1.5 || !policy.AllowsRequest(debt) {
+		t.Fatalf("expected debt within the grace to be allowed, got %+v debt=%v", policy, debt)
+	}
+	deduct(users[1], 1)
+	if policy, debt := overage(users[1]); debt != 2.5 || policy.AllowsRequest(debt) {
+		t.Fatalf("expected debt past the grace to be blocked, got %+v debt=%v", policy, debt)
+	}
+
+	deduct(users[2], 4)
+	if policy, debt := overage(users[2]); debt != 3 || !policy.AllowsRequest(debt) {
+		t.Fatalf("expected only the uncovered cost as debt, got %+v debt=%v", policy, debt)
+	}
+	var left models.PrepaidCard
+	if errFind := conn.First(&left, card.ID).Error; errFind != nil || left.Balance != 0 {
+		t.Fatalf("expected the card to be drained first, got %v (%v)", left.Balance, errFind)
+	}
+}
@@ -0,0 +1,95 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	"gorm.io/gorm"
+)
+
+func TestDeductPrepaidBalanceFollowsDeductionOrder(t *testing.T) {
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+	now := time.Now().UTC()
+	// Cards in redemption order: old has no expiry, soon expires first, big holds the most.
+	specs := []struct {
+		name      string
+		balance   float64
+		redeemed  time.Duration
+		expiresIn time.Duration
+	}{
+		{"old", 2, -72 * time.Hour, 0},
+		{"soon", 2, -48 * time.Hour, 24 * time.Hour},
+		{"big", 5, -24 * time.Hour, 720 * time.Hour},
+	}
+	cases := []struct {
+		order string
+		want  map[string]float64 // Card balances after deducting 3.
+	}{
+		{"", map[string]float64{"old": 2, "soon": 0, "big": 4}},
+		{internalsettings.PrepaidDeductionSoonestExpiry, map[string]float64{"old": 2, "soon": 0, "big": 4}},
+		{internalsettings.PrepaidDeductionFIFO, map[string]float64{"old": 0, "soon": 1, "big": 5}},
+		{internalsettings.PrepaidDeductionLargestFirst, map[string]float64{"old": 2, "soon": 2, "big": 2}},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("order=%q", tc.order), func(t *testing.T) {
+			conn, errOpen := db.Open(":memory:")
+			if errOpen != nil {
+				t.Fatalf("open db: %v", errOpen)
+			}
+			if errMigrate := db.Migrate(conn); errMigrate != nil {
+				t.Fatalf("migrate db: %v", errMigrate)
+			}
+			values := map[string]json.RawMessage{}
+			if tc.order != "" {
+				values[internalsettings.PrepaidDeductionOrderKey] = json.RawMessage(`"` + tc.order + `"`)
+			}
+			internalsettings.StoreDBConfig(time.Now(), values)
+
+			user := models.User{Username: "u", Email: "u@example.com", Password: "x"}
+			if errCreate := conn.Create(&user).Error; errCreate != nil {
+				t.Fatalf("create user: %v", errCreate)
+			}
+			for _, spec := range specs {
+				redeemedAt := now.Add(spec.redeemed)
+				card := models.PrepaidCard{
+					Name:           spec.name,
+					CardSN:         "sn-" + spec.name,
+					Password:       "pw",
+					Amount:         spec.balance,
+					Balance:        spec.balance,
+					RedeemedUserID: &user.ID,
+					RedeemedAt:     &redeemedAt,
+					IsEnabled:      true,
+				}
+				if spec.expiresIn > 0 {
+					expiresAt := now.Add(spec.expiresIn)
+					card.ExpiresAt = &expiresAt
+				}
+				if errCreate := conn.Create(&card).Error; errCreate != nil {
+					t.Fatalf("create card: %v", errCreate)
+				}
+			}
+
+			if errTx := conn.Transaction(func(tx *gorm.DB) error {
+				return deductPrepaidBalance(context.Background(), tx, user.ID, nil, 3)
+			}); errTx != nil {
+				t.Fatalf("deduct: %v", errTx)
+			}
+			var cards []models.PrepaidCard
+			if errFind := conn.Find(&cards).Error; errFind != nil {
+				t.Fatalf("load cards: %v", errFind)
+			}
+			for _, card := range cards {
+				if card.Balance != tc.want[card.Name] {
+					t.Fatalf("card %s: expected balance %v, got %v", card.Name, tc.want[card.Name], card.Balance)
+				}
+			}
+		})
+	}
+}
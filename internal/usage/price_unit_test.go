@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"testing"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestCostFromRuleScalesTokenPricesByUnit(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+	record := coreusage.Record{
+		Provider: "claude",
+		Model:    "claude-sonnet",
+		Detail:   coreusage.Detail{InputTokens: 1000, OutputTokens: 2000, CachedTokens: 500},
+	}
+	// 1000*$3/M + 2000*$15/M + 500*$0.30/M = $0.03315.
+	const want = 33_150
+
+	cases := []struct {
+		unit                models.PriceUnit
+		input, output, read float64
+	}{
+		{unit: "", input: 3, output: 15, read: 0.3},
+		{unit: models.PriceUnitPer1M, input: 3, output: 15, read: 0.3},
+		{unit: models.PriceUnitPer1K, input: 0.003, output: 0.015, read: 0.0003},
+		{unit: models.PriceUnitPerToken, input: 0.000003, output: 0.000015, read: 0.0000003},
+	}
+	for _, tc := range cases {
+		rule := &models.BillingRule{
+			BillingType:           models.BillingTypePerToken,
+			PriceInputToken:       price(tc.input),
+			PriceOutputToken:      price(tc.output),
+			PriceCacheCreateToken: price(0),
+			PriceCacheReadToken:   price(tc.read),
+			PriceUnit:             tc.unit,
+		}
+		if got := costFromRule(rule, record, false); got != want {
+			t.Fatalf("unit %q: expected %d micros, got %d", tc.unit, want, got)
+		}
+		rule.StreamPriceMultiplier = price(2)
+		if got := costFromRule(rule, record, true); got != 2*want {
+			t.Fatalf("unit %q: expected %d micros when streamed, got %d", tc.unit, 2*want, got)
+		}
+	}
+
+	perRequest := &models.BillingRule{
+		BillingType:     models.BillingTypePerRequest,
+		PricePerRequest: price(0.02),
+		PriceUnit:       models.PriceUnitPerToken,
+	}
+	if got := costFromRule(perRequest, record, false); got != 20_000 {
+		t.Fatalf("expected the price unit not to affect per-request rules, got %d", got)
+	}
+}
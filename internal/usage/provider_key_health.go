@@ -0,0 +1,238 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ProviderKeyEventDegraded is the webhook event sent when a provider API key is degraded.
+const ProviderKeyEventDegraded = "provider_key.degraded"
+
+// AuthLookup returns the runtime auth with the given ID.
+type AuthLookup func(id string) (*coreauth.Auth, bool)
+
+// authFailureTracker counts consecutive upstream auth failures per runtime auth ID. The
+// count in memory is authoritative; the persisted auth_failure_count mirrors it so admins
+// can see keys that are about to be degraded.
+type authFailureTracker struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// newAuthFailureTracker constructs an empty tracker.
+func newAuthFailureTracker() *authFailureTracker {
+	return &authFailureTracker{counts: make(map[string]int)}
+}
+
+// increment counts one more failure for authID and returns the new count.
+func (t *authFailureTracker) increment(authID string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counts[authID]++
+	return t.counts[authID]
+}
+
+// reset forgets authID and reports whether it had failures.
+func (t *authFailureTracker) reset(authID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.counts[authID]; !ok {
+		return false
+	}
+	delete(t.counts, authID)
+	return true
+}
+
+// SetAuthLookup lets the plugin map config-derived auths back to their provider API keys,
+// which enables degrading keys after repeated upstream auth failures.
+func (p *GormUsagePlugin) SetAuthLookup(lookup AuthLookup) {
+	if p != nil {
+		p.authLookup = lookup
+	}
+}
+
+// providerKeyForAuth returns the provider and upstream API key of a config-derived auth.
+func (p *GormUsagePlugin) providerKeyForAuth(authID string) (string, string, bool) {
+	authID = strings.TrimSpace(authID)
+	if p.authLookup == nil || authID == "" {
+		return "", "", false
+	}
+	auth, ok := p.authLookup(authID)
+	if !ok || auth == nil || auth.Attributes == nil {
+		return "", "", false
+	}
+	if !strings.HasPrefix(auth.Attributes["source"], "config:") {
+		return "", "", false
+	}
+	apiKey := strings.TrimSpace(auth.Attributes["api_key"])
+	provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+	if apiKey == "" || provider == "" {
+		return "", "", false
+	}
+	return provider, apiKey, true
+}
+
+// recordAuthFailure counts an upstream 401/403 against the provider API key behind authID
+// and degrades the key once PROVIDER_KEY_AUTH_FAILURE_THRESHOLD is reached in a row.
+func (p *GormUsagePlugin) recordAuthFailure(logger *log.Entry, authID string) {
+	provider, apiKey, ok := p.providerKeyForAuth(authID)
+	if !ok {
+		return
+	}
+	count := p.authFailures.increment(authID)
+
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	threshold := internalsettings.GetInt(internalsettings.ProviderKeyAuthFailureThresholdKey)
+	if threshold <= 0 || count < threshold {
+		if errCount := p.db.WithContext(dbCtx).
+			Model(&models.ProviderAPIKey{}).
+			Where("provider = ? AND api_key = ? AND degraded = ?", provider, apiKey, false).
+			UpdateColumn("auth_failure_count", count).Error; errCount != nil {
+			logger.WithError(errCount).Warnf("usage plugin: record provider key auth failure failed (auth=%s)", authID)
+		}
+		return
+	}
+
+	degraded, errDegrade := degradeProviderKeys(dbCtx, p.db, provider, apiKey, count, time.Now().UTC())
+	if errDegrade != nil {
+		logger.WithError(errDegrade).Warnf("usage plugin: degrade provider key failed (auth=%s)", authID)
+		return
+	}
+	p.authFailures.reset(authID)
+	for i := range degraded {
+		logger.WithField("provider_api_key_id", degraded[i].ID).
+			Warnf("usage plugin: degraded %s provider key after %d consecutive auth failures", provider, count)
+		notifyProviderKeyDegraded(degraded[i])
+	}
+}
+
+// clearAuthFailures resets the failure count of the key behind authID after a success.
+func (p *GormUsagePlugin) clearAuthFailures(logger *log.Entry, authID string) {
+	if !p.authFailures.reset(authID) {
+		return
+	}
+	provider, apiKey, ok := p.providerKeyForAuth(authID)
+	if !ok {
+		return
+	}
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if errReset := p.db.WithContext(dbCtx).
+		Model(&models.ProviderAPIKey{}).
+		Where("provider = ? AND api_key = ? AND auth_failure_count > 0", provider, apiKey).
+		UpdateColumn("auth_failure_count", 0).Error; errReset != nil {
+		logger.WithError(errReset).Warnf("usage plugin: reset provider key auth failures failed (auth=%s)", authID)
+	}
+}
+
+// degradeProviderKeys marks the healthy provider API keys holding apiKey as degraded and
+// returns the rows it changed. UpdatedAt moves forward so the DB watcher rebuilds the
+// config without them.
+func degradeProviderKeys(ctx context.Context, db *gorm.DB, provider, apiKey string, failures int, now time.Time) ([]models.ProviderAPIKey, error) {
+	var degraded []models.ProviderAPIKey
+	errTx := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []models.ProviderAPIKey
+		if errFind := tx.Where("provider = ? AND api_key = ? AND degraded = ?", provider, apiKey, false).
+			Order("id ASC").
+			Find(&rows).Error; errFind != nil {
+			return errFind
+		}
+		for i := range rows {
+			res := tx.Model(&models.ProviderAPIKey{}).
+				Where("id = ? AND degraded = ?", rows[i].ID, false).
+				Updates(map[string]any{
+					"degraded":           true,
+					"degraded_at":        now,
+					"auth_failure_count": failures,
+					"updated_at":         now,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			rows[i].Degraded = true
+			rows[i].DegradedAt = &now
+			rows[i].AuthFailureCount = failures
+			rows[i].UpdatedAt = now
+			degraded = append(degraded, rows[i])
+		}
+		return nil
+	})
+	if errTx != nil {
+		return nil, errTx
+	}
+	return degraded, nil
+}
+
+// providerKeyWebhookPayload is the JSON body posted to PROVIDER_KEY_WEBHOOK_URL.
+type providerKeyWebhookPayload struct {
+	Event            string    `json:"event"`
+	ProviderAPIKeyID uint64    `json:"provider_api_key_id"`
+	Provider         string    `json:"provider"`
+	Name             string    `json:"name"`
+	AuthFailureCount int       `json:"auth_failure_count"`
+	OccurredAt       time.Time `json:"occurred_at"`
+}
+
+// providerKeyWebhookClient delivers provider key events.
+var providerKeyWebhookClient = &http.Client{Timeout: alertWebhookTimeout}
+
+// notifyProviderKeyDegraded posts a degraded event for row in the background. Nothing is
+// sent when no URL is configured; delivery failures are logged only.
+func notifyProviderKeyDegraded(row models.ProviderAPIKey) {
+	url := internalsettings.GetString(internalsettings.ProviderKeyWebhookURLKey)
+	if url == "" {
+		return
+	}
+	payload := providerKeyWebhookPayload{
+		Event:            ProviderKeyEventDegraded,
+		ProviderAPIKeyID: row.ID,
+		Provider:         row.Provider,
+		Name:             row.Name,
+		AuthFailureCount: row.AuthFailureCount,
+		OccurredAt:       time.Now().UTC(),
+	}
+	go func() {
+		if errPost := postProviderKeyWebhook(context.Background(), providerKeyWebhookClient, url, payload); errPost != nil {
+			log.WithError(errPost).WithField("provider_api_key_id", row.ID).Warn("provider key webhook: delivery failed")
+		}
+	}()
+}
+
+// postProviderKeyWebhook sends payload to url as JSON.
+func postProviderKeyWebhook(ctx context.Context, client *http.Client, url string, payload providerKeyWebhookPayload) error {
+	body, errMarshal := json.Marshal(payload)
+	if errMarshal != nil {
+		return errMarshal
+	}
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if errReq != nil {
+		return errReq
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return errDo
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
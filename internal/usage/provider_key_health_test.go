@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+func TestRepeatedAuthFailuresDegradeProviderKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	key := models.ProviderAPIKey{Provider: "gemini", APIKey: "revoked-key"}
+	if errCreate := conn.Create(&key).Error; errCreate != nil {
+		t.Fatalf("create provider key: %v", errCreate)
+	}
+
+	events := make(chan providerKeyWebhookPayload, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload providerKeyWebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		events <- payload
+	}))
+	defer hook.Close()
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.ProviderKeyAuthFailureThresholdKey: json.RawMessage(`2`),
+		internalsettings.ProviderKeyWebhookURLKey:           json.RawMessage(`"` + hook.URL + `"`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Now(), nil) })
+
+	plugin := NewGormUsagePlugin(conn)
+	plugin.SetAuthLookup(func(id string) (*coreauth.Auth, bool) {
+		if id != "gemini:apikey:abc" {
+			return nil, false
+		}
+		return &coreauth.Auth{ID: id, Provider: "gemini", Attributes: map[string]string{
+			"source":  "config:gemini[abc]",
+			"api_key": "revoked-key",
+		}}, true
+	})
+	respond := func(status int) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini:generateContent", nil)
+		c.Status(status)
+		c.Writer.WriteHeaderNow()
+		plugin.HandleUsage(context.WithValue(context.Background(), "gin", c), coreusage.Record{
+			Provider:    "gemini",
+			Model:       "gemini-pro",
+			AuthID:      "gemini:apikey:abc",
+			RequestedAt: time.Now().UTC(),
+			Failed:      status >= http.StatusBadRequest,
+		})
+	}
+	load := func() models.ProviderAPIKey {
+		var row models.ProviderAPIKey
+		if errFind := conn.First(&row, key.ID).Error; errFind != nil {
+			t.Fatalf("load provider key: %v", errFind)
+		}
+		return row
+	}
+
+	respond(http.StatusUnauthorized)
+	if row := load(); row.Degraded || row.AuthFailureCount != 1 {
+		t.Fatalf("expected one counted failure, got degraded=%v count=%d", row.Degraded, row.AuthFailureCount)
+	}
+	respond(http.StatusOK)
+	if row := load(); row.AuthFailureCount != 0 {
+		t.Fatalf("expected a success to reset the count, got %d", row.AuthFailureCount)
+	}
+
+	respond(http.StatusForbidden)
+	respond(http.StatusUnauthorized)
+	row := load()
+	if !row.Degraded || row.DegradedAt == nil || row.AuthFailureCount != 2 {
+		t.Fatalf("expected the key degraded after two consecutive failures, got %+v", row)
+	}
+	select {
+	case payload := <-events:
+		if payload.Event != ProviderKeyEventDegraded || payload.ProviderAPIKeyID != key.ID {
+			t.Fatalf("unexpected webhook payload %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a degraded webhook event")
+	}
+}
@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultRelinkBatchSize bounds the usage rows scanned per relink batch.
+const defaultRelinkBatchSize = 1000
+
+// RelinkResult summarizes one orphaned auth relink pass.
+type RelinkResult struct {
+	Scanned  int64 `json:"scanned"`  // Orphaned usage rows examined.
+	Relinked int64 `json:"relinked"` // Usage rows re-linked to an auth record.
+	Orphaned int64 `json:"orphaned"` // Usage rows still carrying an auth key with no auth record.
+}
+
+// RelinkOrphanedAuths sets auth_id on usage rows recorded while their auth key had no
+// auth record, now that a record with that key exists again. Rows are scanned in id
+// order in batches of batchSize, and only rows whose auth_id is still null are updated,
+// so repeated runs are safe.
+func RelinkOrphanedAuths(ctx context.Context, db *gorm.DB, batchSize int) (RelinkResult, error) {
+	var result RelinkResult
+	if db == nil {
+		return result, fmt.Errorf("usage relink: nil db")
+	}
+	if batchSize <= 0 {
+		batchSize = defaultRelinkBatchSize
+	}
+
+	// orphanRow is an unlinked usage row with its recorded auth key.
+	type orphanRow struct {
+		ID      uint64
+		AuthKey string
+	}
+	lastID := uint64(0)
+	for {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return result, errCtx
+		}
+		var rows []orphanRow
+		if errFind := db.WithContext(ctx).Model(&models.Usage{}).
+			Select("id", "auth_key").
+			Where("auth_id IS NULL AND auth_key <> '' AND id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&rows).Error; errFind != nil {
+			return result, fmt.Errorf("usage relink: scan usage: %w", errFind)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		lastID = rows[len(rows)-1].ID
+		result.Scanned += int64(len(rows))
+
+		idsByKey := make(map[string][]uint64)
+		for _, row := range rows {
+			key := strings.TrimSpace(row.AuthKey)
+			if key != "" {
+				idsByKey[key] = append(idsByKey[key], row.ID)
+			}
+		}
+		keys := make([]string, 0, len(idsByKey))
+		for key := range idsByKey {
+			keys = append(keys, key)
+		}
+		var auths []models.Auth
+		if errFind := db.WithContext(ctx).
+			Select("id", "key").
+			Where("key IN ?", keys).
+			Find(&auths).Error; errFind != nil {
+			return result, fmt.Errorf("usage relink: load auths: %w", errFind)
+		}
+		for _, auth := range auths {
+			ids := idsByKey[strings.TrimSpace(auth.Key)]
+			if len(ids) == 0 {
+				continue
+			}
+			res := db.WithContext(ctx).Model(&models.Usage{}).
+				Where("id IN ? AND auth_id IS NULL", ids).
+				Update("auth_id", auth.ID)
+			if res.Error != nil {
+				return result, fmt.Errorf("usage relink: update usage: %w", res.Error)
+			}
+			result.Relinked += res.RowsAffected
+		}
+		if len(rows) < batchSize {
+			break
+		}
+	}
+
+	if errCount := db.WithContext(ctx).Model(&models.Usage{}).
+		Where("auth_id IS NULL AND auth_key <> ''").
+		Count(&result.Orphaned).Error; errCount != nil {
+		return result, fmt.Errorf("usage relink: count orphaned: %w", errCount)
+	}
+	return result, nil
+}
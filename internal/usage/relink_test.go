@@ -0,0 +1,56 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/datatypes"
+)
+
+func TestRelinkOrphanedAuths(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	now := time.Now().UTC()
+	auth := models.Auth{Key: "back.json", Content: datatypes.JSON(`{}`), AuthGroupID: models.AuthGroupIDs{}, CreatedAt: now, UpdatedAt: now}
+	if errCreate := conn.Create(&auth).Error; errCreate != nil {
+		t.Fatalf("create auth: %v", errCreate)
+	}
+	otherID := uint64(999)
+	rows := []models.Usage{
+		{Provider: "codex", Model: "gpt-5", AuthKey: "back.json", RequestedAt: now},
+		{Provider: "codex", Model: "gpt-5", AuthKey: "gone.json", RequestedAt: now},
+		{Provider: "codex", Model: "gpt-5", AuthKey: "back.json", RequestedAt: now},
+		{Provider: "codex", Model: "gpt-5", AuthKey: "back.json", AuthID: &otherID, RequestedAt: now},
+		{Provider: "codex", Model: "gpt-5", RequestedAt: now},
+	}
+	if errCreate := conn.Create(&rows).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	result, errRelink := RelinkOrphanedAuths(context.Background(), conn, 1)
+	if errRelink != nil {
+		t.Fatalf("relink: %v", errRelink)
+	}
+	if result.Scanned != 3 || result.Relinked != 2 || result.Orphaned != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	var linked int64
+	conn.Model(&models.Usage{}).Where("auth_id = ?", auth.ID).Count(&linked)
+	if linked != 2 {
+		t.Fatalf("expected 2 rows linked to the auth, got %d", linked)
+	}
+
+	again, errAgain := RelinkOrphanedAuths(context.Background(), conn, 0)
+	if errAgain != nil || again.Relinked != 0 || again.Orphaned != 1 {
+		t.Fatalf("expected a second pass to change nothing, got %+v (%v)", again, errAgain)
+	}
+}
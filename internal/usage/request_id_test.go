@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestUsageRecordsRequestIDEchoedOnResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	plugin := NewGormUsagePlugin(conn)
+	r := gin.New()
+	r.Use(logging.GinRequestIDMiddleware())
+	r.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("accessMetadata", map[string]string{"user_id": "7"})
+		plugin.HandleUsage(context.WithValue(c.Request.Context(), "gin", c), coreusage.Record{
+			Provider:    "openai",
+			Model:       "gpt-4",
+			RequestedAt: time.Now().UTC(),
+			Failed:      true,
+		})
+		c.Status(http.StatusBadGateway)
+	})
+
+	cases := []struct {
+		inbound string
+		want    string
+	}{
+		{inbound: "support-123", want: "support-123"},
+		{inbound: "bad id\n", want: ""},
+		{inbound: "", want: ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if tc.inbound != "" {
+			req.Header.Set(logging.RequestIDHeader, tc.inbound)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		echoed := w.Header().Get(logging.RequestIDHeader)
+		if echoed == "" || (tc.want != "" && echoed != tc.want) || (tc.want == "" && echoed == tc.inbound) {
+			t.Fatalf("inbound %q: unexpected echoed request id %q", tc.inbound, echoed)
+		}
+		var row models.Usage
+		if errFind := conn.Order("id DESC").First(&row).Error; errFind != nil {
+			t.Fatalf("load usage: %v", errFind)
+		}
+		if row.RequestID == nil || *row.RequestID != echoed {
+			t.Fatalf("inbound %q: expected usage request id %q, got %v", tc.inbound, echoed, row.RequestID)
+		}
+	}
+}
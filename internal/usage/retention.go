@@ -0,0 +1,182 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultRetentionInterval is how often the retention pass runs.
+	defaultRetentionInterval = time.Hour
+	// defaultRetentionBatchSize bounds the rows removed per delete statement.
+	defaultRetentionBatchSize = 1000
+)
+
+// RetentionResult summarizes one retention pass.
+type RetentionResult struct {
+	RolledUpDays int   // Days aggregated into usage_daily_rollups.
+	DeletedRows  int64 // Raw usage rows removed.
+}
+
+// RetentionJob rolls up and then deletes usage rows older than USAGE_RETENTION_DAYS.
+type RetentionJob struct {
+	db        *gorm.DB
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time
+}
+
+// NewRetentionJob constructs a usage retention job.
+func NewRetentionJob(db *gorm.DB) *RetentionJob {
+	if db == nil {
+		return nil
+	}
+	return &RetentionJob{
+		db:        db,
+		interval:  defaultRetentionInterval,
+		batchSize: defaultRetentionBatchSize,
+		now:       time.Now,
+	}
+}
+
+// Start runs the retention loop in the background.
+func (j *RetentionJob) Start(ctx context.Context) {
+	if j == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go j.run(ctx)
+	log.Infof("usage retention job started (interval=%s)", j.interval)
+}
+
+// run executes retention passes until ctx is canceled.
+func (j *RetentionJob) run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			days := usageRetentionDays()
+			if days <= 0 {
+				continue
+			}
+			result, errRun := j.RunOnce(ctx, days)
+			if errRun != nil {
+				if !errors.Is(errRun, context.Canceled) {
+					log.WithError(errRun).Warn("usage retention: pass failed")
+				}
+				continue
+			}
+			if result.DeletedRows > 0 {
+				log.Infof("usage retention: rolled up %d day(s), deleted %d usage row(s)", result.RolledUpDays, result.DeletedRows)
+			}
+		}
+	}
+}
+
+// usageRetentionDays reads USAGE_RETENTION_DAYS; 0 disables retention.
+func usageRetentionDays() int {
+	return internalsettings.GetInt(internalsettings.UsageRetentionDaysKey)
+}
+
+// RunOnce removes usage rows older than retentionDays whole UTC days. Each day is rolled
+// up into usage_daily_rollups before its raw rows are deleted in batches. A day that is
+// already rolled up only has late rows merged in, so a pass interrupted mid-delete never
+// undercounts when it resumes.
+func (j *RetentionJob) RunOnce(ctx context.Context, retentionDays int) (RetentionResult, error) {
+	var result RetentionResult
+	if j == nil || j.db == nil {
+		return result, errors.New("usage retention: nil db")
+	}
+	if retentionDays <= 0 {
+		return result, nil
+	}
+	clock := j.now
+	if clock == nil {
+		clock = time.Now
+	}
+	cutoff := startOfUTCDay(clock()).AddDate(0, 0, -retentionDays)
+
+	for {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return result, errCtx
+		}
+		var oldest models.Usage
+		res := j.db.WithContext(ctx).
+			Select("requested_at").
+			Where("requested_at < ?", cutoff).
+			Order("requested_at ASC").
+			Limit(1).
+			Find(&oldest)
+		if res.Error != nil {
+			return result, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return result, nil
+		}
+
+		day := startOfUTCDay(oldest.RequestedAt)
+		_, rolled, errHas := findRollupDay(ctx, j.db, day)
+		if errHas != nil {
+			return result, errHas
+		}
+		if rolled {
+			if _, errMerge := MergeLateUsage(ctx, j.db, day); errMerge != nil {
+				return result, errMerge
+			}
+		} else {
+			if errRollup := RollupUsageDay(ctx, j.db, day); errRollup != nil {
+				return result, errRollup
+			}
+			result.RolledUpDays++
+		}
+
+		deleted, errDelete := j.deleteRange(ctx, day, day.AddDate(0, 0, 1))
+		result.DeletedRows += deleted
+		if errDelete != nil {
+			return result, errDelete
+		}
+		if deleted == 0 {
+			return result, errors.New("usage retention: no rows deleted for " + day.Format("2006-01-02"))
+		}
+	}
+}
+
+// deleteRange deletes usage rows in [start, end) in batches and returns the row count.
+func (j *RetentionJob) deleteRange(ctx context.Context, start, end time.Time) (int64, error) {
+	batchSize := j.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultRetentionBatchSize
+	}
+	var total int64
+	for {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return total, errCtx
+		}
+		ids := j.db.WithContext(ctx).
+			Model(&models.Usage{}).
+			Select("id").
+			Where("requested_at >= ? AND requested_at < ?", start, end).
+			Order("id ASC").
+			Limit(batchSize)
+		res := j.db.WithContext(ctx).Where("id IN (?)", ids).Delete(&models.Usage{})
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestRetentionRollsUpBeforeDeleting(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	userID := uint64(7)
+	rows := []models.Usage{
+		{Provider: "openai", Model: "gpt-4", UserID: &userID, RequestedAt: now.AddDate(0, 0, -10), TotalTokens: 10, CostMicros: 100},
+		{Provider: "openai", Model: "gpt-4", UserID: &userID, RequestedAt: now.AddDate(0, 0, -10).Add(time.Hour), TotalTokens: 5, CostMicros: 50, Failed: true},
+		{Provider: "claude", Model: "sonnet", RequestedAt: now.AddDate(0, 0, -9), TotalTokens: 3, CostMicros: 30},
+		{Provider: "openai", Model: "gpt-4", UserID: &userID, RequestedAt: now.AddDate(0, 0, -1), TotalTokens: 1, CostMicros: 10},
+	}
+	if errCreate := conn.Create(&rows).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	job := NewRetentionJob(conn)
+	job.batchSize = 1
+	job.now = func() time.Time { return now }
+	result, errRun := job.RunOnce(context.Background(), 5)
+	if errRun != nil {
+		t.Fatalf("run retention: %v", errRun)
+	}
+	if result.RolledUpDays != 2 || result.DeletedRows != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	var remaining int64
+	if errCount := conn.Model(&models.Usage{}).Count(&remaining).Error; errCount != nil {
+		t.Fatalf("count usages: %v", errCount)
+	}
+	if remaining != 1 {
+		t.Fatalf("expected 1 remaining usage row, got %d", remaining)
+	}
+
+	var rollups []models.UsageDailyRollup
+	if errFind := conn.Order("day ASC").Find(&rollups).Error; errFind != nil {
+		t.Fatalf("query rollups: %v", errFind)
+	}
+	if len(rollups) != 2 {
+		t.Fatalf("expected 2 rollup rows, got %d", len(rollups))
+	}
+	first := rollups[0]
+	if first.UserID != userID || first.RequestCount != 2 || first.ErrorCount != 1 || first.TotalTokens != 15 || first.CostMicros != 150 {
+		t.Fatalf("unexpected first rollup %+v", first)
+	}
+	if rollups[1].UserID != 0 || rollups[1].Provider != "claude" || rollups[1].RequestCount != 1 {
+		t.Fatalf("unexpected second rollup %+v", rollups[1])
+	}
+
+	if result, errRun = job.RunOnce(context.Background(), 5); errRun != nil || result.DeletedRows != 0 {
+		t.Fatalf("expected idempotent second pass, got %+v %v", result, errRun)
+	}
+}
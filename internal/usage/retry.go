@@ -0,0 +1,207 @@
+package usage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"expvar"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultRetryBufferSize bounds how many failed usage writes are kept in memory.
+	defaultRetryBufferSize = 10000
+	// retryFlushInterval is how often the background flusher looks for due entries.
+	retryFlushInterval = time.Second
+	// retryBaseBackoff is the delay before the first retry of a failed write.
+	retryBaseBackoff = time.Second
+	// retryMaxBackoff caps the exponential backoff between retries.
+	retryMaxBackoff = time.Minute
+	// retryMaxAttempts drops an entry after this many failed retries.
+	retryMaxAttempts = 30
+	// shutdownFlushTimeout bounds the final flush performed by Close.
+	shutdownFlushTimeout = 15 * time.Second
+)
+
+// retryBufferDepth exposes the number of buffered usage writes via expvar.
+var retryBufferDepth = expvar.NewInt("usage_retry_buffer_depth")
+
+// RetryBufferDepth returns the number of usage writes waiting to be retried.
+func RetryBufferDepth() int64 {
+	return retryBufferDepth.Value()
+}
+
+// retryBuffer is a bounded FIFO of usage writes awaiting retry.
+type retryBuffer struct {
+	mu       sync.Mutex
+	entries  []*pendingUsage
+	capacity int
+}
+
+// newRetryBuffer constructs a retryBuffer holding at most capacity entries.
+func newRetryBuffer(capacity int) *retryBuffer {
+	if capacity <= 0 {
+		capacity = defaultRetryBufferSize
+	}
+	return &retryBuffer{capacity: capacity}
+}
+
+// push schedules entry for retry, dropping it when the buffer is full.
+func (b *retryBuffer) push(entry *pendingUsage, now time.Time) bool {
+	if b == nil || entry == nil {
+		return false
+	}
+	entry.nextAttempt = now.Add(retryBackoff(entry.attempts))
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.entries) >= b.capacity {
+		log.Errorf("usage plugin: retry buffer full (%d), dropping usage record for model %s", b.capacity, entry.record.Model)
+		return false
+	}
+	b.entries = append(b.entries, entry)
+	retryBufferDepth.Set(int64(len(b.entries)))
+	return true
+}
+
+// takeDue removes and returns entries whose retry time has passed; force returns all entries.
+func (b *retryBuffer) takeDue(now time.Time, force bool) []*pendingUsage {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.entries) == 0 {
+		return nil
+	}
+	due := make([]*pendingUsage, 0, len(b.entries))
+	kept := b.entries[:0]
+	for _, entry := range b.entries {
+		if force || !entry.nextAttempt.After(now) {
+			due = append(due, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	for i := len(kept); i < len(b.entries); i++ {
+		b.entries[i] = nil
+	}
+	b.entries = kept
+	retryBufferDepth.Set(int64(len(b.entries)))
+	return due
+}
+
+// len returns the number of buffered entries.
+func (b *retryBuffer) len() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// retryBackoff returns the exponential delay for the given attempt count.
+func retryBackoff(attempts int) time.Duration {
+	delay := retryBaseBackoff
+	for i := 0; i < attempts && delay < retryMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxBackoff {
+		delay = retryMaxBackoff
+	}
+	return delay
+}
+
+// Start launches the background flusher for buffered usage writes.
+func (p *GormUsagePlugin) Start() {
+	if p == nil || p.db == nil {
+		return
+	}
+	go p.runRetries()
+}
+
+// Close stops the background flusher and makes a final attempt to write buffered usage.
+func (p *GormUsagePlugin) Close() {
+	if p == nil || p.db == nil {
+		return
+	}
+	p.once.Do(func() {
+		close(p.stop)
+	})
+	select {
+	case <-p.done:
+	case <-time.After(shutdownFlushTimeout):
+		log.Warn("usage plugin: timed out waiting for retry flusher to stop")
+		return
+	}
+
+	deadline := time.Now().Add(shutdownFlushTimeout)
+	entries := p.retry.takeDue(time.Now(), true)
+	lost := 0
+	for _, entry := range entries {
+		if time.Now().After(deadline) {
+			lost++
+			continue
+		}
+		if errPersist := p.persist(entry); errPersist != nil {
+			log.WithError(errPersist).Warn("usage plugin: final flush failed")
+			lost++
+		}
+	}
+	retryBufferDepth.Set(0)
+	if lost > 0 {
+		log.Errorf("usage plugin: %d buffered usage records could not be written before shutdown", lost)
+	}
+}
+
+// runRetries replays due entries until Close is called.
+func (p *GormUsagePlugin) runRetries() {
+	defer close(p.done)
+	ticker := time.NewTicker(retryFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.flushDue(time.Now())
+		}
+	}
+}
+
+// flushDue retries entries whose backoff has elapsed and re-queues the ones that still fail.
+func (p *GormUsagePlugin) flushDue(now time.Time) {
+	entries := p.retry.takeDue(now, false)
+	for i, entry := range entries {
+		errPersist := p.persist(entry)
+		if errPersist == nil {
+			continue
+		}
+		entry.attempts++
+		if entry.attempts >= retryMaxAttempts {
+			log.WithError(errPersist).Errorf("usage plugin: giving up on usage record for model %s after %d attempts", entry.record.Model, entry.attempts)
+		} else {
+			p.retry.push(entry, time.Now())
+		}
+		// The database is likely still unavailable; defer the rest without burning attempts.
+		for _, rest := range entries[i+1:] {
+			p.retry.push(rest, time.Now())
+		}
+		return
+	}
+	if len(entries) > 0 {
+		log.Infof("usage plugin: flushed %d buffered usage records", len(entries))
+	}
+}
+
+// newIdempotencyKey returns a random key identifying one usage record across retries.
+func newIdempotencyKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, errRead := io.ReadFull(rand.Reader, buf); errRead != nil {
+		return "", errRead
+	}
+	return hex.EncodeToString(buf), nil
+}
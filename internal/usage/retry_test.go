@@ -0,0 +1,97 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestPersistIsIdempotentAcrossRetries(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	plugin := NewGormUsagePlugin(conn)
+	entry := &pendingUsage{
+		key:       "retry-key",
+		record:    coreusage.Record{Provider: "p", Model: "m", RequestedAt: time.Now().UTC()},
+		createdAt: time.Now().UTC(),
+	}
+	for i := 0; i < 2; i++ {
+		if errPersist := plugin.persist(entry); errPersist != nil {
+			t.Fatalf("persist attempt %d: %v", i+1, errPersist)
+		}
+	}
+
+	var count int64
+	if errCount := conn.Model(&models.Usage{}).Where("idempotency_key = ?", "retry-key").Count(&count).Error; errCount != nil {
+		t.Fatalf("count usage: %v", errCount)
+	}
+	if count != 1 {
+		t.Fatalf("expected one usage row, got %d", count)
+	}
+}
+
+func TestRetryBufferBoundsAndBackoff(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	buffer := newRetryBuffer(2)
+	for i := 0; i < 3; i++ {
+		buffer.push(&pendingUsage{key: "k", attempts: i}, now)
+	}
+	if buffer.len() != 2 {
+		t.Fatalf("expected buffer capped at 2, got %d", buffer.len())
+	}
+	if RetryBufferDepth() != 2 {
+		t.Fatalf("expected depth gauge 2, got %d", RetryBufferDepth())
+	}
+
+	if due := buffer.takeDue(now.Add(1500*time.Millisecond), false); len(due) != 1 {
+		t.Fatalf("expected only the first entry to be due, got %d", len(due))
+	}
+	if due := buffer.takeDue(now, true); len(due) != 1 || buffer.len() != 0 {
+		t.Fatalf("expected forced take to drain the buffer")
+	}
+	if retryBackoff(100) != retryMaxBackoff {
+		t.Fatalf("expected backoff to be capped at %s", retryMaxBackoff)
+	}
+}
+
+func TestPersistRecordsRequestedModel(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	plugin := NewGormUsagePlugin(conn)
+	entries := []*pendingUsage{
+		{key: "plain", record: coreusage.Record{Provider: "p", Model: "m", RequestedAt: time.Now().UTC()}},
+		{key: "override", meta: map[string]string{"requested_model": "asked"}, record: coreusage.Record{Provider: "p", Model: "m", RequestedAt: time.Now().UTC()}},
+	}
+	for _, entry := range entries {
+		entry.createdAt = time.Now().UTC()
+		if errPersist := plugin.persist(entry); errPersist != nil {
+			t.Fatalf("persist %s: %v", entry.key, errPersist)
+		}
+	}
+
+	var rows []models.Usage
+	if errFind := conn.Order("id ASC").Find(&rows).Error; errFind != nil || len(rows) != 2 {
+		t.Fatalf("load usage: %d rows, %v", len(rows), errFind)
+	}
+	if rows[0].Model != "m" || rows[0].RequestedModel != "m" {
+		t.Fatalf("expected requested model to default to the effective model, got %+v", rows[0])
+	}
+	if rows[1].Model != "m" || rows[1].RequestedModel != "asked" {
+		t.Fatalf("expected requested model from metadata, got %+v", rows[1])
+	}
+}
@@ -0,0 +1,228 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rollupInsertBatchSize bounds the rows inserted per statement when writing rollups.
+const rollupInsertBatchSize = 200
+
+// usageRollupSelect aggregates raw usage columns into UsageDailyRollup fields.
+const usageRollupSelect = `
+	COALESCE(user_id, 0) AS user_id,
+	provider,
+	model,
+	COALESCE(source, '') AS source,
+	COUNT(*) AS request_count,
+	COALESCE(SUM(CASE WHEN failed THEN 1 ELSE 0 END), 0) AS error_count,
+	COALESCE(SUM(input_tokens), 0) AS input_tokens,
+	COALESCE(SUM(output_tokens), 0) AS output_tokens,
+	COALESCE(SUM(reasoning_tokens), 0) AS reasoning_tokens,
+	COALESCE(SUM(cached_tokens), 0) AS cached_tokens,
+	COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(cost_micros), 0) AS cost_micros,
+	COALESCE(SUM(CASE WHEN failed THEN cost_micros ELSE 0 END), 0) AS failed_cost
+`
+
+// startOfUTCDay truncates t to midnight UTC.
+func startOfUTCDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// RollupUsageDay replaces the rollup rows for the UTC day containing day with
+// aggregates computed from the raw usages table and marks the day as rolled up. It must
+// only run while the day's raw rows are intact; use MergeLateUsage once a day is marked.
+func RollupUsageDay(ctx context.Context, db *gorm.DB, day time.Time) error {
+	if db == nil {
+		return errors.New("usage rollup: nil db")
+	}
+	start := startOfUTCDay(day)
+	end := start.AddDate(0, 0, 1)
+
+	maxID, errMax := maxUsageID(ctx, db)
+	if errMax != nil {
+		return errMax
+	}
+	rows, errAggregate := aggregateUsage(ctx, db.Where("requested_at >= ? AND requested_at < ? AND id <= ?", start, end, maxID), start)
+	if errAggregate != nil {
+		return errAggregate
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if errDelete := tx.Where("day = ?", start).Delete(&models.UsageDailyRollup{}).Error; errDelete != nil {
+			return errDelete
+		}
+		if len(rows) > 0 {
+			if errCreate := tx.CreateInBatches(&rows, rollupInsertBatchSize).Error; errCreate != nil {
+				return errCreate
+			}
+		}
+		return markRollupDay(tx, start, maxID)
+	})
+}
+
+// MergeLateUsage adds usage rows that arrived after the UTC day containing day was rolled
+// up to its rollup rows. Only rows with IDs above the day's mark are read, so it stays
+// correct after retention has deleted the day's older raw rows. It reports whether any
+// rows were merged; a day that was never rolled up is left alone.
+func MergeLateUsage(ctx context.Context, db *gorm.DB, day time.Time) (bool, error) {
+	if db == nil {
+		return false, errors.New("usage rollup: nil db")
+	}
+	start := startOfUTCDay(day)
+	end := start.AddDate(0, 0, 1)
+
+	mark, found, errMark := findRollupDay(ctx, db, start)
+	if errMark != nil || !found {
+		return false, errMark
+	}
+	maxID, errMax := maxUsageID(ctx, db)
+	if errMax != nil {
+		return false, errMax
+	}
+	if maxID <= mark.MaxUsageID {
+		return false, nil
+	}
+	rows, errAggregate := aggregateUsage(ctx, db.Where("requested_at >= ? AND requested_at < ? AND id > ? AND id <= ?", start, end, mark.MaxUsageID, maxID), start)
+	if errAggregate != nil {
+		return false, errAggregate
+	}
+
+	errTx := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range rows {
+			row := &rows[i]
+			res := tx.Model(&models.UsageDailyRollup{}).
+				Where("day = ? AND user_id = ? AND provider = ? AND model = ? AND source = ?", start, row.UserID, row.Provider, row.Model, row.Source).
+				Updates(map[string]any{
+					"request_count":    gorm.Expr("request_count + ?", row.RequestCount),
+					"error_count":      gorm.Expr("error_count + ?", row.ErrorCount),
+					"input_tokens":     gorm.Expr("input_tokens + ?", row.InputTokens),
+					"output_tokens":    gorm.Expr("output_tokens + ?", row.OutputTokens),
+					"reasoning_tokens": gorm.Expr("reasoning_tokens + ?", row.ReasoningTokens),
+					"cached_tokens":    gorm.Expr("cached_tokens + ?", row.CachedTokens),
+					"total_tokens":     gorm.Expr("total_tokens + ?", row.TotalTokens),
+					"cost_micros":      gorm.Expr("cost_micros + ?", row.CostMicros),
+					"failed_cost":      gorm.Expr("failed_cost + ?", row.FailedCost),
+					"updated_at":       row.UpdatedAt,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				if errCreate := tx.Create(row).Error; errCreate != nil {
+					return errCreate
+				}
+			}
+		}
+		return markRollupDay(tx, start, maxID)
+	})
+	if errTx != nil {
+		return false, errTx
+	}
+	return len(rows) > 0, nil
+}
+
+// AnonymizeUserRollups folds a user's rollup rows into the anonymous (user 0) rows for the
+// same day, provider, model and source, so totals survive deleting the user. It returns
+// the number of rollup rows folded.
+func AnonymizeUserRollups(tx *gorm.DB, userID uint64) (int64, error) {
+	if tx == nil {
+		return 0, errors.New("usage rollup: nil db")
+	}
+	if userID == 0 {
+		return 0, nil
+	}
+	var rows []models.UsageDailyRollup
+	if errFind := tx.Where("user_id = ?", userID).Order("id ASC").Find(&rows).Error; errFind != nil {
+		return 0, errFind
+	}
+	now := time.Now().UTC()
+	for i := range rows {
+		row := &rows[i]
+		res := tx.Model(&models.UsageDailyRollup{}).
+			Where("day = ? AND user_id = 0 AND provider = ? AND model = ? AND source = ?", row.Day, row.Provider, row.Model, row.Source).
+			Updates(map[string]any{
+				"request_count":    gorm.Expr("request_count + ?", row.RequestCount),
+				"error_count":      gorm.Expr("error_count + ?", row.ErrorCount),
+				"input_tokens":     gorm.Expr("input_tokens + ?", row.InputTokens),
+				"output_tokens":    gorm.Expr("output_tokens + ?", row.OutputTokens),
+				"reasoning_tokens": gorm.Expr("reasoning_tokens + ?", row.ReasoningTokens),
+				"cached_tokens":    gorm.Expr("cached_tokens + ?", row.CachedTokens),
+				"total_tokens":     gorm.Expr("total_tokens + ?", row.TotalTokens),
+				"cost_micros":      gorm.Expr("cost_micros + ?", row.CostMicros),
+				"failed_cost":      gorm.Expr("failed_cost + ?", row.FailedCost),
+				"updated_at":       now,
+			})
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		if res.RowsAffected > 0 {
+			if errDelete := tx.Delete(&models.UsageDailyRollup{}, row.ID).Error; errDelete != nil {
+				return 0, errDelete
+			}
+			continue
+		}
+		if errMove := tx.Model(&models.UsageDailyRollup{}).Where("id = ?", row.ID).
+			Updates(map[string]any{"user_id": 0, "updated_at": now}).Error; errMove != nil {
+			return 0, errMove
+		}
+	}
+	return int64(len(rows)), nil
+}
+
+// aggregateUsage groups the usage rows selected by scope into rollup rows for day.
+func aggregateUsage(ctx context.Context, scope *gorm.DB, day time.Time) ([]models.UsageDailyRollup, error) {
+	var rows []models.UsageDailyRollup
+	if errScan := scope.WithContext(ctx).
+		Model(&models.Usage{}).
+		Select(usageRollupSelect).
+		Group("COALESCE(user_id, 0), provider, model, COALESCE(source, '')").
+		Scan(&rows).Error; errScan != nil {
+		return nil, errScan
+	}
+	now := time.Now().UTC()
+	for i := range rows {
+		rows[i].ID = 0
+		rows[i].Day = day
+		rows[i].CreatedAt = now
+		rows[i].UpdatedAt = now
+	}
+	return rows, nil
+}
+
+// maxUsageID returns the highest usage ID, or 0 when there are no usages.
+func maxUsageID(ctx context.Context, db *gorm.DB) (uint64, error) {
+	var maxID uint64
+	if errMax := db.WithContext(ctx).
+		Model(&models.Usage{}).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&maxID).Error; errMax != nil {
+		return 0, errMax
+	}
+	return maxID, nil
+}
+
+// markRollupDay records that day is rolled up through usage ID maxID.
+func markRollupDay(tx *gorm.DB, day time.Time, maxID uint64) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_usage_id", "rolled_up_at"}),
+	}).Create(&models.UsageRollupDay{Day: day, MaxUsageID: maxID, RolledUpAt: time.Now().UTC()}).Error
+}
+
+// findRollupDay loads the rollup mark for the UTC day starting at day.
+func findRollupDay(ctx context.Context, db *gorm.DB, day time.Time) (models.UsageRollupDay, bool, error) {
+	var mark models.UsageRollupDay
+	res := db.WithContext(ctx).Where("day = ?", day).Limit(1).Find(&mark)
+	if res.Error != nil {
+		return mark, false, res.Error
+	}
+	return mark, res.RowsAffected > 0, nil
+}
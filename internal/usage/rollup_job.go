@@ -0,0 +1,191 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// defaultRollupInterval is how often the rollup job looks for finished days and late rows.
+const defaultRollupInterval = 10 * time.Minute
+
+// RollupResult summarizes one rollup pass.
+type RollupResult struct {
+	RolledUpDays int // Finished days aggregated for the first time.
+	MergedDays   int // Rolled-up days that received late-arriving rows.
+}
+
+// RollupJob keeps usage_daily_rollups current. Each pass rolls up UTC days that have
+// finished since the last pass (the nightly rollup) and merges usage rows that arrived
+// for already rolled-up days (the incremental update).
+type RollupJob struct {
+	db       *gorm.DB
+	interval time.Duration
+	now      func() time.Time
+
+	lastUsageID uint64 // Highest usage ID examined for late rows.
+	primed      bool   // Whether lastUsageID has been loaded.
+}
+
+// NewRollupJob constructs a usage rollup job.
+func NewRollupJob(db *gorm.DB) *RollupJob {
+	if db == nil {
+		return nil
+	}
+	return &RollupJob{
+		db:       db,
+		interval: defaultRollupInterval,
+		now:      time.Now,
+	}
+}
+
+// Start runs the rollup loop in the background.
+func (j *RollupJob) Start(ctx context.Context) {
+	if j == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go j.run(ctx)
+	log.Infof("usage rollup job started (interval=%s)", j.interval)
+}
+
+// run executes rollup passes until ctx is canceled, starting with an immediate catch-up.
+func (j *RollupJob) run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		result, errRun := j.RunOnce(ctx)
+		if errRun != nil {
+			if !errors.Is(errRun, context.Canceled) {
+				log.WithError(errRun).Warn("usage rollup: pass failed")
+			}
+		} else if result.RolledUpDays > 0 || result.MergedDays > 0 {
+			log.Infof("usage rollup: rolled up %d day(s), merged late rows into %d day(s)", result.RolledUpDays, result.MergedDays)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce rolls up finished UTC days that have no rollup yet, then merges usage rows
+// recorded since the previous pass into the days they belong to. The current UTC day is
+// never rolled up; dashboards read it from raw usages.
+func (j *RollupJob) RunOnce(ctx context.Context) (RollupResult, error) {
+	var result RollupResult
+	if j == nil || j.db == nil {
+		return result, errors.New("usage rollup: nil db")
+	}
+	clock := j.now
+	if clock == nil {
+		clock = time.Now
+	}
+	today := startOfUTCDay(clock())
+
+	if !j.primed {
+		var lastID uint64
+		if errMax := j.db.WithContext(ctx).
+			Model(&models.UsageRollupDay{}).
+			Select("COALESCE(MAX(max_usage_id), 0)").
+			Scan(&lastID).Error; errMax != nil {
+			return result, errMax
+		}
+		j.lastUsageID = lastID
+		j.primed = true
+	}
+	maxID, errMax := maxUsageID(ctx, j.db)
+	if errMax != nil {
+		return result, errMax
+	}
+
+	// Finished days after the newest rolled-up day.
+	var latest models.UsageRollupDay
+	resLatest := j.db.WithContext(ctx).Order("day DESC").Limit(1).Find(&latest)
+	if resLatest.Error != nil {
+		return result, resLatest.Error
+	}
+	cursor := time.Time{}
+	if resLatest.RowsAffected > 0 {
+		cursor = startOfUTCDay(latest.Day).AddDate(0, 0, 1)
+	}
+	for {
+		day, found, errNext := j.nextUsageDay(ctx, cursor, today, 0, maxID)
+		if errNext != nil {
+			return result, errNext
+		}
+		if !found {
+			break
+		}
+		if errRollup := RollupUsageDay(ctx, j.db, day); errRollup != nil {
+			return result, errRollup
+		}
+		result.RolledUpDays++
+		cursor = day.AddDate(0, 0, 1)
+	}
+
+	// Late rows: usages recorded since the last pass for days before today.
+	cursor = time.Time{}
+	for j.lastUsageID < maxID {
+		day, found, errNext := j.nextUsageDay(ctx, cursor, today, j.lastUsageID, maxID)
+		if errNext != nil {
+			return result, errNext
+		}
+		if !found {
+			break
+		}
+		_, rolled, errFind := findRollupDay(ctx, j.db, day)
+		if errFind != nil {
+			return result, errFind
+		}
+		if rolled {
+			merged, errMerge := MergeLateUsage(ctx, j.db, day)
+			if errMerge != nil {
+				return result, errMerge
+			}
+			if merged {
+				result.MergedDays++
+			}
+		} else {
+			if errRollup := RollupUsageDay(ctx, j.db, day); errRollup != nil {
+				return result, errRollup
+			}
+			result.RolledUpDays++
+		}
+		cursor = day.AddDate(0, 0, 1)
+	}
+	j.lastUsageID = maxID
+	return result, nil
+}
+
+// nextUsageDay returns the earliest UTC day in [from, before) holding a usage row with an
+// ID in (afterID, maxID].
+func (j *RollupJob) nextUsageDay(ctx context.Context, from, before time.Time, afterID, maxID uint64) (time.Time, bool, error) {
+	if errCtx := ctx.Err(); errCtx != nil {
+		return time.Time{}, false, errCtx
+	}
+	var next models.Usage
+	res := j.db.WithContext(ctx).
+		Select("requested_at").
+		Where("requested_at >= ? AND requested_at < ?", from, before).
+		Where("id > ? AND id <= ?", afterID, maxID).
+		Order("requested_at ASC").
+		Limit(1).
+		Find(&next)
+	if res.Error != nil {
+		return time.Time{}, false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return time.Time{}, false, nil
+	}
+	return startOfUTCDay(next.RequestedAt), true, nil
+}
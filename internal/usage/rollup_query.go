@@ -0,0 +1,411 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"gorm.io/gorm"
+)
+
+// Span is a half-open time range [Start, End).
+type Span struct {
+	Start time.Time // Inclusive start.
+	End   time.Time // Exclusive end.
+}
+
+// ModelCost is the aggregated cost for one model.
+type ModelCost struct {
+	Model            string // Model identifier.
+	CostMicros       int64  // Aggregated cost in micros.
+	FailedCostMicros int64  // Part of CostMicros charged for failed requests.
+}
+
+// SplitRollupRange splits [start, end) into UTC days that can be read from rollups and
+// the remaining spans that must be read from raw usages. Rollups are only used for whole
+// UTC days before the current day that are marked as rolled up, so partial days at the
+// edges of a range in a non-UTC timezone, today, and days the rollup job has not reached
+// yet all come from raw usages.
+func SplitRollupRange(ctx context.Context, db *gorm.DB, start, end, now time.Time) ([]Span, []Span, error) {
+	if db == nil {
+		return nil, nil, errors.New("usage rollup: nil db")
+	}
+	if !start.Before(end) {
+		return nil, nil, nil
+	}
+	firstDay := startOfUTCDay(start)
+	if firstDay.Before(start) {
+		firstDay = firstDay.AddDate(0, 0, 1)
+	}
+	lastDay := startOfUTCDay(end)
+	if today := startOfUTCDay(now); today.Before(lastDay) {
+		lastDay = today
+	}
+	if !firstDay.Before(lastDay) {
+		return nil, []Span{{Start: start, End: end}}, nil
+	}
+
+	var marked []time.Time
+	if errFind := db.WithContext(ctx).
+		Model(&models.UsageRollupDay{}).
+		Where("day >= ? AND day < ?", firstDay, lastDay).
+		Pluck("day", &marked).Error; errFind != nil {
+		return nil, nil, errFind
+	}
+	markedSet := make(map[int64]struct{}, len(marked))
+	for _, day := range marked {
+		markedSet[startOfUTCDay(day).Unix()] = struct{}{}
+	}
+
+	var rollupSpans, rawSpans []Span
+	appendSpan := func(spans []Span, from, to time.Time) []Span {
+		if !from.Before(to) {
+			return spans
+		}
+		if n := len(spans); n > 0 && spans[n-1].End.Equal(from) {
+			spans[n-1].End = to
+			return spans
+		}
+		return append(spans, Span{Start: from, End: to})
+	}
+	rawSpans = appendSpan(rawSpans, start, firstDay)
+	for day := firstDay; day.Before(lastDay); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		if _, ok := markedSet[day.Unix()]; ok {
+			rollupSpans = appendSpan(rollupSpans, day, next)
+		} else {
+			rawSpans = appendSpan(rawSpans, day, next)
+		}
+	}
+	rawSpans = appendSpan(rawSpans, lastDay, end)
+	return rollupSpans, rawSpans, nil
+}
+
+// spanScope restricts query to rows whose column falls in any of spans.
+func spanScope(query *gorm.DB, column string, spans []Span) *gorm.DB {
+	cond := query.Session(&gorm.Session{NewDB: true})
+	for i, span := range spans {
+		if i == 0 {
+			cond = cond.Where(column+" >= ? AND "+column+" < ?", span.Start, span.End)
+		} else {
+			cond = cond.Or(column+" >= ? AND "+column+" < ?", span.Start, span.End)
+		}
+	}
+	return query.Where(cond)
+}
+
+// SumCost returns the total usage cost in [start, end), reading rollups where possible.
+func SumCost(ctx context.Context, db *gorm.DB, start, end, now time.Time) (int64, error) {
+	rollupSpans, rawSpans, errSplit := SplitRollupRange(ctx, db, start, end, now)
+	if errSplit != nil {
+		return 0, errSplit
+	}
+	var total int64
+	if len(rollupSpans) > 0 {
+		var cost int64
+		if errSum := spanScope(db.WithContext(ctx).Model(&models.UsageDailyRollup{}), "day", rollupSpans).
+			Select("COALESCE(SUM(cost_micros), 0)").
+			Scan(&cost).Error; errSum != nil {
+			return 0, errSum
+		}
+		total += cost
+	}
+	if len(rawSpans) > 0 {
+		var cost int64
+		if errSum := spanScope(db.WithContext(ctx).Model(&models.Usage{}), "requested_at", rawSpans).
+			Select("COALESCE(SUM(cost_micros), 0)").
+			Scan(&cost).Error; errSum != nil {
+			return 0, errSum
+		}
+		total += cost
+	}
+	return total, nil
+}
+
+// CostByModel returns usage cost per model in [start, end), highest first, reading
+// rollups where possible.
+func CostByModel(ctx context.Context, db *gorm.DB, start, end, now time.Time) ([]ModelCost, error) {
+	rollupSpans, rawSpans, errSplit := SplitRollupRange(ctx, db, start, end, now)
+	if errSplit != nil {
+		return nil, errSplit
+	}
+	totals := make(map[string]*ModelCost)
+	collect := func(query *gorm.DB, failedExpr string) error {
+		var rows []ModelCost
+		if errScan := query.
+			Select("model, COALESCE(SUM(cost_micros), 0) AS cost_micros, COALESCE(SUM(" + failedExpr + "), 0) AS failed_cost_micros").
+			Group("model").
+			Scan(&rows).Error; errScan != nil {
+			return errScan
+		}
+		for _, row := range rows {
+			total, ok := totals[row.Model]
+			if !ok {
+				total = &ModelCost{Model: row.Model}
+				totals[row.Model] = total
+			}
+			total.CostMicros += row.CostMicros
+			total.FailedCostMicros += row.FailedCostMicros
+		}
+		return nil
+	}
+	if len(rollupSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.UsageDailyRollup{}), "day", rollupSpans), "failed_cost"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+	if len(rawSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.Usage{}), "requested_at", rawSpans), "CASE WHEN failed THEN cost_micros ELSE 0 END"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+
+	out := make([]ModelCost, 0, len(totals))
+	for _, total := range totals {
+		out = append(out, *total)
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].CostMicros != out[k].CostMicros {
+			return out[i].CostMicros > out[k].CostMicros
+		}
+		return out[i].Model < out[k].Model
+	})
+	return out, nil
+}
+
+// ModelUsage is the aggregated usage for one provider and model.
+type ModelUsage struct {
+	Provider     string `json:"provider"`      // Provider name.
+	Model        string `json:"model"`         // Model identifier.
+	RequestCount int64  `json:"request_count"` // Request count.
+	ErrorCount   int64  `json:"error_count"`   // Failed request count.
+	InputTokens  int64  `json:"input_tokens"`  // Input token total.
+	OutputTokens int64  `json:"output_tokens"` // Output token total.
+	TotalTokens  int64  `json:"total_tokens"`  // Total token count.
+	CostMicros   int64  `json:"cost_micros"`   // Cost in micros.
+}
+
+// UserUsageByModel returns a user's all-time usage per provider and model, reading
+// rollups for rolled-up days so totals survive raw usage retention.
+func UserUsageByModel(ctx context.Context, db *gorm.DB, userID uint64, now time.Time) ([]ModelUsage, error) {
+	if db == nil {
+		return nil, errors.New("usage rollup: nil db")
+	}
+	// Pluck the earliest rows rather than MIN() so drivers keep the column's time type.
+	var firsts []time.Time
+	if errRollup := db.WithContext(ctx).Model(&models.UsageDailyRollup{}).
+		Where("user_id = ?", userID).
+		Order("day ASC").Limit(1).
+		Pluck("day", &firsts).Error; errRollup != nil {
+		return nil, errRollup
+	}
+	var rawFirst []time.Time
+	if errRaw := db.WithContext(ctx).Model(&models.Usage{}).
+		Where("user_id = ?", userID).
+		Order("requested_at ASC").Limit(1).
+		Pluck("requested_at", &rawFirst).Error; errRaw != nil {
+		return nil, errRaw
+	}
+	var start time.Time
+	for _, first := range append(firsts, rawFirst...) {
+		if start.IsZero() || first.Before(start) {
+			start = first
+		}
+	}
+	if start.IsZero() {
+		return []ModelUsage{}, nil
+	}
+	rollupSpans, rawSpans, errSplit := SplitRollupRange(ctx, db, startOfUTCDay(start), startOfUTCDay(now).AddDate(0, 0, 1), now)
+	if errSplit != nil {
+		return nil, errSplit
+	}
+
+	totals := make(map[string]*ModelUsage)
+	collect := func(query *gorm.DB, requestExpr, errorExpr string) error {
+		var rows []ModelUsage
+		if errScan := query.
+			Where("user_id = ?", userID).
+			Select(`provider, model,
+				` + requestExpr + ` AS request_count,
+				COALESCE(SUM(` + errorExpr + `), 0) AS error_count,
+				COALESCE(SUM(input_tokens), 0) AS input_tokens,
+				COALESCE(SUM(output_tokens), 0) AS output_tokens,
+				COALESCE(SUM(total_tokens), 0) AS total_tokens,
+				COALESCE(SUM(cost_micros), 0) AS cost_micros`).
+			Group("provider, model").
+			Scan(&rows).Error; errScan != nil {
+			return errScan
+		}
+		for _, row := range rows {
+			key := row.Provider + "\x00" + row.Model
+			total, ok := totals[key]
+			if !ok {
+				total = &ModelUsage{Provider: row.Provider, Model: row.Model}
+				totals[key] = total
+			}
+			total.RequestCount += row.RequestCount
+			total.ErrorCount += row.ErrorCount
+			total.InputTokens += row.InputTokens
+			total.OutputTokens += row.OutputTokens
+			total.TotalTokens += row.TotalTokens
+			total.CostMicros += row.CostMicros
+		}
+		return nil
+	}
+	if len(rollupSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.UsageDailyRollup{}), "day", rollupSpans), "COALESCE(SUM(request_count), 0)", "error_count"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+	if len(rawSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.Usage{}), "requested_at", rawSpans), "COUNT(*)", "CASE WHEN failed THEN 1 ELSE 0 END"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+
+	out := make([]ModelUsage, 0, len(totals))
+	for _, total := range totals {
+		out = append(out, *total)
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].Provider != out[k].Provider {
+			return out[i].Provider < out[k].Provider
+		}
+		return out[i].Model < out[k].Model
+	})
+	return out, nil
+}
+
+// UserUsage is the aggregated usage for one user.
+type UserUsage struct {
+	UserID       uint64 // Related user ID; 0 for usage without a user.
+	RequestCount int64  // Request count.
+	ErrorCount   int64  // Failed request count.
+	TotalTokens  int64  // Total token count.
+	CostMicros   int64  // Cost in micros.
+}
+
+// UsageByUser returns usage per user in [start, end), reading rollups where possible.
+// Raw rows are filtered on requested_at and grouped by user_id, the leading columns of
+// idx_usages_requested_user_cost.
+func UsageByUser(ctx context.Context, db *gorm.DB, start, end, now time.Time) ([]UserUsage, error) {
+	rollupSpans, rawSpans, errSplit := SplitRollupRange(ctx, db, start, end, now)
+	if errSplit != nil {
+		return nil, errSplit
+	}
+	totals := make(map[uint64]*UserUsage)
+	collect := func(query *gorm.DB, userExpr, requestExpr, errorExpr string) error {
+		var rows []UserUsage
+		if errScan := query.
+			Select(userExpr + ` AS user_id,
+				` + requestExpr + ` AS request_count,
+				COALESCE(SUM(` + errorExpr + `), 0) AS error_count,
+				COALESCE(SUM(total_tokens), 0) AS total_tokens,
+				COALESCE(SUM(cost_micros), 0) AS cost_micros`).
+			Group(userExpr).
+			Scan(&rows).Error; errScan != nil {
+			return errScan
+		}
+		for _, row := range rows {
+			total, ok := totals[row.UserID]
+			if !ok {
+				total = &UserUsage{UserID: row.UserID}
+				totals[row.UserID] = total
+			}
+			total.RequestCount += row.RequestCount
+			total.ErrorCount += row.ErrorCount
+			total.TotalTokens += row.TotalTokens
+			total.CostMicros += row.CostMicros
+		}
+		return nil
+	}
+	if len(rollupSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.UsageDailyRollup{}), "day", rollupSpans), "user_id", "COALESCE(SUM(request_count), 0)", "error_count"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+	if len(rawSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.Usage{}), "requested_at", rawSpans), "COALESCE(user_id, 0)", "COUNT(*)", "CASE WHEN failed THEN 1 ELSE 0 END"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+
+	out := make([]UserUsage, 0, len(totals))
+	for _, total := range totals {
+		out = append(out, *total)
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].CostMicros != out[k].CostMicros {
+			return out[i].CostMicros > out[k].CostMicros
+		}
+		return out[i].UserID < out[k].UserID
+	})
+	return out, nil
+}
+
+// UsageByModel returns usage per provider and model in [start, end), highest cost first,
+// reading rollups where possible.
+func UsageByModel(ctx context.Context, db *gorm.DB, start, end, now time.Time) ([]ModelUsage, error) {
+	rollupSpans, rawSpans, errSplit := SplitRollupRange(ctx, db, start, end, now)
+	if errSplit != nil {
+		return nil, errSplit
+	}
+	totals := make(map[string]*ModelUsage)
+	collect := func(query *gorm.DB, requestExpr, errorExpr string) error {
+		var rows []ModelUsage
+		if errScan := query.
+			Select(`provider, model,
+				` + requestExpr + ` AS request_count,
+				COALESCE(SUM(` + errorExpr + `), 0) AS error_count,
+				COALESCE(SUM(input_tokens), 0) AS input_tokens,
+				COALESCE(SUM(output_tokens), 0) AS output_tokens,
+				COALESCE(SUM(total_tokens), 0) AS total_tokens,
+				COALESCE(SUM(cost_micros), 0) AS cost_micros`).
+			Group("provider, model").
+			Scan(&rows).Error; errScan != nil {
+			return errScan
+		}
+		for _, row := range rows {
+			key := row.Provider + "\x00" + row.Model
+			total, ok := totals[key]
+			if !ok {
+				total = &ModelUsage{Provider: row.Provider, Model: row.Model}
+				totals[key] = total
+			}
+			total.RequestCount += row.RequestCount
+			total.ErrorCount += row.ErrorCount
+			total.InputTokens += row.InputTokens
+			total.OutputTokens += row.OutputTokens
+			total.TotalTokens += row.TotalTokens
+			total.CostMicros += row.CostMicros
+		}
+		return nil
+	}
+	if len(rollupSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.UsageDailyRollup{}), "day", rollupSpans), "COALESCE(SUM(request_count), 0)", "error_count"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+	if len(rawSpans) > 0 {
+		if errCollect := collect(spanScope(db.WithContext(ctx).Model(&models.Usage{}), "requested_at", rawSpans), "COUNT(*)", "CASE WHEN failed THEN 1 ELSE 0 END"); errCollect != nil {
+			return nil, errCollect
+		}
+	}
+
+	out := make([]ModelUsage, 0, len(totals))
+	for _, total := range totals {
+		out = append(out, *total)
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].CostMicros != out[k].CostMicros {
+			return out[i].CostMicros > out[k].CostMicros
+		}
+		if out[i].Provider != out[k].Provider {
+			return out[i].Provider < out[k].Provider
+		}
+		return out[i].Model < out[k].Model
+	})
+	return out, nil
+}
@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestRollupJobMergesLateRowsAndServesRanges(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	rows := []models.Usage{
+		{Provider: "openai", Model: "gpt-4", RequestedAt: now.AddDate(0, 0, -3).Add(-2 * time.Hour), CostMicros: 100},
+		{Provider: "openai", Model: "gpt-4", RequestedAt: now.AddDate(0, 0, -2), CostMicros: 200},
+		{Provider: "claude", Model: "sonnet", RequestedAt: now.AddDate(0, 0, -1), CostMicros: 300, Failed: true},
+		{Provider: "claude", Model: "sonnet", RequestedAt: now.Add(-time.Hour), CostMicros: 400, Failed: true},
+	}
+	if errCreate := conn.Create(&rows).Error; errCreate != nil {
+		t.Fatalf("create usages: %v", errCreate)
+	}
+
+	job := NewRollupJob(conn)
+	job.now = func() time.Time { return now }
+	result, errRun := job.RunOnce(ctx)
+	if errRun != nil {
+		t.Fatalf("run rollup: %v", errRun)
+	}
+	if result.RolledUpDays != 3 || result.MergedDays != 0 {
+		t.Fatalf("unexpected first pass %+v", result)
+	}
+	var todayRollups int64
+	if errCount := conn.Model(&models.UsageDailyRollup{}).Where("day >= ?", startOfUTCDay(now)).Count(&todayRollups).Error; errCount != nil {
+		t.Fatalf("count rollups: %v", errCount)
+	}
+	if todayRollups != 0 {
+		t.Fatalf("expected the current day to stay raw, got %d rollup rows", todayRollups)
+	}
+
+	late := models.Usage{Provider: "openai", Model: "gpt-4", RequestedAt: now.AddDate(0, 0, -2).Add(time.Hour), CostMicros: 50}
+	if errCreate := conn.Create(&late).Error; errCreate != nil {
+		t.Fatalf("create late usage: %v", errCreate)
+	}
+	if result, errRun = job.RunOnce(ctx); errRun != nil || result.MergedDays != 1 || result.RolledUpDays != 0 {
+		t.Fatalf("expected late row merge, got %+v %v", result, errRun)
+	}
+	var merged models.UsageDailyRollup
+	if errFind := conn.Where("day = ? AND model = ?", startOfUTCDay(now.AddDate(0, 0, -2)), "gpt-4").First(&merged).Error; errFind != nil {
+		t.Fatalf("find merged rollup: %v", errFind)
+	}
+	if merged.RequestCount != 2 || merged.CostMicros != 250 {
+		t.Fatalf("unexpected merged rollup %+v", merged)
+	}
+
+	// A range in UTC+8 starts and ends mid-UTC-day, so its edges must come from raw usages.
+	loc := time.FixedZone("UTC+8", 8*3600)
+	start := time.Date(2026, 3, 17, 0, 0, 0, 0, loc)
+	end := time.Date(2026, 3, 21, 0, 0, 0, 0, loc)
+	rollupSpans, rawSpans, errSplit := SplitRollupRange(ctx, conn, start, end, now)
+	if errSplit != nil {
+		t.Fatalf("split range: %v", errSplit)
+	}
+	if len(rollupSpans) != 1 || !rollupSpans[0].Start.Equal(startOfUTCDay(now.AddDate(0, 0, -3))) || !rollupSpans[0].End.Equal(startOfUTCDay(now)) {
+		t.Fatalf("unexpected rollup spans %+v", rollupSpans)
+	}
+	if len(rawSpans) != 2 || !rawSpans[0].Start.Equal(start) || !rawSpans[1].End.Equal(end) {
+		t.Fatalf("unexpected raw spans %+v", rawSpans)
+	}
+
+	var rawTotal int64
+	if errSum := conn.Model(&models.Usage{}).
+		Where("requested_at >= ? AND requested_at < ?", start, end).
+		Select("COALESCE(SUM(cost_micros), 0)").
+		Scan(&rawTotal).Error; errSum != nil {
+		t.Fatalf("sum raw cost: %v", errSum)
+	}
+	total, errTotal := SumCost(ctx, conn, start, end, now)
+	if errTotal != nil || total != rawTotal {
+		t.Fatalf("expected rollup-backed total %d, got %d %v", rawTotal, total, errTotal)
+	}
+	byModel, errByModel := CostByModel(ctx, conn, start, end, now)
+	if errByModel != nil || len(byModel) != 2 {
+		t.Fatalf("unexpected cost by model %+v %v", byModel, errByModel)
+	}
+	if byModel[0].Model != "sonnet" || byModel[0].CostMicros != 700 || byModel[1].CostMicros != 350 {
+		t.Fatalf("unexpected cost by model %+v", byModel)
+	}
+	if byModel[0].FailedCostMicros != 700 || byModel[1].FailedCostMicros != 0 {
+		t.Fatalf("expected failed cost from rollups and raw rows, got %+v", byModel)
+	}
+
+	// Rolled-up days no longer depend on raw rows.
+	purgeStart := startOfUTCDay(now.AddDate(0, 0, -2))
+	if errDelete := conn.Where("requested_at >= ? AND requested_at < ?", purgeStart, purgeStart.AddDate(0, 0, 1)).Delete(&models.Usage{}).Error; errDelete != nil {
+		t.Fatalf("delete raw usages: %v", errDelete)
+	}
+	if total, errTotal = SumCost(ctx, conn, start, end, now); errTotal != nil || total != rawTotal {
+		t.Fatalf("expected total %d from rollups after purge, got %d %v", rawTotal, total, errTotal)
+	}
+}
@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestExceedsDailySpendCap(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	now := time.Now().UTC()
+	ctx := context.Background()
+
+	capped := models.User{Username: "capped", Email: "capped@example.com", Password: "x", DailySpendCap: 1, CreatedAt: now, UpdatedAt: now}
+	unlimited := models.User{Username: "unlimited", Email: "unlimited@example.com", Password: "x", CreatedAt: now, UpdatedAt: now}
+	for _, user := range []*models.User{&capped, &unlimited} {
+		if errCreate := conn.Create(user).Error; errCreate != nil {
+			t.Fatalf("create user: %v", errCreate)
+		}
+	}
+
+	for _, userID := range []uint64{capped.ID, unlimited.ID} {
+		uid := userID
+		row := models.Usage{UserID: &uid, Provider: "p", Model: "m", RequestedAt: now, CostMicros: 1_500_000, CreatedAt: now}
+		if errCreate := conn.Create(&row).Error; errCreate != nil {
+			t.Fatalf("create usage: %v", errCreate)
+		}
+	}
+
+	over, errCap := exceedsDailySpendCap(ctx, conn, capped.ID, 1.5)
+	if errCap != nil {
+		t.Fatalf("spend cap check: %v", errCap)
+	}
+	if !over {
+		t.Fatalf("expected capped user to exceed daily spend cap")
+	}
+
+	over, errCap = exceedsDailySpendCap(ctx, conn, unlimited.ID, 1.5)
+	if errCap != nil {
+		t.Fatalf("spend cap check: %v", errCap)
+	}
+	if over {
+		t.Fatalf("expected zero cap to be unlimited")
+	}
+}
@@ -0,0 +1,92 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestConcurrentUsagePersistOnSQLite(t *testing.T) {
+	conn := openDeductionTestDB(t, filepath.Join(t.TempDir(), "stress.db"))
+	ctx := context.Background()
+
+	authGroupID, errAuthGroup := billing.ResolveDefaultAuthGroupID(ctx, conn)
+	if errAuthGroup != nil || authGroupID == nil {
+		t.Fatalf("resolve default auth group: %v", errAuthGroup)
+	}
+	userGroupID, errUserGroup := billing.ResolveDefaultUserGroupID(ctx, conn)
+	if errUserGroup != nil || userGroupID == nil {
+		t.Fatalf("resolve default user group: %v", errUserGroup)
+	}
+	price := 0.01
+	rule := models.BillingRule{
+		AuthGroupID:     *authGroupID,
+		UserGroupID:     *userGroupID,
+		Provider:        "openai",
+		Model:           "gpt-4",
+		BillingType:     models.BillingTypePerRequest,
+		PricePerRequest: &price,
+		IsEnabled:       true,
+	}
+	if errCreate := conn.Create(&rule).Error; errCreate != nil {
+		t.Fatalf("create billing rule: %v", errCreate)
+	}
+	user := models.User{Username: "u", Email: "u@example.com", Password: "x"}
+	if errCreate := conn.Create(&user).Error; errCreate != nil {
+		t.Fatalf("create user: %v", errCreate)
+	}
+	card := createRedeemedCard(t, conn, user.ID, 10)
+
+	const workers = 100
+	plugin := NewGormUsagePlugin(conn)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errors []error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := &pendingUsage{
+				key:       fmt.Sprintf("stress-%d", i),
+				record:    coreusage.Record{Provider: "openai", Model: "gpt-4", RequestedAt: time.Now().UTC()},
+				meta:      map[string]string{"user_id": strconv.FormatUint(user.ID, 10)},
+				createdAt: time.Now().UTC(),
+			}
+			if errPersist := plugin.persist(entry); errPersist != nil {
+				mu.Lock()
+				errors = append(errors, errPersist)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(errors) > 0 {
+		t.Fatalf("expected every write to succeed, got %d errors, first: %v", len(errors), errors[0])
+	}
+
+	var count int64
+	if errCount := conn.Model(&models.Usage{}).Count(&count).Error; errCount != nil {
+		t.Fatalf("count usage: %v", errCount)
+	}
+	if count != workers {
+		t.Fatalf("expected %d usage rows, got %d", workers, count)
+	}
+	var reloaded models.PrepaidCard
+	if errFind := conn.First(&reloaded, card.ID).Error; errFind != nil {
+		t.Fatalf("reload card: %v", errFind)
+	}
+	if math.Abs(reloaded.Balance-9) > 1e-6 {
+		t.Fatalf("expected balance 9 after %d deductions, got %v", workers, reloaded.Balance)
+	}
+}
@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestStreamedUsageAppliesMultiplier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+
+	ctx := context.Background()
+	authGroupID, errAuthGroup := billing.ResolveDefaultAuthGroupID(ctx, conn)
+	if errAuthGroup != nil || authGroupID == nil {
+		t.Fatalf("resolve default auth group: %v", errAuthGroup)
+	}
+	userGroupID, errUserGroup := billing.ResolveDefaultUserGroupID(ctx, conn)
+	if errUserGroup != nil || userGroupID == nil {
+		t.Fatalf("resolve default user group: %v", errUserGroup)
+	}
+	price := 1.0
+	multiplier := 1.5
+	rule := models.BillingRule{
+		AuthGroupID:           *authGroupID,
+		UserGroupID:           *userGroupID,
+		Provider:              "openai",
+		Model:                 "gpt-4",
+		BillingType:           models.BillingTypePerRequest,
+		PricePerRequest:       &price,
+		StreamPriceMultiplier: &multiplier,
+		IsEnabled:             true,
+	}
+	if errCreate := conn.Create(&rule).Error; errCreate != nil {
+		t.Fatalf("create billing rule: %v", errCreate)
+	}
+
+	plugin := NewGormUsagePlugin(conn)
+	for _, stream := range []bool{false, true} {
+		recorder := httptest.NewRecorder()
+		ginCtx, _ := gin.CreateTestContext(recorder)
+		ginCtx.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		if stream {
+			ginCtx.Header("Content-Type", "text/event-stream")
+		}
+		plugin.HandleUsage(context.WithValue(ctx, "gin", ginCtx), coreusage.Record{
+			Provider:    "openai",
+			Model:       "gpt-4",
+			RequestedAt: time.Now().UTC(),
+		})
+	}
+
+	var rows []models.Usage
+	if errFind := conn.Order("id ASC").Find(&rows).Error; errFind != nil {
+		t.Fatalf("query usage: %v", errFind)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 usage rows, got %d", len(rows))
+	}
+	if rows[0].Stream || rows[0].CostMicros != 1_000_000 {
+		t.Fatalf("expected non-stream cost 1000000, got stream=%v cost=%d", rows[0].Stream, rows[0].CostMicros)
+	}
+	if !rows[1].Stream || rows[1].CostMicros != 1_500_000 {
+		t.Fatalf("expected stream cost 1500000, got stream=%v cost=%d", rows[1].Stream, rows[1].CostMicros)
+	}
+}
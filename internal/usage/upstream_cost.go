@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"math"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// UpstreamCostKey is the gin context key under which an executor or middleware may store
+// the provider-reported cost of the request, in USD as a float64.
+const UpstreamCostKey = "UPSTREAM_COST"
+
+// upstreamCostFromContext returns the provider-reported cost in micros, taken from
+// UpstreamCostKey or else from the usage.cost field of the captured response. It
+// returns nil when the provider reported no cost.
+func upstreamCostFromContext(ctx context.Context) *int64 {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return nil
+	}
+	if v, exists := ginCtx.Get(UpstreamCostKey); exists {
+		if cost, okCost := v.(float64); okCost {
+			return costMicrosOf(cost)
+		}
+	}
+	return upstreamCostFromResponse(extractAPIResponse(ginCtx))
+}
+
+// upstreamCostFromResponse reads usage.cost from a JSON response body, or from the last
+// server-sent event that carries it.
+func upstreamCostFromResponse(body []byte) *int64 {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if gjson.ValidBytes(body) {
+		return usageCostOf(gjson.GetBytes(body, "usage.cost"))
+	}
+	var found *int64
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(line), []byte("data:")))
+		if len(line) == 0 || line[0] != '{' || !gjson.ValidBytes(line) {
+			continue
+		}
+		if cost := usageCostOf(gjson.GetBytes(line, "usage.cost")); cost != nil {
+			found = cost
+		}
+	}
+	return found
+}
+
+// usageCostOf converts a numeric usage.cost value into micros.
+func usageCostOf(value gjson.Result) *int64 {
+	if value.Type != gjson.Number {
+		return nil
+	}
+	return costMicrosOf(value.Float())
+}
+
+// costMicrosOf converts a USD amount into micros, rejecting negative and non-finite values.
+func costMicrosOf(cost float64) *int64 {
+	if math.IsNaN(cost) || math.IsInf(cost, 0) || cost < 0 {
+		return nil
+	}
+	micros := int64(math.Round(cost * 1_000_000))
+	return &micros
+}
@@ -0,0 +1,96 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+)
+
+func TestUpstreamCostFromResponse(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want *int64
+	}{
+		{"json", `{"id":"x","usage":{"prompt_tokens":10,"cost":0.0125}}`, int64Ptr(12500)},
+		{"json without cost", `{"usage":{"prompt_tokens":10}}`, nil},
+		{"string cost", `{"usage":{"cost":"0.5"}}`, nil},
+		{"negative cost", `{"usage":{"cost":-1}}`, nil},
+		{"sse", "data: {\"choices\":[]}\n\ndata: {\"usage\":{\"cost\":0.002}}\n\ndata: [DONE]\n", int64Ptr(2000)},
+		{"empty", "", nil},
+	}
+	for _, tc := range cases {
+		got := upstreamCostFromResponse([]byte(tc.body))
+		switch {
+		case tc.want == nil && got != nil:
+			t.Fatalf("%s: expected no cost, got %d", tc.name, *got)
+		case tc.want != nil && (got == nil || *got != *tc.want):
+			t.Fatalf("%s: expected %d, got %v", tc.name, *tc.want, got)
+		}
+	}
+}
+
+func TestCalculateCostPrefersUpstreamCost(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate db: %v", errMigrate)
+	}
+	ctx := context.Background()
+	authGroupID, errAuthGroup := billing.ResolveDefaultAuthGroupID(ctx, conn)
+	if errAuthGroup != nil || authGroupID == nil {
+		t.Fatalf("resolve default auth group: %v", errAuthGroup)
+	}
+	userGroupID, errUserGroup := billing.ResolveDefaultUserGroupID(ctx, conn)
+	if errUserGroup != nil || userGroupID == nil {
+		t.Fatalf("resolve default user group: %v", errUserGroup)
+	}
+	price := 0.5
+	rule := models.BillingRule{
+		AuthGroupID:     *authGroupID,
+		UserGroupID:     *userGroupID,
+		Provider:        "openrouter",
+		Model:           "gpt-4",
+		BillingType:     models.BillingTypePerRequest,
+		PricePerRequest: &price,
+		BillFailed:      models.BillFailedFull,
+		IsEnabled:       true,
+	}
+	if errCreate := conn.Create(&rule).Error; errCreate != nil {
+		t.Fatalf("create billing rule: %v", errCreate)
+	}
+
+	record := coreusage.Record{Provider: "openrouter", Model: "gpt-4", RequestedAt: time.Now().UTC()}
+	upstream := int64Ptr(1234)
+
+	billed, computed := calculateCost(ctx, conn, nil, nil, nil, nil, record, false, upstream)
+	if billed != 500_000 || computed != 500_000 {
+		t.Fatalf("opt-out rule: billed=%d computed=%d", billed, computed)
+	}
+
+	if errUpdate := conn.Model(&rule).Update("prefer_upstream_cost", true).Error; errUpdate != nil {
+		t.Fatalf("enable upstream cost: %v", errUpdate)
+	}
+	billed, computed = calculateCost(ctx, conn, nil, nil, nil, nil, record, false, upstream)
+	if billed != 1234 || computed != 500_000 {
+		t.Fatalf("upstream cost: billed=%d computed=%d", billed, computed)
+	}
+	billed, _ = calculateCost(ctx, conn, nil, nil, nil, nil, record, false, nil)
+	if billed != 500_000 {
+		t.Fatalf("missing upstream cost should fall back to the rule, got %d", billed)
+	}
+	record.Failed = true
+	billed, _ = calculateCost(ctx, conn, nil, nil, nil, nil, record, false, upstream)
+	if billed != 500_000 {
+		t.Fatalf("failed record should follow bill_failed, got %d", billed)
+	}
+}
+
+func int64Ptr(v int64) *int64 { return &v }
@@ -9,12 +9,17 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/billing"
 	dbutil "github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/events"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/logging"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/quota"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 
 	"github.com/gin-gonic/gin"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
@@ -25,12 +30,46 @@ import (
 )
 
 // GormUsagePlugin persists usage records and applies billing deductions.
+// Writes that fail are buffered in memory and retried in the background.
 type GormUsagePlugin struct {
-	db *gorm.DB
+	db    *gorm.DB
+	retry *retryBuffer
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	authLookup   AuthLookup          // Resolves runtime auths; nil disables provider key tracking.
+	authFailures *authFailureTracker // Consecutive upstream auth failures per auth ID.
 }
 
 // NewGormUsagePlugin constructs a GormUsagePlugin backed by GORM.
-func NewGormUsagePlugin(db *gorm.DB) *GormUsagePlugin { return &GormUsagePlugin{db: db} }
+func NewGormUsagePlugin(db *gorm.DB) *GormUsagePlugin {
+	return &GormUsagePlugin{
+		db:    db,
+		retry: newRetryBuffer(defaultRetryBufferSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+
+		authFailures: newAuthFailureTracker(),
+	}
+}
+
+// pendingUsage captures everything needed to persist a usage record, so failed
+// writes can be replayed after the request context is gone.
+type pendingUsage struct {
+	key             string
+	record          coreusage.Record
+	meta            map[string]string
+	errorStatusCode *int
+	errorDetail     datatypes.JSON
+	stream          bool
+	upstreamCost    *int64
+	durationMs      *int64
+	createdAt       time.Time
+
+	attempts    int
+	nextAttempt time.Time
+}
 
 // HandleUsage records usage data and deducts bill or prepaid balances.
 func (p *GormUsagePlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
@@ -38,11 +77,78 @@ func (p *GormUsagePlugin) HandleUsage(ctx context.Context, record coreusage.Reco
 		return
 	}
 
-	meta := accessMetadataFromContext(ctx)
+	logger := logging.FromContext(ctx)
+	key, errKey := newIdempotencyKey()
+	if errKey != nil {
+		logger.WithError(errKey).Warn("usage plugin: generate idempotency key failed")
+	}
+	now := time.Now().UTC()
+	durationMs := requestDurationMs(record.RequestedAt, now)
+	record.RequestedAt = normalizeTime(record.RequestedAt)
+	errorStatusCode, errorDetail := buildUsageErrorDetail(ctx, record)
+	entry := &pendingUsage{
+		key:             key,
+		record:          record,
+		meta:            accessMetadataFromContext(ctx),
+		errorStatusCode: errorStatusCode,
+		errorDetail:     errorDetail,
+		stream:          isStreamingRequest(ctx),
+		upstreamCost:    upstreamCostFromContext(ctx),
+		durationMs:      durationMs,
+		createdAt:       now,
+	}
+
+	if errorStatusCode != nil && *errorStatusCode == http.StatusTooManyRequests {
+		p.recordQuotaExceeded(logger, record.AuthID)
+	}
+	switch {
+	case errorStatusCode != nil && (*errorStatusCode == http.StatusUnauthorized || *errorStatusCode == http.StatusForbidden):
+		p.recordAuthFailure(logger, record.AuthID)
+	case errorStatusCode == nil:
+		p.clearAuthFailures(logger, record.AuthID)
+	}
+
+	if errPersist := p.persist(entry); errPersist != nil {
+		if key == "" {
+			logger.WithError(errPersist).Warn("usage plugin: failed to persist usage or deduct balance")
+			return
+		}
+		logger.WithError(errPersist).Warn("usage plugin: failed to persist usage, buffering for retry")
+		p.retry.push(entry, time.Now())
+	}
+}
 
+// recordQuotaExceeded counts a 429 response against the auth's priority decay window,
+// logging through the request's logger.
+func (p *GormUsagePlugin) recordQuotaExceeded(logger *log.Entry, authKey string) {
 	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	authID := resolveAuthRecordID(dbCtx, p.db, authKey)
+	if authID == nil {
+		return
+	}
+	decayed, errRecord := quota.RecordQuotaExceeded(dbCtx, p.db, *authID, quota.LoadDecayConfig(), time.Now())
+	if errRecord != nil {
+		logger.WithError(errRecord).Warnf("usage plugin: record quota error failed (auth=%s)", authKey)
+		return
+	}
+	if decayed {
+		logger.Infof("usage plugin: lowered effective priority after repeated quota errors (auth=%s)", authKey)
+	}
+}
+
+// persist writes one usage row and applies its deduction in a single transaction. In
+// deferred billing mode the row is inserted on its own and marked unbilled for the
+// billing reconciler. A row whose idempotency key already exists is treated as done, so
+// replays never charge twice.
+func (p *GormUsagePlugin) persist(entry *pendingUsage) error {
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	meta := entry.meta
+	record := entry.record
+
 	var apiKeyID *uint64
 	if rawID := strings.TrimSpace(meta["api_key_id"]); rawID != "" {
 		parsed, errParseUint := strconv.ParseUint(rawID, 10, 64)
@@ -84,58 +190,191 @@ func (p *GormUsagePlugin) HandleUsage(ctx context.Context, record coreusage.Reco
 		model = mappedModel
 	}
 
+	requestedModel := model
+	if requested := strings.TrimSpace(meta["requested_model"]); requested != "" {
+		requestedModel = requested
+	}
+
 	recordForBilling := record
 	recordForBilling.Provider = provider
 	recordForBilling.Model = model
 
-	costMicros := calculateCost(dbCtx, p.db, apiKeyID, userID, authID, billingUserGroupID, recordForBilling)
+	costMicros, computedCostMicros := calculateCost(dbCtx, p.db, apiKeyID, userID, authID, billingUserGroupID, recordForBilling, entry.stream, entry.upstreamCost)
 	amountToDeduct := float64(costMicros) / 1_000_000
 
-	errorStatusCode, errorDetail := buildUsageErrorDetail(ctx, record)
+	source := strings.TrimSpace(record.Source)
+	if fallback := strings.TrimSpace(meta["fallback"]); fallback != "" {
+		source = strings.TrimSpace(source + " fallback:" + fallback)
+	}
+
+	var idempotencyKey *string
+	if entry.key != "" {
+		keyCopy := entry.key
+		idempotencyKey = &keyCopy
+	}
+
+	var requestID *string
+	if rawID := strings.TrimSpace(meta["request_id"]); rawID != "" {
+		requestID = &rawID
+	}
 
 	row := models.Usage{
-		Provider:        provider,
-		Model:           model,
-		UserID:          userID,
-		UserGroupID:     billingUserGroupID,
-		APIKeyID:        apiKeyID,
-		AuthID:          authID,
-		AuthKey:         authKey,
-		AuthIndex:       strings.TrimSpace(record.AuthIndex),
-		Source:          strings.TrimSpace(record.Source),
-		RequestedAt:     normalizeTime(record.RequestedAt),
-		Failed:          record.Failed,
-		ErrorStatusCode: errorStatusCode,
-		ErrorDetail:     errorDetail,
-		InputTokens:     record.Detail.InputTokens,
-		OutputTokens:    record.Detail.OutputTokens,
-		ReasoningTokens: record.Detail.ReasoningTokens,
-		CachedTokens:    record.Detail.CachedTokens,
-		TotalTokens:     totalTokens,
-		CostMicros:      costMicros,
-		CreatedAt:       time.Now().UTC(),
-	}
-
-	if errTx := p.db.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
-		if errCreate := tx.Create(&row).Error; errCreate != nil {
-			return errCreate
-		}
-
-		if amountToDeduct > 0 && row.UserID != nil {
-			deducted, errDeductBill := deductBillBalance(dbCtx, tx, *row.UserID, billingUserGroupID, amountToDeduct, costMicros)
-			if errDeductBill != nil {
-				return errDeductBill
+		IdempotencyKey:     idempotencyKey,
+		RequestID:          requestID,
+		Provider:           provider,
+		Model:              model,
+		RequestedModel:     requestedModel,
+		UserID:             userID,
+		UserGroupID:        billingUserGroupID,
+		APIKeyID:           apiKeyID,
+		AuthID:             authID,
+		AuthKey:            authKey,
+		AuthIndex:          strings.TrimSpace(record.AuthIndex),
+		Source:             source,
+		RequestedAt:        record.RequestedAt,
+		Failed:             record.Failed,
+		Stream:             entry.stream,
+		DurationMs:         entry.durationMs,
+		ErrorStatusCode:    entry.errorStatusCode,
+		ErrorDetail:        entry.errorDetail,
+		InputTokens:        record.Detail.InputTokens,
+		OutputTokens:       record.Detail.OutputTokens,
+		ReasoningTokens:    record.Detail.ReasoningTokens,
+		CachedTokens:       record.Detail.CachedTokens,
+		TotalTokens:        totalTokens,
+		CostMicros:         costMicros,
+		ComputedCostMicros: computedCostMicros,
+		UpstreamCostMicros: entry.upstreamCost,
+		CreatedAt:          entry.createdAt,
+	}
+
+	created := false
+	var errTx error
+	if deferredBilling() {
+		// The billing reconciler charges the row later, so the insert takes no bill or
+		// prepaid card locks.
+		row.Unbilled = amountToDeduct > 0 && row.UserID != nil
+		res := p.db.WithContext(dbCtx).Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		errTx, created = res.Error, res.RowsAffected > 0
+	} else {
+		errTx = p.db.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			if res.Error != nil {
+				return res.Error
 			}
-			if !deducted {
-				if errDeductPrepaid := deductPrepaidBalance(dbCtx, tx, *row.UserID, billingUserGroupID, amountToDeduct); errDeductPrepaid != nil {
-					return errDeductPrepaid
-				}
+			if res.RowsAffected == 0 {
+				// An earlier attempt committed this record and its deduction.
+				return nil
 			}
-		}
+			created = true
+			return chargeUsage(dbCtx, tx, row, billingUserGroupID, amountToDeduct, costMicros)
+		})
+	}
+	if errTx != nil {
+		return errTx
+	}
+	if created {
+		events.Publish(events.TypeUsage, events.UsageData{
+			ID:              row.ID,
+			RequestedAt:     row.RequestedAt,
+			Provider:        row.Provider,
+			Model:           row.Model,
+			UserID:          row.UserID,
+			APIKeyID:        row.APIKeyID,
+			AuthKey:         row.AuthKey,
+			Failed:          row.Failed,
+			ErrorStatusCode: row.ErrorStatusCode,
+			TotalTokens:     row.TotalTokens,
+			CostMicros:      row.CostMicros,
+			DurationMs:      row.DurationMs,
+		})
+	}
+	return nil
+}
+
+// chargeUsage applies the cost of a usage row recorded in sync billing mode: a row that
+// would push the user past their daily spend cap is flagged instead of charged, otherwise
+// the cost is deducted from active bills or, failing that, prepaid cards.
+func chargeUsage(ctx context.Context, tx *gorm.DB, row models.Usage, userGroupID *uint64, amount float64, costMicros int64) error {
+	if amount <= 0 || row.UserID == nil {
 		return nil
-	}); errTx != nil {
-		log.WithError(errTx).Warn("usage plugin: failed to persist usage or deduct balance")
 	}
+	overCap, errCap := exceedsDailySpendCap(ctx, tx, *row.UserID, amount)
+	if errCap != nil {
+		return errCap
+	}
+	if overCap {
+		return markUsageOverSpendCap(ctx, tx, row.ID)
+	}
+	return deductBalance(ctx, tx, *row.UserID, userGroupID, amount, costMicros)
+}
+
+// deductBalance deducts amount from the user's active bills, or from their prepaid cards
+// when the bills cannot cover it.
+func deductBalance(ctx context.Context, tx *gorm.DB, userID uint64, userGroupID *uint64, amount float64, costMicros int64) error {
+	deducted, errDeductBill := deductBillBalance(ctx, tx, userID, userGroupID, amount, costMicros)
+	if errDeductBill != nil {
+		return errDeductBill
+	}
+	if deducted {
+		return nil
+	}
+	return deductPrepaidBalance(ctx, tx, userID, userGroupID, amount)
+}
+
+// exceedsDailySpendCap reports whether charging amount would push the user past their daily spend cap.
+func exceedsDailySpendCap(ctx context.Context, tx *gorm.DB, userID uint64, amount float64) (bool, error) {
+	if tx == nil {
+		return false, errors.New("nil tx")
+	}
+	spendCap, errCap := loadDailySpendCap(ctx, tx, userID)
+	if errCap != nil || spendCap <= 0 {
+		return false, errCap
+	}
+	usedToday, errUsage := loadTodayUsageAmount(ctx, tx, userID, nil, time.Now().UTC())
+	if errUsage != nil {
+		return false, errUsage
+	}
+	// usedToday already includes the usage row created in this transaction.
+	return usedToday > spendCap+billQuotaEpsilon, nil
+}
+
+// loadDailySpendCap returns the user's daily spend cap; 0 means no cap or no such user.
+func loadDailySpendCap(ctx context.Context, tx *gorm.DB, userID uint64) (float64, error) {
+	var user models.User
+	if errFind := tx.WithContext(ctx).
+		Select("id", "daily_spend_cap").
+		Where("id = ?", userID).
+		Take(&user).Error; errFind != nil {
+		if errors.Is(errFind, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, errFind
+	}
+	return user.DailySpendCap, nil
+}
+
+// markUsageOverSpendCap flags persisted usage rows as failed because the daily spend cap was
+// reached. Their cost is zeroed since it was never charged, which keeps them out of daily
+// quota, spend cap and dashboard cost sums.
+func markUsageOverSpendCap(ctx context.Context, tx *gorm.DB, usageIDs ...uint64) error {
+	statusCode := http.StatusTooManyRequests
+	detail, errMarshal := json.Marshal(usageErrorDetail{
+		StatusCode: statusCode,
+		Message:    "daily spend cap exceeded",
+	})
+	if errMarshal != nil {
+		return errMarshal
+	}
+	return tx.WithContext(ctx).
+		Model(&models.Usage{}).
+		Where("id IN ?", usageIDs).
+		Updates(map[string]any{
+			"failed":            true,
+			"error_status_code": statusCode,
+			"error_detail":      datatypes.JSON(detail),
+			"cost_micros":       0,
+		}).Error
 }
 
 // resolveAuthRecordID looks up the auth record ID by key.
@@ -169,8 +408,23 @@ func resolveAuthRecordID(ctx context.Context, db *gorm.DB, authKey string) *uint
 // billQuotaEpsilon defines a tolerance for quota comparisons.
 const billQuotaEpsilon = 0.000001
 
-// deductBillBalance deducts usage from active bills and updates quotas.
+// deductBillBalance deducts usage from active bills and updates quotas, retrying on
+// SQLite busy errors.
 func deductBillBalance(ctx context.Context, tx *gorm.DB, userID uint64, userGroupID *uint64, amount float64, costMicros int64) (bool, error) {
+	if tx == nil {
+		return false, errors.New("nil tx")
+	}
+	var deducted bool
+	errDeduct := dbutil.RetryOnBusy(ctx, tx, func() error {
+		var errOnce error
+		deducted, errOnce = deductBillBalanceOnce(ctx, tx, userID, userGroupID, amount, costMicros)
+		return errOnce
+	})
+	return deducted, errDeduct
+}
+
+// deductBillBalanceOnce performs a single bill deduction attempt.
+func deductBillBalanceOnce(ctx context.Context, tx *gorm.DB, userID uint64, userGroupID *uint64, amount float64, costMicros int64) (bool, error) {
 	if tx == nil {
 		return false, errors.New("nil tx")
 	}
@@ -238,23 +492,11 @@ func deductBillBalance(ctx context.Context, tx *gorm.DB, userID uint64, userGrou
 		if bill.LeftQuota <= 0 {
 			continue
 		}
-		deduct := bill.LeftQuota
-		if deduct > remaining {
-			deduct = remaining
+		deducted, errTake := takeBillQuota(ctx, tx, bill.ID, bill.LeftQuota, remaining, now)
+		if errTake != nil {
+			return false, errTake
 		}
-		res := tx.WithContext(ctx).
-			Model(&models.Bill{}).
-			Where("id = ?", bill.ID).
-			Updates(map[string]any{
-				"used_quota": gorm.Expr("used_quota + ?", deduct),
-				"left_quota": gorm.Expr("left_quota - ?", deduct),
-				"used_count": gorm.Expr("used_count + ?", 1),
-				"updated_at": now,
-			})
-		if res.Error != nil {
-			return false, res.Error
-		}
-		remaining -= deduct
+		remaining -= deducted
 	}
 	if remaining > billQuotaEpsilon {
 		return false, errors.New("bill quota not enough after lock")
@@ -305,8 +547,20 @@ func refreshBillUserGroupIDs(ctx context.Context, tx *gorm.DB, userID uint64) er
 		Update("bill_user_group_id", merged.Clean()).Error
 }
 
-// deductPrepaidBalance deducts usage from prepaid cards in priority order.
+// deductPrepaidBalance deducts usage from prepaid cards in PREPAID_DEDUCTION_ORDER, retrying on
+// SQLite busy errors. Cost the cards cannot cover accrues as debt under the user's
+// overage policy.
 func deductPrepaidBalance(ctx context.Context, tx *gorm.DB, userID uint64, userGroupID *uint64, amount float64) error {
+	if tx == nil {
+		return errors.New("nil tx")
+	}
+	return dbutil.RetryOnBusy(ctx, tx, func() error {
+		return deductPrepaidBalanceOnce(ctx, tx, userID, userGroupID, amount)
+	})
+}
+
+// deductPrepaidBalanceOnce performs a single prepaid deduction attempt.
+func deductPrepaidBalanceOnce(ctx context.Context, tx *gorm.DB, userID uint64, userGroupID *uint64, amount float64) error {
 	if tx == nil {
 		return errors.New("nil tx")
 	}
@@ -319,7 +573,7 @@ func deductPrepaidBalance(ctx context.Context, tx *gorm.DB, userID uint64, userG
 		Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where("redeemed_user_id = ? AND is_enabled = ? AND balance > 0 AND redeemed_at IS NOT NULL", userID, true).
 		Where("(expires_at IS NULL OR expires_at >= ?)", now).
-		Order("expires_at ASC NULLS LAST, redeemed_at ASC NULLS LAST, id ASC").
+		Order(billing.PrepaidDeductionOrder()).
 		Model(&models.PrepaidCard{})
 	if userGroupID != nil && *userGroupID != 0 {
 		q = q.Where("user_group_id = ?", *userGroupID)
@@ -336,35 +590,115 @@ func deductPrepaidBalance(ctx context.Context, tx *gorm.DB, userID uint64, userG
 		if card.Balance <= 0 {
 			continue
 		}
-		deduct := card.Balance
-		if deduct > remaining {
-			deduct = remaining
+		deducted, errTake := takePrepaidBalance(ctx, tx, card.ID, card.Balance, remaining)
+		if errTake != nil {
+			return errTake
+		}
+		remaining -= deducted
+	}
+	if remaining > billQuotaEpsilon {
+		return accrueOverageDebt(ctx, tx, userID, remaining)
+	}
+	return nil
+}
+
+// accrueOverageDebt records usage cost that neither bills nor prepaid cards covered as
+// debt on the user, when their overage policy accrues debt.
+func accrueOverageDebt(ctx context.Context, tx *gorm.DB, userID uint64, amount float64) error {
+	policy, _, errPolicy := billing.LoadUserOverage(ctx, tx, userID)
+	if errPolicy != nil {
+		return errPolicy
+	}
+	if !policy.AccruesDebt() {
+		return nil
+	}
+	return tx.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("overage_debt", gorm.Expr("overage_debt + ?", amount)).Error
+}
+
+// guardedDeductAttempts bounds how often a guarded deduction reloads a row after losing a race.
+const guardedDeductAttempts = 3
+
+// takeBillQuota deducts up to want from a bill without letting left_quota go negative,
+// and returns the amount actually taken. SQLite has no row locks, so the guard in the
+// UPDATE is what keeps concurrent deductions consistent with Postgres.
+func takeBillQuota(ctx context.Context, tx *gorm.DB, billID uint64, left, want float64, now time.Time) (float64, error) {
+	for attempt := 0; attempt < guardedDeductAttempts; attempt++ {
+		if left <= 0 || want <= 0 {
+			return 0, nil
+		}
+		deduct := math.Min(left, want)
+		res := tx.WithContext(ctx).
+			Model(&models.Bill{}).
+			Where("id = ? AND left_quota >= ?", billID, deduct-billQuotaEpsilon).
+			Updates(map[string]any{
+				"used_quota": gorm.Expr("used_quota + ?", deduct),
+				"left_quota": gorm.Expr("left_quota - ?", deduct),
+				"used_count": gorm.Expr("used_count + ?", 1),
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		if res.RowsAffected > 0 {
+			return deduct, nil
+		}
+		// A concurrent deduction lowered left_quota; reload and retry with what is left.
+		var fresh models.Bill
+		if errFind := tx.WithContext(ctx).Select("left_quota").Where("id = ?", billID).Take(&fresh).Error; errFind != nil {
+			if errors.Is(errFind, gorm.ErrRecordNotFound) {
+				return 0, nil
+			}
+			return 0, errFind
 		}
+		left = fresh.LeftQuota
+	}
+	return 0, nil
+}
+
+// takePrepaidBalance deducts up to want from a prepaid card without letting its balance
+// go negative, and returns the amount actually taken.
+func takePrepaidBalance(ctx context.Context, tx *gorm.DB, cardID uint64, balance, want float64) (float64, error) {
+	for attempt := 0; attempt < guardedDeductAttempts; attempt++ {
+		if balance <= 0 || want <= 0 {
+			return 0, nil
+		}
+		deduct := math.Min(balance, want)
 		res := tx.WithContext(ctx).
 			Model(&models.PrepaidCard{}).
-			Where("id = ?", card.ID).
+			Where("id = ? AND balance >= ?", cardID, deduct-billQuotaEpsilon).
 			Update("balance", gorm.Expr("balance - ?", deduct))
 		if res.Error != nil {
-			return res.Error
+			return 0, res.Error
+		}
+		if res.RowsAffected > 0 {
+			return deduct, nil
+		}
+		// A concurrent deduction lowered the balance; reload and retry with what is left.
+		var fresh models.PrepaidCard
+		if errFind := tx.WithContext(ctx).Select("balance").Where("id = ?", cardID).Take(&fresh).Error; errFind != nil {
+			if errors.Is(errFind, gorm.ErrRecordNotFound) {
+				return 0, nil
+			}
+			return 0, errFind
 		}
-		remaining -= deduct
+		balance = fresh.Balance
 	}
-
-	return nil
+	return 0, nil
 }
 
-// loadTodayUsageAmount sums today's usage cost in local time.
+// loadTodayUsageAmount sums the usage cost of the current BILLING_TIMEZONE day.
 func loadTodayUsageAmount(ctx context.Context, db *gorm.DB, userID uint64, userGroupID *uint64, now time.Time) (float64, error) {
 	if db == nil {
 		return 0, errors.New("nil db")
 	}
-	loc := time.Local
-	localNow := now.In(loc)
-	todayStart := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, loc)
+	todayStart := internalsettings.BillingDayStart(now)
 	var costMicros int64
 	q := db.WithContext(ctx).
 		Model(&models.Usage{}).
-		Where("user_id = ? AND requested_at >= ?", userID, todayStart).
+		Where("user_id = ? AND requested_at >= ?", userID, todayStart.UTC()).
 		Select("COALESCE(SUM(cost_micros), 0)")
 	if userGroupID != nil && *userGroupID != 0 {
 		q = q.Where("user_group_id = ?", *userGroupID)
@@ -375,7 +709,8 @@ func loadTodayUsageAmount(ctx context.Context, db *gorm.DB, userID uint64, userG
 	return float64(costMicros) / 1_000_000, nil
 }
 
-// accessMetadataFromContext extracts access metadata from a gin context.
+// accessMetadataFromContext extracts access metadata from a gin context. The request ID
+// assigned by the logging middleware is added under "request_id".
 func accessMetadataFromContext(ctx context.Context) map[string]string {
 	if ctx == nil {
 		return nil
@@ -384,18 +719,21 @@ func accessMetadataFromContext(ctx context.Context) map[string]string {
 	if !ok || ginCtx == nil {
 		return nil
 	}
-	v, exists := ginCtx.Get("accessMetadata")
-	if !exists {
-		return nil
+	var meta map[string]string
+	if v, exists := ginCtx.Get("accessMetadata"); exists {
+		meta, _ = v.(map[string]string)
 	}
-	meta, ok := v.(map[string]string)
-	if !ok {
+	requestID := logging.GetGinRequestID(ginCtx)
+	if meta == nil && requestID == "" {
 		return nil
 	}
-	out := make(map[string]string, len(meta))
+	out := make(map[string]string, len(meta)+1)
 	for k, val := range meta {
 		out[k] = val
 	}
+	if requestID != "" {
+		out["request_id"] = requestID
+	}
 	return out
 }
 
@@ -440,6 +778,27 @@ func buildUsageErrorDetail(ctx context.Context, record coreusage.Record) (*int,
 	return &statusValue, datatypes.JSON(payload)
 }
 
+// isStreamingRequest reports whether the request behind ctx was answered as a stream.
+func isStreamingRequest(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return false
+	}
+	if strings.HasPrefix(strings.ToLower(ginCtx.Writer.Header().Get("Content-Type")), "text/event-stream") {
+		return true
+	}
+	if ginCtx.Request == nil || ginCtx.Request.URL == nil {
+		return false
+	}
+	if strings.Contains(ginCtx.Request.URL.Path, "streamGenerateContent") {
+		return true
+	}
+	return strings.EqualFold(ginCtx.Request.URL.Query().Get("alt"), "sse")
+}
+
 func extractUsageErrorContext(ctx context.Context) (int, bool, []byte) {
 	if ctx == nil {
 		return 0, false, nil
@@ -507,21 +866,35 @@ func normalizeTime(t time.Time) time.Time {
 	return t.UTC()
 }
 
-// calculateCost computes usage cost in micros based on billing rules.
-func calculateCost(ctx context.Context, db *gorm.DB, apiKeyID, userID, authID, billingUserGroupID *uint64, record coreusage.Record) int64 {
-	if db == nil {
-		return 0
+// requestDurationMs returns the milliseconds from requestedAt until reportedAt, or nil when
+// the record has no start time. The SDK stamps requestedAt when the upstream call starts and
+// reports usage once the response finished, so this is the upstream latency as seen by the
+// proxy.
+func requestDurationMs(requestedAt, reportedAt time.Time) *int64 {
+	if requestedAt.IsZero() {
+		return nil
 	}
-	if record.Failed {
-		return 0
+	ms := reportedAt.Sub(requestedAt).Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	return &ms
+}
+
+// calculateCost returns the billed cost and the rule-based cost of a record in micros.
+// Failed records are charged according to the matched rule's bill_failed policy. When
+// the rule prefers upstream cost and the provider reported one, a successful record is
+// billed that cost instead of the rule-based estimate.
+func calculateCost(ctx context.Context, db *gorm.DB, apiKeyID, userID, authID, billingUserGroupID *uint64, record coreusage.Record, stream bool, upstreamCostMicros *int64) (int64, int64) {
+	if db == nil {
+		return 0, 0
 	}
 
 	provider := strings.TrimSpace(record.Provider)
 	model := strings.TrimSpace(record.Model)
 	if provider == "" || model == "" {
-		return 0
+		return 0, 0
 	}
-	providerLower := strings.ToLower(provider)
 
 	var authGroupID *uint64
 	if authID != nil {
@@ -548,104 +921,67 @@ func calculateCost(ctx context.Context, db *gorm.DB, apiKeyID, userID, authID, b
 		}
 	}
 
-	costFromRule := func(rule *models.BillingRule) int64 {
-		if rule == nil {
-			return 0
-		}
+	resolution, errResolve := billing.ResolveBillingRule(ctx, db, authGroupID, userGroupID, provider, model)
+	if errResolve != nil {
+		return 0, 0
+	}
+	computed := costFromRule(resolution.Rule, record, stream)
+	if resolution.Rule != nil && resolution.Rule.PreferUpstreamCost && upstreamCostMicros != nil && !record.Failed {
+		return *upstreamCostMicros, computed
+	}
+	return computed, computed
+}
 
-		switch rule.BillingType {
-		case models.BillingTypePerRequest:
-			if rule.PricePerRequest == nil {
-				return 0
-			}
-			return int64(math.Round(*rule.PricePerRequest * 1_000_000))
-		case models.BillingTypePerToken:
-			var total float64
-			if rule.PriceInputToken != nil {
-				total += float64(record.Detail.InputTokens) * (*rule.PriceInputToken)
-			}
-			if rule.PriceOutputToken != nil {
-				total += float64(record.Detail.OutputTokens) * (*rule.PriceOutputToken)
-			}
-			if rule.PriceCacheCreateToken != nil {
-				total += float64(0) * (*rule.PriceCacheCreateToken)
-			}
-			if rule.PriceCacheReadToken != nil {
-				total += float64(record.Detail.CachedTokens) * (*rule.PriceCacheReadToken)
-			}
-			// Token prices are per 1,000,000 tokens, so micros = price_per_million * tokens
-			return int64(math.Round(total))
+// costFromRule returns the rule-based cost of a record in micros. Token prices cover
+// rule.PriceUnit tokens each and per-request prices one request, both in whole currency
+// units, so a price p for n tokens costs p * n / unit * 1,000,000 micros.
+func costFromRule(rule *models.BillingRule, record coreusage.Record, stream bool) int64 {
+	if rule == nil {
+		return 0
+	}
+
+	inputOnly := false
+	if record.Failed {
+		switch rule.BillFailed {
+		case models.BillFailedFull:
+		case models.BillFailedInputOnly:
+			inputOnly = true
 		default:
 			return 0
 		}
 	}
 
-	loadCandidateRules := func(primaryAuthGroupID, primaryUserGroupID, defaultAuthGroupID, defaultUserGroupID uint64) ([]models.BillingRule, error) {
-		q := db.WithContext(ctx).Model(&models.BillingRule{}).Where("is_enabled = true")
-		if defaultAuthGroupID != 0 && defaultUserGroupID != 0 && (defaultAuthGroupID != primaryAuthGroupID || defaultUserGroupID != primaryUserGroupID) {
-			q = q.Where("(auth_group_id = ? AND user_group_id = ?) OR (auth_group_id = ? AND user_group_id = ?)", primaryAuthGroupID, primaryUserGroupID, defaultAuthGroupID, defaultUserGroupID)
-		} else {
-			q = q.Where("auth_group_id = ? AND user_group_id = ?", primaryAuthGroupID, primaryUserGroupID)
-		}
-		q = q.Where("((LOWER(provider) = ? AND model = ?) OR (provider = '' AND model = ''))", providerLower, model)
-
-		var rules []models.BillingRule
-		if errFindRules := q.Find(&rules).Error; errFindRules != nil {
-			return nil, errFindRules
-		}
-		return rules, nil
+	multiplier := 1.0
+	if stream && rule.StreamPriceMultiplier != nil && *rule.StreamPriceMultiplier > 0 {
+		multiplier = *rule.StreamPriceMultiplier
 	}
 
-	if authGroupID != nil && userGroupID != nil {
-		rulesPrimary, errPrimary := loadCandidateRules(*authGroupID, *userGroupID, 0, 0)
-		if errPrimary != nil {
+	switch rule.BillingType {
+	case models.BillingTypePerRequest:
+		if rule.PricePerRequest == nil || inputOnly {
 			return 0
 		}
-		if rule := billing.SelectBillingRule(rulesPrimary, *authGroupID, *userGroupID, 0, 0, provider, model); rule != nil {
-			return costFromRule(rule)
+		return int64(math.Round(*rule.PricePerRequest * 1_000_000 * multiplier))
+	case models.BillingTypePerToken:
+		var total float64
+		if rule.PriceInputToken != nil {
+			total += float64(record.Detail.InputTokens) * (*rule.PriceInputToken)
 		}
-	}
-
-	defaultAuthGroupID, errDefaultAuthGroup := billing.ResolveDefaultAuthGroupID(ctx, db)
-	if errDefaultAuthGroup != nil {
-		return 0
-	}
-	defaultUserGroupID, errDefaultUserGroup := billing.ResolveDefaultUserGroupID(ctx, db)
-	if errDefaultUserGroup != nil {
-		return 0
-	}
-
-	primaryAuthGroupID := authGroupID
-	if primaryAuthGroupID == nil {
-		primaryAuthGroupID = defaultAuthGroupID
-	}
-	primaryUserGroupID := userGroupID
-	if primaryUserGroupID == nil {
-		primaryUserGroupID = defaultUserGroupID
-	}
-	if primaryAuthGroupID == nil || primaryUserGroupID == nil {
-		return 0
-	}
-
-	primaryAuthGroupIDValue := *primaryAuthGroupID
-	primaryUserGroupIDValue := *primaryUserGroupID
-
-	var defaultAuthGroupIDValue uint64
-	if defaultAuthGroupID != nil {
-		defaultAuthGroupIDValue = *defaultAuthGroupID
-	}
-	var defaultUserGroupIDValue uint64
-	if defaultUserGroupID != nil {
-		defaultUserGroupIDValue = *defaultUserGroupID
-	}
-
-	rules, errRules := loadCandidateRules(primaryAuthGroupIDValue, primaryUserGroupIDValue, defaultAuthGroupIDValue, defaultUserGroupIDValue)
-	if errRules != nil {
+		if rule.PriceOutputToken != nil && !inputOnly {
+			total += float64(record.Detail.OutputTokens) * (*rule.PriceOutputToken)
+		}
+		if rule.PriceCacheCreateToken != nil {
+			total += float64(0) * (*rule.PriceCacheCreateToken)
+		}
+		if rule.PriceCacheReadToken != nil {
+			total += float64(record.Detail.CachedTokens) * (*rule.PriceCacheReadToken)
+		}
+		// total is in currency units per price unit; scale it to micros per token.
+		microsPerUnit := 1_000_000 / float64(rule.PriceUnit.Tokens())
+		return int64(math.Round(total * microsPerUnit * multiplier))
+	default:
 		return 0
 	}
-
-	rule := billing.SelectBillingRule(rules, primaryAuthGroupIDValue, primaryUserGroupIDValue, defaultAuthGroupIDValue, defaultUserGroupIDValue, provider, model)
-	return costFromRule(rule)
 }
 
 // Ensure GormUsagePlugin implements coreusage.Plugin.
@@ -11,6 +11,7 @@ import (
 
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/providerkeys"
 )
 
 // stableIDGenerator produces deterministic short IDs with a per-key counter.
@@ -49,8 +50,9 @@ func (g *stableIDGenerator) Next(kind string, parts ...string) (string, string)
 	return fmt.Sprintf("%s:%s", kind, short), short
 }
 
-// synthesizeConfigAuths builds auth records from the in-memory config snapshot.
-func synthesizeConfigAuths(cfg *sdkconfig.Config) []*coreauth.Auth {
+// synthesizeConfigAuths builds auth records from the in-memory config snapshot
+// plus the Qwen/iFlow keys loaded alongside it.
+func synthesizeConfigAuths(cfg *sdkconfig.Config, extra providerkeys.ExtraKeys) []*coreauth.Auth {
 	if cfg == nil {
 		return nil
 	}
@@ -62,6 +64,8 @@ func synthesizeConfigAuths(cfg *sdkconfig.Config) []*coreauth.Auth {
 	out = append(out, synthesizeCodexKeys(cfg, now, idGen)...)
 	out = append(out, synthesizeOpenAICompat(cfg, now, idGen)...)
 	out = append(out, synthesizeVertexCompat(cfg, now, idGen)...)
+	out = append(out, synthesizeQwenKeys(extra.Qwen, now, idGen)...)
+	out = append(out, synthesizeIFlowKeys(extra.IFlow, now, idGen)...)
 	return out
 }
 
@@ -324,6 +328,65 @@ func synthesizeVertexCompat(cfg *sdkconfig.Config, now time.Time, idGen *stableI
 	return out
 }
 
+// synthesizeQwenKeys converts Qwen API key entries into auth records.
+func synthesizeQwenKeys(entries []providerkeys.ExtraKey, now time.Time, idGen *stableIDGenerator) []*coreauth.Auth {
+	return synthesizeExtraKeys("qwen", entries, now, idGen)
+}
+
+// synthesizeIFlowKeys converts iFlow API key entries into auth records.
+func synthesizeIFlowKeys(entries []providerkeys.ExtraKey, now time.Time, idGen *stableIDGenerator) []*coreauth.Auth {
+	return synthesizeExtraKeys("iflow", entries, now, idGen)
+}
+
+// synthesizeExtraKeys converts API key entries for providers without an SDK config section into auth records.
+func synthesizeExtraKeys(provider string, entries []providerkeys.ExtraKey, now time.Time, idGen *stableIDGenerator) []*coreauth.Auth {
+	out := make([]*coreauth.Auth, 0, len(entries))
+	for i := range entries {
+		entry := entries[i]
+		key := strings.TrimSpace(entry.APIKey)
+		if key == "" {
+			continue
+		}
+		prefix := strings.TrimSpace(entry.Prefix)
+		base := strings.TrimSpace(entry.BaseURL)
+		proxyURL := strings.TrimSpace(entry.ProxyURL)
+		id, token := idGen.Next(provider+":apikey", key, base)
+		attrs := map[string]string{
+			"source":    fmt.Sprintf("config:%s[%s]", provider, token),
+			"api_key":   key,
+			"auth_kind": "apikey",
+		}
+		if entry.Priority != 0 {
+			attrs["priority"] = strconv.Itoa(entry.Priority)
+		}
+		if base != "" {
+			attrs["base_url"] = base
+		}
+		if hash := hashModelEntries(entry.Models); hash != "" {
+			attrs["models_hash"] = hash
+		}
+		// The SDK only applies per-key exclusions to its own config sections, so the
+		// selector enforces these from the auth attributes.
+		if excluded := providerkeys.EncodeExcludedModels(entry.ExcludedModels); excluded != "" {
+			attrs[providerkeys.ExcludedModelsAttribute] = excluded
+		}
+		addConfigHeadersToAttrs(entry.Headers, attrs)
+		a := &coreauth.Auth{
+			ID:         id,
+			Provider:   provider,
+			Label:      provider + "-apikey",
+			Prefix:     prefix,
+			Status:     coreauth.StatusActive,
+			ProxyURL:   proxyURL,
+			Attributes: attrs,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
 // addConfigHeadersToAttrs adds header key-values to the auth attribute map.
 func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string) {
 	if len(headers) == 0 || attrs == nil {
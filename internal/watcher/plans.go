@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// tableMark summarizes a table for change detection: its row count and newest row.
+type tableMark struct {
+	count     int64
+	updatedAt time.Time
+	id        uint64
+}
+
+// loadTableMark reads the change-detection mark of the table behind model.
+func loadTableMark(ctx context.Context, db *gorm.DB, model any) (tableMark, error) {
+	var mark tableMark
+	if errCount := db.WithContext(ctx).Model(model).Count(&mark.count).Error; errCount != nil {
+		return mark, errCount
+	}
+	var latest struct {
+		ID        uint64     `gorm:"column:id"`         // Latest row ID.
+		UpdatedAt *time.Time `gorm:"column:updated_at"` // Latest row update time.
+	}
+	res := db.WithContext(ctx).
+		Model(model).
+		Select("id", "updated_at").
+		Order("updated_at DESC, id DESC").
+		Limit(1).
+		Find(&latest)
+	if res.Error != nil {
+		return mark, res.Error
+	}
+	if res.RowsAffected > 0 && latest.UpdatedAt != nil {
+		mark.updatedAt = latest.UpdatedAt.UTC()
+		mark.id = latest.ID
+	}
+	return mark, nil
+}
+
+// PlanAccessChangedAt returns when the running watcher last saw bills or plans change,
+// which moves the plan model allowlists resolved for users. It returns the zero time
+// when no watcher is running.
+func PlanAccessChangedAt() time.Time {
+	w := activeWatcher.Load()
+	if w == nil {
+		return time.Time{}
+	}
+	changed := w.planChangedAt.Load()
+	if changed == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, changed).UTC()
+}
+
+// pollPlans records a plan access change when the bills or plans tables change. Bills
+// change on every deduction, so this only bumps a timestamp; readers re-resolve lazily.
+func (w *dbWatcher) pollPlans(ctx context.Context, force bool) {
+	if w == nil || w.db == nil {
+		return
+	}
+	qctx, cancel := context.WithTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	planMark, errPlans := loadTableMark(qctx, w.db, &models.Plan{})
+	if errPlans != nil {
+		if !errors.Is(errPlans, context.Canceled) {
+			log.WithError(errPlans).Warn("db watcher: query plans latest row failed")
+		}
+		return
+	}
+	billMark, errBills := loadTableMark(qctx, w.db, &models.Bill{})
+	if errBills != nil {
+		if !errors.Is(errBills, context.Canceled) {
+			log.WithError(errBills).Warn("db watcher: query bills latest row failed")
+		}
+		return
+	}
+	if !force && w.planHasMark && planMark == w.planMark && billMark == w.billMark {
+		return
+	}
+	log.Debugf("db watcher: bills or plans changed (plan_updated_at=%s bill_updated_at=%s)", planMark.updatedAt.Format(time.RFC3339Nano), billMark.updatedAt.Format(time.RFC3339Nano))
+	w.planChangedAt.Store(time.Now().UnixNano())
+	w.planMark = planMark
+	w.billMark = billMark
+	w.planHasMark = true
+}
@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Subsystems refreshed by a forced reload.
+const (
+	// SubsystemConfig is the YAML config file.
+	SubsystemConfig = "config"
+	// SubsystemProviderKeys is the provider API key and OAuth alias config stored in the DB.
+	SubsystemProviderKeys = "provider_keys"
+	// SubsystemAuths is the auth records dispatched to the core manager.
+	SubsystemAuths = "auths"
+	// SubsystemSettings is the DB-backed settings snapshot.
+	SubsystemSettings = "settings"
+	// SubsystemPayloadRules is the payload rule config built from model mappings.
+	SubsystemPayloadRules = "payload_rules"
+	// SubsystemPlans is the bill and plan state behind plan model allowlists.
+	SubsystemPlans = "plans"
+)
+
+// ErrWatcherNotRunning indicates that no started watcher can serve a reload.
+var ErrWatcherNotRunning = errors.New("db watcher not running")
+
+// ReloadResult lists the subsystems a forced reload refreshed.
+type ReloadResult struct {
+	Reloaded []string `json:"reloaded"`
+}
+
+// reloadRequest asks the poll loop for an immediate forced pass.
+type reloadRequest struct {
+	done chan ReloadResult
+}
+
+// Reload forces the running watcher to re-read the config file and every DB-backed
+// snapshot instead of waiting for the next change-detecting poll. The pass runs on the
+// poll loop, so it never overlaps a regular poll.
+func Reload(ctx context.Context) (ReloadResult, error) {
+	w := activeWatcher.Load()
+	if w == nil || w.reloadCh == nil || w.shuttingDown.Load() {
+		return ReloadResult{}, ErrWatcherNotRunning
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req := reloadRequest{done: make(chan ReloadResult, 1)}
+	select {
+	case w.reloadCh <- req:
+	case <-ctx.Done():
+		return ReloadResult{}, ctx.Err()
+	}
+	select {
+	case result := <-req.done:
+		return result, nil
+	case <-ctx.Done():
+		return ReloadResult{}, ctx.Err()
+	}
+}
+
+// forceReload re-reads every source regardless of the change-detection snapshots.
+func (w *dbWatcher) forceReload(ctx context.Context) ReloadResult {
+	result := ReloadResult{Reloaded: make([]string, 0, 6)}
+	if strings.TrimSpace(w.configPath) != "" {
+		w.cfgMu.Lock()
+		w.cfgHash = ""
+		w.cfgMu.Unlock()
+		if w.pollConfig(ctx) {
+			result.Reloaded = append(result.Reloaded, SubsystemConfig)
+		}
+	}
+	w.pollProviderKeys(ctx, true)
+	result.Reloaded = append(result.Reloaded, SubsystemProviderKeys)
+	w.markForceAuth()
+	w.pollAuth(ctx, w.consumeForceAuth())
+	result.Reloaded = append(result.Reloaded, SubsystemAuths)
+	w.pollSettings(ctx, true)
+	result.Reloaded = append(result.Reloaded, SubsystemSettings)
+	w.pollPayloadRules(ctx, true)
+	result.Reloaded = append(result.Reloaded, SubsystemPayloadRules)
+	w.pollPlans(ctx, true)
+	result.Reloaded = append(result.Reloaded, SubsystemPlans)
+	return result
+}
@@ -0,0 +1,74 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+)
+
+func TestReloadForcesPassOnPollLoop(t *testing.T) {
+	prev := activeWatcher.Load()
+	t.Cleanup(func() { activeWatcher.Store(prev) })
+
+	activeWatcher.Store(nil)
+	if _, errReload := Reload(context.Background()); !errors.Is(errReload, ErrWatcherNotRunning) {
+		t.Fatalf("expected ErrWatcherNotRunning, got %v", errReload)
+	}
+
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	var reloads atomic.Int32
+	w := &dbWatcher{
+		db:           conn,
+		cfg:          &sdkconfig.Config{},
+		reload:       func(*sdkconfig.Config) { reloads.Add(1) },
+		pollInterval: time.Hour,
+		reloadCh:     make(chan reloadRequest),
+		authStates:   make(map[string]authState),
+		pending:      make(map[string]authUpdate),
+	}
+	w.dispatchCond = sync.NewCond(&w.dispatchMu)
+	activeWatcher.Store(w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx)
+
+	result, errReload := Reload(ctx)
+	if errReload != nil {
+		t.Fatalf("Reload: %v", errReload)
+	}
+	want := []string{SubsystemProviderKeys, SubsystemAuths, SubsystemSettings, SubsystemPayloadRules, SubsystemPlans}
+	if len(result.Reloaded) != len(want) {
+		t.Fatalf("expected %v, got %v", want, result.Reloaded)
+	}
+	for i := range want {
+		if result.Reloaded[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, result.Reloaded)
+		}
+	}
+	// The startup pass and the forced pass both push provider key config.
+	if got := reloads.Load(); got < 2 {
+		t.Fatalf("expected forced provider key reload, got %d reload(s)", got)
+	}
+	BeginShutdown()
+	before := reloads.Load()
+	if _, errReload := Reload(ctx); !errors.Is(errReload, ErrWatcherNotRunning) {
+		t.Fatalf("expected reloads to be refused during shutdown, got %v", errReload)
+	}
+	if got := reloads.Load(); got != before {
+		t.Fatalf("expected no reload during shutdown, got %d more", got-before)
+	}
+}
@@ -1,28 +1,32 @@
 package watcher
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	sdkcliproxy "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	internalaccess "github.com/router-for-me/CLIProxyAPIBusiness/internal/access"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/modelmapping"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/payloadrule"
 	"github.com/router-for-me/CLIProxyAPIBusiness/internal/providerkeys"
 	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
 	log "github.com/sirupsen/logrus"
@@ -38,8 +42,29 @@ const (
 	defaultQueryTimeout = 10 * time.Second
 	// defaultDispatchBuffer defines the pending update buffer size.
 	defaultDispatchBuffer = 2048
+	// dispatchSendTimeout bounds how long one update may wait for the consumer before the
+	// rest of the batch is requeued.
+	dispatchSendTimeout = 5 * time.Second
 )
 
+var (
+	// dispatchPendingDepth exposes the number of auth updates waiting for dispatch via expvar.
+	dispatchPendingDepth = expvar.NewInt("watcher_dispatch_pending")
+	// dispatchDropped exposes the number of auth updates dropped at the pending cap via expvar.
+	dispatchDropped = expvar.NewInt("watcher_dispatch_dropped")
+)
+
+// DispatchPendingDepth returns the number of auth updates waiting for dispatch.
+func DispatchPendingDepth() int64 {
+	return dispatchPendingDepth.Value()
+}
+
+// DispatchDropped returns the total number of auth updates dropped because the pending
+// queue was full.
+func DispatchDropped() int64 {
+	return dispatchDropped.Value()
+}
+
 // authState caches an auth hash and its last update time.
 type authState struct {
 	hash      string
@@ -53,14 +78,6 @@ type authUpdate struct {
 	auth   *coreauth.Auth
 }
 
-// payloadParamEntry represents a payload rule parameter entry.
-type payloadParamEntry struct {
-	Path      string `json:"path"`
-	RuleType  string `json:"rule_type"`
-	ValueType string `json:"value_type"`
-	Value     any    `json:"value"`
-}
-
 // payloadRuleRow mirrors the DB row used to build payload configs.
 type payloadRuleRow struct {
 	ID             uint64         `gorm:"column:id"`               // Payload rule ID.
@@ -88,12 +105,20 @@ type dbWatcher struct {
 	reload     func(*sdkconfig.Config)
 
 	pollInterval time.Duration
+	reloadCh     chan reloadRequest // Forced reload requests served by the poll loop.
+	shuttingDown atomic.Bool        // Set by BeginShutdown; suppresses polls and reloads.
 
 	// config polling
 	cfgMu     sync.RWMutex
 	cfg       *sdkconfig.Config
+	extraKeys providerkeys.ExtraKeys // Qwen/iFlow keys that the SDK config cannot hold.
 	cfgHash   string
 	forceAuth bool
+	// modifyAll re-dispatches every auth on the next auth poll, for config file changes
+	// that can affect model registration beyond what the auth hashes capture.
+	modifyAll bool
+	// aliasDirty lists OAuth model alias channels whose auths must re-register models.
+	aliasDirty map[string]struct{}
 
 	// auth snapshot
 	authMu       sync.RWMutex
@@ -124,6 +149,18 @@ type dbWatcher struct {
 	oauthLatestAt     time.Time
 	oauthLatestID     uint64
 	oauthHasLatest    bool
+	providerKeysHash  string // Content hash of the config derived from provider keys.
+	// providerChangedAt is when the provider key or mapping snapshot last changed, in
+	// Unix nanoseconds; readers use it to invalidate cached model catalogs.
+	providerChangedAt atomic.Int64
+
+	// plan access snapshot (stored in Plan + Bill tables)
+	planMark    tableMark
+	billMark    tableMark
+	planHasMark bool
+	// planChangedAt is when bills or plans last changed, in Unix nanoseconds; readers use
+	// it to invalidate cached plan model allowlists.
+	planChangedAt atomic.Int64
 
 	// dispatch queue
 	queueMu sync.RWMutex
@@ -134,11 +171,61 @@ type dbWatcher struct {
 	dispatchCond   *sync.Cond
 	pending        map[string]authUpdate
 	pendingOrder   []string
+	resync         map[string]struct{} // IDs whose updates were dropped; re-sent by the next auth poll.
+	overflowing    bool                // Whether a drop was logged since the queue last had room.
 	dispatchCtx    context.Context
 	dispatchCancel context.CancelFunc
 	wg             sync.WaitGroup
 }
 
+// activeWatcher is the most recently built watcher, kept for read-only snapshot access.
+var activeWatcher atomic.Pointer[dbWatcher]
+
+// CurrentAuths returns a deep copy of the auths held by the running watcher.
+// It reports false when no watcher has been built yet.
+func CurrentAuths() ([]*coreauth.Auth, bool) {
+	w := activeWatcher.Load()
+	if w == nil {
+		return nil, false
+	}
+	return w.SnapshotAuths(), true
+}
+
+// DispatchAuthModify queues auth as a modify update on the running watcher's dispatch
+// queue, so the core manager applies it as soon as the queue drains. It reports false
+// when no watcher is running.
+func DispatchAuthModify(auth *coreauth.Auth) bool {
+	w := activeWatcher.Load()
+	if w == nil || auth == nil || strings.TrimSpace(auth.ID) == "" || w.shuttingDown.Load() {
+		return false
+	}
+	w.enqueueUpdate(authUpdate{action: "modify", id: auth.ID, auth: auth.Clone()})
+	return true
+}
+
+// ProviderCatalogChangedAt returns when the running watcher last saw provider keys or
+// model mappings change. It returns the zero time when no watcher is running.
+func ProviderCatalogChangedAt() time.Time {
+	w := activeWatcher.Load()
+	if w == nil {
+		return time.Time{}
+	}
+	changed := w.providerChangedAt.Load()
+	if changed == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, changed).UTC()
+}
+
+// BeginShutdown stops the running watcher from starting new polls or forced reloads, so
+// config and auths stay fixed while in-flight requests drain. A poll already underway
+// finishes; Stop still has to be called to end the loops.
+func BeginShutdown() {
+	if w := activeWatcher.Load(); w != nil {
+		w.shuttingDown.Store(true)
+	}
+}
+
 // NewDatabaseWatcherFactory builds a watcher factory backed by database polling.
 func NewDatabaseWatcherFactory(db *gorm.DB) sdkcliproxy.WatcherFactory {
 	return func(configPath, authDir string, reload func(*sdkconfig.Config)) (*sdkcliproxy.WatcherWrapper, error) {
@@ -148,10 +235,12 @@ func NewDatabaseWatcherFactory(db *gorm.DB) sdkcliproxy.WatcherFactory {
 			authDir:      strings.TrimSpace(authDir),
 			reload:       reload,
 			pollInterval: defaultPollInterval,
+			reloadCh:     make(chan reloadRequest),
 			authStates:   make(map[string]authState),
 			pending:      make(map[string]authUpdate, defaultDispatchBuffer),
 		}
 		w.dispatchCond = sync.NewCond(&w.dispatchMu)
+		activeWatcher.Store(w)
 		return buildWatcherWrapper(w)
 	}
 }
@@ -263,6 +352,7 @@ func (w *dbWatcher) run(ctx context.Context) {
 	w.pollAuth(ctx, true)
 	w.pollSettings(ctx, true)
 	w.pollPayloadRules(ctx, true)
+	w.pollPlans(ctx, true)
 
 	ticker := time.NewTicker(w.pollInterval)
 	defer ticker.Stop()
@@ -271,25 +361,36 @@ func (w *dbWatcher) run(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
+		case req := <-w.reloadCh:
+			if w.shuttingDown.Load() {
+				req.done <- ReloadResult{Reloaded: []string{}}
+				continue
+			}
+			req.done <- w.forceReload(ctx)
 		case <-ticker.C:
-			w.pollConfig(ctx)
-			w.pollProviderKeys(ctx, false)
+			if w.shuttingDown.Load() {
+				continue
+			}
+			configChanged := w.pollConfig(ctx)
+			w.pollProviderKeys(ctx, configChanged)
 			w.pollAuth(ctx, w.consumeForceAuth())
 			w.pollSettings(ctx, false)
 			w.pollPayloadRules(ctx, false)
+			w.pollPlans(ctx, false)
 		}
 	}
 }
 
-// pollConfig reloads the config file when its contents change.
-func (w *dbWatcher) pollConfig(ctx context.Context) {
+// pollConfig reloads the config file when its contents change and reports whether it did.
+// A reload replaces the provider keys merged into the config, so callers must re-apply them.
+func (w *dbWatcher) pollConfig(ctx context.Context) bool {
 	if w == nil || strings.TrimSpace(w.configPath) == "" {
-		return
+		return false
 	}
 
 	data, errRead := os.ReadFile(w.configPath)
 	if errRead != nil || len(data) == 0 {
-		return
+		return false
 	}
 	sum := sha256.Sum256(data)
 	hash := hex.EncodeToString(sum[:])
@@ -298,13 +399,13 @@ func (w *dbWatcher) pollConfig(ctx context.Context) {
 	prevHash := w.cfgHash
 	w.cfgMu.RUnlock()
 	if prevHash != "" && prevHash == hash {
-		return
+		return false
 	}
 
 	cfg, errLoad := sdkconfig.LoadConfig(w.configPath)
 	if errLoad != nil {
 		log.WithError(errLoad).Warn("db watcher: load config failed")
-		return
+		return false
 	}
 	ensureDBAccessProvider(cfg)
 	cfg.RemoteManagement.DisableControlPanel = true
@@ -313,6 +414,7 @@ func (w *dbWatcher) pollConfig(ctx context.Context) {
 	w.cfgMu.Lock()
 	w.cfg = cfg
 	w.cfgHash = hash
+	w.modifyAll = true
 	w.cfgMu.Unlock()
 
 	if w.reload != nil {
@@ -320,6 +422,7 @@ func (w *dbWatcher) pollConfig(ctx context.Context) {
 	}
 
 	w.markForceAuth()
+	return true
 }
 
 // pollProviderKeys rebuilds provider API key config from DB when it changes.
@@ -402,8 +505,10 @@ func (w *dbWatcher) pollProviderKeys(ctx context.Context, force bool) {
 		}
 	}
 
+	// Degraded keys stay out of the config until an admin reactivates them.
 	var providerRows []models.ProviderAPIKey
 	if errFind := w.db.WithContext(qctx).
+		Where("degraded = ?", false).
 		Order("id ASC").
 		Find(&providerRows).Error; errFind != nil {
 		if errors.Is(errFind, context.Canceled) {
@@ -433,17 +538,25 @@ func (w *dbWatcher) pollProviderKeys(ctx context.Context, force bool) {
 		baseCfg = &sdkconfig.Config{}
 	}
 	next := *baseCfg
-	providerkeys.ApplyToConfig(&next, providerRows, mappingRows)
+	extraKeys := providerkeys.ApplyToConfig(&next, providerRows, mappingRows)
 
-	w.cfgMu.Lock()
-	w.cfg = &next
-	w.cfgMu.Unlock()
+	// Skip the reload when only columns that do not reach the config changed.
+	hash := hashProviderKeyConfig(&next, extraKeys)
+	if force || hash != w.providerKeysHash {
+		w.cfgMu.Lock()
+		w.cfg = &next
+		w.extraKeys = extraKeys
+		w.markAliasChangesLocked(baseCfg.OAuthModelAlias, next.OAuthModelAlias)
+		w.cfgMu.Unlock()
 
-	if w.reload != nil {
-		w.reload(&next)
+		if w.reload != nil {
+			w.reload(&next)
+		}
+		w.markForceAuth()
+		w.providerKeysHash = hash
 	}
-	w.markForceAuth()
 
+	w.providerChangedAt.Store(time.Now().UnixNano())
 	w.providerHasLatest = hasProvider
 	w.providerLatestAt = providerAt
 	w.providerLatestID = providerID
@@ -474,6 +587,94 @@ func ensureDBAccessProvider(cfg *sdkconfig.Config) {
 	}
 }
 
+// providerKeyDigest is the part of the config derived from provider keys and mappings.
+type providerKeyDigest struct {
+	GeminiKey           []sdkconfig.GeminiKey                  `json:"gemini"`
+	CodexKey            []sdkconfig.CodexKey                   `json:"codex"`
+	ClaudeKey           []sdkconfig.ClaudeKey                  `json:"claude"`
+	OpenAICompatibility []sdkconfig.OpenAICompatibility        `json:"openai"`
+	OAuthModelAlias     map[string][]sdkconfig.OAuthModelAlias `json:"oauth_alias"`
+	Extra               providerkeys.ExtraKeys                 `json:"extra"`
+}
+
+// hashProviderKeyConfig hashes the provider-key derived parts of cfg, like cfgHash does
+// for the config file, so unrelated row updates do not trigger a reload.
+func hashProviderKeyConfig(cfg *sdkconfig.Config, extra providerkeys.ExtraKeys) string {
+	if cfg == nil {
+		return ""
+	}
+	data, errMarshal := json.Marshal(providerKeyDigest{
+		GeminiKey:           cfg.GeminiKey,
+		CodexKey:            cfg.CodexKey,
+		ClaudeKey:           cfg.ClaudeKey,
+		OpenAICompatibility: cfg.OpenAICompatibility,
+		OAuthModelAlias:     cfg.OAuthModelAlias,
+		Extra:               extra,
+	})
+	if errMarshal != nil {
+		return ""
+	}
+	return hashBytes(data)
+}
+
+// markAliasChangesLocked records alias channels whose entries differ between prev and
+// next and reports whether any did. Callers must hold cfgMu.
+func (w *dbWatcher) markAliasChangesLocked(prev, next map[string][]sdkconfig.OAuthModelAlias) bool {
+	changed := false
+	mark := func(channel string) {
+		if hashAliasEntries(prev[channel]) == hashAliasEntries(next[channel]) {
+			return
+		}
+		if w.aliasDirty == nil {
+			w.aliasDirty = make(map[string]struct{})
+		}
+		w.aliasDirty[channel] = struct{}{}
+		changed = true
+	}
+	for channel := range prev {
+		mark(channel)
+	}
+	for channel := range next {
+		if _, seen := prev[channel]; !seen {
+			mark(channel)
+		}
+	}
+	return changed
+}
+
+// hashAliasEntries hashes alias entries independent of their order.
+func hashAliasEntries(entries []sdkconfig.OAuthModelAlias) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, strings.ToLower(strings.TrimSpace(e.Name))+"\x00"+strings.ToLower(strings.TrimSpace(e.Alias))+"\x00"+strconv.FormatBool(e.Fork))
+	}
+	sort.Strings(items)
+	return hashBytes([]byte(strings.Join(items, "\n")))
+}
+
+// aliasChannelDirty reports whether auth belongs to an alias channel in dirty, mirroring
+// how the SDK resolves the channel when registering models.
+func aliasChannelDirty(dirty map[string]struct{}, auth *coreauth.Auth) bool {
+	if len(dirty) == 0 || auth == nil {
+		return false
+	}
+	authKind := strings.ToLower(strings.TrimSpace(auth.Attributes["auth_kind"]))
+	if authKind == "" {
+		if kind, _ := auth.AccountInfo(); strings.EqualFold(kind, "api_key") {
+			authKind = "apikey"
+		}
+	}
+	channel := coreauth.OAuthModelAliasChannel(auth.Provider, authKind)
+	if channel == "" {
+		return false
+	}
+	_, ok := dirty[channel]
+	return ok
+}
+
 // markForceAuth flags that auths should be polled immediately.
 func (w *dbWatcher) markForceAuth() {
 	w.cfgMu.Lock()
@@ -505,7 +706,9 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 	}
 	var latest latestRow
 	hasLatest := false
+	// Unscoped so moving an auth to the trash, which bumps updated_at, triggers a reload.
 	errLatest := w.db.WithContext(qctx).
+		Unscoped().
 		Model(&models.Auth{}).
 		Select("id", "updated_at").
 		Order("updated_at DESC, id DESC").
@@ -557,7 +760,7 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 
 	var rows []models.Auth
 	if errFind := w.db.WithContext(qctx).
-		Select("key", "content", "priority", "created_at", "updated_at").
+		Select("key", "content", "proxy_url", "auth_group_id", "priority", "effective_priority", "created_at", "updated_at").
 		Where("is_available = ?", true).
 		Order("id ASC").
 		Find(&rows).Error; errFind != nil {
@@ -567,6 +770,22 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 		log.WithError(errFind).Warn("db watcher: query auth records failed")
 		return
 	}
+	groupProxies, errGroups := w.loadGroupProxies(qctx)
+	if errGroups != nil {
+		if errors.Is(errGroups, context.Canceled) {
+			return
+		}
+		log.WithError(errGroups).Warn("db watcher: query auth group proxies failed")
+		return
+	}
+	groupHours, errHours := w.loadGroupActiveHours(qctx)
+	if errHours != nil {
+		if errors.Is(errHours, context.Canceled) {
+			return
+		}
+		log.WithError(errHours).Warn("db watcher: query auth group active hours failed")
+		return
+	}
 
 	nextStates := make(map[string]authState, len(rows))
 	nextAuths := make([]*coreauth.Auth, 0, len(rows))
@@ -580,18 +799,31 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 		hash := hashBytes(row.Content)
 		nextStates[key] = authState{hash: hash, updatedAt: row.UpdatedAt}
 
-		a := synthesizeAuthFromDBRow(w.authDir, key, row.Content, row.Priority, row.CreatedAt, row.UpdatedAt)
+		fallbackProxyURL := resolveRowProxyURL(row.ProxyURL, row.AuthGroupID, groupProxies)
+		a := synthesizeAuthFromDBRow(w.authDir, key, row.Content, fallbackProxyURL, row.SelectionPriority(), row.CreatedAt, row.UpdatedAt)
 		if a == nil || a.ID == "" {
 			continue
 		}
+		if primary := row.AuthGroupID.Primary(); primary != nil {
+			if hours, ok := groupHours[*primary]; ok {
+				a.Attributes[models.AuthAttrActiveHoursStart] = hours.Start
+				a.Attributes[models.AuthAttrActiveHoursEnd] = hours.End
+				a.Attributes[models.AuthAttrActiveHoursTimezone] = hours.Timezone
+			}
+		}
 		nextAuths = append(nextAuths, a)
 		nextAuthByID[a.ID] = a
 	}
 
-	w.cfgMu.RLock()
+	w.cfgMu.Lock()
 	cfgSnapshot := w.cfg
-	w.cfgMu.RUnlock()
-	configAuths := synthesizeConfigAuths(cfgSnapshot)
+	extraKeys := w.extraKeys
+	modifyAll := w.modifyAll
+	aliasDirty := w.aliasDirty
+	w.modifyAll = false
+	w.aliasDirty = nil
+	w.cfgMu.Unlock()
+	configAuths := synthesizeConfigAuths(cfgSnapshot, extraKeys)
 	for _, auth := range configAuths {
 		if auth == nil || auth.ID == "" {
 			continue
@@ -602,6 +834,8 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 		nextAuthByID[key] = auth
 	}
 
+	// Taken before enqueueing so updates dropped by this poll wait for the next one.
+	resync := w.takeResync()
 	for id, st := range nextStates {
 		prev, ok := prevStates[id]
 		switch {
@@ -609,7 +843,8 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 			if auth := nextAuthByID[id]; auth != nil {
 				w.enqueueUpdate(authUpdate{action: "add", id: id, auth: auth.Clone()})
 			}
-		case force || prev.hash != st.hash || !prev.updatedAt.Equal(st.updatedAt):
+		case modifyAll || prev.hash != st.hash || !prev.updatedAt.Equal(st.updatedAt) ||
+			aliasChannelDirty(aliasDirty, nextAuthByID[id]):
 			if auth := nextAuthByID[id]; auth != nil {
 				w.enqueueUpdate(authUpdate{action: "modify", id: id, auth: auth.Clone()})
 			}
@@ -623,6 +858,17 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 		w.enqueueUpdate(authUpdate{action: "delete", id: id})
 	}
 
+	// Re-send updates dropped at the pending cap; duplicates of the diff above coalesce.
+	for id := range resync {
+		if auth := nextAuthByID[id]; auth != nil {
+			w.enqueueUpdate(authUpdate{action: "modify", id: id, auth: auth.Clone()})
+			continue
+		}
+		if _, ok := prevStates[id]; !ok {
+			w.enqueueUpdate(authUpdate{action: "delete", id: id})
+		}
+	}
+
 	w.authMu.Lock()
 	w.authStates = nextStates
 	w.lastAuths = nextAuths
@@ -631,6 +877,64 @@ func (w *dbWatcher) pollAuth(ctx context.Context, force bool) {
 	w.authMu.Unlock()
 }
 
+// loadGroupProxies returns the default proxy of every auth group that has one, keyed by group ID.
+func (w *dbWatcher) loadGroupProxies(ctx context.Context) (map[uint64]string, error) {
+	var groups []models.AuthGroup
+	if errFind := w.db.WithContext(ctx).
+		Select("id", "default_proxy_url").
+		Where("default_proxy_url <> ''").
+		Find(&groups).Error; errFind != nil {
+		return nil, errFind
+	}
+	out := make(map[uint64]string, len(groups))
+	for _, group := range groups {
+		if proxyURL := strings.TrimSpace(group.DefaultProxyURL); proxyURL != "" {
+			out[group.ID] = proxyURL
+		}
+	}
+	return out, nil
+}
+
+// loadGroupActiveHours returns the active hours of every auth group that has them, keyed
+// by group ID.
+func (w *dbWatcher) loadGroupActiveHours(ctx context.Context) (map[uint64]models.ActiveHours, error) {
+	var groups []models.AuthGroup
+	if errFind := w.db.WithContext(ctx).
+		Select("id", "active_hours_start", "active_hours_end", "active_hours_timezone").
+		Where("active_hours_start <> '' OR active_hours_end <> ''").
+		Find(&groups).Error; errFind != nil {
+		return nil, errFind
+	}
+	out := make(map[uint64]models.ActiveHours, len(groups))
+	for _, group := range groups {
+		if hours, ok := group.ActiveHours(); ok {
+			out[group.ID] = hours
+		}
+	}
+	return out, nil
+}
+
+// resolveRowProxyURL returns the auth row's own proxy, or else the default proxy of its
+// lowest-numbered group that has one.
+func resolveRowProxyURL(proxyURL string, groupIDs models.AuthGroupIDs, groupProxies map[uint64]string) string {
+	if proxyURL = strings.TrimSpace(proxyURL); proxyURL != "" {
+		return proxyURL
+	}
+	var (
+		bestID uint64
+		best   string
+	)
+	for _, id := range groupIDs.Clean() {
+		if id == nil {
+			continue
+		}
+		if groupProxy, ok := groupProxies[*id]; ok && (best == "" || *id < bestID) {
+			bestID, best = *id, groupProxy
+		}
+	}
+	return best
+}
+
 // pollSettings refreshes DB-backed settings and updates the in-memory config snapshot.
 func (w *dbWatcher) pollSettings(ctx context.Context, force bool) {
 	if w == nil || w.db == nil {
@@ -829,7 +1133,7 @@ func (w *dbWatcher) pollPayloadRules(ctx context.Context, force bool) {
 	var mappingRows []models.ModelMapping
 	errFindMappings := w.db.WithContext(qctx).
 		Model(&models.ModelMapping{}).
-		Select("id", "provider", "model_name", "new_model_name", "selector", "rate_limit", "fork", "is_enabled", "user_group_id").
+		Select("id", "provider", "model_name", "new_model_name", "selector", "rate_limit", "fork", "is_enabled", "user_group_id", "fallback_enabled", "fallback_targets", "timeout_seconds", "max_output_tokens").
 		Find(&mappingRows).Error
 	if errFindMappings != nil {
 		if errors.Is(errFindMappings, context.Canceled) {
@@ -865,6 +1169,8 @@ func (w *dbWatcher) pollPayloadRules(ctx context.Context, force bool) {
 	}
 
 	payloadConfig := buildPayloadConfig(rows)
+	// Appended last so a mapping's token limit wins over its payload rule params.
+	payloadConfig.Override = append(payloadConfig.Override, buildMaxOutputTokensRules(mappingRows)...)
 
 	w.cfgMu.RLock()
 	cfg := w.cfg
@@ -878,11 +1184,12 @@ func (w *dbWatcher) pollPayloadRules(ctx context.Context, force bool) {
 		}
 		w.cfgMu.Lock()
 		w.cfg = &next
+		aliasChanged := oauthMappingsLoaded && w.markAliasChangesLocked(cfg.OAuthModelAlias, next.OAuthModelAlias)
 		w.cfgMu.Unlock()
 		if w.reload != nil {
 			w.reload(&next)
 		}
-		if oauthMappingsLoaded && !mappingSame {
+		if aliasChanged {
 			w.markForceAuth()
 		}
 	}
@@ -912,7 +1219,7 @@ func buildPayloadConfig(rows []payloadRuleRow) sdkconfig.PayloadConfig {
 		if modelName == "" {
 			continue
 		}
-		entries, errParse := parsePayloadParamEntries(row.Params)
+		entries, errParse := payloadrule.ParseEntries(row.Params)
 		if errParse != nil {
 			log.WithError(errParse).Warn("db watcher: parse payload params failed")
 			continue
@@ -997,6 +1304,42 @@ func buildPayloadConfig(rows []payloadRuleRow) sdkconfig.PayloadConfig {
 	}
 }
 
+// maxOutputTokensPaths maps upstream protocols to the request field that bounds output
+// tokens. Codex is omitted because its upstream rejects the field.
+var maxOutputTokensPaths = map[string]string{
+	sdktranslator.FormatOpenAI.String():         "max_tokens",
+	sdktranslator.FormatClaude.String():         "max_tokens",
+	sdktranslator.FormatOpenAIResponse.String(): "max_output_tokens",
+	sdktranslator.FormatGemini.String():         "generationConfig.maxOutputTokens",
+	sdktranslator.FormatAntigravity.String():    "generationConfig.maxOutputTokens",
+}
+
+// buildMaxOutputTokensRules converts mapping output token limits into override payload
+// rules, one per upstream protocol. Mappings with a zero limit inherit the default.
+func buildMaxOutputTokensRules(rows []models.ModelMapping) []sdkconfig.PayloadRule {
+	protocols := make([]string, 0, len(maxOutputTokensPaths))
+	for protocol := range maxOutputTokensPaths {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	out := make([]sdkconfig.PayloadRule, 0)
+	for i := range rows {
+		row := &rows[i]
+		alias := strings.TrimSpace(row.NewModelName)
+		if !row.IsEnabled || row.MaxOutputTokens <= 0 || alias == "" {
+			continue
+		}
+		for _, protocol := range protocols {
+			out = append(out, sdkconfig.PayloadRule{
+				Models: []sdkconfig.PayloadModelRule{{Name: alias, Protocol: protocol}},
+				Params: map[string]any{maxOutputTokensPaths[protocol]: row.MaxOutputTokens},
+			})
+		}
+	}
+	return out
+}
+
 func buildOAuthModelMappings(rows []models.ModelMapping) map[string][]sdkconfig.OAuthModelAlias {
 	if len(rows) == 0 {
 		return nil
@@ -1034,66 +1377,107 @@ func buildOAuthModelMappings(rows []models.ModelMapping) map[string][]sdkconfig.
 	return out
 }
 
-// parsePayloadParamEntries parses JSON params into a normalized entry list.
-func parsePayloadParamEntries(raw datatypes.JSON) ([]payloadParamEntry, error) {
-	trimmed := bytes.TrimSpace(raw)
-	if len(trimmed) == 0 {
-		return nil, nil
-	}
-	switch trimmed[0] {
-	case '[':
-		var entries []payloadParamEntry
-		if errUnmarshal := json.Unmarshal(trimmed, &entries); errUnmarshal != nil {
-			return nil, errUnmarshal
-		}
-		return entries, nil
-	case '{':
-		var obj map[string]any
-		if errUnmarshal := json.Unmarshal(trimmed, &obj); errUnmarshal != nil {
-			return nil, errUnmarshal
-		}
-		entries := make([]payloadParamEntry, 0, len(obj))
-		for key, value := range obj {
-			entry := payloadParamEntry{Path: key, RuleType: "default"}
-			if nested, ok := value.(map[string]any); ok {
-				if rt, okRule := nested["rule_type"].(string); okRule {
-					entry.RuleType = rt
-				}
-				if vt, okValueType := nested["value_type"].(string); okValueType {
-					entry.ValueType = vt
-				}
-				if v, okValue := nested["value"]; okValue {
-					entry.Value = v
-				} else {
-					entry.Value = value
-				}
-			} else {
-				entry.Value = value
-			}
-			entries = append(entries, entry)
-		}
-		return entries, nil
-	default:
-		return nil, nil
-	}
+// dispatchMaxPending returns the pending update cap from settings; 0 means unbounded.
+func dispatchMaxPending() int {
+	return internalsettings.GetInt(internalsettings.WatcherDispatchMaxPendingKey)
+}
+
+// coalesceUpdate merges next into prev, an update for the same auth that has not been
+// sent yet. It reports false when the two cancel out.
+func coalesceUpdate(prev, next authUpdate) (authUpdate, bool) {
+	switch {
+	case prev.action == "add" && next.action == "delete":
+		// The consumer never saw the auth, so there is nothing to delete.
+		return authUpdate{}, false
+	case prev.action == "add":
+		next.action = "add"
+	case prev.action == "delete" && next.action == "add":
+		// The consumer still holds the old auth, so replace it in place.
+		next.action = "modify"
+	}
+	return next, true
 }
 
-// enqueueUpdate stores an auth update for later dispatch.
+// enqueueUpdate stores an auth update for later dispatch, coalescing it with any pending
+// update for the same auth. Updates for new auths beyond the pending cap are dropped and
+// re-sent by the next auth poll.
 func (w *dbWatcher) enqueueUpdate(update authUpdate) {
 	if w == nil || update.id == "" {
 		return
 	}
 	w.dispatchMu.Lock()
-	if _, exists := w.pending[update.id]; !exists {
+	if prev, exists := w.pending[update.id]; exists {
+		if merged, keep := coalesceUpdate(prev, update); keep {
+			w.pending[update.id] = merged
+		} else {
+			// The stale pendingOrder entry is skipped by nextBatch.
+			delete(w.pending, update.id)
+		}
+	} else {
+		if limit := dispatchMaxPending(); limit > 0 && len(w.pending) >= limit {
+			w.dropUpdateLocked(update.id, limit)
+			w.dispatchMu.Unlock()
+			w.markForceAuth()
+			return
+		}
 		w.pendingOrder = append(w.pendingOrder, update.id)
+		w.pending[update.id] = update
 	}
-	w.pending[update.id] = update
+	dispatchPendingDepth.Set(int64(len(w.pending)))
 	if w.dispatchCond != nil {
 		w.dispatchCond.Signal()
 	}
 	w.dispatchMu.Unlock()
 }
 
+// dropUpdateLocked records an update dropped at the pending cap. It logs once per overflow
+// episode. Callers must hold dispatchMu.
+func (w *dbWatcher) dropUpdateLocked(id string, limit int) {
+	if w.resync == nil {
+		w.resync = make(map[string]struct{})
+	}
+	w.resync[id] = struct{}{}
+	dispatchDropped.Add(1)
+	if !w.overflowing {
+		w.overflowing = true
+		log.Warnf("db watcher: dispatch queue full (%d pending), dropping auth updates until it drains", limit)
+	}
+}
+
+// takeResync returns and clears the IDs whose updates were dropped.
+func (w *dbWatcher) takeResync() map[string]struct{} {
+	w.dispatchMu.Lock()
+	defer w.dispatchMu.Unlock()
+	out := w.resync
+	w.resync = nil
+	return out
+}
+
+// requeue puts updates that could not be sent back at the front of the queue. Updates
+// enqueued since they were taken are merged on top of them.
+func (w *dbWatcher) requeue(updates []authUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+	w.dispatchMu.Lock()
+	defer w.dispatchMu.Unlock()
+	order := make([]string, 0, len(updates)+len(w.pendingOrder))
+	for _, update := range updates {
+		if newer, exists := w.pending[update.id]; exists {
+			merged, keep := coalesceUpdate(update, newer)
+			if !keep {
+				delete(w.pending, update.id)
+				continue
+			}
+			update = merged
+		}
+		w.pending[update.id] = update
+		order = append(order, update.id)
+	}
+	w.pendingOrder = append(order, w.pendingOrder...)
+	dispatchPendingDepth.Set(int64(len(w.pending)))
+}
+
 // dispatchLoop sends queued updates to the configured channel until canceled.
 func (w *dbWatcher) dispatchLoop(ctx context.Context) {
 	for {
@@ -1109,19 +1493,43 @@ func (w *dbWatcher) dispatchLoop(ctx context.Context) {
 		if !ok {
 			return
 		}
-		for _, update := range batch {
+		for i, update := range batch {
 			val, okEncode := encodeUpdate(encoder, update)
 			if !okEncode {
 				continue
 			}
-			func() {
-				defer func() { _ = recover() }()
-				queue.Send(val)
-			}()
+			if sendUpdate(ctx, queue, val, dispatchSendTimeout) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnf("db watcher: auth update consumer blocked for %s, requeueing %d update(s)", dispatchSendTimeout, len(batch)-i)
+			w.requeue(batch[i:])
+			break
 		}
 	}
 }
 
+// sendUpdate sends val on queue unless ctx ends or the consumer stays blocked past timeout.
+// It reports false only in those two cases; a closed queue counts as sent since the update
+// can never be delivered.
+func sendUpdate(ctx context.Context, queue, val reflect.Value, timeout time.Duration) (sent bool) {
+	defer func() {
+		if recover() != nil {
+			sent = true
+		}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: queue, Send: val},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)},
+	})
+	return chosen == 0
+}
+
 // nextBatch waits for pending updates and returns the next batch.
 func (w *dbWatcher) nextBatch(ctx context.Context) ([]authUpdate, bool) {
 	w.dispatchMu.Lock()
@@ -1137,10 +1545,16 @@ func (w *dbWatcher) nextBatch(ctx context.Context) ([]authUpdate, bool) {
 	}
 	out := make([]authUpdate, 0, len(w.pendingOrder))
 	for _, id := range w.pendingOrder {
-		out = append(out, w.pending[id])
+		update, exists := w.pending[id]
+		if !exists {
+			continue
+		}
+		out = append(out, update)
 		delete(w.pending, id)
 	}
 	w.pendingOrder = w.pendingOrder[:0]
+	w.overflowing = false
+	dispatchPendingDepth.Set(0)
 	return out, true
 }
 
@@ -1193,8 +1607,9 @@ func encodeUpdate(enc *updateEncoder, update authUpdate) (reflect.Value, bool) {
 	return v, true
 }
 
-// synthesizeAuthFromDBRow builds an auth entry from the stored JSON payload.
-func synthesizeAuthFromDBRow(authDir string, key string, payload []byte, priority int, createdAt, updatedAt time.Time) *coreauth.Auth {
+// synthesizeAuthFromDBRow builds an auth entry from the stored JSON payload. A proxy_url in
+// the payload wins over fallbackProxyURL, which carries the row or group proxy.
+func synthesizeAuthFromDBRow(authDir string, key string, payload []byte, fallbackProxyURL string, priority int, createdAt, updatedAt time.Time) *coreauth.Auth {
 	var metadata map[string]any
 	if errUnmarshal := json.Unmarshal(payload, &metadata); errUnmarshal != nil {
 		return nil
@@ -1218,6 +1633,9 @@ func synthesizeAuthFromDBRow(authDir string, key string, payload []byte, priorit
 	if v, ok := metadata["proxy_url"].(string); ok {
 		proxyURL = strings.TrimSpace(v)
 	}
+	if proxyURL == "" {
+		proxyURL = strings.TrimSpace(fallbackProxyURL)
+	}
 
 	prefix := ""
 	if rawPrefix, ok := metadata["prefix"].(string); ok {
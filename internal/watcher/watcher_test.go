@@ -0,0 +1,356 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/db"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/models"
+	"github.com/router-for-me/CLIProxyAPIBusiness/internal/providerkeys"
+	internalsettings "github.com/router-for-me/CLIProxyAPIBusiness/internal/settings"
+)
+
+func TestPollProviderKeysSkipsUnchangedConfig(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	const keyCount = 500
+	rows := make([]models.ProviderAPIKey, 0, keyCount)
+	for i := 0; i < keyCount; i++ {
+		rows = append(rows, models.ProviderAPIKey{
+			Provider: "claude",
+			Name:     fmt.Sprintf("key-%d", i),
+			APIKey:   fmt.Sprintf("sk-test-%d", i),
+		})
+	}
+	if errCreate := conn.CreateInBatches(&rows, 100).Error; errCreate != nil {
+		t.Fatalf("create provider keys: %v", errCreate)
+	}
+
+	reloads := 0
+	w := &dbWatcher{
+		db:           conn,
+		cfg:          &sdkconfig.Config{},
+		reload:       func(*sdkconfig.Config) { reloads++ },
+		pollInterval: time.Second,
+		authStates:   make(map[string]authState),
+		pending:      make(map[string]authUpdate),
+	}
+	w.dispatchCond = sync.NewCond(&w.dispatchMu)
+	ctx := context.Background()
+
+	drain := func() []authUpdate {
+		w.dispatchMu.Lock()
+		defer w.dispatchMu.Unlock()
+		out := make([]authUpdate, 0, len(w.pendingOrder))
+		for _, id := range w.pendingOrder {
+			out = append(out, w.pending[id])
+		}
+		w.pending = make(map[string]authUpdate)
+		w.pendingOrder = nil
+		return out
+	}
+	poll := func() {
+		w.pollProviderKeys(ctx, false)
+		w.pollAuth(ctx, w.consumeForceAuth())
+	}
+
+	w.pollProviderKeys(ctx, true)
+	w.pollAuth(ctx, w.consumeForceAuth())
+	if updates := drain(); len(updates) != keyCount {
+		t.Fatalf("expected %d initial adds, got %d", keyCount, len(updates))
+	}
+	if reloads != 1 {
+		t.Fatalf("expected 1 initial reload, got %d", reloads)
+	}
+
+	if errUpdate := conn.Model(&models.ProviderAPIKey{}).Where("id = ?", rows[10].ID).
+		Updates(map[string]any{"name": "renamed", "updated_at": time.Now().Add(time.Minute)}).Error; errUpdate != nil {
+		t.Fatalf("rename provider key: %v", errUpdate)
+	}
+	poll()
+	if updates := drain(); len(updates) != 0 {
+		t.Fatalf("expected no dispatches for an unrelated update, got %d", len(updates))
+	}
+	if reloads != 1 {
+		t.Fatalf("expected no reload for an unrelated update, got %d", reloads)
+	}
+
+	if errUpdate := conn.Model(&models.ProviderAPIKey{}).Where("id = ?", rows[20].ID).
+		Updates(map[string]any{"prefix": "team", "updated_at": time.Now().Add(2 * time.Minute)}).Error; errUpdate != nil {
+		t.Fatalf("update provider key prefix: %v", errUpdate)
+	}
+	poll()
+	updates := drain()
+	if len(updates) != 1 || updates[0].action != "modify" {
+		t.Fatalf("expected exactly one modify, got %+v", updates)
+	}
+	if reloads != 2 {
+		t.Fatalf("expected a reload for a prefix change, got %d", reloads)
+	}
+}
+
+func TestPollAuthFallsBackToGroupProxy(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	group := models.AuthGroup{Name: "eu", DefaultProxyURL: "socks5://eu-proxy:1080"}
+	if errCreate := conn.Create(&group).Error; errCreate != nil {
+		t.Fatalf("create group: %v", errCreate)
+	}
+	now := time.Now().UTC()
+	auths := []models.Auth{
+		{Key: "group.json", Content: []byte(`{"type":"codex"}`), AuthGroupID: models.AuthGroupIDs{&group.ID}, IsAvailable: true, CreatedAt: now, UpdatedAt: now},
+		{Key: "own.json", Content: []byte(`{"type":"codex"}`), AuthGroupID: models.AuthGroupIDs{&group.ID}, ProxyURL: "http://own:8080", IsAvailable: true, CreatedAt: now, UpdatedAt: now},
+		{Key: "content.json", Content: []byte(`{"type":"codex","proxy_url":"http://content:8080"}`), AuthGroupID: models.AuthGroupIDs{&group.ID}, IsAvailable: true, CreatedAt: now, UpdatedAt: now},
+	}
+	if errCreate := conn.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	w := &dbWatcher{
+		db:           conn,
+		cfg:          &sdkconfig.Config{},
+		pollInterval: time.Second,
+		authStates:   make(map[string]authState),
+		pending:      make(map[string]authUpdate),
+	}
+	w.dispatchCond = sync.NewCond(&w.dispatchMu)
+	proxies := func() map[string]string {
+		w.dispatchMu.Lock()
+		defer w.dispatchMu.Unlock()
+		out := make(map[string]string, len(w.pending))
+		for id, update := range w.pending {
+			if update.auth != nil {
+				out[id] = update.auth.ProxyURL
+			}
+		}
+		w.pending = make(map[string]authUpdate)
+		w.pendingOrder = nil
+		return out
+	}
+
+	w.pollAuth(context.Background(), false)
+	got := proxies()
+	if got["group.json"] != "socks5://eu-proxy:1080" || got["own.json"] != "http://own:8080" || got["content.json"] != "http://content:8080" {
+		t.Fatalf("unexpected initial proxies: %+v", got)
+	}
+
+	later := now.Add(time.Minute)
+	if errUpdate := conn.Model(&models.AuthGroup{}).Where("id = ?", group.ID).
+		Update("default_proxy_url", "socks5://eu-proxy-2:1080").Error; errUpdate != nil {
+		t.Fatalf("update group: %v", errUpdate)
+	}
+	if errTouch := conn.Model(&models.Auth{}).Where("1 = 1").Update("updated_at", later).Error; errTouch != nil {
+		t.Fatalf("touch auths: %v", errTouch)
+	}
+	w.pollAuth(context.Background(), false)
+	if got = proxies(); got["group.json"] != "socks5://eu-proxy-2:1080" {
+		t.Fatalf("expected the new group proxy after a change, got %+v", got)
+	}
+}
+
+func TestPollAuthDetectsUpdateAfterUTC8Write(t *testing.T) {
+	conn, errOpen := db.Open(":memory:")
+	if errOpen != nil {
+		t.Fatalf("open db: %v", errOpen)
+	}
+	if errMigrate := db.Migrate(conn); errMigrate != nil {
+		t.Fatalf("migrate: %v", errMigrate)
+	}
+
+	// A UTC+8 client writes the earlier timestamp; its local wall clock is later than the
+	// UTC one written afterwards, so mixed offsets would sort it first.
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	auths := []models.Auth{
+		{Key: "east.json", Content: []byte(`{"type":"codex"}`), IsAvailable: true, CreatedAt: base.In(shanghai), UpdatedAt: base.In(shanghai)},
+		{Key: "west.json", Content: []byte(`{"type":"codex"}`), IsAvailable: true, CreatedAt: base.Add(-time.Hour), UpdatedAt: base.Add(-time.Hour)},
+	}
+	if errCreate := conn.Create(&auths).Error; errCreate != nil {
+		t.Fatalf("create auths: %v", errCreate)
+	}
+
+	w := &dbWatcher{
+		db:           conn,
+		cfg:          &sdkconfig.Config{},
+		pollInterval: time.Second,
+		authStates:   make(map[string]authState),
+		pending:      make(map[string]authUpdate),
+	}
+	w.dispatchCond = sync.NewCond(&w.dispatchMu)
+	drain := func() map[string]authUpdate {
+		w.dispatchMu.Lock()
+		defer w.dispatchMu.Unlock()
+		out := w.pending
+		w.pending = make(map[string]authUpdate)
+		w.pendingOrder = nil
+		return out
+	}
+
+	w.pollAuth(context.Background(), false)
+	if got := drain(); len(got) != 2 {
+		t.Fatalf("expected both auths on the first poll, got %d", len(got))
+	}
+
+	if errUpdate := conn.Model(&models.Auth{}).Where("key = ?", "west.json").Updates(map[string]any{
+		"content":    []byte(`{"type":"codex","label":"moved"}`),
+		"updated_at": base.Add(30 * time.Minute),
+	}).Error; errUpdate != nil {
+		t.Fatalf("update auth: %v", errUpdate)
+	}
+	w.pollAuth(context.Background(), false)
+	got := drain()
+	if _, ok := got["west.json"]; !ok || len(got) != 1 {
+		t.Fatalf("expected the UTC update to be detected, got %v", got)
+	}
+}
+
+func TestEnqueueUpdateCoalescesPendingActions(t *testing.T) {
+	w := &dbWatcher{pending: make(map[string]authUpdate)}
+	w.dispatchCond = sync.NewCond(&w.dispatchMu)
+
+	w.enqueueUpdate(authUpdate{action: "add", id: "added-then-deleted"})
+	w.enqueueUpdate(authUpdate{action: "delete", id: "added-then-deleted"})
+	w.enqueueUpdate(authUpdate{action: "add", id: "added-then-modified"})
+	w.enqueueUpdate(authUpdate{action: "modify", id: "added-then-modified"})
+	w.enqueueUpdate(authUpdate{action: "modify", id: "modified-then-deleted"})
+	w.enqueueUpdate(authUpdate{action: "delete", id: "modified-then-deleted"})
+	w.enqueueUpdate(authUpdate{action: "delete", id: "deleted-then-added"})
+	w.enqueueUpdate(authUpdate{action: "add", id: "deleted-then-added"})
+
+	batch, ok := w.nextBatch(context.Background())
+	if !ok {
+		t.Fatalf("expected a batch")
+	}
+	got := make(map[string]string, len(batch))
+	for _, update := range batch {
+		got[update.id] = update.action
+	}
+	want := map[string]string{
+		"added-then-modified":   "add",
+		"modified-then-deleted": "delete",
+		"deleted-then-added":    "modify",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for id, action := range want {
+		if got[id] != action {
+			t.Fatalf("expected %s for %s, got %v", action, id, got)
+		}
+	}
+	if depth := DispatchPendingDepth(); depth != 0 {
+		t.Fatalf("expected pending depth 0 after the batch, got %d", depth)
+	}
+}
+
+func TestEnqueueUpdateDropsBeyondCapAndResyncs(t *testing.T) {
+	internalsettings.StoreDBConfig(time.Now(), map[string]json.RawMessage{
+		internalsettings.WatcherDispatchMaxPendingKey: json.RawMessage(`2`),
+	})
+	t.Cleanup(func() { internalsettings.StoreDBConfig(time.Time{}, nil) })
+
+	w := &dbWatcher{pending: make(map[string]authUpdate)}
+	w.dispatchCond = sync.NewCond(&w.dispatchMu)
+	droppedBefore := DispatchDropped()
+
+	w.enqueueUpdate(authUpdate{action: "add", id: "a"})
+	w.enqueueUpdate(authUpdate{action: "add", id: "b"})
+	w.enqueueUpdate(authUpdate{action: "add", id: "c"})
+	// Updates to IDs already pending still coalesce at the cap.
+	w.enqueueUpdate(authUpdate{action: "modify", id: "a"})
+
+	if depth := DispatchPendingDepth(); depth != 2 {
+		t.Fatalf("expected pending depth 2, got %d", depth)
+	}
+	if dropped := DispatchDropped() - droppedBefore; dropped != 1 {
+		t.Fatalf("expected 1 dropped update, got %d", dropped)
+	}
+	if !w.consumeForceAuth() {
+		t.Fatalf("expected a drop to force the next auth poll")
+	}
+	if _, ok := w.takeResync()["c"]; !ok {
+		t.Fatalf("expected the dropped update to be marked for resync")
+	}
+}
+
+func TestSendUpdateGivesUpOnBlockedConsumer(t *testing.T) {
+	queue := reflect.ValueOf(make(chan int))
+	val := reflect.ValueOf(1)
+	if sendUpdate(context.Background(), queue, val, 10*time.Millisecond) {
+		t.Fatalf("expected the send to time out")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if sendUpdate(ctx, queue, val, time.Minute) {
+		t.Fatalf("expected the send to stop once the context is canceled")
+	}
+
+	w := &dbWatcher{pending: make(map[string]authUpdate)}
+	w.dispatchCond = sync.NewCond(&w.dispatchMu)
+	w.enqueueUpdate(authUpdate{action: "delete", id: "a"})
+	w.requeue([]authUpdate{{action: "add", id: "a"}, {action: "modify", id: "b"}})
+	batch, _ := w.nextBatch(context.Background())
+	if len(batch) != 1 || batch[0].id != "b" {
+		t.Fatalf("expected the unsent add to cancel against the newer delete, got %v", batch)
+	}
+}
+
+func TestBuildMaxOutputTokensRules(t *testing.T) {
+	rules := buildMaxOutputTokensRules([]models.ModelMapping{
+		{Provider: "claude", ModelName: "claude-long", NewModelName: "long", MaxOutputTokens: 4096, IsEnabled: true},
+		{Provider: "openai", ModelName: "gpt-mini", NewModelName: "inherit", IsEnabled: true},
+		{Provider: "openai", ModelName: "gpt-off", NewModelName: "disabled", MaxOutputTokens: 10, IsEnabled: false},
+	})
+	if len(rules) != len(maxOutputTokensPaths) {
+		t.Fatalf("expected one rule per protocol for the limited mapping, got %d", len(rules))
+	}
+	for _, rule := range rules {
+		model := rule.Models[0]
+		if model.Name != "long" || rule.Params[maxOutputTokensPaths[model.Protocol]] != 4096 {
+			t.Fatalf("unexpected rule %+v", rule)
+		}
+	}
+}
+
+func TestSynthesizeExtraKeysCarriesExcludedModels(t *testing.T) {
+	auths := synthesizeConfigAuths(&sdkconfig.Config{}, providerkeys.ExtraKeys{
+		Qwen:  []providerkeys.ExtraKey{{APIKey: "sk-qwen", ExcludedModels: []string{" Qwen3-* ", "qwen-max", "qwen3-*"}}},
+		IFlow: []providerkeys.ExtraKey{{APIKey: "sk-iflow", BaseURL: "https://iflow.example.com"}},
+	})
+	if len(auths) != 2 {
+		t.Fatalf("expected 2 auths, got %d", len(auths))
+	}
+	for _, a := range auths {
+		switch a.Provider {
+		case "qwen":
+			if got := a.Attributes[providerkeys.ExcludedModelsAttribute]; got != "qwen-max,qwen3-*" {
+				t.Fatalf("expected normalized exclusions on the qwen auth, got %q", got)
+			}
+			if !providerkeys.ExcludesModel(a.Attributes, "qwen3-coder") || providerkeys.ExcludesModel(a.Attributes, "qwen-plus") {
+				t.Fatalf("unexpected exclusion matching for %v", a.Attributes)
+			}
+		case "iflow":
+			if _, ok := a.Attributes[providerkeys.ExcludedModelsAttribute]; ok {
+				t.Fatalf("expected no exclusions on the iflow auth, got %v", a.Attributes)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package webui
+
+import (
+	"bytes"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Cache-Control values for web UI responses.
+const (
+	// cacheImmutable lets browsers keep content-hashed assets for a year.
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheRevalidate makes browsers check for a new index or unhashed file on every load.
+	cacheRevalidate = "no-cache"
+)
+
+// hashedAssetPattern captures the content hash of build outputs named like
+// "index-3f9a1c2b.js" or "app.B4xk92Lq.css".
+var hashedAssetPattern = regexp.MustCompile(`[-.]([A-Za-z0-9_]{8,})\.[A-Za-z0-9]+$`)
+
+// Server serves the embedded bundle as a single page application mounted at a path prefix.
+type Server struct {
+	bundle     Bundle
+	fileServer http.Handler
+}
+
+// NewServer constructs a Server for bundle.
+func NewServer(bundle Bundle) *Server {
+	return &Server{bundle: bundle, fileServer: http.FileServer(http.FS(bundle.DistFS))}
+}
+
+// NormalizePrefix cleans a mount prefix to "/" or "/segment[/segment]" without a trailing slash.
+func NormalizePrefix(prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return "/"
+	}
+	return path.Clean("/" + prefix)
+}
+
+// Serve handles a GET or HEAD request for requestPath under prefix: existing files are
+// served with cache headers and page routes fall back to index.html so client side routes
+// survive a reload. It reports false when nothing was served.
+func (s *Server) Serve(w http.ResponseWriter, r *http.Request, prefix, requestPath string) bool {
+	if s.ServeFile(w, r, prefix, requestPath) {
+		return true
+	}
+	if !s.IsPageRoute(prefix, requestPath) {
+		return false
+	}
+	s.ServeIndex(w, prefix)
+	return true
+}
+
+// ServeFile serves the bundle file requestPath names under prefix, if it exists.
+func (s *Server) ServeFile(w http.ResponseWriter, r *http.Request, prefix, requestPath string) bool {
+	name, ok := bundleFileName(prefix, requestPath)
+	if !ok || name == "" || name == "index.html" {
+		return false
+	}
+	info, errStat := fs.Stat(s.bundle.DistFS, name)
+	if errStat != nil || info.IsDir() {
+		return false
+	}
+	w.Header().Set("Cache-Control", CacheControl(name))
+	req := r.Clone(r.Context())
+	req.URL.Path = "/" + name
+	req.URL.RawPath = ""
+	s.fileServer.ServeHTTP(w, req)
+	return true
+}
+
+// IsPageRoute reports whether requestPath is a client side route under prefix, as opposed
+// to a missing asset or file.
+func (s *Server) IsPageRoute(prefix, requestPath string) bool {
+	name, ok := bundleFileName(prefix, requestPath)
+	if !ok {
+		return false
+	}
+	if name == "" || name == "index.html" {
+		return true
+	}
+	return name != "assets" && !strings.HasPrefix(name, "assets/") && !strings.Contains(path.Base(name), ".")
+}
+
+// ServeIndex writes index.html with revalidation headers. Under a non-root prefix the
+// absolute asset references are rewritten to point below the prefix.
+func (s *Server) ServeIndex(w http.ResponseWriter, prefix string) {
+	body := s.bundle.IndexHTML
+	if prefix = NormalizePrefix(prefix); prefix != "/" {
+		body = bytes.ReplaceAll(body, []byte(`"/assets/`), []byte(`"`+prefix+`/assets/`))
+	}
+	w.Header().Set("Cache-Control", cacheRevalidate)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// CacheControl returns the Cache-Control value for a bundle file. Only files under assets/
+// whose name carries a content hash are immutable; a hash contains at least one digit,
+// which keeps names like "vendor-component.js" revalidating.
+func CacheControl(name string) string {
+	if !strings.HasPrefix(name, "assets/") {
+		return cacheRevalidate
+	}
+	match := hashedAssetPattern.FindStringSubmatch(path.Base(name))
+	if match == nil || !strings.ContainsAny(match[1], "0123456789") {
+		return cacheRevalidate
+	}
+	return cacheImmutable
+}
+
+// bundleFileName maps requestPath under prefix to a cleaned bundle-relative name.
+func bundleFileName(prefix, requestPath string) (string, bool) {
+	rel, ok := trimMountPrefix(requestPath, NormalizePrefix(prefix))
+	if !ok {
+		return "", false
+	}
+	return strings.TrimPrefix(path.Clean("/"+rel), "/"), true
+}
+
+// trimMountPrefix returns requestPath relative to prefix.
+func trimMountPrefix(requestPath, prefix string) (string, bool) {
+	if prefix == "/" {
+		return requestPath, true
+	}
+	if requestPath == prefix {
+		return "", true
+	}
+	if strings.HasPrefix(requestPath, prefix+"/") {
+		return strings.TrimPrefix(requestPath, prefix), true
+	}
+	return "", false
+}
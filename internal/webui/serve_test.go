@@ -0,0 +1,84 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testServer() *Server {
+	index := []byte(`<html><script src="/assets/index-3f9a1c2b.js"></script></html>`)
+	return NewServer(Bundle{
+		DistFS: fstest.MapFS{
+			"index.html":                        {Data: index},
+			"favicon.ico":                       {Data: []byte("icon")},
+			"assets/index-3f9a1c2b.js":          {Data: []byte("console.log(1)")},
+			"assets/vendor-component.js":        {Data: []byte("console.log(2)")},
+			"assets/fonts/inter-Ab12Cd34.woff2": {Data: []byte("font")},
+		},
+		IndexHTML: index,
+	})
+}
+
+func serve(s *Server, prefix, target string) (*httptest.ResponseRecorder, bool) {
+	w := httptest.NewRecorder()
+	ok := s.Serve(w, httptest.NewRequest(http.MethodGet, target, nil), prefix, target)
+	return w, ok
+}
+
+func TestServeSetsCacheHeaders(t *testing.T) {
+	s := testServer()
+	cases := []struct {
+		target string
+		cache  string
+	}{
+		{"/assets/index-3f9a1c2b.js", cacheImmutable},
+		{"/assets/fonts/inter-Ab12Cd34.woff2", cacheImmutable},
+		{"/assets/vendor-component.js", cacheRevalidate},
+		{"/favicon.ico", cacheRevalidate},
+		{"/", cacheRevalidate},
+		{"/index.html", cacheRevalidate},
+	}
+	for _, tc := range cases {
+		w, ok := serve(s, "/", tc.target)
+		if !ok || w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d (served=%t)", tc.target, w.Code, ok)
+		}
+		if got := w.Header().Get("Cache-Control"); got != tc.cache {
+			t.Fatalf("%s: expected Cache-Control %q, got %q", tc.target, tc.cache, got)
+		}
+	}
+}
+
+func TestServeFallsBackToIndexForPageRoutes(t *testing.T) {
+	s := testServer()
+	w, ok := serve(s, "/", "/admin/users/42")
+	if !ok || !strings.Contains(w.Body.String(), "<html>") {
+		t.Fatalf("expected index for a deep link, got %d %q", w.Code, w.Body.String())
+	}
+	for _, target := range []string{"/assets/missing.js", "/missing.png"} {
+		if _, ok := serve(s, "/", target); ok {
+			t.Fatalf("%s: expected missing files not to fall back to index", target)
+		}
+	}
+}
+
+func TestServeUnderPrefix(t *testing.T) {
+	s := testServer()
+	if _, ok := serve(s, "/panel", "/admin/users"); ok {
+		t.Fatal("expected paths outside the prefix to be ignored")
+	}
+	w, ok := serve(s, "/panel/", "/panel/admin/users")
+	if !ok || !strings.Contains(w.Body.String(), `"/panel/assets/index-3f9a1c2b.js"`) {
+		t.Fatalf("expected index with prefixed asset references, got %q", w.Body.String())
+	}
+	w, ok = serve(s, "/panel", "/panel/assets/index-3f9a1c2b.js")
+	if !ok || w.Body.String() != "console.log(1)" || w.Header().Get("Cache-Control") != cacheImmutable {
+		t.Fatalf("expected prefixed asset, got %d %q", w.Code, w.Body.String())
+	}
+	if _, ok = serve(s, "/panel", "/panelx"); ok {
+		t.Fatal("expected sibling paths sharing the prefix text to be ignored")
+	}
+}